@@ -0,0 +1,173 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The types of hardware attestation reports.
+const (
+	AttestationSEVSNP = "sev-snp" // An AMD SEV-SNP attestation report
+	AttestationTDX    = "tdx"     // An Intel TDX quote (version 4)
+	AttestationSGX    = "sgx"     // An Intel SGX quote (version 3)
+)
+
+// ErrInvalidAttestation is returned when an attestation
+// does not bind the server's TLS public key to the nonce
+// sent by the client.
+var ErrInvalidAttestation = errors.New("kes: invalid attestation")
+
+// Attestation is a hardware attestation report of a KES
+// server running inside a confidential VM or enclave.
+//
+// The report data of the hardware report binds the public
+// key of the server's TLS certificate to a client nonce.
+// See: AttestationReportData
+type Attestation struct {
+	// Type is the type of the hardware report - either
+	// AttestationSEVSNP, AttestationTDX or AttestationSGX.
+	Type string `json:"type"`
+
+	// Report is the raw hardware report resp. quote.
+	Report []byte `json:"report"`
+
+	// PublicKey is the DER-encoded SubjectPublicKeyInfo
+	// of the server's TLS certificate.
+	PublicKey []byte `json:"public_key"`
+
+	// Nonce is the nonce sent by the client.
+	Nonce []byte `json:"nonce"`
+}
+
+// AttestationReportData returns the report data that binds
+// the DER-encoded SubjectPublicKeyInfo of a TLS certificate
+// to the nonce. The report data is the SHA-512 hash of the
+// public key and the nonce.
+func AttestationReportData(publicKey, nonce []byte) [64]byte {
+	h := sha512.New()
+	h.Write([]byte("kes-attestation-v1\n"))
+	h.Write(publicKey)
+	h.Write(nonce)
+
+	var reportData [64]byte
+	copy(reportData[:], h.Sum(nil))
+	return reportData
+}
+
+// ReportData returns the report data contained in
+// the hardware report.
+func (a *Attestation) ReportData() ([]byte, error) {
+	// The offsets of the report data within the
+	// AMD SEV-SNP attestation report and the Intel
+	// TDX resp. SGX quotes.
+	const (
+		SEVSNPOffset = 0x50
+		TDXOffset    = 48 + 520
+		SGXOffset    = 48 + 320
+	)
+	var offset int
+	switch a.Type {
+	case AttestationSEVSNP:
+		offset = SEVSNPOffset
+	case AttestationTDX:
+		offset = TDXOffset
+	case AttestationSGX:
+		offset = SGXOffset
+	default:
+		return nil, fmt.Errorf("kes: unsupported attestation type '%s'", a.Type)
+	}
+	if len(a.Report) < offset+64 {
+		return nil, ErrInvalidAttestation
+	}
+	return a.Report[offset : offset+64], nil
+}
+
+// Verify verifies that the attestation binds the public key
+// of the certificate to the nonce.
+//
+// Verify does not verify the hardware report itself - i.e.
+// its signature, the TCB version and the measurements of the
+// confidential VM or enclave. The caller has to verify the
+// report with the tooling of the hardware vendor and compare
+// the measurements with the expected values.
+func (a *Attestation) Verify(nonce []byte, cert *x509.Certificate) error {
+	if !bytes.Equal(a.PublicKey, cert.RawSubjectPublicKeyInfo) {
+		return ErrInvalidAttestation
+	}
+	if !bytes.Equal(a.Nonce, nonce) {
+		return ErrInvalidAttestation
+	}
+	reportData, err := a.ReportData()
+	if err != nil {
+		return err
+	}
+	expected := AttestationReportData(a.PublicKey, nonce)
+	if subtle.ConstantTimeCompare(reportData, expected[:]) != 1 {
+		return ErrInvalidAttestation
+	}
+	return nil
+}
+
+// Attest requests a hardware attestation report from the
+// server. It sends a random nonce and verifies that the
+// report binds the server's TLS certificate to the nonce.
+// See: Attestation.Verify
+//
+// The caller should verify the hardware report itself
+// before trusting the server. Attest returns an error
+// if the server does not run inside a confidential VM
+// or enclave.
+//
+// Attest verifies the certificate of the TLS connection
+// to the server. Hence, it fails if the server is behind
+// a TLS proxy.
+func (c *Client) Attest() (*Attestation, error) {
+	nonce := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	type Request struct {
+		Nonce []byte `json:"nonce"`
+	}
+	body, err := json.Marshal(Request{
+		Nonce: nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/attest", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var attestation Attestation
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&attestation); err != nil {
+		return nil, err
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return nil, errors.New("kes: cannot verify attestation: no TLS connection")
+	}
+	if err = attestation.Verify(nonce, resp.TLS.PeerCertificates[0]); err != nil {
+		return nil, err
+	}
+	return &attestation, nil
+}
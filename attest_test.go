@@ -0,0 +1,55 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+var attestationReportDataOffsets = []struct {
+	Type   string
+	Offset int
+}{
+	{Type: AttestationSEVSNP, Offset: 0x50}, // 0
+	{Type: AttestationTDX, Offset: 568},     // 1
+	{Type: AttestationSGX, Offset: 368},     // 2
+}
+
+func TestAttestationVerify(t *testing.T) {
+	var (
+		cert  = &x509.Certificate{RawSubjectPublicKeyInfo: []byte("public-key")}
+		nonce = []byte("0123456789abcdef")
+	)
+	for i, test := range attestationReportDataOffsets {
+		reportData := AttestationReportData(cert.RawSubjectPublicKeyInfo, nonce)
+		report := make([]byte, test.Offset+64+128)
+		copy(report[test.Offset:], reportData[:])
+
+		attestation := &Attestation{
+			Type:      test.Type,
+			Report:    report,
+			PublicKey: cert.RawSubjectPublicKeyInfo,
+			Nonce:     nonce,
+		}
+		if err := attestation.Verify(nonce, cert); err != nil {
+			t.Fatalf("Test %d: failed to verify attestation: %v", i, err)
+		}
+		if err := attestation.Verify([]byte("fedcba9876543210"), cert); err != ErrInvalidAttestation {
+			t.Fatalf("Test %d: verified attestation for another nonce", i)
+		}
+		if err := attestation.Verify(nonce, &x509.Certificate{RawSubjectPublicKeyInfo: []byte("other-key")}); err != ErrInvalidAttestation {
+			t.Fatalf("Test %d: verified attestation for another certificate", i)
+		}
+
+		report[test.Offset] ^= 1
+		if err := attestation.Verify(nonce, cert); err != ErrInvalidAttestation {
+			t.Fatalf("Test %d: verified attestation with modified report data", i)
+		}
+		if attestation.Report = report[:test.Offset+63]; attestation.Verify(nonce, cert) != ErrInvalidAttestation {
+			t.Fatalf("Test %d: verified truncated report", i)
+		}
+	}
+}
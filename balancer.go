@@ -0,0 +1,233 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LookupEndpoints resolves the host of the given endpoint and
+// returns one endpoint for each of its IP addresses. It can be
+// used to spread requests across all KES servers behind a DNS
+// name. For example:
+//   endpoints, err := LookupEndpoints(ctx, "https://kes.example.com:7373")
+//   if err != nil {
+//       // handle error
+//   }
+//   client.Endpoints = endpoints
+//
+// The returned endpoints contain IP addresses. Therefore, the
+// client verifies the server certificates against the IP
+// addresses - unless tls.Config.ServerName is set to the host
+// name of the endpoint.
+func LookupEndpoints(ctx context.Context, endpoint string) ([]string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return nil, fmt.Errorf("kes: invalid endpoint '%s'", endpoint)
+	}
+	if net.ParseIP(host) != nil {
+		return []string{endpoint}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		e := *u
+		if port == "" {
+			e.Host = addr
+			if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+				e.Host = "[" + addr + "]"
+			}
+		} else {
+			e.Host = net.JoinHostPort(addr, port)
+		}
+		endpoints = append(endpoints, e.String())
+	}
+	return endpoints, nil
+}
+
+// loadBalancer spreads requests across a set of KES server
+// endpoints in a round-robin fashion.
+//
+// It keeps track of which endpoints are reachable. Once an
+// endpoint becomes unreachable, the loadBalancer takes it
+// offline for an exponentially increasing period of time.
+// Afterwards, it probes whether the endpoint is reachable
+// again before sending requests to it.
+type loadBalancer struct {
+	endpoints []*endpoint
+	err       error // Non-nil if an endpoint is invalid
+	next      uint32
+}
+
+// endpoint is a KES server endpoint and its health state.
+type endpoint struct {
+	URL *url.URL
+
+	lock    sync.Mutex
+	offline bool
+	probing bool
+	retryAt time.Time     // Time when the endpoint should be probed
+	backoff time.Duration // Duration the endpoint is taken offline
+}
+
+// newLoadBalancer returns a new loadBalancer that spreads
+// requests across the given endpoints.
+func newLoadBalancer(endpoints []string) *loadBalancer {
+	lb := &loadBalancer{
+		endpoints: make([]*endpoint, 0, len(endpoints)),
+	}
+	for _, e := range endpoints {
+		u, err := url.Parse(e)
+		if err != nil {
+			lb.err = err
+			return lb
+		}
+		if u.Scheme == "" || u.Host == "" {
+			lb.err = fmt.Errorf("kes: invalid endpoint '%s'", e)
+			return lb
+		}
+		lb.endpoints = append(lb.endpoints, &endpoint{URL: u})
+	}
+	return lb
+}
+
+// pick returns the next endpoint that should receive a request.
+// It skips all endpoints that have been tried already. If all
+// remaining endpoints are offline, pick returns the one that
+// became unreachable first. If all endpoints have been tried,
+// pick returns nil.
+//
+// pick starts probing offline endpoints once their offline
+// period has passed, using the given HTTP client.
+func (lb *loadBalancer) pick(client *http.Client, tried []*endpoint) *endpoint {
+	var (
+		n        = uint32(len(lb.endpoints))
+		start    = atomic.AddUint32(&lb.next, 1)
+		fallback *endpoint
+		retryAt  time.Time
+	)
+	for i := uint32(0); i < n; i++ {
+		e := lb.endpoints[(start+i)%n]
+		if containsEndpoint(tried, e) {
+			continue
+		}
+
+		e.lock.Lock()
+		offline, probe := e.offline, e.offline && !e.probing && time.Now().After(e.retryAt)
+		if probe {
+			e.probing = true
+		}
+		if offline && (fallback == nil || e.retryAt.Before(retryAt)) {
+			fallback, retryAt = e, e.retryAt
+		}
+		e.lock.Unlock()
+
+		if probe {
+			go lb.probe(client, e)
+		}
+		if !offline {
+			return e
+		}
+	}
+	return fallback
+}
+
+// markOnline marks the endpoint as reachable.
+func (lb *loadBalancer) markOnline(e *endpoint) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.offline, e.backoff = false, 0
+}
+
+// markOffline takes the endpoint offline. It doubles
+// the offline period each time the endpoint fails
+// again - up to a limit.
+func (lb *loadBalancer) markOffline(e *endpoint) {
+	const (
+		MinBackoff = 1 * time.Second
+		MaxBackoff = 30 * time.Second
+	)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	switch {
+	case e.backoff < MinBackoff:
+		e.backoff = MinBackoff
+	case e.offline && e.backoff < MaxBackoff:
+		e.backoff *= 2
+		if e.backoff > MaxBackoff {
+			e.backoff = MaxBackoff
+		}
+	}
+	e.offline = true
+	e.retryAt = time.Now().Add(e.backoff)
+}
+
+// probe checks whether the endpoint is reachable by
+// requesting the server version. Any response from
+// the server brings the endpoint back online.
+func (lb *loadBalancer) probe(client *http.Client, e *endpoint) {
+	const Timeout = 5 * time.Second
+
+	defer func() {
+		e.lock.Lock()
+		e.probing = false
+		e.lock.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, e.URL.String()+"/version", nil)
+	if err != nil {
+		lb.markOffline(e)
+		return
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		lb.markOffline(e)
+		return
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	lb.markOnline(e)
+}
+
+func containsEndpoint(endpoints []*endpoint, e *endpoint) bool {
+	for _, endpoint := range endpoints {
+		if endpoint == e {
+			return true
+		}
+	}
+	return false
+}
+
+// isDialError reports whether err has been caused by a
+// failed attempt to connect to the server. In this case,
+// the request has not been sent to the server.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
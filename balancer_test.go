@@ -0,0 +1,82 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadBalancer(t *testing.T) {
+	var requests [2]int
+	newServer := func(i int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != "request" {
+				t.Errorf("Server %d: invalid request body: got '%s'", i, body)
+			}
+			requests[i]++
+		}))
+	}
+	server0, server1 := newServer(0), newServer(1)
+	defer server0.Close()
+	defer server1.Close()
+
+	offline := httptest.NewServer(http.NotFoundHandler())
+	offline.Close()
+
+	client := &retry{
+		Balancer: newLoadBalancer([]string{server0.URL, offline.URL, server1.URL}),
+	}
+	for i := 0; i < 10; i++ {
+		resp, err := client.Post("/v1/key/create/my-key", "text/plain", bytes.NewReader([]byte("request")))
+		if err != nil {
+			t.Fatalf("Request %d: failed to send request: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if requests[0] == 0 || requests[1] == 0 {
+		t.Fatalf("Requests have not been spread across all servers: %v", requests)
+	}
+	if requests[0]+requests[1] != 10 {
+		t.Fatalf("Invalid number of requests: got %d - want %d", requests[0]+requests[1], 10)
+	}
+	if e := client.Balancer.endpoints[1]; !e.offline {
+		t.Fatalf("Unreachable endpoint '%v' has not been taken offline", e.URL)
+	}
+}
+
+var lookupEndpointsTests = []struct {
+	Endpoint   string
+	Endpoints  []string
+	ShouldFail bool
+}{
+	{Endpoint: "https://127.0.0.1:7373", Endpoints: []string{"https://127.0.0.1:7373"}},
+	{Endpoint: "https://[::1]:7373", Endpoints: []string{"https://[::1]:7373"}},
+	{Endpoint: "127.0.0.1:7373", ShouldFail: true},
+}
+
+func TestLookupEndpoints(t *testing.T) {
+	for i, test := range lookupEndpointsTests {
+		endpoints, err := LookupEndpoints(context.Background(), test.Endpoint)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to lookup endpoints: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: lookup should have failed", i)
+		}
+		if len(endpoints) != len(test.Endpoints) {
+			t.Fatalf("Test %d: got %d endpoints - want %d", i, len(endpoints), len(test.Endpoints))
+		}
+		for j := range endpoints {
+			if endpoints[j] != test.Endpoints[j] {
+				t.Fatalf("Test %d: got '%s' - want '%s'", i, endpoints[j], test.Endpoints[j])
+			}
+		}
+	}
+}
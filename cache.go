@@ -0,0 +1,224 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// DEKCache is a client-side cache of data encryption keys.
+// It allows applications that generate or decrypt many DEKs
+// to amortize the corresponding server requests.
+//
+// A Client with a DEKCache returns the same DEK for all calls
+// of GenerateKey with the same key name and context until the
+// cached DEK expires. Further, it remembers the plaintext of
+// generated and decrypted DEKs such that Decrypt does not have
+// to contact the server for recently seen ciphertexts.
+//
+// Since a cached DEK is used to encrypt more than one object,
+// applications that require a unique DEK per object must not
+// use a DEKCache. Also, a cached DEK remains usable even if
+// the key gets deleted or the client loses access to it until
+// the DEK expires. Callers can bypass the cache via the
+// Client.WithoutCache method.
+//
+// The cached plaintexts are encrypted in memory with a random
+// key of the cache. A DEKCache must not be shared between clients
+// of different KES servers.
+type DEKCache struct {
+	// TTL is the duration a DEK stays in the cache.
+	// If 0, it defaults to 5 minutes.
+	TTL time.Duration
+
+	// MaxEntries is the max. number of DEKs in the
+	// cache. Once full, the cache evicts the entry
+	// that expires first. If 0, it defaults to 1000.
+	MaxEntries int
+
+	once    sync.Once
+	aead    cipher.AEAD
+	initErr error
+
+	lock    sync.Mutex
+	entries map[[sha256.Size]byte]*dekCacheEntry
+}
+
+type dekCacheEntry struct {
+	Expiry     time.Time
+	Nonce      []byte
+	Plaintext  []byte // Encrypted with the cache key
+	Ciphertext []byte
+}
+
+// Clear removes all DEKs from the cache.
+func (c *DEKCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = nil
+}
+
+// generated returns a cached DEK generated by the given
+// key with the given context, if any.
+func (c *DEKCache) generated(enclave, key string, context []byte) (DEK, bool) {
+	return c.get(cacheID('g', enclave, key, context, nil))
+}
+
+// decrypted returns the cached plaintext of the given
+// ciphertext, if any.
+func (c *DEKCache) decrypted(enclave, key string, ciphertext, context []byte) ([]byte, bool) {
+	dek, ok := c.get(cacheID('d', enclave, key, context, ciphertext))
+	return dek.Plaintext, ok
+}
+
+// addGenerated adds a DEK generated by the given key with
+// the given context to the cache. Since the plaintext of
+// the DEK is known, it also adds the DEK's ciphertext.
+func (c *DEKCache) addGenerated(enclave, key string, context []byte, dek DEK) {
+	c.add(cacheID('g', enclave, key, context, nil), dek)
+	c.addDecrypted(enclave, key, dek.Ciphertext, context, dek.Plaintext)
+}
+
+// addDecrypted adds the plaintext of the given ciphertext
+// to the cache.
+func (c *DEKCache) addDecrypted(enclave, key string, ciphertext, context, plaintext []byte) {
+	c.add(cacheID('d', enclave, key, context, ciphertext), DEK{
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext,
+	})
+}
+
+func (c *DEKCache) get(id [sha256.Size]byte) (DEK, bool) {
+	if c.init() != nil {
+		return DEK{}, false
+	}
+
+	c.lock.Lock()
+	entry, ok := c.entries[id]
+	if ok && time.Now().After(entry.Expiry) {
+		delete(c.entries, id)
+		ok = false
+	}
+	c.lock.Unlock()
+	if !ok {
+		return DEK{}, false
+	}
+
+	plaintext, err := c.aead.Open(nil, entry.Nonce, entry.Plaintext, id[:])
+	if err != nil {
+		return DEK{}, false
+	}
+	return DEK{
+		Plaintext:  plaintext,
+		Ciphertext: append([]byte(nil), entry.Ciphertext...),
+	}, true
+}
+
+func (c *DEKCache) add(id [sha256.Size]byte, dek DEK) {
+	const (
+		DefaultTTL        = 5 * time.Minute
+		DefaultMaxEntries = 1000
+	)
+	if c.init() != nil {
+		return
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return
+	}
+	ttl, maxEntries := c.TTL, c.MaxEntries
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	entry := &dekCacheEntry{
+		Expiry:     time.Now().Add(ttl),
+		Nonce:      nonce,
+		Plaintext:  c.aead.Seal(nil, nonce, dek.Plaintext, id[:]),
+		Ciphertext: append([]byte(nil), dek.Ciphertext...),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[[sha256.Size]byte]*dekCacheEntry{}
+	}
+	if _, ok := c.entries[id]; !ok && len(c.entries) >= maxEntries {
+		c.evict(len(c.entries) - maxEntries + 1)
+	}
+	c.entries[id] = entry
+}
+
+// evict removes all expired entries. If less than n entries
+// have expired, it removes the entries that expire first
+// until it has removed n entries.
+func (c *DEKCache) evict(n int) {
+	now := time.Now()
+	for id, entry := range c.entries {
+		if now.After(entry.Expiry) {
+			delete(c.entries, id)
+			n--
+		}
+	}
+	for ; n > 0 && len(c.entries) > 0; n-- {
+		var (
+			oldest [sha256.Size]byte
+			expiry time.Time
+		)
+		for id, entry := range c.entries {
+			if expiry.IsZero() || entry.Expiry.Before(expiry) {
+				oldest, expiry = id, entry.Expiry
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// init generates the random key that encrypts
+// the cached plaintexts.
+func (c *DEKCache) init() error {
+	c.once.Do(func() {
+		var key [32]byte
+		if _, c.initErr = rand.Read(key[:]); c.initErr != nil {
+			return
+		}
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			c.initErr = err
+			return
+		}
+		c.aead, c.initErr = cipher.NewGCM(block)
+	})
+	return c.initErr
+}
+
+// cacheID returns the ID of a cache entry. It
+// hashes all values such that the cache does
+// not keep them in memory.
+func cacheID(kind byte, enclave, key string, context, ciphertext []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte{kind})
+	for _, v := range [][]byte{[]byte(enclave), []byte(key), context, ciphertext} {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(v)))
+		h.Write(size[:])
+		h.Write(v)
+	}
+
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
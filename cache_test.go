@@ -0,0 +1,57 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDEKCache(t *testing.T) {
+	cache := &DEKCache{MaxEntries: 4}
+	dek := DEK{
+		Plaintext:  []byte("plaintext"),
+		Ciphertext: []byte("ciphertext"),
+	}
+	cache.addGenerated("", "my-key", []byte("context"), dek)
+
+	cached, ok := cache.generated("", "my-key", []byte("context"))
+	if !ok {
+		t.Fatal("Generated DEK is not cached")
+	}
+	if !bytes.Equal(cached.Plaintext, dek.Plaintext) || !bytes.Equal(cached.Ciphertext, dek.Ciphertext) {
+		t.Fatalf("Invalid DEK: got %v - want %v", cached, dek)
+	}
+	if plaintext, ok := cache.decrypted("", "my-key", dek.Ciphertext, []byte("context")); !ok || !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatalf("Invalid plaintext: got '%s' - want '%s'", plaintext, dek.Plaintext)
+	}
+
+	if _, ok = cache.generated("", "my-key", nil); ok {
+		t.Fatal("Found DEK for a different context")
+	}
+	if _, ok = cache.generated("my-enclave", "my-key", []byte("context")); ok {
+		t.Fatal("Found DEK of a different enclave")
+	}
+	for _, entry := range cache.entries {
+		if bytes.Contains(entry.Plaintext, dek.Plaintext) {
+			t.Fatal("Cached plaintext is not encrypted")
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		cache.addDecrypted("", "my-key", []byte{byte(i)}, nil, dek.Plaintext)
+	}
+	if n := len(cache.entries); n != cache.MaxEntries {
+		t.Fatalf("Cache exceeds its max. size: got %d - want %d", n, cache.MaxEntries)
+	}
+
+	cache = &DEKCache{TTL: time.Nanosecond}
+	cache.addGenerated("", "my-key", nil, dek)
+	time.Sleep(time.Millisecond)
+	if _, ok = cache.generated("", "my-key", nil); ok {
+		t.Fatal("Found expired DEK")
+	}
+}
@@ -6,15 +6,21 @@ package kes
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -50,6 +56,19 @@ type Client struct {
 	// For example: https://127.0.0.1:7373
 	Endpoint string
 
+	// Endpoints is an optional list of KES server
+	// HTTPS endpoints. If not empty, the client spreads
+	// requests across all endpoints and ignores Endpoint.
+	//
+	// If an endpoint is not reachable, the client sends
+	// the request to another endpoint. It stops sending
+	// requests to the unreachable endpoint until a health
+	// check indicates that it is reachable again.
+	//
+	// Endpoints must not be modified once the client
+	// has been used. See: LookupEndpoints
+	Endpoints []string
+
 	// HTTPClient is the HTTP client.
 	//
 	// The HTTP client uses its http.RoundTripper
@@ -57,8 +76,26 @@ type Client struct {
 	//
 	// It must not be modified concurrently.
 	HTTPClient http.Client
+
+	// Retry controls how the client retries requests
+	// that failed due to a temporary error.
+	// See: RetryPolicy
+	Retry RetryPolicy
+
+	// Cache is an optional client-side cache of data
+	// encryption keys. If not nil, GenerateKey and
+	// Decrypt use the cache. See: DEKCache
+	Cache *DEKCache
+
+	ctx     context.Context
+	lb      *loadBalancer
+	enclave string
 }
 
+// balancerLock guards the lazy initialization of
+// the client load balancers. See: Client.loadBalancer
+var balancerLock sync.Mutex
+
 // NewClient returns a new KES client with the given
 // KES server endpoint that uses the given TLS certficate
 // mTLS authentication.
@@ -104,6 +141,65 @@ func NewClientWithConfig(endpoint string, config *tls.Config) *Client {
 	}
 }
 
+// WithContext returns a copy of the client that sends all
+// requests with the given context. Once the context is done,
+// pending requests are canceled and no further retry is made.
+//
+// WithContext can be used to limit the duration of individual
+// calls. For example:
+//   ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//   defer cancel()
+//   key, err := client.WithContext(ctx).GenerateKey("my-key", nil)
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c.loadBalancer() // Share the load balancer with the copy
+
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+// WithoutCache returns a copy of the client that does not
+// use the client-side DEK cache. Applications that must not
+// reuse DEKs - e.g. for compliance reasons - can use it to
+// bypass the cache for individual calls. For example:
+//   dek, err := client.WithoutCache().GenerateKey("my-key", nil)
+func (c *Client) WithoutCache() *Client {
+	c.loadBalancer() // Share the load balancer with the copy
+
+	client := *c
+	client.Cache = nil
+	return &client
+}
+
+// retryClient returns a retry client that sends requests
+// via the client's HTTP client according to its retry
+// policy and context.
+func (c *Client) retryClient() *retry {
+	return &retry{
+		Client:   c.HTTPClient,
+		Policy:   c.Retry,
+		Context:  c.ctx,
+		Balancer: c.loadBalancer(),
+	}
+}
+
+// loadBalancer returns the load balancer of the client
+// or nil if the client has no list of Endpoints. It
+// creates the load balancer on its first invocation.
+func (c *Client) loadBalancer() *loadBalancer {
+	if len(c.Endpoints) == 0 {
+		return nil
+	}
+
+	balancerLock.Lock()
+	defer balancerLock.Unlock()
+
+	if c.lb == nil {
+		c.lb = newLoadBalancer(c.Endpoints)
+	}
+	return c.lb
+}
+
 // DEK is a data encryption key. It has a plaintext
 // and a ciphertext representation.
 //
@@ -190,7 +286,7 @@ func (d *DEK) UnmarshalBinary(data []byte) error {
 // Version tries to fetch the version information from the
 // KES server.
 func (c *Client) Version() (string, error) {
-	client := retry(c.HTTPClient)
+	client := c.retryClient()
 	resp, err := client.Get(fmt.Sprintf("%s/version", c.Endpoint))
 	if err != nil {
 		return "", err
@@ -210,6 +306,89 @@ func (c *Client) Version() (string, error) {
 	return response.Version, nil
 }
 
+// State is the state of a component - e.g. the
+// key store - of the KES server.
+type State struct {
+	// Up is true if the component is available.
+	Up bool
+
+	// Error describes why the component is not
+	// available. It is empty if Up is true.
+	Error string
+}
+
+// Status is the status of a KES server.
+type Status struct {
+	Version string        // The server version
+	Uptime  time.Duration // The time since the server has been started
+
+	KeyStore State  // The state of the key store
+	KMS      *State // The state of the KMS. It is nil if no KMS is used.
+	Entropy  *State // The state of the entropy source. It is nil if it is not health-tested.
+
+	CacheHits   uint64 // The number of keys served from the cache
+	CacheMisses uint64 // The number of keys fetched from the key store
+}
+
+// Status fetches the status of the KES server - e.g.
+// whether its key store is available.
+func (c *Client) Status() (Status, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/status", c.Endpoint))
+	if err != nil {
+		return Status{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Status{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type StateResponse struct {
+		State string `json:"state"`
+		Error string `json:"error"`
+	}
+	type Response struct {
+		Version  string         `json:"version"`
+		Uptime   uint64         `json:"uptime"`
+		KeyStore StateResponse  `json:"keystore"`
+		KMS      *StateResponse `json:"kms"`
+		Entropy  *StateResponse `json:"entropy"`
+		Cache    struct {
+			Hits   uint64 `json:"hits"`
+			Misses uint64 `json:"misses"`
+		} `json:"cache"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return Status{}, err
+	}
+
+	status := Status{
+		Version: response.Version,
+		Uptime:  time.Duration(response.Uptime) * time.Second,
+		KeyStore: State{
+			Up:    response.KeyStore.State == "up",
+			Error: response.KeyStore.Error,
+		},
+		CacheHits:   response.Cache.Hits,
+		CacheMisses: response.Cache.Misses,
+	}
+	if response.KMS != nil {
+		status.KMS = &State{
+			Up:    response.KMS.State == "up",
+			Error: response.KMS.Error,
+		}
+	}
+	if response.Entropy != nil {
+		status.Entropy = &State{
+			Up:    response.Entropy.State == "up",
+			Error: response.Entropy.Error,
+		}
+	}
+	return status, nil
+}
+
 // CreateKey tries to create a new cryptographic key with
 // the specified name.
 //
@@ -217,7 +396,7 @@ func (c *Client) Version() (string, error) {
 // application does not have the cryptographic key at
 // any point in time.
 func (c *Client) CreateKey(key string) error {
-	client := retry(c.HTTPClient)
+	client := c.retryClient()
 	resp, err := client.Post(fmt.Sprintf("%s/v1/key/create/%s", c.Endpoint, key), "application/json", nil)
 	if err != nil {
 		return err
@@ -228,6 +407,70 @@ func (c *Client) CreateKey(key string) error {
 	return nil
 }
 
+// CreateKeyWithOps behaves like CreateKey but restricts
+// the new key to the given operations - i.e. "generate",
+// "encrypt", "decrypt" and "export". The server rejects
+// any other use of the key - independent of the policy of
+// the client identity. For example, a key restricted to
+// "decrypt" cannot be used to generate new data keys.
+//
+// A restricted key is only included in backups if
+// its operations include "export".
+func (c *Client) CreateKeyWithOps(key string, ops []string) error {
+	type Request struct {
+		Ops []string `json:"ops"`
+	}
+	body, err := json.Marshal(Request{
+		Ops: ops,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/create/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// CreateImmutableKey behaves like CreateKeyWithOps but
+// marks the new key as immutable. An immutable key can
+// never be deleted - not even by the root identity. It can
+// only be disabled. See: DisableKey
+//
+// Immutable keys should only be used for keys whose
+// deletion would be catastrophic - e.g. root-of-trust keys.
+func (c *Client) CreateImmutableKey(key string, ops []string) error {
+	type Request struct {
+		Ops       []string `json:"ops,omitempty"`
+		Immutable bool     `json:"immutable"`
+	}
+	body, err := json.Marshal(Request{
+		Ops:       ops,
+		Immutable: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/create/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
 // ImportKey tries to import the given key as cryptographic
 // key with the specified name.
 //
@@ -244,7 +487,7 @@ func (c *Client) ImportKey(name string, key []byte) error {
 		return err
 	}
 
-	client := retry(c.HTTPClient)
+	client := c.retryClient()
 	url := fmt.Sprintf("%s/v1/key/import/%s", c.Endpoint, name)
 	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
@@ -259,13 +502,27 @@ func (c *Client) ImportKey(name string, key []byte) error {
 // DeleteKey deletes the given key. Once a key has been deleted
 // all data, that has been encrypted with it, cannot be decrypted
 // anymore.
-func (c *Client) DeleteKey(key string) error {
+func (c *Client) DeleteKey(key string) error { return c.deleteKey(key, "") }
+
+// DeleteKeyIfMatch deletes the given key only if the version of
+// its metadata matches the given version - i.e. if the key has
+// not been modified since it has been described. Otherwise, it
+// returns ErrPreconditionFailed. See: KeyInfo.Version
+func (c *Client) DeleteKeyIfMatch(key, version string) error {
+	return c.deleteKey(key, version)
+}
+
+func (c *Client) deleteKey(key, version string) error {
 	url := fmt.Sprintf("%s/v1/key/delete/%s", c.Endpoint, key)
 	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
 	if err != nil {
 		return err
 	}
-	client := retry(c.HTTPClient)
+	if version != "" {
+		req.Header.Set("If-Match", `"`+version+`"`)
+	}
+
+	client := c.retryClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -276,165 +533,169 @@ func (c *Client) DeleteKey(key string) error {
 	return nil
 }
 
-// GenerateKey generates a new data encryption key (DEK).
-// The context is cryptographically bound to the DEK.
-//
-// A DEK has a plaintext and a ciphertext representation.
-// The plaintext should be used to perform a cryptographic
-// operation - for example: encrypt some data.
-//
-// The ciphertext is the result of encrypting the plaintext
-// with the given key. It should be stored at a durable location
-// but does not need to stay secret. The ciphertext can only
-// be decrypted with the given key at the server.
-//
-// Whenever an application needs the DEK's plaintext representation
-// it should send the ciphertext to the server via the Decrypt method.
-//
-// The context is cryptographically bound to the ciphertext and
-// the same context value must be provided whenever the
-// ciphertext should be decrypted. An application either must
-// remember the context or must be able to re-generate it.
-//
-// If an application does not wish to specify a context
-// value it can set it to nil.
-func (c *Client) GenerateKey(key string, context []byte) (DEK, error) {
-	type Request struct {
-		Context []byte `json:"context,omitempty"` // A context is optional
-	}
-	body, err := json.Marshal(Request{
-		Context: context,
-	})
+// DisableKey disables the given key. A disabled key
+// cannot be used for any key operation - e.g. to generate
+// or decrypt data keys - until it gets enabled again.
+func (c *Client) DisableKey(key string) error { return c.disableKey(key, "") }
+
+// DisableKeyIfMatch disables the given key only if the version
+// of its metadata matches the given version. Otherwise, it
+// returns ErrPreconditionFailed. See: KeyInfo.Version
+func (c *Client) DisableKeyIfMatch(key, version string) error {
+	return c.disableKey(key, version)
+}
+
+func (c *Client) disableKey(key, version string) error {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v1/key/disable/%s", c.Endpoint, key), retryBody(nil))
 	if err != nil {
-		return DEK{}, err
+		return err
+	}
+	if version != "" {
+		req.Header.Set("If-Match", `"`+version+`"`)
 	}
 
-	client := retry(c.HTTPClient)
-	url := fmt.Sprintf("%s/v1/key/generate/%s", c.Endpoint, key)
-	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	client := c.retryClient()
+	resp, err := client.Do(req)
 	if err != nil {
-		return DEK{}, err
+		return err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return DEK{}, parseErrorResponse(resp)
+		return parseErrorResponse(resp)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	type Response struct {
-		Plaintext  []byte `json:"plaintext"`
-		Ciphertext []byte `json:"ciphertext"`
+// EnableKey enables the given key after it has been
+// disabled. See: DisableKey
+func (c *Client) EnableKey(key string) error {
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/key/enable/%s", c.Endpoint, key), "application/json", nil)
+	if err != nil {
+		return err
 	}
-	const limit = 1 << 20
-	var response Response
-	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
-		return DEK{}, err
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
 	}
-	return DEK(response), nil
+	return nil
 }
 
-// Encrypt encrypts and authentictes the given plaintext
-// with the specified key and returns the corresponding
-// ciphertext on success.
+// ScheduleKeyDeletion schedules the deletion of the given key
+// after the given number of days - between 7 and 30. If days
+// is 0, the key gets deleted after 30 days. It returns the point
+// in time when the key will be deleted.
 //
-// An optional context value gets authenticated but is not
-// encrypted. Therefore, the same context value must be provided
-// for decryption. Clients should remember or be able to
-// re-generate the context value.
-func (c *Client) Encrypt(key string, plaintext, context []byte) ([]byte, error) {
+// Until then, the key cannot be used for any key operation and
+// the deletion can be cancelled. See: CancelKeyDeletion
+func (c *Client) ScheduleKeyDeletion(key string, days int) (time.Time, error) {
 	type Request struct {
-		Plaintext []byte `json:"plaintext"`
-		Context   []byte `json:"context,omitempty"` // A context is optional
+		Days int `json:"days,omitempty"`
+	}
+	type Response struct {
+		DeleteAt time.Time `json:"delete_at"`
 	}
 	body, err := json.Marshal(Request{
-		Plaintext: plaintext,
-		Context:   context,
+		Days: days,
 	})
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
 
-	client := retry(c.HTTPClient)
-	url := fmt.Sprintf("%s/v1/key/encrypt/%s", c.Endpoint, key)
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/schedule-deletion/%s", c.Endpoint, key)
 	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, parseErrorResponse(resp)
+		return time.Time{}, parseErrorResponse(resp)
 	}
 	defer resp.Body.Close()
 
-	type Response struct {
-		Ciphertext []byte `json:"ciphertext"`
-	}
 	const limit = 1 << 20
 	var response Response
 	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
-	return response.Ciphertext, nil
+	return response.DeleteAt, nil
 }
 
-// Decrypt tries to decrypt the given ciphertext with the
-// specified key and returns plaintext on success.
-//
-// The context value must match the context used when
-// the ciphertext was produced. If no context was used
-// the context value should be set to nil.
-func (c *Client) Decrypt(key string, ciphertext, context []byte) ([]byte, error) {
+// CancelKeyDeletion cancels the scheduled deletion of the
+// given key. See: ScheduleKeyDeletion
+func (c *Client) CancelKeyDeletion(key string) error {
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/key/cancel-deletion/%s", c.Endpoint, key), "application/json", nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// HoldKey places a legal hold on the given key. A key under
+// legal hold can neither be deleted nor rotated - i.e. no alias
+// referring to it can be changed - until the hold is released.
+// The reason is recorded with the hold and may be empty.
+func (c *Client) HoldKey(key, reason string) error {
 	type Request struct {
-		Ciphertext []byte `json:"ciphertext"`
-		Context    []byte `json:"context,omitempty"` // A context is optional
+		Reason string `json:"reason,omitempty"`
 	}
 	body, err := json.Marshal(Request{
-		Ciphertext: ciphertext,
-		Context:    context,
+		Reason: reason,
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	client := retry(c.HTTPClient)
-	url := fmt.Sprintf("%s/v1/key/decrypt/%s", c.Endpoint, key)
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/hold/set/%s", c.Endpoint, key)
 	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, parseErrorResponse(resp)
+		return parseErrorResponse(resp)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	type Response struct {
-		Plaintext []byte `json:"plaintext"`
+// ReleaseKey releases the legal hold on the given key.
+// See: HoldKey
+func (c *Client) ReleaseKey(key string) error {
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/key/hold/release/%s", c.Endpoint, key), "application/json", nil)
+	if err != nil {
+		return err
 	}
-	const limit = 1 << 20
-	var response Response
-	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
-		return nil, err
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
 	}
-	return response.Plaintext, nil
+	return nil
 }
 
-// SetPolicy adds the given policy to the set of policies.
-// There can be just one policy with one particular name at
-// one point in time.
-//
-// If there is already a policy with the given name then SetPolicy
-// overwrites the existing policy with the given one.
+// CreateSecret stores the opaque secret value - e.g. an API
+// token or a certificate - under the given name. Keys and
+// opaque secrets share the same namespace. Hence, it returns
+// ErrKeyExists if there is already a key or secret with the
+// given name.
 //
-// If there are identities assigned to an existing policy then
-// SetPolicy will not remove those identities before overwriting
-// the policy. Instead, it will just updated the policy entry such
-// that the given policy automatically applies to those identities.
-func (c *Client) SetPolicy(name string, policy *Policy) error {
-	content, err := json.Marshal(policy)
+// An opaque secret cannot be used as cryptographic key - e.g.
+// to generate data keys.
+func (c *Client) CreateSecret(name string, value []byte) error {
+	type Request struct {
+		Bytes []byte `json:"bytes"`
+	}
+	body, err := json.Marshal(Request{
+		Bytes: value,
+	})
 	if err != nil {
 		return err
 	}
-	client := retry(c.HTTPClient)
-	url := fmt.Sprintf("%s/v1/policy/write/%s", c.Endpoint, name)
-	resp, err := client.Post(url, "application/json", bytes.NewReader(content))
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/secret/create/%s", c.Endpoint, name)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -444,11 +705,15 @@ func (c *Client) SetPolicy(name string, policy *Policy) error {
 	return nil
 }
 
-// GetPolicy returns the policy with the given name. If no such
-// policy exists then GetPolicy returns ErrPolicyNotFound.
-func (c *Client) GetPolicy(name string) (*Policy, error) {
-	client := retry(c.HTTPClient)
-	resp, err := client.Get(fmt.Sprintf("%s/v1/policy/read/%s", c.Endpoint, name))
+// GetSecret returns the opaque secret with the given name.
+// It returns an error if the name refers to a cryptographic
+// key. See: CreateSecret
+func (c *Client) GetSecret(name string) ([]byte, error) {
+	type Response struct {
+		Bytes []byte `json:"bytes"`
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/secret/get/%s", c.Endpoint, name))
 	if err != nil {
 		return nil, err
 	}
@@ -457,29 +722,1617 @@ func (c *Client) GetPolicy(name string) (*Policy, error) {
 	}
 	defer resp.Body.Close()
 
-	const limit = 32 * 1024 * 1024 // A policy might be large
-	decoder := json.NewDecoder(io.LimitReader(resp.Body, limit))
-	decoder.DisallowUnknownFields()
-	var policy Policy
-	if err = decoder.Decode(&policy); err != nil {
+	const limit = 2 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
 		return nil, err
 	}
-	return &policy, nil
+	return response.Bytes, nil
 }
 
-// ListPolicies returns a list of policies with names that
-// match the given glob pattern. For example
-//   policies, err := client.ListPolicies("*") // '*' matches any
-// returns the names of all existing policies.
+// CreateCA creates a new certificate authority (CA) with
+// the given name and returns its PEM-encoded certificate.
+// If commonName is empty, the server uses the name as
+// common name of the CA certificate.
+//
+// The CA private key never leaves the server. Keys, opaque
+// secrets and CAs share the same namespace. Hence, it returns
+// ErrKeyExists if there is already a key, secret or CA with
+// the given name.
+func (c *Client) CreateCA(name, commonName string) ([]byte, error) {
+	type Request struct {
+		CommonName string `json:"common_name,omitempty"`
+	}
+	type Response struct {
+		Certificate string `json:"certificate"`
+	}
+	body, err := json.Marshal(Request{
+		CommonName: commonName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/ca/create/%s", c.Endpoint, name)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return []byte(response.Certificate), nil
+}
+
+// SignCertificate requests a certificate for the PEM-encoded
+// certificate request (CSR) from the CA with the given name.
+// The certificate is valid for the given lifetime and can be
+// used for mTLS. If ttl is 0, the server uses its default
+// lifetime - i.e. 24 hours.
+//
+// It returns the PEM-encoded certificate. The certificate
+// chain of the CA can be fetched via CAChain.
+func (c *Client) SignCertificate(name string, csr []byte, ttl time.Duration) ([]byte, error) {
+	type Request struct {
+		CSR string `json:"csr"`
+		TTL string `json:"ttl,omitempty"`
+	}
+	type Response struct {
+		Certificate string `json:"certificate"`
+	}
+	req := Request{
+		CSR: string(csr),
+	}
+	if ttl > 0 {
+		req.TTL = ttl.String()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/ca/sign/%s", c.Endpoint, name)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return []byte(response.Certificate), nil
+}
+
+// CAChain returns the PEM-encoded certificate chain of
+// the CA with the given name. It can be used to verify
+// certificates issued by the CA. See: SignCertificate
+func (c *Client) CAChain(name string) ([]byte, error) {
+	type Response struct {
+		Chain string `json:"chain"`
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/ca/chain/%s", c.Endpoint, name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return []byte(response.Chain), nil
+}
+
+// CreateSigningKey creates a new asymmetric signing key with
+// the given name for the JWS algorithm - either "ES256",
+// "EdDSA" or "RS256". If algorithm is empty, the server
+// creates an ES256 signing key.
+//
+// The private key never leaves the server. Keys, opaque
+// secrets and signing keys share the same namespace. Hence,
+// it returns ErrKeyExists if there is already a key, secret
+// or signing key with the given name.
+func (c *Client) CreateSigningKey(name, algorithm string) error {
+	type Request struct {
+		Algorithm string `json:"algorithm,omitempty"`
+	}
+	body, err := json.Marshal(Request{
+		Algorithm: algorithm,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/jws/create/%s", c.Endpoint, name)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// SignJWS signs the payload with the signing key with the
+// given name and returns the JWS in compact serialization.
+// The typ header parameter is optional. For example, the
+// payload of a JWT is a JSON object of claims and its type
+// is "JWT".
+//
+// The JWS can be verified with the JWKS of the signing key.
+// See: JWKS
+func (c *Client) SignJWS(name string, payload []byte, typ string) (string, error) {
+	type Request struct {
+		Payload []byte `json:"payload"`
+		Type    string `json:"typ,omitempty"`
+	}
+	type Response struct {
+		JWS string `json:"jws"`
+	}
+	body, err := json.Marshal(Request{
+		Payload: payload,
+		Type:    typ,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/jws/sign/%s", c.Endpoint, name)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 2 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return "", err
+	}
+	return response.JWS, nil
+}
+
+// JWKS returns the JSON-encoded JWK set that contains the
+// public key of the signing key with the given name. It can
+// be served as it is - e.g. by an identity service - such that
+// relying parties can verify a JWS signed by the signing key.
+func (c *Client) JWKS(name string) ([]byte, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/jws/jwks/%s", c.Endpoint, name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	return ioutil.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// DeleteKeysReport describes the result of DeleteKeys.
+type DeleteKeysReport struct {
+	Deleted []string `json:"deleted"` // Names of the deleted keys
+
+	// Skipped contains the name of each key that has not
+	// been deleted since it is immutable or under legal
+	// hold and the reason why.
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// DeleteKeys deletes all keys under the given prefix - e.g.
+// all keys "my-app/..." for the prefix "my-app". Keys that
+// are immutable or under legal hold are not deleted but
+// reported as skipped.
+//
+// If not all keys can be deleted, it returns an error and
+// the report of the keys deleted so far. These keys remain
+// deleted and DeleteKeys can be called again.
+func (c *Client) DeleteKeys(prefix string) (DeleteKeysReport, error) {
+	url := fmt.Sprintf("%s/v1/key/bulk/delete/%s", c.Endpoint, prefix)
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return DeleteKeysReport{}, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return DeleteKeysReport{}, err
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many keys
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return DeleteKeysReport{}, err
+	}
+
+	// The server also reports the keys deleted so far
+	// when it fails to delete a key.
+	var report DeleteKeysReport
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		json.Unmarshal(body, &report)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return report, parseErrorResponse(resp)
+	}
+	if err = json.Unmarshal(body, &report); err != nil {
+		return DeleteKeysReport{}, err
+	}
+	return report, nil
+}
+
+// GenerateKey generates a new data encryption key (DEK).
+// The context is cryptographically bound to the DEK.
+//
+// A DEK has a plaintext and a ciphertext representation.
+// The plaintext should be used to perform a cryptographic
+// operation - for example: encrypt some data.
+//
+// The ciphertext is the result of encrypting the plaintext
+// with the given key. It should be stored at a durable location
+// but does not need to stay secret. The ciphertext can only
+// be decrypted with the given key at the server.
+//
+// Whenever an application needs the DEK's plaintext representation
+// it should send the ciphertext to the server via the Decrypt method.
+//
+// The context is cryptographically bound to the ciphertext and
+// the same context value must be provided whenever the
+// ciphertext should be decrypted. An application either must
+// remember the context or must be able to re-generate it.
+//
+// If an application does not wish to specify a context
+// value it can set it to nil.
+//
+// If the client has a DEK cache, GenerateKey returns a cached
+// DEK, if any. See: DEKCache
+func (c *Client) GenerateKey(key string, context []byte) (DEK, error) {
+	if c.Cache != nil {
+		if dek, ok := c.Cache.generated(c.enclave, key, context); ok {
+			return dek, nil
+		}
+	}
+
+	dek, err := c.generateKey(key, context, "")
+	if err != nil {
+		return DEK{}, err
+	}
+	if c.Cache != nil {
+		c.Cache.addGenerated(c.enclave, key, context, dek)
+	}
+	return dek, nil
+}
+
+// GenerateHybridKey behaves like GenerateKey but the server
+// wraps the DEK with an experimental post-quantum hybrid
+// scheme - X25519 combined with ML-KEM-768. It is meant for
+// data that has to be retained for a long time.
+//
+// The ciphertext records the wrapping algorithm such that
+// the Decrypt method can decrypt it. GenerateHybridKey does
+// not use the DEK cache. It fails if hybrid wrapping is not
+// enabled on the server.
+func (c *Client) GenerateHybridKey(key string, context []byte) (DEK, error) {
+	return c.generateKey(key, context, "hybrid")
+}
+
+// generateKey requests a new DEK from the server that is
+// wrapped using the given wrapping scheme.
+func (c *Client) generateKey(key string, context []byte, wrap string) (DEK, error) {
+	type Request struct {
+		Context []byte `json:"context,omitempty"` // A context is optional
+		Wrap    string `json:"wrap,omitempty"`
+	}
+	body, err := json.Marshal(Request{
+		Context: context,
+		Wrap:    wrap,
+	})
+	if err != nil {
+		return DEK{}, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/generate/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return DEK{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return DEK{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return DEK{}, err
+	}
+	return DEK(response), nil
+}
+
+// Encrypt encrypts and authentictes the given plaintext
+// with the specified key and returns the corresponding
+// ciphertext on success.
+//
+// An optional context value gets authenticated but is not
+// encrypted. Therefore, the same context value must be provided
+// for decryption. Clients should remember or be able to
+// re-generate the context value.
+func (c *Client) Encrypt(key string, plaintext, context []byte) ([]byte, error) {
+	type Request struct {
+		Plaintext []byte `json:"plaintext"`
+		Context   []byte `json:"context,omitempty"` // A context is optional
+	}
+	body, err := json.Marshal(Request{
+		Plaintext: plaintext,
+		Context:   context,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/encrypt/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Ciphertext, nil
+}
+
+// Decrypt tries to decrypt the given ciphertext with the
+// specified key and returns plaintext on success.
+//
+// The context value must match the context used when
+// the ciphertext was produced. If no context was used
+// the context value should be set to nil.
+//
+// If the client has a DEK cache, Decrypt returns the cached
+// plaintext, if any. See: DEKCache
+func (c *Client) Decrypt(key string, ciphertext, context []byte) ([]byte, error) {
+	if c.Cache != nil {
+		if plaintext, ok := c.Cache.decrypted(c.enclave, key, ciphertext, context); ok {
+			return plaintext, nil
+		}
+	}
+
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context,omitempty"` // A context is optional
+	}
+	body, err := json.Marshal(Request{
+		Ciphertext: ciphertext,
+		Context:    context,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/decrypt/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		c.Cache.addDecrypted(c.enclave, key, ciphertext, context, response.Plaintext)
+	}
+	return response.Plaintext, nil
+}
+
+// ReEncrypt decrypts the data key ciphertext with the key
+// and encrypts the plaintext data key with the newKey. It
+// returns the new ciphertext. The plaintext data key never
+// leaves the server.
+//
+// The new ciphertext is bound to the same context. Hence,
+// the context must be provided again for decryption. To
+// re-encrypt, the client must be allowed to generate data
+// keys with the newKey.
+func (c *Client) ReEncrypt(key, newKey string, ciphertext, context []byte) ([]byte, error) {
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context,omitempty"` // A context is optional
+		Key        string `json:"key"`
+	}
+	body, err := json.Marshal(Request{
+		Ciphertext: ciphertext,
+		Context:    context,
+		Key:        newKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/reencrypt/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Ciphertext, nil
+}
+
+// GenerateKeys generates one new data encryption key (DEK)
+// for each context. Each context is cryptographically bound
+// to its DEK. It sends a single request to the server and is
+// therefore more efficient than calling GenerateKey for each
+// context. A server generates up to 1000 DEKs per request.
+//
+// The i-th DEK corresponds to the i-th context. A context
+// may be nil. For example, the following generates 3 DEKs
+// without any context:
+//   deks, err := client.GenerateKeys("my-key", make([][]byte, 3))
+//
+// GenerateKeys either returns all DEKs or an error. It
+// does not use the client-side DEK cache. See: GenerateKey
+func (c *Client) GenerateKeys(key string, contexts [][]byte) ([]DEK, error) {
+	type Request struct {
+		Context []byte `json:"context,omitempty"` // A context is optional
+	}
+	request := make([]Request, 0, len(contexts))
+	for _, context := range contexts {
+		request = append(request, Request{Context: context})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/bulk/generate/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	const limit = 1 << 20
+	var response []Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	if len(response) != len(contexts) {
+		return nil, errors.New("kes: server returned an invalid number of keys")
+	}
+	deks := make([]DEK, 0, len(response))
+	for _, r := range response {
+		deks = append(deks, DEK(r))
+	}
+	return deks, nil
+}
+
+// DecryptKeys decrypts all ciphertexts with the specified key
+// and returns the corresponding plaintexts on success. It sends
+// a single request to the server and is therefore more efficient
+// than calling Decrypt for each ciphertext. A server decrypts up
+// to 1000 ciphertexts per request.
+//
+// The i-th context must match the context used when the i-th
+// ciphertext was produced. If no context was used for any
+// ciphertext, contexts can be nil.
+//
+// DecryptKeys either decrypts all ciphertexts or returns an
+// error. It does not use the client-side DEK cache.
+// See: Decrypt
+func (c *Client) DecryptKeys(key string, ciphertexts, contexts [][]byte) ([][]byte, error) {
+	if contexts != nil && len(contexts) != len(ciphertexts) {
+		return nil, errors.New("kes: number of ciphertexts and contexts does not match")
+	}
+
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context,omitempty"` // A context is optional
+	}
+	request := make([]Request, 0, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		r := Request{Ciphertext: ciphertext}
+		if contexts != nil {
+			r.Context = contexts[i]
+		}
+		request = append(request, r)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/key/bulk/decrypt/%s", c.Endpoint, key)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	const limit = 1 << 20
+	var response []Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	if len(response) != len(ciphertexts) {
+		return nil, errors.New("kes: server returned an invalid number of plaintexts")
+	}
+	plaintexts := make([][]byte, 0, len(response))
+	for _, r := range response {
+		plaintexts = append(plaintexts, r.Plaintext)
+	}
+	return plaintexts, nil
+}
+
+// KeyInfo describes a key at the server.
+type KeyInfo struct {
+	Name string `json:"name"`
+
+	// Type is the type of the key - either "key" for a
+	// cryptographic key, "opaque" for an opaque secret,
+	// "ca" for a certificate authority or "jws" for a
+	// signing key.
+	// See: CreateSecret, CreateCA and CreateSigningKey
+	Type string `json:"type,omitempty"`
+
+	// Version is the version of the key's metadata. It
+	// changes whenever the key gets modified - e.g. disabled
+	// or put under legal hold - but not when the key gets
+	// used. See: DeleteKeyIfMatch and DisableKeyIfMatch
+	Version string `json:"version,omitempty"`
+
+	// CreatedAt is the point in time when the key has
+	// been created. It is zero if the server's key store
+	// cannot report it.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+
+	// LastUsed is the point in time when the key has been
+	// used the last time. It is zero if the key has never
+	// been used since the server started tracking its usage.
+	LastUsed time.Time `json:"last_used,omitempty"`
+
+	// Sealed reports whether the key is encrypted by
+	// the server's KMS at the key store.
+	Sealed bool `json:"sealed"`
+
+	// AllowedOps are the operations the key is restricted
+	// to by its usage policy. If empty, the key can be used
+	// for any operation. See: CreateKeyWithOps
+	AllowedOps []string `json:"allowed_ops,omitempty"`
+
+	// Immutable reports whether the key can never be
+	// deleted. See: CreateImmutableKey
+	Immutable bool `json:"immutable,omitempty"`
+
+	// Disabled reports whether the key has been disabled.
+	// See: DisableKey
+	Disabled bool `json:"disabled,omitempty"`
+
+	// LegalHold is the legal hold on the key, if any.
+	// See: HoldKey
+	LegalHold *LegalHold `json:"legal_hold,omitempty"`
+
+	// PendingDeletion is the scheduled deletion of the
+	// key, if any. See: ScheduleKeyDeletion
+	PendingDeletion *PendingDeletion `json:"pending_deletion,omitempty"`
+
+	// Usage maps each key operation - e.g. "generate" - to
+	// the number of requests that have used the key for it.
+	Usage map[string]uint64 `json:"usage,omitempty"`
+
+	// Policies maps the name of each policy that allows
+	// any key operation - e.g. "generate" - on the key to
+	// the allowed operations. It does not consider policy
+	// conditions.
+	Policies map[string][]string `json:"policies,omitempty"`
+}
+
+// LegalHold describes why, since when and by whom
+// a key is held.
+type LegalHold struct {
+	Reason   string    `json:"reason,omitempty"`
+	Identity Identity  `json:"identity,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// PendingDeletion describes when and by whom the
+// deletion of a key has been scheduled.
+type PendingDeletion struct {
+	DeleteAt time.Time `json:"delete_at"`
+	Identity Identity  `json:"identity,omitempty"`
+}
+
+// ListKeys returns a description of all keys with a
+// name matching the pattern - sorted by name. The
+// pattern is matched segment-wise. Each segment of
+// the pattern is matched as described by path.Match
+// and a "**" segment matches zero or more segments -
+// e.g. "my-app/**" matches all keys under "my-app/".
+// An empty pattern matches all keys.
+func (c *Client) ListKeys(pattern string) ([]KeyInfo, error) {
+	if pattern == "" { // The empty pattern never matches anything
+		pattern = "**" // => default to: list "all" keys
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/key/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many keys
+	var keys []KeyInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ListKeysPage returns a description of at most limit keys
+// with a name matching the pattern - sorted by name - and a
+// continue token. If there are more matching keys, the token
+// is not empty and can be passed to the next ListKeysPage
+// call to list the next keys. Initially, the token should
+// be empty.
+//
+// For example:
+//   var token string
+//   for {
+//       keys, next, err := client.ListKeysPage("my-app/**", token, 1000)
+//       if err != nil { ... }
+//       // process keys
+//       if next == "" { break }
+//       token = next
+//   }
+func (c *Client) ListKeysPage(pattern, token string, limit int) ([]KeyInfo, string, error) {
+	if pattern == "" {
+		pattern = "**"
+	}
+	query := url.Values{}
+	if token != "" {
+		query.Set("continue", token)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/key/list/%s?%s", c.Endpoint, url.PathEscape(pattern), query.Encode()))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limitSize = 64 * 1024 * 1024 // There might be many keys
+	var keys []KeyInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limitSize)).Decode(&keys); err != nil {
+		return nil, "", err
+	}
+	return keys, resp.Header.Get("Kes-Continue-Token"), nil
+}
+
+// ListKeysStream returns a KeyStream that iterates over the
+// descriptions of all keys with a name matching the pattern -
+// sorted by name. In contrast to ListKeys, the server streams
+// the keys such that neither the server nor the client have
+// to keep all descriptions in memory.
+//
+// The returned KeyStream must be closed once the client
+// is done with it.
+func (c *Client) ListKeysStream(pattern string) (*KeyStream, error) {
+	if pattern == "" {
+		pattern = "**"
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/key/list/%s", c.Endpoint, url.PathEscape(pattern)), retryBody(nil))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	return &KeyStream{
+		decoder: json.NewDecoder(resp.Body),
+		closer:  resp.Body,
+	}, nil
+}
+
+// KeyStream iterates over a stream of key descriptions
+// sent by the server. Successive calls to the Next method
+// step through the keys. See: ListKeysStream
+//
+// Closing a KeyStream closes the underlying response
+// body and any subsequent call to Next returns false.
+type KeyStream struct {
+	decoder *json.Decoder
+	closer  io.Closer
+
+	key    KeyInfo
+	err    error
+	closed bool
+}
+
+// Key returns the most recent KeyInfo generated by a
+// call to Next.
+func (s *KeyStream) Key() KeyInfo { return s.key }
+
+// Err returns the first non-EOF error that was encountered
+// while iterating over the stream - including any error the
+// server has sent while streaming the keys.
+//
+// Err does not return any error returned from Close.
+func (s *KeyStream) Err() error { return s.err }
+
+// Next advances the stream to the next KeyInfo, which will
+// then be available through the Key method. It returns false
+// when the stream iteration stops - i.e. by reaching the end
+// of the stream, closing the stream or in case of an error.
+func (s *KeyStream) Next() bool {
+	if s.err != nil || s.closed {
+		return false
+	}
+
+	var line struct {
+		KeyInfo
+		Error string `json:"error"`
+	}
+	if err := s.decoder.Decode(&line); err != nil {
+		if err != io.EOF && !s.closed {
+			s.err = err
+		}
+		return false
+	}
+	if line.Error != "" {
+		s.err = errors.New("kes: failed to list keys: " + line.Error)
+		return false
+	}
+	s.key = line.KeyInfo
+	return true
+}
+
+// Close closes the underlying stream. After Close has
+// been called once the Next method returns false.
+func (s *KeyStream) Close() error {
+	s.closed = true
+	return s.closer.Close()
+}
+
+// WatchKeys subscribes to the changes of all keys with a
+// name matching the pattern and returns a stream of change
+// events - e.g. whenever a key gets created, rotated,
+// disabled or deleted. For example
+//   stream, err := client.WatchKeys("my-app/*")
+// notifies the client about changes of all keys under
+// "my-app/".
+//
+// If no / an empty pattern is provided then WatchKeys
+// uses the pattern '**' as default.
+//
+// The returned WatchStream must be closed once the client
+// is done with it.
+func (c *Client) WatchKeys(pattern string) (*WatchStream, error) {
+	if pattern == "" {
+		pattern = "**"
+	}
+	return c.watch(fmt.Sprintf("%s/v1/key/watch/%s", c.Endpoint, url.PathEscape(pattern)))
+}
+
+// DescribeKey returns a description of the key with
+// the given name. If there is no such key, it returns
+// ErrKeyNotFound.
+func (c *Client) DescribeKey(name string) (*KeyInfo, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/key/describe/%s", c.Endpoint, url.PathEscape(name)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var info KeyInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ProvenanceEvent describes how a key came into existence
+// or left the server's key store - e.g. whether it has been
+// generated by the server, imported or restored from a backup.
+//
+// The event type is one of: "created", "imported", "restored",
+// "deleted", "held", "released", "scheduled" or "cancelled".
+type ProvenanceEvent struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Identity Identity  `json:"identity,omitempty"` // The identity that caused the event, if known
+
+	// Origin describes the context of the event - e.g. the
+	// backup archive the key has been restored from.
+	Origin string `json:"origin,omitempty"`
+
+	// Hash is computed over the event and the hash of the
+	// previous event. The server verifies the chain before
+	// returning it. Comparing the hash of the last event with
+	// a previously seen one detects rewritten provenance.
+	Hash []byte `json:"hash"`
+}
+
+// KeyProvenance returns the provenance of the key with the
+// given name - oldest event first. The provenance of a deleted
+// key remains available. If neither the key nor its provenance
+// exist, it returns ErrKeyNotFound.
+//
+// Keys created before the server started to record provenance
+// have no events.
+func (c *Client) KeyProvenance(name string) ([]ProvenanceEvent, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/key/provenance/%s", c.Endpoint, url.PathEscape(name)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var events []ProvenanceEvent
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// KeyAlias is an alias and the name of the key it
+// refers to.
+type KeyAlias struct {
+	Alias string `json:"alias"`
+	Key   string `json:"key"`
+}
+
+// SetKeyAlias creates or updates the alias such that it
+// refers to the given key. Once set, the alias can be used
+// in place of the key name - e.g. to generate or decrypt
+// data keys. Re-pointing the alias to another key does not
+// affect ciphertexts produced before. They can only be
+// decrypted with the key that has produced them.
+//
+// An alias cannot refer to another alias. If there is a
+// key with the alias name, SetKeyAlias returns ErrKeyExists.
+func (c *Client) SetKeyAlias(alias, key string) error {
+	type Request struct {
+		Key string `json:"key"`
+	}
+	body, err := json.Marshal(Request{Key: key})
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/key/alias/set/%s", c.Endpoint, alias), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// DeleteKeyAlias deletes the given alias. It does not
+// delete the key the alias refers to.
+func (c *Client) DeleteKeyAlias(alias string) error {
+	url := fmt.Sprintf("%s/v1/key/alias/delete/%s", c.Endpoint, alias)
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// ListKeyAliases returns all aliases that match the
+// given pattern - e.g. "my-app/*" - sorted by name.
+// An empty pattern matches all aliases.
+func (c *Client) ListKeyAliases(pattern string) ([]KeyAlias, error) {
+	if pattern == "" {
+		pattern = "**"
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/key/alias/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many aliases
+	var aliases []KeyAlias
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SetPolicy adds the given policy to the set of policies.
+// There can be just one policy with one particular name at
+// one point in time.
+//
+// If there is already a policy with the given name then SetPolicy
+// overwrites the existing policy with the given one.
+//
+// If there are identities assigned to an existing policy then
+// SetPolicy will not remove those identities before overwriting
+// the policy. Instead, it will just updated the policy entry such
+// that the given policy automatically applies to those identities.
+func (c *Client) SetPolicy(name string, policy *Policy) error {
+	return c.setPolicy(name, policy, "")
+}
+
+// SetPolicyIfMatch replaces the policy with the given name only
+// if the version of the existing policy matches the given version
+// - i.e. if the policy has not been modified since it has been
+// fetched. Otherwise, it returns ErrPreconditionFailed.
+// See: GetPolicyVersion
+func (c *Client) SetPolicyIfMatch(name string, policy *Policy, version string) error {
+	return c.setPolicy(name, policy, version)
+}
+
+func (c *Client) setPolicy(name string, policy *Policy, version string) error {
+	content, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/policy/write/%s", c.Endpoint, name)
+	req, err := http.NewRequest(http.MethodPost, url, retryBody(bytes.NewReader(content)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if version != "" {
+		req.Header.Set("If-Match", `"`+version+`"`)
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// GetPolicy returns the policy with the given name. If no such
+// policy exists then GetPolicy returns ErrPolicyNotFound.
+func (c *Client) GetPolicy(name string) (*Policy, error) {
+	policy, _, err := c.GetPolicyVersion(name)
+	return policy, err
+}
+
+// GetPolicyVersion returns the policy with the given name
+// and its version. The version changes whenever the policy
+// gets modified. See: SetPolicyIfMatch
+func (c *Client) GetPolicyVersion(name string) (*Policy, string, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/policy/read/%s", c.Endpoint, name))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 32 * 1024 * 1024 // A policy might be large
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, limit))
+	decoder.DisallowUnknownFields()
+	var policy Policy
+	if err = decoder.Decode(&policy); err != nil {
+		return nil, "", err
+	}
+	return &policy, strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// ListPolicies returns a list of policies with names that
+// match the given glob pattern. For example
+//   policies, err := client.ListPolicies("*") // '*' matches any
+// returns the names of all existing policies.
 //
 // If no / an empty pattern is provided then ListPolicies uses
 // the pattern '*' as default.
 func (c *Client) ListPolicies(pattern string) ([]string, error) {
 	if pattern == "" { // The empty pattern never matches anything
-		pattern = "*" // => default to: list "all" policies
+		pattern = "*" // => default to: list "all" policies
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/policy/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many policies
+	var policies []string
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// WatchPolicies subscribes to the changes of all policies
+// with a name matching the pattern and returns a stream of
+// change events - i.e. whenever a policy gets written or
+// deleted.
+//
+// If no / an empty pattern is provided then WatchPolicies
+// uses the pattern '*' as default.
+//
+// The returned WatchStream must be closed once the client
+// is done with it.
+func (c *Client) WatchPolicies(pattern string) (*WatchStream, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	return c.watch(fmt.Sprintf("%s/v1/policy/watch/%s", c.Endpoint, url.PathEscape(pattern)))
+}
+
+// watch subscribes to the change events sent by the
+// watch API at the given endpoint.
+func (c *Client) watch(endpoint string) (*WatchStream, error) {
+	client := c.retryClient()
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	return &WatchStream{
+		decoder: json.NewDecoder(resp.Body),
+		closer:  resp.Body,
+	}, nil
+}
+
+// DeletePolicy removes the policy with the given name. It will not
+// return an error if no policy exists.
+//
+// If there are identities assigned to the deleted policies then these
+// identities will be removed as well.
+//
+// Therefore, setting an empty policy and deleting a policy have
+// slightly different implications. The former will revoke any
+// access permission for all identities assigned to the policy.
+// The later will remove the policy as well as all identities
+// assigned to it.
+func (c *Client) DeletePolicy(name string) error {
+	url := fmt.Sprintf("%s/v1/policy/delete/%s", c.Endpoint, name)
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// PolicyDecision describes whether an identity is allowed
+// to send requests to an API and the policy rule that decides
+// it. See: Client.TestPolicy
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Path    string `json:"path"`   // The evaluated request URL path
+	Policy  string `json:"policy"` // The policy assigned to the identity, if any
+	Rule    string `json:"rule"`   // The deciding rule - e.g. "allow /v1/key/create/*"
+	Reason  string `json:"reason"`
+
+	// Conditional is true if the policy has conditions
+	// that depend on the particular request - e.g. the
+	// client IP. They are not evaluated.
+	Conditional bool `json:"conditional"`
+}
+
+// TestPolicy evaluates a hypothetical request of the identity
+// to the API - e.g. /v1/key/encrypt - for the key against the
+// current policies of the server. The key may be empty for APIs
+// that don't operate on a key - e.g. /v1/policy/list/*.
+//
+// TestPolicy does not send the request itself. Hence, operators
+// can debug authorization issues without generating real traffic.
+func (c *Client) TestPolicy(identity Identity, api, key string) (*PolicyDecision, error) {
+	type Request struct {
+		Identity Identity `json:"identity"`
+		API      string   `json:"api"`
+		Key      string   `json:"key,omitempty"`
+	}
+	body, err := json.Marshal(Request{
+		Identity: identity,
+		API:      api,
+		Key:      key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/policy/test", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var decision PolicyDecision
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+func (c *Client) AssignIdentity(policy string, id Identity) error {
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/identity/assign/%s/%s", c.Endpoint, policy, id.String())
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+func (c *Client) ListIdentities(pattern string) (map[Identity]string, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/identity/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	const limit = 64 * 1024 * 1024 // There might be many identities
+	response := map[Identity]string{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// IdentityUsage is the number of requests an identity
+// has sent to an API within a time window.
+type IdentityUsage struct {
+	Start    time.Time `json:"start"` // The start of the time window
+	Identity Identity  `json:"identity"`
+	API      string    `json:"api"`
+	Requests uint64    `json:"requests"`
+}
+
+// IdentityUsage returns the number of requests per API and
+// time window of all identities matching the pattern - sorted
+// by window start, identity and API. If since is positive, it
+// only returns the time windows that ended during the last
+// since period.
+//
+// The server only counts requests if request accounting
+// is enabled.
+func (c *Client) IdentityUsage(pattern string, since time.Duration) ([]IdentityUsage, error) {
+	if pattern == "" { // The empty pattern never matches anything
+		pattern = "*" // => default to: all identities
 	}
-	client := retry(c.HTTPClient)
-	resp, err := client.Get(fmt.Sprintf("%s/v1/policy/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	endpoint := fmt.Sprintf("%s/v1/identity/usage/%s", c.Endpoint, url.PathEscape(pattern))
+	if since > 0 {
+		endpoint += "?since=" + url.QueryEscape(since.String())
+	}
+	client := c.retryClient()
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many identities and windows
+	var usage []IdentityUsage
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (c *Client) ForgetIdentity(id Identity) error {
+	url := fmt.Sprintf("%s/v1/identity/forget/%s", c.Endpoint, id.String())
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// RenewIdentity sets the TTL of the identity. Once the TTL
+// has expired, the KES server rejects all requests sent by
+// the identity with ErrIdentityExpired. If ttl is 0, the
+// identity never expires.
+//
+// It returns the point in time when the identity expires.
+// An identity cannot renew itself.
+func (c *Client) RenewIdentity(id Identity, ttl time.Duration) (time.Time, error) {
+	url := fmt.Sprintf("%s/v1/identity/renew/%s?ttl=%s", c.Endpoint, id.String(), ttl)
+	client := c.retryClient()
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Expiry time.Time `json:"expiry"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return time.Time{}, err
+	}
+	return response.Expiry, nil
+}
+
+// Group is a named set of identities with a set of policies.
+// Each member of a group has the permissions of all policies
+// of the group - in addition to the policy assigned to the
+// identity itself, if any.
+type Group struct {
+	Policies []string   `json:"policies"`
+	Members  []Identity `json:"members"`
+}
+
+// SetGroup sets the policies of the group with the given
+// name. If no such group exists, SetGroup creates a new
+// group without members. Otherwise, the members of the
+// group are kept.
+//
+// It returns ErrPolicyNotFound if one of the policies
+// does not exist.
+func (c *Client) SetGroup(name string, policies ...string) error {
+	type Request struct {
+		Policies []string `json:"policies"`
+	}
+	if policies == nil {
+		policies = []string{}
+	}
+	content, err := json.Marshal(Request{Policies: policies})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/group/write/%s", c.Endpoint, name)
+	req, err := http.NewRequest(http.MethodPost, url, retryBody(bytes.NewReader(content)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// GetGroup returns the policies and members of the group
+// with the given name.
+func (c *Client) GetGroup(name string) (*Group, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/group/read/%s", c.Endpoint, name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 32 * 1024 * 1024 // A group might have many members
+	var group Group
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListGroups returns the names of all groups that match
+// the given glob pattern.
+//
+// If no / an empty pattern is provided then ListGroups uses
+// the pattern '*' as default.
+func (c *Client) ListGroups(pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/group/list/%s", c.Endpoint, url.PathEscape(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many groups
+	var groups []string
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// DeleteGroup removes the group with the given name. Its
+// members lose the permissions of the group's policies. It
+// will not return an error if no group exists.
+func (c *Client) DeleteGroup(name string) error {
+	return c.deleteGroup(fmt.Sprintf("%s/v1/group/delete/%s", c.Endpoint, name))
+}
+
+// AddGroupMember adds the identity to the group with the
+// given name. An identity cannot add itself to a group.
+func (c *Client) AddGroupMember(name string, id Identity) error {
+	url := fmt.Sprintf("%s/v1/group/add/%s/%s", c.Endpoint, name, id.String())
+	client := c.retryClient()
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// RemoveGroupMember removes the identity from the group
+// with the given name.
+func (c *Client) RemoveGroupMember(name string, id Identity) error {
+	return c.deleteGroup(fmt.Sprintf("%s/v1/group/remove/%s/%s", c.Endpoint, name, id.String()))
+}
+
+// deleteGroup sends a DELETE request to the group API
+// at the given URL.
+func (c *Client) deleteGroup(url string) error {
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// Quota is the key quota of an identity or tenant. It
+// contains the max. number of keys the identity or tenant
+// may create and the number of keys it has created.
+//
+// A Limit <= 0 means that there is no limit.
+type Quota struct {
+	Limit int `json:"limit"`
+	Keys  int `json:"keys"`
+}
+
+// ListQuotas returns the key quotas of all identities and
+// tenants that have a limit or have created at least one key.
+func (c *Client) ListQuotas() (map[Identity]Quota, map[string]Quota, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/quota/list", c.Endpoint))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Identities map[Identity]Quota `json:"identities"`
+		Tenants    map[string]Quota   `json:"tenants"`
+	}
+	const limit = 64 * 1024 * 1024 // There might be many identities
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, nil, err
+	}
+	return response.Identities, response.Tenants, nil
+}
+
+// SetIdentityQuota sets the max. number of keys the
+// identity may create. If limit is 0, the identity may
+// create any number of keys.
+//
+// Once an identity has reached its quota, the KES server
+// rejects requests to create keys with ErrQuotaExceeded.
+func (c *Client) SetIdentityQuota(id Identity, limit int) error {
+	return c.setQuota("identity", id.String(), limit)
+}
+
+// SetTenantQuota sets the max. number of keys the
+// identities of the tenant may create. If limit is 0,
+// the tenant may have any number of keys.
+//
+// Once a tenant has reached its quota, the KES server
+// rejects requests to create keys with ErrQuotaExceeded.
+func (c *Client) SetTenantQuota(tenant string, limit int) error {
+	return c.setQuota("tenant", tenant, limit)
+}
+
+func (c *Client) setQuota(kind, name string, limit int) error {
+	url := fmt.Sprintf("%s/v1/quota/set/%s/%s?limit=%d", c.Endpoint, kind, url.PathEscape(name), limit)
+	client := c.retryClient()
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// MaxBackupSize is the max. size of a backup archive
+// that can be restored.
+const MaxBackupSize = 64 << 20
+
+// Backup returns a signed backup archive of all keys,
+// policies and identity assignments. The keys within
+// the archive are encrypted with the server's KMS.
+//
+// The archive can be restored via Restore by any KES
+// server with access to the same KMS master key.
+func (c *Client) Backup() ([]byte, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/admin/backup", c.Endpoint))
 	if err != nil {
 		return nil, err
 	}
@@ -488,33 +2341,131 @@ func (c *Client) ListPolicies(pattern string) ([]string, error) {
 	}
 	defer resp.Body.Close()
 
-	const limit = 64 * 1024 * 1024 // There might be many policies
-	var policies []string
-	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&policies); err != nil {
+	archive, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBackupSize+1))
+	if err != nil {
 		return nil, err
 	}
-	return policies, nil
+	if len(archive) > MaxBackupSize {
+		return nil, errors.New("kes: backup archive exceeds max. size")
+	}
+	return archive, nil
 }
 
-// DeletePolicy removes the policy with the given name. It will not
-// return an error if no policy exists.
+// RestoreReport describes the result of restoring
+// a backup archive.
+type RestoreReport struct {
+	Keys    int `json:"keys"`    // Number of restored keys
+	Skipped int `json:"skipped"` // Number of keys that already existed
+}
+
+// Restore restores the keys, policies and identity
+// assignments of the backup archive. It does not
+// replace existing keys.
+func (c *Client) Restore(archive []byte) (RestoreReport, error) {
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/admin/restore", c.Endpoint), "application/json", bytes.NewReader(archive))
+	if err != nil {
+		return RestoreReport{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RestoreReport{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var report RestoreReport
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&report); err != nil {
+		return RestoreReport{}, err
+	}
+	return report, nil
+}
+
+// Escrow exports all keys that match at least one of
+// the glob patterns to the key escrow custodians of the
+// server. It returns the escrow package signed by the
+// server.
 //
-// If there are identities assigned to the deleted policies then these
-// identities will be removed as well.
+// Any threshold of custodians can recover the keys from
+// the package offline - e.g. via the 'kes tool escrow'
+// commands.
+func (c *Client) Escrow(patterns ...string) ([]byte, error) {
+	type Request struct {
+		Keys []string `json:"keys"`
+	}
+	body, err := json.Marshal(Request{
+		Keys: patterns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/admin/escrow", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	pkg, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBackupSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg) > MaxBackupSize {
+		return nil, errors.New("kes: escrow package exceeds max. size")
+	}
+	return pkg, nil
+}
+
+// HeaderEnclave is the HTTP header that specifies the
+// enclave a request is sent to. If not present, the
+// request is served by the KES server itself.
+const HeaderEnclave = "Kes-Enclave"
+
+// EnclaveInfo describes an enclave.
+type EnclaveInfo struct {
+	Name string   `json:"name"` // The enclave name
+	Root Identity `json:"root"` // The enclave root identity
+}
+
+// WithEnclave returns a copy of the client that sends all
+// requests to the enclave with the given name.
 //
-// Therefore, setting an empty policy and deleting a policy have
-// slightly different implications. The former will revoke any
-// access permission for all identities assigned to the policy.
-// The later will remove the policy as well as all identities
-// assigned to it.
-func (c *Client) DeletePolicy(name string) error {
-	url := fmt.Sprintf("%s/v1/policy/delete/%s", c.Endpoint, name)
-	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+// An enclave is an isolated "virtual server" with its own
+// root identity, policies, identity assignments and keys.
+// It serves the key, policy and identity APIs.
+func (c *Client) WithEnclave(name string) *Client {
+	transport := c.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.loadBalancer() // Share the load balancer with the copy
+
+	client := *c
+	client.enclave = name
+	client.HTTPClient.Transport = &enclaveTransport{
+		RoundTripper: transport,
+		Enclave:      name,
+	}
+	return &client
+}
+
+// CreateEnclave creates a new enclave with the given
+// name and root identity.
+func (c *Client) CreateEnclave(name string, root Identity) error {
+	type Request struct {
+		Root Identity `json:"root"`
+	}
+	body, err := json.Marshal(Request{Root: root})
 	if err != nil {
 		return err
 	}
-	client := retry(c.HTTPClient)
-	resp, err := client.Do(req)
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/enclave/create/%s", c.Endpoint, url.PathEscape(name))
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -524,10 +2475,17 @@ func (c *Client) DeletePolicy(name string) error {
 	return nil
 }
 
-func (c *Client) AssignIdentity(policy string, id Identity) error {
-	client := retry(c.HTTPClient)
-	url := fmt.Sprintf("%s/v1/identity/assign/%s/%s", c.Endpoint, policy, id.String())
-	resp, err := client.Post(url, "application/json", nil)
+// DeleteEnclave deletes the enclave with the given name
+// including all its keys, policies and identities.
+func (c *Client) DeleteEnclave(name string) error {
+	url := fmt.Sprintf("%s/v1/enclave/delete/%s", c.Endpoint, url.PathEscape(name))
+	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
+	if err != nil {
+		return err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -537,31 +2495,134 @@ func (c *Client) AssignIdentity(policy string, id Identity) error {
 	return nil
 }
 
-func (c *Client) ListIdentities(pattern string) (map[Identity]string, error) {
-	client := retry(c.HTTPClient)
-	resp, err := client.Get(fmt.Sprintf("%s/v1/identity/list/%s", c.Endpoint, url.PathEscape(pattern)))
+// ListEnclaves returns the name and root identity
+// of all enclaves.
+func (c *Client) ListEnclaves() ([]EnclaveInfo, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/enclave/list", c.Endpoint))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, parseErrorResponse(resp)
 	}
+	defer resp.Body.Close()
 
-	const limit = 64 * 1024 * 1024 // There might be many identities
-	response := map[Identity]string{}
-	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+	const limit = 64 * 1024 * 1024 // There might be many enclaves
+	var enclaves []EnclaveInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&enclaves); err != nil {
 		return nil, err
 	}
-	return response, nil
+	return enclaves, nil
 }
 
-func (c *Client) ForgetIdentity(id Identity) error {
-	url := fmt.Sprintf("%s/v1/identity/forget/%s", c.Endpoint, id.String())
+// JobInfo describes an asynchronous job that runs
+// at the server.
+type JobInfo struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Identity Identity        `json:"identity"` // The identity that submitted the job
+	State    string          `json:"state"`    // pending, running, completed, failed or canceled
+	Error    string          `json:"error,omitempty"`
+	Done     int             `json:"done"`  // Number of processed items
+	Total    int             `json:"total"` // Number of total items, 0 if not known yet
+	Created  time.Time       `json:"created"`
+	Updated  time.Time       `json:"updated"`
+}
+
+// Finished reports whether the job has finished
+// - i.e. has completed, failed or been canceled.
+func (j *JobInfo) Finished() bool {
+	return j.State == "completed" || j.State == "failed" || j.State == "canceled"
+}
+
+// SubmitJob submits a new job of the given type with the
+// given parameters. The server runs the job in the background
+// and resumes it after a restart. Callers can poll the job
+// state via JobStatus. For example:
+//   job, err := client.SubmitJob("delete-keys", map[string]string{"pattern": "my-app*"})
+//
+// The params are encoded as JSON. They may be nil.
+func (c *Client) SubmitJob(typ string, params interface{}) (JobInfo, error) {
+	var body []byte
+	if params != nil {
+		var err error
+		if body, err = json.Marshal(params); err != nil {
+			return JobInfo{}, err
+		}
+	}
+
+	client := c.retryClient()
+	url := fmt.Sprintf("%s/v1/job/submit/%s", c.Endpoint, url.PathEscape(typ))
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return JobInfo{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var job JobInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&job); err != nil {
+		return JobInfo{}, err
+	}
+	return job, nil
+}
+
+// JobStatus returns the job with the given ID.
+func (c *Client) JobStatus(id string) (JobInfo, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/job/status/%s", c.Endpoint, url.PathEscape(id)))
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return JobInfo{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var job JobInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&job); err != nil {
+		return JobInfo{}, err
+	}
+	return job, nil
+}
+
+// ListJobs returns all jobs sorted by their creation time.
+// The server keeps finished jobs for a limited time only.
+func (c *Client) ListJobs() ([]JobInfo, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/job/list", c.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 64 * 1024 * 1024 // There might be many jobs
+	var jobs []JobInfo
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CancelJob cancels the job with the given ID. Items
+// that the job has already processed are not restored.
+func (c *Client) CancelJob(id string) error {
+	url := fmt.Sprintf("%s/v1/job/cancel/%s", c.Endpoint, url.PathEscape(id))
 	req, err := http.NewRequest(http.MethodDelete, url, retryBody(nil))
 	if err != nil {
 		return err
 	}
-	client := retry(c.HTTPClient)
+
+	client := c.retryClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -572,6 +2633,259 @@ func (c *Client) ForgetIdentity(id Identity) error {
 	return nil
 }
 
+// SealStatus describes whether a server is sealed. A
+// sealed server cannot access any key until a threshold
+// of key shares has been submitted via Unseal - unless
+// the server unseals itself using its KMS.
+type SealStatus struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+	AutoUnseal  bool `json:"auto_unseal,omitempty"` // Whether the server unseals itself using its KMS
+	Threshold   int  `json:"threshold,omitempty"`   // Number of key shares required to unseal the server
+	Shares      int  `json:"shares,omitempty"`      // Number of key shares
+	Progress    int  `json:"progress"`              // Number of key shares submitted so far
+}
+
+// SealStatus returns the seal status of the server.
+func (c *Client) SealStatus() (SealStatus, error) {
+	client := c.retryClient()
+	resp, err := client.Get(fmt.Sprintf("%s/v1/seal/status", c.Endpoint))
+	if err != nil {
+		return SealStatus{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SealStatus{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var status SealStatus
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&status); err != nil {
+		return SealStatus{}, err
+	}
+	return status, nil
+}
+
+// InitSeal initializes the seal of the server. The server
+// generates a root key, splits it into the given number of
+// key shares and returns them. Any threshold of them can
+// unseal the server.
+//
+// The server does not store the key shares. Hence, they
+// must be distributed to their holders immediately.
+func (c *Client) InitSeal(shares, threshold int) ([][]byte, error) {
+	type Request struct {
+		Shares    int `json:"shares"`
+		Threshold int `json:"threshold"`
+	}
+	body, err := json.Marshal(Request{
+		Shares:    shares,
+		Threshold: threshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/seal/init", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Shares [][]byte `json:"shares"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Shares, nil
+}
+
+// Unseal submits a key share to the server and returns
+// the seal status once the key share has been submitted.
+// The server is unsealed once a threshold of key shares
+// has been submitted.
+func (c *Client) Unseal(share []byte) (SealStatus, error) {
+	type Request struct {
+		Share []byte `json:"share"`
+	}
+	body, err := json.Marshal(Request{
+		Share: share,
+	})
+	if err != nil {
+		return SealStatus{}, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/seal/unseal", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return SealStatus{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SealStatus{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var status SealStatus
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&status); err != nil {
+		return SealStatus{}, err
+	}
+	return status, nil
+}
+
+// enclaveTransport is an http.RoundTripper that
+// adds the enclave name to each request.
+type enclaveTransport struct {
+	http.RoundTripper
+
+	Enclave string
+}
+
+func (t *enclaveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(HeaderEnclave, t.Enclave)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// HeaderApproval is the HTTP header that carries the
+// approval tokens of a request. A request may contain
+// multiple approval headers or a comma-separated list
+// of tokens. See: Client.WithApprovals
+const HeaderApproval = "Kes-Approval"
+
+// IssueApproval approves a request of the root identity with
+// the given method and URL path - e.g. DELETE and
+// /v1/enclave/delete/my-enclave. It returns an approval token
+// and the point in time when the token expires.
+//
+// Servers may require that destructive requests of the root
+// identity are approved by multiple admins. The root identity
+// has to send such requests with the approval tokens of the
+// admins. See: Client.WithApprovals
+func (c *Client) IssueApproval(method, path string) (string, time.Time, error) {
+	type Request struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+	}
+	body, err := json.Marshal(Request{
+		Method: method,
+		Path:   path,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(fmt.Sprintf("%s/v1/approval/issue", c.Endpoint), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	type Response struct {
+		Token  string    `json:"token"`
+		Expiry time.Time `json:"expiry"`
+	}
+	const limit = 1 << 20
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&response); err != nil {
+		return "", time.Time{}, err
+	}
+	return response.Token, response.Expiry, nil
+}
+
+// WithApprovals returns a copy of the client that sends the
+// approval tokens with each request. See: IssueApproval
+//
+// Each token can be used only once. Hence, the returned client
+// should be used for the approved request only.
+func (c *Client) WithApprovals(tokens ...string) *Client {
+	transport := c.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.loadBalancer() // Share the load balancer with the copy
+
+	client := *c
+	client.HTTPClient.Transport = &approvalTransport{
+		RoundTripper: transport,
+		Tokens:       tokens,
+	}
+	return &client
+}
+
+// approvalTransport is an http.RoundTripper that
+// adds approval tokens to each request.
+type approvalTransport struct {
+	http.RoundTripper
+
+	Tokens []string
+}
+
+func (t *approvalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for _, token := range t.Tokens {
+		req.Header.Add(HeaderApproval, token)
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// Session is a short-lived credential derived from an
+// identity. See: Client.IssueSession
+type Session struct {
+	Token    string    `json:"token"`    // The bearer token of the session
+	Identity Identity  `json:"identity"` // The identity of the session - i.e. session:<identity>:<id>
+	Expiry   time.Time `json:"expiry"`
+}
+
+// IssueSession returns a new session of the client identity
+// with the given policy. The session expires after the ttl.
+// If ttl is 0, the server uses its default session lifetime.
+//
+// A client can authenticate with the session token instead
+// of the private key and certificate of the identity. A
+// request of the session is allowed if the session policy and
+// the policy of the identity allow it. Hence, an identity can
+// pass a session to ephemeral workloads - e.g. batch jobs -
+// without copying its long-lived credentials.
+func (c *Client) IssueSession(policy *Policy, ttl time.Duration) (*Session, error) {
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/v1/session/issue", c.Endpoint)
+	if ttl > 0 {
+		url += "?ttl=" + ttl.String()
+	}
+
+	client := c.retryClient()
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	const limit = 1 << 20
+	var session Session
+	if err = json.NewDecoder(io.LimitReader(resp.Body, limit)).Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
 // TraceAuditLog subscribes to the KES server audit
 // log and returns a stream of audit events on success.
 //
@@ -579,8 +2893,35 @@ func (c *Client) ForgetIdentity(id Identity) error {
 // have sufficient permissions to subscribe to the
 // audit log.
 func (c *Client) TraceAuditLog() (*AuditStream, error) {
-	client := retry(c.HTTPClient)
-	resp, err := client.Get(fmt.Sprintf("%s/v1/log/audit/trace", c.Endpoint))
+	return c.TraceAuditLogWithFilter(AuditFilter{})
+}
+
+// TraceAuditLogWithFilter subscribes to the KES server
+// audit log and returns a stream of audit events that
+// match the filter on success. The KES server filters
+// the audit events before sending them to the client.
+//
+// It returns ErrNotAllowed if the client does not
+// have sufficient permissions to subscribe to the
+// audit log.
+func (c *Client) TraceAuditLogWithFilter(filter AuditFilter) (*AuditStream, error) {
+	query := url.Values{}
+	if filter.Identity != "" {
+		query.Set("identity", filter.Identity.String())
+	}
+	if filter.API != "" {
+		query.Set("api", filter.API)
+	}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	endpoint := fmt.Sprintf("%s/v1/log/audit/trace", c.Endpoint)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	client := c.retryClient()
+	resp, err := client.Get(endpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -597,7 +2938,7 @@ func (c *Client) TraceAuditLog() (*AuditStream, error) {
 // have sufficient permissions to subscribe to the
 // error log.
 func (c *Client) TraceErrorLog() (*ErrorStream, error) {
-	client := retry(c.HTTPClient)
+	client := c.retryClient()
 	resp, err := client.Get(fmt.Sprintf("%s/v1/log/error/trace", c.Endpoint))
 	if err != nil {
 		return nil, err
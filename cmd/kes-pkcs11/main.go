@@ -0,0 +1,790 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Command kes-pkcs11 is a PKCS#11 module that forwards
+// key generation, encryption and decryption to a KES
+// server. It must be built as C shared library:
+//   go build -buildmode=c-shared -o kes-pkcs11.so ./cmd/kes-pkcs11
+//
+// The module connects to the KES server specified by the
+// same environment variables as the KES CLI:
+//   KES_SERVER      - the server endpoint (default: https://127.0.0.1:7373)
+//   KES_CLIENT_CERT - the client certificate file
+//   KES_CLIENT_KEY  - the client private key file
+//
+// The module provides one slot with one token. Each token
+// object is a KES key with the key name as CKA_LABEL:
+//   C_GenerateKey  (CKM_AES_KEY_GEN) -> creates a new key
+//   C_DestroyObject                  -> deletes the key
+//   C_Encrypt      (CKM_KES)         -> encrypts data with the key
+//   C_Decrypt      (CKM_KES)         -> decrypts data with the key
+//
+// CKM_KES is the vendor-defined mechanism CKM_VENDOR_DEFINED | 0x4B4553.
+// Its optional mechanism parameter is the encryption context that
+// must be the same for encryption and decryption. The KES server
+// never reveals the key itself. Hence, CKA_VALUE is sensitive.
+//
+// The module cannot list all keys of the KES server. Therefore,
+// C_FindObjects finds a key by its CKA_LABEL only.
+package main
+
+// #include "pkcs11.h"
+import "C"
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/minio/kes"
+)
+
+func main() {}
+
+// ckmKES is the vendor-defined mechanism for
+// encrypting and decrypting data with a KES key.
+const ckmKES = C.CKM_VENDOR_DEFINED | 0x4B4553
+
+// slotID is the ID of the only slot.
+const slotID = 1
+
+var (
+	lock        sync.Mutex
+	client      *kes.Client
+	sessions    map[C.CK_SESSION_HANDLE]*session
+	objects     map[C.CK_OBJECT_HANDLE]string // The key name of each object handle
+	handles     map[string]C.CK_OBJECT_HANDLE // The object handle of each key name
+	nextSession C.CK_SESSION_HANDLE
+	nextObject  C.CK_OBJECT_HANDLE
+)
+
+// session is a PKCS#11 session.
+type session struct {
+	flags C.CK_FLAGS
+
+	findActive  bool
+	findResults []C.CK_OBJECT_HANDLE
+
+	op *operation // The active encryption or decryption, if any
+}
+
+// operation is an encryption or decryption.
+type operation struct {
+	Encrypt bool
+	Key     string
+	Context []byte
+
+	// Result is the result of the operation once
+	// it has been performed. The application may
+	// ask for the result length first.
+	Result []byte
+	Done   bool
+}
+
+//export C_Initialize
+func C_Initialize(pInitArgs C.CK_VOID_PTR) C.CK_RV {
+	if pInitArgs != nil {
+		args := (*C.CK_C_INITIALIZE_ARGS)(pInitArgs)
+		if args.pReserved != nil {
+			return C.CKR_ARGUMENTS_BAD
+		}
+		if args.flags&C.CKF_LIBRARY_CANT_CREATE_OS_THREADS != 0 {
+			return C.CKR_NEED_TO_CREATE_THREADS // The Go runtime creates threads
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if client != nil {
+		return C.CKR_CRYPTOKI_ALREADY_INITIALIZED
+	}
+
+	endpoint := "https://127.0.0.1:7373"
+	if env, ok := os.LookupEnv("KES_SERVER"); ok {
+		endpoint = env
+	}
+	certPath, keyPath := os.Getenv("KES_CLIENT_CERT"), os.Getenv("KES_CLIENT_KEY")
+	if certPath == "" || keyPath == "" {
+		return C.CKR_GENERAL_ERROR
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return C.CKR_GENERAL_ERROR
+	}
+
+	client = kes.NewClient(endpoint, cert)
+	sessions = map[C.CK_SESSION_HANDLE]*session{}
+	objects = map[C.CK_OBJECT_HANDLE]string{}
+	handles = map[string]C.CK_OBJECT_HANDLE{}
+	return C.CKR_OK
+}
+
+//export C_Finalize
+func C_Finalize(pReserved C.CK_VOID_PTR) C.CK_RV {
+	if pReserved != nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if client == nil {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	client, sessions, objects, handles = nil, nil, nil, nil
+	return C.CKR_OK
+}
+
+//export C_GetInfo
+func C_GetInfo(pInfo C.CK_INFO_PTR) C.CK_RV {
+	if pInfo == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	*pInfo = C.CK_INFO{}
+	pInfo.cryptokiVersion = C.CK_VERSION{major: C.CRYPTOKI_VERSION_MAJOR, minor: C.CRYPTOKI_VERSION_MINOR}
+	pad(pInfo.manufacturerID[:], "MinIO, Inc.")
+	pad(pInfo.libraryDescription[:], "KES PKCS#11 module")
+	pInfo.libraryVersion = C.CK_VERSION{major: 1, minor: 0}
+	return C.CKR_OK
+}
+
+//export C_GetFunctionList
+func C_GetFunctionList(ppFunctionList C.CK_FUNCTION_LIST_PTR_PTR) C.CK_RV {
+	if ppFunctionList == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	*ppFunctionList = functionList()
+	return C.CKR_OK
+}
+
+//export C_GetSlotList
+func C_GetSlotList(tokenPresent C.CK_BBOOL, pSlotList C.CK_SLOT_ID_PTR, pulCount C.CK_ULONG_PTR) C.CK_RV {
+	if pulCount == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if pSlotList == nil {
+		*pulCount = 1
+		return C.CKR_OK
+	}
+	if *pulCount < 1 {
+		*pulCount = 1
+		return C.CKR_BUFFER_TOO_SMALL
+	}
+	*pSlotList = slotID
+	*pulCount = 1
+	return C.CKR_OK
+}
+
+//export C_GetSlotInfo
+func C_GetSlotInfo(id C.CK_SLOT_ID, pInfo C.CK_SLOT_INFO_PTR) C.CK_RV {
+	if pInfo == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	*pInfo = C.CK_SLOT_INFO{}
+	pad(pInfo.slotDescription[:], "KES server")
+	pad(pInfo.manufacturerID[:], "MinIO, Inc.")
+	pInfo.flags = C.CKF_TOKEN_PRESENT
+	return C.CKR_OK
+}
+
+//export C_GetTokenInfo
+func C_GetTokenInfo(id C.CK_SLOT_ID, pInfo C.CK_TOKEN_INFO_PTR) C.CK_RV {
+	if pInfo == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	*pInfo = C.CK_TOKEN_INFO{}
+	pad(pInfo.label[:], "KES")
+	pad(pInfo.manufacturerID[:], "MinIO, Inc.")
+	pad(pInfo.model[:], "KES")
+	pad(pInfo.serialNumber[:], "1")
+	pad(pInfo.utcTime[:], "")
+	pInfo.flags = C.CKF_TOKEN_INITIALIZED
+	pInfo.ulMaxSessionCount = C.CK_EFFECTIVELY_INFINITE
+	pInfo.ulSessionCount = C.CK_UNAVAILABLE_INFORMATION
+	pInfo.ulMaxRwSessionCount = C.CK_EFFECTIVELY_INFINITE
+	pInfo.ulRwSessionCount = C.CK_UNAVAILABLE_INFORMATION
+	pInfo.ulTotalPublicMemory = C.CK_UNAVAILABLE_INFORMATION
+	pInfo.ulFreePublicMemory = C.CK_UNAVAILABLE_INFORMATION
+	pInfo.ulTotalPrivateMemory = C.CK_UNAVAILABLE_INFORMATION
+	pInfo.ulFreePrivateMemory = C.CK_UNAVAILABLE_INFORMATION
+	return C.CKR_OK
+}
+
+//export C_GetMechanismList
+func C_GetMechanismList(id C.CK_SLOT_ID, pMechanismList C.CK_MECHANISM_TYPE_PTR, pulCount C.CK_ULONG_PTR) C.CK_RV {
+	if pulCount == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	mechanisms := []C.CK_MECHANISM_TYPE{C.CKM_AES_KEY_GEN, ckmKES}
+	if pMechanismList == nil {
+		*pulCount = C.CK_ULONG(len(mechanisms))
+		return C.CKR_OK
+	}
+	if *pulCount < C.CK_ULONG(len(mechanisms)) {
+		*pulCount = C.CK_ULONG(len(mechanisms))
+		return C.CKR_BUFFER_TOO_SMALL
+	}
+	copy((*[1 << 20]C.CK_MECHANISM_TYPE)(unsafe.Pointer(pMechanismList))[:len(mechanisms):len(mechanisms)], mechanisms)
+	*pulCount = C.CK_ULONG(len(mechanisms))
+	return C.CKR_OK
+}
+
+//export C_GetMechanismInfo
+func C_GetMechanismInfo(id C.CK_SLOT_ID, mechanism C.CK_MECHANISM_TYPE, pInfo C.CK_MECHANISM_INFO_PTR) C.CK_RV {
+	if pInfo == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if !initialized() {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	switch mechanism {
+	case C.CKM_AES_KEY_GEN:
+		*pInfo = C.CK_MECHANISM_INFO{ulMinKeySize: 32, ulMaxKeySize: 32, flags: C.CKF_GENERATE}
+	case ckmKES:
+		*pInfo = C.CK_MECHANISM_INFO{ulMinKeySize: 32, ulMaxKeySize: 32, flags: C.CKF_ENCRYPT | C.CKF_DECRYPT}
+	default:
+		return C.CKR_MECHANISM_INVALID
+	}
+	return C.CKR_OK
+}
+
+//export C_OpenSession
+func C_OpenSession(id C.CK_SLOT_ID, flags C.CK_FLAGS, pApplication C.CK_VOID_PTR, notify C.CK_NOTIFY, phSession C.CK_SESSION_HANDLE_PTR) C.CK_RV {
+	if phSession == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if flags&C.CKF_SERIAL_SESSION == 0 {
+		return C.CKR_SESSION_PARALLEL_NOT_SUPPORTED
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if client == nil {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	nextSession++
+	sessions[nextSession] = &session{flags: flags}
+	*phSession = nextSession
+	return C.CKR_OK
+}
+
+//export C_CloseSession
+func C_CloseSession(hSession C.CK_SESSION_HANDLE) C.CK_RV {
+	lock.Lock()
+	defer lock.Unlock()
+	if client == nil {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if _, ok := sessions[hSession]; !ok {
+		return C.CKR_SESSION_HANDLE_INVALID
+	}
+	delete(sessions, hSession)
+	return C.CKR_OK
+}
+
+//export C_CloseAllSessions
+func C_CloseAllSessions(id C.CK_SLOT_ID) C.CK_RV {
+	lock.Lock()
+	defer lock.Unlock()
+	if client == nil {
+		return C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	if id != slotID {
+		return C.CKR_SLOT_ID_INVALID
+	}
+	sessions = map[C.CK_SESSION_HANDLE]*session{}
+	return C.CKR_OK
+}
+
+//export C_GetSessionInfo
+func C_GetSessionInfo(hSession C.CK_SESSION_HANDLE, pInfo C.CK_SESSION_INFO_PTR) C.CK_RV {
+	if pInfo == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+	*pInfo = C.CK_SESSION_INFO{slotID: slotID, flags: s.flags}
+	if s.flags&C.CKF_RW_SESSION != 0 {
+		pInfo.state = C.CKS_RW_USER_FUNCTIONS
+	} else {
+		pInfo.state = C.CKS_RO_USER_FUNCTIONS
+	}
+	return C.CKR_OK
+}
+
+// The KES server authenticates the module via its
+// client certificate. Therefore, there is no need
+// to login and C_Login and C_Logout do nothing.
+
+//export C_Login
+func C_Login(hSession C.CK_SESSION_HANDLE, userType C.CK_USER_TYPE, pPin C.CK_UTF8CHAR_PTR, ulPinLen C.CK_ULONG) C.CK_RV {
+	_, rv := lookupSession(hSession)
+	return rv
+}
+
+//export C_Logout
+func C_Logout(hSession C.CK_SESSION_HANDLE) C.CK_RV {
+	_, rv := lookupSession(hSession)
+	return rv
+}
+
+//export C_GenerateKey
+func C_GenerateKey(hSession C.CK_SESSION_HANDLE, pMechanism C.CK_MECHANISM_PTR, pTemplate C.CK_ATTRIBUTE_PTR, ulCount C.CK_ULONG, phKey C.CK_OBJECT_HANDLE_PTR) C.CK_RV {
+	if pMechanism == nil || phKey == nil || (pTemplate == nil && ulCount > 0) {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if _, rv := lookupSession(hSession); rv != C.CKR_OK {
+		return rv
+	}
+	if pMechanism.mechanism != C.CKM_AES_KEY_GEN {
+		return C.CKR_MECHANISM_INVALID
+	}
+
+	name, rv := parseTemplate(attributes(pTemplate, ulCount))
+	if rv != C.CKR_OK {
+		return rv
+	}
+	if name == "" {
+		return C.CKR_TEMPLATE_INCOMPLETE
+	}
+	if err := kesClient().CreateKey(name); err != nil {
+		if err == kes.ErrKeyExists {
+			return C.CKR_TEMPLATE_INCONSISTENT
+		}
+		return errorRV(err)
+	}
+	*phKey = objectHandle(name)
+	return C.CKR_OK
+}
+
+//export C_DestroyObject
+func C_DestroyObject(hSession C.CK_SESSION_HANDLE, hObject C.CK_OBJECT_HANDLE) C.CK_RV {
+	if _, rv := lookupSession(hSession); rv != C.CKR_OK {
+		return rv
+	}
+	name, ok := objectName(hObject)
+	if !ok {
+		return C.CKR_OBJECT_HANDLE_INVALID
+	}
+	if err := kesClient().DeleteKey(name); err != nil {
+		return errorRV(err)
+	}
+
+	lock.Lock()
+	delete(objects, hObject)
+	delete(handles, name)
+	lock.Unlock()
+	return C.CKR_OK
+}
+
+//export C_GetAttributeValue
+func C_GetAttributeValue(hSession C.CK_SESSION_HANDLE, hObject C.CK_OBJECT_HANDLE, pTemplate C.CK_ATTRIBUTE_PTR, ulCount C.CK_ULONG) C.CK_RV {
+	if pTemplate == nil && ulCount > 0 {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	if _, rv := lookupSession(hSession); rv != C.CKR_OK {
+		return rv
+	}
+	name, ok := objectName(hObject)
+	if !ok {
+		return C.CKR_OBJECT_HANDLE_INVALID
+	}
+
+	rv := C.CK_RV(C.CKR_OK)
+	for i, attr := range attributes(pTemplate, ulCount) {
+		var value []byte
+		switch attr._type {
+		case C.CKA_CLASS:
+			value = ulongBytes(C.CKO_SECRET_KEY)
+		case C.CKA_KEY_TYPE:
+			value = ulongBytes(C.CKK_AES)
+		case C.CKA_VALUE_LEN:
+			value = ulongBytes(32)
+		case C.CKA_LABEL, C.CKA_ID:
+			value = []byte(name)
+		case C.CKA_TOKEN, C.CKA_SENSITIVE, C.CKA_ENCRYPT, C.CKA_DECRYPT, C.CKA_ALWAYS_SENSITIVE, C.CKA_NEVER_EXTRACTABLE:
+			value = []byte{C.CK_TRUE}
+		case C.CKA_PRIVATE, C.CKA_EXTRACTABLE, C.CKA_MODIFIABLE, C.CKA_SIGN, C.CKA_VERIFY, C.CKA_WRAP, C.CKA_UNWRAP, C.CKA_DERIVE:
+			value = []byte{C.CK_FALSE}
+		case C.CKA_VALUE:
+			pTemplate := (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(pTemplate))
+			pTemplate[i].ulValueLen = C.CK_UNAVAILABLE_INFORMATION
+			rv = C.CKR_ATTRIBUTE_SENSITIVE
+			continue
+		default:
+			pTemplate := (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(pTemplate))
+			pTemplate[i].ulValueLen = C.CK_UNAVAILABLE_INFORMATION
+			rv = C.CKR_ATTRIBUTE_TYPE_INVALID
+			continue
+		}
+
+		pTemplate := (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(pTemplate))
+		switch {
+		case attr.pValue == nil:
+			pTemplate[i].ulValueLen = C.CK_ULONG(len(value))
+		case attr.ulValueLen < C.CK_ULONG(len(value)):
+			pTemplate[i].ulValueLen = C.CK_UNAVAILABLE_INFORMATION
+			rv = C.CKR_BUFFER_TOO_SMALL
+		default:
+			copy((*[1 << 30]byte)(attr.pValue)[:len(value):len(value)], value)
+			pTemplate[i].ulValueLen = C.CK_ULONG(len(value))
+		}
+	}
+	return rv
+}
+
+//export C_FindObjectsInit
+func C_FindObjectsInit(hSession C.CK_SESSION_HANDLE, pTemplate C.CK_ATTRIBUTE_PTR, ulCount C.CK_ULONG) C.CK_RV {
+	if pTemplate == nil && ulCount > 0 {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+	if s.findActive {
+		return C.CKR_OPERATION_ACTIVE
+	}
+
+	var results []C.CK_OBJECT_HANDLE
+	name, rv := parseTemplate(attributes(pTemplate, ulCount))
+	switch {
+	case rv == C.CKR_TEMPLATE_INCONSISTENT:
+		// The template does not match any secret AES key.
+	case rv != C.CKR_OK:
+		return rv
+	case name != "":
+		// The KES server does not reveal whether a key exists
+		// before a client tries to use it. Therefore, we try to
+		// encrypt an empty message. An identity that must not
+		// encrypt may still use the key for decryption.
+		_, err := kesClient().Encrypt(name, nil, nil)
+		if _, ok := err.(kes.Error); err != nil && !ok {
+			return errorRV(err)
+		}
+		if err != kes.ErrKeyNotFound {
+			results = append(results, objectHandle(name))
+		}
+	}
+
+	lock.Lock()
+	s.findActive = true
+	s.findResults = results
+	lock.Unlock()
+	return C.CKR_OK
+}
+
+//export C_FindObjects
+func C_FindObjects(hSession C.CK_SESSION_HANDLE, phObject C.CK_OBJECT_HANDLE_PTR, ulMaxObjectCount C.CK_ULONG, pulObjectCount C.CK_ULONG_PTR) C.CK_RV {
+	if pulObjectCount == nil || (phObject == nil && ulMaxObjectCount > 0) {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if !s.findActive {
+		return C.CKR_OPERATION_NOT_INITIALIZED
+	}
+	n := len(s.findResults)
+	if C.CK_ULONG(n) > ulMaxObjectCount {
+		n = int(ulMaxObjectCount)
+	}
+	if n > 0 {
+		copy((*[1 << 20]C.CK_OBJECT_HANDLE)(unsafe.Pointer(phObject))[:n:n], s.findResults[:n])
+	}
+	s.findResults = s.findResults[n:]
+	*pulObjectCount = C.CK_ULONG(n)
+	return C.CKR_OK
+}
+
+//export C_FindObjectsFinal
+func C_FindObjectsFinal(hSession C.CK_SESSION_HANDLE) C.CK_RV {
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if !s.findActive {
+		return C.CKR_OPERATION_NOT_INITIALIZED
+	}
+	s.findActive, s.findResults = false, nil
+	return C.CKR_OK
+}
+
+//export C_EncryptInit
+func C_EncryptInit(hSession C.CK_SESSION_HANDLE, pMechanism C.CK_MECHANISM_PTR, hKey C.CK_OBJECT_HANDLE) C.CK_RV {
+	return initOperation(hSession, pMechanism, hKey, true)
+}
+
+//export C_Encrypt
+func C_Encrypt(hSession C.CK_SESSION_HANDLE, pData C.CK_BYTE_PTR, ulDataLen C.CK_ULONG, pEncryptedData C.CK_BYTE_PTR, pulEncryptedDataLen C.CK_ULONG_PTR) C.CK_RV {
+	return runOperation(hSession, pData, ulDataLen, pEncryptedData, pulEncryptedDataLen, true)
+}
+
+//export C_DecryptInit
+func C_DecryptInit(hSession C.CK_SESSION_HANDLE, pMechanism C.CK_MECHANISM_PTR, hKey C.CK_OBJECT_HANDLE) C.CK_RV {
+	return initOperation(hSession, pMechanism, hKey, false)
+}
+
+//export C_Decrypt
+func C_Decrypt(hSession C.CK_SESSION_HANDLE, pEncryptedData C.CK_BYTE_PTR, ulEncryptedDataLen C.CK_ULONG, pData C.CK_BYTE_PTR, pulDataLen C.CK_ULONG_PTR) C.CK_RV {
+	return runOperation(hSession, pEncryptedData, ulEncryptedDataLen, pData, pulDataLen, false)
+}
+
+// initOperation starts an encryption or decryption
+// with the given key.
+func initOperation(hSession C.CK_SESSION_HANDLE, pMechanism C.CK_MECHANISM_PTR, hKey C.CK_OBJECT_HANDLE, encrypt bool) C.CK_RV {
+	if pMechanism == nil {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+	if pMechanism.mechanism != ckmKES {
+		return C.CKR_MECHANISM_INVALID
+	}
+	if pMechanism.pParameter == nil && pMechanism.ulParameterLen > 0 {
+		return C.CKR_MECHANISM_PARAM_INVALID
+	}
+	name, ok := objectName(hKey)
+	if !ok {
+		return C.CKR_KEY_HANDLE_INVALID
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if s.op != nil {
+		return C.CKR_OPERATION_ACTIVE
+	}
+	s.op = &operation{
+		Encrypt: encrypt,
+		Key:     name,
+		Context: C.GoBytes(pMechanism.pParameter, C.int(pMechanism.ulParameterLen)),
+	}
+	return C.CKR_OK
+}
+
+// runOperation performs the active encryption or decryption
+// of the session. If pOut is NULL, it only returns the length
+// of the result and the operation remains active. Otherwise,
+// it copies the result to pOut and ends the operation.
+func runOperation(hSession C.CK_SESSION_HANDLE, pIn C.CK_BYTE_PTR, ulInLen C.CK_ULONG, pOut C.CK_BYTE_PTR, pulOutLen C.CK_ULONG_PTR, encrypt bool) C.CK_RV {
+	if pulOutLen == nil || (pIn == nil && ulInLen > 0) {
+		return C.CKR_ARGUMENTS_BAD
+	}
+	s, rv := lookupSession(hSession)
+	if rv != C.CKR_OK {
+		return rv
+	}
+
+	lock.Lock()
+	op := s.op
+	lock.Unlock()
+	if op == nil || op.Encrypt != encrypt {
+		return C.CKR_OPERATION_NOT_INITIALIZED
+	}
+
+	if !op.Done {
+		var (
+			in     = C.GoBytes(unsafe.Pointer(pIn), C.int(ulInLen))
+			result []byte
+			err    error
+		)
+		if encrypt {
+			result, err = kesClient().Encrypt(op.Key, in, op.Context)
+		} else {
+			result, err = kesClient().Decrypt(op.Key, in, op.Context)
+		}
+		if err != nil {
+			endOperation(s)
+			if kesErr, ok := err.(kes.Error); ok && !encrypt && kesErr.Status() == 400 {
+				return C.CKR_ENCRYPTED_DATA_INVALID
+			}
+			return errorRV(err)
+		}
+		op.Result, op.Done = result, true
+	}
+
+	if pOut == nil {
+		*pulOutLen = C.CK_ULONG(len(op.Result))
+		return C.CKR_OK
+	}
+	if *pulOutLen < C.CK_ULONG(len(op.Result)) {
+		*pulOutLen = C.CK_ULONG(len(op.Result))
+		return C.CKR_BUFFER_TOO_SMALL
+	}
+	if n := len(op.Result); n > 0 {
+		copy((*[1 << 30]byte)(unsafe.Pointer(pOut))[:n:n], op.Result)
+	}
+	*pulOutLen = C.CK_ULONG(len(op.Result))
+	endOperation(s)
+	return C.CKR_OK
+}
+
+func endOperation(s *session) {
+	lock.Lock()
+	s.op = nil
+	lock.Unlock()
+}
+
+// parseTemplate returns the CKA_LABEL of the template.
+// It returns CKR_TEMPLATE_INCONSISTENT if the template
+// does not describe a secret AES key.
+func parseTemplate(template []C.CK_ATTRIBUTE) (string, C.CK_RV) {
+	var name string
+	for _, attr := range template {
+		if attr.pValue == nil && attr.ulValueLen > 0 {
+			return "", C.CKR_ATTRIBUTE_VALUE_INVALID
+		}
+		value := C.GoBytes(attr.pValue, C.int(attr.ulValueLen))
+		switch attr._type {
+		case C.CKA_LABEL:
+			name = string(value)
+		case C.CKA_CLASS:
+			if !isULong(value, C.CKO_SECRET_KEY) {
+				return "", C.CKR_TEMPLATE_INCONSISTENT
+			}
+		case C.CKA_KEY_TYPE:
+			if !isULong(value, C.CKK_AES) {
+				return "", C.CKR_TEMPLATE_INCONSISTENT
+			}
+		case C.CKA_VALUE_LEN:
+			if !isULong(value, 32) {
+				return "", C.CKR_TEMPLATE_INCONSISTENT
+			}
+		}
+	}
+	return name, C.CKR_OK
+}
+
+// errorRV returns the PKCS#11 return value
+// for the error returned by the KES client.
+func errorRV(err error) C.CK_RV {
+	switch err {
+	case kes.ErrKeyNotFound:
+		return C.CKR_KEY_HANDLE_INVALID
+	case kes.ErrNotAllowed, kes.ErrIdentityExpired, kes.ErrQuotaExceeded:
+		return C.CKR_FUNCTION_FAILED
+	}
+	if _, ok := err.(kes.Error); ok {
+		return C.CKR_FUNCTION_FAILED
+	}
+	return C.CKR_DEVICE_ERROR // The KES server is not reachable
+}
+
+func initialized() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return client != nil
+}
+
+func kesClient() *kes.Client {
+	lock.Lock()
+	defer lock.Unlock()
+	return client
+}
+
+func lookupSession(hSession C.CK_SESSION_HANDLE) (*session, C.CK_RV) {
+	lock.Lock()
+	defer lock.Unlock()
+	if client == nil {
+		return nil, C.CKR_CRYPTOKI_NOT_INITIALIZED
+	}
+	s, ok := sessions[hSession]
+	if !ok {
+		return nil, C.CKR_SESSION_HANDLE_INVALID
+	}
+	return s, C.CKR_OK
+}
+
+// objectHandle returns the object handle of the
+// named key. It assigns a new handle if the key
+// does not have one yet.
+func objectHandle(name string) C.CK_OBJECT_HANDLE {
+	lock.Lock()
+	defer lock.Unlock()
+	if handle, ok := handles[name]; ok {
+		return handle
+	}
+	nextObject++
+	handles[name] = nextObject
+	objects[nextObject] = name
+	return nextObject
+}
+
+func objectName(hObject C.CK_OBJECT_HANDLE) (string, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	name, ok := objects[hObject]
+	return name, ok
+}
+
+func attributes(pTemplate C.CK_ATTRIBUTE_PTR, ulCount C.CK_ULONG) []C.CK_ATTRIBUTE {
+	if pTemplate == nil || ulCount == 0 {
+		return nil
+	}
+	n := int(ulCount)
+	return (*[1 << 20]C.CK_ATTRIBUTE)(unsafe.Pointer(pTemplate))[:n:n]
+}
+
+func ulongBytes(v C.CK_ULONG) []byte {
+	b := make([]byte, unsafe.Sizeof(v))
+	*(*C.CK_ULONG)(unsafe.Pointer(&b[0])) = v
+	return b
+}
+
+func isULong(b []byte, v C.CK_ULONG) bool {
+	return len(b) == int(unsafe.Sizeof(v)) && *(*C.CK_ULONG)(unsafe.Pointer(&b[0])) == v
+}
+
+// pad copies s into the fixed-size PKCS#11 string
+// and pads it with blanks.
+func pad(dst []C.CK_UTF8CHAR, s string) {
+	for i := range dst {
+		if i < len(s) {
+			dst[i] = C.CK_UTF8CHAR(s[i])
+		} else {
+			dst[i] = ' '
+		}
+	}
+}
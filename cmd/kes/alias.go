@@ -0,0 +1,170 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const aliasCmdUsage = `usage: %s <command>
+
+  set                  Create or update an alias that refers to a key.
+  delete               Delete an alias.
+  list                 List all aliases and the keys they refer to.
+
+  -h, --help           Show list of command-line options
+`
+
+func alias(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), aliasCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "set":
+		return setAlias(args)
+	case "delete":
+		return deleteAlias(args)
+	case "list":
+		return listAliases(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const setAliasCmdUsage = `usage: %s [options] <alias> <key>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Applications can use the alias in place of the key name. Setting an
+existing alias makes it refer to another key - e.g. when rotating the
+key behind a stable alias:
+  $ kes key create bucket-key-2
+  $ kes key alias set current-bucket-key bucket-key-2
+
+Ciphertexts produced via the alias before can only be decrypted with
+the key that has produced them - e.g. bucket-key-1.
+`
+
+func setAlias(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), setAliasCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err = client.SetKeyAlias(args[0], args[1]); err != nil {
+		return fmt.Errorf("Cannot set alias '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const deleteAliasCmdUsage = `usage: %s [options] <alias>...
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Deleting an alias does not delete the key it refers to.
+`
+
+func deleteAlias(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), deleteAliasCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if err = client.DeleteKeyAlias(name); err != nil {
+			return fmt.Errorf("Cannot delete alias '%s': %v", name, err)
+		}
+	}
+	return nil
+}
+
+const listAliasesCmdUsage = `usage: %s [options] [<pattern>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+The pattern matches the alias names like a key pattern - e.g.
+'my-app/*'. Without a pattern, all aliases are listed.
+`
+
+func listAliases(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listAliasesCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	pattern := "**"
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	aliases, err := client.ListKeyAliases(pattern)
+	if err != nil {
+		return fmt.Errorf("Cannot list aliases: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(aliases)
+	}
+	fmt.Println("{")
+	for _, a := range aliases {
+		fmt.Printf("  %s => %s\n", a.Alias, a.Key)
+	}
+	fmt.Println("}")
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const approveCmdUsage = `Approves a destructive request of the root identity.
+
+The server may require that destructive requests of the root
+identity - e.g. deleting an enclave - are approved by multiple
+admins. Each admin issues an approval token for the request.
+The root identity sends the request with the tokens of the
+admins by setting the env. variable KES_APPROVAL to the
+comma-separated list of tokens.
+
+A token can be used only once and expires after the approval
+window configured at the server.
+
+usage: %s <method> <path>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Example:
+  $ kes approve DELETE /v1/enclave/delete/my-enclave
+  $ export KES_APPROVAL=<token-1>,<token-2>
+  $ kes enclave delete my-enclave
+`
+
+func approve(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), approveCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(args[0])
+	token, expiry, err := client.IssueApproval(method, args[1])
+	if err != nil {
+		return fmt.Errorf("Failed to approve '%s %s': %v", method, args[1], err)
+	}
+	if isTerm(os.Stdout) {
+		fmt.Println(token)
+		fmt.Printf("Expires: %s\n", expiry.Local().Format(time.RFC1123))
+	} else {
+		type Response struct {
+			Token  string    `json:"token"`
+			Expiry time.Time `json:"expiry"`
+		}
+		json.NewEncoder(os.Stdout).Encode(Response{Token: token, Expiry: expiry})
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const attestCmdUsage = `usage: %s [options]
+
+  --report <file>      Write the raw hardware report to the file.
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Requests a hardware attestation report from a server that runs inside
+a confidential VM or enclave. It verifies that the report binds the
+server's TLS certificate to a random nonce.
+
+The report itself has to be verified with the tooling of the hardware
+vendor - e.g. the AMD SEV-SNP or Intel DCAP verification libraries:
+  $ kes attest --report=./report.bin
+`
+
+func attestCmd(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), attestCmdUsage, cli.Name())
+	}
+
+	var (
+		insecureSkipVerify bool
+		reportPath         string
+	)
+	cli.StringVar(&reportPath, "report", "", "Write the raw hardware report to the file")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	attestation, err := client.Attest()
+	if err != nil {
+		return fmt.Errorf("Cannot attest server: %v", err)
+	}
+	if reportPath != "" {
+		if err = ioutil.WriteFile(reportPath, attestation.Report, 0644); err != nil {
+			return fmt.Errorf("Cannot write report: %v", err)
+		}
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(attestation)
+	}
+	reportData, _ := attestation.ReportData()
+	publicKey := sha256.Sum256(attestation.PublicKey)
+	fmt.Printf("Type:        %s\n", attestation.Type)
+	fmt.Printf("Public Key:  %s\n", hex.EncodeToString(publicKey[:]))
+	fmt.Printf("Report Data: %s\n", hex.EncodeToString(reportData))
+	fmt.Printf("Report:      %d bytes\n", len(attestation.Report))
+	return nil
+}
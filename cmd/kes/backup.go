@@ -0,0 +1,216 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	xbackup "github.com/minio/kes/internal/backup"
+)
+
+const backupCmdUsage = `usage: %s <command>
+
+  create               Create a backup of the server state.
+  restore              Restore a backup.
+  verify               Verify that a backup can be restored.
+
+  -h, --help           Show list of command-line options
+`
+
+func backup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), backupCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		return createBackup(args)
+	case "restore":
+		return restoreBackup(args)
+	case "verify":
+		return verifyBackup(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const createBackupCmdUsage = `usage: %s [options] <file>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Writes a signed backup archive of all keys, policies and identity
+assignments to the file. The keys are encrypted with the KMS of the
+server. Therefore, the server must use a KMS and the archive can only
+be restored by a server with access to the same KMS master key.
+  $ kes backup create kes-backup.json
+`
+
+func createBackup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), createBackupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	archive, err := client.Backup()
+	if err != nil {
+		return fmt.Errorf("Cannot create backup: %v", err)
+	}
+	if err = ioutil.WriteFile(args[0], archive, 0600); err != nil {
+		return fmt.Errorf("Cannot write backup: %v", err)
+	}
+	return nil
+}
+
+const restoreBackupCmdUsage = `usage: %s [options] <file>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Restores the keys, policies and identity assignments of a backup
+archive. Existing keys are not replaced. Restored policies replace
+existing policies with the same name.
+  $ kes backup restore kes-backup.json
+`
+
+func restoreBackup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), restoreBackupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	archive, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot read backup: %v", err)
+	}
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	report, err := client.Restore(archive)
+	if err != nil {
+		return fmt.Errorf("Cannot restore backup: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(report)
+	}
+	fmt.Printf("Restored %d keys (%d keys already existed)\n", report.Keys, report.Skipped)
+	return nil
+}
+
+const verifyBackupCmdUsage = `usage: %s [options] <file>
+
+  --config             Path to the server config file that specifies the KMS
+
+  -h, --help           Show list of command-line options
+
+Verifies the signature of a backup archive and checks that the KMS
+can decrypt every key of the archive. It uses the KMS credentials of
+the server config file but does not connect to a KES server or key
+store and does not write anything. Keys that cannot be decrypted are
+reported as unreadable.
+  $ kes backup verify --config=server-config.yaml kes-backup.json
+`
+
+func verifyBackup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), verifyBackupCmdUsage, cli.Name())
+	}
+
+	var configPath string
+	cli.StringVar(&configPath, "config", "", "Path to the server config file that specifies the KMS")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 || configPath == "" {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	config, err := loadServerConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("Cannot read config file: %v", err)
+	}
+	config.SetDefaults()
+	if config.Seal.Enabled {
+		return errors.New("Cannot verify backup: the keys of a sealed server are encrypted with its root key")
+	}
+	if config.KMS.Aws.Endpoint == "" {
+		return errors.New("Cannot verify backup: the config file does not specify a KMS")
+	}
+	kms := newAWSKMS(&config)
+	if err = kms.Authenticate(); err != nil {
+		return fmt.Errorf("Failed to connect to AWS-KMS: %v", err)
+	}
+
+	file, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot read backup: %v", err)
+	}
+	var archive xbackup.Archive
+	if err = json.Unmarshal(file, &archive); err != nil {
+		return fmt.Errorf("Cannot read backup: %v", err)
+	}
+	report, err := xbackup.Verify(&archive, kms)
+	if err != nil {
+		return fmt.Errorf("Cannot verify backup: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		if err = json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			return err
+		}
+	} else {
+		names := make([]string, 0, len(report.Unreadable))
+		for name := range report.Unreadable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("Unreadable: %s: %s\n", name, report.Unreadable[name])
+		}
+		fmt.Printf("Verified %d keys (%d keys are unreadable) of backup created at %s\n", report.Keys, len(report.Unreadable), report.CreatedAt)
+	}
+	if len(report.Unreadable) > 0 {
+		return fmt.Errorf("Backup contains %d unreadable keys", len(report.Unreadable))
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+const benchCmdUsage = `usage: %s [options] <operation>
+
+  -c, --concurrency <n>  Number of concurrent requests (default: 16)
+  -d, --duration <d>     Duration of the benchmark (default: 10s)
+  --key <name>           Key used by the generate and decrypt operations.
+                         If not set, a temporary key is created and
+                         deleted once the benchmark has finished.
+  --json                 Print the report as JSON
+
+  -k, --insecure         Skip X.509 certificate validation during TLS handshake
+
+  -h, --help             Show list of command-line options
+
+Sends requests to a kes server for the given duration and reports
+the throughput, the error rate and the latency percentiles. The
+client does not retry failed requests.
+
+Operations:
+  generate             Generate data encryption keys.
+  decrypt              Decrypt a data encryption key.
+  create               Create new keys. The keys are deleted once
+                       the benchmark has finished.
+
+For example:
+  $ kes bench --concurrency=32 --duration=1m generate
+`
+
+// benchReport is the result of a benchmark.
+type benchReport struct {
+	Operation   string        `json:"operation"`
+	Concurrency int           `json:"concurrency"`
+	Duration    time.Duration `json:"duration"`
+	Requests    int           `json:"requests"`
+	Errors      int           `json:"errors"`
+	Throughput  float64       `json:"throughput"` // Successful requests per second
+	ErrorRate   float64       `json:"error_rate"` // Errors per request
+
+	// Latency percentiles of all requests.
+	LatencyAvg time.Duration `json:"latency_avg"`
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP90 time.Duration `json:"latency_p90"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+	LatencyMax time.Duration `json:"latency_max"`
+
+	// ErrorMessages maps each error message
+	// to the number of times it occurred.
+	ErrorMessages map[string]int `json:"error_messages,omitempty"`
+}
+
+func bench(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), benchCmdUsage, cli.Name())
+	}
+
+	var (
+		concurrency        int
+		duration           time.Duration
+		keyName            string
+		jsonOutput         bool
+		insecureSkipVerify bool
+	)
+	cli.IntVar(&concurrency, "c", 16, "Number of concurrent requests")
+	cli.IntVar(&concurrency, "concurrency", 16, "Number of concurrent requests")
+	cli.DurationVar(&duration, "d", 10*time.Second, "Duration of the benchmark")
+	cli.DurationVar(&duration, "duration", 10*time.Second, "Duration of the benchmark")
+	cli.StringVar(&keyName, "key", "", "Key used by the generate and decrypt operations")
+	cli.BoolVar(&jsonOutput, "json", false, "Print the report as JSON")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	if concurrency <= 0 {
+		return fmt.Errorf("Invalid concurrency '%d': must be greater than 0", concurrency)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("Invalid duration '%v': must be greater than 0", duration)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	client.Retry.MaxRetries = -1 // Retries would distort the latencies
+
+	random, err := sioutil.Random(8)
+	if err != nil {
+		return err
+	}
+	prefix := "kes-bench-" + hex.EncodeToString(random)
+
+	var (
+		operation = args[0]
+		op        func(worker, n int) error
+		created   = make([][]string, concurrency) // The keys created by each worker
+	)
+	switch operation {
+	case "generate", "decrypt":
+		if keyName == "" {
+			keyName = prefix
+			if err = client.CreateKey(keyName); err != nil {
+				return fmt.Errorf("Cannot create key '%s': %v", keyName, err)
+			}
+			defer client.DeleteKey(keyName)
+		}
+		if operation == "generate" {
+			op = func(int, int) error {
+				_, err := client.GenerateKey(keyName, nil)
+				return err
+			}
+			break
+		}
+
+		dek, err := client.GenerateKey(keyName, nil)
+		if err != nil {
+			return fmt.Errorf("Cannot generate data key with '%s': %v", keyName, err)
+		}
+		op = func(int, int) error {
+			_, err := client.Decrypt(keyName, dek.Ciphertext, nil)
+			return err
+		}
+	case "create":
+		if keyName != "" {
+			return errors.New("The create operation does not use the '--key' option")
+		}
+		op = func(worker, n int) error {
+			name := fmt.Sprintf("%s-%d-%d", prefix, worker, n)
+			if err := client.CreateKey(name); err != nil {
+				return err
+			}
+			created[worker] = append(created[worker], name)
+			return nil
+		}
+	default:
+		return fmt.Errorf("Unknown operation '%s'", operation)
+	}
+
+	// CTRL+C stops the benchmark early. We still
+	// report the requests sent so far.
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		wg        sync.WaitGroup
+		latencies = make([][]time.Duration, concurrency)
+		failures  = make([]map[string]int, concurrency)
+	)
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			failures[worker] = map[string]int{}
+			for n := 0; ctx.Err() == nil; n++ {
+				t := time.Now()
+				err := op(worker, n)
+				latencies[worker] = append(latencies[worker], time.Since(t))
+				if err != nil {
+					failures[worker][err.Error()]++
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	report := newBenchReport(operation, concurrency, time.Since(start), latencies, failures)
+
+	// The create operation leaves keys behind. We
+	// remove them with the same concurrency - but
+	// without measuring the requests.
+	if operation == "create" {
+		for _, names := range created {
+			wg.Add(1)
+			go func(names []string) {
+				defer wg.Done()
+				for _, name := range names {
+					client.DeleteKey(name)
+				}
+			}(names)
+		}
+		wg.Wait()
+	}
+
+	if jsonOutput || !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(report)
+	}
+	printBenchReport(report)
+	return nil
+}
+
+// newBenchReport computes the benchmark report from the
+// latencies and error messages of all workers.
+func newBenchReport(operation string, concurrency int, duration time.Duration, latencies [][]time.Duration, failures []map[string]int) benchReport {
+	report := benchReport{
+		Operation:     operation,
+		Concurrency:   concurrency,
+		Duration:      duration,
+		ErrorMessages: map[string]int{},
+	}
+
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	for _, f := range failures {
+		for msg, n := range f {
+			report.ErrorMessages[msg] += n
+			report.Errors += n
+		}
+	}
+	report.Requests = len(all)
+	if report.Requests == 0 {
+		return report
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	var sum time.Duration
+	for _, l := range all {
+		sum += l
+	}
+	percentile := func(p int) time.Duration { return all[(len(all)-1)*p/100] }
+
+	report.Throughput = float64(report.Requests-report.Errors) / duration.Seconds()
+	report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+	report.LatencyAvg = sum / time.Duration(len(all))
+	report.LatencyP50 = percentile(50)
+	report.LatencyP90 = percentile(90)
+	report.LatencyP99 = percentile(99)
+	report.LatencyMax = all[len(all)-1]
+	return report
+}
+
+func printBenchReport(report benchReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Operation:\t%s\n", report.Operation)
+	fmt.Fprintf(w, "Concurrency:\t%d\n", report.Concurrency)
+	fmt.Fprintf(w, "Duration:\t%v\n", report.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Requests:\t%d\n", report.Requests)
+	fmt.Fprintf(w, "Throughput:\t%.1f req/s\n", report.Throughput)
+	fmt.Fprintf(w, "Errors:\t%d (%.2f%%)\n", report.Errors, 100*report.ErrorRate)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Latency:\tavg %v\tp50 %v\tp90 %v\tp99 %v\tmax %v\n",
+		report.LatencyAvg.Round(time.Microsecond),
+		report.LatencyP50.Round(time.Microsecond),
+		report.LatencyP90.Round(time.Microsecond),
+		report.LatencyP99.Round(time.Microsecond),
+		report.LatencyMax.Round(time.Microsecond),
+	)
+	w.Flush()
+
+	if len(report.ErrorMessages) > 0 {
+		type failure struct {
+			Message string
+			Count   int
+		}
+		failures := make([]failure, 0, len(report.ErrorMessages))
+		for msg, n := range report.ErrorMessages {
+			failures = append(failures, failure{Message: msg, Count: n})
+		}
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Count > failures[j].Count })
+
+		const MaxFailures = 5
+		fmt.Println()
+		fmt.Println("Errors:")
+		for i, f := range failures {
+			if i == MaxFailures {
+				fmt.Printf("  ... %d more\n", len(failures)-MaxFailures)
+				break
+			}
+			fmt.Printf("  %6d  %s\n", f.Count, f.Message)
+		}
+	}
+}
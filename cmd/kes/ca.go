@@ -0,0 +1,194 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const caCmdUsage = `usage: %s <command>
+
+  create               Create a new certificate authority (CA).
+  sign                 Issue a certificate for a certificate request.
+  chain                Print the certificate chain of a CA.
+
+  -h, --help           Show list of command-line options
+
+A CA issues short-lived TLS certificates - e.g. for mTLS between
+internal services. The CA private key never leaves the server. CAs,
+keys and opaque secrets share the same namespace. Hence, CAs are
+listed, described and deleted via "kes key".
+`
+
+func caCmd(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), caCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		return createCA(args)
+	case "sign":
+		return signCertificate(args)
+	case "chain":
+		return caChain(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const createCACmdUsage = `usage: %s [options] <name>
+
+  --cn <name>          The common name of the CA certificate. By default,
+                       the name of the CA is used.
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Creates a new CA and writes its certificate to STDOUT. For example:
+  $ kes ca create --cn "My App CA" my-app/ca > ca.crt
+`
+
+func createCA(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), createCACmdUsage, cli.Name())
+	}
+
+	var (
+		commonName         string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&commonName, "cn", "", "The common name of the CA certificate")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	cert, err := client.CreateCA(args[0], commonName)
+	if err != nil {
+		return fmt.Errorf("Cannot create CA '%s': %v", args[0], err)
+	}
+	_, err = os.Stdout.Write(cert)
+	return err
+}
+
+const signCertificateCmdUsage = `usage: %s [options] <name> [<csr>]
+
+  --ttl <duration>     The lifetime of the certificate. Must not exceed
+                       7 days. (default: 24h)
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Issues a certificate for the PEM-encoded certificate request (CSR)
+and writes the certificate to STDOUT. If no CSR file is specified, it
+reads the CSR from STDIN. For example:
+  $ kes ca sign --ttl 1h my-app/ca service.csr > service.crt
+`
+
+func signCertificate(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), signCertificateCmdUsage, cli.Name())
+	}
+
+	var (
+		ttl                time.Duration
+		insecureSkipVerify bool
+	)
+	cli.DurationVar(&ttl, "ttl", 0, "The lifetime of the certificate")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) != 1 && len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if len(args) == 2 {
+		file, err := os.Open(args[1])
+		if err != nil {
+			return fmt.Errorf("Cannot open '%s': %v", args[1], err)
+		}
+		defer file.Close()
+		in = file
+	}
+	const MaxSize = 1 << 20 // The server rejects larger CSRs anyway
+	csr, err := ioutil.ReadAll(io.LimitReader(in, MaxSize))
+	if err != nil {
+		return fmt.Errorf("Cannot read certificate request: %v", err)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	cert, err := client.SignCertificate(args[0], csr, ttl)
+	if err != nil {
+		return fmt.Errorf("Cannot issue certificate: %v", err)
+	}
+	_, err = os.Stdout.Write(cert)
+	return err
+}
+
+const caChainCmdUsage = `usage: %s [options] <name>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Writes the PEM-encoded certificate chain of the CA to STDOUT.
+`
+
+func caChain(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), caChainCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	chain, err := client.CAChain(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot get certificate chain of '%s': %v", args[0], err)
+	}
+	_, err = os.Stdout.Write(chain)
+	return err
+}
@@ -5,11 +5,23 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/escrow"
+	xhttp "github.com/minio/kes/internal/http"
+	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/secret"
 	"gopkg.in/yaml.v2"
 )
 
@@ -17,22 +29,221 @@ type serverConfig struct {
 	Addr string       `yaml:"address"`
 	Root kes.Identity `yaml:"root"`
 
+	Probe struct {
+		Addr string `yaml:"address"`
+	} `yaml:"probe"`
+
+	KMIP struct {
+		Addr string `yaml:"address"`
+	} `yaml:"kmip"`
+
+	GRPC struct {
+		Addr string `yaml:"address"`
+	} `yaml:"grpc"`
+
+	Unix struct {
+		Path string `yaml:"path"`
+		Mode string `yaml:"mode"`
+	} `yaml:"unix"`
+
+	Listeners []struct {
+		Addr string `yaml:"address"`
+		TLS  struct {
+			KeyPath  string `yaml:"key"`
+			CertPath string `yaml:"cert"`
+			Auth     string `yaml:"auth"`
+		} `yaml:"tls"`
+		Policy struct {
+			Paths []string `yaml:"paths"`
+			Deny  []string `yaml:"deny"`
+		} `yaml:"policy"`
+	} `yaml:"listeners"`
+
+	Shutdown struct {
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"shutdown"`
+
+	HTTP struct {
+		ReusePort bool `yaml:"reuseport"`
+		Timeout   struct {
+			Read   time.Duration `yaml:"read"`
+			Header time.Duration `yaml:"header"`
+			Write  time.Duration `yaml:"write"`
+			Idle   time.Duration `yaml:"idle"`
+		} `yaml:"timeout"`
+		Header struct {
+			MaxSize int `yaml:"size"` // in KiB
+		} `yaml:"header"`
+		HTTP2 struct {
+			MaxStreams uint32 `yaml:"streams"`
+		} `yaml:"http2"`
+		Proxy struct {
+			Trusted   []string `yaml:"trusted"`
+			Protocol  bool     `yaml:"protocol"`
+			Forwarded bool     `yaml:"forwarded"`
+		} `yaml:"proxy"`
+	} `yaml:"http"`
+
+	Cluster struct {
+		Node   string `yaml:"node"`
+		Dir    string `yaml:"dir"`
+		CAPath string `yaml:"ca"`
+		Peers  map[string]struct {
+			Addr     string       `yaml:"address"`
+			Identity kes.Identity `yaml:"identity"`
+		} `yaml:"peers"`
+	} `yaml:"cluster"`
+
+	Enclave struct {
+		Shared bool `yaml:"shared"`
+	} `yaml:"enclave"`
+
+	Hybrid struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"hybrid"`
+
+	Memory struct {
+		Limit int64 `yaml:"limit"` // in MiB
+	} `yaml:"memory"`
+
+	Data struct {
+		Enabled bool  `yaml:"enabled"`
+		MaxSize int64 `yaml:"size"` // in MiB
+	} `yaml:"data"`
+
+	Secret struct {
+		MaxSize int `yaml:"size"` // in KiB
+	} `yaml:"secret"`
+
+	Seal struct {
+		Enabled bool `yaml:"enabled"`
+		Auto    bool `yaml:"auto"` // Unseal using the KMS
+	} `yaml:"seal"`
+
 	TLS struct {
-		KeyPath  string `yaml:"key"`
-		CertPath string `yaml:"cert"`
-		Proxy    struct {
+		KeyPath  string        `yaml:"key"`
+		CertPath string        `yaml:"cert"`
+		Reload   time.Duration `yaml:"reload"`
+		ACME     struct {
+			Domains   []string `yaml:"domains"`
+			Email     string   `yaml:"email"`
+			Directory string   `yaml:"directory"`
+			CAPath    string   `yaml:"ca"`
+			Cache     string   `yaml:"cache"`
+			HTTP      string   `yaml:"http"`
+		} `yaml:"acme"`
+		Tickets struct {
+			Rotation time.Duration `yaml:"rotation"`
+		} `yaml:"tickets"`
+		Revocation struct {
+			CRL       string        `yaml:"crl"`
+			OCSP      bool          `yaml:"ocsp"`
+			Responder string        `yaml:"responder"`
+			Cache     time.Duration `yaml:"cache"`
+			Failure   string        `yaml:"failure"`
+		} `yaml:"revocation"`
+		Proxy struct {
 			Identities []kes.Identity `yaml:"identities"`
 			Header     struct {
 				ClientCert string `yaml:"cert"`
 			} `yaml:"header"`
 		} `yaml:"proxy"`
+		Signature struct {
+			Required bool          `yaml:"required"`
+			Skew     time.Duration `yaml:"skew"`
+			Key      string        `yaml:"key"`
+		} `yaml:"signature"`
 	} `yaml:"tls"`
 
 	Policies map[string]struct {
-		Paths      []string       `yaml:"paths"`
+		Paths      []string `yaml:"paths"`
+		Deny       []string `yaml:"deny"`
+		Keys       []string `yaml:"keys"`
+		Conditions struct {
+			Time   string   `yaml:"time"`
+			After  string   `yaml:"after"`
+			Before string   `yaml:"before"`
+			IP     []string `yaml:"ip"`
+			OU     []string `yaml:"ou"`
+			SAN    []string `yaml:"san"`
+		} `yaml:"conditions"`
 		Identities []kes.Identity `yaml:"identities"`
 	} `yaml:"policy"`
 
+	LDAP struct {
+		Address string `yaml:"address"`
+		User    struct {
+			DN        string `yaml:"dn"`
+			Base      string `yaml:"base"`
+			Attribute string `yaml:"attribute"`
+		} `yaml:"user"`
+		Group struct {
+			Attribute string `yaml:"attribute"`
+		} `yaml:"group"`
+		Groups map[string]string `yaml:"groups"`
+		Cache  time.Duration     `yaml:"cache"`
+		TLS    struct {
+			CAPath string `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"ldap"`
+
+	OIDC struct {
+		Issuer   string            `yaml:"issuer"`
+		Audience string            `yaml:"audience"`
+		JWKS     string            `yaml:"jwks"`
+		Claim    string            `yaml:"claim"`
+		Groups   map[string]string `yaml:"groups"`
+	} `yaml:"oidc"`
+
+	Authz struct {
+		Endpoint string        `yaml:"endpoint"`
+		Mode     string        `yaml:"mode"`
+		Timeout  time.Duration `yaml:"timeout"`
+		TLS      struct {
+			CAPath string `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"authz"`
+
+	Approval struct {
+		Admins []kes.Identity `yaml:"admins"`
+		Quorum int            `yaml:"quorum"`
+		Window time.Duration  `yaml:"window"`
+		APIs   []string       `yaml:"apis"`
+	} `yaml:"approval"`
+
+	Session struct {
+		Enabled bool          `yaml:"enabled"`
+		TTL     time.Duration `yaml:"ttl"`
+		MaxTTL  time.Duration `yaml:"max_ttl"`
+	} `yaml:"session"`
+
+	Tenants map[string]struct {
+		Identities []kes.Identity `yaml:"identities"`
+		Quota      int            `yaml:"quota"`
+	} `yaml:"tenant"`
+
+	Quota struct {
+		Keys       int                  `yaml:"keys"`
+		Identities map[kes.Identity]int `yaml:"identities"`
+	} `yaml:"quota"`
+
+	Limit struct {
+		Rate       float64 `yaml:"rate"`
+		Burst      int     `yaml:"burst"`
+		Identities map[kes.Identity]struct {
+			Rate  float64 `yaml:"rate"`
+			Burst int     `yaml:"burst"`
+		} `yaml:"identities"`
+		API map[string]struct {
+			Rate  float64 `yaml:"rate"`
+			Burst int     `yaml:"burst"`
+		} `yaml:"api"`
+		Concurrency struct {
+			Requests   int                  `yaml:"requests"`
+			Identities map[kes.Identity]int `yaml:"identities"`
+		} `yaml:"concurrency"`
+	} `yaml:"limit"`
+
 	Cache struct {
 		Expiry struct {
 			Any    time.Duration `yaml:"any"`
@@ -40,71 +251,206 @@ type serverConfig struct {
 		} `yaml:"expiry"`
 	} `yaml:"cache"`
 
+	Usage struct {
+		Interval time.Duration `yaml:"interval"`
+		Metrics  bool          `yaml:"metrics"`
+	} `yaml:"usage"`
+
+	Accounting struct {
+		Enabled   bool          `yaml:"enabled"`
+		Window    time.Duration `yaml:"window"`
+		Retention time.Duration `yaml:"retention"`
+	} `yaml:"accounting"`
+
 	Log struct {
 		Error string `yaml:"error"`
 		Audit string `yaml:"audit"`
+
+		Level  string            `yaml:"level"`
+		Levels map[string]string `yaml:"levels"`
+		Format string            `yaml:"format"`
+
+		Sinks struct {
+			File struct {
+				Path    string `yaml:"path"`
+				MaxSize int64  `yaml:"size"`
+				Backups int    `yaml:"backups"`
+			} `yaml:"file"`
+
+			Syslog struct {
+				Network  string `yaml:"network"`
+				Address  string `yaml:"address"`
+				Tag      string `yaml:"tag"`
+				Facility string `yaml:"facility"`
+				Error    bool   `yaml:"error"`
+				Queue    int    `yaml:"queue"`
+				TLS      struct {
+					CAPath   string `yaml:"ca"`
+					CertPath string `yaml:"cert"`
+					KeyPath  string `yaml:"key"`
+				} `yaml:"tls"`
+			} `yaml:"syslog"`
+
+			Webhook struct {
+				Endpoint string `yaml:"endpoint"`
+			} `yaml:"webhook"`
+		} `yaml:"sinks"`
+
+		Integrity struct {
+			Key      string `yaml:"key"`
+			Pub      string `yaml:"pub"`
+			Interval int    `yaml:"interval"`
+		} `yaml:"integrity"`
 	} `yaml:"log"`
 
-	Keys struct {
-		Fs struct {
-			Path string `yaml:"path"`
-		} `yaml:"fs"`
+	Entropy struct {
+		HWRNG  string `yaml:"hwrng"`
+		RDSEED bool   `yaml:"rdseed"`
+	} `yaml:"entropy"`
 
-		Vault struct {
-			Endpoint   string `yaml:"endpoint"`
-			EnginePath string `yaml:"engine"`
-			Namespace  string `yaml:"namespace"`
+	Attestation struct {
+		Provider string `yaml:"provider"`
+		Path     string `yaml:"path"`
+	} `yaml:"attestation"`
 
-			Prefix string `yaml:"prefix"`
+	Escrow struct {
+		Key        string            `yaml:"key"`
+		Threshold  int               `yaml:"threshold"`
+		Custodians map[string]string `yaml:"custodians"`
+	} `yaml:"escrow"`
 
-			AppRole struct {
-				EnginePath string        `yaml:"engine"`
-				ID         string        `yaml:"id"`
-				Secret     string        `yaml:"secret"`
-				Retry      time.Duration `yaml:"retry"`
-			} `yaml:"approle"`
+	Trace struct {
+		OTLP struct {
+			Endpoint string        `yaml:"endpoint"`
+			Service  string        `yaml:"service"`
+			Interval time.Duration `yaml:"interval"`
+		} `yaml:"otlp"`
+	} `yaml:"trace"`
 
-			TLS struct {
-				KeyPath  string `yaml:"key"`
-				CertPath string `yaml:"cert"`
-				CAPath   string `yaml:"ca"`
-			} `yaml:"tls"`
+	Keys keyStoreConfig `yaml:"keys"`
 
-			Status struct {
-				Ping time.Duration `yaml:"ping"`
-			} `yaml:"status"`
-		} `yaml:"vault"`
+	Migration struct {
+		Keys keyStoreConfig `yaml:"keys"`
+	} `yaml:"migration"`
 
+	KMS struct {
 		Aws struct {
-			SecretsManager struct {
-				Endpoint string `yaml:"endpoint"`
-				Region   string `yaml:"region"`
-				KmsKey   string ` yaml:"kmskey"`
-
-				Login struct {
-					AccessKey    string `yaml:"accesskey"`
-					SecretKey    string `yaml:"secretkey"`
-					SessionToken string `yaml:"token"`
-				} `yaml:"credentials"`
-			} `yaml:"secretsmanager"`
+			Endpoint string `yaml:"endpoint"`
+			Region   string `yaml:"region"`
+			Key      string `yaml:"key"`
+
+			Login struct {
+				AccessKey    string `yaml:"accesskey"`
+				SecretKey    string `yaml:"secretkey"`
+				SessionToken string `yaml:"token"`
+			} `yaml:"credentials"`
+
+			GrantTokens []string `yaml:"grants"`
+
+			Limit struct {
+				Rate  float64 `yaml:"rate"`
+				Burst int     `yaml:"burst"`
+			} `yaml:"limit"`
+
+			Retry struct {
+				Max    uint          `yaml:"max"`
+				Delay  time.Duration `yaml:"delay"`
+				Jitter time.Duration `yaml:"jitter"`
+			} `yaml:"retry"`
+
+			Transport struct {
+				MaxIdleConns        int           `yaml:"maxidle"`
+				IdleConnTimeout     time.Duration `yaml:"idletimeout"`
+				TLSSessionCacheSize int           `yaml:"sessions"`
+			} `yaml:"transport"`
 		} `yaml:"aws"`
+	} `yaml:"kms"`
+}
 
-		Gemalto struct {
-			KeySecure struct {
-				Endpoint string `yaml:"endpoint"`
+// keyStoreConfig is the configuration of a key store
+// backend - e.g. the keys section of the server config.
+type keyStoreConfig struct {
+	Fs struct {
+		Path     string `yaml:"path"`
+		Encoding string `yaml:"encoding"`
+	} `yaml:"fs"`
 
-				Login struct {
-					Token  string        `yaml:"token"`
-					Domain string        `yaml:"domain"`
-					Retry  time.Duration `yaml:"retry"`
-				} `yaml:"credentials"`
+	Vault struct {
+		Endpoint   string `yaml:"endpoint"`
+		EnginePath string `yaml:"engine"`
+		Namespace  string `yaml:"namespace"`
 
-				TLS struct {
-					CAPath string `yaml:"ca"`
-				} `yaml:"tls"`
-			} `yaml:"keysecure"`
-		} `yaml:"gemalto"`
-	} `yaml:"keys"`
+		Prefix   string `yaml:"prefix"`
+		Encoding string `yaml:"encoding"`
+
+		AppRole struct {
+			EnginePath string        `yaml:"engine"`
+			ID         string        `yaml:"id"`
+			Secret     string        `yaml:"secret"`
+			Retry      time.Duration `yaml:"retry"`
+		} `yaml:"approle"`
+
+		TLS struct {
+			KeyPath  string `yaml:"key"`
+			CertPath string `yaml:"cert"`
+			CAPath   string `yaml:"ca"`
+		} `yaml:"tls"`
+
+		Status struct {
+			Ping time.Duration `yaml:"ping"`
+		} `yaml:"status"`
+	} `yaml:"vault"`
+
+	Aws struct {
+		SecretsManager struct {
+			Endpoint string `yaml:"endpoint"`
+			Region   string `yaml:"region"`
+			KmsKey   string ` yaml:"kmskey"`
+
+			Login struct {
+				AccessKey    string `yaml:"accesskey"`
+				SecretKey    string `yaml:"secretkey"`
+				SessionToken string `yaml:"token"`
+			} `yaml:"credentials"`
+
+			RecoveryWindow int `yaml:"recovery"`
+
+			Encoding string `yaml:"encoding"`
+		} `yaml:"secretsmanager"`
+
+		ParameterStore struct {
+			Endpoint string `yaml:"endpoint"`
+			Region   string `yaml:"region"`
+			Prefix   string `yaml:"prefix"`
+			KmsKey   string `yaml:"kmskey"`
+
+			Login struct {
+				AccessKey    string `yaml:"accesskey"`
+				SecretKey    string `yaml:"secretkey"`
+				SessionToken string `yaml:"token"`
+			} `yaml:"credentials"`
+
+			Encoding string `yaml:"encoding"`
+		} `yaml:"parameterstore"`
+	} `yaml:"aws"`
+
+	Gemalto struct {
+		KeySecure struct {
+			Endpoint string `yaml:"endpoint"`
+
+			Login struct {
+				Token  string        `yaml:"token"`
+				Domain string        `yaml:"domain"`
+				Retry  time.Duration `yaml:"retry"`
+			} `yaml:"credentials"`
+
+			TLS struct {
+				CAPath string `yaml:"ca"`
+			} `yaml:"tls"`
+
+			Encoding string `yaml:"encoding"`
+		} `yaml:"keysecure"`
+	} `yaml:"gemalto"`
 }
 
 func loadServerConfig(path string) (config serverConfig, err error) {
@@ -126,7 +472,8 @@ func loadServerConfig(path string) (config serverConfig, err error) {
 	// An identity refers to an env. variable if it has the form:
 	//  ${<env-var-name>}
 	// We then replace the identity with the env. variable value.
-	// Currently only identities can be customized via env. variables.
+	// Currently only identities and AWS-KMS grant tokens can be
+	// customized via env. variables.
 	if refersToEnvVar(config.Root.String()) {
 		config.Root = kes.Identity(os.ExpandEnv(config.Root.String()))
 	}
@@ -142,6 +489,15 @@ func loadServerConfig(path string) (config serverConfig, err error) {
 			}
 		}
 	}
+
+	// AWS-KMS grant tokens are usually issued shortly before
+	// the server starts. Therefore, they can be passed via
+	// env. variables as well.
+	for i, token := range config.KMS.Aws.GrantTokens {
+		if refersToEnvVar(token) {
+			config.KMS.Aws.GrantTokens[i] = os.ExpandEnv(token)
+		}
+	}
 	return config, file.Close()
 }
 
@@ -153,12 +509,671 @@ func (config *serverConfig) SetDefaults() {
 	if config.Log.Error == "" {
 		config.Log.Error = "on" // If not set, default is on.
 	}
-	if config.Keys.Vault.EnginePath == "" {
-		config.Keys.Vault.EnginePath = "kv" // If not set, use the Vault default engine path.
+	if config.Log.Level == "" {
+		config.Log.Level = "info" // If not set, log info, warning and error events.
+	}
+	if config.Shutdown.Timeout == 0 {
+		config.Shutdown.Timeout = 10 * time.Second // If not set, wait at most 10s for in-flight requests.
+	}
+	if config.TLS.Tickets.Rotation == 0 {
+		config.TLS.Tickets.Rotation = time.Hour // If not set, rotate the TLS session ticket keys every hour.
+	}
+	if config.TLS.Revocation.Cache == 0 {
+		config.TLS.Revocation.Cache = 5 * time.Minute // If not set, cache CRLs and OCSP responses for 5 min.
+	}
+	if config.Unix.Mode == "" {
+		config.Unix.Mode = "0660" // If not set, only the owner and group may connect via the Unix socket.
+	}
+	if config.HTTP.Timeout.Read == 0 {
+		config.HTTP.Timeout.Read = 5 * time.Second // If not set, clients must send a request within 5s.
+	}
+	if config.HTTP.Timeout.Idle == 0 {
+		config.HTTP.Timeout.Idle = 90 * time.Second // If not set, keep idle connections open for 90s.
+	}
+	if config.HTTP.Header.MaxSize == 0 {
+		config.HTTP.Header.MaxSize = 1024 // If not set, accept at most 1 MiB of request headers.
+	}
+	if config.HTTP.HTTP2.MaxStreams == 0 {
+		config.HTTP.HTTP2.MaxStreams = 250 // If not set, accept 250 concurrent requests per connection.
+	}
+	if config.Trace.OTLP.Interval == 0 {
+		config.Trace.OTLP.Interval = 5 * time.Second // If not set, export spans every 5s.
+	}
+	if config.Usage.Interval == 0 {
+		config.Usage.Interval = time.Minute // If not set, persist the key usage every minute.
+	}
+	if config.Accounting.Window == 0 {
+		config.Accounting.Window = time.Hour // If not set, count requests per hour.
+	}
+	if config.Accounting.Retention == 0 {
+		config.Accounting.Retention = 7 * 24 * time.Hour // If not set, keep the requests of the last 7 days.
+	}
+	if config.Data.MaxSize == 0 {
+		config.Data.MaxSize = 64 // If not set, accept at most 64 MiB per request.
+	}
+	if config.Secret.MaxSize == 0 {
+		config.Secret.MaxSize = 64 // If not set, accept opaque secrets of at most 64 KiB.
+	}
+	config.Keys.SetDefaults()
+	config.Migration.Keys.SetDefaults()
+}
+
+// SetDefaults set default values for fields that may be empty b/c not specified by user.
+func (keys *keyStoreConfig) SetDefaults() {
+	if keys.Vault.EnginePath == "" {
+		keys.Vault.EnginePath = "kv" // If not set, use the Vault default engine path.
+	}
+	if keys.Vault.AppRole.EnginePath == "" {
+		keys.Vault.AppRole.EnginePath = "approle" // If not set, use the Vault default auth path.
+	}
+}
+
+// count returns the number of key stores specified.
+func (keys *keyStoreConfig) count() int {
+	var keyStores int
+	for _, endpoint := range []string{
+		keys.Fs.Path,
+		keys.Vault.Endpoint,
+		keys.Aws.SecretsManager.Endpoint,
+		keys.Aws.ParameterStore.Endpoint,
+		keys.Gemalto.KeySecure.Endpoint,
+	} {
+		if endpoint != "" {
+			keyStores++
+		}
+	}
+	return keyStores
+}
+
+// verify checks the key store configuration for problems
+// and returns all problems found.
+func (keys *keyStoreConfig) verify() []error {
+	var errs []error
+	if keys.count() > 1 {
+		errs = append(errs, errors.New("Ambiguous configuration: more than one key store specified"))
+	}
+	for _, keyStore := range []struct {
+		Name     string
+		Encoding string
+	}{
+		{Name: "filesystem", Encoding: keys.Fs.Encoding},
+		{Name: "Vault", Encoding: keys.Vault.Encoding},
+		{Name: "AWS SecretsManager", Encoding: keys.Aws.SecretsManager.Encoding},
+		{Name: "AWS ParameterStore", Encoding: keys.Aws.ParameterStore.Encoding},
+		{Name: "Gemalto KeySecure", Encoding: keys.Gemalto.KeySecure.Encoding},
+	} {
+		if !secret.ValidEncoding(keyStore.Encoding) {
+			errs = append(errs, fmt.Errorf("Invalid %s key store encoding '%s': must be '%s', '%s' or '%s'", keyStore.Name, keyStore.Encoding, secret.EncodingJSON, secret.EncodingRaw, secret.EncodingPEM))
+		}
+		if keyStore.Encoding == secret.EncodingRaw && keyStore.Name != "filesystem" {
+			// All other key stores store values as text. Raw bytes
+			// would be modified if they are not valid UTF-8.
+			errs = append(errs, fmt.Errorf("Invalid %s key store encoding '%s': only supported by the filesystem key store", keyStore.Name, keyStore.Encoding))
+		}
+	}
+	return errs
+}
+
+// Verify checks the config for problems - e.g. invalid
+// policies or referenced files that don't exist - and
+// returns all problems found.
+//
+// Verify does not connect to any key store or KMS.
+func (config *serverConfig) Verify() []error {
+	var errs []error
+	if _, err := parsePolicies(config); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch strings.ToLower(config.Log.Error) {
+	case "on", "off":
+	default:
+		errs = append(errs, fmt.Errorf("Error log configuration '%s' is invalid", config.Log.Error))
+	}
+	switch strings.ToLower(config.Log.Audit) {
+	case "on", "off":
+	default:
+		errs = append(errs, fmt.Errorf("Audit log configuration '%s' is invalid", config.Log.Audit))
+	}
+	if _, _, err := parseLogLevels(config); err != nil {
+		errs = append(errs, err)
+	}
+	switch strings.ToLower(config.Log.Format) {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("Error log format '%s' is invalid", config.Log.Format))
+	}
+	if config.Usage.Interval < 0 {
+		errs = append(errs, fmt.Errorf("Key usage interval '%v' is invalid", config.Usage.Interval))
+	}
+	if config.Accounting.Window < time.Minute {
+		errs = append(errs, fmt.Errorf("Accounting window '%v' is invalid: must be at least 1m", config.Accounting.Window))
+	}
+	if config.Accounting.Retention < config.Accounting.Window {
+		errs = append(errs, fmt.Errorf("Accounting retention '%v' is invalid: must not be shorter than the window", config.Accounting.Retention))
+	}
+	if mode, err := strconv.ParseUint(config.Unix.Mode, 8, 32); err != nil || mode > 0777 {
+		errs = append(errs, fmt.Errorf("Unix socket mode '%s' is invalid: must be an octal file mode - e.g. 0660", config.Unix.Mode))
+	}
+	if _, err := parseTrustedProxies(config.HTTP.Proxy.Trusted); err != nil {
+		errs = append(errs, err)
+	}
+	if (config.HTTP.Proxy.Protocol || config.HTTP.Proxy.Forwarded) && len(config.HTTP.Proxy.Trusted) == 0 {
+		errs = append(errs, errors.New("HTTP proxy configuration is invalid: no trusted proxies specified"))
+	}
+	for i, listener := range config.Listeners {
+		if listener.Addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(listener.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("Listener %d: address '%s' is invalid", i, listener.Addr))
+		}
+		if (listener.TLS.KeyPath == "") != (listener.TLS.CertPath == "") {
+			errs = append(errs, fmt.Errorf("Listener %d: TLS private key and certificate must be specified together", i))
+		}
+		switch strings.ToLower(listener.TLS.Auth) {
+		case "", "on", "off":
+		default:
+			errs = append(errs, fmt.Errorf("Listener %d: TLS auth '%s' is invalid: must be 'on' or 'off'", i, listener.TLS.Auth))
+		}
+		if _, err := parseListenerPolicy(listener.Policy.Paths, listener.Policy.Deny); err != nil {
+			errs = append(errs, fmt.Errorf("Listener %d: %v", i, err))
+		}
+	}
+	for name, timeout := range map[string]time.Duration{
+		"read":   config.HTTP.Timeout.Read,
+		"header": config.HTTP.Timeout.Header,
+		"write":  config.HTTP.Timeout.Write,
+		"idle":   config.HTTP.Timeout.Idle,
+	} {
+		if timeout < 0 {
+			errs = append(errs, fmt.Errorf("HTTP %s timeout '%v' is invalid", name, timeout))
+		}
+	}
+	if config.HTTP.Header.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("HTTP header size limit '%d' is invalid", config.HTTP.Header.MaxSize))
+	}
+	if config.Memory.Limit < 0 {
+		errs = append(errs, fmt.Errorf("Memory limit '%d' is invalid", config.Memory.Limit))
+	}
+	if config.Data.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("Data size limit '%d' is invalid", config.Data.MaxSize))
+	}
+	if config.Secret.MaxSize < 0 || config.Secret.MaxSize<<10 > secret.MaxOpaqueSize {
+		errs = append(errs, fmt.Errorf("Secret size limit '%d' is invalid: must be between 1 and %d KiB", config.Secret.MaxSize, secret.MaxOpaqueSize>>10))
+	}
+	if config.Log.Integrity.Key == enclaveSigningKey {
+		if !config.Enclave.Shared {
+			errs = append(errs, errors.New("Audit log integrity key 'enclave' requires a shared enclave key"))
+		}
+	} else if config.Log.Integrity.Key != "" {
+		if _, err := loadSigningKey(config.Log.Integrity.Key); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to load audit log integrity key: %v", err))
+		}
+	}
+	if sink := config.Log.Sinks.Syslog; sink.Network != "" || sink.Address != "" || sink.Tag != "" {
+		switch sink.Network {
+		case "", "udp", "tcp", "tls":
+		default:
+			errs = append(errs, fmt.Errorf("Invalid syslog network '%s': must be 'udp', 'tcp' or 'tls'", sink.Network))
+		}
+		if sink.Network == "" && sink.Address == "" && !xlog.LocalSyslog {
+			errs = append(errs, errors.New("Invalid syslog configuration: there is no local syslog daemon on this platform"))
+		}
+		if sink.Facility != "" {
+			if _, err := xlog.ParseFacility(sink.Facility); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid syslog facility '%s'", sink.Facility))
+			}
+		}
+		if sink.Queue < 0 {
+			errs = append(errs, fmt.Errorf("Invalid syslog queue size '%d': must not be negative", sink.Queue))
+		}
+		if sink.Network != "tls" && (sink.TLS.CAPath != "" || sink.TLS.CertPath != "" || sink.TLS.KeyPath != "") {
+			errs = append(errs, errors.New("Invalid syslog configuration: TLS settings require the 'tls' network"))
+		}
+		if (sink.TLS.CertPath == "") != (sink.TLS.KeyPath == "") {
+			errs = append(errs, errors.New("Invalid syslog configuration: TLS client certificate and private key must be specified together"))
+		}
+	} else if sink.Error {
+		errs = append(errs, errors.New("Invalid syslog configuration: sending error log records requires a syslog network, address or tag"))
+	}
+	switch config.Attestation.Provider {
+	case "", "tsm", "sgx":
+	default:
+		errs = append(errs, fmt.Errorf("Invalid attestation provider '%s': must be 'tsm' or 'sgx'", config.Attestation.Provider))
+	}
+	if config.TLS.Signature.Key != "" {
+		if _, err := loadSigningKey(config.TLS.Signature.Key); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to load response signing key: %v", err))
+		}
+	}
+	if len(config.Escrow.Custodians) > 0 || config.Escrow.Key != "" {
+		if config.Escrow.Key == "" {
+			errs = append(errs, errors.New("Key escrow requires a signing key"))
+		} else if _, err := loadSigningKey(config.Escrow.Key); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to load escrow signing key: %v", err))
+		}
+		if n := len(config.Escrow.Custodians); config.Escrow.Threshold < 1 || config.Escrow.Threshold > n || n > 255 {
+			errs = append(errs, fmt.Errorf("Invalid escrow threshold '%d': must be between 1 and the number of custodians - at most 255", config.Escrow.Threshold))
+		}
+		if _, err := newEscrowCustodians(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if config.Seal.Enabled {
+		if config.KMS.Aws.Endpoint != "" && !config.Seal.Auto {
+			errs = append(errs, errors.New("Ambiguous configuration: seal and KMS specified - enable auto-unseal to protect the seal with the KMS"))
+		}
+		if config.Seal.Auto && config.KMS.Aws.Endpoint == "" {
+			errs = append(errs, errors.New("Seal auto-unseal requires a KMS"))
+		}
+		if len(config.Cluster.Peers) > 0 {
+			errs = append(errs, errors.New("Seal is not supported in cluster mode"))
+		}
+	}
+	if config.Enclave.Shared {
+		if config.KMS.Aws.Endpoint == "" {
+			errs = append(errs, errors.New("Shared enclave key requires a KMS"))
+		}
+		if len(config.Cluster.Peers) > 0 {
+			errs = append(errs, errors.New("Shared enclave key is not supported in cluster mode"))
+		}
+	}
+	if config.Hybrid.Enabled {
+		if config.KMS.Aws.Endpoint == "" {
+			errs = append(errs, errors.New("Hybrid wrapping requires a KMS"))
+		}
+		if len(config.Cluster.Peers) > 0 {
+			errs = append(errs, errors.New("Hybrid wrapping is not supported in cluster mode"))
+		}
+	}
+
+	if len(config.TLS.ACME.Domains) == 0 && config.TLS.KeyPath != "" && config.TLS.CertPath != "" {
+		if _, err := tls.LoadX509KeyPair(config.TLS.CertPath, config.TLS.KeyPath); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to load TLS certificate: %v", err))
+		}
+	}
+	switch strings.ToLower(config.TLS.Revocation.Failure) {
+	case "", "open", "closed":
+	default:
+		errs = append(errs, fmt.Errorf("Invalid option for revocation failure policy: %s", config.TLS.Revocation.Failure))
+	}
+
+	files := map[string]string{ // All referenced files that must exist
+		"TLS ACME CA certificates":                    config.TLS.ACME.CAPath,
+		"Cluster CA certificates":                     config.Cluster.CAPath,
+		"LDAP CA certificates":                        config.LDAP.TLS.CAPath,
+		"Authz CA certificates":                       config.Authz.TLS.CAPath,
+		"Vault client private key":                    config.Keys.Vault.TLS.KeyPath,
+		"Vault client certificate":                    config.Keys.Vault.TLS.CertPath,
+		"Vault CA certificates":                       config.Keys.Vault.TLS.CAPath,
+		"Gemalto KeySecure CA certificates":           config.Keys.Gemalto.KeySecure.TLS.CAPath,
+		"Migration Vault client private key":          config.Migration.Keys.Vault.TLS.KeyPath,
+		"Migration Vault client certificate":          config.Migration.Keys.Vault.TLS.CertPath,
+		"Migration Vault CA certificates":             config.Migration.Keys.Vault.TLS.CAPath,
+		"Migration Gemalto KeySecure CA certificates": config.Migration.Keys.Gemalto.KeySecure.TLS.CAPath,
+	}
+	if crl := config.TLS.Revocation.CRL; !strings.HasPrefix(crl, "https://") && !strings.HasPrefix(crl, "http://") {
+		files["CRL"] = crl
+	}
+	for name, path := range files {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("Cannot access %s: %v", name, err))
+		}
+	}
+
+	keyStores := config.Keys.count()
+	errs = append(errs, config.Keys.verify()...)
+	for _, err := range config.Migration.Keys.verify() {
+		errs = append(errs, fmt.Errorf("Invalid migration target: %v", err))
+	}
+	if config.Cluster.Node != "" || len(config.Cluster.Peers) > 0 {
+		if _, ok := config.Cluster.Peers[config.Cluster.Node]; !ok {
+			errs = append(errs, fmt.Errorf("Cluster node '%s' is not a cluster peer", config.Cluster.Node))
+		}
+		if config.Cluster.Dir == "" {
+			errs = append(errs, errors.New("Cluster directory is not specified"))
+		}
+		for id, peer := range config.Cluster.Peers {
+			if !strings.HasPrefix(peer.Addr, "https://") {
+				errs = append(errs, fmt.Errorf("Cluster peer '%s' has no HTTPS address", id))
+			}
+			if peer.Identity.IsUnknown() {
+				errs = append(errs, fmt.Errorf("Cluster peer '%s' has no identity", id))
+			}
+		}
+		if keyStores > 0 && config.Keys.Fs.Path == "" {
+			errs = append(errs, errors.New("Cluster mode requires the filesystem or in-memory key store"))
+		}
+		if len(config.TLS.ACME.Domains) > 0 {
+			errs = append(errs, errors.New("Cluster mode does not support ACME certificates"))
+		}
+	}
+
+	assigned := map[kes.Identity]string{}
+	for name, policy := range config.Policies {
+		for _, identity := range policy.Identities {
+			if identity.IsUnknown() {
+				continue
+			}
+			if identity == config.Root {
+				errs = append(errs, fmt.Errorf("Cannot assign policy '%s' to root identity '%s'", name, identity))
+			}
+			if other, ok := assigned[identity]; ok && other != name {
+				errs = append(errs, fmt.Errorf("Cannot assign policy '%s' to identity '%s': this identity already has the policy '%s'", name, identity, other))
+			}
+			assigned[identity] = name
+		}
+	}
+	for _, identity := range config.TLS.Proxy.Identities {
+		if identity == config.Root && !identity.IsUnknown() {
+			errs = append(errs, fmt.Errorf("Cannot use root identity '%s' as TLS proxy", identity))
+		}
+		if name, ok := assigned[identity]; ok {
+			errs = append(errs, fmt.Errorf("Cannot assign policy '%s' to TLS proxy '%s'", name, identity))
+		}
+	}
+	if config.TLS.Tickets.Rotation < 0 {
+		errs = append(errs, fmt.Errorf("Invalid TLS session ticket key rotation '%v': must not be negative", config.TLS.Tickets.Rotation))
+	}
+	if config.TLS.Signature.Skew < 0 {
+		errs = append(errs, fmt.Errorf("Invalid request signature skew '%v': must not be negative", config.TLS.Signature.Skew))
+	}
+	for group, policy := range config.LDAP.Groups {
+		if _, ok := config.Policies[policy]; !ok {
+			errs = append(errs, fmt.Errorf("Cannot map LDAP group '%s' to policy '%s': policy does not exist", group, policy))
+		}
+	}
+	for value, policy := range config.OIDC.Groups {
+		if _, ok := config.Policies[policy]; !ok {
+			errs = append(errs, fmt.Errorf("Cannot map OIDC claim '%s' to policy '%s': policy does not exist", value, policy))
+		}
+	}
+	switch config.Authz.Mode {
+	case "", auth.AuthzAugment, auth.AuthzReplace:
+	default:
+		errs = append(errs, fmt.Errorf("Invalid authorization mode '%s': must be '%s' or '%s'", config.Authz.Mode, auth.AuthzAugment, auth.AuthzReplace))
+	}
+	if len(config.Approval.Admins) > 0 {
+		admins := map[kes.Identity]bool{}
+		for _, admin := range config.Approval.Admins {
+			if admin.IsUnknown() {
+				errs = append(errs, errors.New("Invalid approval admin: identity is empty"))
+			}
+			if admin == config.Root {
+				errs = append(errs, fmt.Errorf("Cannot use root identity '%s' as approval admin", admin))
+			}
+			admins[admin] = true
+		}
+		if config.Approval.Quorum < 1 || config.Approval.Quorum > len(admins) {
+			errs = append(errs, fmt.Errorf("Invalid approval quorum '%d': must be between 1 and the number of admins (%d)", config.Approval.Quorum, len(admins)))
+		}
+		if config.Approval.Window < 0 {
+			errs = append(errs, fmt.Errorf("Invalid approval window '%v': must not be negative", config.Approval.Window))
+		}
+	}
+	if config.Session.TTL < 0 || config.Session.MaxTTL < 0 {
+		errs = append(errs, errors.New("Invalid session TTL: must not be negative"))
+	}
+	if config.Session.MaxTTL > 0 && config.Session.TTL > config.Session.MaxTTL {
+		errs = append(errs, fmt.Errorf("Invalid session TTL '%v': exceeds max. TTL '%v'", config.Session.TTL, config.Session.MaxTTL))
+	}
+	if _, err := newTenants(config, config.Root); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newQuotas(config, config.Root); err != nil {
+		errs = append(errs, err)
+	}
+	if _, _, _, err := rateLimits(config); err != nil {
+		errs = append(errs, err)
+	}
+	if config.Limit.Concurrency.Requests < 0 {
+		errs = append(errs, fmt.Errorf("Invalid concurrency limit '%d': must not be negative", config.Limit.Concurrency.Requests))
+	}
+	for identity, limit := range config.Limit.Concurrency.Identities {
+		if limit < 0 {
+			errs = append(errs, fmt.Errorf("Invalid concurrency limit '%d' for identity '%s': must not be negative", limit, identity))
+		}
+	}
+	return errs
+}
+
+// newQuotas returns the key quotas specified in the config.
+// It returns nil if the config does not specify any quota.
+func newQuotas(config *serverConfig, root kes.Identity) (*auth.Quotas, error) {
+	enabled := config.Quota.Keys != 0 || len(config.Quota.Identities) > 0
+	for _, tenant := range config.Tenants {
+		enabled = enabled || tenant.Quota != 0
 	}
-	if config.Keys.Vault.AppRole.EnginePath == "" {
-		config.Keys.Vault.AppRole.EnginePath = "approle" // If not set, use the Vault default auth path.
+	if !enabled {
+		return nil, nil
+	}
+
+	if config.Quota.Keys < 0 {
+		return nil, fmt.Errorf("Invalid default key quota '%d'", config.Quota.Keys)
+	}
+	quotas := &auth.Quotas{Root: root}
+	quotas.SetDefault(config.Quota.Keys)
+	for identity, limit := range config.Quota.Identities {
+		if limit < 0 {
+			return nil, fmt.Errorf("Invalid key quota '%d' for identity '%s'", limit, identity)
+		}
+		if identity == root {
+			return nil, fmt.Errorf("Cannot set key quota for root identity '%s'", identity)
+		}
+		quotas.SetIdentityLimit(identity, limit)
+	}
+	for name, tenant := range config.Tenants {
+		if tenant.Quota < 0 {
+			return nil, fmt.Errorf("Invalid key quota '%d' for tenant '%s'", tenant.Quota, name)
+		}
+		quotas.SetTenantLimit(name, tenant.Quota)
+	}
+	return quotas, nil
+}
+
+// keyStoreEncoding returns the encoding of secrets at
+// the key store specified in the config.
+func keyStoreEncoding(config *serverConfig) string {
+	switch {
+	case config.Keys.Fs.Path != "":
+		return config.Keys.Fs.Encoding
+	case config.Keys.Vault.Endpoint != "":
+		return config.Keys.Vault.Encoding
+	case config.Keys.Aws.SecretsManager.Endpoint != "":
+		return config.Keys.Aws.SecretsManager.Encoding
+	case config.Keys.Aws.ParameterStore.Endpoint != "":
+		return config.Keys.Aws.ParameterStore.Encoding
+	case config.Keys.Gemalto.KeySecure.Endpoint != "":
+		return config.Keys.Gemalto.KeySecure.Encoding
+	default:
+		return secret.EncodingJSON
+	}
+}
+
+// rateLimits returns the default, identity and API
+// rate limits specified in the config.
+func rateLimits(config *serverConfig) (xhttp.RateLimit, map[kes.Identity]xhttp.RateLimit, map[string]xhttp.RateLimit, error) {
+	if config.Limit.Rate < 0 || config.Limit.Burst < 0 {
+		return xhttp.RateLimit{}, nil, nil, errors.New("Invalid rate limit: rate and burst must not be negative")
+	}
+	limit := xhttp.RateLimit{
+		Rate:  config.Limit.Rate,
+		Burst: config.Limit.Burst,
+	}
+
+	identities := make(map[kes.Identity]xhttp.RateLimit, len(config.Limit.Identities))
+	for identity, l := range config.Limit.Identities {
+		if l.Rate < 0 || l.Burst < 0 {
+			return xhttp.RateLimit{}, nil, nil, fmt.Errorf("Invalid rate limit for identity '%s': rate and burst must not be negative", identity)
+		}
+		identities[identity] = xhttp.RateLimit{Rate: l.Rate, Burst: l.Burst}
+	}
+	apis := make(map[string]xhttp.RateLimit, len(config.Limit.API))
+	for api, l := range config.Limit.API {
+		if !strings.HasPrefix(api, "/v1/") {
+			return xhttp.RateLimit{}, nil, nil, fmt.Errorf("Invalid rate limit for API '%s': API path must start with '/v1/'", api)
+		}
+		if l.Rate < 0 || l.Burst < 0 {
+			return xhttp.RateLimit{}, nil, nil, fmt.Errorf("Invalid rate limit for API '%s': rate and burst must not be negative", api)
+		}
+		apis[api] = xhttp.RateLimit{Rate: l.Rate, Burst: l.Burst}
+	}
+	return limit, identities, apis, nil
+}
+
+// newTenants returns the tenants specified in the config.
+// newEscrowCustodians returns the key escrow custodians
+// specified in the config - sorted by name.
+func newEscrowCustodians(config *serverConfig) ([]escrow.Custodian, error) {
+	custodians := make([]escrow.Custodian, 0, len(config.Escrow.Custodians))
+	for name, publicKey := range config.Escrow.Custodians {
+		key, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("Invalid public key of escrow custodian '%s': must be a base64-encoded X25519 public key", name)
+		}
+		custodians = append(custodians, escrow.Custodian{Name: name, PublicKey: key})
+	}
+	sort.Slice(custodians, func(i, j int) bool { return custodians[i].Name < custodians[j].Name })
+	return custodians, nil
+}
+
+// It returns nil if the config does not specify any tenant.
+func newTenants(config *serverConfig, root kes.Identity) (*auth.Tenants, error) {
+	if len(config.Tenants) == 0 {
+		return nil, nil
+	}
+
+	var proxies = map[kes.Identity]bool{}
+	for _, identity := range config.TLS.Proxy.Identities {
+		proxies[identity] = true
+	}
+
+	tenants := new(auth.Tenants)
+	for name, tenant := range config.Tenants {
+		for _, identity := range tenant.Identities {
+			if identity == root && !identity.IsUnknown() {
+				return nil, fmt.Errorf("Cannot bind root identity '%s' to tenant '%s'", identity, name)
+			}
+			if proxies[identity] && !identity.IsUnknown() {
+				return nil, fmt.Errorf("Cannot bind TLS proxy '%s' to tenant '%s'", identity, name)
+			}
+		}
+		if err := tenants.Add(name, tenant.Identities...); err != nil {
+			return nil, fmt.Errorf("Invalid tenant '%s': %v", name, err)
+		}
+	}
+	return tenants, nil
+}
+
+// parseLogLevels parses the error log level and the
+// per-component levels specified in the config.
+func parseLogLevels(config *serverConfig) (xlog.Level, map[string]xlog.Level, error) {
+	level, err := xlog.ParseLevel(config.Log.Level)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Error log level '%s' is invalid", config.Log.Level)
+	}
+	levels := make(map[string]xlog.Level, len(config.Log.Levels))
+	for component, l := range config.Log.Levels {
+		if levels[component], err = xlog.ParseLevel(l); err != nil {
+			return 0, nil, fmt.Errorf("Error log level '%s' of '%s' is invalid", l, component)
+		}
+	}
+	return level, levels, nil
+}
+
+// parsePolicies parses the policies specified in the config.
+func parsePolicies(config *serverConfig) (map[string]*kes.Policy, error) {
+	policies := make(map[string]*kes.Policy, len(config.Policies))
+	for name, policy := range config.Policies {
+		p, err := kes.NewPolicy(policy.Paths...)
+		if err != nil {
+			return nil, fmt.Errorf("Policy '%s' contains invalid path: %v", name, err)
+		}
+		if err = p.Deny(policy.Deny...); err != nil {
+			return nil, fmt.Errorf("Policy '%s' contains invalid deny path: %v", name, err)
+		}
+		if err = p.RestrictKeys(policy.Keys...); err != nil {
+			return nil, fmt.Errorf("Policy '%s' contains invalid key pattern: %v", name, err)
+		}
+		if c := policy.Conditions; c.Time != "" || c.After != "" || c.Before != "" || len(c.IP) > 0 || len(c.OU) > 0 || len(c.SAN) > 0 {
+			conditions := kes.PolicyConditions{
+				Time: c.Time,
+				IP:   c.IP,
+				OU:   c.OU,
+				SAN:  c.SAN,
+			}
+			if c.After != "" {
+				after, err := time.Parse(time.RFC3339, c.After)
+				if err != nil {
+					return nil, fmt.Errorf("Policy '%s' contains invalid 'after' condition: %v", name, err)
+				}
+				conditions.After = &after
+			}
+			if c.Before != "" {
+				before, err := time.Parse(time.RFC3339, c.Before)
+				if err != nil {
+					return nil, fmt.Errorf("Policy '%s' contains invalid 'before' condition: %v", name, err)
+				}
+				conditions.Before = &before
+			}
+			if err = p.SetConditions(conditions); err != nil {
+				return nil, fmt.Errorf("Policy '%s' contains invalid conditions: %v", name, err)
+			}
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// parseListenerPolicy parses the API policy of a listener.
+// It returns nil if neither paths nor deny paths are
+// specified - i.e. if the listener serves all APIs. If only
+// deny paths are specified, all other APIs are allowed.
+func parseListenerPolicy(paths, deny []string) (*kes.Policy, error) {
+	if len(paths) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	if len(paths) == 0 {
+		paths = []string{"/**"}
+	}
+	policy, err := kes.NewPolicy(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("Policy contains invalid path: %v", err)
+	}
+	if err = policy.Deny(deny...); err != nil {
+		return nil, fmt.Errorf("Policy contains invalid deny path: %v", err)
+	}
+	return policy, nil
+}
+
+// parseTrustedProxies parses the networks of trusted proxies.
+// A network is either a CIDR - e.g. 10.0.0.0/8 - or a single
+// IP address.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("Trusted proxy '%s' is invalid: must be an IP address or CIDR", proxy)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Trusted proxy '%s' is invalid: must be an IP address or CIDR", proxy)
+		}
+		networks = append(networks, network)
 	}
+	return networks, nil
 }
 
 // refersToEnvVar returns true if s has the following form:
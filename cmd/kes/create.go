@@ -6,13 +6,23 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 const createCmdUsage = `usage: %s name [key]
 
+  --ops <list>         Restrict the key to a comma-separated list of operations:
+                       generate, encrypt, decrypt and export. A restricted key
+                       cannot be used for any other operation and is only
+                       included in backups if the list contains export.
+                       By default, the key can be used for any operation.
+  --immutable          Mark the key as immutable. An immutable key can never
+                       be deleted - only disabled.
+
   -k, --insecure       Skip X.509 certificate validation during TLS handshake
 
   -h, --help           Show list of command-line options
@@ -24,7 +34,13 @@ func createKey(args []string) error {
 		fmt.Fprintf(cli.Output(), createCmdUsage, cli.Name())
 	}
 
-	var insecureSkipVerify bool
+	var (
+		insecureSkipVerify bool
+		opsFlag            string
+		immutable          bool
+	)
+	cli.StringVar(&opsFlag, "ops", "", "Restrict the key to a comma-separated list of operations")
+	cli.BoolVar(&immutable, "immutable", false, "Mark the key as immutable")
 	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
 	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
 	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 && len(args) != 2 {
@@ -43,6 +59,18 @@ func createKey(args []string) error {
 		}
 		bytes = b
 	}
+	if immutable && len(bytes) > 0 {
+		return errors.New("Cannot mark an imported key as immutable")
+	}
+	var ops []string
+	if opsFlag != "" {
+		if len(bytes) > 0 {
+			return errors.New("Cannot restrict the operations of an imported key")
+		}
+		for _, op := range strings.Split(opsFlag, ",") {
+			ops = append(ops, strings.TrimSpace(op))
+		}
+	}
 
 	client, err := newClient(insecureSkipVerify)
 	if err != nil {
@@ -52,8 +80,12 @@ func createKey(args []string) error {
 		if err = client.ImportKey(name, bytes); err != nil {
 			return fmt.Errorf("Failed to import %s: %v", name, err)
 		}
+	} else if immutable {
+		if err = client.CreateImmutableKey(name, ops); err != nil {
+			return fmt.Errorf("Failed to create %s: %v", name, err)
+		}
 	} else {
-		if err = client.CreateKey(name); err != nil {
+		if err = client.CreateKeyWithOps(name, ops); err != nil {
 			return fmt.Errorf("Failed to create %s: %v", name, err)
 		}
 	}
@@ -8,13 +8,35 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"time"
 )
 
-const deleteCmdUsage = `usage: %s name
+const deleteCmdUsage = `usage: %s [options] name
+
+  --prefix             Delete all keys under the prefix 'name/'
+  --schedule=<days>    Delete the key after the given number of days -
+                       between 7 and 30. Until then, the key cannot be
+                       used and the deletion can be cancelled.
+  --cancel             Cancel the scheduled deletion of the key
+  --if-match=<version> Delete the key only if its version matches
 
   -k, --insecure       Skip X.509 certificate validation during TLS handshake
 
   -h, --help           Show list of command-line options
+
+Deletes the key with the given name. With --prefix, it deletes
+all keys whose name starts with the given name followed by a '/'
+and prints the names of the deleted keys. Keys that are immutable
+or under legal hold are skipped. For example:
+  $ kes key delete my-key
+  $ kes key delete --prefix my-app
+  $ kes key delete --schedule=7 my-key
+  $ kes key delete --cancel my-key
+
+With --if-match, the key is only deleted if it has not been modified
+since it has been described. See: kes key describe
+  $ kes key delete --if-match=8f2e6b1c0d9a4e7f5b3c2a1d0e9f8a7b my-key
 `
 
 func deleteKey(args []string) error {
@@ -23,7 +45,17 @@ func deleteKey(args []string) error {
 		fmt.Fprintf(cli.Output(), deleteCmdUsage, cli.Name())
 	}
 
-	var insecureSkipVerify bool
+	var (
+		prefix             bool
+		days               int
+		cancel             bool
+		version            string
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&prefix, "prefix", false, "Delete all keys under the prefix 'name/'")
+	cli.IntVar(&days, "schedule", 0, "Delete the key after the given number of days")
+	cli.BoolVar(&cancel, "cancel", false, "Cancel the scheduled deletion of the key")
+	cli.StringVar(&version, "if-match", "", "Delete the key only if its version matches")
 	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
 	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
 	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
@@ -36,6 +68,44 @@ func deleteKey(args []string) error {
 	if err != nil {
 		return err
 	}
+	if cancel {
+		if err = client.CancelKeyDeletion(name); err != nil {
+			return fmt.Errorf("Failed to cancel deletion of %s: %v", name, err)
+		}
+		return nil
+	}
+	if isFlagPresent(cli, "schedule") {
+		deleteAt, err := client.ScheduleKeyDeletion(name, days)
+		if err != nil {
+			return fmt.Errorf("Failed to schedule deletion of %s: %v", name, err)
+		}
+		fmt.Printf("Deleting %s on %s\n", name, deleteAt.Local().Format(time.RFC3339))
+		return nil
+	}
+	if prefix {
+		report, err := client.DeleteKeys(name)
+		for _, key := range report.Deleted {
+			fmt.Println(key)
+		}
+		skipped := make([]string, 0, len(report.Skipped))
+		for key := range report.Skipped {
+			skipped = append(skipped, key)
+		}
+		sort.Strings(skipped)
+		for _, key := range skipped {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %s\n", key, report.Skipped[key])
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to delete keys under %s: %v", name, err)
+		}
+		return nil
+	}
+	if version != "" {
+		if err = client.DeleteKeyIfMatch(name, version); err != nil {
+			return fmt.Errorf("Failed to delete %s: %v", name, err)
+		}
+		return nil
+	}
 	if err := client.DeleteKey(name); err != nil {
 		return fmt.Errorf("Failed to delete %s: %v", name, err)
 	}
@@ -0,0 +1,100 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const disableCmdUsage = `usage: %s [options] name...
+
+  --if-match=<version> Disable the key only if its version matches
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Disables the keys with the given names. A disabled key cannot be
+used for any key operation until it gets enabled again. In contrast
+to deleting, disabling a key can be undone. For example:
+  $ kes key disable my-key
+  $ kes key enable my-key
+
+With --if-match, a single key is only disabled if it has not been
+modified since it has been described. See: kes key describe
+`
+
+func disableKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), disableCmdUsage, cli.Name())
+	}
+
+	var (
+		version            string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&version, "if-match", "", "Disable the key only if its version matches")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 || (version != "" && len(args) != 1) {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		if err = client.DisableKeyIfMatch(args[0], version); err != nil {
+			return fmt.Errorf("Failed to disable %s: %v", args[0], err)
+		}
+		return nil
+	}
+	for _, name := range args {
+		if err = client.DisableKey(name); err != nil {
+			return fmt.Errorf("Failed to disable %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+const enableCmdUsage = `usage: %s [options] name...
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Enables the keys with the given names after they have been disabled.
+`
+
+func enableKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), enableCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if err = client.EnableKey(name); err != nil {
+			return fmt.Errorf("Failed to enable %s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/minio/kes"
+)
+
+const enclaveCmdUsage = `usage: %s <command>
+
+  create               Create a new enclave.
+  delete               Delete an enclave.
+  list                 List all enclaves.
+
+  -h, --help           Show list of command-line options
+
+An enclave is an isolated virtual server with its own root identity,
+policies, identities and keys. Commands are sent to an enclave when
+the env. variable KES_ENCLAVE is set to the enclave name.
+  $ export KES_ENCLAVE=my-enclave
+  $ kes key create my-key
+`
+
+func enclave(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), enclaveCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		return createEnclave(args)
+	case "delete":
+		return deleteEnclave(args)
+	case "list":
+		return listEnclaves(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const createEnclaveCmdUsage = `usage: %s [options] <name> <root-identity>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Creates a new enclave with the given root identity.
+  $ kes enclave create my-enclave $(kes tool identity of client.crt)
+`
+
+func createEnclave(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), createEnclaveCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err = client.CreateEnclave(args[0], kes.Identity(args[1])); err != nil {
+		return fmt.Errorf("Cannot create enclave '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const deleteEnclaveCmdUsage = `usage: %s [options] <name>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Deletes an enclave including all its keys, policies and identities.
+  $ kes enclave delete my-enclave
+`
+
+func deleteEnclave(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), deleteEnclaveCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err = client.DeleteEnclave(args[0]); err != nil {
+		return fmt.Errorf("Cannot delete enclave '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const listEnclavesCmdUsage = `usage: %s [options]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func listEnclaves(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listEnclavesCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	enclaves, err := client.ListEnclaves()
+	if err != nil {
+		return fmt.Errorf("Cannot list enclaves: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(enclaves)
+	}
+	for _, enclave := range enclaves {
+		fmt.Printf("%-24s %s\n", enclave.Name, enclave.Root)
+	}
+	return nil
+}
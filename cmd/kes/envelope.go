@@ -0,0 +1,210 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/kes/internal/envelope"
+)
+
+const encryptDataCmdUsage = `usage: %s [options] --key=<name> [<file>]
+
+  --key <name>         The key that encrypts the data key
+  -o, --output <file>  Write the envelope to the file instead of STDOUT
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Encrypts a file - or STDIN if no file is given - with a new data key
+generated by the server. The data is encrypted locally. The output is
+an envelope that contains the encrypted data key and the encrypted data.
+For example:
+  $ kes encrypt --key=my-key config.yaml > config.yaml.enc
+
+Use "kes decrypt" to decrypt the envelope.
+`
+
+func encryptData(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), encryptDataCmdUsage, cli.Name())
+	}
+
+	var (
+		keyName            string
+		output             string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&keyName, "key", "", "The key that encrypts the data key")
+	cli.StringVar(&output, "o", "", "Write the envelope to the file instead of STDOUT")
+	cli.StringVar(&output, "output", "", "Write the envelope to the file instead of STDOUT")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	if keyName == "" {
+		return errors.New("No key specified: use --key=<name>")
+	}
+
+	in := io.Reader(os.Stdin)
+	if len(args) == 1 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("Cannot open '%s': %v", args[0], err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	dek, err := client.GenerateKey(keyName, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to generate data key: %v", err)
+	}
+	defer wipe(dek.Plaintext)
+
+	return writeOutput(output, func(out io.Writer) error {
+		// Closing the envelope must not close the output.
+		// We have to sync the output file before closing it.
+		out = struct{ io.Writer }{out}
+
+		w, err := envelope.NewWriter(out, keyName, dek.Plaintext, dek.Ciphertext)
+		if err != nil {
+			return fmt.Errorf("Failed to encrypt data: %v", err)
+		}
+		if _, err = io.Copy(w, in); err != nil {
+			return fmt.Errorf("Failed to encrypt data: %v", err)
+		}
+		if err = w.Close(); err != nil {
+			return fmt.Errorf("Failed to encrypt data: %v", err)
+		}
+		return nil
+	})
+}
+
+const decryptDataCmdUsage = `usage: %s [options] [<file>]
+
+  -o, --output <file>  Write the data to the file instead of STDOUT
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Decrypts an envelope created by "kes encrypt" - read from the file
+or STDIN if no file is given. The server decrypts the data key of
+the envelope. The data is decrypted locally. For example:
+  $ kes decrypt config.yaml.enc > config.yaml
+
+If the envelope has been modified, the command fails. When writing
+to STDOUT, data that has been written before such a failure must
+not be used. When writing to a file, the file is not created.
+`
+
+func decryptData(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), decryptDataCmdUsage, cli.Name())
+	}
+
+	var (
+		output             string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&output, "o", "", "Write the data to the file instead of STDOUT")
+	cli.StringVar(&output, "output", "", "Write the data to the file instead of STDOUT")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	in := io.Reader(os.Stdin)
+	if len(args) == 1 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("Cannot open '%s': %v", args[0], err)
+		}
+		defer file.Close()
+		in = file
+	}
+	header, in, err := envelope.ReadHeader(in)
+	if err != nil {
+		return fmt.Errorf("Invalid envelope: %v", err)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	plaintext, err := client.Decrypt(header.Key, header.DEK, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to decrypt data key: %v", err)
+	}
+	defer wipe(plaintext)
+
+	return writeOutput(output, func(out io.Writer) error {
+		r, err := header.NewReader(in, plaintext)
+		if err != nil {
+			return fmt.Errorf("Invalid envelope: %v", err)
+		}
+		if _, err = io.Copy(out, r); err != nil {
+			return fmt.Errorf("Failed to decrypt data: %v", err)
+		}
+		return nil
+	})
+}
+
+// writeOutput calls f with STDOUT if filename is empty.
+// Otherwise, it calls f with a temp. file that replaces
+// the file once f has succeeded. If f fails, the temp.
+// file is removed.
+func writeOutput(filename string, f func(io.Writer) error) error {
+	if filename == "" {
+		return f(os.Stdout)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("Cannot create '%s': %v", filename, err)
+	}
+	defer os.Remove(file.Name()) // Fails once the file has been renamed
+
+	if err = f(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("Cannot write '%s': %v", filename, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("Cannot write '%s': %v", filename, err)
+	}
+	if err = os.Rename(file.Name(), filename); err != nil {
+		return fmt.Errorf("Cannot write '%s': %v", filename, err)
+	}
+	return nil
+}
+
+// wipe overwrites b with zeros.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
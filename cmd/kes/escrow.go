@@ -0,0 +1,53 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const escrowCmdUsage = `usage: %s [options] <file> <pattern>...
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Exports all keys matching at least one of the glob patterns to the
+escrow custodians of the server and writes the signed escrow package
+to the file. Any threshold of custodians can recover the keys offline.
+See: kes tool escrow
+  $ kes escrow escrow.json 'bucket-*'
+`
+
+func escrowCmd(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), escrowCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) < 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	pkg, err := client.Escrow(args[1:]...)
+	if err != nil {
+		return fmt.Errorf("Cannot export keys to escrow: %v", err)
+	}
+	if err = ioutil.WriteFile(args[0], pkg, 0600); err != nil {
+		return fmt.Errorf("Cannot write escrow package: %v", err)
+	}
+	return nil
+}
@@ -13,6 +13,9 @@ import (
 
 const generateCmdUsage = `usage: %s name [context]
 
+  --hybrid             Wrap the data key with the experimental post-quantum
+                       hybrid scheme (X25519 + ML-KEM-768)
+
   -k, --insecure       Skip X.509 certificate validation during TLS handshake
 
   -h, --help           Show list of command-line options
@@ -24,7 +27,11 @@ func deriveKey(args []string) error {
 		fmt.Fprintf(cli.Output(), generateCmdUsage, cli.Name())
 	}
 
-	var insecureSkipVerify bool
+	var (
+		insecureSkipVerify bool
+		hybrid             bool
+	)
+	cli.BoolVar(&hybrid, "hybrid", false, "Wrap the data key with the post-quantum hybrid scheme")
 	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
 	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
 	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 && len(args) != 2 {
@@ -48,7 +55,11 @@ func deriveKey(args []string) error {
 	if err != nil {
 		return err
 	}
-	key, err := client.GenerateKey(name, context)
+	generateKey := client.GenerateKey
+	if hybrid {
+		generateKey = client.GenerateHybridKey
+	}
+	key, err := generateKey(name, context)
 	if err != nil {
 		return fmt.Errorf("Failed to generate data key: %v", err)
 	}
@@ -0,0 +1,279 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/minio/kes"
+)
+
+const groupCmdUsage = `usage: %s <command>
+
+  set                  Set the policies of a group.
+  show                 Show the policies and members of a group.
+  list                 List groups at the KES server.
+  delete               Delete a group.
+  join                 Add an identity to a group.
+  leave                Remove an identity from a group.
+
+  -h, --help           Show list of command-line options
+`
+
+func group(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), groupCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "set":
+		return setGroup(args)
+	case "show":
+		return showGroup(args)
+	case "list":
+		return listGroups(args)
+	case "delete":
+		return deleteGroup(args)
+	case "join":
+		return joinGroup(args)
+	case "leave":
+		return leaveGroup(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const setGroupCmdUsage = `Sets the policies of a group.
+
+Each member of the group has the permissions of all
+policies of the group. If the group does not exist,
+it gets created without any members.
+
+usage: %s <group> [<policy>...]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func setGroup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), setGroupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err := client.SetGroup(args[0], args[1:]...); err != nil {
+		return fmt.Errorf("Failed to set group '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const showGroupCmdUsage = `usage: %s <group>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func showGroup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), showGroupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	group, err := client.GetGroup(args[0])
+	if err != nil {
+		return fmt.Errorf("Failed to fetch group '%s': %v", args[0], err)
+	}
+	if isTerm(os.Stdout) {
+		fmt.Println("Policies:")
+		for _, policy := range group.Policies {
+			fmt.Printf("  %s\n", policy)
+		}
+		fmt.Println("Members:")
+		for _, member := range group.Members {
+			fmt.Printf("  %s\n", member)
+		}
+	} else {
+		json.NewEncoder(os.Stdout).Encode(group)
+	}
+	return nil
+}
+
+const listGroupsCmdUsage = `usage: %s [<pattern>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func listGroups(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listGroupsCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	var pattern string
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	groups, err := client.ListGroups(pattern)
+	if err != nil {
+		return fmt.Errorf("Failed to list groups: %v", err)
+	}
+	sort.Strings(groups)
+	if isTerm(os.Stdout) {
+		fmt.Println("[")
+		for _, g := range groups {
+			fmt.Printf("  %s\n", g)
+		}
+		fmt.Println("]")
+	} else {
+		json.NewEncoder(os.Stdout).Encode(groups)
+	}
+	return nil
+}
+
+const deleteGroupCmdUsage = `usage: %s <group>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func deleteGroup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), deleteGroupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteGroup(args[0]); err != nil {
+		return fmt.Errorf("Failed to delete group '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const joinGroupCmdUsage = `usage: %s <group> <identity>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func joinGroup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), joinGroupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err := client.AddGroupMember(args[0], kes.Identity(args[1])); err != nil {
+		return fmt.Errorf("Failed to add '%s' to group '%s': %v", args[1], args[0], err)
+	}
+	return nil
+}
+
+const leaveGroupCmdUsage = `usage: %s <group> <identity>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func leaveGroup(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), leaveGroupCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveGroupMember(args[0], kes.Identity(args[1])); err != nil {
+		return fmt.Errorf("Failed to remove '%s' from group '%s': %v", args[1], args[0], err)
+	}
+	return nil
+}
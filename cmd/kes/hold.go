@@ -0,0 +1,93 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const holdCmdUsage = `usage: %s [options] name...
+
+  --reason <text>      The reason for the legal hold - e.g. a case number
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Places a legal hold on the keys with the given names. A key under
+legal hold can neither be deleted nor rotated until the hold gets
+released. Placing and releasing a hold is recorded in the key's
+provenance. For example:
+  $ kes key hold --reason "case 2020-42" my-key
+  $ kes key release my-key
+`
+
+func holdKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), holdCmdUsage, cli.Name())
+	}
+
+	var (
+		reason             string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&reason, "reason", "", "The reason for the legal hold")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if err = client.HoldKey(name, reason); err != nil {
+			return fmt.Errorf("Failed to place legal hold on %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+const releaseCmdUsage = `usage: %s [options] name...
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Releases the legal hold on the keys with the given names.
+`
+
+func releaseKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), releaseCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if err = client.ReleaseKey(name); err != nil {
+			return fmt.Errorf("Failed to release legal hold on %s: %v", name, err)
+		}
+	}
+	return nil
+}
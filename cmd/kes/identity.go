@@ -5,10 +5,16 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/minio/kes"
 )
@@ -18,6 +24,8 @@ const identityCmdUsage = `usage: %s <command>
   assign               Assign an identity to a policy.
   list                 List identities at the KES server.
   forget               Forget an identity.
+  renew                Set the TTL of an identity.
+  usage                Show the requests of identities per API.
 
   -h, --help           Show list of command-line options
 `
@@ -41,6 +49,10 @@ func identity(args []string) error {
 		return listIdentity(args)
 	case "forget":
 		return forgetIdentity(args)
+	case "renew":
+		return renewIdentity(args)
+	case "usage":
+		return identityUsage(args)
 	default:
 		cli.Usage()
 		os.Exit(2)
@@ -168,3 +180,166 @@ func forgetIdentity(args []string) error {
 	}
 	return nil
 }
+
+const renewIdentityCmdUsage = `usage: %s [options] <identity>
+
+  --ttl                Duration until the identity expires. If 0,
+                       the identity never expires. (default: 720h)
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Once an identity has expired, the KES server rejects all its requests
+with "401 identity expired". To rotate a client certificate, assign the
+identity of the new certificate and shorten the TTL of the old identity:
+  $ kes identity assign <new-identity> <policy>
+  $ kes identity renew --ttl=24h <old-identity>
+`
+
+func renewIdentity(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), renewIdentityCmdUsage, cli.Name())
+	}
+
+	var (
+		ttl                time.Duration
+		insecureSkipVerify bool
+	)
+	cli.DurationVar(&ttl, "ttl", 720*time.Hour, "Duration until the identity expires")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	expiry, err := client.RenewIdentity(kes.Identity(args[0]), ttl)
+	if err != nil {
+		return fmt.Errorf("Cannot renew '%s': %v", args[0], err)
+	}
+	if expiry.IsZero() {
+		fmt.Printf("%s never expires\n", args[0])
+	} else {
+		fmt.Printf("%s expires at %s\n", args[0], expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+const identityUsageCmdUsage = `usage: %s [options] [<pattern>]
+
+  --since              Only show the requests of the given period. If 0,
+                       show all requests kept by the server. (default: 24h)
+  --json               Print the requests per time window as JSON
+  --csv                Print the requests per time window as CSV
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Shows the number of requests per API of all identities matching the
+pattern - or of all identities if no pattern is given. The server
+counts the requests in time windows - e.g. per hour - if request
+accounting is enabled. For each identity and API, it shows the total
+number of requests and the max. number of requests within one time
+window. For example:
+  $ kes identity usage --since=168h
+  $ kes identity usage --csv > usage.csv
+
+The CSV columns are:
+  start, identity, api, requests
+where start is the start of the time window.
+`
+
+func identityUsage(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), identityUsageCmdUsage, cli.Name())
+	}
+
+	var (
+		since              time.Duration
+		jsonOutput         bool
+		csvOutput          bool
+		insecureSkipVerify bool
+	)
+	cli.DurationVar(&since, "since", 24*time.Hour, "Only show the requests of the given period")
+	cli.BoolVar(&jsonOutput, "json", false, "Print the requests per time window as JSON")
+	cli.BoolVar(&csvOutput, "csv", false, "Print the requests per time window as CSV")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	if jsonOutput && csvOutput {
+		return errors.New("Cannot print requests as JSON and CSV at the same time")
+	}
+	pattern := "*"
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	records, err := client.IdentityUsage(pattern, since)
+	if err != nil {
+		return fmt.Errorf("Cannot show identity usage: %v", err)
+	}
+
+	switch {
+	case csvOutput:
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"start", "identity", "api", "requests"})
+		for _, r := range records {
+			w.Write([]string{r.Start.UTC().Format(time.RFC3339), r.Identity.String(), r.API, strconv.FormatUint(r.Requests, 10)})
+		}
+		w.Flush()
+		return w.Error()
+	case jsonOutput || !isTerm(os.Stdout):
+		return json.NewEncoder(os.Stdout).Encode(records)
+	}
+
+	type Total struct {
+		Identity kes.Identity
+		API      string
+		Requests uint64
+		Peak     uint64
+	}
+	var (
+		totals  []*Total
+		indices = map[[2]string]*Total{}
+	)
+	for _, r := range records {
+		t, ok := indices[[2]string{r.Identity.String(), r.API}]
+		if !ok {
+			t = &Total{Identity: r.Identity, API: r.API}
+			indices[[2]string{r.Identity.String(), r.API}] = t
+			totals = append(totals, t)
+		}
+		t.Requests += r.Requests
+		if r.Requests > t.Peak {
+			t.Peak = r.Requests
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Identity != totals[j].Identity {
+			return totals[i].Identity < totals[j].Identity
+		}
+		return totals[i].API < totals[j].API
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IDENTITY\tAPI\tREQUESTS\tPEAK/WINDOW")
+	for _, t := range totals {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", t.Identity, t.API, t.Requests, t.Peak)
+	}
+	return w.Flush()
+}
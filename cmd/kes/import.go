@@ -0,0 +1,145 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/minio/kes/internal/fs"
+	"github.com/minio/kes/internal/importer"
+)
+
+const importCmdUsage = `usage: %s [options]
+
+  --config             Path to a config file that specifies the key store
+                       to import from
+  --prefix <prefix>    Only import entries with this prefix - e.g. "my-app/".
+                       The prefix is removed from the key names.
+  --field <name>       The field of a Vault K/V entry that holds the key.
+                       By default, the field with the entry name is used.
+  --dry-run            Validate all entries but don't import any key
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Imports existing keys from the key store specified in the config file
+into a KES server. The config file has the same format as the server
+config file. Supported key stores are a directory of files, a Vault
+K/V path and the AWS SecretsManager.
+
+An entry can be a key as written by a KES server, a PEM block, the raw
+32 key bytes or the base64 or hex encoded key bytes. If the config file
+specifies a KMS, keys encrypted with it - e.g. by another KES server -
+are decrypted. The KES server encrypts the imported keys with its own
+KMS again. Existing keys are not replaced.
+  $ kes import --config=vault-config.yaml --prefix=my-app/
+`
+
+func importCmd(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), importCmdUsage, cli.Name())
+	}
+
+	var (
+		configPath         string
+		prefix             string
+		field              string
+		dryRun             bool
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&configPath, "config", "", "Path to a config file that specifies the key store to import from")
+	cli.StringVar(&prefix, "prefix", "", "Only import entries with this prefix")
+	cli.StringVar(&field, "field", "", "The field of a Vault K/V entry that holds the key")
+	cli.BoolVar(&dryRun, "dry-run", false, "Validate all entries but don't import any key")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 || configPath == "" {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	config, err := loadServerConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("Cannot read config file: %v", err)
+	}
+	config.SetDefaults()
+	if field != "" && config.Keys.Vault.Endpoint == "" {
+		return errors.New("Cannot import keys: --field is only supported for Vault")
+	}
+
+	imp := &importer.Importer{Prefix: prefix}
+	switch {
+	case config.Keys.Fs.Path != "":
+		if stat, err := os.Stat(config.Keys.Fs.Path); err != nil {
+			return fmt.Errorf("Failed to open %s: %v", config.Keys.Fs.Path, err)
+		} else if !stat.IsDir() {
+			return fmt.Errorf("%s is not a directory", config.Keys.Fs.Path)
+		}
+		imp.Source = &fs.Store{Dir: config.Keys.Fs.Path}
+	case config.Keys.Vault.Endpoint != "":
+		vaultStore := newVaultStore(&config)
+		vaultStore.Field = field
+		if err = vaultStore.Authenticate(context.Background()); err != nil {
+			return fmt.Errorf("Failed to connect to Vault: %v", err)
+		}
+		imp.Source = vaultStore
+	case config.Keys.Aws.SecretsManager.Endpoint != "":
+		awsStore := newAWSSecretsManager(&config)
+		if err = awsStore.Authenticate(); err != nil {
+			return fmt.Errorf("Failed to connect to AWS Secrets Manager: %v", err)
+		}
+		imp.Source = awsStore
+	default:
+		return errors.New("Cannot import keys: the config file specifies neither a filesystem, Vault nor AWS SecretsManager key store")
+	}
+	if config.KMS.Aws.Endpoint != "" {
+		kms := newAWSKMS(&config)
+		if err = kms.Authenticate(); err != nil {
+			return fmt.Errorf("Failed to connect to AWS-KMS: %v", err)
+		}
+		imp.KMS = kms
+	}
+
+	var dst importer.Destination // A nil Destination only validates the entries
+	if !dryRun {
+		client, err := newClient(insecureSkipVerify)
+		if err != nil {
+			return err
+		}
+		dst = client
+	}
+	report, err := imp.Import(dst)
+	if !isTerm(os.Stdout) {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			return err
+		}
+	} else {
+		names := make([]string, 0, len(report.Invalid))
+		for name := range report.Invalid {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("Invalid: %s: %s\n", name, report.Invalid[name])
+		}
+		if dryRun {
+			fmt.Printf("Validated %d keys (%d entries are invalid)\n", report.Keys, len(report.Invalid))
+		} else {
+			fmt.Printf("Imported %d keys (%d keys already existed, %d entries are invalid)\n", report.Keys, report.Skipped, len(report.Invalid))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot import keys: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+const jobCmdUsage = `usage: %s <command>
+
+  submit               Submit a new job.
+  status               Show the state and progress of a job.
+  list                 List all jobs.
+  cancel               Cancel a job.
+
+  -h, --help           Show list of command-line options
+
+A job is a long-running operation that the server performs in the
+background. The server resumes unfinished jobs after a restart.
+
+Job types:
+  delete-keys          Delete all keys matching a glob pattern.
+                       Parameters: {"pattern": "<pattern>"}
+  rewrap-keys          Encrypt all keys matching a glob pattern again
+                       with the KMS - e.g. after a KMS key rotation.
+                       Requires a KMS.
+                       Parameters: {"pattern": "<pattern>"}
+  migrate-keys         Copy all keys matching a glob pattern to the
+                       migration key store of the server config.
+                       Parameters: {"pattern": "<pattern>"}
+`
+
+func job(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), jobCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "submit":
+		return submitJob(args)
+	case "status":
+		return jobStatus(args)
+	case "list":
+		return listJobs(args)
+	case "cancel":
+		return cancelJob(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const submitJobCmdUsage = `usage: %s [options] <type> [<params>]
+
+  -w, --wait           Wait until the job has finished
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Submits a new job of the given type. The optional parameters
+are a JSON object. It prints the ID of the new job.
+  $ kes job submit delete-keys '{"pattern": "my-app*"}'
+`
+
+func submitJob(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), submitJobCmdUsage, cli.Name())
+	}
+
+	var (
+		wait               bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&wait, "w", false, "Wait until the job has finished")
+	cli.BoolVar(&wait, "wait", false, "Wait until the job has finished")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 && len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var params interface{}
+	if len(args) == 2 {
+		if !json.Valid([]byte(args[1])) {
+			return fmt.Errorf("Invalid job parameters: '%s' is not valid JSON", args[1])
+		}
+		params = json.RawMessage(args[1])
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	job, err := client.SubmitJob(args[0], params)
+	if err != nil {
+		return fmt.Errorf("Cannot submit job '%s': %v", args[0], err)
+	}
+	if wait {
+		return waitForJob(client, job.ID)
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(job)
+	}
+	fmt.Println(job.ID)
+	return nil
+}
+
+const jobStatusCmdUsage = `usage: %s [options] <id>
+
+  -w, --wait           Wait until the job has finished
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Shows the state and progress of a job.
+  $ kes job status 5a2f8c0b9e6d4a1f3c7b2e8d0f4a6c1e
+`
+
+func jobStatus(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), jobStatusCmdUsage, cli.Name())
+	}
+
+	var (
+		wait               bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&wait, "w", false, "Wait until the job has finished")
+	cli.BoolVar(&wait, "wait", false, "Wait until the job has finished")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if wait {
+		return waitForJob(client, args[0])
+	}
+	job, err := client.JobStatus(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot fetch job '%s': %v", args[0], err)
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(job)
+	}
+	printJob(job)
+	return nil
+}
+
+const listJobsCmdUsage = `usage: %s [options]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func listJobs(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listJobsCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	jobs, err := client.ListJobs()
+	if err != nil {
+		return fmt.Errorf("Cannot list jobs: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(jobs)
+	}
+	for _, job := range jobs {
+		printJob(job)
+	}
+	return nil
+}
+
+const cancelJobCmdUsage = `usage: %s [options] <id>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Cancels a pending or running job. Items that the job has
+processed already are not restored.
+  $ kes job cancel 5a2f8c0b9e6d4a1f3c7b2e8d0f4a6c1e
+`
+
+func cancelJob(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), cancelJobCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err = client.CancelJob(args[0]); err != nil {
+		return fmt.Errorf("Cannot cancel job '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+// waitForJob polls the job state until the job has
+// finished. It returns an error if the job has not
+// completed successfully.
+func waitForJob(client *kes.Client, id string) error {
+	const PollInterval = 1 * time.Second
+	for {
+		job, err := client.JobStatus(id)
+		if err != nil {
+			return fmt.Errorf("Cannot fetch job '%s': %v", id, err)
+		}
+		if job.Finished() {
+			if !isTerm(os.Stdout) {
+				if err = json.NewEncoder(os.Stdout).Encode(job); err != nil {
+					return err
+				}
+			} else {
+				printJob(job)
+			}
+			if job.State != "completed" {
+				return fmt.Errorf("Job '%s' has %s", id, job.State)
+			}
+			return nil
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+func printJob(job kes.JobInfo) {
+	progress := fmt.Sprintf("%d", job.Done)
+	if job.Total > 0 {
+		progress = fmt.Sprintf("%d/%d", job.Done, job.Total)
+	}
+	fmt.Printf("%s  %-12s %-10s %-12s %s\n", job.ID, job.Type, job.State, progress, job.Error)
+}
@@ -14,9 +14,19 @@ const keyCmdUsage = `usage: %s <command>
 
     create               Create a new secret key at a kes server.
     delete               Delete a secret key from a kes server.
+    disable              Disable a secret key until it gets enabled again.
+    enable               Enable a disabled secret key.
+    hold                 Place a legal hold on a secret key.
+    release              Release the legal hold on a secret key.
+    list                 List all keys with their metadata.
+    watch                Print an event whenever a key changes.
+    describe             Show the metadata and usage of a key.
+    provenance           Show how a key has been created and by whom.
+    alias                Manage aliases that refer to keys.
 
     derive               Derive a new key from a secret key.     
     decrypt              Decrypt an encrypted key with a secret key. 
+    reencrypt            Re-encrypt an encrypted key with another secret key.
 
   -h, --help             Show this list of command line optios.
 `
@@ -38,10 +48,30 @@ func key(args []string) error {
 		return createKey(args)
 	case "delete":
 		return deleteKey(args)
+	case "disable":
+		return disableKey(args)
+	case "enable":
+		return enableKey(args)
+	case "hold":
+		return holdKey(args)
+	case "release":
+		return releaseKey(args)
+	case "list":
+		return listKeys(args)
+	case "watch":
+		return watchKeys(args)
+	case "describe":
+		return describeKey(args)
+	case "provenance":
+		return keyProvenance(args)
+	case "alias":
+		return alias(args)
 	case "derive":
 		return deriveKey(args)
 	case "decrypt":
 		return decryptKey(args)
+	case "reencrypt":
+		return reencryptKey(args)
 	default:
 		cli.Usage()
 		os.Exit(2)
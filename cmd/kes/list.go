@@ -0,0 +1,394 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+const listKeyCmdUsage = `usage: %s [options] [<pattern>]
+
+  --json               Print the keys as JSON
+  --csv                Print the keys as CSV
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Lists all keys matching the pattern - or all keys if no pattern
+is given. For each key, it shows when the key has been created,
+when the key has been used the last time, whether the key is sealed
+by the server's KMS and which policies allow which key operations.
+Key names can be hierarchical - e.g. "my-app/my-key". A '*' only
+matches within one segment of a name while a '**' segment matches
+any number of segments. For example:
+  $ kes key list 'my-app*'
+  $ kes key list 'my-app/**'
+  $ kes key list --csv > inventory.csv
+
+The CSV columns are:
+  name, created_at, age_days, last_used, sealed, policies
+where policies is a ';'-separated list of <policy>:<op>+<op>...
+`
+
+func listKeys(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listKeyCmdUsage, cli.Name())
+	}
+
+	var (
+		jsonOutput         bool
+		csvOutput          bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&jsonOutput, "json", false, "Print the keys as JSON")
+	cli.BoolVar(&csvOutput, "csv", false, "Print the keys as CSV")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	if jsonOutput && csvOutput {
+		return errors.New("Cannot print keys as JSON and CSV at the same time")
+	}
+
+	var pattern string
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if csvOutput {
+		// A key inventory may contain many keys. Hence, we
+		// stream the keys instead of fetching all at once.
+		stream, err := client.ListKeysStream(pattern)
+		if err != nil {
+			return fmt.Errorf("Cannot list keys: %v", err)
+		}
+		defer stream.Close()
+
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"name", "created_at", "age_days", "last_used", "sealed", "policies"})
+		for stream.Next() {
+			key := stream.Key()
+			var createdAt, age, lastUsed string
+			if !key.CreatedAt.IsZero() {
+				createdAt = key.CreatedAt.UTC().Format(time.RFC3339)
+				age = strconv.Itoa(int(now.Sub(key.CreatedAt).Hours() / 24))
+			}
+			if !key.LastUsed.IsZero() {
+				lastUsed = key.LastUsed.UTC().Format(time.RFC3339)
+			}
+			w.Write([]string{key.Name, createdAt, age, lastUsed, strconv.FormatBool(key.Sealed), formatKeyPolicies(key, "+", ";")})
+		}
+		w.Flush()
+		if err = stream.Err(); err != nil {
+			return fmt.Errorf("Cannot list keys: %v", err)
+		}
+		return w.Error()
+	}
+
+	keys, err := client.ListKeys(pattern)
+	if err != nil {
+		return fmt.Errorf("Cannot list keys: %v", err)
+	}
+	if jsonOutput || !isTerm(os.Stdout) {
+		keysJSON := make([]keyJSON, 0, len(keys))
+		for _, key := range keys {
+			keysJSON = append(keysJSON, newKeyJSON(key))
+		}
+		return json.NewEncoder(os.Stdout).Encode(keysJSON)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tAGE\tLAST USED\tSEALED\tPOLICIES")
+	for _, key := range keys {
+		age, lastUsed := "-", "-"
+		if !key.CreatedAt.IsZero() {
+			age = formatAge(now.Sub(key.CreatedAt))
+		}
+		if !key.LastUsed.IsZero() {
+			lastUsed = formatAge(now.Sub(key.LastUsed)) + " ago"
+		}
+		sealed := "no"
+		if key.Sealed {
+			sealed = "yes"
+		}
+		policies := formatKeyPolicies(key, ",", " ")
+		if policies == "" {
+			policies = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.Name, age, lastUsed, sealed, policies)
+	}
+	return w.Flush()
+}
+
+const describeKeyCmdUsage = `usage: %s [options] <name>
+
+  --json               Print the key description as JSON
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Shows when the key has been created, when the key has been used the
+last time, how often it has been used for which operation, whether the
+key is sealed by the server's KMS and which policies allow which key
+operations. For example:
+  $ kes key describe my-key
+`
+
+func describeKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), describeKeyCmdUsage, cli.Name())
+	}
+
+	var (
+		jsonOutput         bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&jsonOutput, "json", false, "Print the key description as JSON")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	key, err := client.DescribeKey(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot describe key '%s': %v", args[0], err)
+	}
+	if jsonOutput || !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(newKeyJSON(*key))
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name\t%s\n", key.Name)
+	if key.Type != "" {
+		fmt.Fprintf(w, "Type\t%s\n", key.Type)
+	}
+	if key.Version != "" {
+		fmt.Fprintf(w, "Version\t%s\n", key.Version)
+	}
+	if key.CreatedAt.IsZero() {
+		fmt.Fprintln(w, "Created\t-")
+	} else {
+		fmt.Fprintf(w, "Created\t%s (%s ago)\n", key.CreatedAt.Local().Format(time.RFC3339), formatAge(now.Sub(key.CreatedAt)))
+	}
+	if key.LastUsed.IsZero() {
+		fmt.Fprintln(w, "Last used\tnever")
+	} else {
+		fmt.Fprintf(w, "Last used\t%s (%s ago)\n", key.LastUsed.Local().Format(time.RFC3339), formatAge(now.Sub(key.LastUsed)))
+	}
+	if key.Sealed {
+		fmt.Fprintln(w, "Sealed\tyes")
+	} else {
+		fmt.Fprintln(w, "Sealed\tno")
+	}
+	if len(key.AllowedOps) == 0 {
+		fmt.Fprintln(w, "Allowed ops\tany")
+	} else {
+		fmt.Fprintf(w, "Allowed ops\t%s\n", strings.Join(key.AllowedOps, ", "))
+	}
+	if key.Immutable {
+		fmt.Fprintln(w, "Immutable\tyes")
+	}
+	if key.Disabled {
+		fmt.Fprintln(w, "Disabled\tyes")
+	}
+	if hold := key.LegalHold; hold != nil {
+		fmt.Fprintf(w, "Legal hold\tsince %s by %s\n", hold.Time.Local().Format(time.RFC3339), hold.Identity)
+		if hold.Reason != "" {
+			fmt.Fprintf(w, "Hold reason\t%s\n", hold.Reason)
+		}
+	}
+	if pending := key.PendingDeletion; pending != nil {
+		fmt.Fprintf(w, "Deletion\tscheduled for %s by %s\n", pending.DeleteAt.Local().Format(time.RFC3339), pending.Identity)
+	}
+
+	ops := make([]string, 0, len(key.Usage))
+	for op := range key.Usage {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	if len(ops) == 0 {
+		fmt.Fprintln(w, "Usage\t-")
+	}
+	for i, op := range ops {
+		label := ""
+		if i == 0 {
+			label = "Usage"
+		}
+		fmt.Fprintf(w, "%s\t%s: %d\n", label, op, key.Usage[op])
+	}
+
+	policies := formatKeyPolicies(*key, ",", " ")
+	if policies == "" {
+		policies = "-"
+	}
+	fmt.Fprintf(w, "Policies\t%s\n", policies)
+	return w.Flush()
+}
+
+const keyProvenanceCmdUsage = `usage: %s [options] <name>
+
+  --json               Print the provenance as JSON
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Shows how the key came into existence - e.g. generated by the server,
+imported or restored from a backup - and by which identity. The
+provenance of a deleted key remains available. For example:
+  $ kes key provenance my-key
+`
+
+func keyProvenance(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), keyProvenanceCmdUsage, cli.Name())
+	}
+
+	var (
+		jsonOutput         bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&jsonOutput, "json", false, "Print the provenance as JSON")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	events, err := client.KeyProvenance(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot fetch provenance of key '%s': %v", args[0], err)
+	}
+	if jsonOutput || !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(events)
+	}
+	if len(events) == 0 {
+		fmt.Println("No provenance recorded - the key has been created before the server recorded provenance")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tIDENTITY\tORIGIN")
+	for _, event := range events {
+		identity, origin := event.Identity.String(), event.Origin
+		if identity == "" {
+			identity = "-"
+		}
+		if origin == "" {
+			origin = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", event.Time.Local().Format(time.RFC3339), event.Type, identity, origin)
+	}
+	return w.Flush()
+}
+
+// keyJSON is the JSON representation of a kes.KeyInfo
+// that omits unknown timestamps instead of printing
+// the zero time.
+type keyJSON struct {
+	Name            string               `json:"name"`
+	Type            string               `json:"type,omitempty"`
+	Version         string               `json:"version,omitempty"`
+	CreatedAt       *time.Time           `json:"created_at,omitempty"`
+	LastUsed        *time.Time           `json:"last_used,omitempty"`
+	Sealed          bool                 `json:"sealed"`
+	AllowedOps      []string             `json:"allowed_ops,omitempty"`
+	Immutable       bool                 `json:"immutable,omitempty"`
+	Disabled        bool                 `json:"disabled,omitempty"`
+	LegalHold       *kes.LegalHold       `json:"legal_hold,omitempty"`
+	PendingDeletion *kes.PendingDeletion `json:"pending_deletion,omitempty"`
+	Usage           map[string]uint64    `json:"usage,omitempty"`
+	Policies        map[string][]string  `json:"policies,omitempty"`
+}
+
+func newKeyJSON(key kes.KeyInfo) keyJSON {
+	k := keyJSON{
+		Name:            key.Name,
+		Type:            key.Type,
+		Version:         key.Version,
+		Sealed:          key.Sealed,
+		AllowedOps:      key.AllowedOps,
+		Immutable:       key.Immutable,
+		Disabled:        key.Disabled,
+		LegalHold:       key.LegalHold,
+		PendingDeletion: key.PendingDeletion,
+		Usage:           key.Usage,
+		Policies:        key.Policies,
+	}
+	if !key.CreatedAt.IsZero() {
+		k.CreatedAt = &key.CreatedAt
+	}
+	if !key.LastUsed.IsZero() {
+		k.LastUsed = &key.LastUsed
+	}
+	return k
+}
+
+// formatKeyPolicies returns the policies of the key sorted
+// by name - e.g. "my-app:generate,decrypt ops:delete".
+func formatKeyPolicies(key kes.KeyInfo, opSep, policySep string) string {
+	names := make([]string, 0, len(key.Policies))
+	for name := range key.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	policies := make([]string, 0, len(names))
+	for _, name := range names {
+		policies = append(policies, name+":"+strings.Join(key.Policies[name], opSep))
+	}
+	return strings.Join(policies, policySep)
+}
+
+// formatAge formats the duration in the largest
+// unit - i.e. days, hours, minutes or seconds.
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
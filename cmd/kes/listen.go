@@ -0,0 +1,93 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenUnix listens on the Unix domain socket at the given
+// path and restricts access to the socket to the file mode.
+//
+// It removes a stale socket file - e.g. left behind by a
+// server that has not been shut down gracefully - before
+// creating the socket.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if stat, err := os.Lstat(path); err == nil && stat.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// listenReusePort opens n listeners at the TCP address with
+// SO_REUSEPORT set such that the OS distributes incoming
+// connections across them. Each listener should be served
+// by its own accept loop.
+func listenReusePort(addr string, n int) ([]net.Listener, error) {
+	config := net.ListenConfig{Control: reusePort}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		listener, err := config.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// systemdListeners returns the listening sockets passed
+// to the server by systemd via socket activation. It
+// returns no listeners if the server has not been
+// started via socket activation. See: sd_listen_fds(3)
+func systemdListeners() ([]net.Listener, error) {
+	const ListenFDsStart = 3 // SD_LISTEN_FDS_START
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil // The sockets, if any, are meant for another process
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid number of sockets '%s'", os.Getenv("LISTEN_FDS"))
+	}
+
+	// Child processes must not inherit the sockets.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := ListenFDsStart; fd < ListenFDsStart+n; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("file descriptor %d is not a listening socket: %v", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
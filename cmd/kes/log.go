@@ -5,6 +5,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/minio/kes"
 )
 
 const logCmdUsage = `usage: %s <command>
@@ -48,10 +50,17 @@ func log(args []string) error {
 
 const logTraceCmdUsage = `Trace server log events.
 
-Connects to a KES server and traces log events.
+Connects to a KES server and traces audit log events.
+Audit log events can be filtered by identity, API and
+response status.
 
 usage: %s [flags]
 
+  --identity <id>      Only trace requests sent by this identity.
+  --api <api>          Only trace requests to this API - e.g. /v1/key/create.
+  --status <status>    Only trace responses with this status code or
+                       status class - e.g. 404 or 4xx.
+  --error              Trace error log events instead of audit log events.
   --json               Print log events as JSON.
 
   -k, --insecure       Skip X.509 certificate validation during TLS handshake.
@@ -66,7 +75,13 @@ func logTrace(args []string) error {
 	}
 
 	var jsonOutput bool
+	var errorLog bool
+	var filter kes.AuditFilter
 	var insecureSkipVerify bool
+	cli.StringVar((*string)(&filter.Identity), "identity", "", "Only trace requests sent by this identity")
+	cli.StringVar(&filter.API, "api", "", "Only trace requests to this API")
+	cli.StringVar(&filter.Status, "status", "", "Only trace responses with this status code or status class")
+	cli.BoolVar(&errorLog, "error", false, "Trace error log events instead of audit log events")
 	cli.BoolVar(&jsonOutput, "json", false, "Print log events as JSON")
 	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
 	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
@@ -79,7 +94,13 @@ func logTrace(args []string) error {
 	if err != nil {
 		return err
 	}
-	stream, err := client.TraceAuditLog()
+	if errorLog {
+		if filter != (kes.AuditFilter{}) {
+			return errors.New("Error log events cannot be filtered by identity, API or status")
+		}
+		return traceErrorLog(cli, client, jsonOutput)
+	}
+	stream, err := client.TraceAuditLogWithFilter(filter)
 	if err != nil {
 		return err
 	}
@@ -136,3 +157,30 @@ func logTrace(args []string) error {
 	}
 	return stream.Err()
 }
+
+func traceErrorLog(cli *flag.FlagSet, client *kes.Client, jsonOutput bool) error {
+	stream, err := client.TraceErrorLog()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		if err := stream.Close(); err != nil {
+			fmt.Fprintln(cli.Output(), err)
+		}
+	}()
+
+	isTerminal := isTerm(os.Stdout)
+	for stream.Next() {
+		if !isTerminal || jsonOutput {
+			fmt.Println(string(stream.Bytes()))
+			continue
+		}
+		fmt.Print(stream.Event().Message)
+	}
+	return stream.Err()
+}
@@ -5,10 +5,12 @@
 package main
 
 import (
+	"crypto"
 	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
@@ -25,9 +27,27 @@ const usage = `usage: %s <command>
     server               Start a kes server.
 
     key                  Manage secret keys.
+    secret               Manage opaque secrets - e.g. API tokens.
+    ca                   Manage certificate authorities.
+    jws                  Sign JWS and JWT with asymmetric keys.
     log                  Work with server logs.
     policy               Manage the kes server policies.
     identity             Assign policies to identities.
+    group                Manage groups of identities.
+    session              Issue a short-lived session token.
+    quota                Manage key quotas of identities and tenants.
+    backup               Create and restore backups of the server state.
+    import               Import existing keys from Vault, AWS or files.
+    escrow               Export keys to offline escrow custodians.
+    enclave              Manage isolated enclaves.
+    approve              Approve a destructive request of the root identity.
+    job                  Manage long-running server jobs.
+    seal                 Initialize and unseal a sealed server.
+    attest               Verify a server running inside an enclave.
+    encrypt              Encrypt data with a key.
+    decrypt              Decrypt data encrypted by "encrypt".
+    shell                Start an interactive shell.
+    bench                Benchmark a kes server.
 
     tool                 Run specific key and identity management tools.
 
@@ -63,12 +83,48 @@ func main() {
 		err = server(args)
 	case "key":
 		err = key(args)
+	case "secret":
+		err = secretCmd(args)
+	case "ca":
+		err = caCmd(args)
+	case "jws":
+		err = jwsCmd(args)
 	case "log":
 		err = log(args)
 	case "identity":
 		err = identity(args)
 	case "policy":
 		err = policy(args)
+	case "group":
+		err = group(args)
+	case "session":
+		err = session(args)
+	case "quota":
+		err = quota(args)
+	case "backup":
+		err = backup(args)
+	case "import":
+		err = importCmd(args)
+	case "escrow":
+		err = escrowCmd(args)
+	case "enclave":
+		err = enclave(args)
+	case "approve":
+		err = approve(args)
+	case "job":
+		err = job(args)
+	case "seal":
+		err = seal(args)
+	case "attest":
+		err = attestCmd(args)
+	case "encrypt":
+		err = encryptData(args)
+	case "decrypt":
+		err = decryptData(args)
+	case "shell":
+		err = shell(args)
+	case "bench":
+		err = bench(args)
 	case "tool":
 		err = tool(args)
 	default:
@@ -103,9 +159,66 @@ func isFlagPresent(set *flag.FlagSet, name string) bool {
 	return found
 }
 
+// newClient returns a new KES client. If the env.
+// variable KES_ENCLAVE is set, the client sends all
+// requests to the referenced enclave. If the env.
+// variable KES_APPROVAL is set, the client sends the
+// comma-separated approval tokens with each request.
 func newClient(insecureSkipVerify bool) (*kes.Client, error) {
+	client, err := newServerClient(insecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints := strings.Split(client.Endpoint, ","); len(endpoints) > 1 {
+		// KES_SERVER may contain a comma-separated list of
+		// endpoints. The client spreads requests across them.
+		for i := range endpoints {
+			endpoints[i] = strings.TrimSpace(endpoints[i])
+		}
+		client.Endpoint, client.Endpoints = endpoints[0], endpoints
+	}
+	if name := os.Getenv("KES_ENCLAVE"); name != "" {
+		client = client.WithEnclave(name)
+	}
+	if tokens := os.Getenv("KES_APPROVAL"); tokens != "" {
+		client = client.WithApprovals(strings.Split(tokens, ",")...)
+	}
+	return client, nil
+}
+
+func newServerClient(insecureSkipVerify bool) (*kes.Client, error) {
+	addr := "https://127.0.0.1:7373"
+	if env, ok := os.LookupEnv("KES_SERVER"); ok {
+		addr = env
+	}
+
 	certPath := os.Getenv("KES_CLIENT_CERT")
 	keyPath := os.Getenv("KES_CLIENT_KEY")
+	if token := os.Getenv("KES_TOKEN"); certPath == "" && token != "" {
+		// Authenticate with an OIDC bearer token instead
+		// of using a client certificate.
+		client := kes.NewClientWithConfig(addr, &tls.Config{
+			InsecureSkipVerify: insecureSkipVerify,
+		})
+		client.HTTPClient.Transport = &bearerTokenTransport{
+			RoundTripper: client.HTTPClient.Transport,
+			Token:        token,
+		}
+		return client, nil
+	}
+	if username := os.Getenv("KES_USERNAME"); certPath == "" && username != "" {
+		// Authenticate as LDAP user instead of using
+		// a client certificate.
+		client := kes.NewClientWithConfig(addr, &tls.Config{
+			InsecureSkipVerify: insecureSkipVerify,
+		})
+		client.HTTPClient.Transport = &basicAuthTransport{
+			RoundTripper: client.HTTPClient.Transport,
+			Username:     username,
+			Password:     os.Getenv("KES_PASSWORD"),
+		}
+		return client, nil
+	}
 	if certPath == "" {
 		return nil, errors.New("No client TLS certificate: env KES_CLIENT_CERT is not set or empty")
 	}
@@ -117,14 +230,50 @@ func newClient(insecureSkipVerify bool) (*kes.Client, error) {
 		return nil, fmt.Errorf("Failed to load TLS key or cert for client: %v", err)
 	}
 
-	addr := "https://127.0.0.1:7373"
-	if env, ok := os.LookupEnv("KES_SERVER"); ok {
-		addr = env
-	}
-	return kes.NewClientWithConfig(addr, &tls.Config{
+	client := kes.NewClientWithConfig(addr, &tls.Config{
 		Certificates:       []tls.Certificate{cert},
 		InsecureSkipVerify: insecureSkipVerify,
-	}), nil
+	})
+
+	// Sign all requests such that the client also works
+	// with servers that require request signatures.
+	if signer, ok := cert.PrivateKey.(crypto.Signer); ok {
+		client.HTTPClient.Transport = &kes.SigningTransport{
+			RoundTripper: client.HTTPClient.Transport,
+			Signer:       signer,
+		}
+	}
+	return client, nil
+}
+
+// basicAuthTransport is an http.RoundTripper that adds
+// the username and password as HTTP basic authentication
+// to each request.
+type basicAuthTransport struct {
+	http.RoundTripper
+
+	Username string
+	Password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.Username, t.Password)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// bearerTokenTransport is an http.RoundTripper that adds
+// the token as bearer token to each request.
+type bearerTokenTransport struct {
+	http.RoundTripper
+
+	Token string
+}
+
+func (t *bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	return t.RoundTripper.RoundTrip(req)
 }
 
 func isTerm(f *os.File) bool { return terminal.IsTerminal(int(f.Fd())) }
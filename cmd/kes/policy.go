@@ -22,7 +22,9 @@ usage: %s <command>
   add                  Add a new named policy.
   show                 Download and print a named policy.
   list                 List named policies.
+  watch                Print an event whenever a policy changes.
   delete               Delete a named policy.
+  test                 Test whether an identity is allowed to send a request.
 
   -h, --help           Show list of command-line options
 `
@@ -45,8 +47,12 @@ func policy(args []string) error {
 		return showPolicy(args)
 	case "list":
 		return listPolicies(args)
+	case "watch":
+		return watchPolicies(args)
 	case "delete":
 		return deletePolicy(args)
+	case "test":
+		return testPolicy(args)
 	default:
 		cli.Usage()
 		os.Exit(2)
@@ -59,8 +65,13 @@ const addPolicyCmdUsage = `Adds a named policy to the policy set of the KES serv
 It reads a JSON encoded policy from the specified file and
 adds it to the policy set of the KES server.
 
+With --if-match, an existing policy is only replaced if it has
+not been modified since it has been shown. See: kes policy show
+
 usage: %s <policy> <file>
   
+  --if-match=<version> Replace the policy only if its version matches
+
   -k, --insecure       Skip X.509 certificate validation during TLS handshake
 
   -h, --help           Show list of command-line options
@@ -72,7 +83,11 @@ func addPolicy(args []string) error {
 		fmt.Fprintf(cli.Output(), addPolicyCmdUsage, cli.Name())
 	}
 
-	var insecureSkipVerify bool
+	var (
+		version            string
+		insecureSkipVerify bool
+	)
+	cli.StringVar(&version, "if-match", "", "Replace the policy only if its version matches")
 	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
 	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
 	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
@@ -93,7 +108,12 @@ func addPolicy(args []string) error {
 	if err = policy.UnmarshalJSON(data); err != nil {
 		return fmt.Errorf("Policy file is invalid JSON: %v", err)
 	}
-	if err = client.SetPolicy(args[0], &policy); err != nil {
+	if version != "" {
+		err = client.SetPolicyIfMatch(args[0], &policy, version)
+	} else {
+		err = client.SetPolicy(args[0], &policy)
+	}
+	if err != nil {
 		return fmt.Errorf("Failed to add policy '%s': %v", args[0], err)
 	}
 	return nil
@@ -140,12 +160,15 @@ func showPolicy(args []string) error {
 	if err != nil {
 		return err
 	}
-	policy, err := client.GetPolicy(name)
+	policy, version, err := client.GetPolicyVersion(name)
 	if err != nil {
 		return fmt.Errorf("Failed to fetch policy '%s': %v", args[0], err)
 	}
 	if isTerm(os.Stdout) {
 		fmt.Println(policy.String())
+		if version != "" {
+			fmt.Printf("Version: %s\n", version)
+		}
 	} else {
 		output, _ := policy.MarshalJSON()
 		os.Stdout.Write(output)
@@ -240,3 +263,71 @@ func deletePolicy(args []string) error {
 	}
 	return nil
 }
+
+const testPolicyCmdUsage = `Tests whether an identity is allowed to send a request.
+
+It evaluates a hypothetical request of the identity to the API
+for the key against the current policies - without sending the
+request itself - and prints whether the request would be allowed
+and which policy rule decides it. Policy conditions, like IP
+ranges, are not evaluated since they depend on the request.
+
+usage: %s <identity> <api> [<key>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Example:
+  $ kes policy test 3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22 /v1/key/decrypt my-key
+`
+
+func testPolicy(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), testPolicyCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 && len(args) != 3 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	var key string
+	if len(args) == 3 {
+		key = args[2]
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	decision, err := client.TestPolicy(kes.Identity(args[0]), args[1], key)
+	if err != nil {
+		return fmt.Errorf("Failed to test policy: %v", err)
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(decision)
+	}
+
+	result := "denied"
+	if decision.Allowed {
+		result = "allowed"
+	}
+	fmt.Printf("Request:  %s\n", decision.Path)
+	fmt.Printf("Result:   %s\n", result)
+	if decision.Policy != "" {
+		fmt.Printf("Policy:   %s\n", decision.Policy)
+	}
+	if decision.Rule != "" {
+		fmt.Printf("Rule:     %s\n", decision.Rule)
+	}
+	fmt.Printf("Reason:   %s\n", decision.Reason)
+	if decision.Conditional {
+		fmt.Println("Note:     the policy has conditions that have not been evaluated")
+	}
+	return nil
+}
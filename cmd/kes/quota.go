@@ -0,0 +1,171 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/minio/kes"
+)
+
+const quotaCmdUsage = `usage: %s <command>
+
+  list                 List the key quotas of identities and tenants.
+  set                  Set the key quota of an identity or tenant.
+
+  -h, --help           Show list of command-line options
+`
+
+func quota(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), quotaCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "list":
+		return listQuota(args)
+	case "set":
+		return setQuota(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const listQuotaCmdUsage = `usage: %s
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func listQuota(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), listQuotaCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	identities, tenants, err := client.ListQuotas()
+	if err != nil {
+		return fmt.Errorf("Cannot list quotas: %v", err)
+	}
+
+	if !isTerm(os.Stdout) {
+		type Response struct {
+			Identities map[kes.Identity]kes.Quota `json:"identities"`
+			Tenants    map[string]kes.Quota       `json:"tenants"`
+		}
+		return json.NewEncoder(os.Stdout).Encode(Response{
+			Identities: identities,
+			Tenants:    tenants,
+		})
+	}
+
+	formatLimit := func(limit int) string {
+		if limit <= 0 {
+			return "unlimited"
+		}
+		return strconv.Itoa(limit)
+	}
+	ids := make([]string, 0, len(identities))
+	for id := range identities {
+		ids = append(ids, id.String())
+	}
+	sort.Strings(ids)
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("{")
+	for _, id := range ids {
+		quota := identities[kes.Identity(id)]
+		fmt.Printf("  identity %s => %d / %s\n", id, quota.Keys, formatLimit(quota.Limit))
+	}
+	for _, name := range names {
+		quota := tenants[name]
+		fmt.Printf("  tenant %s => %d / %s\n", name, quota.Keys, formatLimit(quota.Limit))
+	}
+	fmt.Println("}")
+	return nil
+}
+
+const setQuotaCmdUsage = `usage: %s [options] <identity|tenant> <limit>
+
+  --tenant             Set the quota of a tenant instead of an identity
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+A limit of 0 means that there is no limit. Once an identity or tenant
+has reached its quota, the KES server rejects requests to create keys
+with "403 key quota exceeded". For example:
+  $ kes quota set <identity> 1000
+  $ kes quota set --tenant <tenant> 5000
+`
+
+func setQuota(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), setQuotaCmdUsage, cli.Name())
+	}
+
+	var (
+		tenant             bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&tenant, "tenant", false, "Set the quota of a tenant instead of an identity")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	limit, err := strconv.Atoi(args[1])
+	if err != nil || limit < 0 {
+		return fmt.Errorf("Invalid limit '%s': must be a non-negative number", args[1])
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if tenant {
+		err = client.SetTenantQuota(args[0], limit)
+	} else {
+		err = client.SetIdentityQuota(kes.Identity(args[0]), limit)
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot set quota of '%s': %v", args[0], err)
+	}
+	return nil
+}
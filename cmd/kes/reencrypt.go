@@ -0,0 +1,67 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const reencryptCmdUsage = `usage: %s <name> <new-name> <ciphertext> [<context>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Decrypts the encrypted key with the secret key <name> and encrypts
+it with the secret key <new-name> - without revealing the plaintext
+key. The new ciphertext is bound to the same context. For example:
+  $ kes key reencrypt old-key new-key <ciphertext>
+`
+
+func reencryptKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), reencryptCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 3 && len(args) != 4 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(args[2])
+	if err != nil {
+		return fmt.Errorf("Invalid ciphertext: %v", err)
+	}
+	var context []byte
+	if len(args) == 4 {
+		context, err = base64.StdEncoding.DecodeString(args[3])
+		if err != nil {
+			return fmt.Errorf("Invalid context: %v", err)
+		}
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	ciphertext, err = client.ReEncrypt(args[0], args[1], ciphertext, context)
+	if err != nil {
+		return fmt.Errorf("Failed to re-encrypt data key: %v", err)
+	}
+
+	if isTerm(os.Stdout) {
+		fmt.Printf("\n  ciphertext: %s\n", base64.StdEncoding.EncodeToString(ciphertext))
+	} else {
+		fmt.Printf(`{"ciphertext":"%s"}`, base64.StdEncoding.EncodeToString(ciphertext))
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	xhttp "github.com/minio/kes/internal/http"
+	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/secret"
+)
+
+// configReloader applies changes of the server config
+// file to a running server - without a restart.
+//
+// It applies changes to the policies and identities, the
+// audit log sinks, the cache expiry and the rate limits.
+// Changes to other sections, like the key store, require
+// a restart.
+type configReloader struct {
+	// Path is the path of the config file.
+	Path string
+
+	// Root is the root identity. It cannot
+	// be changed by a reload.
+	Root kes.Identity
+
+	Roles    *auth.Roles
+	Proxy    *auth.TLSProxy
+	Store    *secret.Store
+	Limiter  *xhttp.RateLimiter
+	AuditLog *xlog.SystemLog
+
+	// Concurrency limits the in-flight
+	// requests of each identity.
+	Concurrency *xhttp.ConcurrencyLimiter
+
+	// EnclaveKey is the shared enclave key, if any.
+	// It is used to derive the audit log signing key.
+	EnclaveKey *secret.EnclaveKey
+
+	// ErrorLog is the logger for reload errors
+	// and changes that require a restart.
+	ErrorLog *stdlog.Logger
+
+	lock       sync.Mutex
+	config     serverConfig // The currently applied config
+	auditSinks []io.Writer  // The currently used audit log sinks
+	modTime    time.Time
+}
+
+// newConfigReloader returns a new configReloader for the
+// config file at path. The config and audit log sinks
+// must be the ones the server has been started with.
+func newConfigReloader(path string, config serverConfig, auditSinks []io.Writer) (*configReloader, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &configReloader{
+		Path:       path,
+		config:     config,
+		auditSinks: auditSinks,
+		modTime:    stat.ModTime(),
+	}, nil
+}
+
+// ReloadAfter checks periodically, every interval, whether
+// the config file has been modified and reloads it if so.
+// It stops once the ctx.Done() channel returns.
+//
+// ReloadAfter does not block but starts a new go-routine.
+func (r *configReloader) ReloadAfter(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			stat, err := os.Stat(r.Path)
+			if err != nil {
+				r.ErrorLog.Printf("Failed to check config file '%s': %v", r.Path, err)
+				continue
+			}
+			r.lock.Lock()
+			modified := stat.ModTime().After(r.modTime)
+			r.lock.Unlock()
+			if modified {
+				if err = r.Reload(); err != nil {
+					r.ErrorLog.Printf("Failed to reload config file '%s': %v", r.Path, err)
+				}
+			}
+		}
+	}()
+}
+
+// Reload reads the config file and applies all changes
+// section by section. If a section is invalid, Reload
+// does not apply any change of this section and returns
+// an error.
+func (r *configReloader) Reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stat, err := os.Stat(r.Path)
+	if err != nil {
+		return err
+	}
+	config, err := loadServerConfig(r.Path)
+	if err != nil {
+		return err
+	}
+	config.SetDefaults()
+	r.modTime = stat.ModTime() // Don't retry an invalid config file until it changes again
+
+	if err = r.applyPolicies(&config); err != nil {
+		return err
+	}
+	r.config.Policies = config.Policies
+
+	if !reflect.DeepEqual(config.Log.Sinks, r.config.Log.Sinks) || config.Log.Integrity != r.config.Log.Integrity {
+		if err = r.applyAuditSinks(&config); err != nil {
+			return err
+		}
+	}
+	r.config.Log = config.Log
+
+	if !reflect.DeepEqual(config.Limit, r.config.Limit) {
+		limit, identities, apis, err := rateLimits(&config)
+		if err != nil {
+			return err
+		}
+		r.Limiter.SetLimits(limit, identities, apis)
+		r.Concurrency.SetLimits(config.Limit.Concurrency.Requests, config.Limit.Concurrency.Identities)
+	}
+	r.config.Limit = config.Limit
+
+	if config.Cache != r.config.Cache {
+		r.Store.StartGC(context.Background(), config.Cache.Expiry.Any, config.Cache.Expiry.Unused)
+	}
+
+	for name, changed := range map[string]bool{
+		"addr":     config.Addr != r.config.Addr,
+		"probe":    config.Probe != r.config.Probe,
+		"kmip":     config.KMIP != r.config.KMIP,
+		"shutdown": config.Shutdown != r.config.Shutdown,
+		"cluster":  !reflect.DeepEqual(config.Cluster, r.config.Cluster),
+		"enclave":  config.Enclave != r.config.Enclave,
+		"root":     config.Root != r.config.Root,
+		"tls":      !reflect.DeepEqual(config.TLS, r.config.TLS),
+		"ldap":     !reflect.DeepEqual(config.LDAP, r.config.LDAP),
+		"oidc":     !reflect.DeepEqual(config.OIDC, r.config.OIDC),
+		"tenant":   !reflect.DeepEqual(config.Tenants, r.config.Tenants),
+		"quota":    !reflect.DeepEqual(config.Quota, r.config.Quota),
+		"keys":     !reflect.DeepEqual(config.Keys, r.config.Keys),
+		"kms":      !reflect.DeepEqual(config.KMS, r.config.KMS),
+	} {
+		if changed {
+			r.ErrorLog.Printf("Config file '%s': changes to the '%s' section require a restart", r.Path, name)
+		}
+	}
+	r.config = config
+	r.ErrorLog.Printf("Reloaded config file '%s'", r.Path)
+	return nil
+}
+
+// applyPolicies replaces the policies and identities of the
+// currently applied config with the ones of the given config.
+// Policies and identities created at runtime are not affected
+// unless the config file contains a policy with the same name.
+//
+// The caller must hold the lock.
+func (r *configReloader) applyPolicies(config *serverConfig) error {
+	policies, err := parsePolicies(config)
+	if err != nil {
+		return err
+	}
+
+	// First, we check all identity assignments such that we
+	// either apply all changes or none.
+	assigned := map[kes.Identity]string{}
+	for name, policy := range config.Policies {
+		for _, identity := range policy.Identities {
+			if identity.IsUnknown() {
+				continue
+			}
+			if identity == r.Root {
+				return fmt.Errorf("Cannot assign policy '%s' to root identity '%s'", name, identity)
+			}
+			if r.Proxy != nil && r.Proxy.Is(identity) {
+				return fmt.Errorf("Cannot assign policy '%s' to TLS proxy '%s'", name, identity)
+			}
+			if other, ok := assigned[identity]; ok && other != name {
+				return fmt.Errorf("Cannot assign policy '%s' to identity '%s': this identity already has a policy", name, identity)
+			}
+			assigned[identity] = name
+		}
+	}
+
+	identities := r.Roles.Identities()
+	for name, policy := range r.config.Policies {
+		if _, ok := config.Policies[name]; !ok {
+			r.Roles.Delete(name) // The policy has been removed from the config file
+			continue
+		}
+		for _, identity := range policy.Identities {
+			if _, ok := assigned[identity]; !ok && identities[identity] == name {
+				r.Roles.Forget(identity) // The identity has been removed from the config file
+			}
+		}
+	}
+	for name, policy := range policies {
+		r.Roles.Set(name, policy)
+	}
+	for identity, name := range assigned {
+		if err = r.Roles.Assign(name, identity); err != nil {
+			return err
+		}
+	}
+	return r.Roles.Save()
+}
+
+// applyAuditSinks replaces the current audit log sinks
+// with the ones specified in the given config.
+//
+// The caller must hold the lock.
+func (r *configReloader) applyAuditSinks(config *serverConfig) error {
+	// The new file sink continues the hash chain of the
+	// current one. Therefore, we remove the current sinks
+	// before creating the new ones.
+	for _, sink := range r.auditSinks {
+		r.AuditLog.RemoveOutput(sink)
+	}
+	sinks, err := newAuditSinks(config, r.EnclaveKey, r.ErrorLog)
+	if err != nil {
+		for _, sink := range r.auditSinks { // Keep the current sinks
+			r.AuditLog.AddOutput(sink)
+		}
+		return err
+	}
+	for _, sink := range sinks {
+		r.AuditLog.AddOutput(sink)
+	}
+	closeAuditSinks(r.auditSinks)
+	r.auditSinks = sinks
+	return nil
+}
+
+// AuditSinks returns the currently used audit log sinks.
+func (r *configReloader) AuditSinks() []io.Writer {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.auditSinks
+}
+
+// closeAuditSinks closes all audit log sinks that
+// implement io.Closer - e.g. to flush buffered events.
+func closeAuditSinks(sinks []io.Writer) {
+	for _, sink := range sinks {
+		if chain, ok := sink.(*xlog.ChainWriter); ok {
+			sink = chain.Writer
+		}
+		if closer, ok := sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
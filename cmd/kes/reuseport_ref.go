@@ -0,0 +1,18 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	// We only support SO_REUSEPORT
+	// on linux at the moment.
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,230 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/minio/kes"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const sealCmdUsage = `usage: %s <command>
+
+  status               Show whether the server is sealed.
+  init                 Initialize the seal and print the key shares.
+  unseal               Submit a key share to unseal the server.
+
+  -h, --help           Show list of command-line options
+
+A server with a seal protects all keys with a root key that is split
+into key shares. The server starts sealed and serves requests once a
+threshold of key shares has been submitted - e.g. 3 of 5:
+  $ kes seal init --shares=5 --threshold=3
+  $ kes seal unseal
+
+With auto-unseal, the server unseals itself using its KMS. Then, the key
+shares are only required if the KMS is not available.
+`
+
+func seal(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), sealCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "status":
+		return sealStatus(args)
+	case "init":
+		return initSeal(args)
+	case "unseal":
+		return unseal(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const sealStatusCmdUsage = `usage: %s [options]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+`
+
+func sealStatus(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), sealStatusCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	status, err := client.SealStatus()
+	if err != nil {
+		return fmt.Errorf("Cannot fetch seal status: %v", err)
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(status)
+	}
+	printSealStatus(status)
+	return nil
+}
+
+const initSealCmdUsage = `usage: %s [options]
+
+  --shares <n>         Number of key shares. (default: 5)
+  --threshold <m>      Number of key shares required to unseal
+                       the server. (default: 3)
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Initializes the seal of a server and prints the key shares - one per line.
+The server does not store the key shares. Distribute them to their holders
+immediately. The server is unsealed once it has been initialized.
+  $ kes seal init --shares=5 --threshold=3
+`
+
+func initSeal(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), initSealCmdUsage, cli.Name())
+	}
+
+	var (
+		insecureSkipVerify bool
+		shares, threshold  int
+	)
+	cli.IntVar(&shares, "shares", 5, "Number of key shares")
+	cli.IntVar(&threshold, "threshold", 3, "Number of key shares required to unseal the server")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	keyShares, err := client.InitSeal(shares, threshold)
+	if err != nil {
+		return fmt.Errorf("Cannot initialize seal: %v", err)
+	}
+	for _, share := range keyShares {
+		fmt.Println(base64.StdEncoding.EncodeToString(share))
+	}
+	return nil
+}
+
+const unsealCmdUsage = `usage: %s [options] [<share>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Submits a key share to the server. If no key share is specified, it is
+read from the terminal without echo or from standard input.
+  $ kes seal unseal
+`
+
+func unseal(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), unsealCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var encodedShare string
+	switch {
+	case len(args) == 1:
+		encodedShare = args[0]
+	case isTerm(os.Stdin):
+		fmt.Fprint(os.Stderr, "Key share: ")
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("Cannot read key share: %v", err)
+		}
+		encodedShare = string(b)
+	default:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("Cannot read key share: %v", err)
+		}
+		encodedShare = line
+	}
+	share, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedShare))
+	if err != nil {
+		return errors.New("Invalid key share: not base64-encoded")
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	status, err := client.Unseal(share)
+	if err != nil {
+		return fmt.Errorf("Cannot unseal server: %v", err)
+	}
+	if !isTerm(os.Stdout) {
+		return json.NewEncoder(os.Stdout).Encode(status)
+	}
+	printSealStatus(status)
+	return nil
+}
+
+func printSealStatus(status kes.SealStatus) {
+	switch {
+	case !status.Initialized:
+		fmt.Println("Sealed:    yes - the seal has not been initialized")
+	case status.Sealed:
+		fmt.Println("Sealed:    yes")
+		fmt.Printf("Progress:  %d of %d key shares submitted\n", status.Progress, status.Threshold)
+	default:
+		fmt.Println("Sealed:    no")
+	}
+	if status.Initialized {
+		fmt.Printf("Threshold: %d of %d key shares\n", status.Threshold, status.Shares)
+	}
+	if status.AutoUnseal {
+		fmt.Println("Auto:      yes - the server unseals itself using its KMS")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const secretCmdUsage = `usage: %s <command>
+
+  create               Store an opaque secret - e.g. an API token.
+  get                  Print an opaque secret.
+
+  -h, --help           Show list of command-line options
+
+Opaque secrets are arbitrary bytes - like API tokens or certificates.
+In contrast to keys, they are returned as they are and cannot be used
+to encrypt or decrypt data. Keys and opaque secrets share the same
+namespace. Hence, opaque secrets are listed, described and deleted via
+"kes key".
+`
+
+func secretCmd(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), secretCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		return createSecret(args)
+	case "get":
+		return getSecret(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const createSecretCmdUsage = `usage: %s [options] <name> [<file>]
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Stores the content of the file as opaque secret. If no file is
+specified, it reads the secret from STDIN. For example:
+  $ kes secret create my-app/api-token token.txt
+  $ echo -n "s3cr3t" | kes secret create my-app/password
+`
+
+func createSecret(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), createSecretCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 && len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if len(args) == 2 {
+		file, err := os.Open(args[1])
+		if err != nil {
+			return fmt.Errorf("Cannot open '%s': %v", args[1], err)
+		}
+		defer file.Close()
+		in = file
+	}
+	const MaxSize = 1 << 20 // The server rejects larger secrets anyway
+	value, err := ioutil.ReadAll(io.LimitReader(in, MaxSize))
+	if err != nil {
+		return fmt.Errorf("Cannot read secret: %v", err)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if err = client.CreateSecret(args[0], value); err != nil {
+		return fmt.Errorf("Cannot create secret '%s': %v", args[0], err)
+	}
+	return nil
+}
+
+const getSecretCmdUsage = `usage: %s [options] <name>
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Writes the opaque secret to STDOUT as it is.
+`
+
+func getSecret(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), getSecretCmdUsage, cli.Name())
+	}
+
+	var insecureSkipVerify bool
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	value, err := client.GetSecret(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot get secret '%s': %v", args[0], err)
+	}
+	_, err = os.Stdout.Write(value)
+	return err
+}
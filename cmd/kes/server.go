@@ -6,13 +6,17 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	stdlog "log"
 	"net"
@@ -21,6 +25,8 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -28,16 +34,34 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/accounting"
+	"github.com/minio/kes/internal/attest"
 	"github.com/minio/kes/internal/auth"
 	"github.com/minio/kes/internal/aws"
+	"github.com/minio/kes/internal/cluster"
+	xenclave "github.com/minio/kes/internal/enclave"
+	"github.com/minio/kes/internal/entropy"
+	"github.com/minio/kes/internal/escrow"
 	"github.com/minio/kes/internal/fs"
 	"github.com/minio/kes/internal/gemalto"
+	xgrpc "github.com/minio/kes/internal/grpc"
 	xhttp "github.com/minio/kes/internal/http"
+	xjob "github.com/minio/kes/internal/job"
+	"github.com/minio/kes/internal/kmip"
 	xlog "github.com/minio/kes/internal/log"
 	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kes/internal/proxyproto"
+	xseal "github.com/minio/kes/internal/seal"
 	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/trace"
 	"github.com/minio/kes/internal/vault"
+	"github.com/minio/kes/internal/watch"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const serverCmdUsage = `usage: %s [options]
@@ -65,6 +89,10 @@ const serverCmdUsage = `usage: %s [options]
                           Require and verify      : --auth=on (default)
                           Require but don't verify: --auth=off
 
+  --validate           Validate the config file, load all referenced files,
+                       connect to the key store and KMS and exit without
+                       starting the server.
+
   -q, --quiet          Do not print information on startup.
 `
 
@@ -79,6 +107,7 @@ func server(args []string) error {
 		configPath   string
 		rootIdentity string
 		mlock        bool
+		validate     bool
 
 		tlsKeyPath  string
 		tlsCertPath string
@@ -93,6 +122,7 @@ func server(args []string) error {
 	cli.StringVar(&tlsKeyPath, "key", "", "Path to the TLS private key")
 	cli.StringVar(&tlsCertPath, "cert", "", "Path to the TLS certificate")
 	cli.StringVar(&mtlsAuth, "auth", "on", "Controls how the server handles mTLS authentication")
+	cli.BoolVar(&validate, "validate", false, "Validate the config file and exit")
 	cli.Var(&quiet, "q", "Do not print information on startup")
 	cli.Var(&quiet, "quiet", "Do not print information on startup")
 	cli.Parse(args[1:])
@@ -100,6 +130,7 @@ func server(args []string) error {
 		cli.Usage()
 		os.Exit(2)
 	}
+	startTime := time.Now()
 
 	config, err := loadServerConfig(configPath)
 	if err != nil {
@@ -117,16 +148,32 @@ func server(args []string) error {
 		rootIdentity = config.Root.String()
 	}
 	if tlsKeyPath == "" {
-		if config.TLS.KeyPath == "" {
-			return errors.New("No private key file has been specified")
-		}
 		tlsKeyPath = config.TLS.KeyPath
 	}
 	if tlsCertPath == "" {
-		if config.TLS.CertPath == "" {
+		tlsCertPath = config.TLS.CertPath
+	}
+	if len(config.TLS.ACME.Domains) == 0 {
+		if tlsKeyPath == "" {
+			return errors.New("No private key file has been specified")
+		}
+		if tlsCertPath == "" {
 			return errors.New("No certificate file has been specified")
 		}
-		tlsCertPath = config.TLS.CertPath
+	} else if tlsKeyPath != "" || tlsCertPath != "" {
+		return errors.New("Ambiguous configuration: TLS certificate and ACME specified at the same time")
+	}
+
+	if validate {
+		if config.Root == "" {
+			config.Root = kes.Identity(rootIdentity)
+		}
+		if errs := config.Verify(); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintln(cli.Output(), err)
+			}
+			return fmt.Errorf("Invalid config file: %d problem(s) found", len(errs))
+		}
 	}
 
 	switch {
@@ -142,6 +189,14 @@ func server(args []string) error {
 		return errors.New("Ambiguous configuration: Hashicorp Vault and Gemalto KeySecure endpoint are specified at the same time")
 	case config.Keys.Aws.SecretsManager.Endpoint != "" && config.Keys.Gemalto.KeySecure.Endpoint != "":
 		return errors.New("Ambiguous configuration: AWS SecretsManager and Gemalto KeySecure endpoint are specified at the same time")
+	case config.Keys.Fs.Path != "" && config.Keys.Aws.ParameterStore.Endpoint != "":
+		return errors.New("Ambiguous configuration: FS and AWS ParameterStore endpoint are specified at the same time")
+	case config.Keys.Vault.Endpoint != "" && config.Keys.Aws.ParameterStore.Endpoint != "":
+		return errors.New("Ambiguous configuration: Hashicorp Vault and AWS ParameterStore endpoint are specified at the same time")
+	case config.Keys.Aws.SecretsManager.Endpoint != "" && config.Keys.Aws.ParameterStore.Endpoint != "":
+		return errors.New("Ambiguous configuration: AWS SecretsManager and AWS ParameterStore endpoint are specified at the same time")
+	case config.Keys.Aws.ParameterStore.Endpoint != "" && config.Keys.Gemalto.KeySecure.Endpoint != "":
+		return errors.New("Ambiguous configuration: AWS ParameterStore and Gemalto KeySecure endpoint are specified at the same time")
 	}
 
 	if mlock {
@@ -156,16 +211,38 @@ func server(args []string) error {
 	var errorLog *xlog.SystemLog
 	switch strings.ToLower(config.Log.Error) {
 	case "on":
-		if isTerm(os.Stderr) { // If STDERR is a tty - write plain logs, not JSON.
+		switch strings.ToLower(config.Log.Format) {
+		case "text":
 			errorLog = xlog.NewLogger(os.Stderr, "", stdlog.LstdFlags)
-		} else {
+		case "json":
 			errorLog = xlog.NewLogger(xlog.NewJSONWriter(os.Stderr), "", stdlog.LstdFlags)
+		case "":
+			if isTerm(os.Stderr) { // If STDERR is a tty - write plain logs, not JSON.
+				errorLog = xlog.NewLogger(os.Stderr, "", stdlog.LstdFlags)
+			} else {
+				errorLog = xlog.NewLogger(xlog.NewJSONWriter(os.Stderr), "", stdlog.LstdFlags)
+			}
+		default:
+			return fmt.Errorf("Error log format '%s' is invalid", config.Log.Format)
 		}
 	case "off":
 		errorLog = xlog.NewLogger(ioutil.Discard, "", stdlog.LstdFlags)
 	default:
 		return fmt.Errorf("Error log configuration '%s' is invalid", config.Log.Error)
 	}
+	logLevel, logLevels, err := parseLogLevels(&config)
+	if err != nil {
+		return err
+	}
+	errorLog.SetLevel(logLevel, logLevels)
+	if config.Log.Sinks.Syslog.Error {
+		syslog, err := newSyslog(&config, "error", errorLog.Log())
+		if err != nil {
+			return err
+		}
+		syslog.Level = xlog.LevelError // Text written via errorLog.Log() reports errors
+		errorLog.AddOutput(syslog)
+	}
 
 	var auditLog *xlog.SystemLog
 	switch strings.ToLower(config.Log.Audit) {
@@ -177,6 +254,26 @@ func server(args []string) error {
 		return fmt.Errorf("Audit log configuration '%s' is invalid", config.Log.Audit)
 	}
 
+	var revocation *auth.Revocation
+	if config.TLS.Revocation.CRL != "" || config.TLS.Revocation.OCSP {
+		revocation = &auth.Revocation{
+			CRL:           config.TLS.Revocation.CRL,
+			OCSP:          config.TLS.Revocation.OCSP,
+			OCSPResponder: config.TLS.Revocation.Responder,
+			CacheExpiry:   config.TLS.Revocation.Cache,
+			Client:        &http.Client{Timeout: 10 * time.Second},
+			ErrorLog:      errorLog.Log(),
+		}
+		switch strings.ToLower(config.TLS.Revocation.Failure) {
+		case "", "closed":
+			revocation.FailOpen = false
+		case "open":
+			revocation.FailOpen = true
+		default:
+			return fmt.Errorf("Invalid option for revocation failure policy: %s", config.TLS.Revocation.Failure)
+		}
+	}
+
 	var proxy *auth.TLSProxy
 	if len(config.TLS.Proxy.Identities) != 0 {
 		proxy = &auth.TLSProxy{
@@ -185,6 +282,7 @@ func server(args []string) error {
 		if strings.ToLower(mtlsAuth) != "off" {
 			proxy.VerifyOptions = new(x509.VerifyOptions)
 		}
+		proxy.Revocation = revocation
 		for _, identity := range config.TLS.Proxy.Identities {
 			if identity == kes.Identity(rootIdentity) {
 				return fmt.Errorf("Cannot use root identity '%s' as TLS proxy", identity)
@@ -195,15 +293,44 @@ func server(args []string) error {
 		}
 	}
 
-	roles := &auth.Roles{
-		Root: kes.Identity(rootIdentity),
+	var verifier *auth.SignatureVerifier
+	if config.TLS.Signature.Required {
+		verifier = &auth.SignatureVerifier{
+			MaxSkew: config.TLS.Signature.Skew,
+		}
 	}
-	for name, policy := range config.Policies {
-		p, err := kes.NewPolicy(policy.Paths...)
+	var (
+		escrowKey        ed25519.PrivateKey
+		escrowCustodians []escrow.Custodian
+	)
+	if config.Escrow.Key != "" {
+		if escrowKey, err = loadSigningKey(config.Escrow.Key); err != nil {
+			return fmt.Errorf("Failed to load escrow signing key: %v", err)
+		}
+		if escrowCustodians, err = newEscrowCustodians(&config); err != nil {
+			return err
+		}
+	}
+
+	var responseSigner crypto.Signer
+	if config.TLS.Signature.Key != "" {
+		key, err := loadSigningKey(config.TLS.Signature.Key)
 		if err != nil {
-			return fmt.Errorf("Policy '%s' contains invalid path: %v", name, err)
+			return fmt.Errorf("Failed to load response signing key: %v", err)
 		}
-		roles.Set(name, p)
+		responseSigner = key
+	}
+
+	roles := &auth.Roles{
+		Root:   kes.Identity(rootIdentity),
+		Events: new(watch.Hub),
+	}
+	policies, err := parsePolicies(&config)
+	if err != nil {
+		return err
+	}
+	for name, policy := range config.Policies {
+		roles.Set(name, policies[name])
 
 		for _, identity := range policy.Identities {
 			if identity == kes.Identity(rootIdentity) {
@@ -221,178 +348,1058 @@ func server(args []string) error {
 		}
 	}
 
-	var (
-		store            = &secret.Store{}
-		keyStore         string
-		keyStoreEndpoint string
-	)
-	switch {
-	case config.Keys.Fs.Path != "":
-		f, err := os.Stat(config.Keys.Fs.Path)
-		if err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("Failed to open %s: %v", config.Keys.Fs.Path, err)
+	if config.LDAP.Address != "" {
+		if config.LDAP.User.DN == "" || config.LDAP.User.Base == "" || config.LDAP.User.Attribute == "" {
+			return errors.New("Invalid LDAP configuration: the user DN, base and attribute must be specified")
 		}
-		if err == nil && !f.IsDir() {
-			return fmt.Errorf("%s is not a directory", config.Keys.Fs.Path)
+		roles.LDAP = &auth.LDAP{
+			Addr:           config.LDAP.Address,
+			UserDN:         config.LDAP.User.DN,
+			BaseDN:         config.LDAP.User.Base,
+			UserAttribute:  config.LDAP.User.Attribute,
+			GroupAttribute: config.LDAP.Group.Attribute,
+			Policies:       config.LDAP.Groups,
+			CacheExpiry:    config.LDAP.Cache,
+			TLSConfig:      &tls.Config{},
+			ErrorLog:       errorLog.Log(),
 		}
-		if os.IsNotExist(err) {
-			msg := fmt.Sprintf("Creating directory '%s' ... ", config.Keys.Fs.Path)
-			quiet.Print(msg)
-			if err = os.MkdirAll(config.Keys.Fs.Path, 0700); err != nil {
-				return fmt.Errorf("Failed to create directory %s: %v", config.Keys.Fs.Path, err)
+		if config.LDAP.TLS.CAPath != "" {
+			caCerts, err := ioutil.ReadFile(config.LDAP.TLS.CAPath)
+			if err != nil {
+				return fmt.Errorf("Failed to read LDAP CA certificates: %v", err)
+			}
+			roles.LDAP.TLSConfig.RootCAs = x509.NewCertPool()
+			if !roles.LDAP.TLSConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+				return fmt.Errorf("Failed to parse LDAP CA certificates: '%s' contains no PEM-encoded certificate", config.LDAP.TLS.CAPath)
 			}
-			quiet.ClearMessage(msg)
 		}
-		store.Remote = &fs.Store{
-			Dir:      config.Keys.Fs.Path,
-			ErrorLog: errorLog.Log(),
+		for group, policy := range config.LDAP.Groups {
+			if _, ok := roles.Get(policy); !ok {
+				return fmt.Errorf("Cannot map LDAP group '%s' to policy '%s': policy does not exist", group, policy)
+			}
 		}
+	}
 
-		keyStore = "Filesystem"
-		if keyStoreEndpoint, err = filepath.Abs(config.Keys.Fs.Path); err != nil {
-			keyStoreEndpoint = config.Keys.Fs.Path
+	if config.OIDC.Issuer != "" {
+		if config.OIDC.Audience == "" {
+			return errors.New("Invalid OIDC configuration: the audience must be specified")
 		}
-	case config.Keys.Vault.Endpoint != "":
-		vaultStore := &vault.Store{
-			Addr:      config.Keys.Vault.Endpoint,
-			Engine:    config.Keys.Vault.EnginePath,
-			Location:  config.Keys.Vault.Prefix,
-			Namespace: config.Keys.Vault.Namespace,
-			AppRole: vault.AppRole{
-				Engine: config.Keys.Vault.AppRole.EnginePath,
-				ID:     config.Keys.Vault.AppRole.ID,
-				Secret: config.Keys.Vault.AppRole.Secret,
-				Retry:  config.Keys.Vault.AppRole.Retry,
-			},
-			StatusPingAfter: config.Keys.Vault.Status.Ping,
-			ErrorLog:        errorLog.Log(),
-			ClientKeyPath:   config.Keys.Vault.TLS.KeyPath,
-			ClientCertPath:  config.Keys.Vault.TLS.CertPath,
-			CAPath:          config.Keys.Vault.TLS.CAPath,
+		roles.OIDC = &auth.OIDC{
+			Issuer:   config.OIDC.Issuer,
+			Audience: config.OIDC.Audience,
+			JWKSURL:  config.OIDC.JWKS,
+			Claim:    config.OIDC.Claim,
+			Policies: config.OIDC.Groups,
+			Client:   &http.Client{Timeout: 10 * time.Second},
+			ErrorLog: errorLog.Log(),
+		}
+		for value, policy := range config.OIDC.Groups {
+			if _, ok := roles.Get(policy); !ok {
+				return fmt.Errorf("Cannot map OIDC claim '%s' to policy '%s': policy does not exist", value, policy)
+			}
 		}
 
-		msg := fmt.Sprintf("Authenticating to Hashicorp Vault '%s' ... ", vaultStore.Addr)
+		msg := fmt.Sprintf("Fetching OIDC keys from '%s' ... ", config.OIDC.Issuer)
 		quiet.Print(msg)
-		if err := vaultStore.Authenticate(context.Background()); err != nil {
-			return fmt.Errorf("Failed to connect to Vault: %v", err)
+		if err = roles.OIDC.Refresh(); err != nil {
+			return fmt.Errorf("Failed to fetch OIDC keys: %v", err)
 		}
 		quiet.ClearMessage(msg)
-		store.Remote = vaultStore
+	}
 
-		keyStore = "Hashicorp Vault"
-		keyStoreEndpoint = config.Keys.Vault.Endpoint
-	case config.Keys.Aws.SecretsManager.Endpoint != "":
-		awsStore := &aws.SecretsManager{
-			Addr:     config.Keys.Aws.SecretsManager.Endpoint,
-			Region:   config.Keys.Aws.SecretsManager.Region,
-			KMSKeyID: config.Keys.Aws.SecretsManager.KmsKey,
-			ErrorLog: errorLog.Log(),
-			Login: aws.Credentials{
-				AccessKey:    config.Keys.Aws.SecretsManager.Login.AccessKey,
-				SecretKey:    config.Keys.Aws.SecretsManager.Login.SecretKey,
-				SessionToken: config.Keys.Aws.SecretsManager.Login.SessionToken,
+	if config.Authz.Endpoint != "" {
+		tlsConfig := &tls.Config{}
+		if config.Authz.TLS.CAPath != "" {
+			caCerts, err := ioutil.ReadFile(config.Authz.TLS.CAPath)
+			if err != nil {
+				return fmt.Errorf("Failed to read authz CA certificates: %v", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+				return fmt.Errorf("Failed to parse authz CA certificates: '%s' contains no PEM-encoded certificate", config.Authz.TLS.CAPath)
+			}
+		}
+		timeout := config.Authz.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		roles.Authorizer = &auth.HTTPAuthorizer{
+			Endpoint: config.Authz.Endpoint,
+			Mode:     config.Authz.Mode,
+			Client: &http.Client{
+				Timeout:   timeout,
+				Transport: &http.Transport{TLSClientConfig: tlsConfig},
 			},
+			ErrorLog: errorLog.Log(),
 		}
+	}
 
-		msg := fmt.Sprintf("Authenticating to AWS SecretsManager '%s' ... ", awsStore.Addr)
+	if roles.Tenants, err = newTenants(&config, kes.Identity(rootIdentity)); err != nil {
+		return err
+	}
+	if roles.Quotas, err = newQuotas(&config, kes.Identity(rootIdentity)); err != nil {
+		return err
+	}
+	if roles.Quotas != nil {
+		// Only keys of the configured tenants count against
+		// a tenant quota - not every key name with a '/'.
+		roles.Quotas.Tenancy = roles.Tenants
+		if roles.Quotas.Tenancy == nil {
+			roles.Quotas.Tenancy = new(auth.Tenants)
+		}
+	}
+
+	limit, identityLimits, apiLimits, err := rateLimits(&config)
+	if err != nil {
+		return err
+	}
+	limiter := new(xhttp.RateLimiter)
+	limiter.SetLimits(limit, identityLimits, apiLimits)
+
+	concurrency := new(xhttp.ConcurrencyLimiter)
+	concurrency.SetLimits(config.Limit.Concurrency.Requests, config.Limit.Concurrency.Identities)
+
+	// Retries of key create and delete requests with the same
+	// idempotency key receive the response of the first request.
+	idempotency := new(xhttp.IdempotencyCache)
+
+	// ctx is done once the server has been shut down. It stops
+	// all background tasks - e.g. the cache garbage collection.
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	store := &secret.Store{}
+	remote, keyStore, keyStoreEndpoint, err := newKeyStore(ctx, &config, errorLog, quiet)
+	if err != nil {
+		return err
+	}
+	store.Remote = remote
+
+	metrics := &metric.Metrics{}
+	var kmsName, kmsEndpoint string
+	if config.KMS.Aws.Endpoint != "" {
+		awsKMS := newAWSKMS(&config)
+		awsKMS.Log = errorLog.Logger("aws-kms")
+
+		msg := fmt.Sprintf("Authenticating to AWS-KMS '%s' ... ", awsKMS.Addr)
 		quiet.Print(msg)
-		if err := awsStore.Authenticate(); err != nil {
-			return fmt.Errorf("Failed to connect to AWS Secrets Manager: %v", err)
+		if err := awsKMS.Authenticate(); err != nil {
+			return fmt.Errorf("Failed to connect to AWS-KMS: %v", err)
 		}
 		quiet.ClearMessage(msg)
-		store.Remote = awsStore
+		store.KMS = awsKMS
+
+		metrics.CounterFunc("kes_kms_aws_new_connections_total", "Number of newly established connections to AWS-KMS.", func() float64 {
+			return float64(awsKMS.ConnStats().NewConns)
+		})
+		metrics.CounterFunc("kes_kms_aws_reused_connections_total", "Number of requests that reused an idle connection to AWS-KMS.", func() float64 {
+			return float64(awsKMS.ConnStats().ReusedConns)
+		})
+		metrics.CounterFunc("kes_kms_aws_tls_handshakes_total", "Number of completed TLS handshakes with AWS-KMS.", func() float64 {
+			return float64(awsKMS.ConnStats().TLSHandshakes)
+		})
+		metrics.CounterFunc("kes_kms_aws_tls_resumed_total", "Number of TLS handshakes with AWS-KMS that resumed a TLS session.", func() float64 {
+			return float64(awsKMS.ConnStats().TLSResumed)
+		})
+
+		kmsName = "AWS-KMS"
+		kmsEndpoint = config.KMS.Aws.Endpoint
+	}
 
-		keyStore = "AWS SecretsManager"
-		keyStoreEndpoint = config.Keys.Aws.SecretsManager.Endpoint
-	case config.Keys.Gemalto.KeySecure.Endpoint != "":
-		gemaltoStore := &gemalto.KeySecure{
-			Endpoint: config.Keys.Gemalto.KeySecure.Endpoint,
-			CAPath:   config.Keys.Gemalto.KeySecure.TLS.CAPath,
-			ErrorLog: errorLog.Log(),
-			Login: gemalto.Credentials{
-				Token:  config.Keys.Gemalto.KeySecure.Login.Token,
-				Domain: config.Keys.Gemalto.KeySecure.Login.Domain,
-				Retry:  config.Keys.Gemalto.KeySecure.Login.Retry,
-			},
+	// The key store may store secrets as raw bytes or PEM blocks
+	// instead of JSON - e.g. to share them with other tools. Values
+	// with any encoding can be read - independent of the encoding
+	// used to write new values.
+	store.Remote = secret.EncodingRemote{Remote: store.Remote, Encoding: keyStoreEncoding(&config)}
+	store.Remote = metric.Remote{Remote: store.Remote, Metrics: metrics}
+	if store.KMS != nil {
+		store.KMS = metric.KMS{KMS: store.KMS, Metrics: metrics}
+	}
+
+	// All keys are generated from a health-tested entropy
+	// source. Once a health test fails, the server refuses
+	// to generate keys.
+	entropySource, err := newEntropySource(&config)
+	if err != nil {
+		return err
+	}
+	store.Rand = entropySource
+
+	// Clients can watch keys and policies for changes
+	// instead of polling the list APIs.
+	store.Events = new(watch.Hub)
+
+	// Without a KMS, the keys can be protected by a root key
+	// that is split into key shares. The server starts sealed
+	// and serves requests once it has been unsealed.
+	//
+	// With auto-unseal, the root key is also protected by the
+	// KMS such that the server unseals itself on startup. If
+	// the KMS cannot decrypt the root key, the server remains
+	// sealed and can be unsealed with the key shares.
+	var (
+		barrier    *xseal.Barrier
+		autoUnseal error
+	)
+	if config.Seal.Enabled {
+		barrier = &xseal.Barrier{Remote: store.Remote, Rand: store.Rand}
+		if config.Seal.Auto {
+			if store.KMS == nil {
+				return errors.New("Seal auto-unseal requires a KMS")
+			}
+			barrier.KMS = store.KMS
+		}
+		if err := barrier.Load(); err != nil {
+			return fmt.Errorf("Failed to load seal from %s: %v", keyStore, err)
+		}
+		if config.Seal.Auto && barrier.State().Initialized {
+			autoUnseal = barrier.AutoUnseal()
+		}
+		store.KMS = barrier
+	}
+	metrics.CounterFunc("kes_cache_hits_total", "Number of secrets served from the cache.", func() float64 {
+		hits, _ := store.CacheStats()
+		return float64(hits)
+	})
+	metrics.CounterFunc("kes_cache_misses_total", "Number of secrets fetched from the key store.", func() float64 {
+		_, misses := store.CacheStats()
+		return float64(misses)
+	})
+	metrics.GaugeFunc("kes_cache_size_bytes", "Approximate memory used by cached secrets.", func() float64 {
+		return float64(store.CacheSize())
+	})
+	store.StartGC(ctx, config.Cache.Expiry.Any, config.Cache.Expiry.Unused)
+
+	// If the approximate memory used by the cache and in-flight
+	// requests exceeds the limit, the server evicts all cached
+	// secrets. If that's not sufficient, it rejects requests until
+	// enough in-flight requests have completed.
+	var memoryLimiter *xhttp.MemoryLimiter
+	if config.Memory.Limit > 0 {
+		memoryLimiter = &xhttp.MemoryLimiter{
+			Limit: config.Memory.Limit << 20,
+			Usage: store.CacheSize,
+			Evict: store.EvictAll,
+		}
+		metrics.GaugeFunc("kes_memory_in_use_bytes", "Approximate memory used by cached secrets and in-flight requests.", func() float64 {
+			return float64(memoryLimiter.InUse())
+		})
+		metrics.CounterFunc("kes_http_requests_shed_total", "Number of requests rejected because the memory limit has been exceeded.", func() float64 {
+			return float64(memoryLimiter.Shed())
+		})
+	}
+	metrics.GaugeVecFunc("kes_http_inflight_requests", "Number of in-flight requests by identity.", []string{"identity"}, concurrency.InFlight)
+	metrics.CounterVecFunc("kes_http_requests_rejected_concurrency_total", "Number of requests rejected because the identity has reached its concurrency limit.", []string{"identity"}, concurrency.Rejected)
+
+	// All servers that share a key store and KMS can share an
+	// enclave key. They derive their server-local secrets from
+	// it - such that they are interchangeable behind a load
+	// balancer.
+	var enclaveKey *secret.EnclaveKey
+	if config.Enclave.Shared {
+		if store.KMS == nil {
+			return errors.New("Shared enclave key requires a KMS")
+		}
+		msg := "Loading shared enclave key ... "
+		quiet.Print(msg)
+		key, err := secret.LoadEnclaveKey(store.Reader(), store.Remote, store.KMS)
+		if err != nil {
+			return fmt.Errorf("Failed to load shared enclave key: %v", err)
 		}
+		quiet.ClearMessage(msg)
+		enclaveKey = &key
+	}
 
-		msg := fmt.Sprintf("Authenticating to Gemalto KeySecure '%s' ... ", gemaltoStore.Endpoint)
-		quiet.Printf(msg)
-		if err := gemaltoStore.Authenticate(); err != nil {
-			return fmt.Errorf("Failed to connect to Gemalto KeySecure: %v", err)
+	// The post-quantum hybrid wrapping uses a KEM key pair
+	// that is independent of the keys and protected by the
+	// KMS - like the enclave key.
+	if config.Hybrid.Enabled {
+		if store.KMS == nil {
+			return errors.New("Hybrid wrapping requires a KMS")
+		}
+		msg := "Loading hybrid KEM key ... "
+		quiet.Print(msg)
+		hybridKey, err := secret.LoadHybridKey(store.Reader(), store.Remote, store.KMS)
+		if err != nil {
+			return fmt.Errorf("Failed to load hybrid KEM key: %v", err)
 		}
 		quiet.ClearMessage(msg)
-		store.Remote = gemaltoStore
+		store.HybridKey = hybridKey
+	}
 
-		keyStore = "Gemalto KeySecure"
-		keyStoreEndpoint = config.Keys.Gemalto.KeySecure.Endpoint
-	default:
-		store.Remote = &mem.Store{}
+	// Destructive operations of the root identity may require
+	// the approval of multiple admins. Servers sharing an enclave
+	// key accept each other's approval tokens. The used tokens are
+	// recorded at the key store such that each token can be used
+	// only once.
+	if len(config.Approval.Admins) > 0 {
+		roles.Approvals = &auth.Approvals{
+			Admins: config.Approval.Admins,
+			Quorum: config.Approval.Quorum,
+			Window: config.Approval.Window,
+			APIs:   config.Approval.APIs,
+		}
+		if enclaveKey != nil {
+			roles.Approvals.Secret = enclaveKey.DeriveKey("kes approval token key", 32)
+		}
+	}
 
-		keyStore = "In-Memory"
-		keyStoreEndpoint = "non-persistent"
+	// Identities may issue short-lived session tokens for
+	// ephemeral workloads. Servers sharing an enclave key
+	// accept each other's session tokens.
+	if config.Session.Enabled {
+		roles.Sessions = &auth.Sessions{
+			TTL:    config.Session.TTL,
+			MaxTTL: config.Session.MaxTTL,
+		}
+		if enclaveKey != nil {
+			roles.Sessions.Secret = enclaveKey.DeriveKey("kes session token key", 32)
+		}
 	}
-	store.StartGC(context.Background(), config.Cache.Expiry.Any, config.Cache.Expiry.Unused)
+
+	auditSinks, err := newAuditSinks(&config, enclaveKey, errorLog.Log())
+	if err != nil {
+		return err
+	}
+	for _, sink := range auditSinks {
+		auditLog.AddOutput(sink)
+	}
+
+	// Enclaves are isolated "virtual servers" with their own
+	// root identity, policies and keys. They are loaded once
+	// the server has been set up. See: enclaves.Load
+	enclaves := &xenclave.Manager{
+		KMS:               store.KMS,
+		Rand:              store.Rand,
+		HybridKey:         store.HybridKey,
+		Identify:          roles.Identify,
+		Authorizer:        roles.Authorizer,
+		CacheExpiry:       config.Cache.Expiry.Any,
+		CacheUnusedExpiry: config.Cache.Expiry.Unused,
+	}
+
+	// In cluster mode, all writes - including policies, identity
+	// assignments and quotas - are replicated to all cluster nodes.
+	// Each node applies them to its own key store.
+	var node *cluster.Node
+	if len(config.Cluster.Peers) > 0 {
+		if node, err = newClusterNode(ctx, &config, tlsCertPath, tlsKeyPath, errorLog.Log()); err != nil {
+			return err
+		}
+		local := store.Remote
+		node.Apply = func(cmd cluster.Command) error {
+			switch cmd.Op {
+			case cluster.OpCreate:
+				if err := local.Create(cmd.Key, cmd.Value); err != nil {
+					return err
+				}
+				switch cmd.Key {
+				case secret.ReservedName:
+					if err := roles.Reload(); err != nil {
+						errorLog.Log().Printf("cluster: failed to reload policies: %v", err)
+					}
+				case secret.ReservedQuotaName:
+					if roles.Quotas != nil {
+						if err := roles.Quotas.Load(); err != nil {
+							errorLog.Log().Printf("cluster: failed to reload key quotas: %v", err)
+						}
+					}
+				case secret.ReservedAliasName:
+					if err := store.LoadAliases(); err != nil {
+						errorLog.Log().Printf("cluster: failed to reload key aliases: %v", err)
+					}
+				case secret.ReservedStateName:
+					if err := store.LoadStates(); err != nil {
+						errorLog.Log().Printf("cluster: failed to reload key states: %v", err)
+					}
+				default:
+					if err := enclaves.Reload(cmd.Key); err != nil {
+						errorLog.Log().Printf("cluster: failed to reload enclaves: %v", err)
+					}
+				}
+			case cluster.OpDelete:
+				if err := local.Delete(cmd.Key); err != nil {
+					return err
+				}
+				store.Evict(cmd.Key) // Another node may have deleted the key
+				enclaves.Evict(cmd.Key)
+			}
+			return nil
+		}
+		store.Remote = &cluster.Remote{Node: node, Remote: local}
+	}
+
+	// Policies and identity assignments changed at runtime are
+	// persisted at the key store. They take precedence over the
+	// policies and identities of the config file.
+	roles.Remote = store.Remote
+	enclaves.Remote = store.Remote
+	if roles.Approvals != nil {
+		roles.Approvals.Remote = store.Remote
+	}
+	if err = roles.Load(); err != nil {
+		return fmt.Errorf("Failed to load policies from %s: %v", keyStore, err)
+	}
+	if roles.Quotas != nil {
+		roles.Quotas.Remote = store.Remote
+		if err = roles.Quotas.Load(); err != nil {
+			return fmt.Errorf("Failed to load key quotas from %s: %v", keyStore, err)
+		}
+	}
+	if err = store.LoadUsage(); err != nil {
+		return fmt.Errorf("Failed to load key usage from %s: %v", keyStore, err)
+	}
+	if err = store.LoadAliases(); err != nil {
+		return fmt.Errorf("Failed to load key aliases from %s: %v", keyStore, err)
+	}
+	if err = store.LoadStates(); err != nil {
+		return fmt.Errorf("Failed to load key states from %s: %v", keyStore, err)
+	}
+	var requests *accounting.Accounting
+	if config.Accounting.Enabled {
+		requests = &accounting.Accounting{
+			Window:    config.Accounting.Window,
+			Retention: config.Accounting.Retention,
+			Remote:    store.Remote,
+		}
+		if err = requests.Load(); err != nil {
+			return fmt.Errorf("Failed to load request accounting from %s: %v", keyStore, err)
+		}
+	}
+	if validate {
+		fmt.Fprintln(cli.Output(), "The config file is valid")
+		return nil
+	}
+	if node != nil {
+		if err = node.Start(ctx); err != nil {
+			return fmt.Errorf("Failed to start cluster node: %v", err)
+		}
+	}
+
+	var tracer *trace.Tracer
+	if config.Trace.OTLP.Endpoint != "" {
+		tracer = &trace.Tracer{
+			Exporter: &trace.OTLP{
+				Endpoint:    config.Trace.OTLP.Endpoint,
+				ServiceName: config.Trace.OTLP.Service,
+			},
+			ErrorLog: errorLog.Log(),
+		}
+		tracer.StartExport(ctx, config.Trace.OTLP.Interval)
+	}
+
+	// streamCtx is done once the server starts to shut down.
+	// It ends long-running requests - like log traces - such
+	// that they don't block the shutdown.
+	streamCtx, cancelStreams := context.WithCancel(ctx)
+	defer cancelStreams()
 
 	const maxBody = 1 << 20
+	// The key, policy and identity APIs are served by the
+	// server itself and by each enclave.
+	handleEnclaveAPIs := func(mux *http.ServeMux, store *secret.Store, roles *auth.Roles) {
+		mux.Handle("/v1/key/create/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/create/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.Idempotent(idempotency, roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleCreateKey(store, roles)))))))))))))))))
+		mux.Handle("/v1/key/import/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/import/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.Idempotent(idempotency, roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleImportKey(store, roles)))))))))))))))))
+		mux.Handle("/v1/key/delete/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/key/delete/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.Idempotent(idempotency, roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleDeleteKey(store, roles))))))))))))))))
+		mux.Handle("/v1/key/disable/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/disable/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleDisableKey(store)))))))))))))))
+		mux.Handle("/v1/key/enable/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/enable/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleEnableKey(store)))))))))))))))
+		mux.Handle("/v1/key/hold/set/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/hold/set/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleHoldKey(store, roles))))))))))))))))
+		mux.Handle("/v1/key/hold/release/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/hold/release/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleReleaseKey(store, roles)))))))))))))))
+		mux.Handle("/v1/key/schedule-deletion/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/schedule-deletion/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleScheduleKeyDeletion(store, roles))))))))))))))))
+		mux.Handle("/v1/key/cancel-deletion/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/cancel-deletion/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleCancelKeyDeletion(store, roles)))))))))))))))
+		mux.Handle("/v1/key/generate/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/generate/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleGenerateKey(store))))))))))))))))
+		mux.Handle("/v1/key/encrypt/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/encrypt/**", xhttp.LimitRequestBody(maxBody/2, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleEncryptKey(store))))))))))))))))
+		mux.Handle("/v1/key/decrypt/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/decrypt/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleDecryptKey(store))))))))))))))))
+		mux.Handle("/v1/key/reencrypt/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/reencrypt/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleReEncryptKey(store, roles))))))))))))))))
+		mux.Handle("/v1/key/bulk/generate/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/bulk/generate/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleBulkGenerateKey(store))))))))))))))))
+		mux.Handle("/v1/key/bulk/decrypt/", timeout(15*time.Second, xhttp.SignResponse(responseSigner, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/bulk/decrypt/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleBulkDecryptKey(store))))))))))))))))
+		mux.Handle("/v1/key/bulk/delete/", timeout(time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/key/bulk/delete/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleBulkDeleteKey(store, roles)))))))))))))))
+		mux.Handle("/v1/key/list/", timeout(time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/key/list/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.ETag(xhttp.HandleListKeys(store, roles)))))))))))))))
+		mux.Handle("/v1/key/watch/", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/key/watch/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.EnforceTenancy(roles, xhttp.CancelOnDone(streamCtx, xhttp.HandleWatchKeys(store, roles)))))))))))))
+		mux.Handle("/v1/key/describe/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/key/describe/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.ETag(xhttp.HandleDescribeKey(store, roles)))))))))))))))
+		mux.Handle("/v1/key/provenance/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/key/provenance/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleKeyProvenance(store))))))))))))))
+		mux.Handle("/v1/key/alias/set/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/alias/set/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleSetAlias(store, roles)))))))))))))))
+		mux.Handle("/v1/key/alias/delete/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/key/alias/delete/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleDeleteAlias(store))))))))))))))
+		mux.Handle("/v1/key/alias/list/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/key/alias/list/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.ETag(xhttp.HandleListAliases(store, roles)))))))))))))))
+		mux.Handle("/v1/secret/create/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/secret/create/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleCreateSecret(store, roles, config.Secret.MaxSize<<10))))))))))))))))
+		mux.Handle("/v1/secret/get/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/secret/get/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleGetSecret(store))))))))))))))
+		mux.Handle("/v1/ca/create/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/ca/create/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleCreateCA(store, roles))))))))))))))))
+		mux.Handle("/v1/ca/sign/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/ca/sign/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleSignCertificate(store)))))))))))))))
+		mux.Handle("/v1/ca/chain/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/ca/chain/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleGetCAChain(store))))))))))))))
+		mux.Handle("/v1/jws/create/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/jws/create/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.LockPreconditions(xhttp.HandleCreateSigningKey(store, roles))))))))))))))))
+		mux.Handle("/v1/jws/sign/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/jws/sign/**", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleSignJWS(store)))))))))))))))
+		mux.Handle("/v1/jws/jwks/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/jws/jwks/**", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleGetJWKS(store))))))))))))))
+		if config.Data.Enabled {
+			maxSize := config.Data.MaxSize << 20
+			mux.Handle("/v1/data/encrypt/", timeout(5*time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/data/encrypt/**", xhttp.LimitRequestBody(maxSize, xhttp.RequireContentType("application/octet-stream", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleEncryptData(store, maxSize)))))))))))))))
+			mux.Handle("/v1/data/decrypt/", timeout(5*time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/data/decrypt/**", xhttp.LimitRequestBody(maxSize, xhttp.RequireContentType("application/octet-stream", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleDecryptData(store, maxSize)))))))))))))))
+		}
+
+		mux.Handle("/v1/policy/write/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/policy/write/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.LockPreconditions(xhttp.HandleWritePolicy(roles)))))))))))))))
+		mux.Handle("/v1/policy/read/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/policy/read/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.ETag(xhttp.HandleReadPolicy(roles)))))))))))))))
+		mux.Handle("/v1/policy/list/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/policy/list/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.ETag(xhttp.HandleListPolicies(roles)))))))))))))))
+		mux.Handle("/v1/policy/watch/", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/policy/watch/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.EnforceTenancy(roles, xhttp.CancelOnDone(streamCtx, xhttp.HandleWatchPolicies(roles)))))))))))))
+		mux.Handle("/v1/policy/delete/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/policy/delete/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.LockPreconditions(xhttp.HandleDeletePolicy(roles))))))))))))))
+		mux.Handle("/v1/policy/test", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/policy/test", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleTestPolicy(roles))))))))))))))
+
+		mux.Handle("/v1/identity/assign/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/identity/assign/*/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleAssignIdentity(roles))))))))))))))
+		mux.Handle("/v1/identity/list/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/identity/list/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleListIdentities(roles))))))))))))))
+		mux.Handle("/v1/identity/forget/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/identity/forget/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleForgetIdentity(roles)))))))))))))
+		mux.Handle("/v1/identity/renew/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/identity/renew/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleRenewIdentity(roles)))))))))))))
+
+		mux.Handle("/v1/group/write/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/group/write/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleWriteGroup(roles))))))))))))))
+		mux.Handle("/v1/group/read/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/group/read/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleReadGroup(roles)))))))))))))
+		mux.Handle("/v1/group/list/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/group/list/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleListGroups(roles)))))))))))))
+		mux.Handle("/v1/group/delete/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/group/delete/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleDeleteGroup(roles)))))))))))))
+		mux.Handle("/v1/group/add/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/group/add/*/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleAddGroupMember(roles)))))))))))))
+		mux.Handle("/v1/group/remove/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/group/remove/*/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleRemoveGroupMember(roles)))))))))))))
+	}
+	enclaves.NewHandler = func(e *xenclave.Enclave) http.Handler {
+		mux := http.NewServeMux()
+		handleEnclaveAPIs(mux, e.Store, e.Roles)
+		mux.Handle("/", timeout(10*time.Second, xhttp.EnforceHTTP2(xhttp.AuditLog(auditLog.Log(), e.Roles, xhttp.TLSProxy(proxy, http.NotFound)))))
+		return mux
+	}
+	if err = enclaves.Load(ctx); err != nil {
+		return fmt.Errorf("Failed to load enclaves from %s: %v", keyStore, err)
+	}
+
+	// Jobs are long-running operations - like deleting many
+	// keys - that run in the background. Unfinished jobs are
+	// resumed once the server starts again.
+	jobs := &xjob.Manager{
+		Remote: store.Remote,
+		Types: map[string]xjob.Func{
+			"delete-keys": xjob.DeleteKeys(store, roles, serverLock(xhttp.PreconditionLock)),
+		},
+		AuditLog: auditLog.Log(),
+	}
+	if store.KMS != nil {
+		jobs.Types["rewrap-keys"] = xjob.RewrapKeys(store, serverLock(xhttp.ExclusivePreconditionLock))
+	}
+	if config.Migration.Keys.count() > 0 {
+		target := serverConfig{Keys: config.Migration.Keys}
+		remote, _, _, err := newKeyStore(ctx, &target, errorLog, quiet)
+		if err != nil {
+			return fmt.Errorf("Failed to connect to migration key store: %v", err)
+		}
+		jobs.Types["migrate-keys"] = xjob.MigrateKeys(store, secret.EncodingRemote{
+			Remote:   remote,
+			Encoding: keyStoreEncoding(&target),
+		}, serverLock(xhttp.PreconditionLock))
+	}
+	if err = jobs.Start(ctx); err != nil {
+		return fmt.Errorf("Failed to load jobs from %s: %v", keyStore, err)
+	}
+
+	// The key usage is kept in memory and written to the key
+	// store in batches - such that the server does not write
+	// to the key store on every request.
+	go saveUsage(ctx, config.Usage.Interval, store, enclaves, requests, errorLog.Log())
+
+	// Keys scheduled for deletion are deleted once their
+	// deletion window has passed.
+	go deletePendingKeys(ctx, time.Hour, store, enclaves, roles, errorLog.Log())
+	if config.Usage.Metrics {
+		metrics.CounterVecFunc("kes_key_operations_total", "Number of requests that used a key by key and operation.", []string{"key", "op"}, func() []metric.Sample {
+			usages := store.Usages()
+			samples := make([]metric.Sample, 0, len(usages))
+			for _, name := range sortedKeys(usages) {
+				ops := make([]string, 0, len(usages[name].Ops))
+				for op := range usages[name].Ops {
+					ops = append(ops, op)
+				}
+				sort.Strings(ops)
+				for _, op := range ops {
+					samples = append(samples, metric.Sample{Labels: []string{name, op}, Value: float64(usages[name].Ops[op])})
+				}
+			}
+			return samples
+		})
+		metrics.GaugeVecFunc("kes_key_last_used_timestamp_seconds", "Unix time when a key has been used the last time.", []string{"key"}, func() []metric.Sample {
+			usages := store.Usages()
+			samples := make([]metric.Sample, 0, len(usages))
+			for _, name := range sortedKeys(usages) {
+				samples = append(samples, metric.Sample{Labels: []string{name}, Value: float64(usages[name].LastUsed.Unix())})
+			}
+			return samples
+		})
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/v1/key/create/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/create/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleCreateKey(store))))))))))
-	mux.Handle("/v1/key/import/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/import/*", xhttp.LimitRequestBody(maxBody, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleImportKey(store))))))))))
-	mux.Handle("/v1/key/delete/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/key/delete/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleDeleteKey(store))))))))))
-	mux.Handle("/v1/key/generate/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/generate/*", xhttp.LimitRequestBody(maxBody, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleGenerateKey(store))))))))))
-	mux.Handle("/v1/key/encrypt/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/encrypt/*", xhttp.LimitRequestBody(maxBody/2, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleEncryptKey(store))))))))))
-	mux.Handle("/v1/key/decrypt/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/key/decrypt/*", xhttp.LimitRequestBody(maxBody, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleDecryptKey(store))))))))))
+	handleEnclaveAPIs(mux, store, roles)
+
+	mux.Handle("/v1/identity/usage/", timeout(15*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/identity/usage/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.EnforceTenancy(roles, xhttp.HandleIdentityUsage(requests, roles))))))))))))))
+
+	mux.Handle("/v1/quota/list", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/quota/list", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleListQuotas(roles)))))))))))))
+	mux.Handle("/v1/quota/set/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/quota/set/*/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleSetQuota(roles)))))))))))))
+
+	mux.Handle("/v1/log/audit/trace", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/log/audit/trace", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.CancelOnDone(streamCtx, xhttp.HandleTraceAuditLog(auditLog))))))))))))
+	mux.Handle("/v1/log/error/trace", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/log/error/trace", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.CancelOnDone(streamCtx, xhttp.HandleTraceErrorLog(errorLog))))))))))))
 
-	mux.Handle("/v1/policy/write/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/policy/write/*", xhttp.LimitRequestBody(maxBody, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleWritePolicy(roles))))))))))
-	mux.Handle("/v1/policy/read/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/policy/read/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleReadPolicy(roles))))))))))
-	mux.Handle("/v1/policy/list/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/policy/list/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleListPolicies(roles))))))))))
-	mux.Handle("/v1/policy/delete/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/policy/delete/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleDeletePolicy(roles))))))))))
+	mux.Handle("/v1/admin/backup", timeout(5*time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/admin/backup", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleBackup(store, roles)))))))))))))
+	mux.Handle("/v1/admin/restore", timeout(5*time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/admin/restore", xhttp.LimitRequestBody(64<<20, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.LockPreconditions(xhttp.HandleRestore(store, roles)))))))))))))))
+	mux.Handle("/v1/admin/escrow", timeout(5*time.Minute, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/admin/escrow", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleEscrow(store, escrowCustodians, config.Escrow.Threshold, escrowKey))))))))))))))
 
-	mux.Handle("/v1/identity/assign/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/identity/assign/*/*", xhttp.LimitRequestBody(maxBody, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleAssignIdentity(roles))))))))))
-	mux.Handle("/v1/identity/list/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/identity/list/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleListIdentities(roles))))))))))
-	mux.Handle("/v1/identity/forget/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/identity/forget/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleForgetIdentity(roles))))))))))
+	mux.Handle("/v1/approval/issue", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/approval/issue", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleIssueApproval(roles))))))))))))))
+	mux.Handle("/v1/session/issue", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/session/issue", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleIssueSession(roles))))))))))))))
 
-	mux.Handle("/v1/log/audit/trace", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/log/audit/trace", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleTraceAuditLog(auditLog)))))))))
-	mux.Handle("/v1/log/error/trace", xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/log/error/trace", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.EnforcePolicies(roles, xhttp.HandleTraceErrorLog(errorLog)))))))))
+	mux.Handle("/v1/enclave/create/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/enclave/create/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleCreateEnclave(enclaves))))))))))))))
+	mux.Handle("/v1/enclave/delete/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/enclave/delete/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.LockPreconditions(xhttp.HandleDeleteEnclave(enclaves))))))))))))))
+	mux.Handle("/v1/enclave/list", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/enclave/list", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleListEnclaves(enclaves)))))))))))))
 
+	mux.Handle("/v1/job/submit/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/job/submit/*", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleSubmitJob(jobs, roles))))))))))))))
+	mux.Handle("/v1/job/status/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/job/status/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleJobStatus(jobs)))))))))))))
+	mux.Handle("/v1/job/list", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/job/list", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleListJobs(jobs)))))))))))))
+	mux.Handle("/v1/job/cancel/", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodDelete, xhttp.ValidatePath("/v1/job/cancel/*", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleCancelJob(jobs)))))))))))))
+
+	mux.Handle("/v1/metrics", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/metrics", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleMetrics(metrics)))))))))))))
+	mux.Handle("/v1/status", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/status", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleStatus(version, startTime, store)))))))))))))
+
+	if barrier != nil {
+		mux.Handle("/v1/seal/status", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/seal/status", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleSealStatus(barrier)))))))))))))
+		mux.Handle("/v1/seal/init", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/seal/init", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleInitSeal(barrier))))))))))))))
+		mux.Handle("/v1/seal/unseal", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/seal/unseal", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleUnseal(barrier))))))))))))))
+	}
+
+	if node != nil {
+		mux.Handle("/v1/cluster/status", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/cluster/status", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleClusterStatus(node)))))))))))))
+
+		// The Raft messages are sent by other cluster nodes only.
+		// Therefore, they are neither audited nor rate-limited.
+		mux.Handle("/v1/cluster/vote", timeout(10*time.Second, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/cluster/vote", xhttp.LimitRequestBody(1<<20, xhttp.RequireContentType("application/json", xhttp.EnforceClusterPeer(node, roles, xhttp.HandleClusterVote(node)))))))))
+		mux.Handle("/v1/cluster/append", timeout(10*time.Second, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/cluster/append", xhttp.LimitRequestBody(8<<20, xhttp.RequireContentType("application/json", xhttp.EnforceClusterPeer(node, roles, xhttp.HandleClusterAppend(node)))))))))
+		mux.Handle("/v1/cluster/propose", timeout(15*time.Second, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/cluster/propose", xhttp.LimitRequestBody(2<<20, xhttp.RequireContentType("application/json", xhttp.EnforceClusterPeer(node, roles, xhttp.HandleClusterPropose(node)))))))))
+	}
 	mux.Handle("/version", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/version", xhttp.LimitRequestBody(0, xhttp.TLSProxy(proxy, xhttp.HandleVersion(version))))))))) // /version is accessible to any identity
+	mux.Handle("/v1/ready", timeout(10*time.Second, xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/ready", xhttp.LimitRequestBody(0, xhttp.HandleReady(store, 5*time.Second))))))                                                                      // The probes are accessible to any client - even via HTTP/1.1
+	mux.Handle("/v1/live", timeout(10*time.Second, xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/live", xhttp.LimitRequestBody(0, xhttp.HandleLive())))))
 	mux.Handle("/", timeout(10*time.Second, xhttp.EnforceHTTP2(xhttp.AuditLog(auditLog.Log(), roles, xhttp.TLSProxy(proxy, http.NotFound)))))
 
 	server := http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:        addr,
+		Handler:     xhttp.ObserveMetrics(metrics, xhttp.LimitMemory(memoryLimiter, xhttp.AccountRequests(requests, roles.Identify, xhttp.Trace(tracer, xhttp.RequireUnsealed(barrier, xhttp.SelectEnclave(enclaves, mux)))))),
+		ConnState:   metrics.ConnState,
+		ConnContext: auth.NewConnContext, // Compute the client identity once per connection
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS13,
 		},
 		ErrorLog: errorLog.Log(),
 
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 0 * time.Second, // explicitly set no write timeout - see timeout handler.
+		// By default, there is no write timeout since each
+		// handler has its own timeout - see timeout handler.
+		ReadTimeout:       config.HTTP.Timeout.Read,
+		ReadHeaderTimeout: config.HTTP.Timeout.Header,
+		WriteTimeout:      config.HTTP.Timeout.Write,
+		IdleTimeout:       config.HTTP.Timeout.Idle,
+		MaxHeaderBytes:    config.HTTP.Header.MaxSize << 10,
 	}
-	switch strings.ToLower(mtlsAuth) {
-	case "on":
-		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
-	case "off":
-		server.TLSConfig.ClientAuth = tls.RequireAnyClientCert
-	default:
+	if err = http2.ConfigureServer(&server, &http2.Server{
+		MaxConcurrentStreams: config.HTTP.HTTP2.MaxStreams,
+	}); err != nil {
+		return fmt.Errorf("Failed to configure HTTP/2: %v", err)
+	}
+
+	// If the server sits behind a load balancer - like HAProxy or
+	// an AWS NLB - the load balancer may pass the client address
+	// via the PROXY protocol or the X-Forwarded-For header. Both
+	// are only accepted from trusted proxies. Otherwise, a client
+	// could spoof its address.
+	trustedProxies, _ := parseTrustedProxies(config.HTTP.Proxy.Trusted) // The proxies have been verified already
+	if config.HTTP.Proxy.Forwarded {
+		server.Handler = xhttp.ForwardedFor(trustedProxies, server.Handler)
+	}
+	proxyListener := func(listener net.Listener) net.Listener {
+		if !config.HTTP.Proxy.Protocol {
+			return listener
+		}
+		return &proxyproto.Listener{
+			Listener: listener,
+			Trusted:  trustedProxies,
+			ErrorLog: errorLog.Log(),
+		}
+	}
+	// LDAP users authenticate with a username and password
+	// and OIDC clients and sessions with a bearer token.
+	// Therefore, clients may connect without a certificate.
+	certless := roles.LDAP != nil || roles.OIDC != nil || roles.Sessions != nil
+	clientAuth, ok := clientAuthType(mtlsAuth, certless)
+	if !ok {
 		return fmt.Errorf("Invalid option for --auth: %s", mtlsAuth)
 	}
+	server.TLSConfig.ClientAuth = clientAuth
+
+	// Clients can resume a TLS session via a session ticket instead
+	// of performing a full handshake. If there is an enclave key,
+	// clients can resume a session established with any server
+	// sharing the enclave key. TLS 1.3 session resumption performs
+	// a (EC)DHE key exchange. Therefore, the ticket keys don't
+	// weaken forward secrecy.
+	ticketKeys := &xhttp.SessionTicketKeys{
+		Rotation: config.TLS.Tickets.Rotation,
+		ErrorLog: errorLog.Log(),
+	}
+	if enclaveKey != nil {
+		ticketKeys.Secret = enclaveKey.DeriveKey("kes tls session ticket key", 32)
+	}
+	if revocation != nil && config.TLS.Revocation.Cache > 0 && ticketKeys.Rotation > config.TLS.Revocation.Cache/2 {
+		// A resumed session skips the revocation check. Hence, a
+		// session ticket must not be valid for longer than a cached
+		// revocation status.
+		ticketKeys.Rotation = config.TLS.Revocation.Cache / 2
+	}
+	if err = ticketKeys.Rotate(ctx, server.TLSConfig); err != nil {
+		return fmt.Errorf("Failed to create TLS session ticket keys: %v", err)
+	}
+	if revocation != nil {
+		// Reject clients with a revoked certificate during
+		// the TLS handshake.
+		server.TLSConfig.VerifyPeerCertificate = revocation.VerifyPeerCertificate
+	}
+	var reloadCertificates []func() error
+	if len(config.TLS.ACME.Domains) == 0 {
+		certificate, err := xhttp.LoadCertificate(tlsCertPath, tlsKeyPath)
+		if err != nil {
+			return fmt.Errorf("Failed to load TLS certificate: %v", err)
+		}
+		certificate.ErrorLog = errorLog.Log()
+		certificate.ReloadAfter(ctx, config.TLS.Reload)
+		server.TLSConfig.GetCertificate = certificate.GetCertificate
+		reloadCertificates = append(reloadCertificates, certificate.Reload)
+	} else {
+		manager, err := newACMEManager(&config)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig.GetCertificate = manager.GetCertificate
+
+		// The ACME server verifies the domains via the tls-alpn-01
+		// challenge. It does not send a client certificate. Therefore,
+		// we accept challenge connections without client certificate.
+		// These connections cannot be used to send requests.
+		challengeConfig := &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: manager.GetCertificate,
+			NextProtos:     []string{acme.ALPNProto},
+		}
+		server.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+				return challengeConfig, nil
+			}
+			return nil, nil
+		}
+		if config.TLS.ACME.HTTP != "" { // Serve the http-01 challenge
+			challengeServer := &http.Server{
+				Addr:     config.TLS.ACME.HTTP,
+				Handler:  manager.HTTPHandler(nil),
+				ErrorLog: errorLog.Log(),
+			}
+			server.RegisterOnShutdown(func() { challengeServer.Close() })
+			go func() {
+				if err := challengeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					errorLog.Log().Printf("http: failed to serve ACME HTTP challenges: %v", err)
+				}
+			}()
+		}
+	}
+
+	if provider := newAttestationProvider(&config); provider != nil {
+		// The attestation binds the TLS certificate of the server.
+		// Therefore, its API is registered once the certificate
+		// has been loaded.
+		mux.Handle("/v1/attest", timeout(10*time.Second, xhttp.AuditLog(auditLog.Log(), roles, xhttp.EnforceHTTP2(xhttp.RequireMethod(http.MethodPost, xhttp.ValidatePath("/v1/attest", xhttp.LimitRequestBody(maxBody, xhttp.RequireContentType("application/json", xhttp.TLSProxy(proxy, xhttp.VerifySignature(verifier, xhttp.EnforcePolicies(roles, xhttp.LimitRate(limiter, roles, xhttp.LimitConcurrency(concurrency, roles, xhttp.HandleAttest(provider, server.TLSConfig.GetCertificate))))))))))))))
+	}
+
+	if config.Probe.Addr != "" {
+		// Kubernetes probes neither send a client certificate nor
+		// support HTTP/2. Therefore, we serve them separately via
+		// plain HTTP such that the server can require mTLS.
+		probeMux := http.NewServeMux()
+		probeMux.Handle("/v1/ready", xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/ready", xhttp.LimitRequestBody(0, xhttp.HandleReady(store, 5*time.Second)))))
+		probeMux.Handle("/v1/live", xhttp.RequireMethod(http.MethodGet, xhttp.ValidatePath("/v1/live", xhttp.LimitRequestBody(0, xhttp.HandleLive()))))
+		probeServer := &http.Server{
+			Addr:         config.Probe.Addr,
+			Handler:      probeMux,
+			ErrorLog:     errorLog.Log(),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		server.RegisterOnShutdown(func() { probeServer.Close() })
+		go func() {
+			if err := probeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errorLog.Log().Printf("http: failed to serve probes: %v", err)
+			}
+		}()
+	}
+
+	if config.KMIP.Addr != "" {
+		// Many KMIP clients do not support TLS 1.3 yet.
+		// The ACME tls-alpn-01 challenge is only served
+		// by the HTTPS server.
+		kmipConfig := server.TLSConfig.Clone()
+		kmipConfig.MinVersion = tls.VersionTLS12
+		kmipConfig.GetConfigForClient = nil
+		kmipConfig.NextProtos = nil // KMIP is not served via HTTP/2
+		kmipServer := &kmip.Server{
+			Addr:      config.KMIP.Addr,
+			Store:     store,
+			Roles:     roles,
+			TLSConfig: kmipConfig,
+			ErrorLog:  errorLog.Log(),
+			Lock:      serverLock(xhttp.PreconditionLock),
+		}
+		server.RegisterOnShutdown(func() { kmipServer.Close() })
+		go func() {
+			if err := kmipServer.ListenAndServe(); err != nil && err != kmip.ErrServerClosed {
+				errorLog.Log().Printf("kmip: failed to serve KMIP requests: %v", err)
+			}
+		}()
+	}
+
+	if config.GRPC.Addr != "" {
+		// The ACME tls-alpn-01 challenge is only served
+		// by the HTTPS server. The gRPC server negotiates
+		// HTTP/2 via ALPN itself.
+		grpcConfig := server.TLSConfig.Clone()
+		grpcConfig.GetConfigForClient = nil
+		grpcConfig.NextProtos = nil
+		grpcServer := &xgrpc.Server{
+			Addr:      config.GRPC.Addr,
+			Handler:   server.Handler,
+			TLSConfig: grpcConfig,
+		}
+		server.RegisterOnShutdown(func() { grpcServer.Close() })
+		go func() {
+			if err := grpcServer.ListenAndServe(); err != nil && err != xgrpc.ErrServerClosed {
+				errorLog.Log().Printf("grpc: failed to serve gRPC requests: %v", err)
+			}
+		}()
+	}
+
+	// If the server has been started via systemd socket activation,
+	// it serves HTTPS via the passed TCP sockets - instead of
+	// listening on the address - and the API via the passed Unix
+	// domain sockets.
+	sdListeners, err := systemdListeners()
+	if err != nil {
+		return fmt.Errorf("Failed to use systemd sockets: %v", err)
+	}
+	var tcpListeners, unixListeners []net.Listener
+	for _, listener := range sdListeners {
+		if listener.Addr().Network() == "unix" {
+			unixListeners = append(unixListeners, listener)
+		} else {
+			tcpListeners = append(tcpListeners, listener)
+		}
+	}
+	if len(tcpListeners) > 0 {
+		addr = tcpListeners[0].Addr().String()
+	} else if config.HTTP.ReusePort {
+		if tcpListeners, err = listenReusePort(addr, runtime.GOMAXPROCS(0)); err != nil {
+			return fmt.Errorf("Failed to listen on '%s': %v", addr, err)
+		}
+	} else if config.HTTP.Proxy.Protocol {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on '%s': %v", addr, err)
+		}
+		tcpListeners = append(tcpListeners, listener)
+	}
+	for i := range tcpListeners {
+		tcpListeners[i] = proxyListener(tcpListeners[i])
+	}
+	if config.Unix.Path != "" {
+		mode, _ := strconv.ParseUint(config.Unix.Mode, 8, 32) // The mode has been verified already
+		listener, err := listenUnix(config.Unix.Path, os.FileMode(mode))
+		if err != nil {
+			return fmt.Errorf("Failed to listen on Unix socket: %v", err)
+		}
+		unixListeners = append(unixListeners, listener)
+	}
+
+	var unixServer *http.Server
+	if len(unixListeners) > 0 {
+		// Processes connected via a Unix domain socket don't use TLS.
+		// Instead, they are identified by the user ID provided by the
+		// OS - i.e. "unix:<uid>". Requests are served via unencrypted
+		// HTTP/2 (h2c) since the API requires HTTP/2.
+		unixServer = &http.Server{
+			Handler: h2c.NewHandler(server.Handler, &http2.Server{
+				MaxConcurrentStreams: config.HTTP.HTTP2.MaxStreams,
+				IdleTimeout:          config.HTTP.Timeout.Idle,
+			}),
+			ConnState: metrics.ConnState,
+			ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+				peer, err := auth.PeerOf(conn)
+				if err != nil {
+					errorLog.Log().Printf("http: failed to get peer credentials: %v", err)
+					return ctx // Requests without peer credentials are rejected
+				}
+				return auth.NewPeerContext(ctx, peer)
+			},
+			ErrorLog: errorLog.Log(),
+
+			ReadTimeout:       config.HTTP.Timeout.Read,
+			ReadHeaderTimeout: config.HTTP.Timeout.Header,
+			WriteTimeout:      config.HTTP.Timeout.Write,
+			IdleTimeout:       config.HTTP.Timeout.Idle,
+			MaxHeaderBytes:    config.HTTP.Header.MaxSize << 10,
+		}
+		for _, listener := range unixListeners {
+			go func(listener net.Listener) {
+				if err := unixServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+					errorLog.Log().Printf("http: failed to serve Unix socket '%v': %v", listener.Addr(), err)
+				}
+			}(listener)
+		}
+	}
+
+	// Additional listeners serve the API at other addresses - e.g.
+	// the admin APIs on localhost and the data plane APIs on the pod
+	// IP. Each listener may have its own TLS certificate, client
+	// authentication and API policy.
+	listenerServers := make([]*http.Server, 0, len(config.Listeners))
+	for _, l := range config.Listeners {
+		if l.Addr == "" {
+			continue
+		}
+		tlsConfig := server.TLSConfig.Clone()
+		if l.TLS.Auth != "" {
+			tlsConfig.ClientAuth, _ = clientAuthType(l.TLS.Auth, certless) // The auth mode has been verified already
+		}
+		if l.TLS.CertPath != "" {
+			certificate, err := xhttp.LoadCertificate(l.TLS.CertPath, l.TLS.KeyPath)
+			if err != nil {
+				return fmt.Errorf("Failed to load TLS certificate of listener '%s': %v", l.Addr, err)
+			}
+			certificate.ErrorLog = errorLog.Log()
+			certificate.ReloadAfter(ctx, config.TLS.Reload)
+			tlsConfig.GetCertificate = certificate.GetCertificate
+			tlsConfig.GetConfigForClient = nil // ACME challenges are served by the main listener
+			reloadCertificates = append(reloadCertificates, certificate.Reload)
+		}
+		if err = ticketKeys.Rotate(ctx, tlsConfig); err != nil {
+			return fmt.Errorf("Failed to create TLS session ticket keys: %v", err)
+		}
+		policy, _ := parseListenerPolicy(l.Policy.Paths, l.Policy.Deny) // The policy has been verified already
+
+		listenerServer := &http.Server{
+			Addr:        l.Addr,
+			Handler:     xhttp.RestrictAPIs(policy, server.Handler),
+			ConnState:   metrics.ConnState,
+			ConnContext: auth.NewConnContext,
+			TLSConfig:   tlsConfig,
+			ErrorLog:    errorLog.Log(),
+
+			ReadTimeout:       config.HTTP.Timeout.Read,
+			ReadHeaderTimeout: config.HTTP.Timeout.Header,
+			WriteTimeout:      config.HTTP.Timeout.Write,
+			IdleTimeout:       config.HTTP.Timeout.Idle,
+			MaxHeaderBytes:    config.HTTP.Header.MaxSize << 10,
+		}
+		if err = http2.ConfigureServer(listenerServer, &http2.Server{
+			MaxConcurrentStreams: config.HTTP.HTTP2.MaxStreams,
+		}); err != nil {
+			return fmt.Errorf("Failed to configure HTTP/2: %v", err)
+		}
+		listener, err := net.Listen("tcp", l.Addr)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on '%s': %v", l.Addr, err)
+		}
+		listener = proxyListener(listener)
+		go func(listener net.Listener) {
+			if err := listenerServer.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+				errorLog.Log().Printf("http: failed to serve HTTPS at '%v': %v", listener.Addr(), err)
+			}
+		}(listener)
+		listenerServers = append(listenerServers, listenerServer)
+	}
 
+	var reloader *configReloader
+	if configPath != "" {
+		if reloader, err = newConfigReloader(configPath, config, auditSinks); err != nil {
+			return fmt.Errorf("Cannot watch config file: %v", err)
+		}
+		reloader.Root = kes.Identity(rootIdentity)
+		reloader.Roles = roles
+		reloader.Proxy = proxy
+		reloader.Store = store
+		reloader.Limiter = limiter
+		reloader.Concurrency = concurrency
+		reloader.AuditLog = auditLog
+		reloader.EnclaveKey = enclaveKey
+		reloader.ErrorLog = errorLog.Log()
+		reloader.ReloadAfter(ctx, 10*time.Second)
+	}
+
+	// On SIGHUP, reload the certificate - e.g. after it has been
+	// rotated - and the config file. Established connections are
+	// not affected.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			for _, reloadCertificate := range reloadCertificates {
+				if err := reloadCertificate(); err != nil {
+					errorLog.Log().Printf("http: failed to reload TLS certificate: %v", err)
+				}
+			}
+			if reloader != nil {
+				if err := reloader.Reload(); err != nil {
+					errorLog.Log().Printf("Failed to reload config file '%s': %v", configPath, err)
+				}
+			}
+		}
+	}()
+
+	// On SIGINT or SIGTERM, the server stops accepting new
+	// connections and waits until all in-flight requests have
+	// completed - but at most until the shutdown timeout has
+	// passed. Then, it closes all remaining connections.
+	server.RegisterOnShutdown(cancelStreams)
+	shutdownCh := make(chan struct{})
 	sigCh := make(chan os.Signal)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
+		defer close(shutdownCh)
 		<-sigCh
 
-		shutdownContext, cancelShutdown := context.WithDeadline(context.Background(), time.Now().Add(800*time.Millisecond))
+		shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), config.Shutdown.Timeout)
 		err := server.Shutdown(shutdownContext)
+		if unixServer != nil {
+			if unixErr := unixServer.Shutdown(shutdownContext); err == nil {
+				err = unixErr
+			}
+		}
+		for _, listenerServer := range listenerServers {
+			if listenerErr := listenerServer.Shutdown(shutdownContext); err == nil {
+				err = listenerErr
+			}
+		}
 		if cancelShutdown(); err == context.DeadlineExceeded {
+			errorLog.Log().Printf("http: shutdown timeout of %v exceeded: closing remaining connections", config.Shutdown.Timeout)
 			err = server.Close()
+			if unixServer != nil {
+				unixServer.Close()
+			}
+			for _, listenerServer := range listenerServers {
+				listenerServer.Close()
+			}
 		}
 		if err != nil {
 			fmt.Fprintf(cli.Output(), "Abnormal server shutdown: %v\n", err)
 		}
 	}()
 
+	// If the server runs as Windows service, the service control
+	// manager stops the server, or reloads its config, via the
+	// signal channels.
+	stopService, err := startService(hupCh, sigCh)
+	if err != nil {
+		return fmt.Errorf("Cannot start Windows service: %v", err)
+	}
+	defer stopService()
+
 	// The following code prints a server startup message similar to:
 	//
 	// Endpoint: https://127.0.0.1:7373        https://192.168.161.34:7373
@@ -426,10 +1433,20 @@ func server(args []string) error {
 
 	const margin = 10 // len("Endpoint: ")
 	quiet.Print(blue.Sprint("Endpoint: "))
-	quiet.Println(bold.Sprint(alignEndpoints(margin, interfaceIP4Addrs(), port)))
+	endpointIPs := interfaceIP4Addrs()
+	if ip.To4() == nil { // The server listens on an IPv6 address - i.e. dual-stack if unspecified
+		endpointIPs = append(endpointIPs, interfaceIP6Addrs()...)
+	}
+	quiet.Println(bold.Sprint(alignEndpoints(margin, endpointIPs, port)))
+	for _, listener := range unixListeners {
+		quiet.Println(blue.Sprint("Unix:    "), bold.Sprint(listener.Addr()))
+	}
+	for _, listenerServer := range listenerServers {
+		quiet.Println(blue.Sprint("Listener:"), bold.Sprint("https://"+listenerServer.Addr))
+	}
 	quiet.Println()
 
-	if r, err := hex.DecodeString(rootIdentity); err == nil && len(r) == sha256.Size {
+	if r, err := hex.DecodeString(rootIdentity); (err == nil && len(r) == sha256.Size) || strings.HasPrefix(rootIdentity, "unix:") {
 		quiet.Println(blue.Sprint("Root:    "), rootIdentity)
 	} else {
 		quiet.Println(blue.Sprint("Root:    "), "_     [ disabled ]")
@@ -442,27 +1459,184 @@ func server(args []string) error {
 	quiet.Println()
 
 	quiet.Println(blue.Sprint("Keys:    "), fmt.Sprintf("%s: %s", keyStore, keyStoreEndpoint))
+	if node != nil {
+		quiet.Println(blue.Sprint("Cluster: "), fmt.Sprintf("%s  [ %d nodes ]", node.ID, len(node.Peers)))
+	}
+	if kmsName != "" {
+		quiet.Println(blue.Sprint("KMS:     "), fmt.Sprintf("%s: %s", kmsName, kmsEndpoint))
+	}
+	if barrier != nil {
+		switch state := barrier.State(); {
+		case state.Initialized && !state.Sealed:
+			quiet.Println(blue.Sprint("Seal:    "), "unsealed", fmt.Sprintf("  [ auto-unseal via %s ]", kmsName))
+		case state.Initialized && autoUnseal != nil:
+			quiet.Println(blue.Sprint("Seal:    "), color.New(color.Bold, color.FgYellow).Sprint("sealed"), color.YellowString("  [ auto-unseal failed: %v - submit %d of %d key shares via 'kes seal unseal' ]", autoUnseal, state.Threshold, state.Shares))
+		case state.Initialized:
+			quiet.Println(blue.Sprint("Seal:    "), color.New(color.Bold, color.FgYellow).Sprint("sealed"), color.YellowString("  [ submit %d of %d key shares via 'kes seal unseal' ]", state.Threshold, state.Shares))
+		default:
+			quiet.Println(blue.Sprint("Seal:    "), color.New(color.Bold, color.FgYellow).Sprint("not initialized"), color.YellowString("  [ initialize the seal via 'kes seal init' ]"))
+		}
+	}
 	quiet.Println()
 
 	if runtime.GOOS == "windows" {
-		quiet.Println(blue.Sprint("CLI:     "), bold.Sprintf("set KES_SERVER=https://%v:%s", ip, port))
+		quiet.Println(blue.Sprint("CLI:     "), bold.Sprintf("set KES_SERVER=https://%s", net.JoinHostPort(ip.String(), port)))
 		quiet.Println("         ", bold.Sprint("set KES_CLIENT_KEY=")+italic.Sprint("<client-private-key>")+`   // e.g. root.key`)
 		quiet.Println("         ", bold.Sprint("set KES_CLIENT_CERT=")+italic.Sprint("<client-certificate>")+`  // e.g. root.cert`)
 		quiet.Println("         ", bold.Sprint("kes --help"))
 	} else {
-		quiet.Println(blue.Sprint("CLI:     "), bold.Sprintf("export KES_SERVER=https://%v:%s", ip, port))
+		quiet.Println(blue.Sprint("CLI:     "), bold.Sprintf("export KES_SERVER=https://%s", net.JoinHostPort(ip.String(), port)))
 		quiet.Println("         ", bold.Sprint("export KES_CLIENT_KEY=")+italic.Sprint("<client-private-key>")+"   // e.g. $HOME/root.key")
 		quiet.Println("         ", bold.Sprint("export KES_CLIENT_CERT=")+italic.Sprint("<client-certificate>")+"  // e.g. $HOME/root.cert")
 		quiet.Println("         ", bold.Sprint("kes --help"))
 	}
 
-	// Start the HTTPS server
-	if err := server.ListenAndServeTLS(tlsCertPath, tlsKeyPath); err != http.ErrServerClosed {
+	// Start the HTTPS server. The certificate is provided by the TLS config.
+	if len(tcpListeners) == 0 {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		for _, listener := range tcpListeners[1:] {
+			go func(listener net.Listener) {
+				if err := server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+					errorLog.Log().Printf("http: failed to serve HTTPS at '%v': %v", listener.Addr(), err)
+				}
+			}(listener)
+		}
+		err = server.ServeTLS(tcpListeners[0], "", "")
+	}
+	if err != http.ErrServerClosed {
 		return fmt.Errorf("Cannot start server: %v", err)
 	}
+	<-shutdownCh // Wait until all in-flight requests have completed
+
+	// Once no request is in-flight anymore, we stop all background
+	// tasks, flush the remaining trace spans and audit events and
+	// close the connections to the key store and KMS.
+	cancelCtx()
+	if err := store.SaveUsage(); err != nil {
+		errorLog.Log().Printf("Failed to save key usage: %v", err)
+	}
+	if err := enclaves.SaveUsage(); err != nil {
+		errorLog.Log().Printf("Failed to save key usage: %v", err)
+	}
+	if requests != nil {
+		if err := requests.Save(); err != nil {
+			errorLog.Log().Printf("Failed to save request accounting: %v", err)
+		}
+	}
+	if tracer != nil {
+		if err := tracer.Flush(); err != nil {
+			errorLog.Log().Printf("trace: failed to flush spans: %v", err)
+		}
+	}
+	if reloader != nil {
+		auditSinks = reloader.AuditSinks()
+	}
+	for _, sink := range auditSinks {
+		auditLog.RemoveOutput(sink)
+	}
+	closeAuditSinks(auditSinks)
+	for _, client := range []interface{}{store.Remote, store.KMS} {
+		if closer, ok := client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errorLog.Log().Printf("Failed to close connection to %s: %v", keyStore, err)
+			}
+		}
+	}
 	return nil
 }
 
+// newACMEManager returns an ACME certificate manager that
+// obtains and renews the server certificate for the
+// configured domains.
+func newACMEManager(config *serverConfig) (*autocert.Manager, error) {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.TLS.ACME.Domains...),
+		Email:      config.TLS.ACME.Email,
+		Client: &acme.Client{
+			DirectoryURL: config.TLS.ACME.Directory,
+		},
+	}
+	if manager.Client.DirectoryURL == "" {
+		manager.Client.DirectoryURL = acme.LetsEncryptURL
+	}
+	if config.TLS.ACME.Cache != "" {
+		manager.Cache = autocert.DirCache(config.TLS.ACME.Cache)
+	}
+	if config.TLS.ACME.CAPath != "" { // An internal ACME server - e.g. with a private CA
+		caCerts, err := ioutil.ReadFile(config.TLS.ACME.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read ACME CA certificates: %v", err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("Failed to parse ACME CA certificates: '%s' contains no PEM-encoded certificate", config.TLS.ACME.CAPath)
+		}
+		manager.Client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: rootCAs},
+			},
+		}
+	}
+	return manager, nil
+}
+
+// newClusterNode returns a new cluster node for the
+// cluster specified in the config. The node uses the
+// TLS certificate of the server as client certificate
+// when sending requests to other cluster nodes.
+func newClusterNode(ctx context.Context, config *serverConfig, certPath, keyPath string, errorLog *stdlog.Logger) (*cluster.Node, error) {
+	certificate, err := xhttp.LoadCertificate(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load TLS certificate: %v", err)
+	}
+	certificate.ErrorLog = errorLog
+	certificate.ReloadAfter(ctx, config.TLS.Reload)
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certificate.GetCertificate(nil)
+		},
+	}
+	if config.Cluster.CAPath != "" {
+		caCerts, err := ioutil.ReadFile(config.Cluster.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read cluster CA certificates: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("Failed to parse cluster CA certificates: '%s' contains no PEM-encoded certificate", config.Cluster.CAPath)
+		}
+	}
+
+	node := &cluster.Node{
+		ID:  config.Cluster.Node,
+		Dir: config.Cluster.Dir,
+		Transport: &cluster.HTTPTransport{
+			Client: &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig:   tlsConfig,
+					ForceAttemptHTTP2: true,
+					MaxIdleConns:      10,
+					IdleConnTimeout:   90 * time.Second,
+				},
+			},
+		},
+		ErrorLog: errorLog,
+	}
+	for id, peer := range config.Cluster.Peers {
+		node.Peers = append(node.Peers, cluster.Peer{
+			ID:       id,
+			Addr:     peer.Addr,
+			Identity: peer.Identity,
+		})
+	}
+	return node, nil
+}
+
 // quiet is a boolean flag.Value that can print
 // to STDOUT.
 //
@@ -582,8 +1756,11 @@ func alignEndpoints(leftMargin int, IPs []net.IP, port string) string {
 		n         int
 	)
 	for _, ip := range IPs {
-		endpoint := fmt.Sprintf("https://%v:%s", ip, port)
-		endpoint += strings.Repeat(" ", 2+maxEndpointSize-len(endpoint)) // pad with white spaces
+		endpoint := "https://" + net.JoinHostPort(ip.String(), port)
+		if len(endpoint) < maxEndpointSize {
+			endpoint += strings.Repeat(" ", maxEndpointSize-len(endpoint)) // pad with white spaces
+		}
+		endpoint += "  "
 		if n == 2 {
 			endpoints += "\n" + strings.Repeat(" ", leftMargin)
 			n = 0
@@ -594,6 +1771,80 @@ func alignEndpoints(leftMargin int, IPs []net.IP, port string) string {
 	return endpoints
 }
 
+// saveUsage writes the key usage of the server and all
+// enclaves and, if not nil, the request accounting to the
+// key store every interval until ctx is done.
+func saveUsage(ctx context.Context, interval time.Duration, store *secret.Store, enclaves *xenclave.Manager, requests *accounting.Accounting, errorLog *stdlog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.SaveUsage(); err != nil {
+				errorLog.Printf("Failed to save key usage: %v", err)
+			}
+			if err := enclaves.SaveUsage(); err != nil {
+				errorLog.Printf("Failed to save key usage: %v", err)
+			}
+			if requests != nil {
+				if err := requests.Save(); err != nil {
+					errorLog.Printf("Failed to save request accounting: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// deletePendingKeys deletes the keys of the server and all
+// enclaves whose deletion window has passed - once when called
+// and then every interval until ctx is done.
+func deletePendingKeys(ctx context.Context, interval time.Duration, store *secret.Store, enclaves *xenclave.Manager, roles *auth.Roles, errorLog *stdlog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		deleted, err := store.DeletePending(ctx, now, serverLock(xhttp.PreconditionLock))
+		if err != nil {
+			errorLog.Printf("Failed to delete keys pending deletion: %v", err)
+		}
+		if roles.Quotas != nil && len(deleted) > 0 {
+			for _, name := range deleted {
+				roles.Quotas.Release(name)
+			}
+			if err = roles.Quotas.Save(); err != nil {
+				errorLog.Printf("Failed to save key quotas: %v", err)
+			}
+		}
+		if err = enclaves.DeletePending(ctx, now, xhttp.PreconditionLock); err != nil {
+			errorLog.Printf("Failed to delete keys pending deletion: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// serverLock returns a function that returns the precondition
+// lock of a key of the server itself - i.e. not of an enclave.
+func serverLock(lock func(enclave, key string) sync.Locker) func(key string) sync.Locker {
+	return func(key string) sync.Locker { return lock("", key) }
+}
+
+// sortedKeys returns the keys of the map sorted.
+func sortedKeys(m map[string]secret.Usage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // timeout returns and HTTP handler that runs f
 // with the given time limit.
 //
@@ -767,6 +2018,30 @@ func interfaceIP4Addrs() []net.IP {
 	return ip4Addr
 }
 
+// interfaceIP6Addrs returns a list of the system's global
+// unicast and loopback IPv6 interface addresses.
+func interfaceIP6Addrs() []net.IP {
+	interfaces, err := net.InterfaceAddrs()
+	if err != nil {
+		return []net.IP{}
+	}
+
+	var ip6Addr []net.IP
+	for _, iface := range interfaces {
+		var ip net.IP
+		switch addr := iface.(type) {
+		case *net.IPNet:
+			ip = addr.IP
+		case *net.IPAddr:
+			ip = addr.IP
+		}
+		if ip.To4() == nil && (ip.IsGlobalUnicast() || ip.IsLoopback()) {
+			ip6Addr = append(ip6Addr, ip)
+		}
+	}
+	return ip6Addr
+}
+
 // serverAddr takes an address string <IP>:<port> and
 // splits it into an IP address and port number.
 //
@@ -789,7 +2064,393 @@ func serverAddr(addr string) (ip net.IP, port string, err error) {
 		return nil, "", fmt.Errorf("Invalid server address: %s", addr)
 	}
 	if ip.IsUnspecified() {
-		ip = net.IPv4(127, 0, 0, 1)
+		if ip.To4() != nil {
+			ip = net.IPv4(127, 0, 0, 1)
+		} else {
+			ip = net.IPv6loopback
+		}
 	}
 	return ip, port, err
 }
+
+// clientAuthType returns the TLS client authentication type
+// for the auth mode - i.e. "on" or "off". It reports whether
+// the mode is valid.
+//
+// If certless is true, clients may connect without a client
+// certificate - e.g. since LDAP users authenticate with a
+// username and password.
+func clientAuthType(mode string, certless bool) (tls.ClientAuthType, bool) {
+	switch strings.ToLower(mode) {
+	case "on":
+		if certless {
+			return tls.VerifyClientCertIfGiven, true
+		}
+		return tls.RequireAndVerifyClientCert, true
+	case "off":
+		if certless {
+			return tls.RequestClientCert, true
+		}
+		return tls.RequireAnyClientCert, true
+	default:
+		return tls.NoClientCert, false
+	}
+}
+
+// newAuditSinks returns the audit log sinks - i.e. the file,
+// syslog and webhook sinks - specified in the config.
+//
+// If the integrity key is 'enclave', the audit log signing
+// key is derived from the shared enclave key.
+func newAuditSinks(config *serverConfig, enclaveKey *secret.EnclaveKey, errorLog *stdlog.Logger) ([]io.Writer, error) {
+	// If an integrity key is specified, each audit log sink
+	// receives its own hash chain of audit events that is
+	// signed periodically.
+	var (
+		signingKey ed25519.PrivateKey
+		sinks      []io.Writer
+		err        error
+	)
+	switch config.Log.Integrity.Key {
+	case "":
+	case enclaveSigningKey:
+		if enclaveKey == nil {
+			return nil, errors.New("Audit log integrity key 'enclave' requires a shared enclave key")
+		}
+		signingKey = ed25519.NewKeyFromSeed(enclaveKey.DeriveKey("kes audit log signing key", ed25519.SeedSize))
+		if config.Log.Integrity.Pub != "" {
+			if err = writePublicKey(config.Log.Integrity.Pub, signingKey.Public()); err != nil {
+				return nil, fmt.Errorf("Failed to write audit log public key: %v", err)
+			}
+		}
+	default:
+		if signingKey, err = loadSigningKey(config.Log.Integrity.Key); err != nil {
+			return nil, fmt.Errorf("Failed to load audit log integrity key: %v", err)
+		}
+	}
+	chain := func(out io.Writer, prev [sha256.Size]byte) io.Writer {
+		if signingKey == nil {
+			return out
+		}
+		return &xlog.ChainWriter{
+			Writer:       out,
+			Key:          signingKey,
+			SignInterval: config.Log.Integrity.Interval,
+			Prev:         prev,
+		}
+	}
+	if sink := config.Log.Sinks.File; sink.Path != "" {
+		prev, err := xlog.LastRecordHash(sink.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read audit log file '%s': %v", sink.Path, err)
+		}
+		sinks = append(sinks, chain(&xlog.RotatingFile{
+			Path:       sink.Path,
+			MaxSize:    sink.MaxSize << 20, // The size is specified in MiB
+			MaxBackups: sink.Backups,
+		}, prev))
+	}
+	if sink := config.Log.Sinks.Syslog; sink.Network != "" || sink.Address != "" || sink.Tag != "" {
+		out, err := newSyslog(config, "audit", errorLog)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, chain(out, [sha256.Size]byte{}))
+	}
+	if sink := config.Log.Sinks.Webhook; sink.Endpoint != "" {
+		sinks = append(sinks, chain(&xlog.Webhook{
+			Endpoint: sink.Endpoint,
+			ErrorLog: errorLog,
+		}, [sha256.Size]byte{}))
+	}
+	return sinks, nil
+}
+
+// newSyslog returns a syslog sink for the syslog server
+// specified in the config. The msgID identifies the kind
+// of messages - e.g. "audit".
+//
+// The sink connects to the syslog server once it sends
+// the first message. Hence, the server starts even if
+// the syslog server is not reachable.
+func newSyslog(config *serverConfig, msgID string, errorLog *stdlog.Logger) (*xlog.Syslog, error) {
+	sink := config.Log.Sinks.Syslog
+	syslog := &xlog.Syslog{
+		Network:      sink.Network,
+		Address:      sink.Address,
+		AppName:      sink.Tag,
+		MsgID:        msgID,
+		MaxQueueSize: sink.Queue,
+		ErrorLog:     errorLog,
+	}
+	if syslog.AppName == "" {
+		syslog.AppName = "kes"
+	}
+	if sink.Facility != "" {
+		facility, err := xlog.ParseFacility(sink.Facility)
+		if err != nil {
+			return nil, err
+		}
+		syslog.Facility = facility
+	}
+	if sink.Network == "tls" {
+		syslog.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if sink.TLS.CAPath != "" {
+			caCerts, err := ioutil.ReadFile(sink.TLS.CAPath)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read syslog CA certificates: %v", err)
+			}
+			syslog.TLSConfig.RootCAs = x509.NewCertPool()
+			if !syslog.TLSConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+				return nil, fmt.Errorf("Failed to parse syslog CA certificates: '%s' contains no PEM-encoded certificate", sink.TLS.CAPath)
+			}
+		}
+		if sink.TLS.CertPath != "" || sink.TLS.KeyPath != "" {
+			certificate, err := tls.LoadX509KeyPair(sink.TLS.CertPath, sink.TLS.KeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load syslog TLS client certificate: %v", err)
+			}
+			syslog.TLSConfig.Certificates = []tls.Certificate{certificate}
+		}
+	}
+	return syslog, nil
+}
+
+// newEntropySource returns a new entropy source that mixes
+// the operating system RNG with the hardware RNGs specified
+// in the config, if any.
+func newEntropySource(config *serverConfig) (*entropy.Source, error) {
+	var hwrng io.Reader
+	if config.Entropy.HWRNG != "" {
+		file, err := os.Open(config.Entropy.HWRNG)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open hardware RNG: %v", err)
+		}
+		hwrng = file // The file remains open as long as the server is running
+	}
+	source, err := entropy.NewSource(hwrng, config.Entropy.RDSEED)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize entropy source: %v", err)
+	}
+	return source, nil
+}
+
+// newAWSKMS returns an AWS-KMS client for the KMS
+// specified in the config.
+func newAWSKMS(config *serverConfig) *aws.KMS {
+	return &aws.KMS{
+		Addr:   config.KMS.Aws.Endpoint,
+		Region: config.KMS.Aws.Region,
+		KeyID:  config.KMS.Aws.Key,
+		Login: aws.Credentials{
+			AccessKey:    config.KMS.Aws.Login.AccessKey,
+			SecretKey:    config.KMS.Aws.Login.SecretKey,
+			SessionToken: config.KMS.Aws.Login.SessionToken,
+		},
+		GrantTokens: config.KMS.Aws.GrantTokens,
+		Limit: aws.RateLimit{
+			Rate:  config.KMS.Aws.Limit.Rate,
+			Burst: config.KMS.Aws.Limit.Burst,
+		},
+		Retry: aws.Retry{
+			N:      config.KMS.Aws.Retry.Max,
+			Delay:  config.KMS.Aws.Retry.Delay,
+			Jitter: config.KMS.Aws.Retry.Jitter,
+		},
+		Transport: aws.Transport{
+			MaxIdleConns:        config.KMS.Aws.Transport.MaxIdleConns,
+			IdleConnTimeout:     config.KMS.Aws.Transport.IdleConnTimeout,
+			TLSSessionCacheSize: config.KMS.Aws.Transport.TLSSessionCacheSize,
+		},
+	}
+}
+
+// newVaultStore returns a Vault key store for the
+// Vault instance specified in the config.
+func newVaultStore(config *serverConfig) *vault.Store {
+	return &vault.Store{
+		Addr:      config.Keys.Vault.Endpoint,
+		Engine:    config.Keys.Vault.EnginePath,
+		Location:  config.Keys.Vault.Prefix,
+		Namespace: config.Keys.Vault.Namespace,
+		AppRole: vault.AppRole{
+			Engine: config.Keys.Vault.AppRole.EnginePath,
+			ID:     config.Keys.Vault.AppRole.ID,
+			Secret: config.Keys.Vault.AppRole.Secret,
+			Retry:  config.Keys.Vault.AppRole.Retry,
+		},
+		StatusPingAfter: config.Keys.Vault.Status.Ping,
+		ClientKeyPath:   config.Keys.Vault.TLS.KeyPath,
+		ClientCertPath:  config.Keys.Vault.TLS.CertPath,
+		CAPath:          config.Keys.Vault.TLS.CAPath,
+	}
+}
+
+// newAWSSecretsManager returns an AWS SecretsManager
+// key store for the SecretsManager specified in the
+// config.
+func newAWSSecretsManager(config *serverConfig) *aws.SecretsManager {
+	return &aws.SecretsManager{
+		Addr:     config.Keys.Aws.SecretsManager.Endpoint,
+		Region:   config.Keys.Aws.SecretsManager.Region,
+		KMSKeyID: config.Keys.Aws.SecretsManager.KmsKey,
+		Login: aws.Credentials{
+			AccessKey:    config.Keys.Aws.SecretsManager.Login.AccessKey,
+			SecretKey:    config.Keys.Aws.SecretsManager.Login.SecretKey,
+			SessionToken: config.Keys.Aws.SecretsManager.Login.SessionToken,
+		},
+		RecoveryWindow: config.Keys.Aws.SecretsManager.RecoveryWindow,
+	}
+}
+
+// newAttestationProvider returns the hardware attestation
+// provider specified in the config or nil if attestation
+// is disabled.
+func newAttestationProvider(config *serverConfig) attest.Provider {
+	switch config.Attestation.Provider {
+	case "tsm":
+		return &attest.TSM{Path: config.Attestation.Path}
+	case "sgx":
+		return &attest.SGX{Path: config.Attestation.Path}
+	default:
+		return nil
+	}
+}
+
+// enclaveSigningKey is the audit log integrity key
+// that refers to the key derived from the shared
+// enclave key.
+const enclaveSigningKey = "enclave"
+
+// newKeyStore returns the key store specified by the keys
+// section of the config, its name and its endpoint. If the
+// config specifies no key store, it returns an in-memory
+// key store.
+func newKeyStore(ctx context.Context, config *serverConfig, errorLog *xlog.SystemLog, quiet quiet) (secret.Remote, string, string, error) {
+	switch {
+	case config.Keys.Fs.Path != "":
+		f, err := os.Stat(config.Keys.Fs.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, "", "", fmt.Errorf("Failed to open %s: %v", config.Keys.Fs.Path, err)
+		}
+		if err == nil && !f.IsDir() {
+			return nil, "", "", fmt.Errorf("%s is not a directory", config.Keys.Fs.Path)
+		}
+		if os.IsNotExist(err) {
+			msg := fmt.Sprintf("Creating directory '%s' ... ", config.Keys.Fs.Path)
+			quiet.Print(msg)
+			if err = os.MkdirAll(config.Keys.Fs.Path, 0700); err != nil {
+				return nil, "", "", fmt.Errorf("Failed to create directory %s: %v", config.Keys.Fs.Path, err)
+			}
+			quiet.ClearMessage(msg)
+		}
+		endpoint, err := filepath.Abs(config.Keys.Fs.Path)
+		if err != nil {
+			endpoint = config.Keys.Fs.Path
+		}
+		return secret.LoggingRemote{
+			Remote: &fs.Store{Dir: config.Keys.Fs.Path},
+			Log:    errorLog.Logger("fs"),
+		}, "Filesystem", endpoint, nil
+	case config.Keys.Vault.Endpoint != "":
+		vaultStore := newVaultStore(config)
+		vaultStore.Log = errorLog.Logger("vault")
+
+		msg := fmt.Sprintf("Authenticating to Hashicorp Vault '%s' ... ", vaultStore.Addr)
+		quiet.Print(msg)
+		if err := vaultStore.Authenticate(ctx); err != nil {
+			return nil, "", "", fmt.Errorf("Failed to connect to Vault: %v", err)
+		}
+		quiet.ClearMessage(msg)
+		return vaultStore, "Hashicorp Vault", config.Keys.Vault.Endpoint, nil
+	case config.Keys.Aws.SecretsManager.Endpoint != "":
+		awsStore := newAWSSecretsManager(config)
+		awsStore.Log = errorLog.Logger("aws-secrets-manager")
+
+		msg := fmt.Sprintf("Authenticating to AWS SecretsManager '%s' ... ", awsStore.Addr)
+		quiet.Print(msg)
+		if err := awsStore.Authenticate(); err != nil {
+			return nil, "", "", fmt.Errorf("Failed to connect to AWS Secrets Manager: %v", err)
+		}
+		quiet.ClearMessage(msg)
+		return awsStore, "AWS SecretsManager", config.Keys.Aws.SecretsManager.Endpoint, nil
+	case config.Keys.Aws.ParameterStore.Endpoint != "":
+		awsStore := &aws.ParameterStore{
+			Addr:     config.Keys.Aws.ParameterStore.Endpoint,
+			Region:   config.Keys.Aws.ParameterStore.Region,
+			Prefix:   config.Keys.Aws.ParameterStore.Prefix,
+			KMSKeyID: config.Keys.Aws.ParameterStore.KmsKey,
+			Log:      errorLog.Logger("aws-parameter-store"),
+			Login: aws.Credentials{
+				AccessKey:    config.Keys.Aws.ParameterStore.Login.AccessKey,
+				SecretKey:    config.Keys.Aws.ParameterStore.Login.SecretKey,
+				SessionToken: config.Keys.Aws.ParameterStore.Login.SessionToken,
+			},
+		}
+
+		msg := fmt.Sprintf("Authenticating to AWS ParameterStore '%s' ... ", awsStore.Addr)
+		quiet.Print(msg)
+		if err := awsStore.Authenticate(); err != nil {
+			return nil, "", "", fmt.Errorf("Failed to connect to AWS Parameter Store: %v", err)
+		}
+		quiet.ClearMessage(msg)
+		return awsStore, "AWS ParameterStore", config.Keys.Aws.ParameterStore.Endpoint, nil
+	case config.Keys.Gemalto.KeySecure.Endpoint != "":
+		gemaltoStore := &gemalto.KeySecure{
+			Endpoint: config.Keys.Gemalto.KeySecure.Endpoint,
+			CAPath:   config.Keys.Gemalto.KeySecure.TLS.CAPath,
+			Log:      errorLog.Logger("gemalto"),
+			Login: gemalto.Credentials{
+				Token:  config.Keys.Gemalto.KeySecure.Login.Token,
+				Domain: config.Keys.Gemalto.KeySecure.Login.Domain,
+				Retry:  config.Keys.Gemalto.KeySecure.Login.Retry,
+			},
+		}
+
+		msg := fmt.Sprintf("Authenticating to Gemalto KeySecure '%s' ... ", gemaltoStore.Endpoint)
+		quiet.Printf(msg)
+		if err := gemaltoStore.Authenticate(ctx); err != nil {
+			return nil, "", "", fmt.Errorf("Failed to connect to Gemalto KeySecure: %v", err)
+		}
+		quiet.ClearMessage(msg)
+		return gemaltoStore, "Gemalto KeySecure", config.Keys.Gemalto.KeySecure.Endpoint, nil
+	default:
+		return secret.LoggingRemote{
+			Remote: &mem.Store{},
+			Log:    errorLog.Logger("mem"),
+		}, "In-Memory", "non-persistent", nil
+	}
+}
+
+// writePublicKey writes the PEM-encoded public key
+// to the file - replacing any existing file.
+func writePublicKey(filename string, key crypto.PublicKey) error {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+}
+
+// loadSigningKey reads and parses the PEM-encoded
+// PKCS #8 Ed25519 private key used to sign the
+// audit log or the responses of the data key APIs.
+func loadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	pemBlock, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBlock)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("'%s' does not contain a PEM-encoded private key", filename)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("'%s' does not contain an Ed25519 private key", filename)
+	}
+	return privateKey, nil
+}
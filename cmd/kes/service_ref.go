@@ -0,0 +1,15 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package main
+
+import "os"
+
+func startService(hupCh, sigCh chan<- os.Signal) (func(), error) {
+	// Windows services are, obviously,
+	// only supported on windows.
+	return func() {}, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// startService handles the control requests of the Windows
+// service control manager if the server has been started as
+// Windows service - e.g. via:
+//   sc.exe create kes binPath= "C:\kes\kes.exe server --config C:\kes\config.yaml"
+//
+// A stop or shutdown request is delivered as SIGTERM to sigCh
+// and a parameter change request - i.e. "sc.exe control kes
+// paramchange" - as SIGHUP to hupCh.
+//
+// The returned function reports to the service control manager
+// that the service has stopped. It must be called once the server
+// has shut down. If the server does not run as Windows service,
+// startService returns a function that does nothing.
+func startService(hupCh, sigCh chan<- os.Signal) (func(), error) {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return nil, err
+	}
+	if interactive {
+		return func() {}, nil
+	}
+
+	s := &service{
+		hupCh:   hupCh,
+		sigCh:   sigCh,
+		stopped: make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.Run("kes", s) // The name is ignored for services running in their own process
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(s.stopped) })
+		<-done
+	}, nil
+}
+
+// service implements svc.Handler.
+type service struct {
+	hupCh   chan<- os.Signal
+	sigCh   chan<- os.Signal
+	stopped chan struct{}
+}
+
+func (s *service) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange
+
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case <-s.stopped:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.ParamChange:
+				select {
+				case s.hupCh <- syscall.SIGHUP:
+				default: // A reload is already pending
+				}
+			case svc.Stop, svc.Shutdown:
+				// The server waits for in-flight requests and closes
+				// the connections to the key store and KMS. Hence, we
+				// only report that the service has stopped once the
+				// server has shut down.
+				status <- svc.Status{State: svc.StopPending}
+				select {
+				case s.sigCh <- syscall.SIGTERM:
+				case <-s.stopped: // The server is already shutting down
+				}
+				<-s.stopped
+				return false, 0
+			}
+		}
+	}
+}
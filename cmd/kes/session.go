@@ -0,0 +1,88 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+const sessionCmdUsage = `Issues a short-lived session token.
+
+A session token is a credential derived from the client identity
+with a narrowed policy. It can be passed to ephemeral workloads -
+e.g. batch jobs - instead of the long-lived private key and
+certificate of the identity. A request of the session is allowed
+if the session policy and the policy of the identity allow it.
+
+Clients authenticate with the session token by setting the env.
+variable KES_TOKEN to the token.
+
+usage: %s [options] <policy-file>
+
+  --ttl                Duration until the session expires. If not
+                       set, the server default is used.
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake
+
+  -h, --help           Show list of command-line options
+
+Example:
+  $ kes session --ttl=30m batch-job.json
+`
+
+func session(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), sessionCmdUsage, cli.Name())
+	}
+
+	var (
+		ttl                time.Duration
+		insecureSkipVerify bool
+	)
+	cli.DurationVar(&ttl, "ttl", 0, "Duration until the session expires")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+	if ttl < 0 {
+		return fmt.Errorf("Invalid TTL '%v': must not be negative", ttl)
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("Cannot read policy file '%s': %v", args[0], err)
+	}
+	var policy kes.Policy
+	if err = policy.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("Policy file is invalid JSON: %v", err)
+	}
+
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	session, err := client.IssueSession(&policy, ttl)
+	if err != nil {
+		return fmt.Errorf("Failed to issue session: %v", err)
+	}
+	if isTerm(os.Stdout) {
+		fmt.Println(session.Token)
+		fmt.Printf("Identity: %s\n", session.Identity)
+		fmt.Printf("Expires:  %s\n", session.Expiry.Local().Format(time.RFC1123))
+	} else {
+		json.NewEncoder(os.Stdout).Encode(session)
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const shellCmdUsage = `usage: %s [options]
+
+  -h, --help           Show list of command-line options
+
+Starts an interactive shell. The shell keeps a session with the
+server endpoint, client certificate and private key such that
+commands don't have to repeat them. For example:
+  $ kes shell
+  kes> set server https://127.0.0.1:7373
+  kes> set cert client.crt
+  kes> set key client.key
+  kes> key create my-key
+
+The session and the command history are stored in the user's
+config directory and restored when the shell starts again.
+Press TAB to complete commands.
+`
+
+const shellHelp = `Commands:
+  <command> [args]       Run a kes command - e.g. "key create my-key"
+  set <name> <value>     Set a session value
+  unset <name>           Remove a session value
+  session                Show the current session
+  history                Show the command history
+  help                   Show this help
+  exit                   Leave the shell
+
+Session values:
+  server                 The server endpoint(s)   (KES_SERVER)
+  cert                   The client certificate   (KES_CLIENT_CERT)
+  key                    The client private key   (KES_CLIENT_KEY)
+  enclave                The enclave              (KES_ENCLAVE)
+  insecure               Skip certificate verification: on / off
+`
+
+// shellCommands are the commands and sub-commands
+// that the shell can complete.
+var shellCommands = map[string][]string{
+	"key":      {"create", "delete", "list", "describe", "provenance", "derive", "decrypt"},
+	"policy":   {"add", "show", "list", "delete"},
+	"identity": {"assign", "list", "forget", "renew", "usage"},
+	"log":      {"trace"},
+	"quota":    {"list", "set"},
+	"backup":   {"create", "restore"},
+	"enclave":  {"create", "delete", "list"},
+	"job":      {"submit", "status", "list", "cancel"},
+	"seal":     {"status", "init", "unseal"},
+	"bench":    {"generate", "decrypt", "create"},
+	"encrypt":  nil,
+	"decrypt":  nil,
+	"tool":     {"identity", "audit"},
+
+	"set":     {"server", "cert", "key", "enclave", "insecure"},
+	"unset":   {"server", "cert", "key", "enclave", "insecure"},
+	"session": nil,
+	"history": nil,
+	"help":    nil,
+	"exit":    nil,
+}
+
+// shellSession is the state of a shell that is
+// stored across shell invocations.
+type shellSession struct {
+	Server   string `json:"server,omitempty"`
+	Cert     string `json:"cert,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Enclave  string `json:"enclave,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// env returns the session as env. variables that
+// are passed to the commands.
+func (s *shellSession) env() []string {
+	env := os.Environ()
+	for name, value := range map[string]string{
+		"KES_SERVER":      s.Server,
+		"KES_CLIENT_CERT": s.Cert,
+		"KES_CLIENT_KEY":  s.Key,
+		"KES_ENCLAVE":     s.Enclave,
+	} {
+		if value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+func shell(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), shellCmdUsage, cli.Name())
+	}
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Cannot start shell: %v", err)
+	}
+
+	var sessionFile, historyFile string
+	if dir, err := os.UserConfigDir(); err == nil {
+		dir = filepath.Join(dir, "kes")
+		if err = os.MkdirAll(dir, 0700); err == nil {
+			sessionFile = filepath.Join(dir, "shell-session.json")
+			historyFile = filepath.Join(dir, "shell-history")
+		}
+	}
+	var session shellSession
+	if sessionFile != "" {
+		if b, err := ioutil.ReadFile(sessionFile); err == nil {
+			json.Unmarshal(b, &session)
+		}
+	}
+
+	// The commands handle CTRL+C themselves.
+	// The shell keeps running.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	readLine, closeShell, err := newShellReader(historyFile)
+	if err != nil {
+		return fmt.Errorf("Cannot start shell: %v", err)
+	}
+	defer closeShell()
+
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args, err := splitShellLine(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if historyFile != "" {
+			appendHistory(historyFile, line)
+		}
+
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			fmt.Print(shellHelp)
+		case "history":
+			if b, err := ioutil.ReadFile(historyFile); err == nil {
+				os.Stdout.Write(b)
+			}
+		case "session":
+			fmt.Printf("server:   %s\ncert:     %s\nkey:      %s\nenclave:  %s\ninsecure: %v\n", session.Server, session.Cert, session.Key, session.Enclave, session.Insecure)
+		case "set", "unset":
+			if err = updateSession(&session, args); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if sessionFile != "" {
+				b, _ := json.Marshal(session)
+				if err = ioutil.WriteFile(sessionFile, b, 0600); err != nil {
+					fmt.Fprintf(os.Stderr, "Cannot save session: %v\n", err)
+				}
+			}
+		case "shell", "server":
+			fmt.Fprintf(os.Stderr, "Cannot run '%s' within the shell\n", args[0])
+		default:
+			if _, ok := shellCommands[args[0]]; ok && args[0] != "tool" && session.Insecure {
+				args = append(args, "-k")
+			}
+			cmd := exec.Command(binary, args...)
+			cmd.Env = session.env()
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			cmd.Run() // The command prints its errors itself
+		}
+
+		// Drop any CTRL+C received while running a
+		// command such that it does not affect the
+		// next command.
+		select {
+		case <-sigCh:
+		default:
+		}
+	}
+}
+
+// newShellReader returns a function that reads the next
+// command line and a function that restores the terminal.
+//
+// If STDIN is a terminal, the lines are read in raw mode
+// with command completion and history. Otherwise, e.g.
+// when commands are piped into the shell, the lines are
+// read as they are.
+func newShellReader(historyFile string) (func() (string, error), func(), error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		readLine := func() (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+		return readLine, func() {}, nil
+	}
+
+	// The terminal only keeps the history of the lines it
+	// has read. Therefore, we let it read the lines of the
+	// history file first - without echoing them.
+	const MaxHistory = 100
+	var history []string
+	if b, err := ioutil.ReadFile(historyFile); err == nil && historyFile != "" {
+		history = strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(history) > MaxHistory {
+			history = history[len(history)-MaxHistory:]
+		}
+	}
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{
+		Reader: strings.NewReader(strings.Replace(strings.Join(history, "\r")+"\r", "\t", " ", -1)),
+		Writer: ioutil.Discard,
+	}
+	term := terminal.NewTerminal(rw, "kes> ")
+	for range history {
+		if _, err := term.ReadLine(); err != nil {
+			break
+		}
+	}
+	rw.Reader, rw.Writer = os.Stdin, os.Stdout
+	term.AutoCompleteCallback = completeShellLine
+
+	readLine := func() (string, error) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+		defer terminal.Restore(fd, state)
+
+		if width, height, err := terminal.GetSize(fd); err == nil && width > 0 {
+			term.SetSize(width, height)
+		}
+		return term.ReadLine()
+	}
+	return readLine, func() { fmt.Println() }, nil
+}
+
+// completeShellLine completes the command or
+// sub-command at the end of the line when the
+// user presses TAB.
+func completeShellLine(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+
+	fields := strings.Fields(line)
+	if strings.HasSuffix(line, " ") || len(fields) == 0 {
+		fields = append(fields, "")
+	}
+
+	var candidates []string
+	switch len(fields) {
+	case 1:
+		for command := range shellCommands {
+			candidates = append(candidates, command)
+		}
+	case 2:
+		candidates = shellCommands[fields[0]]
+	default:
+		return "", 0, false
+	}
+
+	prefix := fields[len(fields)-1]
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+
+	completion := matches[0]
+	for _, m := range matches[1:] { // Complete the common prefix
+		for !strings.HasPrefix(m, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if len(matches) == 1 {
+		completion += " "
+	}
+	line = line[:len(line)-len(prefix)] + completion
+	return line, len(line), true
+}
+
+// updateSession applies a set or unset command
+// to the session.
+func updateSession(session *shellSession, args []string) error {
+	if args[0] == "set" && len(args) != 3 || args[0] == "unset" && len(args) != 2 {
+		return errors.New("usage: set <name> <value> | unset <name>")
+	}
+
+	var value string
+	if args[0] == "set" {
+		value = args[2]
+	}
+	switch args[1] {
+	case "server":
+		session.Server = value
+	case "cert", "key":
+		if value != "" {
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return err
+			}
+			value = abs
+		}
+		if args[1] == "cert" {
+			session.Cert = value
+		} else {
+			session.Key = value
+		}
+	case "enclave":
+		session.Enclave = value
+	case "insecure":
+		switch strings.ToLower(value) {
+		case "on", "true", "yes":
+			session.Insecure = true
+		case "", "off", "false", "no":
+			session.Insecure = false
+		default:
+			return fmt.Errorf("Invalid value '%s': must be 'on' or 'off'", value)
+		}
+	default:
+		return fmt.Errorf("Unknown session value '%s'", args[1])
+	}
+	return nil
+}
+
+// splitShellLine splits the line into arguments. An
+// argument may be enclosed in single or double quotes
+// to include spaces - e.g. a JSON object.
+func splitShellLine(line string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("Invalid command: unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// appendHistory appends the line to the history file.
+func appendHistory(filename, line string) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, line)
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	xlog "github.com/minio/kes/internal/log"
+)
+
+const toolAuditCmdUsage = `usage: %s <command>
+
+  key                  Create a new audit log signing key.
+  verify               Verify the integrity of audit log files.
+
+  -h, --help           Show list of command-line options
+`
+
+func toolAudit(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), toolAuditCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "key":
+		return newAuditKey(args)
+	case "verify":
+		return verifyAuditLog(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const newAuditKeyCmdUsage = `usage: %s [options]
+
+  --key                Path to the private key (default: ./audit.key)
+  --pub                Path to the public key (default: ./audit.pub)
+
+  -f, --force          Overwrite the private key and/or public key, if it exists
+
+  -h, --help           Show list of command-line options
+`
+
+func newAuditKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), newAuditKeyCmdUsage, cli.Name())
+	}
+
+	var (
+		keyPath string
+		pubPath string
+		force   bool
+	)
+	cli.StringVar(&keyPath, "key", "./audit.key", "Path to the private key (default: ./audit.key)")
+	cli.StringVar(&pubPath, "pub", "./audit.pub", "Path to the public key (default: ./audit.pub)")
+	cli.BoolVar(&force, "f", false, "Overwrite the private key and/or public key, if it exists")
+	cli.BoolVar(&force, "force", false, "Overwrite the private key and/or public key, if it exists")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("Failed to generate Ed25519 key pair: %v", err)
+	}
+	privBytes, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("Failed to encode private key: %v", err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return fmt.Errorf("Failed to encode public key: %v", err)
+	}
+
+	fileFlags := os.O_CREATE | os.O_WRONLY
+	if force {
+		fileFlags |= os.O_TRUNC
+	} else {
+		fileFlags |= os.O_EXCL
+	}
+
+	keyFile, err := os.OpenFile(keyPath, fileFlags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists: Use --force to overwrite the private key", keyPath)
+		}
+		return fmt.Errorf("Failed to create private key: %v", err)
+	}
+	defer keyFile.Close()
+
+	pubFile, err := os.OpenFile(pubPath, fileFlags, 0644)
+	if err != nil {
+		os.Remove(keyPath)
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists: Use --force to overwrite the public key", pubPath)
+		}
+		return fmt.Errorf("Failed to create public key: %v", err)
+	}
+	defer pubFile.Close()
+
+	if err = pem.Encode(pubFile, &pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}); err != nil {
+		os.Remove(keyPath)
+		os.Remove(pubPath)
+		return fmt.Errorf("Failed to create public key: %v", err)
+	}
+	if err = pubFile.Close(); err != nil {
+		os.Remove(keyPath)
+		os.Remove(pubPath)
+		return fmt.Errorf("Failed to close %s: %v", pubPath, err)
+	}
+
+	if err = pem.Encode(keyFile, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
+		os.Remove(keyPath)
+		os.Remove(pubPath)
+		return fmt.Errorf("Failed to create private key: %v", err)
+	}
+	if err = keyFile.Close(); err != nil {
+		os.Remove(keyPath)
+		os.Remove(pubPath)
+		return fmt.Errorf("Failed to close %s: %v", keyPath, err)
+	}
+	return nil
+}
+
+const verifyAuditLogCmdUsage = `usage: %s [options] <file>...
+
+  --pub                Path to the public key (default: ./audit.pub)
+  --partial            Accept logs that do not start with the first audit
+                       event - e.g. since old log files have been removed
+  --allow-unsigned     Accept logs without any signature
+
+  -h, --help           Show list of command-line options
+
+Verifies the hash chain and the signatures of the given audit
+log files. Rotated log files must be passed from the oldest to
+the most recent file - e.g.:
+  $ kes tool audit verify --pub=./audit.pub audit.log.2 audit.log.1 audit.log
+
+By default, the first file must start with the first audit event
+ever written. If old log files have been removed, use --partial.
+Then, removing the oldest audit events cannot be detected.
+`
+
+func verifyAuditLog(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), verifyAuditLogCmdUsage, cli.Name())
+	}
+
+	var (
+		pubPath       string
+		partial       bool
+		allowUnsigned bool
+	)
+	cli.StringVar(&pubPath, "pub", "./audit.pub", "Path to the public key (default: ./audit.pub)")
+	cli.BoolVar(&partial, "partial", false, "Accept logs that do not start with the first audit event")
+	cli.BoolVar(&allowUnsigned, "allow-unsigned", false, "Accept logs without any signature")
+	if args = parseCommandFlags(cli, args[1:]); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	pemBlock, err := ioutil.ReadFile(pubPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read public key: %v", err)
+	}
+	block, _ := pem.Decode(pemBlock)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return fmt.Errorf("'%s' does not contain a PEM-encoded public key", pubPath)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("Failed to parse public key: %v", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("'%s' does not contain an Ed25519 public key", pubPath)
+	}
+
+	readers := make([]io.Reader, 0, len(args))
+	for _, filename := range args {
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("Failed to open audit log file: %v", err)
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	report, err := xlog.VerifyChain(io.MultiReader(readers...), publicKey, xlog.VerifyOptions{
+		Partial:       partial,
+		AllowUnsigned: allowUnsigned,
+	})
+	if err != nil {
+		return fmt.Errorf("Audit log verification failed: %v", err)
+	}
+	fmt.Printf("Verified %d audit events and %d signatures\n", report.Records, report.Signatures)
+	if report.Unsigned > 0 {
+		fmt.Printf("Warning: the last %d audit events are not covered by a signature\n", report.Unsigned)
+	}
+	return nil
+}
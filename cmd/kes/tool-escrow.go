@@ -0,0 +1,297 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/minio/kes/internal/escrow"
+	"golang.org/x/crypto/curve25519"
+)
+
+const toolEscrowCmdUsage = `usage: %s <command>
+
+  key                  Create a new custodian key pair.
+  verify               Verify the signature of an escrow package.
+  share                Decrypt the key share of a custodian.
+  recover              Recover the keys from a threshold of key shares.
+
+  -h, --help           Show list of command-line options
+`
+
+func toolEscrow(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), toolEscrowCmdUsage, cli.Name())
+	}
+
+	cli.Parse(args[1:])
+	if args = cli.Args(); len(args) == 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "key":
+		return newCustodianKey(args)
+	case "verify":
+		return verifyEscrow(args)
+	case "share":
+		return decryptEscrowShare(args)
+	case "recover":
+		return recoverEscrow(args)
+	default:
+		cli.Usage()
+		os.Exit(2)
+		return nil // for the compiler
+	}
+}
+
+const newCustodianKeyCmdUsage = `usage: %s [options]
+
+  --key                Path to the private key (default: ./custodian.key)
+
+  -f, --force          Overwrite the private key, if it exists
+
+  -h, --help           Show list of command-line options
+
+Creates a new X25519 custodian key pair. The private key is written
+to the file and must be kept offline by the custodian. The public key
+is printed and has to be added to the escrow custodians of the server
+config file.
+`
+
+func newCustodianKey(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), newCustodianKeyCmdUsage, cli.Name())
+	}
+
+	var (
+		keyPath string
+		force   bool
+	)
+	cli.StringVar(&keyPath, "key", "./custodian.key", "Path to the private key (default: ./custodian.key)")
+	cli.BoolVar(&force, "f", false, "Overwrite the private key, if it exists")
+	cli.BoolVar(&force, "force", false, "Overwrite the private key, if it exists")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 0 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var private, public [32]byte
+	if _, err := rand.Read(private[:]); err != nil {
+		return fmt.Errorf("Failed to generate X25519 key pair: %v", err)
+	}
+	curve25519.ScalarBaseMult(&public, &private)
+
+	fileFlags := os.O_CREATE | os.O_WRONLY
+	if force {
+		fileFlags |= os.O_TRUNC
+	} else {
+		fileFlags |= os.O_EXCL
+	}
+	keyFile, err := os.OpenFile(keyPath, fileFlags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists: Use --force to overwrite the private key", keyPath)
+		}
+		return fmt.Errorf("Failed to create private key: %v", err)
+	}
+	defer keyFile.Close()
+
+	if _, err = fmt.Fprintln(keyFile, base64.StdEncoding.EncodeToString(private[:])); err != nil {
+		os.Remove(keyPath)
+		return fmt.Errorf("Failed to create private key: %v", err)
+	}
+	if err = keyFile.Close(); err != nil {
+		os.Remove(keyPath)
+		return fmt.Errorf("Failed to close %s: %v", keyPath, err)
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(public[:]))
+	return nil
+}
+
+const verifyEscrowCmdUsage = `usage: %s [options] <file>
+
+  --pub                Path to the escrow signing public key (default: ./escrow.pub)
+
+  -h, --help           Show list of command-line options
+
+Verifies the signature of the escrow package and prints its manifest.
+`
+
+func verifyEscrow(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), verifyEscrowCmdUsage, cli.Name())
+	}
+
+	var pubPath string
+	cli.StringVar(&pubPath, "pub", "./escrow.pub", "Path to the escrow signing public key (default: ./escrow.pub)")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	manifest, err := loadEscrowManifest(pubPath, args[0])
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(manifest.Keys))
+	for name := range manifest.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Created:    %s\n", manifest.CreatedAt)
+	fmt.Printf("Threshold:  %d of %d custodians\n", manifest.Threshold, len(manifest.Custodians))
+	for _, c := range manifest.Custodians {
+		fmt.Printf("Custodian:  %s (%s)\n", c.Name, base64.StdEncoding.EncodeToString(c.PublicKey))
+	}
+	for _, name := range names {
+		fmt.Printf("Key:        %s\n", name)
+	}
+	return nil
+}
+
+const decryptEscrowShareCmdUsage = `usage: %s [options] <file> <custodian>
+
+  --key                Path to the custodian private key (default: ./custodian.key)
+  --pub                Path to the escrow signing public key (default: ./escrow.pub)
+
+  -h, --help           Show list of command-line options
+
+Verifies the escrow package and prints the key share of the custodian
+decrypted with the custodian's private key.
+  $ kes tool escrow share --key=./alice.key escrow.json alice
+`
+
+func decryptEscrowShare(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), decryptEscrowShareCmdUsage, cli.Name())
+	}
+
+	var keyPath, pubPath string
+	cli.StringVar(&keyPath, "key", "./custodian.key", "Path to the custodian private key (default: ./custodian.key)")
+	cli.StringVar(&pubPath, "pub", "./escrow.pub", "Path to the escrow signing public key (default: ./escrow.pub)")
+	if args = parseCommandFlags(cli, args[1:]); len(args) != 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	manifest, err := loadEscrowManifest(pubPath, args[0])
+	if err != nil {
+		return err
+	}
+	keyFile, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read private key: %v", err)
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(keyFile)))
+	if err != nil {
+		return fmt.Errorf("'%s' does not contain a base64-encoded private key", keyPath)
+	}
+	share, err := manifest.DecryptShare(args[1], privateKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(share))
+	return nil
+}
+
+const recoverEscrowCmdUsage = `usage: %s [options] <file> <share>...
+
+  --pub                Path to the escrow signing public key (default: ./escrow.pub)
+
+  -h, --help           Show list of command-line options
+
+Verifies the escrow package and recovers the escrowed keys from a
+threshold of decrypted key shares. See: kes tool escrow share
+The keys are printed as JSON object - i.e. {"<name>":"<base64-key>"}.
+Each key can be imported via: kes key create <name> <base64-key>
+  $ kes tool escrow recover escrow.json <alice-share> <carol-share>
+`
+
+func recoverEscrow(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), recoverEscrowCmdUsage, cli.Name())
+	}
+
+	var pubPath string
+	cli.StringVar(&pubPath, "pub", "./escrow.pub", "Path to the escrow signing public key (default: ./escrow.pub)")
+	if args = parseCommandFlags(cli, args[1:]); len(args) < 2 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	manifest, err := loadEscrowManifest(pubPath, args[0])
+	if err != nil {
+		return err
+	}
+	shares := make([][]byte, 0, len(args)-1)
+	for _, s := range args[1:] {
+		share, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("Invalid key share: %v", err)
+		}
+		shares = append(shares, share)
+	}
+	keys, err := manifest.Recover(shares)
+	if err != nil {
+		return err
+	}
+
+	output := make(map[string][]byte, len(keys))
+	for name, key := range keys {
+		output[name] = append([]byte{}, key[:]...)
+	}
+	return json.NewEncoder(os.Stdout).Encode(output)
+}
+
+// loadEscrowManifest reads the escrow package from the file,
+// verifies it with the PEM-encoded Ed25519 public key at
+// pubPath and returns its manifest.
+func loadEscrowManifest(pubPath, filename string) (*escrow.Manifest, error) {
+	pemBlock, err := ioutil.ReadFile(pubPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read public key: %v", err)
+	}
+	block, _ := pem.Decode(pemBlock)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("'%s' does not contain a PEM-encoded public key", pubPath)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse public key: %v", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("'%s' does not contain an Ed25519 public key", pubPath)
+	}
+
+	file, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read escrow package: %v", err)
+	}
+	var pkg escrow.Package
+	if err = json.Unmarshal(file, &pkg); err != nil {
+		return nil, fmt.Errorf("Failed to parse escrow package: %v", err)
+	}
+	return pkg.Verify(publicKey)
+}
@@ -13,6 +13,8 @@ import (
 const toolCmdUsage = `usage: %s <command>
   
   identity             Identity management tools.
+  audit                Audit log integrity tools.
+  escrow               Key escrow custodian tools.
 
   -h, --help           Show list of command-line options
 `
@@ -32,6 +34,10 @@ func tool(args []string) error {
 	switch args[0] {
 	case "identity":
 		return toolIdentity(args)
+	case "audit":
+		return toolAudit(args)
+	case "escrow":
+		return toolEscrow(args)
 	default:
 		cli.Usage()
 		os.Exit(2)
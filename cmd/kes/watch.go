@@ -0,0 +1,141 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+const watchKeysCmdUsage = `usage: %s [options] [<pattern>]
+
+Prints an event whenever a key matching the pattern gets created,
+rotated, disabled, enabled or deleted - until interrupted.
+
+  --json               Print the events as JSON.
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake.
+
+  -h, --help           Show list of command-line options.
+
+If no pattern is specified, all keys are watched.
+  $ kes key watch 'my-app/*'
+`
+
+func watchKeys(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), watchKeysCmdUsage, cli.Name())
+	}
+
+	var (
+		jsonOutput         bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&jsonOutput, "json", false, "Print the events as JSON")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var pattern string
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	stream, err := client.WatchKeys(pattern)
+	if err != nil {
+		return fmt.Errorf("Cannot watch keys: %v", err)
+	}
+	return printWatchEvents(cli, stream, jsonOutput)
+}
+
+const watchPoliciesCmdUsage = `usage: %s [options] [<pattern>]
+
+Prints an event whenever a policy matching the pattern gets
+written or deleted - until interrupted.
+
+  --json               Print the events as JSON.
+
+  -k, --insecure       Skip X.509 certificate validation during TLS handshake.
+
+  -h, --help           Show list of command-line options.
+`
+
+func watchPolicies(args []string) error {
+	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cli.Usage = func() {
+		fmt.Fprintf(cli.Output(), watchPoliciesCmdUsage, cli.Name())
+	}
+
+	var (
+		jsonOutput         bool
+		insecureSkipVerify bool
+	)
+	cli.BoolVar(&jsonOutput, "json", false, "Print the events as JSON")
+	cli.BoolVar(&insecureSkipVerify, "k", false, "Skip X.509 certificate validation during TLS handshake")
+	cli.BoolVar(&insecureSkipVerify, "insecure", false, "Skip X.509 certificate validation during TLS handshake")
+	if args = parseCommandFlags(cli, args[1:]); len(args) > 1 {
+		cli.Usage()
+		os.Exit(2)
+	}
+
+	var pattern string
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	client, err := newClient(insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	stream, err := client.WatchPolicies(pattern)
+	if err != nil {
+		return fmt.Errorf("Cannot watch policies: %v", err)
+	}
+	return printWatchEvents(cli, stream, jsonOutput)
+}
+
+// printWatchEvents prints the events of the stream until
+// the stream ends or the process receives SIGINT or SIGTERM.
+func printWatchEvents(cli *flag.FlagSet, stream *kes.WatchStream, jsonOutput bool) error {
+	defer stream.Close()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		if err := stream.Close(); err != nil {
+			fmt.Fprintln(cli.Output(), err)
+		}
+	}()
+
+	isTerminal := isTerm(os.Stdout)
+	encoder := json.NewEncoder(os.Stdout)
+	for stream.Next() {
+		event := stream.Event()
+		if !isTerminal || jsonOutput {
+			encoder.Encode(event)
+			continue
+		}
+		name := event.Name
+		if event.Target != "" {
+			name += " -> " + event.Target
+		}
+		fmt.Printf("%s %-8s %s\n", event.Time.Local().Format(time.RFC3339), event.Action, name)
+	}
+	return stream.Err()
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/minio/kes/internal/envelope"
+)
+
+// Seal encrypts the plaintext with a new data encryption
+// key (DEK) generated by the key with the given name and
+// returns the envelope. Seal is a convenience wrapper around
+// SealWriter for data that fits into memory.
+//
+// An envelope consists of a single-line JSON header followed
+// by the encrypted data:
+//   {"version":1,"key":"<name>","cipher":"<cipher>","dek":"<base64>","nonce":"<base64>"}\n
+//   <ciphertext>
+//
+// The header fields are:
+//   • version: The envelope format version. Currently 1.
+//   • key:     The name of the key that encrypted the DEK.
+//   • cipher:  Either "AES-256-GCM" or "ChaCha20-Poly1305".
+//   • dek:     The DEK ciphertext returned by GenerateKey.
+//              It has been generated with an empty context.
+//   • nonce:   A random nonce - 8 bytes for both ciphers.
+//
+// The data is encrypted with the plaintext DEK as stream of
+// 16 KiB chunks using the DARE / sio format. Each chunk is
+// encrypted and authenticated individually such that large
+// files can be processed in constant memory. The entire raw
+// header line - including the newline - is authenticated as
+// associated data. Hence, modifying the header, reordering,
+// removing or appending chunks is detected when the envelope
+// is opened. For the chunk format see:
+// https://github.com/secure-io/sio-go
+//
+// An envelope can be opened by Open, OpenReader, the
+// "kes decrypt" command and the server's data decryption
+// API.
+func (c *Client) Seal(key string, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.SealWriter(&buf, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Open decrypts the envelope created by Seal or SealWriter
+// and returns the plaintext. The server decrypts the DEK of
+// the envelope. The data is decrypted locally.
+//
+// Open returns an error if the envelope is not authentic -
+// e.g. because it has been modified or truncated.
+func (c *Client) Open(envelope []byte) ([]byte, error) {
+	r, err := c.OpenReader(bytes.NewReader(envelope))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// SealWriter generates a new DEK with the key with the given
+// name, writes the envelope header to w and returns an
+// io.WriteCloser that encrypts everything written to it.
+//
+// The caller must close the returned io.WriteCloser to
+// complete the envelope. Closing it also closes w if w
+// implements io.Closer. See Seal for the envelope format.
+//
+// The envelope header refers to the key by the given name.
+// Hence, it should not be an alias that may refer to another
+// key once the envelope gets opened.
+func (c *Client) SealWriter(w io.Writer, key string) (io.WriteCloser, error) {
+	dek, err := c.GenerateKey(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.NewWriter(w, key, dek.Plaintext, dek.Ciphertext)
+}
+
+// OpenReader reads the envelope header from r, decrypts
+// the DEK of the envelope and returns an io.Reader that
+// decrypts the data read from r.
+//
+// The io.Reader returns an error if the data is not
+// authentic. Data read before such an error must not
+// be trusted.
+func (c *Client) OpenReader(r io.Reader) (io.Reader, error) {
+	header, r, err := envelope.ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.Decrypt(header.Key, header.DEK, nil)
+	if err != nil {
+		return nil, err
+	}
+	return header.NewReader(r, plaintext)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientSealOpen(t *testing.T) {
+	var (
+		plaintext  = bytes.Repeat([]byte{0x01}, 32)
+		ciphertext = []byte("encrypted-dek")
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/key/generate/my-key", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]byte{"plaintext": plaintext, "ciphertext": ciphertext})
+	})
+	mux.HandleFunc("/v1/key/decrypt/my-key", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Ciphertext []byte `json:"ciphertext"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !bytes.Equal(req.Ciphertext, ciphertext) {
+			http.Error(w, "invalid ciphertext", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]byte{"plaintext": plaintext})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &Client{Endpoint: server.URL}
+	for i, size := range []int{0, 1, 16 * 1024, 16*1024 + 1, 1 << 20} {
+		data := bytes.Repeat([]byte{'a'}, size)
+		envelope, err := client.Seal("my-key", data)
+		if err != nil {
+			t.Fatalf("Test %d: failed to seal data: %v", i, err)
+		}
+		if !bytes.HasPrefix(envelope, []byte(`{"version":1,"key":"my-key"`)) {
+			t.Fatalf("Test %d: invalid envelope header: %q", i, envelope[:bytes.IndexByte(envelope, '\n')])
+		}
+		opened, err := client.Open(envelope)
+		if err != nil {
+			t.Fatalf("Test %d: failed to open envelope: %v", i, err)
+		}
+		if !bytes.Equal(opened, data) {
+			t.Fatalf("Test %d: opened data does not match sealed data", i)
+		}
+
+		envelope[len(envelope)-1] ^= 1
+		if _, err = client.Open(envelope); err == nil {
+			t.Fatalf("Test %d: opened modified envelope", i)
+		}
+	}
+}
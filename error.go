@@ -28,6 +28,162 @@ var (
 	// ErrPolicyNotFound represents a KES server response returned when a client
 	// tries to access a policy which does not exist.
 	ErrPolicyNotFound Error = NewError(http.StatusNotFound, "policy does not exist")
+
+	// ErrIdentityExpired represents a KES server response returned when a
+	// client sends a request with an identity whose TTL has expired.
+	ErrIdentityExpired Error = NewError(http.StatusUnauthorized, "identity expired")
+
+	// ErrQuotaExceeded represents a KES server response returned when a
+	// client tries to create a key but the client identity or its tenant
+	// has already reached its key quota.
+	ErrQuotaExceeded Error = NewError(http.StatusForbidden, "key quota exceeded")
+
+	// ErrDecrypt represents a KES server response returned when a
+	// client tries to decrypt a ciphertext that is not authentic -
+	// e.g. because it has been encrypted with a different key or
+	// context.
+	ErrDecrypt Error = NewError(http.StatusBadRequest, "ciphertext is not authentic")
+
+	// ErrTooManyRequests represents a KES server response returned
+	// when a client has exceeded its request rate limit.
+	ErrTooManyRequests Error = NewError(http.StatusTooManyRequests, "too many requests")
+
+	// ErrOverloaded represents a KES server response returned when
+	// the server rejects a request because it is running out of
+	// memory. Clients may retry such requests later.
+	ErrOverloaded Error = NewError(http.StatusServiceUnavailable, "server is overloaded")
+
+	// ErrEnclaveNotFound represents a KES server response returned
+	// when a client tries to access an enclave which does not exist.
+	ErrEnclaveNotFound Error = NewError(http.StatusNotFound, "enclave does not exist")
+
+	// ErrEnclaveExists represents a KES server response returned
+	// when a client tries to create an enclave which already exists.
+	ErrEnclaveExists Error = NewError(http.StatusBadRequest, "enclave does already exist")
+
+	// ErrPreconditionFailed represents a KES server response returned
+	// when a conditional request - i.e. a request with an If-Match
+	// header - has not been applied because the key or policy has been
+	// modified in the meantime.
+	ErrPreconditionFailed Error = NewError(http.StatusPreconditionFailed, "precondition failed: resource has been modified")
+
+	// ErrRequestTooLarge represents a KES server response returned
+	// when a client sends a request body that exceeds the body size
+	// limit of the API.
+	ErrRequestTooLarge Error = NewError(http.StatusRequestEntityTooLarge, "request body too large")
+
+	// ErrUnsupportedMediaType represents a KES server response returned
+	// when a client sends a request body with a Content-Type that is
+	// not accepted by the API - e.g. a JSON API.
+	ErrUnsupportedMediaType Error = NewError(http.StatusUnsupportedMediaType, "unsupported content type")
+
+	// ErrTooManyConcurrentRequests represents a KES server response
+	// returned when a client has too many requests in-flight at the
+	// same time. Clients may retry such requests once some of their
+	// requests have completed.
+	ErrTooManyConcurrentRequests Error = NewError(http.StatusTooManyRequests, "too many concurrent requests")
+)
+
+// Key store and KMS errors. The server returns these errors when
+// a request to its key store or KMS fails. Clients may retry
+// requests that failed with an unavailable or throttled error.
+var (
+	// ErrBackendUnavailable represents a KES server response returned
+	// when the key store is not reachable or has not been connected.
+	ErrBackendUnavailable Error = NewError(http.StatusServiceUnavailable, "key store is not available")
+
+	// ErrBackendThrottled represents a KES server response returned
+	// when the key store has throttled the request.
+	ErrBackendThrottled Error = NewError(http.StatusServiceUnavailable, "key store is throttling requests")
+
+	// ErrBackendFailure represents a KES server response returned
+	// when the key store has rejected or failed the request.
+	ErrBackendFailure Error = NewError(http.StatusBadGateway, "key store request failed")
+
+	// ErrKMSUnavailable represents a KES server response returned
+	// when the KMS is not reachable or has not been connected.
+	ErrKMSUnavailable Error = NewError(http.StatusServiceUnavailable, "KMS is not available")
+
+	// ErrKMSThrottled represents a KES server response returned
+	// when the KMS has throttled the request.
+	ErrKMSThrottled Error = NewError(http.StatusServiceUnavailable, "KMS is throttling requests")
+
+	// ErrKMSFailure represents a KES server response returned
+	// when the KMS has rejected or failed the request.
+	ErrKMSFailure Error = NewError(http.StatusBadGateway, "KMS request failed")
+
+	// ErrCorrupted represents a KES server response returned when
+	// an entry at the key store is malformed and cannot be used -
+	// e.g. because it has been modified outside the server.
+	ErrCorrupted Error = NewError(http.StatusInternalServerError, "key store entry is corrupted")
+)
+
+// errorCodes maps errors to their machine-readable codes.
+// Codes must not change once they have been released.
+var errorCodes = map[Error]string{
+	ErrNotAllowed:      "not_allowed",
+	ErrKeyNotFound:     "key_not_found",
+	ErrKeyExists:       "key_exists",
+	ErrPolicyNotFound:  "policy_not_found",
+	ErrIdentityExpired: "identity_expired",
+	ErrQuotaExceeded:   "quota_exceeded",
+	ErrDecrypt:         "not_authentic",
+	ErrTooManyRequests: "too_many_requests",
+	ErrOverloaded:      "overloaded",
+	ErrEnclaveNotFound: "enclave_not_found",
+	ErrEnclaveExists:   "enclave_exists",
+
+	ErrBackendUnavailable: "backend_unavailable",
+	ErrBackendThrottled:   "backend_throttled",
+	ErrBackendFailure:     "backend_failure",
+	ErrKMSUnavailable:     "kms_unavailable",
+	ErrKMSThrottled:       "kms_throttled",
+	ErrKMSFailure:         "kms_failure",
+	ErrCorrupted:          "corrupted",
+
+	ErrPreconditionFailed: "precondition_failed",
+
+	ErrRequestTooLarge:      "request_too_large",
+	ErrUnsupportedMediaType: "unsupported_media_type",
+
+	ErrTooManyConcurrentRequests: "too_many_concurrent_requests",
+}
+
+// Error classes. Each server error belongs to the error class
+// with the same HTTP status code. For example:
+//   errors.Is(ErrKeyNotFound, ErrNotFound) // true
+//
+// Error classes allow clients to handle server errors that don't
+// have their own error value - e.g. any 4xx client error.
+var (
+	// ErrBadRequest is the class of errors caused by
+	// malformed or invalid requests.
+	ErrBadRequest Error = NewError(http.StatusBadRequest, "")
+
+	// ErrUnauthorized is the class of errors caused by
+	// invalid or expired client credentials.
+	ErrUnauthorized Error = NewError(http.StatusUnauthorized, "")
+
+	// ErrForbidden is the class of errors caused by
+	// insufficient permissions.
+	ErrForbidden Error = NewError(http.StatusForbidden, "")
+
+	// ErrNotFound is the class of errors caused by
+	// referencing a non-existing resource - like a key.
+	ErrNotFound Error = NewError(http.StatusNotFound, "")
+
+	// ErrInternal is the class of errors caused by
+	// an unexpected server failure.
+	ErrInternal Error = NewError(http.StatusInternalServerError, "")
+
+	// ErrBadGateway is the class of errors caused by
+	// a failed request to the key store or KMS.
+	ErrBadGateway Error = NewError(http.StatusBadGateway, "")
+
+	// ErrUnavailable is the class of errors caused by
+	// a server that is temporarily not available - e.g.
+	// because its key store is not reachable.
+	ErrUnavailable Error = NewError(http.StatusServiceUnavailable, "")
 )
 
 // Error is the type of client-server API errors.
@@ -71,6 +227,26 @@ func (e Error) Status() int { return e.code }
 
 func (e Error) Error() string { return e.message }
 
+// Code returns the machine-readable code of the error - e.g.
+// "key_not_found". Codes are stable across server versions such
+// that clients can branch on them instead of error messages.
+// It returns an empty string if the error has no code.
+//
+// The server sends the code as part of error responses.
+func (e Error) Code() string { return errorCodes[e] }
+
+// Is reports whether the target is the error class
+// of e. An error class is an Error with an empty
+// message. Any Error belongs to the error class with
+// the same status code. For example:
+//   errors.Is(ErrKeyExists, ErrBadRequest) // true
+//
+// Is is used by errors.Is.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.message == "" && t.code == e.code
+}
+
 // parseErrorResponse returns an error containing
 // the response status code and response body
 // as error message if the response is an error
@@ -101,11 +277,19 @@ func parseErrorResponse(resp *http.Response) error {
 	if strings.HasPrefix(contentType, "application/json") {
 		type Response struct {
 			Message string `json:"message"`
+			Code    string `json:"code"`
 		}
 		var response Response
 		if err := json.NewDecoder(io.LimitReader(resp.Body, size)).Decode(&response); err != nil {
 			return err
 		}
+		if response.Code != "" {
+			for err, code := range errorCodes {
+				if code == response.Code && err.code == resp.StatusCode {
+					return err
+				}
+			}
+		}
 		return NewError(resp.StatusCode, response.Message)
 	}
 
@@ -5,7 +5,10 @@
 package kes
 
 import (
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +31,56 @@ func TestNewError(t *testing.T) {
 		}
 	}
 }
+
+var errorIsTests = []struct {
+	Err    error
+	Target error
+	Is     bool
+}{
+	{Err: ErrKeyNotFound, Target: ErrKeyNotFound, Is: true},
+	{Err: ErrKeyNotFound, Target: ErrNotFound, Is: true},
+	{Err: ErrKeyExists, Target: ErrBadRequest, Is: true},
+	{Err: ErrTooManyRequests, Target: ErrUnavailable, Is: false},
+	{Err: ErrOverloaded, Target: ErrUnavailable, Is: true},
+	{Err: ErrNotFound, Target: ErrKeyNotFound, Is: false},
+	{Err: ErrPolicyNotFound, Target: ErrKeyNotFound, Is: false},
+}
+
+func TestErrorIs(t *testing.T) {
+	for i, test := range errorIsTests {
+		if is := errors.Is(test.Err, test.Target); is != test.Is {
+			t.Fatalf("Test %d: got %v - want %v", i, is, test.Is)
+		}
+	}
+}
+
+var parseErrorResponseTests = []struct {
+	Status int
+	Body   string
+	Err    Error
+	Code   string
+}{
+	{Status: http.StatusNotFound, Body: `{"message":"key does not exist"}`, Err: ErrKeyNotFound, Code: "key_not_found"},
+	{Status: http.StatusNotFound, Body: `{"message":"no such key","code":"key_not_found"}`, Err: ErrKeyNotFound, Code: "key_not_found"},
+	{Status: http.StatusServiceUnavailable, Body: `{"message":"KMS is throttling requests","code":"kms_throttled"}`, Err: ErrKMSThrottled, Code: "kms_throttled"},
+	{Status: http.StatusBadRequest, Body: `{"message":"invalid argument","code":"unknown"}`, Err: NewError(http.StatusBadRequest, "invalid argument")},
+	{Status: http.StatusInternalServerError, Body: `{"message":"key does not exist","code":"key_not_found"}`, Err: NewError(http.StatusInternalServerError, "key does not exist")},
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	for i, test := range parseErrorResponseTests {
+		resp := &http.Response{
+			StatusCode:    test.Status,
+			ContentLength: -1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          ioutil.NopCloser(strings.NewReader(test.Body)),
+		}
+		err := parseErrorResponse(resp)
+		if err != test.Err {
+			t.Fatalf("Test %d: got %v - want %v", i, err, test.Err)
+		}
+		if code := err.(Error).Code(); code != test.Code {
+			t.Fatalf("Test %d: got code '%s' - want '%s'", i, code, test.Code)
+		}
+	}
+}
@@ -5,12 +5,15 @@ go 1.13
 require (
 	github.com/aws/aws-sdk-go v1.26.3
 	github.com/fatih/color v1.7.0
+	github.com/golang/protobuf v1.3.1
 	github.com/hashicorp/vault/api v1.0.4
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/secure-io/sio-go v0.3.0
 	github.com/stretchr/testify v1.4.0 // indirect
 	golang.org/x/crypto v0.0.0-20191117063200-497ca9f6d64f
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d
+	google.golang.org/grpc v1.22.0
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v2 v2.2.4
 )
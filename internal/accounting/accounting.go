@@ -0,0 +1,227 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package accounting counts the API requests of each
+// identity in fixed time windows - e.g. per hour - such
+// that the load caused by a particular workload can be
+// attributed and unusual spikes can be detected.
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// Record is the number of requests an identity
+// has sent to an API within a time window.
+type Record struct {
+	Start    time.Time    `json:"start"` // The start of the time window
+	Identity kes.Identity `json:"identity"`
+	API      string       `json:"api"`
+	Requests uint64       `json:"requests"`
+}
+
+// Accounting counts the requests of each identity per
+// API in fixed time windows. Windows older than the
+// retention period are discarded.
+//
+// An Accounting is safe for concurrent use once it
+// has been configured.
+type Accounting struct {
+	// Window is the length of a time window.
+	Window time.Duration
+
+	// Retention is the period for which time
+	// windows are kept.
+	Retention time.Duration
+
+	// Remote is the Remote store of the server. The
+	// counters are persisted at the Remote store under
+	// secret.ReservedAccountingName. See: Save
+	Remote secret.Remote
+
+	lock     sync.Mutex
+	windows  map[int64]map[counter]uint64 // Maps the start of a window, in Unix time, to its counters
+	dirty    bool                         // Whether the counters have changed since the last Save
+	saveLock sync.Mutex                   // Orders concurrent calls of Save
+
+	now func() time.Time // For testing. If nil, time.Now is used.
+}
+
+type counter struct {
+	Identity kes.Identity
+	API      string
+}
+
+// Add counts a request of the identity to the API.
+func (a *Accounting) Add(identity kes.Identity, api string) {
+	now := a.clock()
+	start := now.Truncate(a.Window).Unix()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.windows == nil {
+		a.windows = map[int64]map[counter]uint64{}
+	}
+	window, ok := a.windows[start]
+	if !ok {
+		window = map[counter]uint64{}
+		a.windows[start] = window
+		a.prune(now)
+	}
+	window[counter{Identity: identity, API: api}]++
+	a.dirty = true
+}
+
+// Report returns the counters of all time windows that end
+// after since - sorted by window start, identity and API.
+func (a *Accounting) Report(since time.Time) []Record {
+	a.lock.Lock()
+	var records []Record
+	for start, window := range a.windows {
+		t := time.Unix(start, 0).UTC()
+		if !t.Add(a.Window).After(since) {
+			continue
+		}
+		for c, n := range window {
+			records = append(records, Record{
+				Start:    t,
+				Identity: c.Identity,
+				API:      c.API,
+				Requests: n,
+			})
+		}
+	}
+	a.lock.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Start.Equal(records[j].Start) {
+			return records[i].Start.Before(records[j].Start)
+		}
+		if records[i].Identity != records[j].Identity {
+			return records[i].Identity < records[j].Identity
+		}
+		return records[i].API < records[j].API
+	})
+	return records
+}
+
+// Save writes the counters to the Remote store. It does
+// nothing if no Remote store is set or the counters have
+// not changed since the last Save. Hence, it should be
+// called periodically to persist the counters in batches.
+//
+// If the encoded counters exceed secret.MaxSize, Save
+// discards the oldest time windows until they fit.
+//
+// Save replaces the entry via secret.Replace. If it
+// fails, the Remote store keeps the previous counters.
+func (a *Accounting) Save() error {
+	if a.Remote == nil {
+		return nil
+	}
+
+	// The save lock ensures that concurrent calls to Save
+	// write their snapshots in order. Otherwise, an older
+	// snapshot may overwrite a more recent one.
+	a.saveLock.Lock()
+	defer a.saveLock.Unlock()
+
+	a.lock.Lock()
+	if !a.dirty {
+		a.lock.Unlock()
+		return nil
+	}
+	a.dirty = false
+	a.lock.Unlock()
+
+	records := a.Report(time.Time{})
+	value, err := json.Marshal(records)
+	for err == nil && len(value) > secret.MaxSize && len(records) > 0 {
+		oldest := records[0].Start
+		for len(records) > 0 && records[0].Start.Equal(oldest) {
+			records = records[1:]
+		}
+		value, err = json.Marshal(records)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = secret.Replace(a.Remote, secret.ReservedAccountingName, string(value)); err != nil {
+		a.markDirty()
+		return err
+	}
+	return nil
+}
+
+// Load reads the counters from the Remote store and replaces
+// the counters kept in memory. It does nothing if no Remote
+// store is set or the Remote store does not contain any
+// counters.
+func (a *Accounting) Load() error {
+	if a.Remote == nil {
+		return nil
+	}
+
+	value, err := secret.Load(a.Remote, secret.ReservedAccountingName)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []Record
+	if err = json.Unmarshal([]byte(value), &records); err != nil {
+		return errors.New("accounting: persisted counters are malformed")
+	}
+
+	windows := map[int64]map[counter]uint64{}
+	for _, r := range records {
+		start := r.Start.Truncate(a.Window).Unix()
+		window, ok := windows[start]
+		if !ok {
+			window = map[counter]uint64{}
+			windows[start] = window
+		}
+		window[counter{Identity: r.Identity, API: r.API}] += r.Requests
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.windows = windows
+	a.dirty = false
+	a.prune(a.clock())
+	return nil
+}
+
+// prune removes all time windows that have ended before
+// the retention period. The caller must hold the lock.
+func (a *Accounting) prune(now time.Time) {
+	for start := range a.windows {
+		if !time.Unix(start, 0).Add(a.Window).After(now.Add(-a.Retention)) {
+			delete(a.windows, start)
+		}
+	}
+}
+
+func (a *Accounting) markDirty() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.dirty = true
+}
+
+func (a *Accounting) clock() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
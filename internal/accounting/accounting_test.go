@@ -0,0 +1,75 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestAccounting(t *testing.T) {
+	var (
+		remote = &mem.Store{}
+		now    = time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	)
+	accounting := &Accounting{
+		Window:    time.Hour,
+		Retention: 3 * time.Hour,
+		Remote:    remote,
+		now:       func() time.Time { return now },
+	}
+
+	accounting.Add("app", "/v1/key/generate/")
+	accounting.Add("app", "/v1/key/generate/")
+	accounting.Add("ops", "/v1/key/create/")
+	now = now.Add(time.Hour)
+	accounting.Add("app", "/v1/key/decrypt/")
+
+	records := accounting.Report(time.Time{})
+	if len(records) != 3 {
+		t.Fatalf("Invalid number of records: got %d - want 3: %v", len(records), records)
+	}
+	if r := records[0]; r.Identity != "app" || r.API != "/v1/key/generate/" || r.Requests != 2 || !r.Start.Equal(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Invalid record: %v", r)
+	}
+	if r := records[2]; r.Identity != "app" || r.API != "/v1/key/decrypt/" || !r.Start.Equal(time.Date(2020, 6, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Invalid record: %v", r)
+	}
+	if records = accounting.Report(now.Add(-10 * time.Minute)); len(records) != 1 {
+		t.Fatalf("Invalid number of records since %v: got %d - want 1", now.Add(-10*time.Minute), len(records))
+	}
+
+	if err := accounting.Save(); err != nil {
+		t.Fatalf("Failed to save counters: %v", err)
+	}
+	if _, err := remote.Get(secret.ReservedAccountingName); err != nil {
+		t.Fatalf("Counters have not been saved: %v", err)
+	}
+	loaded := &Accounting{
+		Window:    time.Hour,
+		Retention: 3 * time.Hour,
+		Remote:    remote,
+		now:       func() time.Time { return now },
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Failed to load counters: %v", err)
+	}
+	if records = loaded.Report(time.Time{}); len(records) != 3 || records[0].Requests != 2 {
+		t.Fatalf("Invalid loaded records: %v", records)
+	}
+
+	// Once the retention period has passed, the first
+	// window must be discarded.
+	now = now.Add(3 * time.Hour)
+	accounting.Add("app", "/v1/key/generate/")
+	for _, r := range accounting.Report(time.Time{}) {
+		if r.Start.Hour() == 10 {
+			t.Fatalf("Window has not been discarded: %v", r)
+		}
+	}
+}
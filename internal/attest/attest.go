@@ -0,0 +1,153 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package attest produces hardware attestation reports
+// when the KES server runs inside a confidential VM - i.e.
+// AMD SEV-SNP or Intel TDX - or an Intel SGX enclave.
+//
+// A report contains 64 bytes of report data chosen by the
+// server. The server binds its TLS public key and a client
+// nonce to the report. See: kes.AttestationReportData
+package attest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/minio/kes"
+)
+
+// Provider produces hardware attestation reports.
+type Provider interface {
+	// Report returns a hardware attestation report that
+	// contains the given report data and the type of the
+	// report - e.g. kes.AttestationSEVSNP.
+	Report(reportData [64]byte) (report []byte, typ string, err error)
+}
+
+// TSM is a Provider that uses the Linux configfs-tsm
+// interface of a confidential VM. It supports AMD SEV-SNP
+// and Intel TDX guests.
+type TSM struct {
+	// Path is the configfs-tsm report directory.
+	// If empty, /sys/kernel/config/tsm/report is
+	// used.
+	Path string
+
+	lock sync.Mutex
+	n    uint64
+}
+
+var _ Provider = (*TSM)(nil)
+
+// Report returns a new SEV-SNP attestation report
+// or TDX quote that contains the report data.
+func (t *TSM) Report(reportData [64]byte) ([]byte, string, error) {
+	path := t.Path
+	if path == "" {
+		path = "/sys/kernel/config/tsm/report"
+	}
+
+	t.lock.Lock()
+	t.n++
+	entry := filepath.Join(path, "kes-"+strconv.Itoa(os.Getpid())+"-"+strconv.FormatUint(t.n, 10))
+	t.lock.Unlock()
+
+	if err := os.Mkdir(entry, 0700); err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+	defer os.Remove(entry)
+
+	generation, err := ioutil.ReadFile(filepath.Join(entry, "generation"))
+	if err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(entry, "inblob"), reportData[:], 0600); err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+	report, err := ioutil.ReadFile(filepath.Join(entry, "outblob"))
+	if err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+	provider, err := ioutil.ReadFile(filepath.Join(entry, "provider"))
+	if err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+
+	// The generation is incremented whenever the inblob
+	// is written. If it has been written more than once,
+	// the report may contain other report data.
+	next, err := ioutil.ReadFile(filepath.Join(entry, "generation"))
+	if err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create report: %v", err)
+	}
+	if !isNextGeneration(generation, next) {
+		return nil, "", errors.New("attest: report has been modified concurrently")
+	}
+
+	switch p := string(bytes.TrimSpace(provider)); p {
+	case "sev_guest":
+		return report, kes.AttestationSEVSNP, nil
+	case "tdx_guest":
+		return report, kes.AttestationTDX, nil
+	default:
+		return nil, "", fmt.Errorf("attest: unsupported configfs-tsm provider '%s'", p)
+	}
+}
+
+// SGX is a Provider that uses the /dev/attestation
+// interface of an SGX enclave - as provided by Gramine.
+type SGX struct {
+	// Path is the attestation directory. If empty,
+	// /dev/attestation is used.
+	Path string
+
+	lock sync.Mutex
+}
+
+var _ Provider = (*SGX)(nil)
+
+// Report returns a new SGX quote that contains the
+// report data.
+func (s *SGX) Report(reportData [64]byte) ([]byte, string, error) {
+	path := s.Path
+	if path == "" {
+		path = "/dev/attestation"
+	}
+
+	// The user report data is shared by all threads
+	// of the enclave. Hence, we must not request more
+	// than one quote at the same time.
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := ioutil.WriteFile(filepath.Join(path, "user_report_data"), reportData[:], 0600); err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create quote: %v", err)
+	}
+	quote, err := ioutil.ReadFile(filepath.Join(path, "quote"))
+	if err != nil {
+		return nil, "", fmt.Errorf("attest: failed to create quote: %v", err)
+	}
+	return quote, kes.AttestationSGX, nil
+}
+
+// isNextGeneration reports whether next is the generation
+// that directly follows the given generation.
+func isNextGeneration(generation, next []byte) bool {
+	g, err := strconv.ParseUint(string(bytes.TrimSpace(generation)), 10, 64)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(next)), 10, 64)
+	if err != nil {
+		return false
+	}
+	return n == g+1
+}
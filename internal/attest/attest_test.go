@@ -0,0 +1,69 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package attest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestSGX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-attest")
+	if err != nil {
+		t.Fatalf("Failed to create attestation directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	quote := []byte("sgx-quote")
+	if err = ioutil.WriteFile(filepath.Join(dir, "quote"), quote, 0600); err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+
+	var reportData [64]byte
+	copy(reportData[:], "report-data")
+	report, typ, err := (&SGX{Path: dir}).Report(reportData)
+	if err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+	if typ != kes.AttestationSGX {
+		t.Fatalf("Invalid report type: got '%s' - want '%s'", typ, kes.AttestationSGX)
+	}
+	if !bytes.Equal(report, quote) {
+		t.Fatalf("Invalid quote: got '%s' - want '%s'", report, quote)
+	}
+	userReportData, err := ioutil.ReadFile(filepath.Join(dir, "user_report_data"))
+	if err != nil {
+		t.Fatalf("Failed to read user report data: %v", err)
+	}
+	if !bytes.Equal(userReportData, reportData[:]) {
+		t.Fatalf("Invalid user report data: got %x - want %x", userReportData, reportData)
+	}
+}
+
+var isNextGenerationTests = []struct {
+	Generation string
+	Next       string
+	IsNext     bool
+}{
+	{Generation: "0\n", Next: "1\n", IsNext: true},  // 0
+	{Generation: "41", Next: "42", IsNext: true},    // 1
+	{Generation: "1\n", Next: "1\n", IsNext: false}, // 2
+	{Generation: "1\n", Next: "3\n", IsNext: false}, // 3
+	{Generation: "", Next: "1\n", IsNext: false},    // 4
+	{Generation: "a", Next: "b", IsNext: false},     // 5
+}
+
+func TestIsNextGeneration(t *testing.T) {
+	for i, test := range isNextGenerationTests {
+		if isNext := isNextGeneration([]byte(test.Generation), []byte(test.Next)); isNext != test.IsNext {
+			t.Fatalf("Test %d: got %v - want %v", i, isNext, test.IsNext)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// DefaultApprovalAPIs are the APIs that require approval
+// if no APIs are specified explicitly. See: Approvals.APIs
+var DefaultApprovalAPIs = []string{
+	"/v1/key/bulk/delete/",
+	"/v1/job/submit/delete-keys",
+	"/v1/admin/restore",
+	"/v1/enclave/delete/",
+}
+
+// Approvals implements a break-glass mode for the root
+// identity. Destructive operations of the root identity -
+// e.g. deleting many keys at once - require the approval
+// of multiple admin identities. Hence, a single compromised
+// root or admin identity cannot perform such operations
+// on its own.
+//
+// An admin approves one particular request - i.e. its
+// method and URL path - by issuing an approval token. The
+// root identity has to send the request with the tokens of
+// at least Quorum distinct admins as kes.HeaderApproval
+// headers. Each token can be used only once and expires
+// after the approval window.
+//
+// Used tokens are recorded at the Remote store. Hence, a
+// token cannot be used again after a restart or at another
+// server sharing the Remote store - e.g. another cluster node.
+type Approvals struct {
+	// Admins are the identities that can approve
+	// requests of the root identity.
+	Admins []kes.Identity
+
+	// Quorum is the number of distinct admins that
+	// have to approve a request.
+	Quorum int
+
+	// Window is the time span in which an approval
+	// token can be used. If <= 0, it defaults to 15
+	// minutes.
+	Window time.Duration
+
+	// APIs are the URL path prefixes of the APIs that
+	// require approval. If empty, DefaultApprovalAPIs
+	// is used.
+	APIs []string
+
+	// Secret is an optional secret used to authenticate
+	// approval tokens. Servers with the same secret accept
+	// each other's tokens. If empty, a random secret is
+	// generated.
+	Secret []byte
+
+	// Remote is the key-value store that records the
+	// used approval tokens. Servers that accept each
+	// other's tokens must share the Remote store. If
+	// nil, used tokens are only recorded in memory.
+	Remote secret.Remote
+
+	lock   sync.Mutex
+	used   map[string]time.Time // The nonces of used tokens and their expiry
+	loaded bool                 // Whether the used tokens have been loaded from the Remote store
+}
+
+// An Approval is the approval of one request by a quorum
+// of admins. Its tokens are used up unless the Approval
+// gets released.
+type Approval struct {
+	// Approvers are the admins that have approved
+	// the request.
+	Approvers []kes.Identity
+
+	approvals *Approvals
+	nonces    []string
+}
+
+// Release releases the approval tokens such that they
+// can be used again - e.g. because the approved request
+// has failed.
+func (a *Approval) Release() error {
+	a.approvals.lock.Lock()
+	defer a.approvals.lock.Unlock()
+	return a.approvals.release(a.nonces)
+}
+
+var (
+	errNotApprover         = kes.NewError(http.StatusForbidden, "identity is not an approver")
+	errApprovalNotRequired = kes.NewError(http.StatusBadRequest, "API does not require approval")
+)
+
+// approvalToken is the content of an approval token.
+type approvalToken struct {
+	Identity kes.Identity `json:"identity"`
+	Method   string       `json:"method"`
+	Path     string       `json:"path"`
+	Expiry   int64        `json:"exp"`
+	Nonce    string       `json:"nonce"`
+}
+
+// Required reports whether requests of the root identity
+// with the given URL path require approval.
+func (a *Approvals) Required(apiPath string) bool {
+	apis := a.APIs
+	if len(apis) == 0 {
+		apis = DefaultApprovalAPIs
+	}
+	for _, api := range apis {
+		if strings.HasPrefix(apiPath, api) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the identity can approve
+// requests of the root identity.
+func (a *Approvals) IsAdmin(identity kes.Identity) bool {
+	for _, admin := range a.Admins {
+		if !identity.IsUnknown() && secret.EqualIdentity(identity, admin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Issue returns an approval token of the admin for a request
+// of the root identity with the given method and URL path. It
+// also returns the point in time when the token expires.
+func (a *Approvals) Issue(admin kes.Identity, method, apiPath string) (string, time.Time, error) {
+	if !a.IsAdmin(admin) {
+		return "", time.Time{}, errNotApprover
+	}
+	if !a.Required(apiPath) {
+		return "", time.Time{}, errApprovalNotRequired
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", time.Time{}, err
+	}
+	key, err := a.secret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiry := time.Now().Add(a.window()).Truncate(time.Second)
+	payload, err := json.Marshal(approvalToken{
+		Identity: admin,
+		Method:   method,
+		Path:     apiPath,
+		Expiry:   expiry.Unix(),
+		Nonce:    hex.EncodeToString(nonce[:]),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return token, expiry, nil
+}
+
+// Approve verifies the approval tokens of the request sent by
+// the requester. It returns the Approval of the request if at
+// least Quorum distinct admins other than the requester have
+// approved it. Otherwise, it returns an error.
+//
+// Invalid, expired and already used tokens are ignored. The
+// tokens of an Approval are used up - i.e. cannot be used for
+// another request - unless the Approval gets released.
+func (a *Approvals) Approve(req *http.Request, requester kes.Identity) (*Approval, error) {
+	key, err := a.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err = a.load(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	a.prune(now)
+
+	var (
+		approval = &Approval{approvals: a}
+		seen     = map[kes.Identity]bool{}
+	)
+	for _, value := range req.Header[http.CanonicalHeaderKey(kes.HeaderApproval)] {
+		for _, token := range strings.Split(value, ",") {
+			t, ok := parseApproval(key, strings.TrimSpace(token))
+			if !ok || seen[t.Identity] {
+				continue
+			}
+			if t.Method != req.Method || t.Path != req.URL.Path {
+				continue
+			}
+			expiry := time.Unix(t.Expiry, 0)
+			if !now.Before(expiry) {
+				continue
+			}
+			if !a.IsAdmin(t.Identity) || secret.EqualIdentity(t.Identity, requester) {
+				continue
+			}
+
+			if err = a.use(t.Nonce, expiry); err == kes.ErrKeyExists {
+				continue
+			}
+			if err != nil {
+				a.release(approval.nonces)
+				return nil, err
+			}
+			seen[t.Identity] = true
+			approval.Approvers = append(approval.Approvers, t.Identity)
+			approval.nonces = append(approval.nonces, t.Nonce)
+		}
+	}
+	if len(approval.Approvers) < a.Quorum {
+		if err = a.release(approval.nonces); err != nil {
+			return nil, err
+		}
+		return nil, kes.NewError(http.StatusForbidden, fmt.Sprintf("operation requires the approval of %d admins: %d approved", a.Quorum, len(approval.Approvers)))
+	}
+	return approval, nil
+}
+
+// load loads the used tokens from the Remote store once
+// such that tokens used before a restart get pruned once
+// they expire. It ignores Remote stores that cannot list
+// their entries.
+func (a *Approvals) load() error {
+	if a.loaded || a.Remote == nil {
+		return nil
+	}
+	names, err := secret.ListPrefix(a.Remote, secret.ReservedApprovalPrefix)
+	if err != nil && err != secret.ErrListNotSupported {
+		return err
+	}
+	if a.used == nil {
+		a.used = map[string]time.Time{}
+	}
+	for _, name := range names {
+		value, err := a.Remote.Get(name)
+		if err == kes.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		expiry, _ := strconv.ParseInt(value, 10, 64) // A malformed expiry gets pruned
+		a.used[strings.TrimPrefix(name, secret.ReservedApprovalPrefix)] = time.Unix(expiry, 0)
+	}
+	a.loaded = true
+	return nil
+}
+
+// use marks the token with the given nonce as used until
+// it expires. It returns kes.ErrKeyExists if the token has
+// been used already - possibly by another server sharing
+// the Remote store.
+func (a *Approvals) use(nonce string, expiry time.Time) error {
+	if a.Remote != nil {
+		if err := a.Remote.Create(secret.ReservedApprovalPrefix+nonce, strconv.FormatInt(expiry.Unix(), 10)); err != nil {
+			return err
+		}
+	} else if _, used := a.used[nonce]; used {
+		return kes.ErrKeyExists
+	}
+	if a.used == nil {
+		a.used = map[string]time.Time{}
+	}
+	a.used[nonce] = expiry
+	return nil
+}
+
+// release marks the tokens with the given nonces
+// as unused.
+func (a *Approvals) release(nonces []string) error {
+	for _, nonce := range nonces {
+		if a.Remote != nil {
+			if err := a.Remote.Delete(secret.ReservedApprovalPrefix + nonce); err != nil {
+				return err
+			}
+		}
+		delete(a.used, nonce)
+	}
+	return nil
+}
+
+// prune removes all expired tokens. Expired tokens are
+// rejected anyway. It keeps tokens that cannot be removed
+// from the Remote store and retries on the next call.
+func (a *Approvals) prune(now time.Time) {
+	for nonce, expiry := range a.used {
+		if !now.After(expiry) {
+			continue
+		}
+		if a.Remote != nil {
+			if err := a.Remote.Delete(secret.ReservedApprovalPrefix + nonce); err != nil {
+				continue
+			}
+		}
+		delete(a.used, nonce)
+	}
+}
+
+func (a *Approvals) window() time.Duration {
+	if a.Window <= 0 {
+		return 15 * time.Minute
+	}
+	return a.Window
+}
+
+// secret returns the key used to authenticate approval
+// tokens. It generates a random key if no Secret is set.
+func (a *Approvals) secret() ([]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if len(a.Secret) == 0 {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, err
+		}
+		a.Secret = key[:]
+	}
+	return a.Secret, nil
+}
+
+// parseApproval parses and authenticates the approval
+// token. It reports whether the token is valid.
+func parseApproval(key []byte, token string) (approvalToken, bool) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return approvalToken{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return approvalToken{}, false
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return approvalToken{}, false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return approvalToken{}, false
+	}
+
+	var a approvalToken
+	if err = json.Unmarshal(payload, &a); err != nil {
+		return approvalToken{}, false
+	}
+	return a, true
+}
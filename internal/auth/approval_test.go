@@ -0,0 +1,139 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+)
+
+func TestApprovals(t *testing.T) {
+	approvals := &Approvals{
+		Admins: []kes.Identity{"admin-1", "admin-2", "admin-3"},
+		Quorum: 2,
+	}
+	if _, _, err := approvals.Issue("root", http.MethodDelete, "/v1/enclave/delete/my-enclave"); err != errNotApprover {
+		t.Fatalf("Issued approval of non-admin: got %v - want %v", err, errNotApprover)
+	}
+	if _, _, err := approvals.Issue("admin-1", http.MethodPost, "/v1/key/create/my-key"); err != errApprovalNotRequired {
+		t.Fatalf("Issued approval for API without approval: got %v - want %v", err, errApprovalNotRequired)
+	}
+
+	issue := func(admin kes.Identity, path string) string {
+		token, expiry, err := approvals.Issue(admin, http.MethodDelete, path)
+		if err != nil {
+			t.Fatalf("Failed to issue approval: %v", err)
+		}
+		if !expiry.After(time.Now()) {
+			t.Fatalf("Approval has already expired: %v", expiry)
+		}
+		return token
+	}
+	const Path = "/v1/enclave/delete/my-enclave"
+	var (
+		token1 = issue("admin-1", Path)
+		token2 = issue("admin-2", Path)
+		token3 = issue("admin-3", "/v1/enclave/delete/other-enclave")
+	)
+
+	for i, test := range []struct {
+		Tokens    []string
+		Approvers int
+		Fail      bool
+	}{
+		{Tokens: []string{token1}, Fail: true},                          // 0
+		{Tokens: []string{token1, token1}, Fail: true},                  // 1
+		{Tokens: []string{token1, token3}, Fail: true},                  // 2
+		{Tokens: []string{token1, token2 + "x"}, Fail: true},            // 3
+		{Tokens: []string{token1 + "," + token2, token3}, Approvers: 2}, // 4
+		{Tokens: []string{token1, token2}, Fail: true},                  // 5: tokens have been used
+	} {
+		req, err := http.NewRequest(http.MethodDelete, "https://localhost:7373"+Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		for _, token := range test.Tokens {
+			req.Header.Add(kes.HeaderApproval, token)
+		}
+		approval, err := approvals.Approve(req, "root")
+		if test.Fail && err == nil {
+			t.Fatalf("Test %d: request should have been rejected", i)
+		}
+		if !test.Fail && err != nil {
+			t.Fatalf("Test %d: failed to approve request: %v", i, err)
+		}
+		if err == nil && len(approval.Approvers) != test.Approvers {
+			t.Fatalf("Test %d: got %d approvers - want %d", i, len(approval.Approvers), test.Approvers)
+		}
+	}
+}
+
+func TestApprovalsRemote(t *testing.T) {
+	const Path = "/v1/enclave/delete/my-enclave"
+	var (
+		remote  = &mem.Store{}
+		newNode = func() *Approvals {
+			return &Approvals{
+				Admins: []kes.Identity{"admin-1", "admin-2"},
+				Quorum: 2,
+				Secret: make([]byte, 32),
+				Remote: remote,
+			}
+		}
+		node1, node2 = newNode(), newNode()
+	)
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodDelete, "https://localhost:7373"+Path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		for _, admin := range []kes.Identity{"admin-1", "admin-2"} {
+			token, _, err := node1.Issue(admin, http.MethodDelete, Path)
+			if err != nil {
+				t.Fatalf("Failed to issue approval: %v", err)
+			}
+			req.Header.Add(kes.HeaderApproval, token)
+		}
+		return req
+	}
+
+	// A released approval can be used again - even at
+	// another server sharing the Remote store.
+	req := newRequest()
+	approval, err := node1.Approve(req, "root")
+	if err != nil {
+		t.Fatalf("Failed to approve request: %v", err)
+	}
+	if _, err = node2.Approve(req, "root"); err == nil {
+		t.Fatal("Approval tokens have been used concurrently at another server")
+	}
+	if err = approval.Release(); err != nil {
+		t.Fatalf("Failed to release approval: %v", err)
+	}
+	if _, err = node2.Approve(req, "root"); err != nil {
+		t.Fatalf("Failed to approve request after release: %v", err)
+	}
+	if _, err = node1.Approve(req, "root"); err == nil {
+		t.Fatal("Approval tokens used at another server have been accepted")
+	}
+
+	// Used tokens remain used after a restart.
+	if _, err = newNode().Approve(req, "root"); err == nil {
+		t.Fatal("Approval tokens have been accepted after a restart")
+	}
+
+	// Expired tokens get removed from the Remote store.
+	if _, err = node1.Approve(newRequest(), "root"); err != nil {
+		t.Fatalf("Failed to approve request: %v", err)
+	}
+	node1.prune(time.Now().Add(time.Hour))
+	if len(node1.used) != 0 {
+		t.Fatalf("Expired tokens have not been pruned: %d remaining", len(node1.used))
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/minio/kes"
+)
+
+// Authorizer is an authorization hook that decides whether
+// an authenticated request is allowed. It augments or replaces
+// the built-in policy evaluation - e.g. by delegating the
+// decision to an external policy engine. See: Roles.Authorizer
+//
+// Authorize receives the authenticated identity and the
+// decision of the built-in policies - i.e. nil if they allow
+// the request and an error otherwise. It returns nil if the
+// request is allowed and an error otherwise.
+//
+// An Authorizer that only wants to restrict the built-in
+// policies further should return a non-nil decision as is.
+type Authorizer interface {
+	Authorize(req *http.Request, identity kes.Identity, decision error) error
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary
+// functions as Authorizer.
+type AuthorizerFunc func(*http.Request, kes.Identity, error) error
+
+// Authorize calls f(req, identity, decision).
+func (f AuthorizerFunc) Authorize(req *http.Request, identity kes.Identity, decision error) error {
+	return f(req, identity, decision)
+}
+
+// The modes of an HTTPAuthorizer.
+const (
+	// AuthzAugment allows a request only if the built-in
+	// policies and the policy engine allow it.
+	AuthzAugment = "augment"
+
+	// AuthzReplace allows a request if the policy engine
+	// allows it - regardless of the built-in policies.
+	AuthzReplace = "replace"
+)
+
+// HTTPAuthorizer is an Authorizer that asks an external
+// policy engine - e.g. an Open Policy Agent (OPA) - via
+// HTTP whether a request is allowed.
+//
+// It sends a POST request with the JSON document:
+//  {
+//    "input": {
+//      "identity": "<identity>",
+//      "method":   "<method>",
+//      "path":     "<path>",
+//      "key":      "<key-name>",
+//      "address":  "<client-ip>",
+//      "allowed":  <built-in-decision>
+//    }
+//  }
+// The policy engine must respond with {"result": true} or
+// {"result": {"allow": true}} to allow the request. Any other
+// result - including an undefined one - denies the request.
+//
+// If the policy engine is not reachable, the request is
+// denied with 503 Service Unavailable.
+type HTTPAuthorizer struct {
+	// Endpoint is the URL of the policy decision - e.g.
+	// https://opa:8181/v1/data/kes/allow
+	Endpoint string
+
+	// Mode is either AuthzAugment or AuthzReplace.
+	// If empty, it defaults to AuthzAugment.
+	Mode string
+
+	// Client is the HTTP client used to send decision
+	// requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// ErrorLog specifies an optional logger for errors
+	// when the policy engine cannot be reached. If nil,
+	// logging is done via the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+}
+
+var _ Authorizer = (*HTTPAuthorizer)(nil)
+
+var errAuthzUnavailable = kes.NewError(http.StatusServiceUnavailable, "authorization service unavailable")
+
+// Authorize asks the policy engine whether the request is
+// allowed. In AuthzAugment mode, it denies any request that
+// the built-in policies deny without asking the engine.
+func (a *HTTPAuthorizer) Authorize(req *http.Request, identity kes.Identity, decision error) error {
+	if decision != nil && a.Mode != AuthzReplace {
+		return decision
+	}
+
+	type Input struct {
+		Identity kes.Identity `json:"identity"`
+		Method   string       `json:"method"`
+		Path     string       `json:"path"`
+		Key      string       `json:"key,omitempty"`
+		Address  string       `json:"address,omitempty"`
+		Allowed  bool         `json:"allowed"`
+	}
+	input := Input{
+		Identity: identity,
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Allowed:  decision == nil,
+	}
+	input.Key, _ = APIKeyName(req.URL.Path)
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		input.Address = host
+	}
+	body, err := json.Marshal(struct {
+		Input Input `json:"input"`
+	}{Input: input})
+	if err != nil {
+		return err
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(a.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		a.logf("auth: failed to query policy engine: %v", err)
+		return errAuthzUnavailable
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		a.logf("auth: failed to query policy engine: %s", resp.Status)
+		return errAuthzUnavailable
+	}
+
+	const MaxSize = 1 << 20
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxSize)).Decode(&response); err != nil {
+		a.logf("auth: failed to parse policy decision: %v", err)
+		return errAuthzUnavailable
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, MaxSize)) // Allow connection reuse
+	if !isAllowed(response.Result) {
+		return kes.ErrNotAllowed
+	}
+	return nil
+}
+
+func (a *HTTPAuthorizer) logf(format string, v ...interface{}) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
+
+// isAllowed reports whether the policy decision is either
+// true or an object with "allow" set to true.
+func isAllowed(result json.RawMessage) bool {
+	var allowed bool
+	if err := json.Unmarshal(result, &allowed); err == nil {
+		return allowed
+	}
+	var decision struct {
+		Allow bool `json:"allow"`
+	}
+	if err := json.Unmarshal(result, &decision); err == nil {
+		return decision.Allow
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestRolesAuthorizer(t *testing.T) {
+	roles := &Roles{
+		Root:     "root",
+		Identify: func(*x509.Certificate) kes.Identity { return "af43c" },
+	}
+	roles.Set("my-app", mustNewPolicy("/v1/key/create/*"))
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+
+	var calls int
+	roles.Authorizer = AuthorizerFunc(func(req *http.Request, identity kes.Identity, decision error) error {
+		calls++
+		if identity != "af43c" {
+			t.Fatalf("Invalid identity: got %s - want %s", identity, "af43c")
+		}
+		if req.URL.Path == "/v1/key/delete/my-key" {
+			return nil // Allow deletes although the policy doesn't
+		}
+		return decision
+	})
+
+	for i, test := range []struct {
+		Path string
+		Err  error
+	}{
+		{Path: "/v1/key/create/my-key", Err: nil},                 // 0
+		{Path: "/v1/key/delete/my-key", Err: nil},                 // 1
+		{Path: "/v1/key/generate/my-key", Err: kes.ErrNotAllowed}, // 2
+	} {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373"+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		if err = roles.Verify(req); err != test.Err {
+			t.Fatalf("Test %d: got %v - want %v", i, err, test.Err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("Invalid number of authorizer calls: got %d - want %d", calls, 3)
+	}
+}
+
+func TestHTTPAuthorizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input struct {
+				Identity kes.Identity `json:"identity"`
+				Key      string       `json:"key"`
+				Allowed  bool         `json:"allowed"`
+			} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch request.Input.Key {
+		case "my-key":
+			w.Write([]byte(`{"result":true}`))
+		case "other-key":
+			w.Write([]byte(`{"result":{"allow":false}}`))
+		default:
+			w.Write([]byte(`{}`)) // Undefined decision
+		}
+	}))
+	defer server.Close()
+
+	for i, test := range []struct {
+		Mode     string
+		Key      string
+		Decision error
+		Err      error
+	}{
+		{Mode: AuthzAugment, Key: "my-key", Decision: nil, Err: nil},                             // 0
+		{Mode: AuthzAugment, Key: "my-key", Decision: kes.ErrNotAllowed, Err: kes.ErrNotAllowed}, // 1
+		{Mode: AuthzReplace, Key: "my-key", Decision: kes.ErrNotAllowed, Err: nil},               // 2
+		{Mode: AuthzReplace, Key: "other-key", Decision: nil, Err: kes.ErrNotAllowed},            // 3
+		{Mode: AuthzReplace, Key: "unknown-key", Decision: nil, Err: kes.ErrNotAllowed},          // 4
+		{Mode: "", Key: "other-key", Decision: nil, Err: kes.ErrNotAllowed},                      // 5
+	} {
+		authorizer := &HTTPAuthorizer{Endpoint: server.URL, Mode: test.Mode, Client: server.Client()}
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/key/create/"+test.Key, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if err = authorizer.Authorize(req, "af43c", test.Decision); err != test.Err {
+			t.Fatalf("Test %d: got %v - want %v", i, err, test.Err)
+		}
+	}
+
+	unavailable := &HTTPAuthorizer{
+		Endpoint: server.URL,
+		Client:   &http.Client{Transport: failingTransport{}},
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://localhost:7373/v1/key/list/*", nil)
+	if err := unavailable.Authorize(req, "af43c", nil); err != errAuthzUnavailable {
+		t.Fatalf("Unreachable policy engine: got %v - want %v", err, errAuthzUnavailable)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, http.ErrHandlerTimeout
+}
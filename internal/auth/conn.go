@@ -0,0 +1,51 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"sync"
+
+	"github.com/minio/kes"
+)
+
+type connIdentityKey struct{}
+
+// NewConnContext returns a new context that caches the
+// identity of the client certificate of a connection.
+// Then, Identify computes the identity of the certificate
+// once per connection instead of for every request.
+//
+// It can be used as http.Server.ConnContext. All requests
+// must be identified using the same IdentityFunc.
+func NewConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connIdentityKey{}, &connIdentity{})
+}
+
+// connIdentity caches the identity of the
+// most recently identified certificate.
+//
+// The certificate is compared by pointer since
+// all requests sent over the same TLS connection
+// share the same certificate. However, a TLS proxy
+// forwards the certificates of different clients
+// over the same connection.
+type connIdentity struct {
+	lock     sync.Mutex
+	cert     *x509.Certificate
+	identity kes.Identity
+}
+
+func (c *connIdentity) Identify(cert *x509.Certificate, f IdentityFunc) kes.Identity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cert != cert {
+		c.cert, c.identity = cert, f(cert)
+	}
+	return c.identity
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestNewConnContext(t *testing.T) {
+	var calls int
+	identify := func(cert *x509.Certificate) kes.Identity {
+		calls++
+		return kes.Identity(cert.Subject.CommonName)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://localhost:7373/v1/key/list/*", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req = req.WithContext(NewConnContext(req.Context(), nil))
+
+	certA, certB := &x509.Certificate{}, &x509.Certificate{}
+	certA.Subject.CommonName, certB.Subject.CommonName = "a", "b"
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certA}}
+	for i := 0; i < 3; i++ {
+		if identity := Identify(req, identify); identity != "a" {
+			t.Fatalf("Invalid identity: got %q - want %q", identity, "a")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("Identity has been computed %d times - want 1", calls)
+	}
+
+	// A TLS proxy may forward requests of different
+	// clients over the same connection.
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certB}}
+	if identity := Identify(req, identify); identity != "b" {
+		t.Fatalf("Invalid identity: got %q - want %q", identity, "b")
+	}
+	if calls != 2 {
+		t.Fatalf("Identity has been computed %d times - want 2", calls)
+	}
+}
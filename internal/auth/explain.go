@@ -0,0 +1,135 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"sort"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// Decision describes whether an identity is allowed to
+// send requests with a particular URL path and why.
+// See: Roles.Explain
+type Decision struct {
+	Allowed bool
+
+	// Policy is the name of the policy assigned to
+	// the identity, if any.
+	Policy string
+
+	// Rule is the policy rule that decides - e.g.
+	// "allow /v1/key/create/*". It is empty if the
+	// decision does not depend on a policy rule.
+	Rule string
+
+	// Reason explains the decision.
+	Reason string
+
+	// Conditional is true if the policy has conditions.
+	// Conditions depend on the particular request - e.g.
+	// the client IP - and are not evaluated by Explain.
+	Conditional bool
+}
+
+// Explain evaluates a hypothetical request of the identity
+// with the given URL path against the current policies. It
+// does not authenticate the identity and does not evaluate
+// policy conditions or the Authorizer - both depend on the
+// particular request.
+//
+// The key name of a tenant identity's request is the key
+// name as seen by the tenant - i.e. without the tenant
+// prefix. See: Tenants
+func (r *Roles) Explain(identity kes.Identity, apiPath string) Decision {
+	if identity.IsUnknown() {
+		return Decision{Reason: "identity is unknown"}
+	}
+	if r.IsRoot(identity) {
+		return Decision{Allowed: true, Reason: "identity is root"}
+	}
+	if r.Tenants != nil {
+		tenant, isTenant := r.Tenants.Lookup(identity)
+		if isTenant && isTenantRestricted(apiPath) {
+			return Decision{Reason: "API is not available to identities of tenant '" + tenant + "'"}
+		}
+		if !isTenant && r.Tenants.isTenantKey(apiPath) {
+			return Decision{Reason: "key belongs to a tenant namespace"}
+		}
+	}
+
+	var (
+		name   string
+		policy *kes.Policy
+		expiry time.Time
+		groups = map[string]*kes.Policy{} // The policies of the identity's groups
+	)
+	r.lock.RLock()
+	if n, ok := r.effectiveRoles[identity]; ok {
+		name, policy, expiry = n, r.roles[n], r.expiry[identity]
+	}
+	for _, n := range r.groupPolicies(identity) {
+		if p, ok := r.roles[n]; ok {
+			groups[n] = p
+		}
+	}
+	r.lock.RUnlock()
+
+	if policy == nil && len(groups) == 0 {
+		return Decision{Reason: "no policy is assigned to the identity"}
+	}
+	if !expiry.IsZero() && time.Now().After(expiry) {
+		return Decision{Policy: name, Reason: "identity has expired"}
+	}
+
+	var decision Decision
+	if policy != nil {
+		if decision = explainPolicy(name, policy, apiPath); decision.Allowed {
+			return decision
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for n := range groups {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		d := explainPolicy(n, groups[n], apiPath)
+		if d.Allowed {
+			d.Reason = "group policy allows the API path"
+			return d
+		}
+		if decision.Policy == "" {
+			decision = d
+		}
+	}
+	return decision
+}
+
+// explainPolicy returns the decision of the named
+// policy for the API path.
+func explainPolicy(name string, policy *kes.Policy, apiPath string) Decision {
+	decision := Decision{
+		Policy:      name,
+		Conditional: hasConditions(policy.Conditions()),
+	}
+	decision.Allowed, decision.Rule = policy.Explain(apiPath)
+	switch {
+	case decision.Allowed:
+		decision.Reason = "policy allows the API path"
+	case decision.Rule == "":
+		decision.Reason = "policy does not allow the API path"
+	default:
+		decision.Reason = "policy denies the API path"
+	}
+	return decision
+}
+
+// hasConditions reports whether c contains at least
+// one condition.
+func hasConditions(c kes.PolicyConditions) bool {
+	return c.Time != "" || c.After != nil || c.Before != nil || len(c.IP) > 0 || len(c.OU) > 0 || len(c.SAN) > 0
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestRolesExplain(t *testing.T) {
+	roles := &Roles{Root: "root", Tenants: new(Tenants)}
+	policy := mustNewPolicy("/v1/key/*/*")
+	if err := policy.Deny("/v1/key/delete/*"); err != nil {
+		t.Fatalf("Failed to add deny patterns: %v", err)
+	}
+	roles.Set("my-app", policy)
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err := roles.Assign("my-app", "expired"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if _, err := roles.Renew("expired", time.Hour); err != nil {
+		t.Fatalf("Failed to renew identity: %v", err)
+	}
+	roles.expiry["expired"] = time.Now().Add(-time.Second)
+	if err := roles.Tenants.Add("tenant-a", "b2e07"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+
+	for i, test := range []struct {
+		Identity kes.Identity
+		Path     string
+		Allowed  bool
+		Rule     string
+	}{
+		{Identity: "root", Path: "/v1/key/delete/my-key", Allowed: true},                             // 0
+		{Identity: "af43c", Path: "/v1/key/create/my-key", Allowed: true, Rule: "allow /v1/key/*/*"}, // 1
+		{Identity: "af43c", Path: "/v1/key/delete/my-key", Rule: "deny /v1/key/delete/*"},            // 2
+		{Identity: "af43c", Path: "/v1/policy/list/*"},                                               // 3
+		{Identity: "af43c", Path: "/v1/key/create/tenant-a/my-key"},                                  // 4
+		{Identity: "expired", Path: "/v1/key/create/my-key"},                                         // 5
+		{Identity: "b2e07", Path: "/v1/key/create/my-key"},                                           // 6
+		{Identity: "b2e07", Path: "/v1/policy/test"},                                                 // 7
+		{Identity: "unknown", Path: "/v1/key/create/my-key"},                                         // 8
+	} {
+		decision := roles.Explain(test.Identity, test.Path)
+		if decision.Allowed != test.Allowed || decision.Rule != test.Rule {
+			t.Fatalf("Test %d: got '%v %s' - want '%v %s'", i, decision.Allowed, decision.Rule, test.Allowed, test.Rule)
+		}
+		if decision.Reason == "" {
+			t.Fatalf("Test %d: decision has no reason", i)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// Group is a named set of identities with a set of
+// policies. Each member of a group has the permissions
+// of all policies of the group - in addition to the
+// policy assigned to the identity itself, if any.
+//
+// A request of a group member is allowed if at least
+// one of these policies allows it.
+type Group struct {
+	Policies []string       `json:"policies"`
+	Members  []kes.Identity `json:"members"`
+}
+
+var (
+	errGroupNotFound = kes.NewError(http.StatusNotFound, "group does not exist")
+	errGroupRoot     = kes.NewError(http.StatusBadRequest, "identity is root")
+)
+
+// SetGroup sets the policies of the named group. If the
+// group does not exist, SetGroup creates a new group
+// without members. It returns kes.ErrPolicyNotFound if
+// one of the policies does not exist.
+func (r *Roles) SetGroup(name string, policies []string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, policy := range policies {
+		if _, ok := r.roles[policy]; !ok {
+			return kes.ErrPolicyNotFound
+		}
+	}
+	if r.groups == nil {
+		r.groups = map[string]*Group{}
+	}
+	group, ok := r.groups[name]
+	if !ok {
+		group = &Group{}
+		r.groups[name] = group
+	}
+	group.Policies = dedup(policies)
+	return nil
+}
+
+// Group returns the named group, if it exists.
+func (r *Roles) Group(name string) (Group, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	group, ok := r.groups[name]
+	if !ok {
+		return Group{}, false
+	}
+	return Group{
+		Policies: append([]string{}, group.Policies...),
+		Members:  append([]kes.Identity{}, group.Members...),
+	}, true
+}
+
+// Groups returns the names of all groups.
+func (r *Roles) Groups() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	return names
+}
+
+// DeleteGroup deletes the named group. Its members
+// lose the permissions of the group's policies.
+func (r *Roles) DeleteGroup(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.groups, name)
+}
+
+// AddMember adds the identity to the named group. It
+// returns an error if the group does not exist or the
+// identity is root.
+func (r *Roles) AddMember(name string, id kes.Identity) error {
+	if id.IsUnknown() {
+		return errors.New("auth: identity is unknown")
+	}
+	if secret.EqualIdentity(id, r.Root) {
+		return errGroupRoot
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	group, ok := r.groups[name]
+	if !ok {
+		return errGroupNotFound
+	}
+	for _, member := range group.Members {
+		if member == id {
+			return nil
+		}
+	}
+	group.Members = append(group.Members, id)
+	return nil
+}
+
+// RemoveMember removes the identity from the named
+// group. It returns an error if the group does not
+// exist.
+func (r *Roles) RemoveMember(name string, id kes.Identity) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	group, ok := r.groups[name]
+	if !ok {
+		return errGroupNotFound
+	}
+	for i, member := range group.Members {
+		if member == id {
+			group.Members = append(group.Members[:i], group.Members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// GroupsOf returns the names of all groups the identity
+// is a member of.
+func (r *Roles) GroupsOf(id kes.Identity) []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var names []string
+	for name, group := range r.groups {
+		for _, member := range group.Members {
+			if member == id {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// groupPolicies returns the names of the policies of all
+// groups the identity is a member of. The caller must hold
+// the roles lock.
+func (r *Roles) groupPolicies(id kes.Identity) []string {
+	var names []string
+	for _, group := range r.groups {
+		for _, member := range group.Members {
+			if member == id {
+				names = append(names, group.Policies...)
+				break
+			}
+		}
+	}
+	return dedup(names)
+}
+
+// dedup returns the sorted list of distinct names.
+func dedup(names []string) []string {
+	if len(names) == 0 {
+		return []string{}
+	}
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	n := 1
+	for _, name := range sorted[1:] {
+		if name != sorted[n-1] {
+			sorted[n] = name
+			n++
+		}
+	}
+	return sorted[:n]
+}
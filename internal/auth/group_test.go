@@ -0,0 +1,113 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+)
+
+func TestRolesGroup(t *testing.T) {
+	roles := &Roles{
+		Root:     "root",
+		Identify: func(*x509.Certificate) kes.Identity { return "af43c" },
+	}
+	roles.Set("key-create", mustNewPolicy("/v1/key/create/*"))
+	roles.Set("key-generate", mustNewPolicy("/v1/key/generate/*"))
+
+	if err := roles.SetGroup("my-apps", []string{"key-create", "unknown"}); err != kes.ErrPolicyNotFound {
+		t.Fatalf("Group with unknown policy: got %v - want %v", err, kes.ErrPolicyNotFound)
+	}
+	if err := roles.AddMember("my-apps", "af43c"); err != errGroupNotFound {
+		t.Fatalf("Member of unknown group: got %v - want %v", err, errGroupNotFound)
+	}
+	if err := roles.SetGroup("my-apps", []string{"key-create", "key-generate"}); err != nil {
+		t.Fatalf("Failed to set group: %v", err)
+	}
+	if err := roles.AddMember("my-apps", "root"); err != errGroupRoot {
+		t.Fatalf("Root as group member: got %v - want %v", err, errGroupRoot)
+	}
+
+	verify := func(path string) error {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373"+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		return roles.Verify(req)
+	}
+	if err := verify("/v1/key/create/my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Identity is not a member: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+	if err := roles.AddMember("my-apps", "af43c"); err != nil {
+		t.Fatalf("Failed to add group member: %v", err)
+	}
+	if err := verify("/v1/key/create/my-key"); err != nil {
+		t.Fatalf("Group policy does not allow request: %v", err)
+	}
+	if err := verify("/v1/key/generate/my-key"); err != nil {
+		t.Fatalf("Group policy does not allow request: %v", err)
+	}
+	if err := verify("/v1/key/delete/my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Group policies allow request: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+	if groups := roles.GroupsOf("af43c"); len(groups) != 1 || groups[0] != "my-apps" {
+		t.Fatalf("Invalid groups: got %v - want %v", groups, []string{"my-apps"})
+	}
+
+	roles.Delete("key-generate")
+	if err := verify("/v1/key/generate/my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Deleted group policy allows request: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+	if err := roles.RemoveMember("my-apps", "af43c"); err != nil {
+		t.Fatalf("Failed to remove group member: %v", err)
+	}
+	if err := verify("/v1/key/create/my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Removed member is still allowed: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestRolesGroupSaveLoad(t *testing.T) {
+	remote := &mem.Store{}
+	roles := &Roles{Root: "root", Remote: remote}
+	roles.Set("my-app", mustNewPolicy("/v1/key/create/*"))
+	if err := roles.SetGroup("my-apps", []string{"my-app"}); err != nil {
+		t.Fatalf("Failed to set group: %v", err)
+	}
+	if err := roles.AddMember("my-apps", "af43c"); err != nil {
+		t.Fatalf("Failed to add group member: %v", err)
+	}
+	if err := roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+
+	loaded := &Roles{Root: "root", Remote: remote}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Failed to load roles: %v", err)
+	}
+	group, ok := loaded.Group("my-apps")
+	if !ok {
+		t.Fatal("Group 'my-apps' has not been loaded")
+	}
+	if len(group.Policies) != 1 || group.Policies[0] != "my-app" {
+		t.Fatalf("Invalid group policies: got %v - want %v", group.Policies, []string{"my-app"})
+	}
+	if len(group.Members) != 1 || group.Members[0] != "af43c" {
+		t.Fatalf("Invalid group members: got %v - want %v", group.Members, []kes.Identity{"af43c"})
+	}
+
+	replica := &Roles{Root: "root", Remote: remote}
+	if err := replica.Reload(); err != nil {
+		t.Fatalf("Failed to reload roles: %v", err)
+	}
+	if groups := replica.GroupsOf("af43c"); len(groups) != 1 || groups[0] != "my-apps" {
+		t.Fatalf("Group has not been reloaded: got %v", groups)
+	}
+}
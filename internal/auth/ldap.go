@@ -0,0 +1,381 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// LDAP authenticates users with a username and password
+// against an LDAP directory - e.g. Active Directory - and
+// maps the directory groups of a user to policies.
+//
+// LDAP binds as the user to verify the password and then
+// searches the user's directory entry for its groups. It
+// only supports LDAP over TLS (LDAPS).
+type LDAP struct {
+	// Addr is the address of the LDAP server - e.g.
+	// ldap.example.com:636.
+	Addr string
+
+	// UserDN is the template of the DN used to bind as
+	// user. The username replaces the "%s" - e.g.
+	// "uid=%s,ou=people,dc=example,dc=com" or, for
+	// Active Directory, "%s@example.com".
+	UserDN string
+
+	// BaseDN is the DN of the directory subtree that
+	// contains the user entries.
+	BaseDN string
+
+	// UserAttribute is the attribute of a user entry that
+	// contains the username - e.g. "uid" or "sAMAccountName".
+	UserAttribute string
+
+	// GroupAttribute is the attribute of a user entry that
+	// contains the DNs of the user's groups. If empty, it
+	// defaults to "memberOf".
+	GroupAttribute string
+
+	// Policies maps group DNs to policy names. A user
+	// has the permissions of all policies mapped to its
+	// groups.
+	Policies map[string]string
+
+	// TLSConfig is the TLS configuration used to connect
+	// to the LDAP server.
+	TLSConfig *tls.Config
+
+	// CacheExpiry is the duration a successful
+	// authentication is cached. If 0, it defaults
+	// to 1 minute.
+	CacheExpiry time.Duration
+
+	// ErrorLog specifies an optional logger for errors
+	// when the LDAP server cannot be reached or responds
+	// unexpectedly. If nil, logging is done via the log
+	// package's standard logger.
+	ErrorLog *log.Logger
+
+	// dial is used to connect to the LDAP server.
+	// If nil, LDAP connects via TLS to Addr.
+	dial func() (net.Conn, error)
+
+	lock  sync.Mutex
+	cache map[[sha256.Size]byte]ldapEntry
+}
+
+type ldapEntry struct {
+	Groups []string
+	Expiry time.Time
+}
+
+var (
+	errLDAPCredentials = kes.NewError(http.StatusUnauthorized, "invalid username or password")
+	errLDAPUnavailable = kes.NewError(http.StatusBadGateway, "bad gateway: LDAP server not available")
+)
+
+// Authenticate verifies the username and password and returns
+// the DNs of the user's groups.
+func (l *LDAP) Authenticate(username, password string) ([]string, error) {
+	// An empty password would cause an unauthenticated bind
+	// which succeeds without verifying anything. Further, the
+	// username must not alter the structure of the user DN.
+	if password == "" || username == "" || strings.ContainsAny(username, `,+"\<>;=#`) {
+		return nil, errLDAPCredentials
+	}
+
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	l.lock.Lock()
+	if entry, ok := l.cache[sum]; ok && time.Now().Before(entry.Expiry) {
+		l.lock.Unlock()
+		return entry.Groups, nil
+	}
+	l.lock.Unlock()
+
+	groups, err := l.authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := l.CacheExpiry
+	if expiry == 0 {
+		expiry = time.Minute
+	}
+	l.lock.Lock()
+	if l.cache == nil {
+		l.cache = map[[sha256.Size]byte]ldapEntry{}
+	}
+	now := time.Now()
+	for key, entry := range l.cache { // Remove expired entries such that the cache does not grow forever
+		if now.After(entry.Expiry) {
+			delete(l.cache, key)
+		}
+	}
+	l.cache[sum] = ldapEntry{Groups: groups, Expiry: now.Add(expiry)}
+	l.lock.Unlock()
+	return groups, nil
+}
+
+// LDAP protocol constants. See: RFC 4511
+const (
+	ldapBindRequest       = 0
+	ldapBindResponse      = 1
+	ldapSearchRequest     = 3
+	ldapSearchResultEntry = 4
+	ldapSearchResultDone  = 5
+	ldapUnbindRequest     = 2
+
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+)
+
+func (l *LDAP) authenticate(username, password string) ([]string, error) {
+	dial := l.dial
+	if dial == nil {
+		dial = func() (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: 10 * time.Second}
+			return tls.DialWithDialer(dialer, "tcp", l.Addr, l.TLSConfig)
+		}
+	}
+	conn, err := dial()
+	if err != nil {
+		l.logf("auth: failed to connect to LDAP server '%s': %v", l.Addr, err)
+		return nil, errLDAPUnavailable
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	type BindRequest struct {
+		Version  int
+		Name     []byte
+		Password []byte `asn1:"tag:0"`
+	}
+	bind, err := asn1.MarshalWithParams(BindRequest{
+		Version:  3,
+		Name:     []byte(fmt.Sprintf(l.UserDN, username)),
+		Password: []byte(password),
+	}, fmt.Sprintf("application,tag:%d", ldapBindRequest))
+	if err != nil {
+		return nil, err
+	}
+	if err = writeLDAPMessage(conn, 1, bind); err != nil {
+		l.logf("auth: failed to send LDAP bind request: %v", err)
+		return nil, errLDAPUnavailable
+	}
+	op, err := readLDAPMessage(conn, 1)
+	if err != nil || op.Tag != ldapBindResponse {
+		l.logf("auth: invalid LDAP bind response: %v", err)
+		return nil, errLDAPUnavailable
+	}
+	switch code, err := parseLDAPResult(op); {
+	case err != nil:
+		l.logf("auth: invalid LDAP bind response: %v", err)
+		return nil, errLDAPUnavailable
+	case code == ldapResultInvalidCredentials:
+		return nil, errLDAPCredentials
+	case code != ldapResultSuccess:
+		l.logf("auth: LDAP bind failed with result code %d", code)
+		return nil, errLDAPCredentials
+	}
+
+	type Filter struct {
+		Attribute []byte
+		Value     []byte
+	}
+	type SearchRequest struct {
+		BaseObject   []byte
+		Scope        asn1.Enumerated
+		DerefAliases asn1.Enumerated
+		SizeLimit    int
+		TimeLimit    int
+		TypesOnly    bool
+		Filter       Filter `asn1:"tag:3"` // equalityMatch
+		Attributes   [][]byte
+	}
+	groupAttribute := l.GroupAttribute
+	if groupAttribute == "" {
+		groupAttribute = "memberOf"
+	}
+	search, err := asn1.MarshalWithParams(SearchRequest{
+		BaseObject: []byte(l.BaseDN),
+		Scope:      2, // wholeSubtree
+		SizeLimit:  2,
+		TimeLimit:  30,
+		Filter:     Filter{Attribute: []byte(l.UserAttribute), Value: []byte(username)},
+		Attributes: [][]byte{[]byte(groupAttribute)},
+	}, fmt.Sprintf("application,tag:%d", ldapSearchRequest))
+	if err != nil {
+		return nil, err
+	}
+	if err = writeLDAPMessage(conn, 2, search); err != nil {
+		l.logf("auth: failed to send LDAP search request: %v", err)
+		return nil, errLDAPUnavailable
+	}
+
+	type Attribute struct {
+		Type   []byte
+		Values [][]byte `asn1:"set"`
+	}
+	type SearchResultEntry struct {
+		ObjectName []byte
+		Attributes []Attribute
+	}
+	var (
+		groups  []string
+		entries int
+	)
+	for {
+		op, err := readLDAPMessage(conn, 2)
+		if err != nil {
+			l.logf("auth: invalid LDAP search response: %v", err)
+			return nil, errLDAPUnavailable
+		}
+		if op.Tag == ldapSearchResultDone {
+			if code, err := parseLDAPResult(op); err != nil || code != ldapResultSuccess {
+				l.logf("auth: LDAP search failed with result code %d: %v", code, err)
+				return nil, errLDAPUnavailable
+			}
+			break
+		}
+		if op.Tag != ldapSearchResultEntry {
+			continue // Ignore search result references
+		}
+
+		var entry SearchResultEntry
+		op.FullBytes[0] = 0x30 // Parse the application-tagged entry as SEQUENCE
+		if _, err = asn1.Unmarshal(op.FullBytes, &entry); err != nil {
+			l.logf("auth: invalid LDAP search result entry: %v", err)
+			return nil, errLDAPUnavailable
+		}
+		entries++
+		for _, attribute := range entry.Attributes {
+			if strings.EqualFold(string(attribute.Type), groupAttribute) {
+				for _, value := range attribute.Values {
+					groups = append(groups, string(value))
+				}
+			}
+		}
+	}
+	if entries != 1 { // The username must identify exactly one directory entry
+		return nil, errLDAPCredentials
+	}
+
+	unbind, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: ldapUnbindRequest})
+	writeLDAPMessage(conn, 3, unbind)
+	return groups, nil
+}
+
+func (l *LDAP) logf(format string, v ...interface{}) {
+	if l.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		l.ErrorLog.Printf(format, v...)
+	}
+}
+
+// writeLDAPMessage writes an LDAPMessage with the given
+// message ID and DER-encoded protocol operation to w.
+func writeLDAPMessage(w io.Writer, id int, op []byte) error {
+	message, err := asn1.Marshal(struct {
+		ID int
+		Op asn1.RawValue
+	}{
+		ID: id,
+		Op: asn1.RawValue{FullBytes: op},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	return err
+}
+
+// readLDAPMessage reads the next LDAPMessage with the
+// given message ID from r and returns its protocol
+// operation.
+func readLDAPMessage(r io.Reader, id int) (asn1.RawValue, error) {
+	const MaxSize = 1 << 20
+	header := make([]byte, 2, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return asn1.RawValue{}, err
+	}
+	if header[0] != 0x30 {
+		return asn1.RawValue{}, errors.New("LDAP message is not a SEQUENCE")
+	}
+	length := int(header[1])
+	if length&0x80 != 0 { // Long form: the low bits contain the number of length bytes
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return asn1.RawValue{}, errors.New("LDAP message length is invalid")
+		}
+		header = header[:2+n]
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return asn1.RawValue{}, err
+		}
+		length = 0
+		for _, b := range header[2:] {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > MaxSize {
+		return asn1.RawValue{}, errors.New("LDAP message is too large")
+	}
+	message := make([]byte, len(header)+length)
+	copy(message, header)
+	if _, err := io.ReadFull(r, message[len(header):]); err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	// The message may contain trailing controls. Therefore,
+	// we parse the message ID and the operation one by one.
+	var (
+		seq asn1.RawValue
+		msg struct {
+			ID int
+			Op asn1.RawValue
+		}
+	)
+	if _, err := asn1.Unmarshal(message, &seq); err != nil {
+		return asn1.RawValue{}, err
+	}
+	rest, err := asn1.Unmarshal(seq.Bytes, &msg.ID)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	if _, err = asn1.Unmarshal(rest, &msg.Op); err != nil {
+		return asn1.RawValue{}, err
+	}
+	if msg.ID != id {
+		return asn1.RawValue{}, fmt.Errorf("unexpected LDAP message ID %d", msg.ID)
+	}
+	if msg.Op.Class != asn1.ClassApplication {
+		return asn1.RawValue{}, errors.New("LDAP protocol operation is not application-tagged")
+	}
+	return msg.Op, nil
+}
+
+// parseLDAPResult returns the result code of an
+// LDAPResult protocol operation.
+func parseLDAPResult(op asn1.RawValue) (int, error) {
+	var code asn1.Enumerated
+	if _, err := asn1.Unmarshal(op.Bytes, &code); err != nil {
+		return 0, err
+	}
+	return int(code), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"testing"
+)
+
+// serveLDAP implements a minimal LDAP server that accepts
+// the given password and returns the given groups.
+func serveLDAP(t *testing.T, conn net.Conn, password string, groups []string) {
+	defer conn.Close()
+
+	type BindRequest struct {
+		Version  int
+		Name     []byte
+		Password []byte `asn1:"tag:0"`
+	}
+	type Result struct {
+		Code       asn1.Enumerated
+		MatchedDN  []byte
+		Diagnostic []byte
+	}
+	type Attribute struct {
+		Type   []byte
+		Values [][]byte `asn1:"set"`
+	}
+	type SearchResultEntry struct {
+		ObjectName []byte
+		Attributes []Attribute
+	}
+
+	op, err := readLDAPMessage(conn, 1)
+	if err != nil {
+		t.Errorf("Failed to read bind request: %v", err)
+		return
+	}
+	var bind BindRequest
+	op.FullBytes[0] = 0x30
+	if _, err = asn1.Unmarshal(op.FullBytes, &bind); err != nil {
+		t.Errorf("Failed to parse bind request: %v", err)
+		return
+	}
+	result := Result{Code: ldapResultSuccess}
+	if string(bind.Password) != password {
+		result.Code = ldapResultInvalidCredentials
+	}
+	response, _ := asn1.MarshalWithParams(result, fmt.Sprintf("application,tag:%d", ldapBindResponse))
+	writeLDAPMessage(conn, 1, response)
+	if result.Code != ldapResultSuccess {
+		return
+	}
+
+	if _, err = readLDAPMessage(conn, 2); err != nil {
+		t.Errorf("Failed to read search request: %v", err)
+		return
+	}
+	entry := SearchResultEntry{
+		ObjectName: []byte("uid=alice,ou=people,dc=example,dc=com"),
+		Attributes: []Attribute{{Type: []byte("memberOf")}},
+	}
+	for _, group := range groups {
+		entry.Attributes[0].Values = append(entry.Attributes[0].Values, []byte(group))
+	}
+	response, _ = asn1.MarshalWithParams(entry, fmt.Sprintf("application,tag:%d", ldapSearchResultEntry))
+	writeLDAPMessage(conn, 2, response)
+	response, _ = asn1.MarshalWithParams(Result{Code: ldapResultSuccess}, fmt.Sprintf("application,tag:%d", ldapSearchResultDone))
+	writeLDAPMessage(conn, 2, response)
+
+	readLDAPMessage(conn, 3) // Unbind
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	const password = "secret"
+	groups := []string{"cn=kes-admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"}
+
+	ldap := &LDAP{
+		UserDN:        "uid=%s,ou=people,dc=example,dc=com",
+		BaseDN:        "dc=example,dc=com",
+		UserAttribute: "uid",
+		ErrorLog:      log.New(ioutil.Discard, "", 0),
+		dial: func() (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveLDAP(t, server, password, groups)
+			return client, nil
+		},
+	}
+
+	result, err := ldap.Authenticate("alice", password)
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	sort.Strings(result) // The groups are a SET and may be reordered
+	if len(result) != len(groups) || result[0] != groups[0] || result[1] != groups[1] {
+		t.Fatalf("Invalid groups: got %v - want %v", result, groups)
+	}
+	if _, err = ldap.Authenticate("alice", "wrong"); err != errLDAPCredentials {
+		t.Fatalf("Invalid password: got %v - want %v", err, errLDAPCredentials)
+	}
+	if _, err = ldap.Authenticate("alice", ""); err != errLDAPCredentials {
+		t.Fatalf("Empty password: got %v - want %v", err, errLDAPCredentials)
+	}
+	if _, err = ldap.Authenticate("alice,ou=admins", password); err != errLDAPCredentials {
+		t.Fatalf("Username with DN special characters: got %v - want %v", err, errLDAPCredentials)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// OIDC authenticates clients that present a JWT, issued by
+// an OpenID Connect provider, as bearer token and maps the
+// token claims to policies.
+//
+// The token signature is verified with the issuer's public
+// keys, fetched from its JWKS endpoint. Further, the token
+// must not be expired and must have been issued by the
+// Issuer for the Audience.
+type OIDC struct {
+	// Issuer is the OIDC issuer URL - e.g.
+	// https://accounts.example.com. It must
+	// match the "iss" claim of the token.
+	Issuer string
+
+	// Audience must be contained in the "aud"
+	// claim of the token.
+	Audience string
+
+	// JWKSURL is the URL of the issuer's JSON Web
+	// Key Set. If empty, it is discovered via the
+	// issuer's /.well-known/openid-configuration.
+	JWKSURL string
+
+	// Claim is the token claim that is mapped to
+	// policies - e.g. "groups". If empty, it
+	// defaults to "sub".
+	Claim string
+
+	// Policies maps claim values to policy names.
+	// A client has the permissions of all policies
+	// mapped to the values of its token claim.
+	Policies map[string]string
+
+	// Client is the HTTP client used to fetch the
+	// issuer's keys. If nil, http.DefaultClient
+	// is used.
+	Client *http.Client
+
+	// ErrorLog specifies an optional logger for errors
+	// when the issuer's keys cannot be fetched. If nil,
+	// logging is done via the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+
+	lock        sync.RWMutex
+	keys        map[string]crypto.PublicKey // JWKS keys by key ID
+	lastRefresh time.Time
+}
+
+var (
+	errOIDCInvalidToken = kes.NewError(http.StatusUnauthorized, "invalid bearer token")
+	errOIDCExpiredToken = kes.NewError(http.StatusUnauthorized, "bearer token expired")
+)
+
+// Refresh fetches the public keys of the issuer. If no
+// JWKSURL is set, Refresh discovers it first.
+func (o *OIDC) Refresh() error {
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	jwksURL := o.JWKSURL
+	if jwksURL == "" {
+		var config struct {
+			JWKSURL string `json:"jwks_uri"`
+		}
+		if err := getJSON(client, strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", &config); err != nil {
+			return fmt.Errorf("auth: failed to discover OIDC configuration: %v", err)
+		}
+		if config.JWKSURL == "" {
+			return errors.New("auth: OIDC configuration contains no JWKS URL")
+		}
+		jwksURL = config.JWKSURL
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Type  string `json:"kty"`
+			ID    string `json:"kid"`
+			Use   string `json:"use"`
+			Curve string `json:"crv"`
+			N     string `json:"n"`
+			E     string `json:"e"`
+			X     string `json:"x"`
+			Y     string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(client, jwksURL, &jwks); err != nil {
+		return fmt.Errorf("auth: failed to fetch OIDC keys: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Type {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			exponent := new(big.Int).SetBytes(e)
+			keys[key.ID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Curve {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(key.X)
+			y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+				continue
+			}
+			keys[key.ID] = publicKey
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if key.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[key.ID] = ed25519.PublicKey(x)
+		}
+	}
+
+	o.lock.Lock()
+	o.keys, o.lastRefresh = keys, time.Now()
+	o.lock.Unlock()
+	return nil
+}
+
+// Authenticate verifies the JWT and returns the values
+// of the token's Claim.
+func (o *OIDC) Authenticate(token string) ([]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errOIDCInvalidToken
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errOIDCInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errOIDCInvalidToken
+	}
+
+	key, err := o.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyJWTSignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errOIDCInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errOIDCInvalidToken
+	}
+	if iss, _ := claims["iss"].(string); iss != o.Issuer {
+		return nil, errOIDCInvalidToken
+	}
+	if !containsClaim(claims["aud"], o.Audience) {
+		return nil, errOIDCInvalidToken
+	}
+
+	const Leeway = time.Minute // Tolerate clock skew between the issuer and us
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errOIDCInvalidToken
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(Leeway)) {
+		return nil, errOIDCExpiredToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errOIDCInvalidToken
+	}
+
+	claim := o.Claim
+	if claim == "" {
+		claim = "sub"
+	}
+	switch value := claims[claim].(type) {
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values, nil
+	default:
+		return nil, nil
+	}
+}
+
+// key returns the issuer's public key with the given key ID.
+// If no such key is known, key refreshes the issuer's keys
+// - but at most once per minute.
+func (o *OIDC) key(id string) (crypto.PublicKey, error) {
+	o.lock.RLock()
+	key, ok := o.keys[id]
+	lastRefresh := o.lastRefresh
+	o.lock.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(lastRefresh) < time.Minute {
+		return nil, errOIDCInvalidToken
+	}
+	if err := o.Refresh(); err != nil {
+		o.logf("%v", err)
+		o.lock.Lock()
+		o.lastRefresh = time.Now() // Don't retry on every request
+		o.lock.Unlock()
+		return nil, errOIDCInvalidToken
+	}
+
+	o.lock.RLock()
+	key, ok = o.keys[id]
+	o.lock.RUnlock()
+	if !ok {
+		return nil, errOIDCInvalidToken
+	}
+	return key, nil
+}
+
+func (o *OIDC) logf(format string, v ...interface{}) {
+	if o.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		o.ErrorLog.Printf(format, v...)
+	}
+}
+
+// verifyJWTSignature reports whether signature is a valid
+// signature of message for the given JWS algorithm and key.
+// It only accepts asymmetric signature algorithms.
+func verifyJWTSignature(algorithm string, key crypto.PublicKey, message, signature []byte) bool {
+	var hash crypto.Hash
+	switch algorithm {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		publicKey, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(publicKey, message, signature)
+	default:
+		return false // In particular, "none" and HMAC algorithms
+	}
+	h := hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if algorithm[0] == 'R' {
+			return rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) == nil
+		}
+		if algorithm[0] == 'P' {
+			return rsa.VerifyPSS(publicKey, hash, digest, signature, nil) == nil
+		}
+		return false
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if algorithm[0] != 'E' || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(publicKey, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// decodeJWTPart decodes a base64url-encoded
+// JSON part of a JWT into v.
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	return decoder.Decode(v)
+}
+
+// containsClaim reports whether the claim - either
+// a string or an array of strings - contains value.
+func containsClaim(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, v := range claim {
+			if s, ok := v.(string); ok && s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getJSON fetches the JSON document at url and
+// decodes it into v.
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signJWT returns a JWT with the given header and claims
+// signed with the ECDSA P-256 key.
+func signJWT(t *testing.T, key *ecdsa.PrivateKey, header, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to encode JWT part: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	message := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(message))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign JWT: %v", err)
+	}
+	signature := append(padBytes(r, 32), padBytes(s, 32)...)
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// padBytes returns the big-endian bytes of n
+// left-padded with zeros to size bytes.
+func padBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var issuer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%s","jwks_uri":"%s/keys"}`, issuer, issuer)
+		case "/keys":
+			fmt.Fprintf(w, `{"keys":[{"kty":"EC","kid":"1","use":"sig","crv":"P-256","x":"%s","y":"%s"}]}`,
+				base64.RawURLEncoding.EncodeToString(padBytes(key.X, 32)),
+				base64.RawURLEncoding.EncodeToString(padBytes(key.Y, 32)),
+			)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	issuer = server.URL
+
+	oidc := &OIDC{
+		Issuer:   issuer,
+		Audience: "kes",
+		Claim:    "groups",
+		Client:   server.Client(),
+	}
+	if err = oidc.Refresh(); err != nil {
+		t.Fatalf("Failed to fetch OIDC keys: %v", err)
+	}
+
+	now := time.Now().Unix()
+	header := map[string]interface{}{"alg": "ES256", "kid": "1"}
+	claims := func(aud string, exp int64) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    issuer,
+			"aud":    []string{aud},
+			"sub":    "my-app",
+			"exp":    exp,
+			"groups": []string{"app", "ops"},
+		}
+	}
+
+	// 1
+	groups, err := oidc.Authenticate(signJWT(t, key, header, claims("kes", now+3600)))
+	if err != nil {
+		t.Fatalf("Test 1: failed to authenticate: %v", err)
+	}
+	if len(groups) != 2 || groups[0] != "app" || groups[1] != "ops" {
+		t.Fatalf("Test 1: groups mismatch: got %v - want %v", groups, []string{"app", "ops"})
+	}
+
+	// 2
+	if _, err = oidc.Authenticate(signJWT(t, key, header, claims("kes", now-3600))); err != errOIDCExpiredToken {
+		t.Fatalf("Test 2: error mismatch: got %v - want %v", err, errOIDCExpiredToken)
+	}
+
+	// 3
+	if _, err = oidc.Authenticate(signJWT(t, key, header, claims("other", now+3600))); err != errOIDCInvalidToken {
+		t.Fatalf("Test 3: error mismatch: got %v - want %v", err, errOIDCInvalidToken)
+	}
+
+	// 4
+	none := map[string]interface{}{"alg": "none", "kid": "1"}
+	token := signJWT(t, key, none, claims("kes", now+3600))
+	if _, err = oidc.Authenticate(token); err != errOIDCInvalidToken {
+		t.Fatalf("Test 4: error mismatch: got %v - want %v", err, errOIDCInvalidToken)
+	}
+
+	// 5
+	token = signJWT(t, key, header, claims("kes", now+3600))
+	token = token[:len(token)-4] + "AAAA"
+	if _, err = oidc.Authenticate(token); err != errOIDCInvalidToken {
+		t.Fatalf("Test 5: error mismatch: got %v - want %v", err, errOIDCInvalidToken)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/minio/kes"
+)
+
+// Peer is the process at the other end of a Unix domain
+// socket connection. Its credentials are provided by the
+// operating system and cannot be forged by the process.
+type Peer struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// Identity returns the identity of the peer - i.e.
+// "unix:<uid>". All processes of the same user have
+// the same identity.
+func (p Peer) Identity() kes.Identity {
+	return kes.Identity("unix:" + strconv.FormatUint(uint64(p.UID), 10))
+}
+
+type peerContextKey struct{}
+
+// NewPeerContext returns a new context that carries
+// the credentials of the peer.
+//
+// A server that accepts connections via a Unix domain
+// socket should attach the credentials of the peer to
+// the context of every connection - e.g. via the
+// http.Server.ConnContext.
+func NewPeerContext(ctx context.Context, peer Peer) context.Context {
+	return context.WithValue(ctx, peerContextKey{}, peer)
+}
+
+// PeerFromRequest returns the credentials of the peer who
+// sent the request via a Unix domain socket, if any.
+func PeerFromRequest(req *http.Request) (Peer, bool) {
+	peer, ok := req.Context().Value(peerContextKey{}).(Peer)
+	return peer, ok
+}
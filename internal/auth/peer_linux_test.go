@@ -0,0 +1,54 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeerOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-peer-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "kes.sock"))
+	if err != nil {
+		t.Fatalf("Failed to listen on Unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to Unix socket: %v", err)
+	}
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept connection: %v", err)
+	}
+	defer conn.Close()
+
+	peer, err := PeerOf(conn)
+	if err != nil {
+		t.Fatalf("Failed to get peer credentials: %v", err)
+	}
+	if peer.UID != uint32(os.Getuid()) || peer.GID != uint32(os.Getgid()) || peer.PID != int32(os.Getpid()) {
+		t.Fatalf("Invalid peer credentials: got %+v - want UID %d, GID %d and PID %d", peer, os.Getuid(), os.Getgid(), os.Getpid())
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if _, err = PeerOf(server); err == nil {
+		t.Fatal("Got peer credentials of a non-Unix connection")
+	}
+}
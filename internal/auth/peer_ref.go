@@ -0,0 +1,20 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package auth
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerOf returns the credentials of the process at the
+// other end of the Unix domain socket connection.
+func PeerOf(conn net.Conn) (Peer, error) {
+	// We only support peer credentials
+	// on linux at the moment.
+	return Peer{}, errors.New("auth: peer credentials are not supported on this platform")
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+var rolesVerifyPeerTests = []struct {
+	Peer Peer
+	Path string
+	Err  error
+}{
+	{Peer: Peer{UID: 1000}, Path: "/v1/key/create/my-key", Err: nil},               // 0
+	{Peer: Peer{UID: 1000}, Path: "/v1/key/delete/my-key", Err: kes.ErrNotAllowed}, // 1
+	{Peer: Peer{UID: 0}, Path: "/v1/key/delete/my-key", Err: nil},                  // 2
+	{Peer: Peer{UID: 1001}, Path: "/v1/key/create/my-key", Err: kes.ErrNotAllowed}, // 3
+}
+
+func TestRolesVerifyPeer(t *testing.T) {
+	roles := &Roles{Root: "unix:0"}
+	roles.Set("my-app", mustNewPolicy("/v1/key/create/*"))
+	if err := roles.Assign("my-app", "unix:1000"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+
+	for i, test := range rolesVerifyPeerTests {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost"+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		req = req.WithContext(NewPeerContext(req.Context(), test.Peer))
+		if identity := Identify(req, nil); identity != test.Peer.Identity() {
+			t.Fatalf("Test %d: invalid identity: got %q - want %q", i, identity, test.Peer.Identity())
+		}
+		if err = roles.Verify(req); err != test.Err {
+			t.Fatalf("Test %d: got error '%v' - want '%v'", i, err, test.Err)
+		}
+	}
+
+	// A request that has neither been sent via TLS nor
+	// via a Unix domain socket must be rejected.
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/key/create/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if err = roles.Verify(req); err == nil {
+		t.Fatal("Request without TLS and peer credentials has been accepted")
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/watch"
 )
 
 // IdentityFunc maps a X.509 certificate to an
@@ -53,9 +57,59 @@ type Roles struct {
 	Root     kes.Identity
 	Identify IdentityFunc
 
+	// Remote is an optional key-value store that
+	// persists policies and identity assignments.
+	// See: Save and Load
+	Remote secret.Remote
+
+	// LDAP is an optional LDAP directory that
+	// authenticates clients without a certificate
+	// via username and password (HTTP basic auth).
+	LDAP *LDAP
+
+	// OIDC is an optional OpenID Connect issuer
+	// that authenticates clients without a
+	// certificate via JWT bearer tokens.
+	OIDC *OIDC
+
+	// Tenants optionally binds identities to
+	// tenants with isolated key namespaces.
+	// Tenant identities cannot modify policies
+	// or identity assignments.
+	Tenants *Tenants
+
+	// Quotas optionally limits the number
+	// of keys an identity or tenant may
+	// create.
+	Quotas *Quotas
+
+	// Authorizer is an optional authorization hook
+	// that augments or replaces the evaluation of the
+	// built-in policies. It is called once the request
+	// has been authenticated - except for requests of
+	// the root identity, which are always allowed.
+	Authorizer Authorizer
+
+	// Approvals optionally requires the approval of
+	// multiple admins before the root identity can
+	// perform destructive operations.
+	Approvals *Approvals
+
+	// Sessions optionally allows identities to issue
+	// short-lived session tokens with a narrowed policy.
+	Sessions *Sessions
+
+	// Events is an optional hub to which the
+	// policy API handlers publish an event
+	// whenever a policy gets written or deleted.
+	Events *watch.Hub
+
+	saveLock       sync.Mutex
 	lock           sync.RWMutex
-	roles          map[string]*kes.Policy  // all available roles
-	effectiveRoles map[kes.Identity]string // identities for which a mapping to a policy name exists
+	roles          map[string]*kes.Policy     // all available roles
+	effectiveRoles map[kes.Identity]string    // identities for which a mapping to a policy name exists
+	expiry         map[kes.Identity]time.Time // identities that expire at some point in time
+	groups         map[string]*Group          // named groups of identities with their policies
 }
 
 func (r *Roles) Set(name string, policy *kes.Policy) {
@@ -88,6 +142,15 @@ func (r *Roles) Delete(name string) {
 		for id, policy := range r.effectiveRoles {
 			if name == policy {
 				delete(r.effectiveRoles, id)
+				delete(r.expiry, id)
+			}
+		}
+	}
+	for _, group := range r.groups { // Remove the policy from all groups
+		for i, policy := range group.Policies {
+			if name == policy {
+				group.Policies = append(group.Policies[:i], group.Policies[i+1:]...)
+				break
 			}
 		}
 	}
@@ -105,7 +168,7 @@ func (r *Roles) Policies() (names []string) {
 }
 
 func (r *Roles) Assign(name string, id kes.Identity) error {
-	if id == r.Root {
+	if secret.EqualIdentity(id, r.Root) {
 		return errors.New("key: identity is root")
 	}
 
@@ -127,7 +190,7 @@ func (r *Roles) Assign(name string, id kes.Identity) error {
 }
 
 func (r *Roles) IsAssigned(id kes.Identity) bool {
-	if id == r.Root {
+	if secret.EqualIdentity(id, r.Root) {
 		return true
 	}
 
@@ -157,19 +220,73 @@ func (r *Roles) Identities() map[kes.Identity]string {
 func (r *Roles) Forget(id kes.Identity) {
 	r.lock.Lock()
 	delete(r.effectiveRoles, id)
+	delete(r.expiry, id)
 	r.lock.Unlock()
 }
 
+// Renew sets the expiry of the identity to now + ttl. Once
+// expired, all requests sent by the identity are rejected
+// with kes.ErrIdentityExpired. If ttl is 0, the identity
+// never expires.
+//
+// It returns the new expiry or an error if the identity
+// is root or not assigned to any policy.
+func (r *Roles) Renew(id kes.Identity, ttl time.Duration) (time.Time, error) {
+	if secret.EqualIdentity(id, r.Root) {
+		return time.Time{}, errors.New("key: identity is root")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.effectiveRoles[id]; !ok {
+		return time.Time{}, errors.New("key: identity is not assigned to a policy")
+	}
+	if ttl == 0 {
+		delete(r.expiry, id)
+		return time.Time{}, nil
+	}
+	if r.expiry == nil {
+		r.expiry = map[kes.Identity]time.Time{}
+	}
+	expiry := time.Now().Add(ttl).UTC()
+	r.expiry[id] = expiry
+	return expiry, nil
+}
+
+// IsRoot reports whether the identity is the root
+// identity. It compares the identities in constant
+// time such that a client cannot guess the root
+// identity byte by byte. See: secret.EqualIdentity
+func (r *Roles) IsRoot(identity kes.Identity) bool {
+	return !identity.IsUnknown() && secret.EqualIdentity(identity, r.Root)
+}
+
+// Expiry returns the point in time when the identity
+// expires. It returns the zero time if the identity
+// never expires.
+func (r *Roles) Expiry(id kes.Identity) time.Time {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.expiry[id]
+}
+
 func (r *Roles) Verify(req *http.Request) error {
-	if req.TLS == nil {
+	_, isPeer := PeerFromRequest(req)
+	if req.TLS == nil && !isPeer {
 		// This can only happen if the server accepts non-TLS
 		// connections - which violates our fundamental security
 		// assumption. Therefore, we respond with BadRequest
 		// and log that the server is not correctly configured.
+		//
+		// The only exception are requests sent via a Unix
+		// domain socket. The peer of such a request is
+		// identified by the credentials provided by the OS.
 		return kes.NewError(http.StatusBadRequest, "insecure connection: TLS required")
 	}
 
-	if len(req.TLS.PeerCertificates) > 1 {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 1 {
 		// For now we require that the client sends
 		// only one certificate. However, it's possible
 		// to support multiple - but we have to think
@@ -177,27 +294,185 @@ func (r *Roles) Verify(req *http.Request) error {
 		return kes.NewError(http.StatusBadRequest, "too many identities: more than one certificate is present")
 	}
 
+	if r.Tenants != nil {
+		_, isTenant := r.Tenants.Lookup(r.Principal(req))
+		if isTenant && isTenantRestricted(req.URL.Path) {
+			return kes.ErrNotAllowed
+		}
+		if !isTenant && r.Tenants.isTenantKey(req.URL.Path) {
+			return kes.ErrNotAllowed
+		}
+	}
+	if req.TLS != nil {
+		if r.Sessions != nil && len(req.TLS.PeerCertificates) == 0 {
+			if token, ok := bearerToken(req); ok && strings.HasPrefix(token, SessionTokenPrefix) {
+				return r.verifySession(req, token)
+			}
+		}
+		if r.LDAP != nil && len(req.TLS.PeerCertificates) == 0 {
+			if username, password, ok := req.BasicAuth(); ok {
+				return r.verifyLDAP(req, username, password)
+			}
+		}
+		if r.OIDC != nil && len(req.TLS.PeerCertificates) == 0 {
+			if token, ok := bearerToken(req); ok {
+				return r.verifyOIDC(req, token)
+			}
+		}
+		if len(req.TLS.PeerCertificates) == 0 {
+			return kes.ErrNotAllowed
+		}
+	}
+
 	identity := Identify(req, r.Identify)
 	if identity.IsUnknown() {
 		return kes.ErrNotAllowed
 	}
-	if identity == r.Root {
+	return r.verifyIdentity(req, identity)
+}
+
+// verifyIdentity verifies the request using the policy
+// assigned to the identity and the policies of its groups.
+func (r *Roles) verifyIdentity(req *http.Request, identity kes.Identity) error {
+	if r.IsRoot(identity) {
 		return nil
 	}
 
-	var policy *kes.Policy
+	var (
+		policy *kes.Policy
+		expiry time.Time
+		groups []string // The policies of the identity's groups
+	)
 	r.lock.RLock()
 	if r.roles != nil && r.effectiveRoles != nil {
 		if name, ok := r.effectiveRoles[identity]; ok {
 			policy = r.roles[name]
+			expiry = r.expiry[identity]
 		}
 	}
+	groups = r.groupPolicies(identity)
 	r.lock.RUnlock()
 
-	if policy == nil {
-		return kes.ErrNotAllowed
+	if !expiry.IsZero() && time.Now().After(expiry) {
+		return kes.ErrIdentityExpired
+	}
+
+	var decision error = kes.ErrNotAllowed
+	if policy != nil {
+		decision = policy.Verify(req)
+	}
+	if decision != nil && len(groups) > 0 && r.verifyPolicies(req, groups) == nil {
+		decision = nil
+	}
+	return r.authorize(req, identity, decision)
+}
+
+// authorize returns the decision of the Authorizer, if
+// any, for the request of the authenticated identity.
+// Otherwise, it returns the decision of the built-in
+// policies.
+func (r *Roles) authorize(req *http.Request, identity kes.Identity, decision error) error {
+	if r.Authorizer == nil {
+		return decision
+	}
+	return r.Authorizer.Authorize(req, identity, decision)
+}
+
+// verifyLDAP authenticates the user against the LDAP
+// directory and verifies the request using the policies
+// mapped to the user's groups. The request is allowed if
+// at least one of these policies allows it.
+func (r *Roles) verifyLDAP(req *http.Request, username, password string) error {
+	groups, err := r.LDAP.Authenticate(username, password)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, group := range groups {
+		for dn, name := range r.LDAP.Policies {
+			if strings.EqualFold(dn, group) { // DNs are case-insensitive
+				names = append(names, name)
+			}
+		}
+	}
+	return r.authorize(req, Identify(req, r.Identify), r.verifyPolicies(req, names))
+}
+
+// verifyOIDC verifies the bearer token and verifies the
+// request using the policies mapped to the values of the
+// token claim. The request is allowed if at least one of
+// these policies allows it.
+func (r *Roles) verifyOIDC(req *http.Request, token string) error {
+	values, err := r.OIDC.Authenticate(token)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, value := range values {
+		if name, ok := r.OIDC.Policies[value]; ok {
+			names = append(names, name)
+		}
+	}
+	return r.authorize(req, Identify(req, r.Identify), r.verifyPolicies(req, names))
+}
+
+// verifySession authenticates the session token and verifies
+// the request using the session policy and the permissions of
+// the identity that has issued the session. The request is
+// allowed if both allow it.
+func (r *Roles) verifySession(req *http.Request, token string) error {
+	session, err := r.Sessions.Authenticate(token)
+	if err != nil {
+		return err
+	}
+	if err = session.Policy.Verify(req); err != nil {
+		return err
+	}
+	return r.verifyIdentity(req, session.Parent)
+}
+
+// verifyPolicies returns nil if at least one of the named
+// policies allows the request and kes.ErrNotAllowed otherwise.
+func (r *Roles) verifyPolicies(req *http.Request, names []string) error {
+	var policies []*kes.Policy
+	r.lock.RLock()
+	for _, name := range names {
+		if policy, ok := r.roles[name]; ok {
+			policies = append(policies, policy)
+		}
+	}
+	r.lock.RUnlock()
+
+	for _, policy := range policies {
+		if err := policy.Verify(req); err == nil {
+			return nil
+		}
+	}
+	return kes.ErrNotAllowed
+}
+
+// Principal returns the identity on whose behalf the request
+// has been sent. For a valid session token, it returns the
+// identity that has issued the session. Otherwise, it returns
+// the identity of the request. See: Identify
+//
+// In contrast to the identity of a session, the principal is
+// the same for all sessions of an identity. Hence, state of an
+// identity - like its tenant or its key quota - must be looked
+// up by the principal.
+func (r *Roles) Principal(req *http.Request) kes.Identity {
+	if r.Sessions != nil && req.TLS != nil && len(req.TLS.PeerCertificates) == 0 {
+		if token, ok := bearerToken(req); ok && strings.HasPrefix(token, SessionTokenPrefix) {
+			session, err := r.Sessions.Authenticate(token)
+			if err != nil {
+				return kes.IdentityUnknown
+			}
+			return session.Parent
+		}
 	}
-	return policy.Verify(req)
+	return Identify(req, r.Identify)
 }
 
 // Identify computes the idenitiy of the X.509
@@ -207,8 +482,25 @@ func (r *Roles) Verify(req *http.Request) error {
 // It returns IdentityUnknown if no TLS connection
 // state is present, more than one certificate
 // is present or when f returns IdentityUnknown.
+//
+// If no certificate but a username (HTTP basic auth)
+// is present, it returns "ldap:<username>". Similarly,
+// if a JWT bearer token is present, it returns
+// "oidc:<subject>" and for a session token it returns
+// "session:<identity>:<session-id>". However, these
+// identities are not authenticated.
+//
+// If the request has been sent via a Unix domain socket,
+// it returns the identity of the peer - i.e. "unix:<uid>".
+//
+// If the request context has been derived from a context
+// returned by NewConnContext, the identity is computed
+// once per connection.
 func Identify(req *http.Request, f IdentityFunc) kes.Identity {
 	if req.TLS == nil {
+		if peer, ok := PeerFromRequest(req); ok {
+			return peer.Identity()
+		}
 		return kes.IdentityUnknown
 	}
 	if len(req.TLS.PeerCertificates) > 1 {
@@ -218,9 +510,25 @@ func Identify(req *http.Request, f IdentityFunc) kes.Identity {
 	var cert *x509.Certificate
 	if len(req.TLS.PeerCertificates) > 0 {
 		cert = req.TLS.PeerCertificates[0]
+	} else if username, _, ok := req.BasicAuth(); ok && username != "" {
+		return kes.Identity("ldap:" + username)
+	} else if token, ok := bearerToken(req); ok && strings.HasPrefix(token, SessionTokenPrefix) {
+		return sessionIdentity(token)
+	} else if token, ok := bearerToken(req); ok {
+		var claims struct {
+			Subject string `json:"sub"`
+		}
+		if parts := strings.Split(token, "."); len(parts) == 3 {
+			if err := decodeJWTPart(parts[1], &claims); err == nil && claims.Subject != "" {
+				return kes.Identity("oidc:" + claims.Subject)
+			}
+		}
 	}
 	if f == nil {
-		return defaultIdentify(cert)
+		f = defaultIdentify
+	}
+	if cache, ok := req.Context().Value(connIdentityKey{}).(*connIdentity); ok && cert != nil {
+		return cache.Identify(cert, f)
 	}
 	return f(cert)
 }
@@ -234,3 +542,14 @@ func defaultIdentify(cert *x509.Certificate) kes.Identity {
 	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
 	return kes.Identity(hex.EncodeToString(h[:]))
 }
+
+// bearerToken returns the bearer token of the
+// request's Authorization header, if present.
+func bearerToken(req *http.Request) (string, bool) {
+	const Prefix = "Bearer "
+	header := req.Header.Get("Authorization")
+	if len(header) <= len(Prefix) || !strings.EqualFold(header[:len(Prefix)], Prefix) {
+		return "", false
+	}
+	return header[len(Prefix):], true
+}
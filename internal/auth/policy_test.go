@@ -0,0 +1,71 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestRolesRenew(t *testing.T) {
+	roles := &Roles{
+		Root:     "root",
+		Identify: func(*x509.Certificate) kes.Identity { return "af43c" },
+	}
+	roles.Set("my-app", mustNewPolicy("/v1/key/create/*"))
+
+	if _, err := roles.Renew("af43c", time.Hour); err == nil {
+		t.Fatal("Renewing an unassigned identity should have failed")
+	}
+	if _, err := roles.Renew("root", time.Hour); err == nil {
+		t.Fatal("Renewing the root identity should have failed")
+	}
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/key/create/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{}},
+	}
+
+	if _, err = roles.Renew("af43c", time.Hour); err != nil {
+		t.Fatalf("Failed to renew identity: %v", err)
+	}
+	if err = roles.Verify(req); err != nil {
+		t.Fatalf("Request of a non-expired identity has been rejected: %v", err)
+	}
+
+	roles.expiry["af43c"] = time.Now().Add(-time.Second)
+	if err = roles.Verify(req); err != kes.ErrIdentityExpired {
+		t.Fatalf("Request of an expired identity: got %v - want %v", err, kes.ErrIdentityExpired)
+	}
+
+	if _, err = roles.Renew("af43c", 0); err != nil {
+		t.Fatalf("Failed to remove identity TTL: %v", err)
+	}
+	if err = roles.Verify(req); err != nil {
+		t.Fatalf("Request of an identity without TTL has been rejected: %v", err)
+	}
+	if expiry := roles.Expiry("af43c"); !expiry.IsZero() {
+		t.Fatalf("Identity without TTL has an expiry: %v", expiry)
+	}
+}
+
+func mustNewPolicy(patterns ...string) *kes.Policy {
+	policy, err := kes.NewPolicy(patterns...)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
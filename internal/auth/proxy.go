@@ -40,6 +40,10 @@ type TLSProxy struct {
 	// If it is nil the client certificate won't be verified.
 	VerifyOptions *x509.VerifyOptions
 
+	// Revocation, if not nil, checks whether the certificate
+	// forwarded by the TLS proxy has been revoked.
+	Revocation *Revocation
+
 	lock       sync.RWMutex
 	identities map[kes.Identity]bool
 }
@@ -84,6 +88,9 @@ func (p *TLSProxy) Add(identity kes.Identity) {
 // If the request has not been made by a TLS proxy, Verify
 // only checks whether a client certificate is present.
 func (p *TLSProxy) Verify(req *http.Request) error {
+	if _, ok := PeerFromRequest(req); ok && req.TLS == nil {
+		return nil // Requests sent via a Unix domain socket never pass a TLS proxy
+	}
 	if req.TLS == nil {
 		// This can only happen if the server accepts non-TLS
 		// connections - which violates our fundamental security
@@ -163,6 +170,15 @@ func (p *TLSProxy) Verify(req *http.Request) error {
 				return kes.NewError(http.StatusForbidden, "")
 			}
 		}
+		if p.Revocation != nil {
+			var issuer *x509.Certificate
+			if len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 1 {
+				issuer = req.TLS.VerifiedChains[0][1]
+			}
+			if err = p.Revocation.Check(cert, issuer); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
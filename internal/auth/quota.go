@@ -0,0 +1,368 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// Quotas limits the number of keys an identity or
+// a tenant may create.
+//
+// Quotas keeps track of which identity has created
+// which key. A key counts against the quota of its
+// creator and, if the key is within a tenant namespace,
+// against the quota of the tenant. Keys created before
+// quotas have been tracked do not count against any
+// quota.
+//
+// A limit <= 0 means that there is no limit.
+type Quotas struct {
+	// Root is the root identity. Root is not limited
+	// by any identity quota.
+	Root kes.Identity
+
+	// Remote is an optional key-value store that
+	// persists the key creators and the limits
+	// set at runtime. See: Save and Load
+	Remote secret.Remote
+
+	// Tenancy holds the tenants of the server, if any.
+	// Keys within a tenant namespace count against
+	// the quota of the tenant. If nil, the first
+	// segment of a key name - e.g. "my-tenant" of
+	// "my-tenant/my-key" - is the key's tenant.
+	Tenancy *Tenants
+
+	saveLock sync.Mutex
+	lock     sync.RWMutex
+
+	limit          int                     // The default identity limit
+	identityLimits map[kes.Identity]int    // Identity limits of the config file
+	tenantLimits   map[string]int          // Tenant limits of the config file
+	identityRules  map[kes.Identity]int    // Identity limits set at runtime
+	tenantRules    map[string]int          // Tenant limits set at runtime
+	owners         map[string]kes.Identity // The creator of each key
+	identityKeys   map[kes.Identity]int    // The number of keys of each identity
+	tenantKeys     map[string]int          // The number of keys of each tenant
+}
+
+// Quota is the key limit of an identity or tenant
+// and the number of keys it has created.
+type Quota struct {
+	Limit int `json:"limit"`
+	Keys  int `json:"keys"`
+}
+
+// SetDefault sets the limit of all identities that have
+// no identity-specific limit.
+func (q *Quotas) SetDefault(limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.limit = limit
+}
+
+// SetIdentityLimit sets the limit of the given identity.
+// Runtime limits, see: SetIdentityRule, take precedence.
+func (q *Quotas) SetIdentityLimit(identity kes.Identity, limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.identityLimits == nil {
+		q.identityLimits = map[kes.Identity]int{}
+	}
+	q.identityLimits[identity] = limit
+}
+
+// SetTenantLimit sets the limit of the given tenant.
+// Runtime limits, see: SetTenantRule, take precedence.
+func (q *Quotas) SetTenantLimit(tenant string, limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.tenantLimits == nil {
+		q.tenantLimits = map[string]int{}
+	}
+	q.tenantLimits[tenant] = limit
+}
+
+// SetIdentityRule sets the limit of the given identity
+// at runtime. It takes precedence over the limit set
+// by SetIdentityLimit and is persisted by Save.
+func (q *Quotas) SetIdentityRule(identity kes.Identity, limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.identityRules == nil {
+		q.identityRules = map[kes.Identity]int{}
+	}
+	q.identityRules[identity] = limit
+}
+
+// SetTenantRule sets the limit of the given tenant
+// at runtime. It takes precedence over the limit set
+// by SetTenantLimit and is persisted by Save.
+func (q *Quotas) SetTenantRule(tenant string, limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.tenantRules == nil {
+		q.tenantRules = map[string]int{}
+	}
+	q.tenantRules[tenant] = limit
+}
+
+// Reserve reserves the key with the given name for the
+// identity. It returns kes.ErrQuotaExceeded if the
+// identity or the tenant of the key has already reached
+// its quota and kes.ErrKeyExists if the key has already
+// been created.
+//
+// A reserved key that has not been created must be
+// released.
+func (q *Quotas) Reserve(name string, identity kes.Identity) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if _, ok := q.owners[name]; ok {
+		return kes.ErrKeyExists
+	}
+	if !secret.EqualIdentity(identity, q.Root) {
+		if limit := q.identityLimit(identity); limit > 0 && q.identityKeys[identity] >= limit {
+			return kes.ErrQuotaExceeded
+		}
+	}
+	tenant, isTenant := q.tenantOf(name)
+	if isTenant {
+		if limit := q.tenantLimit(tenant); limit > 0 && q.tenantKeys[tenant] >= limit {
+			return kes.ErrQuotaExceeded
+		}
+	}
+
+	if q.owners == nil {
+		q.owners = map[string]kes.Identity{}
+		q.identityKeys = map[kes.Identity]int{}
+		q.tenantKeys = map[string]int{}
+	}
+	q.owners[name] = identity
+	q.identityKeys[identity]++
+	if isTenant {
+		q.tenantKeys[tenant]++
+	}
+	return nil
+}
+
+// Release releases the key with the given name - e.g.
+// once the key has been deleted. It does nothing if
+// the key has not been reserved.
+func (q *Quotas) Release(name string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	identity, ok := q.owners[name]
+	if !ok {
+		return
+	}
+	delete(q.owners, name)
+	if q.identityKeys[identity]--; q.identityKeys[identity] <= 0 {
+		delete(q.identityKeys, identity)
+	}
+	if tenant, ok := q.tenantOf(name); ok {
+		if q.tenantKeys[tenant]--; q.tenantKeys[tenant] <= 0 {
+			delete(q.tenantKeys, tenant)
+		}
+	}
+}
+
+// Identity returns the quota of the given identity.
+func (q *Quotas) Identity(identity kes.Identity) Quota {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	return Quota{
+		Limit: q.identityLimit(identity),
+		Keys:  q.identityKeys[identity],
+	}
+}
+
+// Tenant returns the quota of the given tenant.
+func (q *Quotas) Tenant(tenant string) Quota {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	return Quota{
+		Limit: q.tenantLimit(tenant),
+		Keys:  q.tenantKeys[tenant],
+	}
+}
+
+// Identities returns the quotas of all identities that
+// have a limit or have created at least one key.
+func (q *Quotas) Identities() map[kes.Identity]Quota {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	quotas := map[kes.Identity]Quota{}
+	for identity := range q.identityLimits {
+		quotas[identity] = Quota{Limit: q.identityLimit(identity), Keys: q.identityKeys[identity]}
+	}
+	for identity := range q.identityRules {
+		quotas[identity] = Quota{Limit: q.identityLimit(identity), Keys: q.identityKeys[identity]}
+	}
+	for identity, keys := range q.identityKeys {
+		quotas[identity] = Quota{Limit: q.identityLimit(identity), Keys: keys}
+	}
+	return quotas
+}
+
+// Tenants returns the quotas of all tenants that have
+// a limit or have at least one key.
+func (q *Quotas) Tenants() map[string]Quota {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	quotas := map[string]Quota{}
+	for tenant := range q.tenantLimits {
+		quotas[tenant] = Quota{Limit: q.tenantLimit(tenant), Keys: q.tenantKeys[tenant]}
+	}
+	for tenant := range q.tenantRules {
+		quotas[tenant] = Quota{Limit: q.tenantLimit(tenant), Keys: q.tenantKeys[tenant]}
+	}
+	for tenant, keys := range q.tenantKeys {
+		quotas[tenant] = Quota{Limit: q.tenantLimit(tenant), Keys: keys}
+	}
+	return quotas
+}
+
+// tenantOf returns the tenant of the key with the given
+// name, if the key is within a tenant namespace. If Tenancy
+// is set, a key name like "my-app/my-key" is only within a
+// tenant namespace if there is a tenant "my-app".
+func (q *Quotas) tenantOf(name string) (string, bool) {
+	tenant, ok := tenantOfKey(name)
+	if ok && q.Tenancy != nil {
+		ok = q.Tenancy.Exists(tenant)
+	}
+	return tenant, ok
+}
+
+// identityLimit returns the limit of the identity.
+//
+// The caller must hold the lock.
+func (q *Quotas) identityLimit(identity kes.Identity) int {
+	if limit, ok := q.identityRules[identity]; ok {
+		return limit
+	}
+	if limit, ok := q.identityLimits[identity]; ok {
+		return limit
+	}
+	return q.limit
+}
+
+// tenantLimit returns the limit of the tenant.
+//
+// The caller must hold the lock.
+func (q *Quotas) tenantLimit(tenant string) int {
+	if limit, ok := q.tenantRules[tenant]; ok {
+		return limit
+	}
+	return q.tenantLimits[tenant]
+}
+
+// quotaState is the persisted representation of the
+// key creators and the limits set at runtime.
+type quotaState struct {
+	Identities map[kes.Identity]int    `json:"identities,omitempty"`
+	Tenants    map[string]int          `json:"tenants,omitempty"`
+	Owners     map[string]kes.Identity `json:"owners"`
+}
+
+// Save writes the key creators and the limits set at
+// runtime to the Remote store under secret.ReservedQuotaName.
+// It does nothing if no Remote store is set.
+//
+// Save replaces the entry via secret.Replace such that
+// the key creators are not lost if Save fails.
+func (q *Quotas) Save() error {
+	if q.Remote == nil {
+		return nil
+	}
+
+	// The save lock ensures that concurrent calls to Save
+	// write their snapshots in order. Otherwise, an older
+	// snapshot may overwrite a more recent one.
+	q.saveLock.Lock()
+	defer q.saveLock.Unlock()
+
+	q.lock.RLock()
+	state := quotaState{
+		Identities: make(map[kes.Identity]int, len(q.identityRules)),
+		Tenants:    make(map[string]int, len(q.tenantRules)),
+		Owners:     make(map[string]kes.Identity, len(q.owners)),
+	}
+	for identity, limit := range q.identityRules {
+		state.Identities[identity] = limit
+	}
+	for tenant, limit := range q.tenantRules {
+		state.Tenants[tenant] = limit
+	}
+	for name, identity := range q.owners {
+		state.Owners[name] = identity
+	}
+	q.lock.RUnlock()
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return secret.Replace(q.Remote, secret.ReservedQuotaName, string(value))
+}
+
+// Load reads the key creators and the limits set at runtime
+// from the Remote store. It does nothing if no Remote store
+// is set or the Remote store does not contain any quotas.
+func (q *Quotas) Load() error {
+	if q.Remote == nil {
+		return nil
+	}
+
+	value, err := secret.Load(q.Remote, secret.ReservedQuotaName)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state quotaState
+	if err = json.Unmarshal([]byte(value), &state); err != nil {
+		return errors.New("auth: persisted quotas are malformed")
+	}
+
+	for identity, limit := range state.Identities {
+		q.SetIdentityRule(identity, limit)
+	}
+	for tenant, limit := range state.Tenants {
+		q.SetTenantRule(tenant, limit)
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.owners = make(map[string]kes.Identity, len(state.Owners))
+	q.identityKeys = map[kes.Identity]int{}
+	q.tenantKeys = map[string]int{}
+	for name, identity := range state.Owners {
+		q.owners[name] = identity
+		q.identityKeys[identity]++
+		if tenant, ok := q.tenantOf(name); ok {
+			q.tenantKeys[tenant]++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+)
+
+func TestQuotasReserve(t *testing.T) {
+	quotas := &Quotas{Root: "root"}
+	quotas.SetDefault(2)
+	quotas.SetIdentityLimit("b2ce1", 0)
+	quotas.SetTenantLimit("tenant-a", 3)
+
+	for _, name := range []string{"key-1", "key-2"} {
+		if err := quotas.Reserve(name, "af43c"); err != nil {
+			t.Fatalf("Failed to reserve key '%s': %v", name, err)
+		}
+	}
+	if err := quotas.Reserve("key-3", "af43c"); err != kes.ErrQuotaExceeded {
+		t.Fatalf("Reserve should have failed with '%v' but got: %v", kes.ErrQuotaExceeded, err)
+	}
+	if err := quotas.Reserve("key-1", "c4d3e"); err != kes.ErrKeyExists {
+		t.Fatalf("Reserve should have failed with '%v' but got: %v", kes.ErrKeyExists, err)
+	}
+	for _, name := range []string{"key-3", "key-4", "key-5"} {
+		if err := quotas.Reserve(name, "root"); err != nil {
+			t.Fatalf("Root should not be limited: %v", err)
+		}
+	}
+
+	for _, name := range []string{"tenant-a/key-1", "tenant-a/key-2", "tenant-a/key-3"} {
+		if err := quotas.Reserve(name, "b2ce1"); err != nil {
+			t.Fatalf("Failed to reserve key '%s': %v", name, err)
+		}
+	}
+	if err := quotas.Reserve("tenant-a/key-4", "b2ce1"); err != kes.ErrQuotaExceeded {
+		t.Fatalf("Reserve should have failed with '%v' but got: %v", kes.ErrQuotaExceeded, err)
+	}
+	if quota := quotas.Tenant("tenant-a"); quota.Limit != 3 || quota.Keys != 3 {
+		t.Fatalf("Tenant quota mismatch: got %+v", quota)
+	}
+
+	quotas.Release("tenant-a/key-1")
+	if err := quotas.Reserve("tenant-a/key-4", "b2ce1"); err != nil {
+		t.Fatalf("Failed to reserve key after release: %v", err)
+	}
+
+	quotas.SetIdentityRule("af43c", 3) // Runtime limits take precedence
+	if err := quotas.Reserve("key-6", "af43c"); err != nil {
+		t.Fatalf("Failed to reserve key after raising the limit: %v", err)
+	}
+	if quota := quotas.Identity("af43c"); quota.Limit != 3 || quota.Keys != 3 {
+		t.Fatalf("Identity quota mismatch: got %+v", quota)
+	}
+}
+
+func TestQuotasTenants(t *testing.T) {
+	tenants := new(Tenants)
+	if err := tenants.Add("tenant-a", "b2ce1"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	quotas := &Quotas{Tenancy: tenants}
+	quotas.SetTenantLimit("tenant-a", 1)
+
+	// A hierarchical key name is only within a tenant
+	// namespace if its first segment is a tenant.
+	for _, name := range []string{"tenant-a/my-key", "my-app/my-key"} {
+		if err := quotas.Reserve(name, "b2ce1"); err != nil {
+			t.Fatalf("Failed to reserve key '%s': %v", name, err)
+		}
+	}
+	if err := quotas.Reserve("tenant-a/my-app/my-key", "b2ce1"); err != kes.ErrQuotaExceeded {
+		t.Fatalf("Reserve should have failed with '%v' but got: %v", kes.ErrQuotaExceeded, err)
+	}
+	if tenantQuotas := quotas.Tenants(); len(tenantQuotas) != 1 || tenantQuotas["tenant-a"].Keys != 1 {
+		t.Fatalf("Tenant quotas mismatch: got %+v", tenantQuotas)
+	}
+}
+
+func TestQuotasSaveLoad(t *testing.T) {
+	remote := &mem.Store{}
+	quotas := &Quotas{Remote: remote}
+	quotas.SetDefault(10)
+	quotas.SetIdentityRule("af43c", 2)
+
+	for _, name := range []string{"key-1", "tenant-a/key-1"} {
+		if err := quotas.Reserve(name, "af43c"); err != nil {
+			t.Fatalf("Failed to reserve key '%s': %v", name, err)
+		}
+	}
+	if err := quotas.Save(); err != nil {
+		t.Fatalf("Failed to save quotas: %v", err)
+	}
+	quotas.Release("key-1")
+	if err := quotas.Save(); err != nil { // Saving twice must replace the persisted quotas
+		t.Fatalf("Failed to save quotas: %v", err)
+	}
+
+	loaded := &Quotas{Remote: remote}
+	loaded.SetDefault(10)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Failed to load quotas: %v", err)
+	}
+	if quota := loaded.Identity("af43c"); quota.Limit != 2 || quota.Keys != 1 {
+		t.Fatalf("Loaded identity quota mismatch: got %+v", quota)
+	}
+	if quota := loaded.Tenant("tenant-a"); quota.Keys != 1 {
+		t.Fatalf("Loaded tenant quota mismatch: got %+v", quota)
+	}
+	if err := loaded.Reserve("tenant-a/key-1", "b2ce1"); err != kes.ErrKeyExists {
+		t.Fatalf("Reserve should have failed with '%v' but got: %v", kes.ErrKeyExists, err)
+	}
+
+	empty := &Quotas{Remote: &mem.Store{}}
+	if err := empty.Load(); err != nil {
+		t.Fatalf("Failed to load from empty store: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Revocation checks whether client certificates have been
+// revoked - either by looking them up in a certificate
+// revocation list (CRL) or by asking the OCSP responder
+// of the certificate issuer.
+//
+// Its VerifyPeerCertificate method can be used as
+// tls.Config.VerifyPeerCertificate such that clients with
+// a revoked certificate cannot establish a connection.
+type Revocation struct {
+	// CRL is the path or the http(s) URL of a DER or
+	// PEM-encoded CRL. If empty, no CRL is checked.
+	CRL string
+
+	// OCSP controls whether the revocation status of a
+	// certificate is checked via OCSP. OCSP requires the
+	// issuer certificate. Therefore, certificates without
+	// verified certificate chain are not checked via OCSP.
+	OCSP bool
+
+	// OCSPResponder is the URL of the OCSP responder. If
+	// empty, the responder specified in the certificate is
+	// used. Certificates that don't specify a responder are
+	// not checked via OCSP.
+	OCSPResponder string
+
+	// FailOpen controls whether a certificate is accepted
+	// when its revocation status cannot be determined - e.g.
+	// because the CRL cannot be fetched or the OCSP responder
+	// is not reachable. By default, such certificates are
+	// rejected.
+	FailOpen bool
+
+	// CacheExpiry is the duration a CRL or OCSP response
+	// is cached. A CRL or OCSP response is refreshed earlier
+	// when its next update is due. If 0, it defaults to
+	// 5 minutes.
+	CacheExpiry time.Duration
+
+	// Client is the HTTP client used to fetch CRLs and
+	// OCSP responses. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// ErrorLog specifies an optional logger for errors
+	// when the revocation status of a certificate cannot
+	// be determined. If nil, logging is done via the log
+	// package's standard logger.
+	ErrorLog *log.Logger
+
+	lock      sync.Mutex
+	crl       *pkix.CertificateList
+	crlExpiry time.Time
+	responses map[string]ocspEntry // OCSP responses by issuer and serial number
+}
+
+type ocspEntry struct {
+	Status int
+	Expiry time.Time
+}
+
+var (
+	errCertificateRevoked   = kes.NewError(http.StatusForbidden, "certificate has been revoked")
+	errRevocationNotChecked = kes.NewError(http.StatusForbidden, "certificate revocation status is unknown")
+)
+
+// VerifyPeerCertificate checks whether the certificate of the
+// TLS peer has been revoked. It can be used as
+// tls.Config.VerifyPeerCertificate.
+func (r *Revocation) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 { // The peer has not sent a certificate
+		return nil
+	}
+
+	var cert, issuer *x509.Certificate
+	if len(verifiedChains) > 0 && len(verifiedChains[0]) > 0 {
+		cert = verifiedChains[0][0]
+		if len(verifiedChains[0]) > 1 {
+			issuer = verifiedChains[0][1]
+		}
+	} else {
+		// Without verified chain we must not trust any
+		// issuer certificate sent by the peer.
+		var err error
+		if cert, err = x509.ParseCertificate(rawCerts[0]); err != nil {
+			return err
+		}
+	}
+	return r.Check(cert, issuer)
+}
+
+// Check returns an error if the certificate has been revoked
+// or if its revocation status cannot be determined and
+// FailOpen is false.
+//
+// The issuer is the verified certificate of the CA that
+// has issued the certificate. It may be nil if unknown.
+func (r *Revocation) Check(cert, issuer *x509.Certificate) error {
+	if r.CRL != "" {
+		revoked, err := r.checkCRL(cert, issuer)
+		if err != nil {
+			r.logf("auth: failed to check CRL for certificate '%s': %v", cert.Subject.CommonName, err)
+			if !r.FailOpen {
+				return errRevocationNotChecked
+			}
+		}
+		if revoked {
+			return errCertificateRevoked
+		}
+	}
+	if r.OCSP && issuer != nil {
+		status, err := r.checkOCSP(cert, issuer)
+		if err != nil {
+			r.logf("auth: failed to check OCSP status of certificate '%s': %v", cert.Subject.CommonName, err)
+			if !r.FailOpen {
+				return errRevocationNotChecked
+			}
+		}
+		switch status {
+		case ocsp.Revoked:
+			return errCertificateRevoked
+		case ocsp.Unknown:
+			if err == nil && !r.FailOpen {
+				return errRevocationNotChecked
+			}
+		}
+	}
+	return nil
+}
+
+// checkCRL reports whether the CRL contains the certificate.
+// It only considers a CRL issued by the certificate issuer.
+func (r *Revocation) checkCRL(cert, issuer *x509.Certificate) (bool, error) {
+	crl, err := r.loadCRL()
+	if err != nil {
+		return false, err
+	}
+	if crl.TBSCertList.Issuer.String() != cert.Issuer.ToRDNSequence().String() {
+		return false, nil // The CRL has not been issued by the certificate issuer
+	}
+	if issuer != nil {
+		if err = issuer.CheckCRLSignature(crl); err != nil {
+			return false, fmt.Errorf("invalid CRL signature: %v", err)
+		}
+	}
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// loadCRL returns the cached CRL or fetches it
+// if the cached CRL has expired.
+func (r *Revocation) loadCRL() (*pkix.CertificateList, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	if r.crl != nil && now.Before(r.crlExpiry) {
+		return r.crl, nil
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if strings.HasPrefix(r.CRL, "https://") || strings.HasPrefix(r.CRL, "http://") {
+		data, err = r.fetch(http.MethodGet, r.CRL, "", nil)
+	} else {
+		data, err = ioutil.ReadFile(r.CRL)
+	}
+	if err == nil {
+		var crl *pkix.CertificateList
+		if crl, err = x509.ParseCRL(data); err == nil {
+			r.crl, r.crlExpiry = crl, r.expiry(now, crl.TBSCertList.NextUpdate)
+			return r.crl, nil
+		}
+	}
+	if r.crl != nil {
+		// Keep using the previous CRL but don't try to
+		// refresh it on every handshake.
+		r.logf("auth: failed to refresh CRL '%s': %v", r.CRL, err)
+		r.crlExpiry = now.Add(time.Minute)
+		return r.crl, nil
+	}
+	return nil, err
+}
+
+// checkOCSP returns the OCSP status of the certificate.
+func (r *Revocation) checkOCSP(cert, issuer *x509.Certificate) (int, error) {
+	responder := r.OCSPResponder
+	if responder == "" {
+		if len(cert.OCSPServer) == 0 {
+			return ocsp.Good, nil // The certificate cannot be checked via OCSP
+		}
+		responder = cert.OCSPServer[0]
+	}
+
+	issuerHash := sha256.Sum256(issuer.Raw)
+	key := hex.EncodeToString(issuerHash[:]) + "/" + cert.SerialNumber.String()
+	now := time.Now()
+	r.lock.Lock()
+	if entry, ok := r.responses[key]; ok && now.Before(entry.Expiry) {
+		r.lock.Unlock()
+		return entry.Status, nil
+	}
+	r.lock.Unlock()
+
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return ocsp.Unknown, err
+	}
+	data, err := r.fetch(http.MethodPost, responder, "application/ocsp-request", request)
+	if err != nil {
+		return ocsp.Unknown, err
+	}
+	response, err := ocsp.ParseResponseForCert(data, cert, issuer)
+	if err != nil {
+		return ocsp.Unknown, err
+	}
+
+	r.lock.Lock()
+	if r.responses == nil {
+		r.responses = map[string]ocspEntry{}
+	}
+	for k, entry := range r.responses { // Remove expired entries such that the cache does not grow forever
+		if now.After(entry.Expiry) {
+			delete(r.responses, k)
+		}
+	}
+	r.responses[key] = ocspEntry{Status: response.Status, Expiry: r.expiry(now, response.NextUpdate)}
+	r.lock.Unlock()
+	return response.Status, nil
+}
+
+// expiry returns the point in time when a CRL or OCSP
+// response with the given next update time expires.
+func (r *Revocation) expiry(now, nextUpdate time.Time) time.Time {
+	cacheExpiry := r.CacheExpiry
+	if cacheExpiry == 0 {
+		cacheExpiry = 5 * time.Minute
+	}
+	expiry := now.Add(cacheExpiry)
+	if !nextUpdate.IsZero() && nextUpdate.After(now) && nextUpdate.Before(expiry) {
+		expiry = nextUpdate
+	}
+	return expiry
+}
+
+// fetch sends an HTTP request with the given body to url
+// and returns the response body.
+func (r *Revocation) fetch(method, url, contentType string, body []byte) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	const MaxSize = 32 << 20 // CRLs of large CAs can be quite large
+	return ioutil.ReadAll(io.LimitReader(resp.Body, MaxSize))
+}
+
+func (r *Revocation) logf(format string, v ...interface{}) {
+	if r.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		r.ErrorLog.Printf(format, v...)
+	}
+}
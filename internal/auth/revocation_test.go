@@ -0,0 +1,145 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCertificate returns a new certificate with the
+// given serial number. If parent is nil, it returns a
+// self-signed CA certificate.
+func newTestCertificate(t *testing.T, serial int64, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if parent == nil {
+		template.Subject.CommonName = "CA"
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		parent, parentKey = template, key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestRevocationCRL(t *testing.T) {
+	ca, caKey := newTestCertificate(t, 1, nil, nil)
+	good, _ := newTestCertificate(t, 2, ca, caKey)
+	revoked, _ := newTestCertificate(t, 3, ca, caKey)
+
+	crl, err := ca.CreateCRL(rand.Reader, caKey, []pkix.RevokedCertificate{
+		{SerialNumber: revoked.SerialNumber, RevocationTime: time.Now()},
+	}, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "kes-revocation")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	crlPath := filepath.Join(dir, "ca.crl")
+	if err = ioutil.WriteFile(crlPath, crl, 0600); err != nil {
+		t.Fatalf("Failed to write CRL: %v", err)
+	}
+
+	revocation := &Revocation{CRL: crlPath}
+	if err = revocation.Check(good, ca); err != nil { // 0
+		t.Fatalf("Test 0: certificate should not be revoked: %v", err)
+	}
+	if err = revocation.Check(revoked, ca); err != errCertificateRevoked { // 1
+		t.Fatalf("Test 1: error mismatch: got %v - want %v", err, errCertificateRevoked)
+	}
+	if err = revocation.Check(revoked, nil); err != errCertificateRevoked { // 2
+		t.Fatalf("Test 2: error mismatch: got %v - want %v", err, errCertificateRevoked)
+	}
+
+	revocation = &Revocation{CRL: filepath.Join(dir, "missing.crl"), ErrorLog: log.New(ioutil.Discard, "", 0)}
+	if err = revocation.Check(good, ca); err != errRevocationNotChecked { // 3
+		t.Fatalf("Test 3: error mismatch: got %v - want %v", err, errRevocationNotChecked)
+	}
+	revocation.FailOpen = true
+	if err = revocation.Check(good, ca); err != nil { // 4
+		t.Fatalf("Test 4: certificate should be accepted: %v", err)
+	}
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	ca, caKey := newTestCertificate(t, 1, nil, nil)
+	good, _ := newTestCertificate(t, 2, ca, caKey)
+	revoked, _ := newTestCertificate(t, 3, ca, caKey)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := ioutil.ReadAll(r.Body)
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: request.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if request.SerialNumber.Cmp(revoked.SerialNumber) == 0 {
+			template.Status, template.RevokedAt = ocsp.Revoked, time.Now()
+		}
+		response, err := ocsp.CreateResponse(ca, ca, template, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	revocation := &Revocation{OCSP: true, OCSPResponder: server.URL}
+	if err := revocation.Check(good, ca); err != nil { // 0
+		t.Fatalf("Test 0: certificate should not be revoked: %v", err)
+	}
+	if err := revocation.Check(revoked, ca); err != errCertificateRevoked { // 1
+		t.Fatalf("Test 1: error mismatch: got %v - want %v", err, errCertificateRevoked)
+	}
+	if err := revocation.Check(good, ca); err != nil || requests != 2 { // 2 - cached response
+		t.Fatalf("Test 2: OCSP response should have been cached: got %d requests - want 2: %v", requests, err)
+	}
+	if err := revocation.Check(revoked, nil); err != nil { // 3 - no verified issuer
+		t.Fatalf("Test 3: certificate without issuer should not be checked via OCSP: %v", err)
+	}
+}
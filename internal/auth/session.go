@@ -0,0 +1,209 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// SessionTokenPrefix is the prefix of all session tokens.
+// It distinguishes session tokens from other bearer tokens -
+// e.g. OIDC tokens.
+const SessionTokenPrefix = "kes-session."
+
+// Sessions issues short-lived session tokens. A session token
+// is a credential derived from an identity with a narrowed
+// policy. An identity can pass a session token to ephemeral
+// workloads - e.g. batch jobs - instead of its own long-lived
+// private key and certificate.
+//
+// A client authenticates with a session token by sending it
+// as bearer token. A request of a session is allowed if the
+// session policy and the identity that has issued the session
+// allow it. Hence, a session never has more permissions than
+// its identity and loses all permissions once the identity
+// gets removed.
+type Sessions struct {
+	// TTL is the default lifetime of a session.
+	// If <= 0, it defaults to one hour.
+	TTL time.Duration
+
+	// MaxTTL is the max. lifetime of a session.
+	// If <= 0, it defaults to 24 hours.
+	MaxTTL time.Duration
+
+	// Secret is an optional secret used to authenticate
+	// session tokens. Servers with the same secret accept
+	// each other's session tokens. If empty, a random
+	// secret is generated.
+	Secret []byte
+
+	lock sync.Mutex
+}
+
+// Session is a short-lived credential derived from
+// an identity.
+type Session struct {
+	ID     string       `json:"id"`
+	Parent kes.Identity `json:"parent"` // The identity that has issued the session
+	Policy *kes.Policy  `json:"policy"`
+	Expiry time.Time    `json:"exp"`
+}
+
+// Identity returns the identity of the session. It
+// contains the identity that has issued the session
+// such that the audit log can attribute requests of
+// the session to it.
+func (s *Session) Identity() kes.Identity {
+	return kes.Identity("session:" + s.Parent.String() + ":" + s.ID)
+}
+
+var (
+	errSessionExpired = kes.NewError(http.StatusUnauthorized, "session expired")
+	errSessionInvalid = kes.NewError(http.StatusUnauthorized, "invalid session token")
+	errSessionTTL     = kes.NewError(http.StatusBadRequest, "invalid session ttl: exceeds max. session lifetime")
+)
+
+// Issue returns a new session token of the parent identity
+// with the given policy and lifetime. If ttl is 0, the
+// session expires after the default TTL.
+func (s *Sessions) Issue(parent kes.Identity, policy *kes.Policy, ttl time.Duration) (string, Session, error) {
+	if ttl == 0 {
+		ttl = s.ttl()
+	}
+	if ttl < 0 || ttl > s.maxTTL() {
+		return "", Session{}, errSessionTTL
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", Session{}, err
+	}
+	key, err := s.secret()
+	if err != nil {
+		return "", Session{}, err
+	}
+	session := Session{
+		ID:     hex.EncodeToString(id[:]),
+		Parent: parent,
+		Policy: policy,
+		Expiry: time.Now().Add(ttl).Truncate(time.Second).UTC(),
+	}
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", Session{}, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	token := SessionTokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return token, session, nil
+}
+
+// Authenticate verifies the session token and returns
+// the session on success.
+func (s *Sessions) Authenticate(token string) (Session, error) {
+	key, err := s.secret()
+	if err != nil {
+		return Session{}, err
+	}
+	payload, sum, ok := splitSessionToken(token)
+	if !ok {
+		return Session{}, errSessionInvalid
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return Session{}, errSessionInvalid
+	}
+
+	var session Session
+	if err = json.Unmarshal(payload, &session); err != nil {
+		return Session{}, errSessionInvalid
+	}
+	if session.Policy == nil || session.Parent.IsUnknown() {
+		return Session{}, errSessionInvalid
+	}
+	if !time.Now().Before(session.Expiry) {
+		return Session{}, errSessionExpired
+	}
+	return session, nil
+}
+
+func (s *Sessions) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return time.Hour
+	}
+	return s.TTL
+}
+
+func (s *Sessions) maxTTL() time.Duration {
+	if s.MaxTTL <= 0 {
+		return 24 * time.Hour
+	}
+	return s.MaxTTL
+}
+
+// secret returns the key used to authenticate session
+// tokens. It generates a random key if no Secret is set.
+func (s *Sessions) secret() ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.Secret) == 0 {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, err
+		}
+		s.Secret = key[:]
+	}
+	return s.Secret, nil
+}
+
+// sessionIdentity returns the identity of the session
+// token without authenticating it.
+func sessionIdentity(token string) kes.Identity {
+	payload, _, ok := splitSessionToken(token)
+	if !ok {
+		return kes.IdentityUnknown
+	}
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil || session.Parent.IsUnknown() {
+		return kes.IdentityUnknown
+	}
+	return session.Identity()
+}
+
+// splitSessionToken returns the decoded payload and
+// authentication tag of the session token.
+func splitSessionToken(token string) (payload, sum []byte, ok bool) {
+	if !strings.HasPrefix(token, SessionTokenPrefix) {
+		return nil, nil, false
+	}
+	token = strings.TrimPrefix(token, SessionTokenPrefix)
+
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, nil, false
+	}
+	if sum, err = base64.RawURLEncoding.DecodeString(token[i+1:]); err != nil {
+		return nil, nil, false
+	}
+	return payload, sum, true
+}
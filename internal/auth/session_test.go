@@ -0,0 +1,124 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestSessionsIssue(t *testing.T) {
+	sessions := &Sessions{MaxTTL: time.Hour}
+	if _, _, err := sessions.Issue("af43c", mustNewPolicy("/v1/key/create/*"), 2*time.Hour); err != errSessionTTL {
+		t.Fatalf("Issued session exceeding max. TTL: got %v - want %v", err, errSessionTTL)
+	}
+
+	token, session, err := sessions.Issue("af43c", mustNewPolicy("/v1/key/create/*"), 0)
+	if err != nil {
+		t.Fatalf("Failed to issue session: %v", err)
+	}
+	if d := time.Until(session.Expiry); d <= 0 || d > time.Hour {
+		t.Fatalf("Invalid session expiry: %v", session.Expiry)
+	}
+	authenticated, err := sessions.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Failed to authenticate session: %v", err)
+	}
+	if authenticated.Identity() != session.Identity() || authenticated.Parent != "af43c" {
+		t.Fatalf("Invalid session: got %s - want %s", authenticated.Identity(), session.Identity())
+	}
+	if id := sessionIdentity(token); id != session.Identity() {
+		t.Fatalf("Invalid session identity: got %s - want %s", id, session.Identity())
+	}
+
+	if _, err = sessions.Authenticate(token[:len(token)-2]); err != errSessionInvalid {
+		t.Fatalf("Authenticated modified session token: got %v - want %v", err, errSessionInvalid)
+	}
+	other := &Sessions{}
+	if _, err = other.Authenticate(token); err != errSessionInvalid {
+		t.Fatalf("Authenticated session token of other server: got %v - want %v", err, errSessionInvalid)
+	}
+}
+
+func TestRolesVerifySession(t *testing.T) {
+	roles := &Roles{Root: "root", Sessions: &Sessions{}}
+	roles.Set("my-app", mustNewPolicy("/v1/key/create/*", "/v1/key/generate/*"))
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	token, _, err := roles.Sessions.Issue("af43c", mustNewPolicy("/v1/key/generate/*", "/v1/key/delete/*"), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to issue session: %v", err)
+	}
+
+	verify := func(path string) error {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373"+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return roles.Verify(req)
+	}
+	for i, test := range []struct {
+		Path string
+		Err  error
+	}{
+		{Path: "/v1/key/generate/my-key", Err: nil},             // 0
+		{Path: "/v1/key/create/my-key", Err: kes.ErrNotAllowed}, // 1: session policy does not allow it
+		{Path: "/v1/key/delete/my-key", Err: kes.ErrNotAllowed}, // 2: identity policy does not allow it
+	} {
+		if err = verify(test.Path); err != test.Err {
+			t.Fatalf("Test %d: got %v - want %v", i, err, test.Err)
+		}
+	}
+
+	roles.Forget("af43c")
+	if err = verify("/v1/key/generate/my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Session of removed identity: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestRolesVerifySessionOfTenant(t *testing.T) {
+	roles := &Roles{Root: "root", Sessions: &Sessions{}, Tenants: &Tenants{}}
+	roles.Set("my-app", mustNewPolicy("/v1/key/generate/*", "/v1/policy/write/*"))
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err := roles.Tenants.Add("tenant-a", "af43c"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	token, _, err := roles.Sessions.Issue("af43c", mustNewPolicy("/v1/key/generate/*", "/v1/policy/write/*"), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to issue session: %v", err)
+	}
+
+	newRequest := func(path, token string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373"+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+	if principal := roles.Principal(newRequest("/v1/key/generate/my-key", token)); principal != "af43c" {
+		t.Fatalf("Invalid principal: got %v - want %v", principal, kes.Identity("af43c"))
+	}
+	if principal := roles.Principal(newRequest("/v1/key/generate/my-key", token+"a")); !principal.IsUnknown() {
+		t.Fatalf("Invalid principal of modified session token: got %v - want %v", principal, kes.IdentityUnknown)
+	}
+
+	if err = roles.Verify(newRequest("/v1/key/generate/my-key", token)); err != nil {
+		t.Fatalf("Failed to verify request within the tenant namespace: %v", err)
+	}
+	if err = roles.Verify(newRequest("/v1/policy/write/my-policy", token)); err != kes.ErrNotAllowed {
+		t.Fatalf("Session of tenant identity accessed a global API: got %v - want %v", err, kes.ErrNotAllowed)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+var (
+	errRequestNotSigned   = kes.NewError(http.StatusUnauthorized, "request is not signed")
+	errInvalidSignature   = kes.NewError(http.StatusUnauthorized, "invalid request signature")
+	errSignatureExpired   = kes.NewError(http.StatusUnauthorized, "request signature expired")
+	errRequestReplayed    = kes.NewError(http.StatusUnauthorized, "request has already been processed")
+	errTooManyNonces      = kes.NewError(http.StatusTooManyRequests, "too many signed requests")
+	errSignatureNoCert    = kes.NewError(http.StatusBadRequest, "request signature requires a client certificate")
+	errInvalidRequestBody = kes.NewError(http.StatusBadRequest, "invalid request body")
+)
+
+// defaultSignatureSkew is the default max. time difference
+// between the signature date of a request and the server time.
+const defaultSignatureSkew = 5 * time.Minute
+
+// defaultMaxNonces is the default max. number of nonces
+// remembered per identity.
+const defaultMaxNonces = 100000
+
+// SignatureVerifier verifies that requests have been signed
+// with the private key of the client certificate and rejects
+// requests that have been sent before.
+//
+// In contrast to mTLS, a request signature protects the request
+// even if the TLS connection is terminated by a TLS proxy. In
+// particular, neither the proxy nor anyone who captured the
+// request can modify or replay it.
+//
+// A SignatureVerifier detects replayed requests by remembering
+// the nonce of each request as long as its signature date is
+// valid. Therefore, it only detects requests replayed to the
+// same server. The nonces are remembered per identity - i.e.
+// per public key of the client certificate - such that one
+// identity cannot exhaust the nonces of another.
+type SignatureVerifier struct {
+	// MaxSkew is the max. time difference between the
+	// signature date of a request and the server time.
+	// Requests with a signature date outside this time
+	// window are rejected.
+	//
+	// If MaxSkew <= 0, it defaults to 5 minutes.
+	MaxSkew time.Duration
+
+	// MaxNonces is the max. number of nonces remembered
+	// per identity. Once an identity has sent MaxNonces
+	// requests within the MaxSkew time window, its
+	// requests are rejected until the oldest nonces
+	// expire.
+	//
+	// If MaxNonces <= 0, it defaults to 100000.
+	MaxNonces int
+
+	lock      sync.Mutex
+	nonces    map[kes.Identity]map[string]time.Time // The nonces seen per identity and their expiry
+	lastSweep time.Time
+}
+
+// Verify verifies the signature of the given request. It
+// returns an error if the request is not signed, has not been
+// signed with the private key of the client certificate, has
+// been signed outside the MaxSkew time window or has been
+// sent before. Requests sent via a Unix domain socket do not
+// have to be signed.
+//
+// Verify reads the entire request body. However, it replaces
+// the request body such that handlers further down the stack
+// can read it again.
+//
+// If the request has been forwarded by a TLS proxy, Verify
+// must be called after the TLSProxy has replaced the proxy
+// certificate with the actual client certificate.
+func (v *SignatureVerifier) Verify(req *http.Request) error {
+	if _, ok := PeerFromRequest(req); ok && req.TLS == nil {
+		// A peer connected via a Unix domain socket has no
+		// private key to sign requests with. Its requests
+		// cannot be intercepted or replayed by another host.
+		return nil
+	}
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return errSignatureNoCert
+	}
+	if req.Header.Get(kes.HeaderSignature) == "" {
+		return errRequestNotSigned
+	}
+
+	nonce := req.Header.Get(kes.HeaderSignatureNonce)
+	if len(nonce) < 16 || len(nonce) > 64 {
+		return errInvalidSignature
+	}
+	date, err := time.Parse(time.RFC3339, req.Header.Get(kes.HeaderSignatureDate))
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	maxSkew := v.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = defaultSignatureSkew
+	}
+	now := time.Now()
+	if date.Before(now.Add(-maxSkew)) || date.After(now.Add(maxSkew)) {
+		return errSignatureExpired
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return errInvalidRequestBody
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if err = kes.VerifyRequest(req, body, req.TLS.PeerCertificates[0].PublicKey); err != nil {
+		return errInvalidSignature
+	}
+
+	maxNonces := v.MaxNonces
+	if maxNonces <= 0 {
+		maxNonces = defaultMaxNonces
+	}
+	identity := defaultIdentify(req.TLS.PeerCertificates[0])
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.nonces == nil {
+		v.nonces = map[kes.Identity]map[string]time.Time{}
+	}
+	if now.Sub(v.lastSweep) > maxSkew {
+		for id, nonces := range v.nonces {
+			sweepNonces(nonces, now)
+			if len(nonces) == 0 {
+				delete(v.nonces, id)
+			}
+		}
+		v.lastSweep = now
+	}
+
+	nonces, ok := v.nonces[identity]
+	if !ok {
+		nonces = map[string]time.Time{}
+		v.nonces[identity] = nonces
+	}
+	if _, ok = nonces[nonce]; ok {
+		return errRequestReplayed
+	}
+	if len(nonces) >= maxNonces {
+		// Forgetting a nonce that has not expired yet would
+		// allow replaying its request. Hence, the request is
+		// rejected unless some nonces have expired.
+		if sweepNonces(nonces, now); len(nonces) >= maxNonces {
+			return errTooManyNonces
+		}
+	}
+	nonces[nonce] = date.Add(maxSkew)
+	return nil
+}
+
+// sweepNonces removes all nonces that have expired at now.
+func sweepNonces(nonces map[string]time.Time, now time.Time) {
+	for nonce, expiry := range nonces {
+		if now.After(expiry) {
+			delete(nonces, nonce)
+		}
+	}
+}
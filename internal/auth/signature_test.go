@@ -0,0 +1,96 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	cert, key := newTestCertificate(t, 1, nil, nil)
+	newRequest := func(sign bool) *http.Request {
+		body := []byte(`{"plaintext":"..."}`)
+		req, err := http.NewRequest(http.MethodPost, "https://127.0.0.1:7373/v1/key/encrypt/my-key", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if sign {
+			if err = kes.SignRequest(req, body, key); err != nil {
+				t.Fatalf("Failed to sign request: %v", err)
+			}
+		}
+		return req
+	}
+
+	verifier := &SignatureVerifier{MaxSkew: time.Minute}
+	req := newRequest(true)
+	if err := verifier.Verify(req); err != nil {
+		t.Fatalf("Failed to verify signed request: %v", err)
+	}
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != `{"plaintext":"..."}` {
+		t.Fatalf("Request body has not been restored: got '%s'", body)
+	}
+
+	replayed := newRequest(false)
+	replayed.Header = req.Header.Clone()
+	if err := verifier.Verify(replayed); err != errRequestReplayed {
+		t.Fatalf("Replayed request: got %v - want %v", err, errRequestReplayed)
+	}
+	if err := verifier.Verify(newRequest(false)); err != errRequestNotSigned {
+		t.Fatalf("Unsigned request: got %v - want %v", err, errRequestNotSigned)
+	}
+
+	expired := newRequest(true)
+	expired.Header.Set(kes.HeaderSignatureDate, time.Now().Add(-2*time.Minute).UTC().Format(time.RFC3339))
+	if err := verifier.Verify(expired); err != errSignatureExpired {
+		t.Fatalf("Expired request: got %v - want %v", err, errSignatureExpired)
+	}
+
+	otherCert, _ := newTestCertificate(t, 2, nil, nil)
+	forged := newRequest(true)
+	forged.TLS.PeerCertificates = []*x509.Certificate{otherCert}
+	if err := verifier.Verify(forged); err != errInvalidSignature {
+		t.Fatalf("Forged request: got %v - want %v", err, errInvalidSignature)
+	}
+}
+
+func TestSignatureVerifierMaxNonces(t *testing.T) {
+	newRequest := func(cert *x509.Certificate, key crypto.Signer) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "https://127.0.0.1:7373/v1/key/list/*", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		if err = kes.SignRequest(req, nil, key); err != nil {
+			t.Fatalf("Failed to sign request: %v", err)
+		}
+		return req
+	}
+	cert, key := newTestCertificate(t, 1, nil, nil)
+	otherCert, otherKey := newTestCertificate(t, 2, nil, nil)
+
+	verifier := &SignatureVerifier{MaxSkew: time.Minute, MaxNonces: 2}
+	for i := 0; i < 2; i++ {
+		if err := verifier.Verify(newRequest(cert, key)); err != nil {
+			t.Fatalf("Failed to verify signed request %d: %v", i, err)
+		}
+	}
+	if err := verifier.Verify(newRequest(cert, key)); err != errTooManyNonces {
+		t.Fatalf("Too many requests: got %v - want %v", err, errTooManyNonces)
+	}
+	if err := verifier.Verify(newRequest(otherCert, otherKey)); err != nil {
+		t.Fatalf("Failed to verify signed request of another identity: %v", err)
+	}
+}
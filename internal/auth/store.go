@@ -0,0 +1,251 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// rolesState is the persisted representation
+// of the policies and identity assignments.
+type rolesState struct {
+	Policies   map[string]*kes.Policy     `json:"policies"`
+	Identities map[kes.Identity]string    `json:"identities"`
+	Expiry     map[kes.Identity]time.Time `json:"expiry,omitempty"`
+	Groups     map[string]*Group          `json:"groups,omitempty"`
+}
+
+// Save writes all policies and identity assignments to
+// the Remote store under secret.ReservedName. It does
+// nothing if no Remote store is set.
+//
+// Save replaces the entry via secret.Replace. Hence,
+// the previous policies are kept if Save fails.
+func (r *Roles) Save() error {
+	if r.Remote == nil {
+		return nil
+	}
+
+	// The save lock ensures that concurrent calls to Save
+	// write their snapshots in order. Otherwise, an older
+	// snapshot may overwrite a more recent one.
+	r.saveLock.Lock()
+	defer r.saveLock.Unlock()
+
+	value, err := r.Export()
+	if err != nil {
+		return err
+	}
+	return secret.Replace(r.Remote, secret.ReservedName, string(value))
+}
+
+// Load reads the policies and identity assignments from the
+// Remote store and adds them to the roles. A loaded policy
+// replaces an existing policy with the same name. Further,
+// a loaded identity assignment replaces an existing one.
+//
+// It does nothing if no Remote store is set or the Remote
+// store does not contain any policies.
+func (r *Roles) Load() error {
+	if r.Remote == nil {
+		return nil
+	}
+
+	state, err := r.loadState()
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.merge(state)
+}
+
+// Reload reads the policies and identity assignments from
+// the Remote store and replaces all existing policies and
+// identity assignments with them. The root identity is
+// never assigned to a policy.
+//
+// In contrast to Load, Reload also removes policies and
+// identity assignments that have been removed from the
+// Remote store - e.g. by another KES server of a cluster.
+// It does nothing if the Remote store does not contain any
+// policies.
+func (r *Roles) Reload() error {
+	if r.Remote == nil {
+		return nil
+	}
+
+	state, err := r.loadState()
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		roles          = make(map[string]*kes.Policy, len(state.Policies))
+		effectiveRoles = make(map[kes.Identity]string, len(state.Identities))
+		expiry         = make(map[kes.Identity]time.Time, len(state.Expiry))
+		groups         = make(map[string]*Group, len(state.Groups))
+	)
+	for name, policy := range state.Policies {
+		if policy != nil {
+			roles[name] = policy
+		}
+	}
+	for id, name := range state.Identities {
+		if id == r.Root || id.IsUnknown() {
+			continue
+		}
+		if _, ok := roles[name]; ok {
+			effectiveRoles[id] = name
+		}
+	}
+	for id, t := range state.Expiry {
+		if _, ok := effectiveRoles[id]; ok {
+			expiry[id] = t
+		}
+	}
+
+	for name, group := range state.Groups {
+		if group != nil {
+			groups[name] = validGroup(group, roles, r.Root)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.roles, r.effectiveRoles, r.expiry, r.groups = roles, effectiveRoles, expiry, groups
+	return nil
+}
+
+// loadState reads the persisted policies and identity
+// assignments from the Remote store. It returns
+// kes.ErrKeyNotFound if the Remote store does not contain
+// any policies.
+func (r *Roles) loadState() (rolesState, error) {
+	value, err := secret.Load(r.Remote, secret.ReservedName)
+	if err != nil {
+		return rolesState{}, err
+	}
+	var state rolesState
+	if err = json.Unmarshal([]byte(value), &state); err != nil {
+		return rolesState{}, errors.New("auth: persisted policies are malformed")
+	}
+	return state, nil
+}
+
+// Export returns the JSON representation of all policies
+// and identity assignments.
+func (r *Roles) Export() ([]byte, error) {
+	r.lock.RLock()
+	state := rolesState{
+		Policies:   make(map[string]*kes.Policy, len(r.roles)),
+		Identities: make(map[kes.Identity]string, len(r.effectiveRoles)),
+		Expiry:     make(map[kes.Identity]time.Time, len(r.expiry)),
+		Groups:     make(map[string]*Group, len(r.groups)),
+	}
+	for name, policy := range r.roles {
+		state.Policies[name] = policy
+	}
+	for id, name := range r.effectiveRoles {
+		state.Identities[id] = name
+	}
+	for id, expiry := range r.expiry {
+		state.Expiry[id] = expiry
+	}
+	for name, group := range r.groups {
+		state.Groups[name] = &Group{
+			Policies: append([]string{}, group.Policies...),
+			Members:  append([]kes.Identity{}, group.Members...),
+		}
+	}
+	r.lock.RUnlock()
+
+	return json.Marshal(state)
+}
+
+// Import adds the policies and identity assignments of
+// the JSON representation returned by Export to the roles.
+// Like Load, an imported policy or identity assignment
+// replaces an existing one.
+//
+// Import does not persist the imported policies and
+// identity assignments. See: Save
+func (r *Roles) Import(data []byte) error {
+	var state rolesState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return errors.New("auth: policies are malformed")
+	}
+	return r.merge(state)
+}
+
+// merge adds the policies and identity assignments
+// of the state to the roles.
+func (r *Roles) merge(state rolesState) error {
+	for name, policy := range state.Policies {
+		if policy == nil {
+			continue
+		}
+		r.Set(name, policy)
+	}
+	for id, name := range state.Identities {
+		if id == r.Root || id.IsUnknown() {
+			continue
+		}
+		if err := r.Assign(name, id); err != nil && err != kes.ErrPolicyNotFound {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for id, expiry := range state.Expiry {
+		if _, ok := r.effectiveRoles[id]; !ok {
+			continue
+		}
+		if r.expiry == nil {
+			r.expiry = map[kes.Identity]time.Time{}
+		}
+		r.expiry[id] = expiry
+	}
+	for name, group := range state.Groups {
+		if group == nil {
+			continue
+		}
+		if r.groups == nil {
+			r.groups = map[string]*Group{}
+		}
+		r.groups[name] = validGroup(group, r.roles, r.Root)
+	}
+	return nil
+}
+
+// validGroup returns a copy of the group without policies
+// that don't exist and without the root identity.
+func validGroup(group *Group, policies map[string]*kes.Policy, root kes.Identity) *Group {
+	valid := &Group{
+		Policies: []string{},
+		Members:  []kes.Identity{},
+	}
+	for _, name := range group.Policies {
+		if _, ok := policies[name]; ok {
+			valid.Policies = append(valid.Policies, name)
+		}
+	}
+	for _, id := range group.Members {
+		if id != root && !id.IsUnknown() {
+			valid.Members = append(valid.Members, id)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+)
+
+func TestRolesSaveLoad(t *testing.T) {
+	remote := &mem.Store{}
+	roles := &Roles{Root: "root", Remote: remote}
+
+	policy, err := kes.NewPolicy("/v1/key/create/*")
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	roles.Set("my-app", policy)
+	if err = roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err = roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+	roles.Forget("af43c")
+	if err = roles.Save(); err != nil { // Saving twice must replace the persisted roles
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+	if err = roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err = roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+
+	loaded := &Roles{Root: "root", Remote: remote}
+	if err = loaded.Load(); err != nil {
+		t.Fatalf("Failed to load roles: %v", err)
+	}
+	if _, ok := loaded.Get("my-app"); !ok {
+		t.Fatal("Policy 'my-app' has not been loaded")
+	}
+	if identities := loaded.Identities(); identities["af43c"] != "my-app" {
+		t.Fatalf("Identity has not been loaded: got %v", identities)
+	}
+
+	empty := &Roles{Root: "root", Remote: &mem.Store{}}
+	if err = empty.Load(); err != nil {
+		t.Fatalf("Failed to load roles from an empty store: %v", err)
+	}
+}
+
+func TestRolesReload(t *testing.T) {
+	remote := &mem.Store{}
+	roles := &Roles{Root: "root", Remote: remote}
+
+	policy, err := kes.NewPolicy("/v1/key/create/*")
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	roles.Set("my-app", policy)
+	if err = roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err = roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+
+	replica := &Roles{Root: "root", Remote: remote}
+	if err = replica.Reload(); err != nil {
+		t.Fatalf("Failed to reload roles: %v", err)
+	}
+	if identities := replica.Identities(); identities["af43c"] != "my-app" {
+		t.Fatalf("Identity has not been loaded: got %v", identities)
+	}
+
+	roles.Delete("my-app")
+	if err = roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+	if err = replica.Reload(); err != nil {
+		t.Fatalf("Failed to reload roles: %v", err)
+	}
+	if _, ok := replica.Get("my-app"); ok {
+		t.Fatal("Deleted policy 'my-app' is still present after reload")
+	}
+	if identities := replica.Identities(); len(identities) != 0 {
+		t.Fatalf("Identities of deleted policy are still present after reload: got %v", identities)
+	}
+}
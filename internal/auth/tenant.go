@@ -0,0 +1,179 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/minio/kes"
+)
+
+// Tenants binds identities to tenants. Each tenant has an
+// isolated key namespace.
+//
+// The keys of a tenant are stored under the name:
+//  <tenant>/<key-name>
+// The key name of a tenant identity is always prefixed with
+// its tenant. Hence, an identity cannot access the keys of
+// another tenant - even if the key name contains a '/'.
+// Further, identities that are not bound to a tenant cannot
+// access the keys of any tenant. They cannot use a key name
+// that starts with the name of a tenant - e.g.
+// "<tenant>/<key-name>".
+//
+// The policy of a tenant identity is verified against the
+// key names as seen by the tenant - i.e. without the tenant
+// prefix. However, tenant identities cannot change policies,
+// identity assignments or key quotas, and cannot trace the
+// server logs.
+type Tenants struct {
+	lock    sync.RWMutex
+	tenants map[string]bool
+	members map[kes.Identity]string
+}
+
+var validTenantName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Add adds a tenant and binds the identities to it.
+//
+// Add returns an error if the tenant name is invalid or if an
+// identity is already bound to another tenant.
+func (t *Tenants) Add(name string, identities ...kes.Identity) error {
+	if !validTenantName.MatchString(name) {
+		return errors.New("auth: invalid tenant name '" + name + "'")
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.tenants == nil {
+		t.tenants = map[string]bool{}
+		t.members = map[kes.Identity]string{}
+	}
+	for _, id := range identities {
+		if tenant, ok := t.members[id]; ok && tenant != name {
+			return errors.New("auth: identity '" + id.String() + "' is already bound to tenant '" + tenant + "'")
+		}
+	}
+	t.tenants[name] = true
+	for _, id := range identities {
+		if !id.IsUnknown() {
+			t.members[id] = name
+		}
+	}
+	return nil
+}
+
+// Lookup returns the tenant the identity is bound to,
+// if any.
+func (t *Tenants) Lookup(id kes.Identity) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	tenant, ok := t.members[id]
+	return tenant, ok
+}
+
+// Exists reports whether a tenant with the
+// given name exists.
+func (t *Tenants) Exists(name string) bool {
+	if t == nil {
+		return false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.tenants[name]
+}
+
+// KeyName returns the name of the tenant's key as
+// stored at the key store.
+func (t *Tenants) KeyName(tenant, name string) string {
+	return tenant + "/" + name
+}
+
+// isTenantKey reports whether the key or data API path
+// refers to a key within the namespace of a tenant.
+func (t *Tenants) isTenantKey(apiPath string) bool {
+	name, ok := APIKeyName(apiPath)
+	if !ok {
+		return false
+	}
+	tenant, ok := tenantOfKey(name)
+	return ok && t.Exists(tenant)
+}
+
+// APIKeyName returns the key name of a key or data API
+// path - e.g. "my-app/my-key" for /v1/key/create/my-app/my-key
+// or /v1/key/bulk/generate/my-app/my-key. For alias API paths,
+// like /v1/key/alias/set/my-alias, it returns the alias. The
+// legal hold API paths, like /v1/key/hold/set/my-key, are key
+// API paths as well. For secret API paths, like
+// /v1/secret/get/my-app/my-token, it returns the secret name
+// and for CA API paths, like /v1/ca/sign/my-app/ca, the CA name.
+// JWS API paths, like /v1/jws/sign/my-app/my-key, are key API
+// paths as well. It returns false if the path is not a key, data,
+// secret, CA or JWS API path.
+func APIKeyName(apiPath string) (string, bool) {
+	var n int // The number of segments before the key name
+	switch {
+	case strings.HasPrefix(apiPath, "/v1/key/bulk/"), strings.HasPrefix(apiPath, "/v1/key/alias/"), strings.HasPrefix(apiPath, "/v1/key/hold/"):
+		n = 5
+	case strings.HasPrefix(apiPath, "/v1/key/"), strings.HasPrefix(apiPath, "/v1/data/"), strings.HasPrefix(apiPath, "/v1/secret/"), strings.HasPrefix(apiPath, "/v1/ca/"), strings.HasPrefix(apiPath, "/v1/jws/"):
+		n = 4
+	default:
+		return "", false
+	}
+	segments := strings.SplitN(apiPath, "/", n+1)
+	if len(segments) <= n {
+		return "", false
+	}
+	return segments[n], true
+}
+
+// tenantOfKey returns the tenant of the key with the
+// given name, if the key is within a tenant namespace.
+func tenantOfKey(name string) (string, bool) {
+	if i := strings.IndexByte(name, '/'); i > 0 {
+		return name[:i], true
+	}
+	return "", false
+}
+
+// isTenantRestricted reports whether a tenant identity must
+// not access the API - i.e. because the API would allow the
+// identity to act outside of its tenant.
+func isTenantRestricted(apiPath string) bool {
+	for _, api := range []string{
+		"/v1/policy/write/",
+		"/v1/policy/delete/",
+		"/v1/policy/test",
+		"/v1/identity/assign/",
+		"/v1/identity/forget/",
+		"/v1/identity/renew/",
+		"/v1/group/",
+		"/v1/approval/",
+		"/v1/session/",
+		"/v1/log/audit/trace",
+		"/v1/log/error/trace",
+		"/v1/metrics",
+		"/v1/quota/",
+		"/v1/cluster/",
+		"/v1/admin/",
+		"/v1/enclave/",
+		"/v1/job/",
+		"/v1/status",
+	} {
+		if strings.HasPrefix(apiPath, api) {
+			return true
+		}
+	}
+	return false
+}
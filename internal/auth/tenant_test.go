@@ -0,0 +1,125 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+var tenantsAddTests = []struct {
+	Name       string
+	Identities []kes.Identity
+	Err        bool
+}{
+	{Name: "tenant-a", Identities: []kes.Identity{"af43c", "b2ce1"}},             // 0
+	{Name: "tenant-b", Identities: []kes.Identity{"c4d3e"}},                      // 1
+	{Name: "tenant-c", Identities: []kes.Identity{"af43c"}, Err: true},           // 2
+	{Name: "tenant-a", Identities: []kes.Identity{"af43c", "d7e8f"}},             // 3
+	{Name: "tenant/d", Identities: []kes.Identity{"e1f2a"}, Err: true},           // 4
+	{Name: "", Identities: []kes.Identity{"e1f2a"}, Err: true},                   // 5
+	{Name: ".kes-tenant", Identities: []kes.Identity{"e1f2a"}, Err: true},        // 6
+	{Name: "tenant-e", Identities: []kes.Identity{kes.IdentityUnknown, "f3a4b"}}, // 7
+}
+
+func TestTenantsAdd(t *testing.T) {
+	var tenants Tenants
+	for i, test := range tenantsAddTests {
+		err := tenants.Add(test.Name, test.Identities...)
+		if err == nil && test.Err {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.Err {
+			t.Fatalf("Test %d: failed to add tenant: %v", i, err)
+		}
+		if err != nil {
+			continue
+		}
+		for _, id := range test.Identities {
+			tenant, ok := tenants.Lookup(id)
+			if id.IsUnknown() {
+				if ok {
+					t.Fatalf("Test %d: unknown identity is bound to tenant '%s'", i, tenant)
+				}
+				continue
+			}
+			if !ok || tenant != test.Name {
+				t.Fatalf("Test %d: identity '%s' is bound to '%s' - want '%s'", i, id, tenant, test.Name)
+			}
+		}
+	}
+	if tenant, ok := tenants.Lookup("c0ffee"); ok {
+		t.Fatalf("Identity without tenant is bound to tenant '%s'", tenant)
+	}
+}
+
+var tenantOfKeyTests = []struct {
+	Name     string
+	Tenant   string
+	IsTenant bool
+}{
+	{Name: "my-key", Tenant: "", IsTenant: false},                 // 0
+	{Name: "tenant-a/my-key", Tenant: "tenant-a", IsTenant: true}, // 1
+	{Name: "/my-key", Tenant: "", IsTenant: false},                // 2
+}
+
+func TestTenantOfKey(t *testing.T) {
+	for i, test := range tenantOfKeyTests {
+		tenant, ok := tenantOfKey(test.Name)
+		if ok != test.IsTenant || tenant != test.Tenant {
+			t.Fatalf("Test %d: got tenant '%s' (%v) - want '%s' (%v)", i, tenant, ok, test.Tenant, test.IsTenant)
+		}
+	}
+}
+
+var apiKeyNameTests = []struct {
+	Path  string
+	Name  string
+	IsKey bool
+}{
+	{Path: "/v1/key/create/my-key", Name: "my-key", IsKey: true},                      // 0
+	{Path: "/v1/key/create/my-app/my-key", Name: "my-app/my-key", IsKey: true},        // 1
+	{Path: "/v1/key/bulk/generate/my-app/my-key", Name: "my-app/my-key", IsKey: true}, // 2
+	{Path: "/v1/data/encrypt/my-app/my-key", Name: "my-app/my-key", IsKey: true},      // 3
+	{Path: "/v1/key/create/", Name: "", IsKey: true},                                  // 4
+	{Path: "/v1/key/create", Name: "", IsKey: false},                                  // 5
+	{Path: "/v1/policy/read/my-policy", Name: "", IsKey: false},                       // 6
+	{Path: "/v1/key/alias/set/my-app/my-alias", Name: "my-app/my-alias", IsKey: true}, // 7
+	{Path: "/v1/key/hold/release/my-app/my-key", Name: "my-app/my-key", IsKey: true},  // 8
+	{Path: "/v1/secret/get/my-app/my-token", Name: "my-app/my-token", IsKey: true},    // 9
+	{Path: "/v1/ca/sign/my-app/my-ca", Name: "my-app/my-ca", IsKey: true},             // 10
+	{Path: "/v1/jws/jwks/my-app/my-key", Name: "my-app/my-key", IsKey: true},          // 11
+}
+
+func TestAPIKeyName(t *testing.T) {
+	for i, test := range apiKeyNameTests {
+		name, ok := APIKeyName(test.Path)
+		if ok != test.IsKey || name != test.Name {
+			t.Fatalf("Test %d: got '%s' (%v) - want '%s' (%v)", i, name, ok, test.Name, test.IsKey)
+		}
+	}
+}
+
+func TestTenantsIsTenantKey(t *testing.T) {
+	var tenants Tenants
+	if err := tenants.Add("tenant-a", "af43c"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	for i, test := range []struct {
+		Path     string
+		IsTenant bool
+	}{
+		{Path: "/v1/key/create/my-key", IsTenant: false},               // 0
+		{Path: "/v1/key/create/tenant-a/my-key", IsTenant: true},       // 1
+		{Path: "/v1/key/create/tenant-b/my-key", IsTenant: false},      // 2
+		{Path: "/v1/data/decrypt/tenant-a/app/my-key", IsTenant: true}, // 3
+		{Path: "/v1/policy/read/tenant-a/my-policy", IsTenant: false},  // 4
+	} {
+		if isTenant := tenants.isTenantKey(test.Path); isTenant != test.IsTenant {
+			t.Fatalf("Test %d: got %v - want %v", i, isTenant, test.IsTenant)
+		}
+	}
+}
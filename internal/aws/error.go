@@ -0,0 +1,58 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/minio/kes"
+)
+
+// isThrottled returns true if err indicates that
+// AWS has throttled the request.
+func isThrottled(err error) bool {
+	if err, ok := err.(awserr.Error); ok {
+		switch err.Code() {
+		case "ThrottlingException", "Throttling", "TooManyRequestsException", "RequestLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
+
+// isUnreachable returns true if err indicates that
+// the request could not be sent to AWS - e.g. because
+// of a network error.
+func isUnreachable(err error) bool {
+	if err, ok := err.(awserr.Error); ok {
+		return err.Code() == "RequestError"
+	}
+	return false
+}
+
+// keyStoreError returns the kind of API error of a
+// failed key store request.
+func keyStoreError(err error) kes.Error {
+	switch {
+	case isThrottled(err):
+		return kes.ErrBackendThrottled
+	case isUnreachable(err):
+		return kes.ErrBackendUnavailable
+	default:
+		return kes.ErrBackendFailure
+	}
+}
+
+// kmsError returns the kind of API error of a
+// failed KMS request.
+func kmsError(err error) kes.Error {
+	switch {
+	case isThrottled(err):
+		return kes.ErrKMSThrottled
+	case isUnreachable(err):
+		return kes.ErrKMSUnavailable
+	default:
+		return kes.ErrKMSFailure
+	}
+}
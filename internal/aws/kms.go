@@ -0,0 +1,370 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/rate"
+	"github.com/minio/kes/internal/secret"
+)
+
+// RateLimit is a client-side request rate limit.
+//
+// Requests exceeding the rate limit are not rejected
+// but delayed until they can be sent without exceeding
+// the limit.
+type RateLimit struct {
+	// Rate is the max. number of requests per second.
+	// If Rate <= 0 the request rate is not limited.
+	Rate float64
+
+	// Burst is the max. number of requests that can
+	// be sent at once. If Burst < 1 a burst of 1 is
+	// used.
+	Burst int
+}
+
+// Retry specifies how requests that got rejected
+// because of throttling or a transient error get
+// retried.
+//
+// Retry waits Delay before the first retry and doubles
+// the delay on every subsequent retry. In addition, it
+// adds a pseudo-random duration [0, Jitter) to each
+// delay such that concurrent requests don't get retried
+// at the same time.
+type Retry struct {
+	// N is the max. number of retries. If 0, requests
+	// are retried 3 times.
+	N uint
+
+	// Delay is the duration to wait before the first
+	// retry. If 0, it defaults to 100ms.
+	Delay time.Duration
+
+	// Jitter is the max. pseudo-random duration that
+	// is added to the delay. If 0, it defaults to 200ms.
+	Jitter time.Duration
+}
+
+// KMS is an AWS-KMS client that encrypts and decrypts
+// secrets with an AWS-KMS customer master key (CMK).
+// See: https://aws.amazon.com/kms
+type KMS struct {
+	// Addr is the HTTP address of AWS-KMS. In general,
+	// the address has the following form:
+	//  kms.<region>.amazonaws.com
+	Addr string
+
+	// Region is the AWS region. Even though the Addr
+	// endpoint contains that information already, this
+	// field is mandatory.
+	Region string
+
+	// KeyID is the ID, ARN or alias of the AWS-KMS
+	// customer master key (CMK) used to encrypt and
+	// decrypt secrets.
+	KeyID string
+
+	// Login contains the AWS credentials (access/secret key).
+	Login Credentials
+
+	// GrantTokens are optional AWS-KMS grant tokens that
+	// are sent on every Encrypt and Decrypt request.
+	//
+	// A grant token allows using the CMK through a grant
+	// that has just been created but may not be effective,
+	// yet. It is required when the AWS principal only has
+	// access to the CMK via a freshly issued grant.
+	// See: https://docs.aws.amazon.com/kms/latest/developerguide/grants.html
+	//
+	// To replace the grant tokens once the KMS has been
+	// used, call SetGrantTokens.
+	GrantTokens []string
+
+	// Limit is the client-side request rate limit.
+	//
+	// AWS-KMS enforces a per-account request quota and
+	// throttles requests exceeding it. Limiting the rate
+	// on the client-side prevents request bursts from
+	// exhausting the quota of the entire account.
+	Limit RateLimit
+
+	// Retry controls how requests throttled by AWS-KMS
+	// are retried.
+	Retry Retry
+
+	// Transport controls how HTTP connections to
+	// AWS-KMS are established and reused.
+	Transport Transport
+
+	// Log specifies an optional logger for errors
+	// when secrets cannot be encrypted or decrypted.
+	// If nil, logging is done via the log package's
+	// standard logger.
+	Log *xlog.Logger
+
+	client  *kms.KMS
+	limiter *rate.Limiter
+	tracer  *connTracer
+
+	lock sync.RWMutex // Protects GrantTokens
+}
+
+var _ secret.KMS = (*KMS)(nil)
+
+// Authenticate tries to establish a connection to
+// AWS-KMS using the login credentials.
+func (k *KMS) Authenticate() error {
+	credentials := credentials.NewStaticCredentials(
+		k.Login.AccessKey,
+		k.Login.SecretKey,
+		k.Login.SessionToken,
+	)
+	if k.Login.AccessKey == "" && k.Login.SecretKey == "" && k.Login.SessionToken == "" {
+		// If all login credentials are empty we pass no credentials to
+		// the AWS SDK such that it tries to fetch them from the env.,
+		// the shared credentials file or the EC2 instance metadata.
+		// See: SecretsManager.Authenticate
+		credentials = nil
+	}
+
+	tracer := newConnTracer(k.Transport)
+	session, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Endpoint:    aws.String(k.Addr),
+			Region:      aws.String(k.Region),
+			Credentials: credentials,
+			HTTPClient:  &http.Client{Transport: tracer},
+
+			// We disable the SDK retry mechanism since
+			// the client retries throttled requests itself.
+			// Otherwise, a throttled request would be retried
+			// by the SDK and by the client.
+			MaxRetries: aws.Int(0),
+		},
+		SharedConfigState: session.SharedConfigDisable,
+	})
+	if err != nil {
+		return err
+	}
+	k.client = kms.New(session)
+	k.tracer = tracer
+	k.limiter = &rate.Limiter{
+		Rate:  k.Limit.Rate,
+		Burst: k.Limit.Burst,
+	}
+	return nil
+}
+
+// Status returns an error if AWS-KMS is not reachable
+// or the customer master key (CMK) is not enabled.
+func (k *KMS) Status() error {
+	if k.client == nil {
+		return errNoKMSConnection
+	}
+
+	var response *kms.DescribeKeyOutput
+	err := k.do(func() (err error) {
+		response, err = k.client.DescribeKey(&kms.DescribeKeyInput{
+			KeyId:       aws.String(k.KeyID),
+			GrantTokens: k.grantTokens(),
+		})
+		return err
+	})
+	if err != nil {
+		return errs.Errorf(kmsError(err), "aws: failed to describe '%s': %w", k.KeyID, err)
+	}
+	if response.KeyMetadata != nil && response.KeyMetadata.Enabled != nil && !*response.KeyMetadata.Enabled {
+		return errs.Errorf(kes.ErrKMSUnavailable, "aws: key '%s' is not enabled", k.KeyID)
+	}
+	return nil
+}
+
+// Close closes all idle connections to AWS-KMS.
+func (k *KMS) Close() error {
+	if k.tracer != nil {
+		if t, ok := k.tracer.RoundTripper.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the AWS-KMS
+// CMK and binds the context to the ciphertext.
+func (k *KMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	if k.client == nil {
+		k.Log.Error("not connected", "err", errNoKMSConnection)
+		return nil, errNoKMSConnection
+	}
+
+	var (
+		grantTokens = k.grantTokens()
+		response    *kms.EncryptOutput
+	)
+	err := k.do(func() (err error) {
+		response, err = k.client.Encrypt(&kms.EncryptInput{
+			KeyId:     aws.String(k.KeyID),
+			Plaintext: plaintext,
+			EncryptionContext: map[string]*string{
+				"context": aws.String(context),
+			},
+			GrantTokens: grantTokens,
+		})
+		return err
+	})
+	if err != nil {
+		k.Log.Error("failed to encrypt", "context", context, "err", err)
+		err = errs.Errorf(kmsError(err), "aws: failed to encrypt '%s': %w", context, err)
+		return nil, err
+	}
+	return response.CiphertextBlob, nil
+}
+
+// Decrypt decrypts the ciphertext with the AWS-KMS
+// CMK. The context must match the context used when
+// the ciphertext has been produced.
+//
+// The returned plaintext is the plaintext of the AWS-KMS
+// response. The caller should wipe it once it is no
+// longer needed. See: secret.Wipe
+func (k *KMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	if k.client == nil {
+		k.Log.Error("not connected", "err", errNoKMSConnection)
+		return nil, errNoKMSConnection
+	}
+
+	var (
+		grantTokens = k.grantTokens()
+		response    *kms.DecryptOutput
+	)
+	err := k.do(func() (err error) {
+		response, err = k.client.Decrypt(&kms.DecryptInput{
+			CiphertextBlob: ciphertext,
+			EncryptionContext: map[string]*string{
+				"context": aws.String(context),
+			},
+			GrantTokens: grantTokens,
+		})
+		return err
+	})
+	if err != nil {
+		k.Log.Error("failed to decrypt", "context", context, "err", err)
+		err = errs.Errorf(kmsError(err), "aws: failed to decrypt '%s': %w", context, err)
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
+
+// ConnStats returns statistics about the HTTP
+// connections to AWS-KMS. It returns zero stats
+// if the KMS hasn't been authenticated.
+func (k *KMS) ConnStats() ConnStats {
+	if k.tracer == nil {
+		return ConnStats{}
+	}
+	return k.tracer.Stats()
+}
+
+// SetGrantTokens replaces the grant tokens sent on
+// every Encrypt and Decrypt request. It is safe to
+// call SetGrantTokens concurrently.
+func (k *KMS) SetGrantTokens(tokens ...string) {
+	grantTokens := make([]string, len(tokens))
+	copy(grantTokens, tokens)
+
+	k.lock.Lock()
+	k.GrantTokens = grantTokens
+	k.lock.Unlock()
+}
+
+// grantTokens returns the current grant tokens
+// as AWS SDK parameter. It returns nil if there
+// are no grant tokens.
+func (k *KMS) grantTokens() []*string {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	if len(k.GrantTokens) == 0 {
+		return nil
+	}
+	return aws.StringSlice(k.GrantTokens)
+}
+
+// do calls f once the client-side rate limit permits
+// it and retries f as long as it fails because of
+// throttling or a transient error - but at most
+// Retry.N times.
+func (k *KMS) do(f func() error) error {
+	var (
+		N      = k.Retry.N
+		Delay  = k.Retry.Delay
+		Jitter = k.Retry.Jitter
+	)
+	if N == 0 {
+		N = 3 // default to 3 re-tries
+	}
+	if Delay == 0 {
+		Delay = 100 * time.Millisecond // default to waiting at least 100ms
+	}
+	if Jitter == 0 {
+		Jitter = 200 * time.Millisecond // default to waiting at most Delay + 200ms
+	}
+	const MaxDelay = 10 * time.Second
+
+	for i := uint(0); ; i++ {
+		if err := k.limiter.Wait(context.Background()); err != nil {
+			return err
+		}
+		err := f()
+		if err == nil || i >= N || !isRetryable(err) {
+			return err
+		}
+
+		delay := Delay << i
+		if delay <= 0 || delay > MaxDelay { // Check for overflow
+			delay = MaxDelay
+		}
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(Jitter))))
+	}
+}
+
+// isRetryable returns true if err indicates that
+// AWS-KMS has throttled the request or failed
+// because of a transient error.
+func isRetryable(err error) bool {
+	if isThrottled(err) || isUnreachable(err) {
+		return true
+	}
+	if err, ok := err.(awserr.Error); ok {
+		switch err.Code() {
+		case kms.ErrCodeInternalException, kms.ErrCodeDependencyTimeoutException:
+			return true
+		}
+	}
+	return false
+}
+
+// errNoKMSConnection is the error returned and logged by
+// the KMS if the AWS-KMS client hasn't been initialized.
+//
+// This error is returned by Encrypt and Decrypt in case
+// of an invalid configuration - i.e. when Authenticate()
+// hasn't been called.
+var errNoKMSConnection = errs.New(kes.ErrKMSUnavailable, "aws: no connection to AWS-KMS")
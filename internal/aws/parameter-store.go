@@ -0,0 +1,261 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/secret"
+)
+
+// ParameterStore is a key-value store that saves/fetches
+// values as SecureString parameters on/from the AWS
+// Systems Manager Parameter Store.
+// See: https://docs.aws.amazon.com/systems-manager/latest/userguide/systems-manager-parameter-store.html
+type ParameterStore struct {
+	// Addr is the HTTP address of the AWS Systems
+	// Manager. In general, the address has the
+	// following form:
+	//  ssm.<region>.amazonaws.com
+	Addr string
+
+	// Region is the AWS region. Even though the Addr
+	// endpoint contains that information already, this
+	// field is mandatory.
+	Region string
+
+	// Prefix is the parameter hierarchy under which
+	// all values are stored - e.g. /kes/. If empty,
+	// values are stored at the top level.
+	Prefix string
+
+	// The KMSKeyID is the AWS-KMS key ID specifying the
+	// AWS-KMS key that is used to encrypt (and decrypt) the
+	// values stored at the AWS Parameter Store.
+	KMSKeyID string
+
+	// Login contains the AWS credentials (access/secret key).
+	Login Credentials
+
+	// Log specifies an optional logger for errors
+	// when parameters cannot be created, fetched or
+	// deleted.
+	// If nil, logging is done via the log package's
+	// standard logger.
+	Log *xlog.Logger
+
+	client *ssm.SSM
+}
+
+var (
+	_ secret.Remote       = (*ParameterStore)(nil)
+	_ secret.PrefixLister = (*ParameterStore)(nil)
+)
+
+// Create stores the given key-value pair at the AWS Parameter Store
+// if and only if it doesn't exists. If such an entry already exists
+// it returns kes.ErrKeyExists.
+//
+// If the ParameterStore.KMSKeyID is set AWS will use this key ID to
+// encrypt the values. Otherwise, AWS will use the default AWS-KMS key
+// of the account for encrypting SecureString parameters.
+func (p *ParameterStore) Create(key, value string) error {
+	if p.client == nil {
+		p.Log.Error("not connected", "err", errNoParameterStoreConnection)
+		return errNoParameterStoreConnection
+	}
+
+	putOpt := ssm.PutParameterInput{
+		Name:      aws.String(p.name(key)),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Overwrite: aws.Bool(false),
+	}
+	if p.KMSKeyID != "" {
+		putOpt.KeyId = aws.String(p.KMSKeyID)
+	}
+	if _, err := p.client.PutParameter(&putOpt); err != nil {
+		if err, ok := err.(awserr.Error); ok {
+			switch err.Code() {
+			case ssm.ErrCodeParameterAlreadyExists:
+				return kes.ErrKeyExists
+			}
+		}
+		p.Log.Error("failed to create entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to create '%s': %w", key, err)
+		return err
+	}
+	return nil
+}
+
+// Get returns the value associated with the given key.
+// If no entry for key exists, it returns kes.ErrKeyNotFound.
+func (p *ParameterStore) Get(key string) (string, error) {
+	if p.client == nil {
+		p.Log.Error("not connected", "err", errNoParameterStoreConnection)
+		return "", errNoParameterStoreConnection
+	}
+
+	response, err := p.client.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(p.name(key)),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		if err, ok := err.(awserr.Error); ok {
+			switch err.Code() {
+			case ssm.ErrCodeParameterNotFound:
+				return "", kes.ErrKeyNotFound
+			case ssm.ErrCodeInvalidKeyId:
+				return "", errs.Errorf(kes.ErrBackendFailure, "aws: cannot access '%s': %w", key, err)
+			}
+		}
+		p.Log.Error("failed to read entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to read '%s': %w", key, err)
+		return "", err
+	}
+	if response.Parameter == nil || response.Parameter.Value == nil {
+		p.Log.Error("failed to read entry", "key", key, "err", "parameter has no value")
+		err = errs.Errorf(kes.ErrCorrupted, "aws: failed to read '%s': parameter has no value", key)
+		return "", err
+	}
+	return *response.Parameter.Value, nil
+}
+
+// Delete removes the key-value pair from the AWS Parameter Store, if
+// it exists.
+func (p *ParameterStore) Delete(key string) error {
+	if p.client == nil {
+		p.Log.Error("not connected", "err", errNoParameterStoreConnection)
+		return errNoParameterStoreConnection
+	}
+
+	_, err := p.client.DeleteParameter(&ssm.DeleteParameterInput{
+		Name: aws.String(p.name(key)),
+	})
+	if err != nil {
+		if err, ok := err.(awserr.Error); ok {
+			if err.Code() == ssm.ErrCodeParameterNotFound {
+				return nil
+			}
+		}
+		p.Log.Error("failed to delete entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to delete '%s': %w", key, err)
+		return err
+	}
+	return nil
+}
+
+// List returns the names of all parameters within the
+// Prefix hierarchy.
+func (p *ParameterStore) List() ([]string, error) { return p.ListPrefix("") }
+
+// ListPrefix returns the names of all parameters within
+// the Prefix hierarchy that start with the prefix - e.g.
+// "my-tenant/". Hierarchical key names are stored as
+// parameter paths. Therefore, ListPrefix only lists the
+// path of the prefix and its sub-paths.
+//
+// AWS can only list parameters by path. Hence, ListPrefix
+// returns secret.ErrListNotSupported if the ParameterStore
+// has no Prefix.
+func (p *ParameterStore) ListPrefix(prefix string) ([]string, error) {
+	if p.client == nil {
+		p.Log.Error("not connected", "err", errNoParameterStoreConnection)
+		return nil, errNoParameterStoreConnection
+	}
+	if p.Prefix == "" {
+		return nil, secret.ErrListNotSupported
+	}
+
+	var (
+		root  = p.name("")
+		path  = strings.TrimSuffix(p.name(prefix), "/") // AWS rejects paths ending with a '/'
+		names []string
+	)
+	input := &ssm.GetParametersByPathInput{
+		Path:      aws.String(path),
+		Recursive: aws.Bool(true),
+	}
+	err := p.client.GetParametersByPathPages(input, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, parameter := range page.Parameters {
+			if parameter.Name != nil && strings.HasPrefix(*parameter.Name, root) {
+				names = append(names, strings.TrimPrefix(*parameter.Name, root))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		p.Log.Error("failed to list entries", "path", path, "err", err)
+		return nil, errs.Errorf(keyStoreError(err), "aws: failed to list '%s': %w", path, err)
+	}
+	return names, nil
+}
+
+// Authenticate tries to establish a connection to
+// the AWS Parameter Store using the login credentials.
+func (p *ParameterStore) Authenticate() error {
+	credentials := credentials.NewStaticCredentials(
+		p.Login.AccessKey,
+		p.Login.SecretKey,
+		p.Login.SessionToken,
+	)
+	if p.Login.AccessKey == "" && p.Login.SecretKey == "" && p.Login.SessionToken == "" {
+		// If all login credentials are empty we pass no credentials to
+		// the AWS SDK such that it tries to fetch them from the env.,
+		// the shared credentials file or the EC2 instance metadata.
+		// See: SecretsManager.Authenticate
+		credentials = nil
+	}
+
+	session, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Endpoint:    aws.String(p.Addr),
+			Region:      aws.String(p.Region),
+			Credentials: credentials,
+		},
+		SharedConfigState: session.SharedConfigDisable,
+	})
+	if err != nil {
+		return err
+	}
+	p.client = ssm.New(session)
+	return nil
+}
+
+// name returns the parameter name of the given
+// key - i.e. the key within the Prefix hierarchy.
+func (p *ParameterStore) name(key string) string {
+	if p.Prefix == "" {
+		return key
+	}
+
+	// AWS demands that hierarchical parameter
+	// names start with a '/'.
+	prefix := p.Prefix
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + key
+}
+
+// errNoParameterStoreConnection is the error returned and
+// logged by the key store if the AWS Parameter Store client
+// hasn't been initialized.
+//
+// This error is returned by Create, Get, Delete, a.s.o.
+// in case of an invalid configuration - i.e. when Authenticate()
+// hasn't been called.
+var errNoParameterStoreConnection = errs.New(kes.ErrBackendUnavailable, "aws: no connection to AWS parameter store")
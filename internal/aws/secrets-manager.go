@@ -5,10 +5,9 @@
 package aws
 
 import (
-	"errors"
-	"fmt"
-	"log"
-	"net/http"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -16,6 +15,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	xlog "github.com/minio/kes/internal/log"
 	"github.com/minio/kes/internal/secret"
 )
 
@@ -51,17 +52,30 @@ type SecretsManager struct {
 	// Login contains the AWS credentials (access/secret key).
 	Login Credentials
 
-	// ErrorLog specifies an optional logger for errors
-	// when files cannot be opened, deleted or contain
-	// invalid content.
+	// RecoveryWindow is the number of days AWS Secrets
+	// Manager keeps a deleted secret before removing it
+	// permanently. Within the recovery window a deleted
+	// secret can be restored via the AWS console or CLI.
+	//
+	// If 0, deleted secrets are removed immediately and
+	// cannot be recovered. Otherwise, AWS demands a
+	// recovery window between 7 and 30 days.
+	RecoveryWindow int
+
+	// Log specifies an optional logger for errors
+	// when secrets cannot be created, fetched or
+	// deleted.
 	// If nil, logging is done via the log package's
 	// standard logger.
-	ErrorLog *log.Logger
+	Log *xlog.Logger
 
 	client *secretsmanager.SecretsManager
 }
 
-var _ secret.Remote = (*SecretsManager)(nil)
+var (
+	_ secret.Remote = (*SecretsManager)(nil)
+	_ secret.Lister = (*SecretsManager)(nil)
+)
 
 // Create stores the given key-value pair at the AWS SecretsManager
 // if and only if it doesn't exists. If such an entry already exists
@@ -72,26 +86,46 @@ var _ secret.Remote = (*SecretsManager)(nil)
 // encrypting secrets at the AWS SecretsManager.
 func (s *SecretsManager) Create(key, value string) error {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return errNoConnection
 	}
 
+	token, err := clientRequestToken()
+	if err != nil {
+		s.Log.Error("failed to create entry", "key", key, "err", err)
+		return err
+	}
 	createOpt := secretsmanager.CreateSecretInput{
 		Name:         aws.String(key),
 		SecretString: aws.String(value),
+
+		// The client request token makes creating a secret
+		// idempotent. If a request succeeds at AWS but the
+		// response gets lost - e.g. due to a network error -
+		// the SDK retries the request with the same input.
+		// AWS treats a retry with the same token and value as
+		// success instead of failing with a ResourceExistsException.
+		ClientRequestToken: aws.String(token),
 	}
 	if s.KMSKeyID != "" {
 		createOpt.KmsKeyId = aws.String(s.KMSKeyID)
 	}
-	if _, err := s.client.CreateSecret(&createOpt); err != nil {
+	if _, err = s.client.CreateSecret(&createOpt); err != nil {
 		if err, ok := err.(awserr.Error); ok {
 			switch err.Code() {
 			case secretsmanager.ErrCodeResourceExistsException:
 				return kes.ErrKeyExists
+			case secretsmanager.ErrCodeInvalidRequestException:
+				// A secret that is scheduled for deletion still
+				// occupies its name until the recovery window
+				// has passed.
+				if s.isScheduledForDeletion(key) {
+					return kes.ErrKeyExists
+				}
 			}
 		}
-		err = fmt.Errorf("aws: failed to create '%s': %v", key, err)
-		s.log(err)
+		s.Log.Error("failed to create entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to create '%s': %w", key, err)
 		return err
 	}
 	return nil
@@ -101,7 +135,7 @@ func (s *SecretsManager) Create(key, value string) error {
 // If no entry for key exists, it returns kes.ErrKeyNotFound.
 func (s *SecretsManager) Get(key string) (string, error) {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return "", errNoConnection
 	}
 
@@ -112,13 +146,17 @@ func (s *SecretsManager) Get(key string) (string, error) {
 		if err, ok := err.(awserr.Error); ok {
 			switch err.Code() {
 			case secretsmanager.ErrCodeDecryptionFailure:
-				return "", kes.NewError(http.StatusForbidden, fmt.Sprintf("aws: cannot access '%s': %v", key, err))
+				return "", errs.Errorf(kes.ErrBackendFailure, "aws: cannot access '%s': %w", key, err)
 			case secretsmanager.ErrCodeResourceNotFoundException:
 				return "", kes.ErrKeyNotFound
+			case secretsmanager.ErrCodeInvalidRequestException:
+				if s.isScheduledForDeletion(key) {
+					return "", kes.ErrKeyNotFound
+				}
 			}
 		}
-		err = fmt.Errorf("aws: failed to read '%s': %v", key, err)
-		s.log(err)
+		s.Log.Error("failed to read entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to read '%s': %w", key, err)
 		return "", err
 	}
 
@@ -132,34 +170,73 @@ func (s *SecretsManager) Get(key string) (string, error) {
 	if response.SecretString != nil {
 		return *response.SecretString, nil
 	}
+	defer secret.Wipe(response.SecretBinary)
 	return string(response.SecretBinary), nil
 }
 
 // Delete removes the key-value pair from the AWS SecretsManager, if
 // it exists.
+//
+// If the SecretsManager.RecoveryWindow is not 0 the secret is only
+// scheduled for deletion and can be restored within the recovery
+// window. Until then, the key cannot be re-created.
 func (s *SecretsManager) Delete(key string) error {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return errNoConnection
 	}
 
-	_, err := s.client.DeleteSecret(&secretsmanager.DeleteSecretInput{
-		SecretId:                   aws.String(key),
-		ForceDeleteWithoutRecovery: aws.Bool(true),
-	})
-	if err != nil {
+	deleteOpt := secretsmanager.DeleteSecretInput{
+		SecretId: aws.String(key),
+	}
+	if s.RecoveryWindow == 0 {
+		deleteOpt.ForceDeleteWithoutRecovery = aws.Bool(true)
+	} else {
+		deleteOpt.RecoveryWindowInDays = aws.Int64(int64(s.RecoveryWindow))
+	}
+	if _, err := s.client.DeleteSecret(&deleteOpt); err != nil {
 		if err, ok := err.(awserr.Error); ok {
-			if err.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			switch err.Code() {
+			case secretsmanager.ErrCodeResourceNotFoundException:
 				return nil
+			case secretsmanager.ErrCodeInvalidRequestException:
+				if s.isScheduledForDeletion(key) { // The secret has been deleted already
+					return nil
+				}
 			}
 		}
-		err = fmt.Errorf("aws: failed to delete '%s': %v", key, err)
-		s.log(err)
+		s.Log.Error("failed to delete entry", "key", key, "err", err)
+		err = errs.Errorf(keyStoreError(err), "aws: failed to delete '%s': %w", key, err)
 		return err
 	}
 	return nil
 }
 
+// List returns the names of all secrets at the AWS
+// SecretsManager. Secrets that are scheduled for
+// deletion are not included.
+func (s *SecretsManager) List() ([]string, error) {
+	if s.client == nil {
+		s.Log.Error("not connected", "err", errNoConnection)
+		return nil, errNoConnection
+	}
+
+	var names []string
+	err := s.client.ListSecretsPages(&secretsmanager.ListSecretsInput{}, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+		for _, entry := range page.SecretList {
+			if entry.Name != nil && entry.DeletedDate == nil {
+				names = append(names, *entry.Name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		s.Log.Error("failed to list entries", "err", err)
+		return nil, errs.Errorf(keyStoreError(err), "aws: failed to list secrets: %w", err)
+	}
+	return names, nil
+}
+
 // Authenticate tries to establish a connection to
 // the AWS Secrets Manager using the login credentials.
 func (s *SecretsManager) Authenticate() error {
@@ -196,6 +273,39 @@ func (s *SecretsManager) Authenticate() error {
 	return nil
 }
 
+// isScheduledForDeletion reports whether the secret
+// with the given name has been deleted but still
+// exists since its recovery window hasn't passed.
+//
+// AWS rejects most requests for such a secret with
+// a generic InvalidRequestException. Therefore, we
+// have to check explicitly whether the secret has
+// been deleted.
+func (s *SecretsManager) isScheduledForDeletion(key string) bool {
+	response, err := s.client.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(key),
+	})
+	if err != nil {
+		return false
+	}
+	return response.DeletedDate != nil
+}
+
+// clientRequestToken returns a random AWS client request
+// token. AWS uses it as version ID of the created secret.
+//
+// The token must not be derived from the key or value.
+// Otherwise, the version ID reveals a hash of the value
+// and re-creating a deleted secret with the same value
+// would be treated as retry of the previous create.
+func clientRequestToken() (string, error) {
+	var token [16]byte
+	if _, err := io.ReadFull(rand.Reader, token[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token[:]), nil // AWS demands 32 - 64 characters
+}
+
 // errNoConnection is the error returned and logged by
 // the key store if the AWS Secrets Manager client hasn't
 // been initialized.
@@ -203,12 +313,4 @@ func (s *SecretsManager) Authenticate() error {
 // This error is returned by Create, Get, Delete, a.s.o.
 // in case of an invalid configuration - i.e. when Authenticate()
 // hasn't been called.
-var errNoConnection = errors.New("aws: no connection to AWS secrets manager")
-
-func (s *SecretsManager) log(v ...interface{}) {
-	if s.ErrorLog == nil {
-		log.Println(v...)
-	} else {
-		s.ErrorLog.Println(v...)
-	}
-}
+var errNoConnection = errs.New(kes.ErrBackendUnavailable, "aws: no connection to AWS secrets manager")
@@ -0,0 +1,140 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// Transport controls how HTTP connections to
+// AWS are established and reused.
+//
+// Establishing a new TLS connection is expensive.
+// Therefore, a client should keep enough idle
+// connections open to handle bursts of requests
+// without performing additional TLS handshakes.
+type Transport struct {
+	// MaxIdleConns is the max. number of idle
+	// connections to AWS. If 0, it defaults
+	// to 100.
+	MaxIdleConns int
+
+	// IdleConnTimeout is the max. amount of time
+	// an idle connection remains open before it
+	// gets closed. If 0, it defaults to 90s.
+	IdleConnTimeout time.Duration
+
+	// TLSSessionCacheSize is the max. number of
+	// TLS sessions cached for TLS session resumption.
+	// A resumed TLS session requires a less expensive
+	// handshake. If 0, it defaults to 64. If < 0, TLS
+	// session resumption is disabled.
+	TLSSessionCacheSize int
+}
+
+// ConnStats contains statistics about the
+// HTTP connections to AWS.
+type ConnStats struct {
+	NewConns      uint64 // Number of newly established connections
+	ReusedConns   uint64 // Number of requests that reused an idle connection
+	TLSHandshakes uint64 // Number of completed TLS handshakes
+	TLSResumed    uint64 // Number of TLS handshakes that resumed a TLS session
+	TLSErrors     uint64 // Number of failed TLS handshakes
+}
+
+// connTracer is an http.RoundTripper that collects
+// connection statistics for all requests it sends.
+type connTracer struct {
+	// The counters must be 64 bit aligned for atomic
+	// operations. Therefore, they are the first fields.
+	newConns      uint64
+	reusedConns   uint64
+	tlsHandshakes uint64
+	tlsResumed    uint64
+	tlsErrors     uint64
+
+	http.RoundTripper
+}
+
+// newConnTracer returns a connTracer that sends
+// requests using an http.Transport configured as
+// specified by the Transport t.
+func newConnTracer(t Transport) *connTracer {
+	var (
+		maxIdleConns    = t.MaxIdleConns
+		idleConnTimeout = t.IdleConnTimeout
+		sessionCache    tls.ClientSessionCache
+	)
+	if maxIdleConns == 0 {
+		maxIdleConns = 100
+	}
+	if idleConnTimeout == 0 {
+		idleConnTimeout = 90 * time.Second
+	}
+	switch {
+	case t.TLSSessionCacheSize == 0:
+		sessionCache = tls.NewLRUClientSessionCache(64)
+	case t.TLSSessionCacheSize > 0:
+		sessionCache = tls.NewLRUClientSessionCache(t.TLSSessionCacheSize)
+	}
+
+	return &connTracer{
+		RoundTripper: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          maxIdleConns,
+			MaxIdleConnsPerHost:   maxIdleConns, // All requests go to the same AWS endpoint
+			IdleConnTimeout:       idleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			TLSClientConfig: &tls.Config{
+				ClientSessionCache: sessionCache,
+			},
+		},
+	}
+}
+
+func (c *connTracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddUint64(&c.reusedConns, 1)
+			} else {
+				atomic.AddUint64(&c.newConns, 1)
+			}
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				atomic.AddUint64(&c.tlsErrors, 1)
+				return
+			}
+			atomic.AddUint64(&c.tlsHandshakes, 1)
+			if state.DidResume {
+				atomic.AddUint64(&c.tlsResumed, 1)
+			}
+		},
+	}
+	return c.RoundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// Stats returns the current connection statistics.
+func (c *connTracer) Stats() ConnStats {
+	return ConnStats{
+		NewConns:      atomic.LoadUint64(&c.newConns),
+		ReusedConns:   atomic.LoadUint64(&c.reusedConns),
+		TLSHandshakes: atomic.LoadUint64(&c.tlsHandshakes),
+		TLSResumed:    atomic.LoadUint64(&c.tlsResumed),
+		TLSErrors:     atomic.LoadUint64(&c.tlsErrors),
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package backup creates and restores signed archives
+// of the server state - i.e. all keys, policies and
+// identity assignments.
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// Version is the version of the archive format.
+const Version = 1
+
+// kmsContext is the KMS context of the archive
+// signing key.
+const kmsContext = "kes backup archive"
+
+var (
+	// ErrKMSRequired is returned by Create and Restore
+	// if the store has no KMS. Without a KMS, the keys
+	// would be exported in plaintext.
+	ErrKMSRequired = kes.NewError(http.StatusNotImplemented, "backup requires a KMS")
+
+	// ErrInvalidSignature is returned by Restore if the
+	// archive has been modified or has not been created
+	// with the same KMS.
+	ErrInvalidSignature = kes.NewError(http.StatusBadRequest, "backup archive signature is invalid")
+
+	// ErrMalformed is returned by Restore if the archive
+	// cannot be parsed.
+	ErrMalformed = kes.NewError(http.StatusBadRequest, "backup archive is malformed")
+)
+
+// Archive is a signed backup of the server state.
+//
+// The state is signed with a random key that is
+// encrypted with the KMS. Therefore, only a server
+// with access to the same KMS master key can verify
+// and restore the archive.
+type Archive struct {
+	State     []byte `json:"state"`     // The JSON-encoded State
+	Key       []byte `json:"key"`       // The KMS-encrypted signing key
+	Signature []byte `json:"signature"` // The HMAC-SHA256 of the State
+}
+
+// State is the server state contained in an Archive.
+type State struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Keys are the keys as stored at the key store - i.e.
+	// encrypted with the KMS.
+	Keys map[string]string `json:"keys"`
+
+	// Policies are the policies and identity assignments.
+	// See: auth.Roles.Export
+	Policies json.RawMessage `json:"policies"`
+
+	// Provenance contains the provenance of each key
+	// that has any. See: secret.Store.Provenance
+	Provenance map[string][]secret.ProvenanceEvent `json:"provenance,omitempty"`
+}
+
+// Report describes the result of Restore.
+type Report struct {
+	Keys    int `json:"keys"`    // Number of restored keys
+	Skipped int `json:"skipped"` // Number of keys that already existed
+}
+
+// Create returns a signed archive of all keys at the
+// store and of all policies and identity assignments.
+//
+// The store must have a KMS and its Remote store must
+// be able to list its entries.
+//
+// Keys with a usage policy that does not allow the
+// secret.OpExport operation are not included in the
+// archive. See: secret.Store.CreateWithOps
+func Create(store *secret.Store, roles *auth.Roles) (*Archive, error) {
+	if store.KMS == nil {
+		return nil, ErrKMSRequired
+	}
+
+	names, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	state := State{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Keys:      make(map[string]string, len(names)),
+	}
+	for _, name := range names {
+		value, err := store.Remote.Get(name)
+		if err == kes.ErrKeyNotFound {
+			continue // The key has been deleted in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err = secret.ParseCiphertext(value); err != nil {
+			return nil, kes.NewError(http.StatusConflict, fmt.Sprintf("key '%s' is not encrypted with the KMS", name))
+		}
+		ops, err := secret.ParseOps(value)
+		if err != nil {
+			return nil, err
+		}
+		if !secret.AllowsOp(ops, secret.OpExport) {
+			continue // The key must not leave the key store
+		}
+		state.Keys[name] = value
+
+		events, err := store.Provenance(name)
+		if err != nil && err != kes.ErrKeyNotFound {
+			return nil, err
+		}
+		if len(events) > 0 {
+			if state.Provenance == nil {
+				state.Provenance = map[string][]secret.ProvenanceEvent{}
+			}
+			state.Provenance[name] = events
+		}
+	}
+	if state.Policies, err = roles.Export(); err != nil {
+		return nil, err
+	}
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	key, err := store.Random(32)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := store.KMS.Encrypt(key, kmsContext)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{
+		State:     stateJSON,
+		Key:       encryptedKey,
+		Signature: sign(key, stateJSON),
+	}, nil
+}
+
+// Restore verifies the archive signature and restores
+// all keys, policies and identity assignments of the
+// archive.
+//
+// Restore does not replace existing keys. Restored
+// policies and identity assignments replace existing
+// ones with the same name.
+//
+// Restore keeps the provenance of each restored key
+// and records that the given identity has restored
+// it from the archive.
+func Restore(archive *Archive, store *secret.Store, roles *auth.Roles, identity kes.Identity) (Report, error) {
+	if store.KMS == nil {
+		return Report{}, ErrKMSRequired
+	}
+
+	key, err := store.KMS.Decrypt(archive.Key, kmsContext)
+	if err != nil {
+		return Report{}, ErrInvalidSignature
+	}
+	if !hmac.Equal(archive.Signature, sign(key, archive.State)) {
+		return Report{}, ErrInvalidSignature
+	}
+
+	var state State
+	if err = json.Unmarshal(archive.State, &state); err != nil {
+		return Report{}, ErrMalformed
+	}
+	if state.Version != Version {
+		return Report{}, kes.NewError(http.StatusBadRequest, fmt.Sprintf("backup archive version %d is not supported", state.Version))
+	}
+
+	var report Report
+	for name, value := range state.Keys {
+		if !isValidName(name) {
+			return report, ErrMalformed
+		}
+		if _, err = secret.ParseCiphertext(value); err != nil {
+			return report, ErrMalformed
+		}
+		switch err = store.Remote.Create(name, value); err {
+		case nil:
+			report.Keys++
+		case kes.ErrKeyExists:
+			report.Skipped++
+			continue
+		default:
+			return report, err
+		}
+		if err = store.ImportProvenance(name, state.Provenance[name]); err != nil {
+			return report, err
+		}
+		err = store.AppendProvenance(name, secret.ProvenanceEvent{
+			Type:     secret.ProvenanceRestored,
+			Identity: identity,
+			Origin:   "backup archive created at " + state.CreatedAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	if err = roles.Import(state.Policies); err != nil {
+		return report, err
+	}
+	return report, roles.Save()
+}
+
+// VerifyReport describes the result of Verify.
+type VerifyReport struct {
+	CreatedAt time.Time `json:"created_at"` // When the archive has been created
+	Keys      int       `json:"keys"`       // Number of entries the KMS can decrypt
+
+	// Unreadable contains the name of each entry the
+	// KMS cannot decrypt and the reason why.
+	Unreadable map[string]string `json:"unreadable,omitempty"`
+}
+
+// Verify verifies the archive signature and checks that
+// the KMS can decrypt every entry of the archive - e.g. to
+// validate a backup before it is needed for disaster recovery.
+// It does not restore or modify anything.
+//
+// Verify returns ErrInvalidSignature if the archive has been
+// modified or has not been created with the same KMS. Entries
+// that cannot be decrypted are reported as unreadable.
+func Verify(archive *Archive, kms secret.KMS) (VerifyReport, error) {
+	key, err := kms.Decrypt(archive.Key, kmsContext)
+	if err != nil {
+		return VerifyReport{}, ErrInvalidSignature
+	}
+	if !hmac.Equal(archive.Signature, sign(key, archive.State)) {
+		return VerifyReport{}, ErrInvalidSignature
+	}
+
+	var state State
+	if err = json.Unmarshal(archive.State, &state); err != nil {
+		return VerifyReport{}, ErrMalformed
+	}
+	if state.Version != Version {
+		return VerifyReport{}, kes.NewError(http.StatusBadRequest, fmt.Sprintf("backup archive version %d is not supported", state.Version))
+	}
+
+	report := VerifyReport{CreatedAt: state.CreatedAt}
+	for name, value := range state.Keys {
+		if !isValidName(name) {
+			err = errors.New("invalid name")
+		} else {
+			err = secret.VerifyCiphertext(kms, name, value)
+		}
+		if err != nil {
+			if report.Unreadable == nil {
+				report.Unreadable = map[string]string{}
+			}
+			report.Unreadable[name] = err.Error()
+			continue
+		}
+		report.Keys++
+	}
+	return report, nil
+}
+
+// isValidName reports whether name is a valid key name
+// that does not refer to an entry managed by the server
+// itself or to an entry outside the key store.
+func isValidName(name string) bool {
+	switch name {
+	case "", secret.ReservedName, secret.ReservedQuotaName, secret.ReservedEnclaveName, secret.ReservedEnclavesName, secret.ReservedJobsName, secret.ReservedUsageName, secret.ReservedAccountingName, secret.ReservedSealName, secret.ReservedAliasName, secret.ReservedStateName, secret.ReservedHybridName:
+		return false
+	}
+	if strings.HasPrefix(name, secret.ReservedEnclavePrefix) || strings.HasPrefix(name, secret.ReservedProvenancePrefix) || strings.HasPrefix(name, secret.ReservedApprovalPrefix) || strings.HasPrefix(name, secret.ReservedRewrapPrefix) || strings.HasPrefix(name, secret.ReservedReplacePrefix) {
+		return false
+	}
+	return secret.ValidName(name)
+}
+
+func sign(key, state []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(state)
+	return mac.Sum(nil)
+}
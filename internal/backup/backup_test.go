@@ -0,0 +1,175 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestCreateRestore(t *testing.T) {
+	store := &secret.Store{Remote: &mem.Store{}, KMS: xorKMS{0x5a}}
+	roles := &auth.Roles{Root: "root", Remote: store.Remote}
+
+	var key secret.Secret
+	key[0] = 1
+	for _, name := range []string{"my-key", "my-tenant/my-key"} {
+		if err := store.Create(context.Background(), name, key); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := store.CreateWithOps(context.Background(), "exported-key", key, []string{secret.OpDecrypt, secret.OpExport}); err != nil {
+		t.Fatalf("Failed to create key 'exported-key': %v", err)
+	}
+	if err := store.CreateWithOps(context.Background(), "internal-key", key, []string{secret.OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key 'internal-key': %v", err)
+	}
+	if err := store.AppendProvenance("my-key", secret.ProvenanceEvent{Type: secret.ProvenanceCreated, Identity: "af43c"}); err != nil {
+		t.Fatalf("Failed to record provenance: %v", err)
+	}
+	policy, err := kes.NewPolicy("/v1/key/create/*")
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	roles.Set("my-app", policy)
+	if err = roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+	if err = roles.Save(); err != nil {
+		t.Fatalf("Failed to save roles: %v", err)
+	}
+
+	archive, err := Create(store, roles)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	restoredStore := &secret.Store{Remote: &mem.Store{}, KMS: xorKMS{0x5a}}
+	restoredRoles := &auth.Roles{Root: "root", Remote: restoredStore.Remote}
+	report, err := Restore(archive, restoredStore, restoredRoles, "root")
+	if err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if report.Keys != 3 || report.Skipped != 0 {
+		t.Fatalf("Invalid restore report: got %+v", report)
+	}
+	for _, name := range []string{"my-key", "my-tenant/my-key", "exported-key"} {
+		if restored, err := restoredStore.Get(context.Background(), name); err != nil || restored != key {
+			t.Fatalf("Key '%s' has not been restored: %v", name, err)
+		}
+	}
+	if _, err = restoredStore.GetFor(context.Background(), "exported-key", secret.OpGenerate); err == nil {
+		t.Fatal("Key usage policy has not been restored")
+	}
+	if _, err = restoredStore.Get(context.Background(), "internal-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Key without export operation has been restored: %v", err)
+	}
+	events, err := restoredStore.Provenance("my-key")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != secret.ProvenanceCreated || events[1].Type != secret.ProvenanceRestored || events[1].Identity != "root" {
+		t.Fatalf("Provenance has not been restored: %+v", events)
+	}
+	if identities := restoredRoles.Identities(); identities["af43c"] != "my-app" {
+		t.Fatalf("Identities have not been restored: got %v", identities)
+	}
+
+	if report, err = Restore(archive, restoredStore, restoredRoles, ""); err != nil {
+		t.Fatalf("Failed to restore backup twice: %v", err)
+	}
+	if report.Keys != 0 || report.Skipped != 3 {
+		t.Fatalf("Existing keys should be skipped: got %+v", report)
+	}
+
+	archive.State[len(archive.State)-2] ^= 1
+	if _, err = Restore(archive, restoredStore, restoredRoles, ""); err != ErrInvalidSignature {
+		t.Fatalf("Modified backup should not be restored: got %v", err)
+	}
+	if _, err = Create(&secret.Store{Remote: &mem.Store{}}, roles); err != ErrKMSRequired {
+		t.Fatalf("Backup without KMS should fail with %v: got %v", ErrKMSRequired, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	store := &secret.Store{Remote: &mem.Store{}, KMS: xorKMS{0x5a}}
+	roles := &auth.Roles{Root: "root", Remote: store.Remote}
+	for _, name := range []string{"my-key", "other-key"} {
+		if err := store.Create(context.Background(), name, secret.Secret{1}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := store.CreateWithOps(context.Background(), "exported-key", secret.Secret{2}, []string{secret.OpDecrypt, secret.OpExport}); err != nil {
+		t.Fatalf("Failed to create key 'exported-key': %v", err)
+	}
+
+	archive, err := Create(store, roles)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	report, err := Verify(archive, xorKMS{0x5a})
+	if err != nil {
+		t.Fatalf("Failed to verify backup: %v", err)
+	}
+	if report.Keys != 3 || len(report.Unreadable) != 0 {
+		t.Fatalf("Invalid verify report: got %+v", report)
+	}
+	if _, err = Verify(archive, xorKMS{0x01}); err != ErrInvalidSignature {
+		t.Fatalf("Backup should not be verified with another KMS: got %v", err)
+	}
+
+	// Replace a key with a ciphertext of another KMS and
+	// re-sign the archive. The key must be reported as
+	// unreadable.
+	var state State
+	if err = json.Unmarshal(archive.State, &state); err != nil {
+		t.Fatalf("Failed to decode backup state: %v", err)
+	}
+	ciphertext, _ := xorKMS{0x01}.Encrypt(make([]byte, 32), "other-key")
+	state.Keys["other-key"] = secret.Ciphertext(ciphertext).String()
+	if archive.State, err = json.Marshal(state); err != nil {
+		t.Fatalf("Failed to encode backup state: %v", err)
+	}
+	key, _ := xorKMS{0x5a}.Decrypt(archive.Key, kmsContext)
+	archive.Signature = sign(key, archive.State)
+
+	if report, err = Verify(archive, xorKMS{0x5a}); err != nil {
+		t.Fatalf("Failed to verify backup: %v", err)
+	}
+	if _, ok := report.Unreadable["other-key"]; report.Keys != 2 || len(report.Unreadable) != 1 || !ok {
+		t.Fatalf("Invalid verify report: got %+v", report)
+	}
+}
+
+// xorKMS is an insecure KMS that "encrypts" by XOR-ing
+// the plaintext with its key byte. It prepends a key
+// byte and the context to detect mismatches.
+type xorKMS struct{ key byte }
+
+func (k xorKMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	ciphertext := append([]byte{k.key}, context...)
+	for _, b := range plaintext {
+		ciphertext = append(ciphertext, b^k.key)
+	}
+	return ciphertext, nil
+}
+
+func (k xorKMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	if len(ciphertext) < 1+len(context) || ciphertext[0] != k.key || string(ciphertext[1:1+len(context)]) != context {
+		return nil, errors.New("decryption failed")
+	}
+	plaintext := make([]byte, 0, len(ciphertext)-1-len(context))
+	for _, b := range ciphertext[1+len(context):] {
+		plaintext = append(plaintext, b^k.key)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,732 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package cluster replicates the writes to a key store
+// across multiple KES servers using the Raft consensus
+// algorithm.
+//
+// All writes are appended to a replicated log by the
+// current leader and applied by every cluster node once
+// a majority of the nodes has stored them. Reads are
+// served by each node from its local key store.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+const (
+	// heartbeatInterval is the interval in which the
+	// leader sends (empty) log entries to the followers.
+	heartbeatInterval = 200 * time.Millisecond
+
+	// electionTimeout is the min. time a follower waits
+	// for the leader before it starts an election. The
+	// actual timeout is randomized between electionTimeout
+	// and 2*electionTimeout.
+	electionTimeout = 1 * time.Second
+
+	// maxBatchSize is the max. size of the log entries
+	// the leader sends to a follower at once.
+	maxBatchSize = 1 << 20
+)
+
+var (
+	// ErrNoLeader is returned by Propose if the cluster
+	// has currently no leader - e.g. during an election
+	// or if less than a majority of the nodes is available.
+	ErrNoLeader = kes.NewError(503, "cluster has no leader")
+
+	// errProposalLost is returned by Propose if the leader
+	// lost its leadership before the proposed command has
+	// been committed.
+	errProposalLost = kes.NewError(503, "cluster leader changed: command has not been committed")
+)
+
+// Command is a replicated write to the key store.
+type Command struct {
+	Op    string `json:"op"` // Either "create" or "delete" - empty for no-ops
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// The commands that modify the key store.
+const (
+	OpCreate = "create"
+	OpDelete = "delete"
+)
+
+// Entry is an entry of the replicated log.
+type Entry struct {
+	Term    uint64  `json:"term"`
+	Command Command `json:"command"`
+}
+
+// Peer is a member of the cluster.
+type Peer struct {
+	ID       string
+	Addr     string       // The HTTPS endpoint - e.g. https://10.0.0.1:7373
+	Identity kes.Identity // The identity of the peer's TLS certificate
+}
+
+// VoteRequest is sent by a candidate to request
+// the vote of a peer.
+type VoteRequest struct {
+	Term         uint64 `json:"term"`
+	Candidate    string `json:"candidate"`
+	LastLogIndex uint64 `json:"last_log_index"`
+	LastLogTerm  uint64 `json:"last_log_term"`
+}
+
+// VoteResponse is the response to a VoteRequest.
+type VoteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+// AppendRequest is sent by the leader to replicate
+// log entries and as heartbeat.
+type AppendRequest struct {
+	Term         uint64  `json:"term"`
+	Leader       string  `json:"leader"`
+	PrevLogIndex uint64  `json:"prev_log_index"`
+	PrevLogTerm  uint64  `json:"prev_log_term"`
+	Entries      []Entry `json:"entries,omitempty"`
+	LeaderCommit uint64  `json:"leader_commit"`
+}
+
+// AppendResponse is the response to an AppendRequest.
+type AppendResponse struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+
+	// LastLogIndex is a hint for the leader which log
+	// entries the follower may have if it rejects the
+	// request.
+	LastLogIndex uint64 `json:"last_log_index"`
+}
+
+// Transport sends the Raft messages to other peers.
+type Transport interface {
+	RequestVote(ctx context.Context, peer Peer, req VoteRequest) (VoteResponse, error)
+
+	AppendEntries(ctx context.Context, peer Peer, req AppendRequest) (AppendResponse, error)
+
+	// Propose forwards the command to the peer, which
+	// should be the leader.
+	Propose(ctx context.Context, peer Peer, cmd Command) error
+}
+
+// Status is the Raft state of a Node.
+type Status struct {
+	ID          string `json:"id"`
+	Role        string `json:"role"`
+	Leader      string `json:"leader"`
+	Term        uint64 `json:"term"`
+	LastIndex   uint64 `json:"last_index"`
+	CommitIndex uint64 `json:"commit_index"`
+	LastApplied uint64 `json:"last_applied"`
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	switch r {
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	default:
+		return "follower"
+	}
+}
+
+// Node is a member of a Raft cluster.
+type Node struct {
+	// ID is the ID of the node. It must be the
+	// ID of one of the peers.
+	ID string
+
+	// Peers are all members of the cluster,
+	// including this node.
+	Peers []Peer
+
+	// Dir is the directory where the node stores
+	// its Raft state and its log.
+	Dir string
+
+	// Transport sends messages to other peers.
+	Transport Transport
+
+	// Apply applies a committed command to the local
+	// key store. It must be deterministic - i.e. return
+	// the same result on every node.
+	Apply func(Command) error
+
+	// ErrorLog is an optional logger for errors
+	// that occur in the background.
+	ErrorLog *log.Logger
+
+	lock    sync.Mutex
+	storage *storage
+	term    uint64
+	vote    string
+	log     []Entry // log[i] is the entry at index i+1
+
+	role        role
+	leader      string
+	commitIndex uint64
+	lastApplied uint64
+	deadline    time.Time // The election deadline of followers and candidates
+	votes       int
+
+	nextIndex   map[string]uint64
+	matchIndex  map[string]uint64
+	inFlight    map[string]bool
+	waiters     map[uint64]waiter
+	applySignal chan struct{}
+}
+
+type waiter struct {
+	Term uint64
+	C    chan error
+}
+
+// Start loads the Raft state of the node from its
+// directory and starts to participate in the cluster
+// until the ctx is done.
+func (n *Node) Start(ctx context.Context) error {
+	if _, ok := n.peer(n.ID); !ok {
+		return errors.New("cluster: node is not a cluster peer")
+	}
+
+	storage, term, vote, entries, err := openStorage(n.Dir)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	n.storage = storage
+	n.term, n.vote, n.log = term, vote, entries
+	n.role = follower
+	n.applySignal = make(chan struct{}, 1)
+	n.waiters = map[uint64]waiter{}
+	n.resetDeadline()
+	n.lock.Unlock()
+
+	go n.run(ctx)
+	go n.applyCommitted(ctx)
+	return nil
+}
+
+// Status returns the current Raft state of the node.
+func (n *Node) Status() Status {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return Status{
+		ID:          n.ID,
+		Role:        n.role.String(),
+		Leader:      n.leader,
+		Term:        n.term,
+		LastIndex:   n.lastIndex(),
+		CommitIndex: n.commitIndex,
+		LastApplied: n.lastApplied,
+	}
+}
+
+// IsPeer reports whether the identity belongs
+// to a member of the cluster.
+func (n *Node) IsPeer(identity kes.Identity) bool {
+	if identity.IsUnknown() {
+		return false
+	}
+	var isPeer bool
+	for _, peer := range n.Peers {
+		// We check all peers such that the time does
+		// not depend on which peer matches, if any.
+		if secret.EqualIdentity(peer.Identity, identity) {
+			isPeer = true
+		}
+	}
+	return isPeer
+}
+
+// Propose replicates the command and waits until it has
+// been applied by the leader. It returns the result of
+// the leader's Apply.
+//
+// If the node is not the leader, it forwards the command
+// to the leader.
+func (n *Node) Propose(ctx context.Context, cmd Command) error {
+	n.lock.Lock()
+	if n.role != leader {
+		leaderID := n.leader
+		n.lock.Unlock()
+
+		peer, ok := n.peer(leaderID)
+		if !ok || leaderID == n.ID {
+			return ErrNoLeader
+		}
+		return n.Transport.Propose(ctx, peer, cmd)
+	}
+
+	entry := Entry{Term: n.term, Command: cmd}
+	if err := n.storage.Append(entry); err != nil {
+		n.lock.Unlock()
+		return err
+	}
+	n.log = append(n.log, entry)
+	index := n.lastIndex()
+	n.matchIndex[n.ID] = index
+
+	ch := make(chan error, 1)
+	n.waiters[index] = waiter{Term: entry.Term, C: ch}
+	n.advanceCommitIndex()
+	n.broadcast()
+	n.lock.Unlock()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		n.lock.Lock()
+		delete(n.waiters, index)
+		n.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// RequestVote handles a vote request of a candidate.
+func (n *Node) RequestVote(req VoteRequest) (VoteResponse, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if req.Term < n.term {
+		return VoteResponse{Term: n.term}, nil
+	}
+	if req.Term > n.term {
+		if err := n.stepDown(req.Term); err != nil {
+			return VoteResponse{}, err
+		}
+	}
+
+	lastIndex, lastTerm := n.lastIndex(), n.termAt(n.lastIndex())
+	upToDate := req.LastLogTerm > lastTerm || (req.LastLogTerm == lastTerm && req.LastLogIndex >= lastIndex)
+	if (n.vote == "" || n.vote == req.Candidate) && upToDate {
+		if err := n.storage.SetState(n.term, req.Candidate); err != nil {
+			return VoteResponse{}, err
+		}
+		n.vote = req.Candidate
+		n.resetDeadline()
+		return VoteResponse{Term: n.term, Granted: true}, nil
+	}
+	return VoteResponse{Term: n.term}, nil
+}
+
+// AppendEntries handles an append request of the leader.
+func (n *Node) AppendEntries(req AppendRequest) (AppendResponse, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if req.Term < n.term {
+		return AppendResponse{Term: n.term, LastLogIndex: n.lastIndex()}, nil
+	}
+	if req.Term > n.term || n.role != follower {
+		if err := n.stepDown(req.Term); err != nil {
+			return AppendResponse{}, err
+		}
+	}
+	n.leader = req.Leader
+	n.resetDeadline()
+
+	if req.PrevLogIndex > n.lastIndex() {
+		return AppendResponse{Term: n.term, LastLogIndex: n.lastIndex()}, nil
+	}
+	if n.termAt(req.PrevLogIndex) != req.PrevLogTerm {
+		return AppendResponse{Term: n.term, LastLogIndex: req.PrevLogIndex - 1}, nil
+	}
+
+	for i, entry := range req.Entries {
+		index := req.PrevLogIndex + 1 + uint64(i)
+		if index <= n.lastIndex() {
+			if n.termAt(index) == entry.Term {
+				continue // We already have this entry
+			}
+			if err := n.truncate(index - 1); err != nil {
+				return AppendResponse{}, err
+			}
+		}
+		if err := n.storage.Append(req.Entries[i:]...); err != nil {
+			return AppendResponse{}, err
+		}
+		n.log = append(n.log, req.Entries[i:]...)
+		break
+	}
+
+	// We only know that our log matches the leader's log up
+	// to the last entry of this request.
+	commitIndex := req.LeaderCommit
+	if last := req.PrevLogIndex + uint64(len(req.Entries)); commitIndex > last {
+		commitIndex = last
+	}
+	if commitIndex > n.commitIndex {
+		n.commitIndex = commitIndex
+		n.signalApply()
+	}
+	return AppendResponse{Term: n.term, Success: true, LastLogIndex: n.lastIndex()}, nil
+}
+
+// run drives the elections and heartbeats
+// until the ctx is done.
+func (n *Node) run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval / 4)
+	defer ticker.Stop()
+
+	var lastHeartbeat time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			n.lock.Lock()
+			n.storage.Close()
+			n.lock.Unlock()
+			return
+		case now := <-ticker.C:
+			n.lock.Lock()
+			switch {
+			case n.role == leader && now.Sub(lastHeartbeat) >= heartbeatInterval:
+				n.broadcast()
+				lastHeartbeat = now
+			case n.role != leader && now.After(n.deadline):
+				if err := n.startElection(); err != nil {
+					n.logf("cluster: failed to start election: %v", err)
+				}
+			}
+			n.lock.Unlock()
+		}
+	}
+}
+
+// applyCommitted applies the committed log entries
+// until the ctx is done.
+func (n *Node) applyCommitted(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.applySignal:
+		}
+
+		n.lock.Lock()
+		first := n.lastApplied + 1
+		entries := append([]Entry(nil), n.log[n.lastApplied:n.commitIndex]...)
+		n.lock.Unlock()
+
+		for i, entry := range entries {
+			var err error
+			if entry.Command.Op != "" {
+				if err = n.Apply(entry.Command); err != nil && err != kes.ErrKeyExists {
+					n.logf("cluster: failed to apply '%s %s': %v", entry.Command.Op, entry.Command.Key, err)
+				}
+			}
+
+			index := first + uint64(i)
+			n.lock.Lock()
+			n.lastApplied = index
+			if w, ok := n.waiters[index]; ok {
+				if w.Term != entry.Term {
+					err = errProposalLost
+				}
+				w.C <- err
+				delete(n.waiters, index)
+			}
+			n.lock.Unlock()
+		}
+	}
+}
+
+// startElection starts a new election with this
+// node as candidate.
+//
+// The caller must hold the lock.
+func (n *Node) startElection() error {
+	if err := n.storage.SetState(n.term+1, n.ID); err != nil {
+		n.resetDeadline()
+		return err
+	}
+	n.term++
+	n.vote = n.ID
+	n.role = candidate
+	n.leader = ""
+	n.votes = 1
+	n.resetDeadline()
+	if n.votes >= n.majority() {
+		n.becomeLeader()
+		return nil
+	}
+
+	req := VoteRequest{
+		Term:         n.term,
+		Candidate:    n.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.termAt(n.lastIndex()),
+	}
+	for _, peer := range n.Peers {
+		if peer.ID == n.ID {
+			continue
+		}
+		go func(peer Peer) {
+			ctx, cancel := context.WithTimeout(context.Background(), electionTimeout)
+			defer cancel()
+
+			resp, err := n.Transport.RequestVote(ctx, peer, req)
+			if err != nil {
+				return
+			}
+
+			n.lock.Lock()
+			defer n.lock.Unlock()
+			if resp.Term > n.term {
+				if err = n.stepDown(resp.Term); err != nil {
+					n.logf("cluster: failed to step down: %v", err)
+				}
+				return
+			}
+			if n.role != candidate || n.term != req.Term || !resp.Granted {
+				return
+			}
+			if n.votes++; n.votes >= n.majority() {
+				n.becomeLeader()
+			}
+		}(peer)
+	}
+	return nil
+}
+
+// becomeLeader turns a candidate into the leader.
+//
+// The caller must hold the lock.
+func (n *Node) becomeLeader() {
+	n.role = leader
+	n.leader = n.ID
+	n.nextIndex = map[string]uint64{}
+	n.matchIndex = map[string]uint64{}
+	n.inFlight = map[string]bool{}
+	for _, peer := range n.Peers {
+		n.nextIndex[peer.ID] = n.lastIndex() + 1
+		n.matchIndex[peer.ID] = 0
+	}
+
+	// A leader can only commit entries of its own term.
+	// Therefore, it appends an empty entry such that all
+	// entries of previous terms get committed as well.
+	entry := Entry{Term: n.term}
+	if err := n.storage.Append(entry); err != nil {
+		n.logf("cluster: failed to append log entry: %v", err)
+		n.stepDown(n.term)
+		return
+	}
+	n.log = append(n.log, entry)
+	n.matchIndex[n.ID] = n.lastIndex()
+	n.advanceCommitIndex()
+	n.broadcast()
+}
+
+// stepDown turns the node into a follower of the
+// given term.
+//
+// The caller must hold the lock.
+func (n *Node) stepDown(term uint64) error {
+	if term > n.term {
+		if err := n.storage.SetState(term, ""); err != nil {
+			return err
+		}
+		n.term, n.vote = term, ""
+		n.leader = ""
+	}
+	if n.role != follower {
+		n.role = follower
+		n.resetDeadline()
+	}
+	return nil
+}
+
+// broadcast sends the missing log entries - or an empty
+// heartbeat - to all followers.
+//
+// The caller must hold the lock.
+func (n *Node) broadcast() {
+	for _, peer := range n.Peers {
+		if peer.ID != n.ID {
+			n.replicate(peer)
+		}
+	}
+}
+
+// replicate sends the missing log entries to the peer
+// unless there is already a request in flight.
+//
+// The caller must hold the lock.
+func (n *Node) replicate(peer Peer) {
+	if n.role != leader || n.inFlight[peer.ID] {
+		return
+	}
+
+	prevIndex := n.nextIndex[peer.ID] - 1
+	var (
+		entries []Entry
+		size    int
+	)
+	for _, entry := range n.log[prevIndex:] {
+		if size += len(entry.Command.Key) + len(entry.Command.Value); size > maxBatchSize && len(entries) > 0 {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	req := AppendRequest{
+		Term:         n.term,
+		Leader:       n.ID,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  n.termAt(prevIndex),
+		Entries:      entries,
+		LeaderCommit: n.commitIndex,
+	}
+	n.inFlight[peer.ID] = true
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), electionTimeout)
+		defer cancel()
+		resp, err := n.Transport.AppendEntries(ctx, peer, req)
+
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		n.inFlight[peer.ID] = false
+		if err != nil {
+			return // We retry with the next heartbeat
+		}
+		if resp.Term > n.term {
+			if err = n.stepDown(resp.Term); err != nil {
+				n.logf("cluster: failed to step down: %v", err)
+			}
+			return
+		}
+		if n.role != leader || n.term != req.Term {
+			return
+		}
+
+		if resp.Success {
+			if match := req.PrevLogIndex + uint64(len(req.Entries)); match > n.matchIndex[peer.ID] {
+				n.matchIndex[peer.ID] = match
+				n.nextIndex[peer.ID] = match + 1
+				n.advanceCommitIndex()
+			}
+		} else {
+			next := n.nextIndex[peer.ID] - 1
+			if hint := resp.LastLogIndex + 1; hint < next {
+				next = hint
+			}
+			if next < 1 {
+				next = 1
+			}
+			n.nextIndex[peer.ID] = next
+		}
+		if n.nextIndex[peer.ID] <= n.lastIndex() {
+			n.replicate(peer)
+		}
+	}()
+}
+
+// advanceCommitIndex commits all entries of the current
+// term that have been stored by a majority of the nodes.
+//
+// The caller must hold the lock.
+func (n *Node) advanceCommitIndex() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.termAt(index) != n.term {
+			break
+		}
+		var count int
+		for _, match := range n.matchIndex {
+			if match >= index {
+				count++
+			}
+		}
+		if count >= n.majority() {
+			n.commitIndex = index
+			n.signalApply()
+			break
+		}
+	}
+}
+
+// truncate removes all log entries after the index.
+// Waiters for removed entries fail.
+//
+// The caller must hold the lock.
+func (n *Node) truncate(index uint64) error {
+	if err := n.storage.Rewrite(n.log[:index]); err != nil {
+		return err
+	}
+	for i := index + 1; i <= n.lastIndex(); i++ {
+		if w, ok := n.waiters[i]; ok {
+			w.C <- errProposalLost
+			delete(n.waiters, i)
+		}
+	}
+	n.log = n.log[:index]
+	return nil
+}
+
+func (n *Node) signalApply() {
+	select {
+	case n.applySignal <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) resetDeadline() {
+	n.deadline = time.Now().Add(electionTimeout + time.Duration(rand.Int63n(int64(electionTimeout))))
+}
+
+func (n *Node) lastIndex() uint64 { return uint64(len(n.log)) }
+
+func (n *Node) termAt(index uint64) uint64 {
+	if index == 0 || index > n.lastIndex() {
+		return 0
+	}
+	return n.log[index-1].Term
+}
+
+func (n *Node) majority() int { return len(n.Peers)/2 + 1 }
+
+func (n *Node) peer(id string) (Peer, bool) {
+	for _, peer := range n.Peers {
+		if peer.ID == id {
+			return peer, true
+		}
+	}
+	return Peer{}, false
+}
+
+func (n *Node) logf(format string, v ...interface{}) {
+	if n.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		n.ErrorLog.Printf(format, v...)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+)
+
+func TestCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-cluster")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	transport := &localTransport{nodes: map[string]*Node{}}
+	peers := []Peer{{ID: "node-1"}, {ID: "node-2"}, {ID: "node-3"}}
+	stores := map[string]*mem.Store{}
+	cancel := map[string]context.CancelFunc{}
+	start := func(id string) {
+		store := &mem.Store{}
+		node := &Node{
+			ID:        id,
+			Peers:     peers,
+			Dir:       filepath.Join(dir, id),
+			Transport: transport,
+			Apply: func(cmd Command) error {
+				if cmd.Op == OpCreate {
+					return store.Create(cmd.Key, cmd.Value)
+				}
+				return store.Delete(cmd.Key)
+			},
+			ErrorLog: log.New(ioutil.Discard, "", 0),
+		}
+
+		var ctx context.Context
+		ctx, cancel[id] = context.WithCancel(context.Background())
+		if err := node.Start(ctx); err != nil {
+			t.Fatalf("Failed to start %s: %v", id, err)
+		}
+		stores[id] = store
+		transport.Add(node)
+	}
+	for _, peer := range peers {
+		start(peer.ID)
+	}
+	defer func() {
+		for _, stop := range cancel {
+			stop()
+		}
+	}()
+
+	leader := waitForLeader(t, transport, "")
+	follower := transport.Follower(leader)
+	remote := &Remote{Node: transport.Node(follower), Remote: stores[follower]}
+	if err = remote.Create("my-key", "my-value"); err != nil {
+		t.Fatalf("Failed to create key via follower: %v", err)
+	}
+	if err = remote.Create("my-key", "other-value"); err != kes.ErrKeyExists {
+		t.Fatalf("Creating an existing key should fail with %v: got %v", kes.ErrKeyExists, err)
+	}
+	waitForValue(t, stores, "my-key", "my-value")
+
+	// Stop the leader. The remaining nodes form a
+	// majority and must elect a new leader.
+	cancel[leader]()
+	transport.Remove(leader)
+	newLeader := waitForLeader(t, transport, leader)
+	remote = &Remote{Node: transport.Node(newLeader), Remote: stores[newLeader]}
+	if err = remote.Delete("my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = remote.Create("my-key-2", "my-value-2"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Restart the old leader. It must catch up by replaying
+	// its own log and receiving the missing entries.
+	start(leader)
+	waitForValue(t, stores, "my-key", "")
+	waitForValue(t, stores, "my-key-2", "my-value-2")
+}
+
+func waitForLeader(t *testing.T, transport *localTransport, exclude string) string {
+	deadline := time.Now().Add(20 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range transport.Nodes() {
+			if status := node.Status(); status.Role == "leader" && status.ID != exclude {
+				return status.ID
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("Cluster did not elect a leader")
+	return ""
+}
+
+// waitForValue waits until all stores contain the
+// key-value pair. An empty value means that the key
+// must not exist.
+func waitForValue(t *testing.T, stores map[string]*mem.Store, key, value string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var done = true
+		for _, store := range stores {
+			v, err := store.Get(key)
+			if err != nil && err != kes.ErrKeyNotFound {
+				t.Fatalf("Failed to fetch '%s': %v", key, err)
+			}
+			if v != value {
+				done = false
+			}
+		}
+		if done {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Not all nodes have applied '%s'", key)
+}
+
+var errUnreachable = errors.New("cluster: peer is not reachable")
+
+// localTransport delivers messages to nodes within
+// the same process.
+type localTransport struct {
+	lock  sync.Mutex
+	nodes map[string]*Node
+}
+
+func (t *localTransport) Add(node *Node) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.nodes[node.ID] = node
+}
+
+func (t *localTransport) Remove(id string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.nodes, id)
+}
+
+func (t *localTransport) Node(id string) *Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.nodes[id]
+}
+
+func (t *localTransport) Nodes() []*Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var nodes []*Node
+	for _, node := range t.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (t *localTransport) Follower(leader string) string {
+	for _, node := range t.Nodes() {
+		if node.ID != leader {
+			return node.ID
+		}
+	}
+	return ""
+}
+
+func (t *localTransport) RequestVote(ctx context.Context, peer Peer, req VoteRequest) (VoteResponse, error) {
+	node := t.Node(peer.ID)
+	if node == nil {
+		return VoteResponse{}, errUnreachable
+	}
+	return node.RequestVote(req)
+}
+
+func (t *localTransport) AppendEntries(ctx context.Context, peer Peer, req AppendRequest) (AppendResponse, error) {
+	node := t.Node(peer.ID)
+	if node == nil {
+		return AppendResponse{}, errUnreachable
+	}
+	return node.AppendEntries(req)
+}
+
+func (t *localTransport) Propose(ctx context.Context, peer Peer, cmd Command) error {
+	node := t.Node(peer.ID)
+	if node == nil {
+		return errUnreachable
+	}
+	return node.Propose(ctx, cmd)
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// proposeTimeout is the max. time a write waits for
+// the cluster to commit and apply it.
+const proposeTimeout = 10 * time.Second
+
+// Remote is a secret.Remote that replicates all writes
+// through the cluster. Reads are served by the local
+// Remote store.
+//
+// The Apply function of the Node must apply the committed
+// commands to the local Remote store.
+type Remote struct {
+	Node   *Node
+	Remote secret.Remote
+}
+
+var _ secret.Remote = (*Remote)(nil)
+
+// Create replicates the key-value pair if and only if
+// no entry with the key exists. Otherwise, it returns
+// kes.ErrKeyExists.
+func (r *Remote) Create(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), proposeTimeout)
+	defer cancel()
+
+	return r.Node.Propose(ctx, Command{Op: OpCreate, Key: key, Value: value})
+}
+
+// Delete removes the entry with the key on all
+// cluster nodes.
+func (r *Remote) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), proposeTimeout)
+	defer cancel()
+
+	return r.Node.Propose(ctx, Command{Op: OpDelete, Key: key})
+}
+
+// Get returns the value associated with the key from
+// the local Remote store.
+func (r *Remote) Get(key string) (string, error) { return r.Remote.Get(key) }
+
+// List returns the names of all entries at the local
+// Remote store. It returns secret.ErrListNotSupported
+// if the local Remote store does not implement
+// secret.Lister.
+func (r *Remote) List() ([]string, error) {
+	lister, ok := r.Remote.(secret.Lister)
+	if !ok {
+		return nil, secret.ErrListNotSupported
+	}
+	return lister.List()
+}
+
+// ListPrefix returns the names of all entries at the
+// local Remote store that start with the prefix. It
+// returns secret.ErrListNotSupported if the local Remote
+// store cannot list its entries.
+func (r *Remote) ListPrefix(prefix string) ([]string, error) {
+	return secret.ListPrefix(r.Remote, prefix)
+}
+
+// CreatedAt returns the point in time when the entry
+// has been created at the local Remote store. It returns
+// secret.ErrStatNotSupported if the local Remote store
+// does not implement secret.Stater.
+func (r *Remote) CreatedAt(key string) (time.Time, error) {
+	stater, ok := r.Remote.(secret.Stater)
+	if !ok {
+		return time.Time{}, secret.ErrStatNotSupported
+	}
+	return stater.CreatedAt(key)
+}
+
+// Status returns an error if the local Remote store is
+// not available or if the cluster has no leader.
+func (r *Remote) Status() error {
+	if checker, ok := r.Remote.(secret.StatusChecker); ok {
+		if err := checker.Status(); err != nil {
+			return err
+		}
+	} else if _, err := r.Remote.Get(secret.ReservedName); err != nil && err != kes.ErrKeyNotFound {
+		return err
+	}
+	if r.Node.Status().Leader == "" {
+		return ErrNoLeader
+	}
+	return nil
+}
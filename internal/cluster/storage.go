@@ -0,0 +1,162 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	stateFile = "state.json"
+	logFile   = "log.json"
+)
+
+// storage persists the Raft state - the current term
+// and vote - and the log of a node.
+//
+// The log is stored as one JSON-encoded entry per line.
+type storage struct {
+	dir string
+	log *os.File
+}
+
+type persistentState struct {
+	Term uint64 `json:"term"`
+	Vote string `json:"vote"`
+}
+
+// openStorage opens the storage within dir and returns
+// the persisted Raft state and log.
+func openStorage(dir string) (*storage, uint64, string, []Entry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, 0, "", nil, err
+	}
+
+	var state persistentState
+	data, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, "", nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &state); err != nil {
+			return nil, 0, "", nil, err
+		}
+	}
+
+	var entries []Entry
+	data, err = ioutil.ReadFile(filepath.Join(dir, logFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, "", nil, err
+	}
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break // A partially written entry - e.g. due to a crash
+		}
+
+		var entry Entry
+		if err = json.Unmarshal(data[:i], &entry); err != nil {
+			return nil, 0, "", nil, err
+		}
+		entries = append(entries, entry)
+		data = data[i+1:]
+	}
+
+	s := &storage{dir: dir}
+	if err = s.Rewrite(entries); err != nil {
+		return nil, 0, "", nil, err
+	}
+	return s, state.Term, state.Vote, entries, nil
+}
+
+// SetState persists the current term and vote.
+func (s *storage) SetState(term uint64, vote string) error {
+	data, err := json.Marshal(persistentState{Term: term, Vote: vote})
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, stateFile), data)
+}
+
+// Append appends the entries to the log.
+func (s *storage) Append(entries ...Entry) error {
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+	if _, err := s.log.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// Rewrite replaces the entire log with the
+// given entries.
+func (s *storage) Rewrite(entries []Entry) error {
+	filename := filepath.Join(s.dir, logFile)
+
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+	if err := writeFile(filename, buffer.Bytes()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log = file
+	return nil
+}
+
+// Close closes the log file.
+func (s *storage) Close() error {
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+// writeFile replaces the file atomically with
+// the given data.
+func writeFile(filename string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+)
+
+// HTTPTransport sends Raft messages to the cluster
+// API of other KES servers.
+type HTTPTransport struct {
+	// Client is the HTTP client used to send requests
+	// to the peers. Its TLS configuration must present
+	// the certificate of this node as client certificate.
+	Client *http.Client
+}
+
+var _ Transport = (*HTTPTransport)(nil)
+
+// RequestVote sends the vote request to the peer.
+func (t *HTTPTransport) RequestVote(ctx context.Context, peer Peer, req VoteRequest) (VoteResponse, error) {
+	var resp VoteResponse
+	if err := t.send(ctx, peer, "/v1/cluster/vote", req, &resp); err != nil {
+		return VoteResponse{}, err
+	}
+	return resp, nil
+}
+
+// AppendEntries sends the append request to the peer.
+func (t *HTTPTransport) AppendEntries(ctx context.Context, peer Peer, req AppendRequest) (AppendResponse, error) {
+	var resp AppendResponse
+	if err := t.send(ctx, peer, "/v1/cluster/append", req, &resp); err != nil {
+		return AppendResponse{}, err
+	}
+	return resp, nil
+}
+
+// Propose forwards the command to the peer.
+func (t *HTTPTransport) Propose(ctx context.Context, peer Peer, cmd Command) error {
+	return t.send(ctx, peer, "/v1/cluster/propose", cmd, nil)
+}
+
+func (t *HTTPTransport) send(ctx context.Context, peer Peer, path string, body, response interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(peer.Addr, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return parseError(resp)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxBatchSize)).Decode(response)
+}
+
+// parseError turns an error response of a peer into
+// a kes.Error such that forwarded writes fail with the
+// same error - e.g. kes.ErrKeyExists - as on the leader.
+func parseError(resp *http.Response) error {
+	const MaxSize = 1 << 20
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxSize))
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &response) == nil {
+		return kes.NewError(resp.StatusCode, response.Message)
+	}
+	return kes.NewError(resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,437 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package enclave implements isolated KES "virtual servers"
+// that are served by a single KES server process.
+//
+// Each enclave has its own root identity, policies, identity
+// assignments and keys. The entries of an enclave are stored
+// at the server's Remote store under the prefix:
+//  .enclaves/<enclave-name>/
+package enclave
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/watch"
+)
+
+var (
+	// ErrNotFound is returned when an enclave
+	// does not exist.
+	ErrNotFound = kes.ErrEnclaveNotFound
+
+	// ErrExists is returned when an enclave with
+	// the same name already exists.
+	ErrExists = kes.ErrEnclaveExists
+
+	errInvalidName = kes.NewError(http.StatusBadRequest, "invalid enclave name")
+	errInvalidRoot = kes.NewError(http.StatusBadRequest, "invalid enclave root identity")
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// Enclave is an isolated KES "virtual server" with its
+// own root identity, policies and keys.
+type Enclave struct {
+	// Name is the name of the enclave.
+	Name string
+
+	// Root is the root identity of the enclave.
+	Root kes.Identity
+
+	// Store is the secret store of the enclave.
+	Store *secret.Store
+
+	// Roles are the policies and identity
+	// assignments of the enclave.
+	Roles *auth.Roles
+
+	// Handler serves the API requests sent
+	// to the enclave.
+	Handler http.Handler
+
+	stopGC context.CancelFunc
+}
+
+// Info describes an enclave.
+type Info struct {
+	Name string       `json:"name"`
+	Root kes.Identity `json:"root"`
+}
+
+// Manager creates, loads and deletes enclaves.
+type Manager struct {
+	// Remote is the Remote store of the server. The
+	// Manager stores the enclaves and their entries
+	// at the Remote store.
+	Remote secret.Remote
+
+	// KMS is an optional KMS used by the secret
+	// store of each enclave. See: secret.Store
+	KMS secret.KMS
+
+	// Rand is an optional source of random bytes
+	// used by the secret store of each enclave to
+	// generate keys. See: secret.Store
+	Rand io.Reader
+
+	// HybridKey is an optional key pair used by the
+	// secret store of each enclave for the post-quantum
+	// hybrid wrapping. See: secret.Store
+	HybridKey *secret.HybridKey
+
+	// Identify computes the identity of a client
+	// certificate. See: auth.Roles
+	Identify auth.IdentityFunc
+
+	// Authorizer is an optional authorization hook
+	// that applies to the requests sent to each
+	// enclave. See: auth.Roles
+	Authorizer auth.Authorizer
+
+	// CacheExpiry and CacheUnusedExpiry are the
+	// cache expiry durations of the secret store
+	// of each enclave. See: secret.Store.StartGC
+	CacheExpiry       time.Duration
+	CacheUnusedExpiry time.Duration
+
+	// NewHandler returns the http.Handler that serves
+	// the API requests sent to the given enclave.
+	NewHandler func(*Enclave) http.Handler
+
+	ctx      context.Context
+	saveLock sync.Mutex
+	lock     sync.RWMutex
+	enclaves map[string]*Enclave
+}
+
+// Load loads all enclaves from the Remote store. The ctx
+// controls the lifetime of the enclaves' cache garbage
+// collection. Load must be called before any other method.
+func (m *Manager) Load(ctx context.Context) error {
+	m.lock.Lock()
+	m.ctx = ctx
+	m.lock.Unlock()
+
+	return m.Reload(secret.ReservedEnclavesName)
+}
+
+// Get returns the enclave with the given name,
+// if it exists.
+func (m *Manager) Get(name string) (*Enclave, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	enclave, ok := m.enclaves[name]
+	return enclave, ok
+}
+
+// List returns the name and root identity of
+// all enclaves sorted by name.
+func (m *Manager) List() []Info {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	infos := make([]Info, 0, len(m.enclaves))
+	for _, enclave := range m.enclaves {
+		infos = append(infos, Info{Name: enclave.Name, Root: enclave.Root})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Create creates a new enclave with the given name and
+// root identity. It returns ErrExists if an enclave with
+// the same name already exists.
+func (m *Manager) Create(name string, root kes.Identity) error {
+	if !validName.MatchString(name) {
+		return errInvalidName
+	}
+	if root.IsUnknown() {
+		return errInvalidRoot
+	}
+
+	m.saveLock.Lock()
+	defer m.saveLock.Unlock()
+
+	m.lock.Lock()
+	if _, ok := m.enclaves[name]; ok {
+		m.lock.Unlock()
+		return ErrExists
+	}
+	enclave, err := m.newEnclave(name, root)
+	if err != nil {
+		m.lock.Unlock()
+		return err
+	}
+	if m.enclaves == nil {
+		m.enclaves = map[string]*Enclave{}
+	}
+	m.enclaves[name] = enclave
+	m.lock.Unlock()
+
+	if err = m.save(); err != nil {
+		m.lock.Lock()
+		delete(m.enclaves, name)
+		m.lock.Unlock()
+		enclave.stopGC()
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the enclave with the given name
+// and, if the Remote store can list its entries,
+// all keys and policies of the enclave.
+//
+// It returns ErrNotFound if no such enclave exists.
+func (m *Manager) Delete(name string) error {
+	m.saveLock.Lock()
+	defer m.saveLock.Unlock()
+
+	m.lock.Lock()
+	enclave, ok := m.enclaves[name]
+	if !ok {
+		m.lock.Unlock()
+		return ErrNotFound
+	}
+	delete(m.enclaves, name)
+	m.lock.Unlock()
+
+	if err := m.save(); err != nil {
+		m.lock.Lock()
+		m.enclaves[name] = enclave
+		m.lock.Unlock()
+		return err
+	}
+	enclave.stopGC()
+
+	// Once the enclave has been removed, its entries
+	// are not accessible anymore. Therefore, we remove
+	// them on a best-effort basis.
+	prefix := prefixOf(name)
+	if lister, ok := m.Remote.(secret.Lister); ok {
+		if names, err := lister.List(); err == nil {
+			for _, n := range names {
+				if strings.HasPrefix(n, prefix) {
+					m.Remote.Delete(n)
+				}
+			}
+		}
+	}
+	remote := &Remote{Remote: m.Remote, Prefix: prefix}
+	secret.DeleteReplaced(remote, secret.ReservedUsageName)
+	secret.DeleteReplaced(remote, secret.ReservedAliasName)
+	secret.DeleteReplaced(remote, secret.ReservedStateName)
+	return secret.DeleteReplaced(remote, secret.ReservedName)
+}
+
+// SaveUsage writes the key usage of all enclaves
+// to the Remote store. See: secret.Store.SaveUsage
+func (m *Manager) SaveUsage() error {
+	m.lock.RLock()
+	enclaves := make([]*Enclave, 0, len(m.enclaves))
+	for _, enclave := range m.enclaves {
+		enclaves = append(enclaves, enclave)
+	}
+	m.lock.RUnlock()
+
+	for _, enclave := range enclaves {
+		if err := enclave.Store.SaveUsage(); err != nil {
+			return fmt.Errorf("enclave: failed to save key usage of '%s': %v", enclave.Name, err)
+		}
+	}
+	return nil
+}
+
+// DeletePending deletes the secrets of all enclaves whose
+// deletion window has passed at now. It holds the lock of
+// a secret within its enclave, if lock is not nil, while
+// deleting the secret. See: secret.Store.DeletePending
+func (m *Manager) DeletePending(ctx context.Context, now time.Time, lock func(enclave, name string) sync.Locker) error {
+	m.lock.RLock()
+	enclaves := make([]*Enclave, 0, len(m.enclaves))
+	for _, enclave := range m.enclaves {
+		enclaves = append(enclaves, enclave)
+	}
+	m.lock.RUnlock()
+
+	for _, enclave := range enclaves {
+		var enclaveLock func(string) sync.Locker
+		if lock != nil {
+			name := enclave.Name
+			enclaveLock = func(key string) sync.Locker { return lock(name, key) }
+		}
+		if _, err := enclave.Store.DeletePending(ctx, now, enclaveLock); err != nil {
+			return fmt.Errorf("enclave: failed to delete pending keys of '%s': %v", enclave.Name, err)
+		}
+	}
+	return nil
+}
+
+// Reload updates the enclaves after the Remote entry with
+// the given name has been created by another KES server -
+// e.g. by another node of a cluster.
+//
+// It reloads the enclaves if the entry is the enclave list,
+// and the policies, key aliases or key states of an enclave
+// if the entry holds them. Otherwise, it does nothing.
+func (m *Manager) Reload(name string) error {
+	if name != secret.ReservedEnclavesName {
+		if enclave, key, ok := m.lookup(name); ok {
+			switch key {
+			case secret.ReservedName:
+				return enclave.Roles.Reload()
+			case secret.ReservedAliasName:
+				return enclave.Store.LoadAliases()
+			case secret.ReservedStateName:
+				return enclave.Store.LoadStates()
+			}
+		}
+		return nil
+	}
+
+	infos, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	enclaves := make(map[string]*Enclave, len(infos))
+	for _, info := range infos {
+		if enclave, ok := m.enclaves[info.Name]; ok && enclave.Root == info.Root {
+			enclaves[info.Name] = enclave
+			continue
+		}
+		enclave, err := m.newEnclave(info.Name, info.Root)
+		if err != nil {
+			return err
+		}
+		enclaves[info.Name] = enclave
+	}
+	for name, enclave := range m.enclaves {
+		if enclaves[name] != enclave {
+			enclave.stopGC()
+		}
+	}
+	m.enclaves = enclaves
+	return nil
+}
+
+// Evict removes the Remote entry with the given name from
+// the cache of the enclave it belongs to, if any. It should
+// be called when the entry has been deleted by another KES
+// server. See: secret.Store.Evict
+func (m *Manager) Evict(name string) {
+	if enclave, key, ok := m.lookup(name); ok {
+		enclave.Store.Evict(key)
+	}
+}
+
+// lookup returns the enclave the Remote entry with the
+// given name belongs to and the name of the entry within
+// the enclave.
+func (m *Manager) lookup(name string) (*Enclave, string, bool) {
+	if !strings.HasPrefix(name, secret.ReservedEnclavePrefix) {
+		return nil, "", false
+	}
+	name = strings.TrimPrefix(name, secret.ReservedEnclavePrefix)
+	i := strings.IndexByte(name, '/')
+	if i < 0 {
+		return nil, "", false
+	}
+	enclave, ok := m.Get(name[:i])
+	return enclave, name[i+1:], ok
+}
+
+// newEnclave returns a new enclave with the given name and
+// root identity and loads its policies, key usage, key
+// aliases and key states from the Remote store.
+func (m *Manager) newEnclave(name string, root kes.Identity) (*Enclave, error) {
+	if m.ctx == nil {
+		return nil, errors.New("enclave: manager has not been loaded")
+	}
+	remote := &Remote{Remote: m.Remote, Prefix: prefixOf(name)}
+	enclave := &Enclave{
+		Name:  name,
+		Root:  root,
+		Store: &secret.Store{Remote: remote, KMS: m.KMS, Rand: m.Rand, HybridKey: m.HybridKey, Events: new(watch.Hub)},
+		Roles: &auth.Roles{
+			Root:       root,
+			Identify:   m.Identify,
+			Remote:     remote,
+			Authorizer: m.Authorizer,
+			Events:     new(watch.Hub),
+		},
+	}
+	if err := enclave.Roles.Load(); err != nil {
+		return nil, err
+	}
+	if err := enclave.Store.LoadUsage(); err != nil {
+		return nil, err
+	}
+	if err := enclave.Store.LoadAliases(); err != nil {
+		return nil, err
+	}
+	if err := enclave.Store.LoadStates(); err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, enclave.stopGC = context.WithCancel(m.ctx)
+	enclave.Store.StartGC(ctx, m.CacheExpiry, m.CacheUnusedExpiry)
+
+	if m.NewHandler != nil {
+		enclave.Handler = m.NewHandler(enclave)
+	}
+	return enclave, nil
+}
+
+// load reads the names and root identities of
+// all enclaves from the Remote store.
+func (m *Manager) load() ([]Info, error) {
+	value, err := secret.Load(m.Remote, secret.ReservedEnclavesName)
+	if err == kes.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	if err = json.Unmarshal([]byte(value), &infos); err != nil {
+		return nil, errors.New("enclave: persisted enclaves are malformed")
+	}
+	return infos, nil
+}
+
+// save writes the names and root identities of
+// all enclaves to the Remote store.
+//
+// It replaces the entry via secret.Replace such that
+// no enclave gets lost if save fails.
+func (m *Manager) save() error {
+	value, err := json.Marshal(m.List())
+	if err != nil {
+		return err
+	}
+	return secret.Replace(m.Remote, secret.ReservedEnclavesName, string(value))
+}
+
+func prefixOf(name string) string { return secret.ReservedEnclavePrefix + name + "/" }
@@ -0,0 +1,79 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package enclave
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	remote := &mem.Store{}
+	manager := &Manager{Remote: remote}
+	if err := manager.Load(ctx); err != nil {
+		t.Fatalf("Failed to load enclaves: %v", err)
+	}
+
+	const root = kes.Identity("3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22")
+	if err := manager.Create("tenant-1", root); err != nil {
+		t.Fatalf("Failed to create enclave: %v", err)
+	}
+	if err := manager.Create("tenant-1", root); err != ErrExists {
+		t.Fatalf("Created enclave twice: got %v - want %v", err, ErrExists)
+	}
+	if err := manager.Create("../tenant", root); err != errInvalidName {
+		t.Fatalf("Created enclave with invalid name: got %v - want %v", err, errInvalidName)
+	}
+
+	enclave, ok := manager.Get("tenant-1")
+	if !ok {
+		t.Fatal("Enclave does not exist")
+	}
+	if enclave.Roles.Root != root {
+		t.Fatalf("Invalid enclave root: got %v - want %v", enclave.Roles.Root, root)
+	}
+	if err := enclave.Store.Create(ctx, "my-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create enclave key: %v", err)
+	}
+	if _, err := remote.Get(".enclaves/tenant-1/my-key"); err != nil {
+		t.Fatalf("Enclave key is not stored under the enclave prefix: %v", err)
+	}
+	if _, err := remote.Get("my-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Enclave key is visible outside the enclave: %v", err)
+	}
+
+	// Another manager - e.g. on another cluster node -
+	// should see the same enclaves.
+	other := &Manager{Remote: remote}
+	if err := other.Load(ctx); err != nil {
+		t.Fatalf("Failed to load enclaves: %v", err)
+	}
+	if infos := other.List(); len(infos) != 1 || infos[0].Name != "tenant-1" || infos[0].Root != root {
+		t.Fatalf("Invalid enclaves: got %v", infos)
+	}
+
+	if err := manager.Delete("tenant-1"); err != nil {
+		t.Fatalf("Failed to delete enclave: %v", err)
+	}
+	if err := manager.Delete("tenant-1"); err != ErrNotFound {
+		t.Fatalf("Deleted enclave twice: got %v - want %v", err, ErrNotFound)
+	}
+	if _, err := remote.Get(".enclaves/tenant-1/my-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Enclave key has not been deleted: %v", err)
+	}
+	if err := other.Reload(secret.ReservedEnclavesName); err != nil {
+		t.Fatalf("Failed to reload enclaves: %v", err)
+	}
+	if _, ok := other.Get("tenant-1"); ok {
+		t.Fatal("Deleted enclave still exists after reload")
+	}
+}
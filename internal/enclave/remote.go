@@ -0,0 +1,81 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package enclave
+
+import (
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/secret"
+)
+
+// Remote is a secret.Remote that stores all entries
+// at an underlying Remote store under a common prefix.
+type Remote struct {
+	secret.Remote
+
+	// Prefix is prepended to the name of
+	// each entry.
+	Prefix string
+}
+
+var (
+	_ secret.Remote       = (*Remote)(nil)
+	_ secret.Lister       = (*Remote)(nil)
+	_ secret.PrefixLister = (*Remote)(nil)
+	_ secret.Stater       = (*Remote)(nil)
+)
+
+// Create creates the entry Prefix + key at the
+// underlying Remote store.
+func (r *Remote) Create(key, value string) error { return r.Remote.Create(r.Prefix+key, value) }
+
+// Delete deletes the entry Prefix + key at the
+// underlying Remote store.
+func (r *Remote) Delete(key string) error { return r.Remote.Delete(r.Prefix + key) }
+
+// Get returns the value of the entry Prefix + key
+// from the underlying Remote store.
+func (r *Remote) Get(key string) (string, error) { return r.Remote.Get(r.Prefix + key) }
+
+// CreatedAt returns the point in time when the entry
+// Prefix + key has been created at the underlying Remote
+// store.
+//
+// It returns secret.ErrStatNotSupported if the underlying
+// Remote store does not implement secret.Stater.
+func (r *Remote) CreatedAt(key string) (time.Time, error) {
+	stater, ok := r.Remote.(secret.Stater)
+	if !ok {
+		return time.Time{}, secret.ErrStatNotSupported
+	}
+	return stater.CreatedAt(r.Prefix + key)
+}
+
+// List returns the names of all entries of the
+// underlying Remote store with the prefix - but
+// without the prefix itself.
+//
+// It returns secret.ErrListNotSupported if the
+// underlying Remote store cannot list its entries.
+func (r *Remote) List() ([]string, error) { return r.ListPrefix("") }
+
+// ListPrefix returns the names of all entries of the
+// underlying Remote store with the Prefix + prefix - but
+// without the Prefix itself.
+//
+// It returns secret.ErrListNotSupported if the
+// underlying Remote store cannot list its entries.
+func (r *Remote) ListPrefix(prefix string) ([]string, error) {
+	entries, err := secret.ListPrefix(r.Remote, r.Prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range entries {
+		names = append(names, strings.TrimPrefix(name, r.Prefix))
+	}
+	return names, nil
+}
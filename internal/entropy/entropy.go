@@ -0,0 +1,132 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package entropy implements a source of random bytes
+// that mixes the operating system RNG with hardware RNGs
+// and continuously tests the health of each of them.
+//
+// The health tests are the repetition count test and
+// the adaptive proportion test of NIST SP 800-90B. Once
+// a test fails, the source stops producing random bytes
+// such that no keys are generated from bad entropy.
+package entropy
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/minio/kes"
+)
+
+// ErrHealthTest is returned by a Source once the health
+// test of one of its entropy sources has failed.
+var ErrHealthTest = kes.NewError(http.StatusServiceUnavailable, "entropy source failed health test")
+
+// Source is an io.Reader that returns random bytes. It
+// XORs the bytes read from the operating system RNG -
+// i.e. getrandom on Linux - with the bytes read from
+// the hardware RNGs, if any.
+//
+// The raw output of each RNG is checked by its own
+// health tests. Once a test fails, Read returns
+// ErrHealthTest - until the server gets restarted.
+type Source struct {
+	lock    sync.Mutex
+	sources []io.Reader
+	tests   []*healthTest
+	names   []string
+	err     error
+}
+
+var _ io.Reader = (*Source)(nil)
+
+// NewSource returns a new Source that mixes the operating
+// system RNG with the hardware RNG hwrng - e.g. /dev/hwrng
+// - if not nil, and the RDSEED CPU instruction if rdseed
+// is true.
+//
+// It runs the start-up health tests of all RNGs and returns
+// an error if a test fails or if the CPU does not support
+// RDSEED.
+func NewSource(hwrng io.Reader, rdseed bool) (*Source, error) {
+	s := &Source{}
+	s.add("getrandom", rand.Reader)
+	if hwrng != nil {
+		s.add("hwrng", hwrng)
+	}
+	if rdseed {
+		if !hasRDSEED() {
+			return nil, errors.New("entropy: CPU does not support RDSEED")
+		}
+		s.add("rdseed", rdseedReader{})
+	}
+
+	// SP 800-90B requires the start-up tests to run on
+	// at least 1024 consecutive samples. These samples
+	// are discarded.
+	if _, err := s.Read(make([]byte, 1024)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Read fills p with random bytes. It returns an error
+// if an RNG fails or its health test fails.
+func (s *Source) Read(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+	buf := make([]byte, len(p))
+	for i, source := range s.sources {
+		if _, err := io.ReadFull(source, buf); err != nil {
+			return 0, s.fail(p, fmt.Errorf("entropy: failed to read from %s: %v", s.names[i], err))
+		}
+		if !s.tests[i].Test(buf) {
+			return 0, s.fail(p, ErrHealthTest)
+		}
+		if i == 0 {
+			copy(p, buf)
+			continue
+		}
+		for j := range p {
+			p[j] ^= buf[j]
+		}
+	}
+	return len(p), nil
+}
+
+// Err returns ErrHealthTest if the health test of
+// an RNG has failed. Otherwise, it returns nil.
+func (s *Source) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// add adds the RNG with the given name to the Source.
+func (s *Source) add(name string, source io.Reader) {
+	s.sources = append(s.sources, source)
+	s.tests = append(s.tests, &healthTest{})
+	s.names = append(s.names, name)
+}
+
+// fail wipes p and returns err. If err is ErrHealthTest,
+// all subsequent reads fail. The caller must hold the
+// lock.
+func (s *Source) fail(p []byte, err error) error {
+	for i := range p {
+		p[i] = 0
+	}
+	if err == ErrHealthTest {
+		s.err = err
+	}
+	return err
+}
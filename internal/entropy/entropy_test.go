@@ -0,0 +1,102 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package entropy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// biasedReader is an io.Reader that returns a stream
+// without repetitions in which 0 occurs every 16th byte.
+type biasedReader struct{ n int }
+
+func (r *biasedReader) Read(p []byte) (int, error) {
+	for i := range p {
+		if r.n%16 == 0 {
+			p[i] = 0
+		} else {
+			p[i] = byte(r.n)
+		}
+		r.n++
+	}
+	return len(p), nil
+}
+
+var healthTests = []struct {
+	Source io.Reader
+	Pass   bool
+}{
+	{Source: rand.Reader, Pass: true},                                        // 0
+	{Source: bytes.NewReader(make([]byte, 4096)), Pass: false},               // 1
+	{Source: &biasedReader{}, Pass: false},                                   // 2
+	{Source: bytes.NewReader(bytes.Repeat([]byte{1, 2}, 2048)), Pass: false}, // 3
+}
+
+func TestHealthTest(t *testing.T) {
+	for i, test := range healthTests {
+		var (
+			health healthTest
+			buf    = make([]byte, 4096)
+		)
+		if _, err := io.ReadFull(test.Source, buf); err != nil {
+			t.Fatalf("Test %d: failed to read samples: %v", i, err)
+		}
+		if pass := health.Test(buf); pass != test.Pass {
+			t.Fatalf("Test %d: got %v - want %v", i, pass, test.Pass)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	hwrng := &biasedReader{}
+	if _, err := NewSource(hwrng, false); err != ErrHealthTest {
+		t.Fatalf("Created source with failing hardware RNG: %v", err)
+	}
+
+	source, err := NewSource(rand.Reader, false)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	key := make([]byte, 32)
+	if _, err = io.ReadFull(source, key); err != nil {
+		t.Fatalf("Failed to read random bytes: %v", err)
+	}
+	if err = source.Err(); err != nil {
+		t.Fatalf("Source reports health test failure: %v", err)
+	}
+
+	source.tests[1].repetitions = rctCutoff // Simulate a stuck hardware RNG
+	source.tests[1].last = 0
+	source.sources[1] = bytes.NewReader(make([]byte, 64))
+	if _, err = source.Read(key); err != ErrHealthTest {
+		t.Fatalf("Source returned random bytes of a stuck RNG: %v", err)
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Fatal("Source did not wipe the buffer after a health test failure")
+	}
+	source.sources[1] = rand.Reader
+	if _, err = source.Read(key); err != ErrHealthTest {
+		t.Fatalf("Source recovered from a health test failure: %v", err)
+	}
+	if err = source.Err(); err != ErrHealthTest {
+		t.Fatalf("Source does not report health test failure: %v", err)
+	}
+}
+
+func TestRDSEED(t *testing.T) {
+	if !hasRDSEED() {
+		t.Skip("CPU does not support RDSEED")
+	}
+	source, err := NewSource(nil, true)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if _, err = source.Read(make([]byte, 4096)); err != nil {
+		t.Fatalf("Failed to read random bytes: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package entropy
+
+// The cutoff values of the health tests. They assume that
+// each byte - i.e. sample - has 8 bits of min-entropy, as
+// the RNGs return conditioned output, and a false positive
+// probability of α = 2^-40. See: NIST SP 800-90B, 4.4
+const (
+	rctCutoff = 6   // C = 1 + ⌈40 / 8⌉
+	aptWindow = 512 // W for non-binary samples
+	aptCutoff = 19  // C = 1 + CRITBINOM(512, 2^-8, 1 - 2^-40)
+)
+
+// healthTest implements the repetition count test (RCT)
+// and the adaptive proportion test (APT) of SP 800-90B.
+//
+// The RCT detects an RNG that is stuck at one value. The
+// APT detects a large loss of entropy - e.g. an RNG that
+// returns one value much more often than the others.
+type healthTest struct {
+	started bool
+
+	last        byte // RCT: The most recent sample
+	repetitions int  // RCT: The number of repetitions of last
+
+	first byte // APT: The first sample of the current window
+	count int  // APT: The number of occurrences of first
+	n     int  // APT: The number of samples in the current window
+}
+
+// Test runs the health tests on the samples and reports
+// whether all samples have passed them.
+func (t *healthTest) Test(samples []byte) bool {
+	for _, b := range samples {
+		if !t.started {
+			t.started = true
+			t.last, t.repetitions = b, 1
+			t.first, t.count, t.n = b, 1, 1
+			continue
+		}
+
+		if b == t.last {
+			t.repetitions++
+			if t.repetitions >= rctCutoff {
+				return false
+			}
+		} else {
+			t.last, t.repetitions = b, 1
+		}
+
+		if t.n == aptWindow {
+			t.first, t.count, t.n = b, 1, 1
+			continue
+		}
+		t.n++
+		if b == t.first {
+			t.count++
+			if t.count >= aptCutoff {
+				return false
+			}
+		}
+	}
+	return true
+}
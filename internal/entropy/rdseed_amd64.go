@@ -0,0 +1,46 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package entropy
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/sys/cpu"
+)
+
+func hasRDSEED() bool { return cpu.X86.HasRDSEED }
+
+// rdseed executes the RDSEED instruction. It returns
+// false if no random value has been available.
+func rdseed() (seed uint64, ok bool)
+
+// rdseedReader is an io.Reader that returns random
+// bytes produced by the RDSEED instruction.
+type rdseedReader struct{}
+
+func (rdseedReader) Read(p []byte) (int, error) {
+	// RDSEED fails when the entropy of the CPU RNG has
+	// been exhausted temporarily. Intel recommends to
+	// retry with a pause in between.
+	const MaxRetries = 1000
+
+	var buf [8]byte
+	for n := 0; n < len(p); {
+		var (
+			seed uint64
+			ok   bool
+		)
+		for i := 0; i < MaxRetries && !ok; i++ {
+			seed, ok = rdseed()
+		}
+		if !ok {
+			return n, errors.New("RDSEED did not return a random value")
+		}
+		binary.LittleEndian.PutUint64(buf[:], seed)
+		n += copy(p[n:], buf[:])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,12 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func rdseed() (seed uint64, ok bool)
+TEXT ·rdseed(SB), NOSPLIT, $0-9
+	BYTE $0x48; BYTE $0x0F; BYTE $0xC7; BYTE $0xF8 // RDSEED AX
+	SETCS ok+8(FP)
+	MOVQ  AX, seed+0(FP)
+	RET
@@ -0,0 +1,21 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !amd64
+
+package entropy
+
+import "errors"
+
+func hasRDSEED() bool {
+	// We only support RDSEED on
+	// amd64 at the moment.
+	return false
+}
+
+type rdseedReader struct{}
+
+func (rdseedReader) Read([]byte) (int, error) {
+	return 0, errors.New("RDSEED is not supported")
+}
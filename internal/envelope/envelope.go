@@ -0,0 +1,169 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package envelope implements an envelope encryption
+// format for data streams.
+//
+// The data is encrypted with a data encryption key (DEK)
+// that is itself encrypted by a KES key. An envelope
+// consists of a single-line JSON header followed by the
+// encrypted data:
+//   {"version":1,"key":"my-key","cipher":"AES-256-GCM","dek":"...","nonce":"..."}\n
+//   <ciphertext>
+//
+// The data is split into chunks that are encrypted and
+// authenticated individually (DARE). The header is bound
+// to every chunk as associated data such that it cannot
+// be modified.
+package envelope
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/secure-io/sio-go"
+	"github.com/secure-io/sio-go/sioutil"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The ciphers that can encrypt an envelope.
+const (
+	AES256GCM        = "AES-256-GCM"
+	ChaCha20Poly1305 = "ChaCha20-Poly1305"
+)
+
+// maxHeaderSize is the max. size of an envelope header.
+const maxHeaderSize = 16 * 1024
+
+var errMalformedHeader = errors.New("envelope: malformed header")
+
+// Header is the header of an envelope.
+type Header struct {
+	Version int    `json:"version"`
+	Key     string `json:"key"`    // The name of the KES key that encrypted the DEK
+	Cipher  string `json:"cipher"` // The cipher that encrypts the data
+	DEK     []byte `json:"dek"`    // The encrypted DEK
+	Nonce   []byte `json:"nonce"`
+
+	raw []byte // The encoded header - including the newline
+}
+
+// NewWriter writes an envelope header to w and returns an
+// io.WriteCloser that encrypts everything written to it
+// with the plaintext DEK. The caller must close the returned
+// io.WriteCloser to complete the envelope. Closing it also
+// closes w if w implements io.Closer. The ciphertext is
+// the DEK encrypted by the KES key with the given name.
+//
+// The plaintext DEK must be 32 bytes long. The envelope
+// uses AES-256-GCM if the CPU provides an AES hardware
+// implementation and ChaCha20-Poly1305 otherwise.
+func NewWriter(w io.Writer, key string, plaintext, ciphertext []byte) (io.WriteCloser, error) {
+	header := Header{
+		Version: 1,
+		Key:     key,
+		Cipher:  ChaCha20Poly1305,
+		DEK:     ciphertext,
+	}
+	if sioutil.NativeAES() {
+		header.Cipher = AES256GCM
+	}
+	stream, err := newStream(header.Cipher, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if header.Nonce, err = sioutil.Random(stream.NonceSize()); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	raw = append(raw, '\n')
+	if _, err = w.Write(raw); err != nil {
+		return nil, err
+	}
+	return stream.EncryptWriter(w, header.Nonce, raw), nil
+}
+
+// ReadHeader reads an envelope header from r. It returns
+// the header and an io.Reader for the encrypted data that
+// follows the header.
+func ReadHeader(r io.Reader) (*Header, io.Reader, error) {
+	br := bufio.NewReaderSize(r, maxHeaderSize)
+	raw, err := br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || err == io.EOF {
+		return nil, nil, errMalformedHeader
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var header Header
+	if err = json.Unmarshal(raw, &header); err != nil {
+		return nil, nil, errMalformedHeader
+	}
+	if header.Version != 1 {
+		return nil, nil, errors.New("envelope: unsupported version")
+	}
+	if header.Key == "" || len(header.DEK) == 0 {
+		return nil, nil, errMalformedHeader
+	}
+	header.raw = append([]byte(nil), raw...)
+	return &header, br, nil
+}
+
+// NewReader returns an io.Reader that decrypts the data
+// of the envelope read from r with the plaintext DEK. The
+// io.Reader returns an error if the data is not authentic
+// - e.g. because it has been modified or truncated.
+//
+// Data read before such an error must not be trusted.
+//
+// The header must have been read by ReadHeader.
+func (h *Header) NewReader(r io.Reader, plaintext []byte) (io.Reader, error) {
+	if h.raw == nil {
+		return nil, errors.New("envelope: header has not been read by ReadHeader")
+	}
+	stream, err := newStream(h.Cipher, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Nonce) != stream.NonceSize() {
+		return nil, errMalformedHeader
+	}
+	return stream.DecryptReader(r, h.Nonce, h.raw), nil
+}
+
+func newStream(name string, key []byte) (*sio.Stream, error) {
+	if len(key) != 32 {
+		return nil, errors.New("envelope: invalid key length")
+	}
+
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+	switch name {
+	case AES256GCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err != nil {
+			return nil, err
+		}
+		aead, err = cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(key)
+	default:
+		return nil, errors.New("envelope: unsupported cipher")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sio.NewStream(aead, sio.BufSize), nil
+}
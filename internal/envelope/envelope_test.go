@@ -0,0 +1,100 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package envelope
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+var envelopeTests = []struct {
+	Size int
+}{
+	{Size: 0},           // 0
+	{Size: 1},           // 1
+	{Size: 16 * 1024},   // 2
+	{Size: 16*1024 + 1}, // 3
+	{Size: 1 << 20},     // 4
+}
+
+func TestEnvelope(t *testing.T) {
+	dek := bytes.Repeat([]byte{1}, 32)
+	for i, test := range envelopeTests {
+		data := bytes.Repeat([]byte{'a'}, test.Size)
+
+		var envelope bytes.Buffer
+		w, err := NewWriter(&envelope, "my-key", dek, []byte("encrypted-dek"))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create envelope: %v", i, err)
+		}
+		if _, err = w.Write(data); err != nil {
+			t.Fatalf("Test %d: failed to encrypt data: %v", i, err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("Test %d: failed to close envelope: %v", i, err)
+		}
+
+		header, r, err := ReadHeader(bytes.NewReader(envelope.Bytes()))
+		if err != nil {
+			t.Fatalf("Test %d: failed to read header: %v", i, err)
+		}
+		if header.Key != "my-key" || !bytes.Equal(header.DEK, []byte("encrypted-dek")) {
+			t.Fatalf("Test %d: invalid header: got key '%s' and DEK '%s'", i, header.Key, header.DEK)
+		}
+		r, err = header.NewReader(r, dek)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt envelope: %v", i, err)
+		}
+		plaintext, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt data: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, data) {
+			t.Fatalf("Test %d: plaintext does not match data", i)
+		}
+	}
+}
+
+func TestEnvelopeNotAuthentic(t *testing.T) {
+	dek := bytes.Repeat([]byte{1}, 32)
+	data := bytes.Repeat([]byte{'a'}, 64*1024)
+
+	var envelope bytes.Buffer
+	w, err := NewWriter(&envelope, "my-key", dek, []byte("encrypted-dek"))
+	if err != nil {
+		t.Fatalf("Failed to create envelope: %v", err)
+	}
+	w.Write(data)
+	w.Close()
+
+	decrypt := func(envelope []byte) error {
+		header, r, err := ReadHeader(bytes.NewReader(envelope))
+		if err != nil {
+			return err
+		}
+		if r, err = header.NewReader(r, dek); err != nil {
+			return err
+		}
+		_, err = ioutil.ReadAll(r)
+		return err
+	}
+
+	modified := append([]byte(nil), envelope.Bytes()...)
+	modified[len(modified)/2] ^= 1
+	if err = decrypt(modified); err == nil {
+		t.Fatal("Decrypting a modified envelope should fail")
+	}
+
+	modified = bytes.Replace(envelope.Bytes(), []byte(`"key":"my-key"`), []byte(`"key":"my-kex"`), 1)
+	if err = decrypt(modified); err == nil {
+		t.Fatal("Decrypting an envelope with a modified header should fail")
+	}
+
+	truncated := envelope.Bytes()[:envelope.Len()-16*1024-16]
+	if err = decrypt(truncated); err == nil {
+		t.Fatal("Decrypting a truncated envelope should fail")
+	}
+}
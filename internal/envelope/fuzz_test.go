@@ -0,0 +1,52 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package envelope
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// FuzzReadHeader checks that reading and decrypting a
+// malformed or malicious envelope neither panics nor
+// hangs. Run it with:
+//   go test -run=^$ -fuzz=FuzzReadHeader ./internal/envelope
+func FuzzReadHeader(f *testing.F) {
+	dek := make([]byte, 32)
+
+	var buffer bytes.Buffer
+	w, err := NewWriter(&buffer, "my-key", dek, []byte("encrypted DEK"))
+	if err != nil {
+		f.Fatalf("Failed to create envelope: %v", err)
+	}
+	if _, err = io.WriteString(w, "Hello World"); err != nil {
+		f.Fatalf("Failed to write envelope: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		f.Fatalf("Failed to close envelope: %v", err)
+	}
+	f.Add(buffer.Bytes())
+	f.Add([]byte(`{"version":1,"key":"my-key","cipher":"AES-256-GCM","dek":"AA==","nonce":"AAAAAAAA"}` + "\n"))
+	f.Add([]byte(`{"version":1,"key":"my-key","cipher":"ChaCha20-Poly1305","dek":"AA==","nonce":""}` + "\n"))
+	f.Add([]byte(`{"version":2}` + "\n"))
+	f.Add([]byte("\n"))
+
+	f.Fuzz(func(t *testing.T, envelope []byte) {
+		header, body, err := ReadHeader(bytes.NewReader(envelope))
+		if err != nil {
+			return
+		}
+		plaintext, err := header.NewReader(body, dek)
+		if err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, plaintext) // Must not panic
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package errs wraps internal errors - e.g. a failed
+// request to a key store - into API errors with a stable
+// code while keeping the original error for logging.
+//
+// The server responds with the API error only. Its message
+// and code don't depend on the key store or KMS. The error
+// message of the wrapped error - e.g. the response of the
+// key store - is only written to the error log.
+package errs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/minio/kes"
+)
+
+// Error is an internal error of a kind of API error.
+//
+// An Error is the API error when inspected via errors.Is
+// or errors.As. For example:
+//   err := errs.New(kes.ErrBackendUnavailable, "vault: no connection")
+//   errors.Is(err, kes.ErrBackendUnavailable) // true
+//   errors.Is(err, kes.ErrUnavailable)        // true
+type Error struct {
+	Kind kes.Error // The API error - e.g. kes.ErrBackendFailure
+	Err  error     // The internal error
+}
+
+var _ error = (*Error)(nil)
+
+// New returns a new Error of the given kind with the
+// given error message.
+func New(kind kes.Error, msg string) error {
+	return &Error{Kind: kind, Err: errors.New(msg)}
+}
+
+// Errorf returns a new Error of the given kind. The format
+// and values are formatted like fmt.Errorf. In particular,
+// the %w verb wraps an error such that it can be unwrapped.
+func Errorf(kind kes.Error, format string, v ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, v...)}
+}
+
+// Error returns the message of the internal error.
+func (e *Error) Error() string { return e.Err.Error() }
+
+// Unwrap returns the internal error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether the target is the kind of e
+// or its error class.
+//
+// Is is used by errors.Is.
+func (e *Error) Is(target error) bool { return errors.Is(e.Kind, target) }
+
+// As sets target to the kind of e if target is
+// a *kes.Error.
+//
+// As is used by errors.As.
+func (e *Error) As(target interface{}) bool {
+	if t, ok := target.(*kes.Error); ok {
+		*t = e.Kind
+		return true
+	}
+	return false
+}
+
+// Kind returns the API error of err. If err is or wraps
+// a kes.Error, Kind returns it. Otherwise, it returns
+// kes.ErrInternal and false.
+func Kind(err error) (kes.Error, bool) {
+	var kind kes.Error
+	if errors.As(err, &kind) {
+		return kind, true
+	}
+	return kes.ErrInternal, false
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package errs
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestError(t *testing.T) {
+	err := Errorf(kes.ErrBackendFailure, "vault: failed to read 'my-key': %w", io.ErrUnexpectedEOF)
+	if msg := err.Error(); msg != "vault: failed to read 'my-key': unexpected EOF" {
+		t.Fatalf("Invalid error message: got '%s'", msg)
+	}
+	if !errors.Is(err, kes.ErrBackendFailure) {
+		t.Fatal("Error is not of its kind")
+	}
+	if !errors.Is(err, kes.ErrBadGateway) {
+		t.Fatal("Error is not of the error class of its kind")
+	}
+	if errors.Is(err, kes.ErrBackendUnavailable) {
+		t.Fatal("Error is of a different kind")
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("Error does not wrap the internal error")
+	}
+
+	kind, ok := Kind(err)
+	if !ok || kind != kes.ErrBackendFailure {
+		t.Fatalf("Invalid kind: got '%v' - want '%v'", kind, kes.ErrBackendFailure)
+	}
+	if kind.Code() != "backend_failure" {
+		t.Fatalf("Invalid code: got '%s' - want '%s'", kind.Code(), "backend_failure")
+	}
+}
+
+func TestKind(t *testing.T) {
+	if kind, ok := Kind(kes.ErrKeyNotFound); !ok || kind != kes.ErrKeyNotFound {
+		t.Fatalf("Invalid kind: got '%v' - want '%v'", kind, kes.ErrKeyNotFound)
+	}
+	if kind, ok := Kind(errors.New("internal error")); ok || kind != kes.ErrInternal {
+		t.Fatalf("Invalid kind: got '%v' - want '%v'", kind, kes.ErrInternal)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package escrow exports keys to offline custodians for
+// regulatory key escrow.
+//
+// The keys are encrypted with a random wrapping key that
+// is split into key shares - one per custodian. Each key
+// share is encrypted to the X25519 public key of its
+// custodian. Any threshold of custodians can jointly
+// recover the wrapping key and, therefore, the keys while
+// fewer custodians learn nothing about them.
+//
+// The manifest of an escrow package is signed by the server
+// such that custodians can verify its origin and integrity.
+package escrow
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/seal"
+	"github.com/minio/kes/internal/secret"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Version is the version of the escrow package format.
+const Version = 1
+
+const (
+	// signatureContext is prepended to the manifest
+	// before it gets signed.
+	signatureContext = "kes-escrow-manifest-v1\n"
+
+	// shareContext is the HKDF info used to derive the
+	// key that encrypts a custodian's key share.
+	shareContext = "kes-escrow-share-v1"
+
+	// checkContext is the associated data of the ciphertext
+	// used to verify a recovered wrapping key.
+	checkContext = "kes escrow check"
+)
+
+var (
+	// ErrInvalidSignature is returned by Verify if the
+	// manifest has been modified or has not been signed
+	// by the expected key.
+	ErrInvalidSignature = errors.New("escrow: manifest signature is invalid")
+
+	// ErrInvalidShares is returned by Recover if the key
+	// shares do not reconstruct the wrapping key - e.g.
+	// because fewer than the threshold have been provided.
+	ErrInvalidShares = errors.New("escrow: key shares are invalid")
+
+	errNoKeys = kes.NewError(http.StatusBadRequest, "escrow: no exportable key matches")
+)
+
+// Custodian is the holder of one key share.
+type Custodian struct {
+	Name      string `json:"name"`
+	PublicKey []byte `json:"public_key"` // The X25519 public key
+
+	// Share is the key share encrypted to the
+	// custodian's public key. See: DecryptShare
+	Share []byte `json:"share,omitempty"`
+}
+
+// Package is an escrow package. It contains the JSON-encoded
+// Manifest and the Ed25519 signature of the server.
+type Package struct {
+	Manifest  []byte `json:"manifest"`
+	Signature []byte `json:"signature"`
+}
+
+// Manifest describes the escrowed keys and custodians.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Threshold is the number of custodians that
+	// have to cooperate to recover the keys.
+	Threshold  int         `json:"threshold"`
+	Custodians []Custodian `json:"custodians"`
+
+	// Keys are the escrowed keys encrypted with the
+	// wrapping key. The key name is bound to each
+	// ciphertext as associated data.
+	Keys map[string][]byte `json:"keys"`
+
+	// Check is the empty plaintext encrypted with the
+	// wrapping key. Recover verifies a reconstructed
+	// wrapping key by decrypting it.
+	Check []byte `json:"check"`
+}
+
+// Export returns an escrow package that contains all keys
+// at the store that match at least one of the glob patterns.
+// Keys with a usage policy that does not allow the
+// secret.OpExport operation are not included.
+//
+// The keys can be recovered by any threshold of the given
+// custodians. The manifest is signed with the signing key.
+func Export(ctx context.Context, store *secret.Store, patterns []string, custodians []Custodian, threshold int, signingKey ed25519.PrivateKey) (*Package, error) {
+	if threshold < 1 || threshold > len(custodians) || len(custodians) > 255 {
+		return nil, errors.New("escrow: threshold must be between 1 and the number of custodians - at most 255")
+	}
+
+	names, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var wrappingKey secret.Secret
+	defer wrappingKey.Destroy()
+	if _, err = io.ReadFull(store.Reader(), wrappingKey[:]); err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{
+		Version:    Version,
+		CreatedAt:  time.Now().UTC(),
+		Threshold:  threshold,
+		Custodians: make([]Custodian, 0, len(custodians)),
+		Keys:       map[string][]byte{},
+	}
+	for _, name := range names {
+		if !matchAny(patterns, name) {
+			continue
+		}
+		key, err := store.GetFor(ctx, name, secret.OpExport)
+		if err == kes.ErrKeyNotFound {
+			continue // The key has been deleted in the meantime
+		}
+		if e, ok := err.(kes.Error); ok && e.Status() == http.StatusForbidden {
+			continue // The key must not leave the key store
+		}
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := wrappingKey.Wrap(key[:], []byte(name))
+		key.Destroy()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Keys[name] = ciphertext
+	}
+	if len(manifest.Keys) == 0 {
+		return nil, errNoKeys
+	}
+	if manifest.Check, err = wrappingKey.Wrap(nil, []byte(checkContext)); err != nil {
+		return nil, err
+	}
+
+	shares, err := seal.Split(store.Reader(), wrappingKey[:], len(custodians), threshold)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range custodians {
+		share, err := encryptShare(store.Reader(), shares[i], c.Name, c.PublicKey)
+		secret.Wipe(shares[i])
+		if err != nil {
+			return nil, fmt.Errorf("escrow: failed to encrypt key share of '%s': %v", c.Name, err)
+		}
+		manifest.Custodians = append(manifest.Custodians, Custodian{
+			Name:      c.Name,
+			PublicKey: c.PublicKey,
+			Share:     share,
+		})
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &Package{
+		Manifest:  manifestJSON,
+		Signature: ed25519.Sign(signingKey, signatureMessage(manifestJSON)),
+	}, nil
+}
+
+// Verify verifies the signature of the package with the
+// public key of the server's signing key and returns the
+// manifest.
+func (p *Package) Verify(publicKey ed25519.PublicKey) (*Manifest, error) {
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, signatureMessage(p.Manifest), p.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(p.Manifest, &manifest); err != nil {
+		return nil, errors.New("escrow: manifest is malformed")
+	}
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("escrow: manifest version %d is not supported", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// DecryptShare decrypts the key share of the named custodian
+// with the custodian's X25519 private key.
+func (m *Manifest) DecryptShare(name string, privateKey []byte) ([]byte, error) {
+	if len(privateKey) != 32 {
+		return nil, errors.New("escrow: invalid X25519 private key")
+	}
+	for _, c := range m.Custodians {
+		if c.Name == name {
+			return decryptShare(c.Share, c.Name, privateKey)
+		}
+	}
+	return nil, fmt.Errorf("escrow: custodian '%s' does not exist", name)
+}
+
+// Recover reconstructs the wrapping key from the decrypted
+// key shares and returns the escrowed keys. It returns
+// ErrInvalidShares if the shares do not reconstruct the
+// wrapping key.
+func (m *Manifest) Recover(shares [][]byte) (map[string]secret.Secret, error) {
+	if len(shares) < m.Threshold {
+		return nil, ErrInvalidShares
+	}
+	key, err := seal.Combine(shares)
+	if err != nil || len(key) != len(secret.Secret{}) {
+		return nil, ErrInvalidShares
+	}
+
+	var wrappingKey secret.Secret
+	defer wrappingKey.Destroy()
+	copy(wrappingKey[:], key)
+	secret.Wipe(key)
+	if _, err = wrappingKey.Unwrap(m.Check, []byte(checkContext)); err != nil {
+		return nil, ErrInvalidShares
+	}
+
+	keys := make(map[string]secret.Secret, len(m.Keys))
+	for name, ciphertext := range m.Keys {
+		plaintext, err := wrappingKey.Unwrap(ciphertext, []byte(name))
+		if err != nil || len(plaintext) != len(secret.Secret{}) {
+			return nil, fmt.Errorf("escrow: failed to decrypt key '%s'", name)
+		}
+		var key secret.Secret
+		copy(key[:], plaintext)
+		secret.Wipe(plaintext)
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// encryptShare encrypts the key share to the X25519 public
+// key of the named custodian. The ephemeral key and nonce
+// are read from random. The returned ciphertext is:
+//  ephemeral public key (32) || nonce (12) || AES-256-GCM ciphertext
+func encryptShare(random io.Reader, share []byte, name string, publicKey []byte) ([]byte, error) {
+	if len(publicKey) != 32 {
+		return nil, errors.New("invalid X25519 public key")
+	}
+	var ephemeralPrivate, ephemeralPublic [32]byte
+	defer secret.Wipe(ephemeralPrivate[:])
+	if _, err := io.ReadFull(random, ephemeralPrivate[:]); err != nil {
+		return nil, err
+	}
+	curve25519.ScalarBaseMult(&ephemeralPublic, &ephemeralPrivate)
+
+	aead, err := newShareAEAD(ephemeralPrivate[:], publicKey, ephemeralPublic[:], publicKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := append(ephemeralPublic[:], nonce...)
+	return aead.Seal(ciphertext, nonce, share, []byte(name)), nil
+}
+
+// decryptShare decrypts a key share produced by encryptShare
+// with the custodian's X25519 private key.
+func decryptShare(ciphertext []byte, name string, privateKey []byte) ([]byte, error) {
+	if len(ciphertext) < 32+12 {
+		return nil, errors.New("escrow: key share is malformed")
+	}
+	var private, public [32]byte
+	defer secret.Wipe(private[:])
+	copy(private[:], privateKey)
+	curve25519.ScalarBaseMult(&public, &private)
+
+	ephemeralPublic := ciphertext[:32]
+	aead, err := newShareAEAD(privateKey, ephemeralPublic, ephemeralPublic, public[:])
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed := ciphertext[32:32+aead.NonceSize()], ciphertext[32+aead.NonceSize():]
+	share, err := aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return nil, errors.New("escrow: failed to decrypt key share: wrong custodian key or modified share")
+	}
+	return share, nil
+}
+
+// newShareAEAD returns an AES-256-GCM AEAD with a key derived
+// via HKDF-SHA256 from the X25519 shared secret of the private
+// and peer public key, and from the ephemeral and custodian
+// public key.
+func newShareAEAD(privateKey, peerPublicKey, ephemeralPublic, custodianPublic []byte) (cipher.AEAD, error) {
+	var private, peer, shared [32]byte
+	defer secret.Wipe(private[:])
+	defer secret.Wipe(shared[:])
+	copy(private[:], privateKey)
+	copy(peer[:], peerPublicKey)
+	curve25519.ScalarMult(&shared, &private, &peer)
+
+	var zero [32]byte
+	if shared == zero {
+		return nil, errors.New("invalid X25519 public key")
+	}
+
+	salt := append(append([]byte{}, ephemeralPublic...), custodianPublic...)
+	key := make([]byte, 32)
+	defer secret.Wipe(key)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared[:], salt, []byte(shareContext)), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// matchAny reports whether name matches at least
+// one of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func signatureMessage(manifest []byte) []byte {
+	return append([]byte(signatureContext), manifest...)
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package escrow
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestExportRecover(t *testing.T) {
+	ctx := context.Background()
+	store := &secret.Store{Remote: &mem.Store{}}
+	if err := store.Create(ctx, "bucket-1", secret.Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Create(ctx, "bucket-2", secret.Secret{2}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Create(ctx, "other-key", secret.Secret{3}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateWithOps(ctx, "bucket-3", secret.Secret{4}, []string{secret.OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	publicKey, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	var (
+		custodians  []Custodian
+		privateKeys [][]byte
+	)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		var private, public [32]byte
+		rand.Read(private[:])
+		curve25519.ScalarBaseMult(&public, &private)
+		custodians = append(custodians, Custodian{Name: name, PublicKey: public[:]})
+		privateKeys = append(privateKeys, private[:])
+	}
+
+	pkg, err := Export(ctx, store, []string{"bucket-*"}, custodians, 2, signingKey)
+	if err != nil {
+		t.Fatalf("Failed to export keys: %v", err)
+	}
+	manifest, err := pkg.Verify(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to verify package: %v", err)
+	}
+	if len(manifest.Keys) != 2 {
+		t.Fatalf("Invalid number of escrowed keys: got %d - want %d", len(manifest.Keys), 2)
+	}
+	if _, ok := manifest.Keys["bucket-3"]; ok {
+		t.Fatal("Escrowed key that must not be exported")
+	}
+
+	aliceShare, err := manifest.DecryptShare("alice", privateKeys[0])
+	if err != nil {
+		t.Fatalf("Failed to decrypt key share: %v", err)
+	}
+	carolShare, err := manifest.DecryptShare("carol", privateKeys[2])
+	if err != nil {
+		t.Fatalf("Failed to decrypt key share: %v", err)
+	}
+	if _, err = manifest.DecryptShare("bob", privateKeys[0]); err == nil {
+		t.Fatal("Decrypted key share with the key of another custodian")
+	}
+
+	if _, err = manifest.Recover([][]byte{aliceShare}); err != ErrInvalidShares {
+		t.Fatalf("Recovered keys with fewer shares than the threshold: %v", err)
+	}
+	keys, err := manifest.Recover([][]byte{aliceShare, carolShare})
+	if err != nil {
+		t.Fatalf("Failed to recover keys: %v", err)
+	}
+	if keys["bucket-1"] != (secret.Secret{1}) || keys["bucket-2"] != (secret.Secret{2}) {
+		t.Fatal("Recovered keys do not match escrowed keys")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	store := &secret.Store{Remote: &mem.Store{}}
+	if err := store.Create(ctx, "my-key", secret.Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	publicKey, signingKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	var private, public [32]byte
+	rand.Read(private[:])
+	curve25519.ScalarBaseMult(&public, &private)
+	custodians := []Custodian{{Name: "alice", PublicKey: public[:]}}
+
+	if _, err := Export(ctx, store, []string{"*"}, custodians, 2, signingKey); err == nil {
+		t.Fatal("Exported keys with a threshold larger than the number of custodians")
+	}
+	if _, err := Export(ctx, store, []string{"no-key"}, custodians, 1, signingKey); err == nil {
+		t.Fatal("Exported package without any key")
+	}
+	pkg, err := Export(ctx, store, []string{"*"}, custodians, 1, signingKey)
+	if err != nil {
+		t.Fatalf("Failed to export keys: %v", err)
+	}
+	if _, err = pkg.Verify(otherKey); err != ErrInvalidSignature {
+		t.Fatalf("Verified package with wrong public key: %v", err)
+	}
+
+	var manifest Manifest
+	if err = json.Unmarshal(pkg.Manifest, &manifest); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+	manifest.Threshold = 0
+	modified := *pkg
+	if modified.Manifest, err = json.Marshal(manifest); err != nil {
+		t.Fatalf("Failed to encode manifest: %v", err)
+	}
+	if _, err = modified.Verify(publicKey); err != ErrInvalidSignature {
+		t.Fatalf("Verified modified package: %v", err)
+	}
+}
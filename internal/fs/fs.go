@@ -5,69 +5,83 @@
 // Package fs implements a key-value store that
 // stores keys as file names and values as file
 // content.
+//
+// Hierarchical key names, like "tenant/app/my-key",
+// are mapped to sub-directories - e.g. the directory
+// "tenant/app" contains the file "my-key".
 package fs
 
 import (
+	"errors"
 	"io"
-	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
 	"github.com/minio/kes/internal/secret"
 )
 
 // Store is a file system key-value store that stores
 // keys as file names in a directory.
+//
+// Store does not log errors. Wrap it with a
+// secret.LoggingRemote to log failed operations.
 type Store struct {
 	// Dir is the directory where key-value entries
 	// are located. The store will read / write
 	// values from / to files in this directory.
 	Dir string
-
-	// ErrorLog specifies an optional logger for errors
-	// when files cannot be opened, deleted or contain
-	// invalid content.
-	// If nil, logging is done via the log package's
-	// standard logger.
-	ErrorLog *log.Logger
 }
 
-var _ secret.Remote = (*Store)(nil)
+// errInvalidFileName is returned by Create if the key
+// name is not a valid file name on this platform - e.g.
+// "CON" on Windows.
+var errInvalidFileName = kes.NewError(http.StatusBadRequest, "key name is not a valid file name")
+
+var (
+	_ secret.Remote       = (*Store)(nil)
+	_ secret.Stater       = (*Store)(nil)
+	_ secret.PrefixLister = (*Store)(nil)
+)
 
 // Create creates a new file in the directory if no file
 // with the name 'key' does not exists and writes value
 // to it.
 // If such a file already exists it returns kes.ErrKeyExists.
 func (s *Store) Create(key, value string) error {
-	// We use os.O_CREATE and os.O_EXCL to enforce that the
-	// file must not have existed before.
-	path := filepath.Join(s.Dir, key)
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if !validFileName(key) {
+		return errInvalidFileName
+	}
+
+	// We use os.O_CREATE and os.O_EXCL - or CREATE_NEW on
+	// Windows - to enforce that the file must not have
+	// existed before.
+	path := filepath.Join(absDir(s.Dir), key)
+	file, err := s.create(path)
 	if err != nil && os.IsExist(err) {
 		return kes.ErrKeyExists
 	}
 	if err != nil {
-		s.logf("fs: cannot open %s: %v", path, err)
-		return err
+		return errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
 	}
-	defer file.Close()
 
+	// On Windows, an open file cannot be removed. Hence,
+	// we close the file before removing a partial file.
 	if _, err = file.WriteString(value); err != nil {
-		s.logf("fs: failed to write to %s: %v", path, err)
-		if rmErr := os.Remove(path); rmErr != nil {
-			s.logf("fs: cannot remove %s: %v", path, rmErr)
-		}
-		return err
+		file.Close()
+		return removeOnError(path, err)
 	}
-
 	if err = file.Sync(); err != nil { // Ensure that we wrote the value to disk
-		s.logf("fs: cannot to flush and sync %s: %v", path, err)
-		if rmErr := os.Remove(path); rmErr != nil {
-			s.logf("fs: cannot remove %s: %v", path, rmErr)
-		}
-		return err
+		file.Close()
+		return removeOnError(path, err)
+	}
+	if err = file.Close(); err != nil {
+		return removeOnError(path, err)
 	}
 	return nil
 }
@@ -76,46 +90,175 @@ func (s *Store) Create(key, value string) error {
 // from the key store and deletes the associated file,
 // if it exists.
 func (s *Store) Delete(key string) error {
-	path := filepath.Join(s.Dir, key)
-	err := os.Remove(path)
+	if !validFileName(key) {
+		return nil // There cannot be such a file
+	}
+
+	path := filepath.Join(absDir(s.Dir), key)
+	err := removeFile(path)
 	if err != nil && os.IsNotExist(err) {
 		err = nil // Ignore the error if the file does not exist
 	}
 	if err != nil {
-		s.logf("fs: failed to delete '%s': %v", path, err)
+		return errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+	}
+
+	// Remove the directories of the key, like "tenant/app",
+	// once they are empty. os.Remove fails for a non-empty
+	// directory - so we stop at the first one that still
+	// contains other keys.
+	root := filepath.Clean(absDir(s.Dir))
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
 	}
-	return err
+	return nil
 }
 
 // Get returns the secret key associated with the given name.
 // If no entry for name exists, Get returns kes.ErrKeyNotFound.
 //
 // In particular, Get reads the secret key from the associated
-// file in KeyStore.Dir.
+// file in KeyStore.Dir. The read buffer is wiped before Get
+// returns.
 func (s *Store) Get(key string) (string, error) {
-	path := filepath.Join(s.Dir, key)
-	file, err := os.Open(path)
+	if !validFileName(key) {
+		return "", kes.ErrKeyNotFound
+	}
+
+	path := filepath.Join(absDir(s.Dir), key)
+	file, err := openFile(path)
 	if err != nil && os.IsNotExist(err) {
 		return "", kes.ErrKeyNotFound
 	}
 	if err != nil {
-		s.logf("fs: cannot open '%s': %v", path, err)
-		return "", err
+		return "", errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
 	}
 	defer file.Close()
 
-	var value strings.Builder
-	if _, err := io.Copy(&value, io.LimitReader(file, secret.MaxSize)); err != nil {
-		s.logf("fs: failed to read from '%s': %v", path, err)
-		return "", err
+	// We read the file into a buffer of the file size - instead
+	// of a growing buffer - such that no partial copy of the
+	// value remains in memory once the buffer has been wiped.
+	stat, err := file.Stat()
+	if err != nil {
+		return "", errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+	}
+	size := stat.Size()
+	if size > secret.MaxSize {
+		size = secret.MaxSize
+	}
+	value := make([]byte, size)
+	defer secret.Wipe(value)
+
+	if _, err = io.ReadFull(file, value); err != nil {
+		return "", errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+	}
+	return string(value), nil
+}
+
+// CreatedAt returns the modification time of the file
+// associated with the given name. Since a file is never
+// modified once it has been created, it is the point in
+// time when the entry has been created.
+// If no entry for name exists, CreatedAt returns
+// kes.ErrKeyNotFound.
+func (s *Store) CreatedAt(key string) (time.Time, error) {
+	if !validFileName(key) {
+		return time.Time{}, kes.ErrKeyNotFound
+	}
+
+	path := filepath.Join(absDir(s.Dir), key)
+	stat, err := os.Stat(path)
+	if err != nil && os.IsNotExist(err) {
+		return time.Time{}, kes.ErrKeyNotFound
+	}
+	if err != nil {
+		return time.Time{}, errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+	}
+	return stat.ModTime().UTC(), nil
+}
+
+// List returns the names of all entries - i.e. the
+// names of all files within Dir and its sub-directories.
+// The names of entries within a sub-directory, like a
+// tenant namespace, are prefixed with the directory name
+// - e.g. "my-tenant/my-key".
+func (s *Store) List() ([]string, error) { return s.ListPrefix("") }
+
+// ListPrefix returns the names of all entries that start
+// with the prefix - e.g. "my-tenant/". Since the prefix
+// ends with a '/', ListPrefix only walks the sub-directory
+// of the prefix.
+func (s *Store) ListPrefix(prefix string) ([]string, error) {
+	root := absDir(s.Dir)
+	dir := filepath.Join(root, prefix)
+	if prefix != "" {
+		stat, err := os.Stat(dir)
+		if err != nil && (os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)) {
+			return nil, nil // There are no entries with this prefix
+		}
+		if err != nil {
+			return nil, errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+		}
+		if !stat.IsDir() {
+			return nil, nil
+		}
+	}
+
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return nil, errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
+	}
+	return names, nil
+}
+
+// create creates a new file at path if no such file exists.
+// It creates the directories of path, if necessary. Since a
+// concurrent Delete may remove an empty directory before the
+// file has been created, it retries once.
+func (s *Store) create(path string) (*os.File, error) {
+	var (
+		root = filepath.Clean(absDir(s.Dir))
+		dir  = filepath.Dir(path)
+		file *os.File
+		err  error
+	)
+	for i := 0; i < 2; i++ {
+		if dir != root {
+			if err = os.MkdirAll(dir, 0700); err != nil {
+				return nil, err
+			}
+		}
+		file, err = createFile(path)
+		if err == nil || !os.IsNotExist(err) {
+			break
+		}
 	}
-	return value.String(), nil
+	return file, err
 }
 
-func (s *Store) logf(format string, v ...interface{}) {
-	if s.ErrorLog == nil {
-		log.Printf(format, v...)
-	} else {
-		s.ErrorLog.Printf(format, v...)
+// removeOnError removes the partially written file at
+// path and returns err. If the file cannot be removed,
+// the returned error mentions it - since the partial
+// file would be mistaken for a valid entry.
+func removeOnError(path string, err error) error {
+	if rmErr := os.Remove(path); rmErr != nil {
+		return errs.Errorf(kes.ErrBackendFailure, "fs: %v - cannot remove partial file: %v", err, rmErr)
 	}
+	return errs.Errorf(kes.ErrBackendFailure, "fs: %w", err)
 }
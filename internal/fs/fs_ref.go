@@ -0,0 +1,21 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package fs
+
+import "os"
+
+func createFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+}
+
+func openFile(path string) (*os.File, error) { return os.Open(path) }
+
+func removeFile(path string) error { return os.Remove(path) }
+
+func absDir(dir string) string { return dir }
+
+func validFileName(name string) bool { return true }
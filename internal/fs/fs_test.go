@@ -0,0 +1,133 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func BenchmarkCreate(b *testing.B) {
+	b.Run("Plain", func(b *testing.B) { benchmarkCreate(b, nil) })
+	b.Run("Sealed", func(b *testing.B) { benchmarkCreate(b, &mem.KMS{}) })
+}
+
+func BenchmarkGet(b *testing.B) {
+	b.Run("Plain", func(b *testing.B) { benchmarkGet(b, nil) })
+	b.Run("Sealed", func(b *testing.B) { benchmarkGet(b, &mem.KMS{}) })
+}
+
+func benchmarkCreate(b *testing.B, kms secret.KMS) {
+	store, cleanup := newBenchmarkStore(b, kms)
+	defer cleanup()
+
+	var (
+		ctx = context.Background()
+		key secret.Secret
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.Create(ctx, "key-"+strconv.Itoa(i), key); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkGet measures fetching a secret from the
+// file system - i.e. it evicts the secret from the
+// cache before every Get.
+func benchmarkGet(b *testing.B, kms secret.KMS) {
+	store, cleanup := newBenchmarkStore(b, kms)
+	defer cleanup()
+
+	var (
+		ctx = context.Background()
+		key secret.Secret
+	)
+	if err := store.Create(ctx, "my-key", key); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Evict("my-key")
+		if _, err := store.Get(ctx, "my-key"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newBenchmarkStore(b *testing.B, kms secret.KMS) (*secret.Store, func()) {
+	dir, err := ioutil.TempDir("", "kes-fs-")
+	if err != nil {
+		b.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	store := &secret.Store{
+		Remote: &Store{Dir: dir},
+		KMS:    kms,
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestStoreListPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-fs-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &Store{Dir: dir}
+	for _, name := range []string{"my-key", "tenant/app/key-1", "tenant/app/key-2", "tenant/other/key-3"} {
+		if err = store.Create(name, "value"); err != nil {
+			t.Fatalf("Failed to create '%s': %v", name, err)
+		}
+	}
+
+	for i, test := range []struct {
+		Prefix string
+		Names  []string
+	}{
+		{Prefix: "", Names: []string{"my-key", "tenant/app/key-1", "tenant/app/key-2", "tenant/other/key-3"}},
+		{Prefix: "tenant/", Names: []string{"tenant/app/key-1", "tenant/app/key-2", "tenant/other/key-3"}},
+		{Prefix: "tenant/app/", Names: []string{"tenant/app/key-1", "tenant/app/key-2"}},
+		{Prefix: "unknown/", Names: nil},
+		{Prefix: "my-key/", Names: nil},
+		{Prefix: "my-key/app/", Names: nil},
+	} {
+		names, err := store.ListPrefix(test.Prefix)
+		if err != nil {
+			t.Fatalf("Test %d: failed to list '%s': %v", i, test.Prefix, err)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, test.Names) {
+			t.Fatalf("Test %d: got %v - want %v", i, names, test.Names)
+		}
+	}
+
+	// Deleting the last key of a directory removes the
+	// directory but not its non-empty parent directories.
+	if err = store.Delete("tenant/other/key-3"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "tenant", "other")); !os.IsNotExist(err) {
+		t.Fatalf("Empty directory has not been removed: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "tenant", "app")); err != nil {
+		t.Fatalf("Non-empty directory has been removed: %v", err)
+	}
+	if _, err = os.Stat(dir); err != nil {
+		t.Fatalf("Store directory has been removed: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// createFile creates a new file at path for writing if
+// no such file exists.
+//
+// The file is opened with FILE_FLAG_WRITE_THROUGH such
+// that writes bypass the lazy-writer of the file system
+// cache. Hence, a value has been written to disk once
+// Create returns - even if the disk cache of the storage
+// controller ignores the flush request of File.Sync.
+func createFile(path string) (*os.File, error) {
+	name, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	handle, err := windows.CreateFile(
+		name,
+		windows.GENERIC_WRITE,
+		0, // Don't share the file while we write the value
+		nil,
+		windows.CREATE_NEW,
+		windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_WRITE_THROUGH,
+		0,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+// openFile opens the file at path for reading.
+//
+// In contrast to os.Open, the file is opened with
+// FILE_SHARE_DELETE. Otherwise, a concurrent Delete
+// would fail while the file is open.
+func openFile(path string) (*os.File, error) {
+	name, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	handle, err := windows.CreateFile(
+		name,
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+// removeFile removes the file at path.
+//
+// Windows removes a file, that is still open, only once
+// the last handle has been closed. Until then, the file
+// name cannot be used - e.g. to create the key again.
+// Therefore, removeFile uses POSIX semantics, if supported
+// by the file system, which remove the file name immediately.
+func removeFile(path string) error {
+	name, err := windows.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: path, Err: err}
+	}
+	handle, err := windows.CreateFile(
+		name,
+		windows.DELETE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT,
+		0,
+	)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: path, Err: err}
+	}
+
+	info := struct{ Flags uint32 }{
+		Flags: windows.FILE_DISPOSITION_DELETE | windows.FILE_DISPOSITION_POSIX_SEMANTICS,
+	}
+	err = windows.SetFileInformationByHandle(handle, windows.FileDispositionInfoEx, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	windows.CloseHandle(handle)
+	switch err {
+	case nil:
+		return nil
+	case windows.ERROR_INVALID_PARAMETER, windows.ERROR_INVALID_FUNCTION, windows.ERROR_NOT_SUPPORTED:
+		// Older Windows versions and file systems other
+		// than NTFS don't support POSIX semantics.
+		return os.Remove(path)
+	default:
+		return &os.PathError{Op: "remove", Path: path, Err: err}
+	}
+}
+
+// absDir returns the absolute path of dir. Windows limits
+// relative paths to MAX_PATH (260) characters while an
+// absolute path can be converted to an extended-length
+// path. See: longPath
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// longPath returns the extended-length form of an absolute
+// path - e.g. \\?\C:\kes\keys\my-key. Such a path is not
+// limited to MAX_PATH characters. The os package converts
+// paths internally. However, paths passed to the Windows API
+// directly must be converted explicitly.
+func longPath(path string) string {
+	const prefix = `\\?\`
+	if len(path) < 248 || strings.HasPrefix(path, prefix) || !filepath.IsAbs(path) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) { // UNC path - e.g. \\server\share\keys
+		return prefix + `UNC\` + path[2:]
+	}
+	return prefix + path
+}
+
+// reservedNames are file names that refer to devices
+// on Windows - with any or no file extension.
+var reservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// validFileName reports whether each segment of the key
+// name is a valid Windows file name. For example, a key
+// named "CON" would refer to the console and a key named
+// "my-key." to the same file as "my-key".
+func validFileName(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+			return false
+		}
+		if strings.ContainsAny(segment, `<>:"|?*\`) {
+			return false
+		}
+		for _, c := range segment {
+			if c < 0x20 {
+				return false
+			}
+		}
+
+		base := segment
+		if i := strings.IndexByte(base, '.'); i >= 0 {
+			base = base[:i]
+		}
+		base = strings.TrimRight(base, " ")
+		for _, reserved := range reservedNames {
+			if strings.EqualFold(base, reserved) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+var validFileNameTests = []struct {
+	Name  string
+	Valid bool
+}{
+	{Name: "my-key", Valid: true},                 // 0
+	{Name: "tenant/app/my-key", Valid: true},      // 1
+	{Name: "my.key", Valid: true},                 // 2
+	{Name: "console", Valid: true},                // 3
+	{Name: "CON", Valid: false},                   // 4
+	{Name: "tenant/nul.txt", Valid: false},        // 5
+	{Name: "com1", Valid: false},                  // 6
+	{Name: "my-key.", Valid: false},               // 7
+	{Name: "my-key ", Valid: false},               // 8
+	{Name: "my:key", Valid: false},                // 9
+	{Name: "my-key?", Valid: false},               // 10
+	{Name: "tenant/app\x01/my-key", Valid: false}, // 11
+}
+
+func TestValidFileName(t *testing.T) {
+	for i, test := range validFileNameTests {
+		if valid := validFileName(test.Name); valid != test.Valid {
+			t.Fatalf("Test %d: got %v - want %v", i, valid, test.Valid)
+		}
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	long := strings.Repeat("a", 255)
+	for i, test := range []struct {
+		Path     string
+		LongPath string
+	}{
+		{Path: `C:\kes\my-key`, LongPath: `C:\kes\my-key`},                         // 0
+		{Path: `C:\kes\` + long, LongPath: `\\?\C:\kes\` + long},                   // 1
+		{Path: `\\server\share\` + long, LongPath: `\\?\UNC\server\share\` + long}, // 2
+		{Path: `\\?\C:\kes\` + long, LongPath: `\\?\C:\kes\` + long},               // 3
+		{Path: `kes\` + long, LongPath: `kes\` + long},                             // 4
+		{Path: `C:\kes\tenant\..\` + long, LongPath: `\\?\C:\kes\` + long},         // 5
+	} {
+		if path := longPath(test.Path); path != test.LongPath {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, path, test.LongPath)
+		}
+	}
+}
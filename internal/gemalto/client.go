@@ -11,12 +11,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	xhttp "github.com/minio/kes/internal/http"
+	xlog "github.com/minio/kes/internal/log"
 )
 
 // authToken is a KeySecure authentication token.
@@ -36,7 +36,7 @@ func (t *authToken) String() string { return fmt.Sprintf("%s %s", t.Type, t.Valu
 // authentication tokens.
 type client struct {
 	xhttp.Retry
-	ErrorLog *log.Logger
+	Log *xlog.Logger
 
 	lock  sync.Mutex
 	token authToken
@@ -141,7 +141,7 @@ func (c *client) RenewAuthToken(ctx context.Context, endpoint string, login Cred
 	)
 	for {
 		if err != nil {
-			logf(c.ErrorLog, "gemalto: failed to renew auth token: %v", err)
+			c.Log.Error("failed to renew auth token", "err", err)
 			timer = time.NewTimer(login.Retry)
 		} else {
 			c.lock.Lock()
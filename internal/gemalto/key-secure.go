@@ -15,7 +15,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"os"
@@ -24,7 +23,9 @@ import (
 	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
 	xhttp "github.com/minio/kes/internal/http"
+	xlog "github.com/minio/kes/internal/log"
 )
 
 // Credentials represents a Gemalto KeySecure
@@ -59,7 +60,7 @@ type KeySecure struct {
 	// token.
 	Login Credentials
 
-	// ErrorLog specifies an optional logger for errors.
+	// Log specifies an optional logger for errors.
 	// If an unexpected error is encountered while trying
 	// to fetch, store or delete a key or when an authentication
 	// error happens then an error event is written to the error
@@ -67,7 +68,7 @@ type KeySecure struct {
 	//
 	// If nil, logging is done via the log package's standard
 	// logger.
-	ErrorLog *log.Logger
+	Log *xlog.Logger
 
 	client *client
 }
@@ -78,7 +79,10 @@ type KeySecure struct {
 // It retruns an error if no connection could be
 // established - for instance because of invalid
 // credentials.
-func (s *KeySecure) Authenticate() (err error) {
+//
+// The authentication token gets renewed in the
+// background until the ctx is done.
+func (s *KeySecure) Authenticate(ctx context.Context) (err error) {
 	var rootCAs *x509.CertPool
 	if s.CAPath != "" {
 		rootCAs, err = loadCustomCAs(s.CAPath)
@@ -88,7 +92,7 @@ func (s *KeySecure) Authenticate() (err error) {
 	}
 
 	s.client = &client{
-		ErrorLog: s.ErrorLog,
+		Log: s.Log,
 		Retry: xhttp.Retry{
 			Client: http.Client{
 				Transport: &http.Transport{
@@ -113,7 +117,16 @@ func (s *KeySecure) Authenticate() (err error) {
 	if err = s.client.Authenticate(s.Endpoint, s.Login); err != nil {
 		return err
 	}
-	go s.client.RenewAuthToken(context.Background(), s.Endpoint, s.Login)
+	go s.client.RenewAuthToken(ctx, s.Endpoint, s.Login)
+	return nil
+}
+
+// Close closes all idle connections to the
+// KeySecure server.
+func (s *KeySecure) Close() error {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
 	return nil
 }
 
@@ -146,7 +159,7 @@ func (s *KeySecure) Create(key, value string) error {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return errs.Errorf(kes.ErrBackendUnavailable, "gemalto: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -155,11 +168,11 @@ func (s *KeySecure) Create(key, value string) error {
 			return kes.ErrKeyExists
 		}
 		if response, err := parseServerError(resp); err != nil {
-			logf(s.ErrorLog, "gemalto: %s: failed to parse server response: %v", resp.Status, err)
+			s.Log.Error("failed to parse server response", "status", resp.Status, "err", err)
 		} else {
-			logf(s.ErrorLog, "gemalto: failed to create key '%s': %s (%d)", key, response.Message, response.Code)
+			s.Log.Error("failed to create key", "key", key, "err", response.Message, "code", response.Code)
 		}
-		return kes.NewError(http.StatusBadGateway, "bad gateway: failed to create key")
+		return errs.Errorf(kes.ErrBackendFailure, "gemalto: failed to create key '%s': %s", key, resp.Status)
 	}
 	return nil
 }
@@ -180,7 +193,7 @@ func (s *KeySecure) Get(key string) (string, error) {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", errs.Errorf(kes.ErrBackendUnavailable, "gemalto: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -190,17 +203,17 @@ func (s *KeySecure) Get(key string) (string, error) {
 		}
 
 		if response, err := parseServerError(resp); err != nil {
-			logf(s.ErrorLog, "gemalto: %s: failed to parse server response: %v", resp.Status, err)
+			s.Log.Error("failed to parse server response", "status", resp.Status, "err", err)
 		} else {
-			logf(s.ErrorLog, "gemalto: failed to access key '%s': %s (%d)", key, response.Message, response.Code)
+			s.Log.Error("failed to access key", "key", key, "err", response.Message, "code", response.Code)
 		}
-		return "", kes.NewError(http.StatusBadGateway, "bad gateway: failed to access key")
+		return "", errs.Errorf(kes.ErrBackendFailure, "gemalto: failed to access key '%s': %s", key, resp.Status)
 	}
 
 	var response Response
 	if err = json.NewDecoder(io.LimitReader(resp.Body, 2<<20)).Decode(&response); err != nil {
-		logf(s.ErrorLog, "gemalto: failed to parse server response: %v", err)
-		return "", kes.NewError(http.StatusBadGateway, "bad gateway: failed to access key")
+		s.Log.Error("failed to parse server response", "err", err)
+		return "", errs.Errorf(kes.ErrBackendFailure, "gemalto: failed to access key '%s': %w", key, err)
 	}
 	return response.Value, nil
 }
@@ -217,7 +230,7 @@ func (s *KeySecure) Delete(key string) error {
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return errs.Errorf(kes.ErrBackendUnavailable, "gemalto: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -233,11 +246,11 @@ func (s *KeySecure) Delete(key string) error {
 		// policy change). So, in this case we don't return an error such that the
 		// client thinks it has deleted the secret successfully.
 		if response, err := parseServerError(resp); err != nil {
-			logf(s.ErrorLog, "gemalto: %s: failed to parse server response: %v", resp.Status, err)
+			s.Log.Error("failed to parse server response", "status", resp.Status, "err", err)
 		} else {
-			logf(s.ErrorLog, "gemalto: failed to delete key '%s': %s (%d)", key, response.Message, response.Code)
+			s.Log.Error("failed to delete key", "key", key, "err", response.Message, "code", response.Code)
 		}
-		return kes.NewError(http.StatusBadGateway, "bad gateway: failed to delete key")
+		return errs.Errorf(kes.ErrBackendFailure, "gemalto: failed to delete key '%s': %s", key, resp.Status)
 	}
 	return nil
 }
@@ -344,11 +357,3 @@ func loadCustomCAs(path string) (*x509.CertPool, error) {
 	}
 	return rootCAs, nil
 }
-
-func logf(logger *log.Logger, format string, v ...interface{}) {
-	if logger == nil {
-		log.Printf(format, v...)
-	} else {
-		logger.Printf(format, v...)
-	}
-}
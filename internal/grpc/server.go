@@ -0,0 +1,358 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package grpc implements the gRPC API of the KES server.
+// See: github.com/minio/kes/kespb
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/kespb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ErrServerClosed is returned by the Server's Serve and
+// ListenAndServe methods after a call to Close.
+var ErrServerClosed = errors.New("grpc: server closed")
+
+// maxResponseSize is the max. size of an HTTP API
+// response to a gRPC call. The responses of the key
+// operations are small. Therefore, 1 MiB is more
+// than enough.
+const maxResponseSize = 1 << 20
+
+// Server is a gRPC server that serves the kespb.KeyService
+// by sending the corresponding request to the HTTP API of
+// the KES server:
+//   CreateKey    -> POST   /v1/key/create/<name>
+//   DeleteKey    -> DELETE /v1/key/delete/<name>
+//   GenerateKey  -> POST   /v1/key/generate/<name>
+//   DecryptKey   -> POST   /v1/key/decrypt/<name>
+//   GenerateKeys -> POST   /v1/key/generate/<name> - for each request
+//   DecryptKeys  -> POST   /v1/key/decrypt/<name>  - for each request
+//
+// Hence, each call is authenticated, authorized, rate-limited
+// and audited as if the client had sent the HTTP request via
+// the same TLS connection. The "authorization" metadata of a
+// call is sent as HTTP Authorization header.
+type Server struct {
+	// Addr is the TCP address the server
+	// listens on.
+	Addr string
+
+	// Handler is the handler of the HTTP API.
+	Handler http.Handler
+
+	// TLSConfig is the TLS configuration of the
+	// server. Clients are identified by their TLS
+	// certificate. Therefore, the server should
+	// request a client certificate.
+	TLSConfig *tls.Config
+
+	lock   sync.Mutex
+	server *grpc.Server
+	closed bool
+}
+
+// ListenAndServe listens on the TCP address s.Addr and
+// serves gRPC calls sent via TLS connections.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener and serves
+// gRPC calls. It performs the TLS handshake with each
+// client.
+//
+// Serve always returns a non-nil error and closes the
+// listener. After Close, it returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	if s.server == nil {
+		s.server = grpc.NewServer(grpc.Creds(credentials.NewTLS(s.TLSConfig)))
+		kespb.RegisterKeyServiceServer(s.server, &keyService{handler: s.Handler})
+	}
+	server := s.server
+	s.lock.Unlock()
+
+	err := server.Serve(listener)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// Close closes all listeners and connections
+// immediately. It cancels all pending calls.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.server != nil {
+		s.server.Stop()
+	}
+	return nil
+}
+
+// keyService implements the kespb.KeyServiceServer
+// using the HTTP API handler.
+type keyService struct {
+	handler http.Handler
+}
+
+var _ kespb.KeyServiceServer = (*keyService)(nil)
+
+func (s *keyService) CreateKey(ctx context.Context, req *kespb.CreateKeyRequest) (*kespb.CreateKeyResponse, error) {
+	if err := s.serve(ctx, http.MethodPost, "/v1/key/create/", req.Name, nil, nil); err != nil {
+		return nil, err
+	}
+	return &kespb.CreateKeyResponse{}, nil
+}
+
+func (s *keyService) DeleteKey(ctx context.Context, req *kespb.DeleteKeyRequest) (*kespb.DeleteKeyResponse, error) {
+	if err := s.serve(ctx, http.MethodDelete, "/v1/key/delete/", req.Name, nil, nil); err != nil {
+		return nil, err
+	}
+	return &kespb.DeleteKeyResponse{}, nil
+}
+
+func (s *keyService) GenerateKey(ctx context.Context, req *kespb.GenerateKeyRequest) (*kespb.GenerateKeyResponse, error) {
+	type Request struct {
+		Context []byte `json:"context"`
+	}
+	type Response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	var resp Response
+	if err := s.serve(ctx, http.MethodPost, "/v1/key/generate/", req.Name, Request{Context: req.Context}, &resp); err != nil {
+		return nil, err
+	}
+	return &kespb.GenerateKeyResponse{
+		Plaintext:  resp.Plaintext,
+		Ciphertext: resp.Ciphertext,
+	}, nil
+}
+
+func (s *keyService) DecryptKey(ctx context.Context, req *kespb.DecryptKeyRequest) (*kespb.DecryptKeyResponse, error) {
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context"`
+	}
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	var resp Response
+	if err := s.serve(ctx, http.MethodPost, "/v1/key/decrypt/", req.Name, Request{Ciphertext: req.Ciphertext, Context: req.Context}, &resp); err != nil {
+		return nil, err
+	}
+	return &kespb.DecryptKeyResponse{
+		Plaintext: resp.Plaintext,
+	}, nil
+}
+
+func (s *keyService) GenerateKeys(stream kespb.KeyService_GenerateKeysServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.GenerateKey(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *keyService) DecryptKeys(stream kespb.KeyService_DecryptKeysServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.DecryptKey(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// serve sends the request for the named key to the HTTP
+// API and decodes the response into resp. The request is
+// sent on behalf of the gRPC client - i.e. with its TLS
+// connection state and remote address.
+//
+// It returns the error of the HTTP API as gRPC status error.
+func (s *keyService) serve(ctx context.Context, method, api, name string, req, resp interface{}) error {
+	if !secret.ValidName(name) {
+		return status.Error(codes.InvalidArgument, "invalid key name")
+	}
+
+	var body io.Reader = http.NoBody // Handlers expect a non-nil body - like for server requests
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		body = bytes.NewReader(b)
+	}
+	r, err := http.NewRequest(method, (&url.URL{Scheme: "https", Host: "localhost", Path: api + name}).String(), body)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid key name")
+	}
+	r = r.WithContext(ctx)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0 // gRPC is served via HTTP/2
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := info.State
+			r.TLS = &state
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			r.Header.Add("Authorization", value)
+		}
+	}
+
+	w := &responseWriter{header: http.Header{}}
+	s.handler.ServeHTTP(w, r)
+	defer secret.Wipe(w.body.Bytes()) // The response may contain a plaintext data key
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status != http.StatusOK {
+		return toStatus(w.status, w.body.Bytes())
+	}
+	if resp != nil {
+		if err := json.Unmarshal(w.body.Bytes(), resp); err != nil {
+			return status.Error(codes.Internal, "invalid server response")
+		}
+	}
+	return nil
+}
+
+// toStatus converts the HTTP API error response into
+// a gRPC status error.
+func toStatus(statusCode int, body []byte) error {
+	type Response struct {
+		Message string `json:"message"`
+	}
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil || response.Message == "" {
+		response.Message = http.StatusText(statusCode)
+	}
+
+	var code codes.Code
+	switch kes.NewError(statusCode, response.Message) {
+	case kes.ErrKeyExists, kes.ErrEnclaveExists:
+		code = codes.AlreadyExists
+	case kes.ErrQuotaExceeded:
+		code = codes.ResourceExhausted
+	default:
+		switch statusCode {
+		case http.StatusBadRequest:
+			code = codes.InvalidArgument
+		case http.StatusUnauthorized:
+			code = codes.Unauthenticated
+		case http.StatusForbidden:
+			code = codes.PermissionDenied
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusPreconditionFailed:
+			code = codes.FailedPrecondition
+		case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+			code = codes.ResourceExhausted
+		case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			code = codes.Unimplemented
+		case http.StatusServiceUnavailable:
+			code = codes.Unavailable
+		case http.StatusGatewayTimeout:
+			code = codes.DeadlineExceeded
+		default:
+			if statusCode < http.StatusInternalServerError {
+				code = codes.InvalidArgument
+			} else {
+				code = codes.Internal
+			}
+		}
+	}
+	return status.Error(code, response.Message)
+}
+
+// responseWriter is an http.ResponseWriter that
+// keeps the response in memory.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+var _ http.Flusher = (*responseWriter)(nil)
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len()+len(b) > maxResponseSize {
+		return 0, errors.New("grpc: response too large")
+	}
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) Flush() {}
@@ -0,0 +1,286 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	xhttp "github.com/minio/kes/internal/http"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/kespb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+var toStatusTests = []struct {
+	Err  error
+	Code codes.Code
+}{
+	{Err: kes.ErrKeyExists, Code: codes.AlreadyExists},                            // 0
+	{Err: kes.ErrKeyNotFound, Code: codes.NotFound},                               // 1
+	{Err: kes.ErrNotAllowed, Code: codes.PermissionDenied},                        // 2
+	{Err: kes.ErrQuotaExceeded, Code: codes.ResourceExhausted},                    // 3
+	{Err: kes.ErrDecrypt, Code: codes.InvalidArgument},                            // 4
+	{Err: kes.ErrIdentityExpired, Code: codes.Unauthenticated},                    // 5
+	{Err: kes.ErrTooManyRequests, Code: codes.ResourceExhausted},                  // 6
+	{Err: kes.ErrPreconditionFailed, Code: codes.FailedPrecondition},              // 7
+	{Err: kes.ErrBackendUnavailable, Code: codes.Unavailable},                     // 8
+	{Err: kes.NewError(http.StatusInternalServerError, ""), Code: codes.Internal}, // 9
+}
+
+func TestToStatus(t *testing.T) {
+	for i, test := range toStatusTests {
+		var w responseWriter
+		w.header = http.Header{}
+		xhttp.Error(&w, test.Err)
+
+		err := toStatus(w.status, w.body.Bytes())
+		if code := status.Code(err); code != test.Code {
+			t.Fatalf("Test %d: got code %v - want %v", i, code, test.Code)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	serverCert, clientCert, otherCert := newCertificate(t), newCertificate(t), newCertificate(t)
+	identity := sha256.Sum256(clientCert.Leaf.RawSubjectPublicKeyInfo)
+
+	store := &secret.Store{Remote: &mem.Store{}}
+	roles := &auth.Roles{Root: kes.Identity(hex.EncodeToString(identity[:]))}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/key/create/", xhttp.EnforcePolicies(roles, xhttp.HandleCreateKey(store, roles)))
+	mux.Handle("/v1/key/delete/", xhttp.EnforcePolicies(roles, xhttp.HandleDeleteKey(store, roles)))
+	mux.Handle("/v1/key/generate/", xhttp.EnforcePolicies(roles, xhttp.HandleGenerateKey(store)))
+	mux.Handle("/v1/key/decrypt/", xhttp.EnforcePolicies(roles, xhttp.HandleDecryptKey(store)))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &Server{
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAnyClientCert,
+		},
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client, conn := newClient(t, listener.Addr().String(), clientCert, serverCert)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err = client.CreateKey(ctx, &kespb.CreateKeyRequest{Name: "my-app/my-key"}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = client.CreateKey(ctx, &kespb.CreateKeyRequest{Name: "my-app/my-key"}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Creating an existing key should fail with %v: got %v", codes.AlreadyExists, err)
+	}
+	if _, err = client.CreateKey(ctx, &kespb.CreateKeyRequest{Name: "../my-key"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Creating a key with an invalid name should fail with %v: got %v", codes.InvalidArgument, err)
+	}
+
+	dek, err := client.GenerateKey(ctx, &kespb.GenerateKeyRequest{Name: "my-app/my-key", Context: []byte("context")})
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	plaintext, err := client.DecryptKey(ctx, &kespb.DecryptKeyRequest{Name: "my-app/my-key", Ciphertext: dek.Ciphertext, Context: []byte("context")})
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key: %v", err)
+	}
+	if !bytes.Equal(plaintext.Plaintext, dek.Plaintext) {
+		t.Fatalf("Plaintext mismatch: got %x - want %x", plaintext.Plaintext, dek.Plaintext)
+	}
+	if _, err = client.DecryptKey(ctx, &kespb.DecryptKeyRequest{Name: "my-app/my-key", Ciphertext: dek.Ciphertext}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Decrypting with a different context should fail with %v: got %v", codes.InvalidArgument, err)
+	}
+
+	// Clients without policy are not allowed to use the key.
+	other, otherConn := newClient(t, listener.Addr().String(), otherCert, serverCert)
+	defer otherConn.Close()
+	if _, err = other.GenerateKey(ctx, &kespb.GenerateKeyRequest{Name: "my-app/my-key"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Client without policy should not be allowed to generate keys: got %v", err)
+	}
+
+	if _, err = client.DeleteKey(ctx, &kespb.DeleteKeyRequest{Name: "my-app/my-key"}); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = client.GenerateKey(ctx, &kespb.GenerateKeyRequest{Name: "my-app/my-key"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Generating a data key with a deleted key should fail with %v: got %v", codes.NotFound, err)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	serverCert, clientCert := newCertificate(t), newCertificate(t)
+	identity := sha256.Sum256(clientCert.Leaf.RawSubjectPublicKeyInfo)
+
+	store := &secret.Store{Remote: &mem.Store{}}
+	if err := store.Create(context.Background(), "my-key", secret.Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	roles := &auth.Roles{Root: kes.Identity(hex.EncodeToString(identity[:]))}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/key/generate/", xhttp.EnforcePolicies(roles, xhttp.HandleGenerateKey(store)))
+	mux.Handle("/v1/key/decrypt/", xhttp.EnforcePolicies(roles, xhttp.HandleDecryptKey(store)))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &Server{
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAnyClientCert,
+		},
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client, conn := newClient(t, listener.Addr().String(), clientCert, serverCert)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const N = 16
+	generate, err := client.GenerateKeys(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	for i := 0; i < N; i++ {
+		if err = generate.Send(&kespb.GenerateKeyRequest{Name: "my-key", Context: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Failed to send request %d: %v", i, err)
+		}
+	}
+	generate.CloseSend()
+
+	var deks []*kespb.GenerateKeyResponse
+	for {
+		dek, err := generate.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive data key: %v", err)
+		}
+		deks = append(deks, dek)
+	}
+	if len(deks) != N {
+		t.Fatalf("Invalid number of data keys: got %d - want %d", len(deks), N)
+	}
+
+	decrypt, err := client.DecryptKeys(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	for i, dek := range deks {
+		if err = decrypt.Send(&kespb.DecryptKeyRequest{Name: "my-key", Ciphertext: dek.Ciphertext, Context: []byte{byte(i)}}); err != nil {
+			t.Fatalf("Failed to send request %d: %v", i, err)
+		}
+		plaintext, err := decrypt.Recv()
+		if err != nil {
+			t.Fatalf("Failed to decrypt data key %d: %v", i, err)
+		}
+		if !bytes.Equal(plaintext.Plaintext, dek.Plaintext) {
+			t.Fatalf("Plaintext %d mismatch: got %x - want %x", i, plaintext.Plaintext, dek.Plaintext)
+		}
+	}
+
+	// The stream ends with the first error.
+	if err = decrypt.Send(&kespb.DecryptKeyRequest{Name: "my-key", Ciphertext: deks[0].Ciphertext}); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	if _, err = decrypt.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Decrypting with a different context should fail with %v: got %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &Server{
+		Handler:   http.NotFoundHandler(),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{newCertificate(t)}},
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve(listener) }()
+
+	time.Sleep(10 * time.Millisecond)
+	server.Close()
+	select {
+	case err = <-errCh:
+		if err != ErrServerClosed {
+			t.Fatalf("Serve returned %v - want %v", err, ErrServerClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+}
+
+func newClient(t *testing.T, addr string, cert, serverCert tls.Certificate) (kespb.KeyServiceClient, *grpc.ClientConn) {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.Leaf)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+		ServerName:   "localhost",
+	})))
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", addr, err)
+	}
+	return kespb.NewKeyServiceClient(conn), conn
+}
+
+func newCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key, Leaf: leaf}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleSetAlias returns an http.HandlerFunc that creates or
+// updates the alias referenced by the request URL - e.g.
+// /v1/key/alias/set/my-alias. The request body contains the
+// name of the key the alias should refer to:
+//  {
+//    "key": "<key-name>"
+//  }
+//
+// Once set, the key APIs accept the alias in place of the key
+// name. Policies are verified against the alias - not against
+// the name of the key it refers to.
+//
+// An alias can only refer to a key within its own namespace.
+// In particular, an alias of a tenant can only refer to a key
+// of the same tenant.
+func HandleSetAlias(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidJSON      = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidAliasName = kes.NewError(http.StatusBadRequest, "invalid alias name")
+		ErrInvalidKeyName   = kes.NewError(http.StatusBadRequest, "invalid key name")
+	)
+	type Request struct {
+		Key string `json:"key"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := keyName(r)
+		if alias == "" {
+			Error(w, ErrInvalidAliasName)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if !secret.ValidName(req.Key) {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		name := req.Key
+		if t, ok := tenantOf(r); ok {
+			name = t.Tenants.KeyName(t.Name, name)
+		}
+
+		aliasNamespace, _ := splitNamespace(roles.Tenants, alias)
+		if namespace, _ := splitNamespace(roles.Tenants, name); namespace != aliasNamespace {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		if err := store.SetAlias(alias, name); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleDeleteAlias returns an http.HandlerFunc that deletes
+// the alias referenced by the request URL - e.g.
+// /v1/key/alias/delete/my-alias. It does not delete the key
+// the alias refers to.
+func HandleDeleteAlias(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidAliasName = kes.NewError(http.StatusBadRequest, "invalid alias name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := keyName(r)
+		if alias == "" {
+			Error(w, ErrInvalidAliasName)
+			return
+		}
+		if err := store.DeleteAlias(alias); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleListAliases returns an http.HandlerFunc that lists
+// all aliases with a name matching the pattern of the request
+// URL - e.g. /v1/key/alias/list/my-app-*. It responds with a
+// JSON array of aliases and the keys they refer to:
+//  [
+//    {
+//      "alias": "<alias-name>",
+//      "key":   "<key-name>"
+//    }
+//  ]
+//
+// A tenant identity only sees the aliases of its tenant,
+// without the tenant prefix.
+func HandleListAliases(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	type Response struct {
+		Alias string `json:"alias"`
+		Key   string `json:"key"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern, _ := auth.APIKeyName(r.URL.Path)
+		t, isTenant := tenantOf(r)
+
+		var aliases = []Response{}
+		for alias, name := range store.Aliases() {
+			namespace, _ := splitNamespace(roles.Tenants, alias)
+			if isTenant && namespace != t.Name {
+				continue
+			}
+			if !isTenant && namespace != "" {
+				continue // Only the tenant can access its aliases
+			}
+
+			alias, name = clientName(r, alias), clientName(r, name)
+			if !kes.MatchPath(pattern, alias) {
+				continue
+			}
+			aliases = append(aliases, Response{
+				Alias: alias,
+				Key:   name,
+			})
+		}
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(aliases)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestHandleAlias(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	for i, name := range []string{"key-1", "key-2", "tenant-a/key-3"} {
+		var key secret.Secret
+		key[0] = byte(i + 1) // Each key must be distinct
+		if err := store.Create(context.Background(), name, key); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	roles := &auth.Roles{Tenants: new(auth.Tenants)}
+	if err := roles.Tenants.Add("tenant-a", "af43c"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	send := func(method, path, body string, handler http.HandlerFunc) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(http.MethodPost, "/v1/key/alias/set/current", `{"key":"key-1"}`, HandleSetAlias(store, roles)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to set alias: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	resp := send(http.MethodPost, "/v1/key/generate/current", `{}`, HandleGenerateKey(store))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate key via alias: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var dek struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &dek); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if usage, ok := store.Usage("key-1"); !ok || usage.Ops["generate"] != 1 {
+		t.Fatalf("Usage has not been recorded for the key: %v", usage)
+	}
+
+	// Once the alias refers to another key, ciphertexts
+	// produced before can only be decrypted with the key
+	// that has produced them.
+	if resp = send(http.MethodPost, "/v1/key/alias/set/current", `{"key":"key-2"}`, HandleSetAlias(store, roles)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to update alias: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	body, _ := json.Marshal(map[string][]byte{"ciphertext": dek.Ciphertext})
+	if resp = send(http.MethodPost, "/v1/key/decrypt/current", string(body), HandleDecryptKey(store)); resp.StatusCode == http.StatusOK {
+		t.Fatal("Decrypted ciphertext with the key the alias refers to now")
+	}
+	if resp = send(http.MethodPost, "/v1/key/decrypt/key-1", string(body), HandleDecryptKey(store)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to decrypt key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+
+	// Identities that are not bound to a tenant cannot
+	// refer to the keys of a tenant.
+	if resp = send(http.MethodPost, "/v1/key/alias/set/other", `{"key":"tenant-a/key-3"}`, HandleSetAlias(store, roles)); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Set alias to key of tenant: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = send(http.MethodPost, "/v1/key/alias/set/key-2", `{"key":"key-1"}`, HandleSetAlias(store, roles)); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Set alias with the name of a key: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp = send(http.MethodGet, "/v1/key/alias/list/**", "", HandleListAliases(store, roles))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to list aliases: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if got, want := strings.TrimSpace(resp.Body.String()), `[{"alias":"current","key":"key-2"}]`; got != want {
+		t.Fatalf("Invalid aliases: got %s - want %s", got, want)
+	}
+
+	if resp = send(http.MethodDelete, "/v1/key/alias/delete/current", "", HandleDeleteAlias(store)); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete alias: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp = send(http.MethodPost, "/v1/key/generate/current", `{}`, HandleGenerateKey(store)); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Generated key via deleted alias: got %d - want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
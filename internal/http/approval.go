@@ -0,0 +1,82 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+// HandleIssueApproval returns a handler function that issues
+// an approval token of the request identity for a request of
+// the root identity. The request is specified by the request
+// body:
+//  {
+//    "method": "<method>",   // e.g. DELETE
+//    "path":   "<path>"      // e.g. /v1/enclave/delete/my-enclave
+//  }
+//
+// It responds with the token and when it expires:
+//  {
+//    "token":  "<token>",
+//    "expiry": "<time>"
+//  }
+// The root identity has to send the approved request with the
+// tokens of enough admins. See: auth.Approvals
+func HandleIssueApproval(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrApprovalDisabled = kes.NewError(http.StatusNotImplemented, "approvals are not enabled")
+		ErrInvalidJSON      = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidMethod    = kes.NewError(http.StatusBadRequest, "invalid method")
+		ErrInvalidPath      = kes.NewError(http.StatusBadRequest, "invalid API path")
+	)
+	type Request struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+	}
+	type Response struct {
+		Token  string    `json:"token"`
+		Expiry time.Time `json:"expiry"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if roles.Approvals == nil {
+			Error(w, ErrApprovalDisabled)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete:
+		default:
+			Error(w, ErrInvalidMethod)
+			return
+		}
+		if !strings.HasPrefix(req.Path, "/") || path.Clean(req.Path) != req.Path {
+			Error(w, ErrInvalidPath)
+			return
+		}
+
+		token, expiry, err := roles.Approvals.Issue(auth.Identify(r, roles.Identify), req.Method, req.Path)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Token:  token,
+			Expiry: expiry,
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/attest"
+)
+
+// HandleAttest returns an http.HandlerFunc that responds
+// with a hardware attestation report produced by the
+// provider. The request body must contain a nonce of 16
+// to 64 bytes chosen by the client:
+//  {
+//    "nonce": "<base64-nonce>"
+//  }
+//
+// The report data of the hardware report binds the public
+// key of the server's TLS certificate - as returned by
+// getCertificate - to the nonce. See: kes.Attestation
+//  {
+//    "type":       "sev-snp" | "tdx" | "sgx",
+//    "report":     "<base64-report>",
+//    "public_key": "<base64-DER-public-key>",
+//    "nonce":      "<base64-nonce>"
+//  }
+func HandleAttest(provider attest.Provider, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) http.HandlerFunc {
+	var (
+		ErrInvalidJSON  = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidNonce = kes.NewError(http.StatusBadRequest, "nonce must be between 16 and 64 bytes")
+		ErrNoTLS        = kes.NewError(http.StatusBadRequest, "attestation requires a TLS connection")
+	)
+	type Request struct {
+		Nonce []byte `json:"nonce"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if len(req.Nonce) < 16 || len(req.Nonce) > 64 {
+			Error(w, ErrInvalidNonce)
+			return
+		}
+		if r.TLS == nil {
+			Error(w, ErrNoTLS)
+			return
+		}
+
+		cert, err := getCertificate(&tls.ClientHelloInfo{ServerName: r.TLS.ServerName})
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		leaf := cert.Leaf
+		if leaf == nil {
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		report, typ, err := provider.Report(kes.AttestationReportData(leaf.RawSubjectPublicKeyInfo, req.Nonce))
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(kes.Attestation{
+			Type:      typ,
+			Report:    report,
+			PublicKey: leaf.RawSubjectPublicKeyInfo,
+			Nonce:     req.Nonce,
+		})
+	}
+}
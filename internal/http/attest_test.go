@@ -0,0 +1,89 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// fakeSEVSNP is an attest.Provider that returns
+// a fake AMD SEV-SNP report containing the report
+// data.
+type fakeSEVSNP struct{}
+
+func (fakeSEVSNP) Report(reportData [64]byte) ([]byte, string, error) {
+	report := make([]byte, 1184)
+	copy(report[0x50:], reportData[:])
+	return report, kes.AttestationSEVSNP, nil
+}
+
+func TestHandleAttest(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return &tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key}, nil
+	}
+	handler := HandleAttest(fakeSEVSNP{}, getCertificate)
+
+	send := func(nonce []byte) *dummyResponseWriter {
+		body, _ := json.Marshal(map[string][]byte{"nonce": nonce})
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/attest", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return &resp
+	}
+
+	nonce := []byte("0123456789abcdef")
+	resp := send(nonce)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	var attestation kes.Attestation
+	if err = json.NewDecoder(&resp.Body).Decode(&attestation); err != nil {
+		t.Fatalf("Failed to decode attestation: %v", err)
+	}
+	if err = attestation.Verify(nonce, cert); err != nil {
+		t.Fatalf("Failed to verify attestation: %v", err)
+	}
+
+	if resp = send([]byte("short")); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Invalid status code for short nonce: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
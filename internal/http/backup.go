@@ -0,0 +1,56 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/backup"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleBackup returns a handler function that responds
+// with a signed backup archive of all keys, policies and
+// identity assignments. See: backup.Archive
+func HandleBackup(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		archive, err := backup.Create(store, roles)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(archive)
+	}
+}
+
+// HandleRestore returns a handler function that restores
+// the keys, policies and identity assignments of the backup
+// archive sent by the client. It responds with the number
+// of restored and skipped keys:
+//  {
+//    "keys":    <n>,
+//    "skipped": <n>
+//  }
+func HandleRestore(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	return func(w http.ResponseWriter, r *http.Request) {
+		var archive backup.Archive
+		if err := json.NewDecoder(r.Body).Decode(&archive); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		report, err := backup.Restore(&archive, store, roles, auth.Identify(r, roles.Identify))
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleCreateCA returns an http.HandlerFunc that creates
+// a new certificate authority (CA) with the name referenced
+// by the request URL - e.g. /v1/ca/create/my-app/ca. The
+// request body may specify the common name of the CA:
+//  {
+//    "common_name": "<name>"
+//  }
+//
+// It responds with the PEM-encoded CA certificate:
+//  {
+//    "certificate": "<PEM-encoded-certificate>"
+//  }
+func HandleCreateCA(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		CommonName string `json:"common_name"`
+	}
+	type Response struct {
+		Certificate string `json:"certificate"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+
+		var req Request
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				Error(w, ErrInvalidJSON)
+				return
+			}
+		}
+		if err := reserveKey(r, roles, name); err != nil {
+			Error(w, err)
+			return
+		}
+		event := provenanceEvent(r, roles, secret.ProvenanceCreated)
+		cert, err := store.CreateCA(r.Context(), name, req.CommonName)
+		if err != nil {
+			releaseKey(roles, name)
+			Error(w, err)
+			return
+		}
+		if err = store.AppendProvenance(name, event); err != nil {
+			Error(w, err)
+			return
+		}
+		if roles.Quotas != nil {
+			if err = roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Certificate: string(cert),
+		})
+	}
+}
+
+// HandleSignCertificate returns an http.HandlerFunc that
+// issues a short-lived certificate for the certificate
+// request (CSR) sent as request body. The certificate is
+// signed by the CA referenced by the request URL - e.g.
+// /v1/ca/sign/my-app/ca:
+//  {
+//    "csr": "<PEM-encoded-CSR>",
+//    "ttl": "<duration>"
+//  }
+//
+// The ttl is optional and defaults to 24h. It responds
+// with the PEM-encoded certificate:
+//  {
+//    "certificate": "<PEM-encoded-certificate>"
+//  }
+func HandleSignCertificate(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidTTL     = kes.NewError(http.StatusBadRequest, "invalid certificate lifetime")
+	)
+	type Request struct {
+		CSR string `json:"csr"`
+		TTL string `json:"ttl"`
+	}
+	type Response struct {
+		Certificate string `json:"certificate"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		ttl := secret.DefaultCertificateTTL
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				Error(w, ErrInvalidTTL)
+				return
+			}
+		}
+
+		cert, err := store.SignCertificate(r.Context(), name, []byte(req.CSR), ttl)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		store.RecordUse(name, "sign")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Certificate: string(cert),
+		})
+	}
+}
+
+// HandleGetCAChain returns an http.HandlerFunc that returns
+// the PEM-encoded certificate chain of the CA referenced by
+// the request URL - e.g. /v1/ca/chain/my-app/ca:
+//  {
+//    "chain": "<PEM-encoded-certificates>"
+//  }
+//
+// Clients use the chain to verify certificates issued by
+// the CA. It never returns the private key of the CA.
+func HandleGetCAChain(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	type Response struct {
+		Chain string `json:"chain"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		chain, err := store.CACertificate(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Chain: string(chain),
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestHandleCA(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateCA(store, roles), http.MethodPost, "/v1/ca/create/my-ca", `{"common_name":"My CA"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create CA: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleGetSecret(store), http.MethodGet, "/v1/secret/get/my-ca", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Returned CA as opaque secret: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp := send(HandleGetCAChain(store), http.MethodGet, "/v1/ca/chain/my-ca", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to get CA chain: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var chain struct {
+		Chain string `json:"chain"`
+	}
+	if err := json.NewDecoder(&resp.Body).Decode(&chain); err != nil {
+		t.Fatalf("Failed to decode CA chain: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(chain.Chain)) {
+		t.Fatal("Failed to parse CA chain")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	rawCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "my-service"},
+	}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	csr, _ := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: rawCSR})))
+
+	if resp = send(HandleSignCertificate(store), http.MethodPost, "/v1/ca/sign/my-ca", `{"csr":`+string(csr)+`,"ttl":"720h"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Issued long-lived certificate: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	resp = send(HandleSignCertificate(store), http.MethodPost, "/v1/ca/sign/my-ca", `{"csr":`+string(csr)+`,"ttl":"1h"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to sign CSR: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var response struct {
+		Certificate string `json:"certificate"`
+	}
+	if err = json.NewDecoder(&resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode certificate: %v", err)
+	}
+	block, _ := pem.Decode([]byte(response.Certificate))
+	if block == nil {
+		t.Fatal("Certificate is not PEM-encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if _, err = cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("Failed to verify certificate: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Certificate is a TLS certificate that can be reloaded
+// from disk - e.g. after it has been rotated - without
+// restarting the server.
+//
+// A tls.Config should use its GetCertificate method such
+// that new TLS connections use the most recently loaded
+// certificate. Already established connections are not
+// affected by a reload.
+type Certificate struct {
+	// CertPath is the path of the PEM-encoded
+	// certificate (chain).
+	CertPath string
+
+	// KeyPath is the path of the PEM-encoded
+	// private key.
+	KeyPath string
+
+	// ErrorLog specifies an optional logger for errors
+	// when the certificate cannot be reloaded. If nil,
+	// logging is done via the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+
+	lock    sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// LoadCertificate loads the certificate and private key
+// from the given files.
+func LoadCertificate(certPath, keyPath string) (*Certificate, error) {
+	c := &Certificate{
+		CertPath: certPath,
+		KeyPath:  keyPath,
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the most recently loaded certificate.
+// It can be used as tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cert, nil
+}
+
+// Reload loads the certificate and private key from disk.
+// If they cannot be loaded, e.g. because they don't match,
+// Reload keeps the current certificate.
+func (c *Certificate) Reload() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertPath, c.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.cert, c.modTime = &cert, modTime
+	c.lock.Unlock()
+	return nil
+}
+
+// ReloadAfter checks periodically, every interval, whether the
+// certificate or private key file has been modified and reloads
+// them if so. It stops once the ctx.Done() channel returns.
+//
+// ReloadAfter does not block but starts a new go-routine.
+func (c *Certificate) ReloadAfter(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			modTime, err := c.lastModified()
+			if err != nil {
+				c.logf("http: failed to check TLS certificate: %v", err)
+				continue
+			}
+			c.lock.RLock()
+			modified := modTime.After(c.modTime)
+			c.lock.RUnlock()
+			if !modified {
+				continue
+			}
+			if err = c.Reload(); err != nil {
+				// The certificate and private key may not be updated
+				// at the same time. Therefore, we try it again on the
+				// next tick.
+				c.logf("http: failed to reload TLS certificate: %v", err)
+			}
+		}
+	}()
+}
+
+// lastModified returns the last modification time of
+// either the certificate or the private key file.
+func (c *Certificate) lastModified() (time.Time, error) {
+	certStat, err := os.Stat(c.CertPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyStat, err := os.Stat(c.KeyPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyStat.ModTime().After(certStat.ModTime()) {
+		return keyStat.ModTime(), nil
+	}
+	return certStat.ModTime(), nil
+}
+
+func (c *Certificate) logf(format string, v ...interface{}) {
+	if c.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		c.ErrorLog.Printf(format, v...)
+	}
+}
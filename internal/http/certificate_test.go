@@ -0,0 +1,95 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a new self-signed certificate
+// with the given common name and its private key to the
+// given files.
+func writeCertificate(t *testing.T, certPath, keyPath, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	privateKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+	if err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKey}), 0600); err != nil {
+		t.Fatalf("Failed to write private key: %v", err)
+	}
+}
+
+func commonName(t *testing.T, c *Certificate) string {
+	cert, _ := c.GetCertificate(nil)
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return x509Cert.Subject.CommonName
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-certificate")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := filepath.Join(dir, "server.cert"), filepath.Join(dir, "server.key")
+	writeCertificate(t, certPath, keyPath, "first")
+	cert, err := LoadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	if name := commonName(t, cert); name != "first" {
+		t.Fatalf("Common name mismatch: got '%s' - want '%s'", name, "first")
+	}
+
+	writeCertificate(t, certPath, keyPath, "second")
+	if err = cert.Reload(); err != nil {
+		t.Fatalf("Failed to reload certificate: %v", err)
+	}
+	if name := commonName(t, cert); name != "second" {
+		t.Fatalf("Common name mismatch: got '%s' - want '%s'", name, "second")
+	}
+
+	// A certificate that does not match the private key must
+	// not replace the current certificate.
+	writeCertificate(t, certPath, filepath.Join(dir, "other.key"), "third")
+	if err = cert.Reload(); err == nil {
+		t.Fatal("Reload should have failed: certificate does not match private key")
+	}
+	if name := commonName(t, cert); name != "second" {
+		t.Fatalf("Common name mismatch: got '%s' - want '%s'", name, "second")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/cluster"
+)
+
+// EnforceClusterPeer returns an http.HandlerFunc that
+// only calls f if the request has been sent by a member
+// of the cluster. Otherwise, it responds with
+// kes.ErrNotAllowed.
+//
+// The cluster API is not subject to policies since
+// it is only used by the cluster members themselves.
+func EnforceClusterPeer(node *cluster.Node, roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !node.IsPeer(auth.Identify(r, roles.Identify)) {
+			Error(w, kes.ErrNotAllowed)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// HandleClusterVote returns a handler function that
+// handles Raft vote requests of cluster candidates.
+func HandleClusterVote(node *cluster.Node) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req cluster.VoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		resp, err := node.RequestVote(req)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleClusterAppend returns a handler function that
+// handles Raft append requests of the cluster leader.
+func HandleClusterAppend(node *cluster.Node) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req cluster.AppendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		resp, err := node.AppendEntries(req)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleClusterPropose returns a handler function that
+// replicates a write forwarded by another cluster member.
+// It responds with 200 OK once the write has been applied
+// and with the error of the write otherwise.
+func HandleClusterPropose(node *cluster.Node) http.HandlerFunc {
+	var (
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidCommand = kes.NewError(http.StatusBadRequest, "invalid cluster command")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var cmd cluster.Command
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if (cmd.Op != cluster.OpCreate && cmd.Op != cluster.OpDelete) || cmd.Key == "" {
+			Error(w, ErrInvalidCommand)
+			return
+		}
+		if err := node.Propose(r.Context(), cmd); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleClusterStatus returns a handler function that
+// returns the Raft state of the cluster node as JSON.
+// In particular, it returns:
+//  {
+//    "id":           "<node>",
+//    "role":         "leader" | "follower" | "candidate",
+//    "leader":       "<node>",
+//    "term":         <n>,
+//    "last_index":   <n>,
+//    "commit_index": <n>,
+//    "last_applied": <n>
+//  }
+func HandleClusterStatus(node *cluster.Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(node.Status())
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kes/internal/secret"
+)
+
+// ConcurrencyLimiter limits the number of concurrent
+// in-flight requests of each identity.
+//
+// In contrast to a RateLimiter, it does not limit how
+// often an identity sends requests but how many of its
+// requests the server processes at the same time.
+// Therefore, one identity with many slow requests cannot
+// occupy all server resources.
+//
+// Its zero value is a usable ConcurrencyLimiter that
+// does not limit any requests.
+type ConcurrencyLimiter struct {
+	lock       sync.Mutex
+	limit      int
+	identities map[kes.Identity]int
+	inFlight   map[kes.Identity]int
+	rejected   map[kes.Identity]uint64
+}
+
+// SetLimits replaces the current limits of the
+// ConcurrencyLimiter.
+//
+// The limit applies to any identity unless there is
+// an identity-specific limit. A limit <= 0 does not
+// limit any requests.
+//
+// Requests that are already in-flight are not affected.
+func (l *ConcurrencyLimiter) SetLimits(limit int, identities map[kes.Identity]int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.limit = limit
+	l.identities = identities
+}
+
+// Acquire reports whether the identity may send another
+// request now. If so, the request is in-flight until the
+// caller calls Release.
+func (l *ConcurrencyLimiter) Acquire(identity kes.Identity) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limit, ok := l.identities[identity]
+	if !ok {
+		limit = l.limit
+	}
+	if limit > 0 && l.inFlight[identity] >= limit {
+		if l.rejected == nil {
+			l.rejected = map[kes.Identity]uint64{}
+		}
+		l.rejected[identity]++
+		return false
+	}
+	if l.inFlight == nil {
+		l.inFlight = map[kes.Identity]int{}
+	}
+	l.inFlight[identity]++
+	return true
+}
+
+// Release marks one in-flight request of the
+// identity as done.
+func (l *ConcurrencyLimiter) Release(identity kes.Identity) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if n := l.inFlight[identity]; n > 1 {
+		l.inFlight[identity] = n - 1
+	} else {
+		delete(l.inFlight, identity)
+	}
+}
+
+// InFlight returns the number of in-flight requests
+// of each identity with at least one such request.
+func (l *ConcurrencyLimiter) InFlight() []metric.Sample {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	samples := make([]metric.Sample, 0, len(l.inFlight))
+	for identity, n := range l.inFlight {
+		samples = append(samples, metric.Sample{Labels: []string{identity.String()}, Value: float64(n)})
+	}
+	sortSamples(samples)
+	return samples
+}
+
+// Rejected returns the number of rejected requests
+// of each identity with at least one such request.
+func (l *ConcurrencyLimiter) Rejected() []metric.Sample {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	samples := make([]metric.Sample, 0, len(l.rejected))
+	for identity, n := range l.rejected {
+		samples = append(samples, metric.Sample{Labels: []string{identity.String()}, Value: float64(n)})
+	}
+	sortSamples(samples)
+	return samples
+}
+
+func sortSamples(samples []metric.Sample) {
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Labels[0] < samples[j].Labels[0] })
+}
+
+// LimitConcurrency returns an http.HandlerFunc that checks
+// whether the request identity has reached its limit of
+// concurrent requests before calling f. If so, it returns
+// 429 (too many requests) to the client.
+//
+// The root identity is not limited. LimitConcurrency should
+// be called after the request has been verified - e.g. by
+// EnforcePolicies. Otherwise, clients could track requests
+// of arbitrary identities.
+func LimitConcurrency(limiter *ConcurrencyLimiter, roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := auth.Identify(r, roles.Identify)
+		if !secret.EqualIdentity(identity, roles.Root) {
+			if !limiter.Acquire(identity) {
+				w.Header().Set("Retry-After", "1")
+				Error(w, kes.ErrTooManyConcurrentRequests)
+				return
+			}
+			defer limiter.Release(identity)
+		}
+		f(w, r)
+	}
+}
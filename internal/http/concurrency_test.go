@@ -0,0 +1,61 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	var limiter ConcurrencyLimiter
+	for i := 0; i < 100; i++ {
+		if !limiter.Acquire("af43c") {
+			t.Fatalf("Request %d: zero ConcurrencyLimiter should not limit any request", i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		limiter.Release("af43c")
+	}
+	if samples := limiter.InFlight(); len(samples) != 0 {
+		t.Fatalf("Invalid in-flight requests: got %v - want none", samples)
+	}
+
+	limiter.SetLimits(2, map[kes.Identity]int{"b2ce1": 4})
+	for i := 0; i < 2; i++ {
+		if !limiter.Acquire("af43c") {
+			t.Fatalf("Request %d: should be allowed", i)
+		}
+	}
+	if limiter.Acquire("af43c") {
+		t.Fatal("Request should have been limited")
+	}
+	if !limiter.Acquire("c4d3e") {
+		t.Fatal("Request of another identity should not be limited")
+	}
+	limiter.Release("af43c")
+	if !limiter.Acquire("af43c") {
+		t.Fatal("Request should be allowed once another request has completed")
+	}
+
+	for i := 0; i < 4; i++ {
+		if !limiter.Acquire("b2ce1") {
+			t.Fatalf("Request %d: identity-specific limit is not applied", i)
+		}
+	}
+	if limiter.Acquire("b2ce1") {
+		t.Fatal("Request should have been limited by the identity-specific limit")
+	}
+
+	inFlight := limiter.InFlight()
+	if len(inFlight) != 3 || inFlight[0].Labels[0] != "af43c" || inFlight[0].Value != 2 {
+		t.Fatalf("Invalid in-flight requests: %v", inFlight)
+	}
+	rejected := limiter.Rejected()
+	if len(rejected) != 2 || rejected[0].Value != 1 || rejected[1].Labels[0] != "b2ce1" {
+		t.Fatalf("Invalid rejected requests: %v", rejected)
+	}
+}
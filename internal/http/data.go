@@ -0,0 +1,149 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/envelope"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleEncryptData returns an http.HandlerFunc that encrypts
+// the request body with a new data encryption key (DEK) and
+// responds with an envelope that contains the DEK, encrypted
+// with the key referenced by the request URL, and the encrypted
+// data. See: envelope.NewWriter
+//
+// The request body is encrypted while it is read. Hence, the
+// server does not buffer the data. If reading the request body
+// fails after the response has been started - e.g. because it
+// exceeds the request body limit - the response is aborted such that the client
+// does not receive an incomplete envelope.
+func HandleEncryptData(store *secret.Store, maxSize int64) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSize {
+			Error(w, kes.ErrRequestTooLarge)
+			return
+		}
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpGenerate)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+
+		dek, err := store.Random(32)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(dek)
+
+		ciphertext, err := key.Wrap(dek, nil)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		store.RecordUse(name, "generate")
+
+		// The envelope contains the key name as seen by
+		// the client - i.e. without any tenant prefix. If
+		// the request refers to an alias, it contains the
+		// name of the key the alias refers to.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		ew, err := envelope.NewWriter(struct{ io.Writer }{w}, clientName(r, name), dek, ciphertext)
+		if err != nil {
+			panic(http.ErrAbortHandler) // The response has been started
+		}
+		if _, err = io.Copy(ew, r.Body); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if err = ew.Close(); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// HandleDecryptData returns an http.HandlerFunc that decrypts
+// the envelope sent as request body and responds with the
+// plaintext data. The DEK of the envelope must have been
+// encrypted with the key referenced by the request URL.
+//
+// The envelope is decrypted while it is read. If the data
+// turns out to be not authentic - e.g. because it has been
+// modified or truncated - after the response has been started,
+// the response is aborted. Clients must not use the plaintext
+// of an aborted response.
+func HandleDecryptData(store *secret.Store, maxSize int64) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrKeyMismatch    = kes.NewError(http.StatusBadRequest, "envelope has been encrypted with a different key")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSize {
+			Error(w, kes.ErrRequestTooLarge)
+			return
+		}
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		header, body, err := envelope.ReadHeader(r.Body)
+		if err != nil {
+			Error(w, kes.NewError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		if header.Key != clientName(r, name) {
+			Error(w, ErrKeyMismatch)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpDecrypt)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+
+		dek, err := key.Unwrap(header.DEK, nil)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(dek)
+		store.RecordUse(name, "decrypt")
+		plaintext, err := header.NewReader(body, dek)
+		if err != nil {
+			Error(w, kes.NewError(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		// We decrypt the first chunk before we start the
+		// response such that the client receives an error
+		// status if the envelope is not authentic at all.
+		buf := make([]byte, 32*1024)
+		n, err := io.ReadFull(plaintext, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			Error(w, kes.NewError(http.StatusBadRequest, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err = w.Write(buf[:n]); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if _, err = io.CopyBuffer(w, plaintext, buf); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/enclave"
+)
+
+// SelectEnclave returns a Router that dispatches requests
+// that contain a kes.HeaderEnclave header to the handler of
+// the referenced enclave. All other requests are dispatched
+// via the router.
+//
+// If the referenced enclave does not exist, the request
+// is rejected.
+//
+// If enclaves is nil, SelectEnclave returns the router.
+func SelectEnclave(enclaves *enclave.Manager, router Router) Router {
+	if enclaves == nil {
+		return router
+	}
+	return enclaveRouter{Router: router, enclaves: enclaves}
+}
+
+type enclaveRouter struct {
+	Router
+
+	enclaves *enclave.Manager
+}
+
+func (e enclaveRouter) Handler(r *http.Request) (http.Handler, string) {
+	name := r.Header.Get(kes.HeaderEnclave)
+	if name == "" {
+		return e.Router.Handler(r)
+	}
+
+	enc, ok := e.enclaves.Get(name)
+	if !ok || enc.Handler == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, enclave.ErrNotFound)
+		}), ""
+	}
+	if router, ok := enc.Handler.(Router); ok {
+		return router.Handler(r)
+	}
+	return enc.Handler, ""
+}
+
+func (e enclaveRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(kes.HeaderEnclave) == "" {
+		e.Router.ServeHTTP(w, r)
+		return
+	}
+	h, _ := e.Handler(r)
+	h.ServeHTTP(w, r)
+}
+
+// HandleCreateEnclave returns a handler function that creates
+// a new enclave with the name specified by the request URL path
+// base. The request body contains the enclave root identity:
+//  {
+//    "root": "<identity>"
+//  }
+func HandleCreateEnclave(enclaves *enclave.Manager) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	type Request struct {
+		Root kes.Identity `json:"root"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if err := enclaves.Create(pathBase(r.URL.Path), req.Root); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleDeleteEnclave returns a handler function that deletes
+// the enclave specified by the request URL path base.
+func HandleDeleteEnclave(enclaves *enclave.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := enclaves.Delete(pathBase(r.URL.Path)); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleListEnclaves returns a handler function that responds
+// with the name and root identity of all enclaves.
+func HandleListEnclaves(enclaves *enclave.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(enclaves.List())
+	}
+}
@@ -5,37 +5,84 @@
 package http
 
 import (
-	"fmt"
+	"encoding/json"
 	"io"
 	"net/http"
+
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/log"
 )
 
 // Error sends the given err as JSON error responds to w.
 //
-// If err has a 'Status() int' method then Error sets the
-// response status code to err.Status(). Otherwise, it will
+// If err is or wraps a kes.Error then Error sends its status
+// code, error message and error code - but not the message
+// of any wrapping error. See: errs.Error
+//
+// Otherwise, if err has a 'Status() int' method then Error sets
+// the response status code to err.Status(). Otherwise, it will
 // send 500 (internal server error).
 //
+// If w is an audit log response writer then Error records
+// the error code in the audit event.
+//
 // If err is nil then Error will send the status code 500 and
 // an empty JSON response body - i.e. '{}'.
 func Error(w http.ResponseWriter, err error) error {
-	var status = http.StatusInternalServerError
-	if e, ok := err.(interface{ Status() int }); ok {
-		status = e.Status()
+	type Response struct {
+		Message string `json:"message"`
+		Code    string `json:"code,omitempty"`
+	}
+	status, message, code := errorStatus(err)
+	if aw, ok := auditWriter(w); ok {
+		aw.ErrorCode = code
 	}
-
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.WriteHeader(status)
 
-	const (
-		emptyMsg = `{}`
-		format   = `{"message":"%v"}`
-	)
 	if err == nil {
-		_, err = io.WriteString(w, emptyMsg)
-	} else {
-		_, err = io.WriteString(w, fmt.Sprintf(format, err))
+		_, err = io.WriteString(w, `{}`)
+		return err
+	}
+	body, err := json.Marshal(Response{Message: message, Code: code})
+	if err != nil {
+		return err
 	}
+	_, err = w.Write(body)
 	return err
 }
+
+// errorStatus returns the response status code, error
+// message and error code that Error sends for err.
+func errorStatus(err error) (status int, message, code string) {
+	status = http.StatusInternalServerError
+	if kind, ok := errs.Kind(err); ok {
+		return kind.Status(), kind.Error(), kind.Code()
+	}
+	if err != nil {
+		if e, ok := err.(interface{ Status() int }); ok {
+			status = e.Status()
+		}
+		message = err.Error()
+	}
+	return status, message, code
+}
+
+// auditWriter returns the audit log response writer
+// that w sends the response to, if any. It looks through
+// response writers that record the response - like the
+// one used by Idempotent.
+func auditWriter(w http.ResponseWriter) (*log.AuditResponseWriter, bool) {
+	for {
+		switch rw := w.(type) {
+		case *idempotentWriter:
+			w = rw.ResponseWriter
+		case *statusWriter:
+			w = rw.ResponseWriter
+		default:
+			aw, ok := w.(*log.AuditResponseWriter)
+			return aw, ok
+		}
+	}
+}
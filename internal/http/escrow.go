@@ -0,0 +1,59 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/escrow"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleEscrow returns a handler function that exports
+// all keys matching at least one of the glob patterns sent
+// by the client to the escrow custodians:
+//  {
+//    "keys": [ "<pattern>", ... ]
+//  }
+//
+// It responds with an escrow package signed with the
+// signing key. Any threshold of custodians can recover
+// the keys offline. See: escrow.Package
+func HandleEscrow(store *secret.Store, custodians []escrow.Custodian, threshold int, signingKey ed25519.PrivateKey) http.HandlerFunc {
+	var (
+		ErrInvalidJSON   = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrNoPattern     = kes.NewError(http.StatusBadRequest, "no key pattern specified")
+		ErrNotConfigured = kes.NewError(http.StatusNotImplemented, "key escrow is not configured")
+	)
+	type Request struct {
+		Keys []string `json:"keys"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(custodians) == 0 || signingKey == nil {
+			Error(w, ErrNotConfigured)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if len(req.Keys) == 0 {
+			Error(w, ErrNoPattern)
+			return
+		}
+		pkg, err := escrow.Export(r.Context(), store, req.Keys, custodians, threshold, signingKey)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pkg)
+	}
+}
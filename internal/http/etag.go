@@ -0,0 +1,322 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/minio/kes"
+)
+
+// maxETagBody is the max. size of a response body for
+// which ETag computes an entity tag. Larger responses
+// are sent to the client as they are.
+const maxETagBody = 1 << 20
+
+// ETag returns an http.HandlerFunc that computes an entity
+// tag from the response body of f and sends it to the client
+// as ETag header. If the request contains an If-None-Match
+// header that matches the entity tag, it responds with
+// 304 Not Modified and without a body.
+//
+// Clients that poll read-only APIs - e.g. key metadata or
+// policies - can send the entity tag of the last response
+// and only receive a response body when the result has
+// changed.
+//
+// ETag only tags successful responses of at most 1 MiB.
+// It should only wrap handlers of read-only APIs.
+func ETag(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ew := &etagWriter{ResponseWriter: w}
+		f(ew, r)
+		if ew.passthrough {
+			return
+		}
+
+		sum := sha256.Sum256(ew.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if matchETag(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(ew.body.Bytes())
+	}
+}
+
+// matchETag reports whether the If-None-Match header
+// value matches the entity tag. It uses the weak
+// comparison - i.e. W/"x" matches "x".
+func matchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionLocks serializes conditional requests with
+// all other modifications of the same key or policy such
+// that the precondition of a request still holds when the
+// request gets applied.
+//
+// The locks are node-local. They do not coordinate the
+// nodes of a cluster that share the same key store. A
+// modification applied by another node may still race
+// with a conditional request.
+var preconditionLocks = lockTable{locks: map[string]*nameLock{}}
+
+// LockPreconditions returns an http.HandlerFunc that calls f
+// while holding the precondition lock of the key or policy
+// referenced by the request URL path within the enclave of
+// the request. A conditional request - i.e. a request with
+// an If-Match header - holds the lock exclusively. Hence, no
+// other modification of the same key or policy gets applied
+// between checking its precondition and applying it. All
+// other requests share the lock.
+//
+// Requests that modify many keys or policies at once - e.g.
+// a bulk deletion or a restore - exclude all other
+// modifications while being applied.
+//
+// LockPreconditions must wrap every handler that modifies
+// keys or policies. Otherwise, an unconditional request can
+// invalidate the precondition of a concurrent conditional
+// request.
+func LockPreconditions(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, ok := preconditionName(r)
+		if !ok {
+			preconditionLocks.all.Lock()
+			defer preconditionLocks.all.Unlock()
+
+			f(w, r)
+			return
+		}
+
+		exclusive := r.Header.Get("If-Match") != ""
+		preconditionLocks.acquire(name, exclusive)
+		defer preconditionLocks.release(name, exclusive)
+		f(w, r)
+	}
+}
+
+// PreconditionLock returns the shared precondition lock of
+// the key with the given name within the enclave. The empty
+// enclave refers to the keys of the server itself. Code that
+// modifies keys outside the HTTP API - e.g. KMIP or jobs -
+// must hold it while applying the modification.
+func PreconditionLock(enclave, key string) sync.Locker {
+	return nameLocker{name: lockName(enclave, "key", key)}
+}
+
+// ExclusivePreconditionLock returns the exclusive precondition
+// lock of the key with the given name within the enclave. Code
+// that must not run concurrently to any modification of the
+// key - e.g. code that replaces the stored value of the key -
+// must hold it.
+func ExclusivePreconditionLock(enclave, key string) sync.Locker {
+	return nameLocker{name: lockName(enclave, "key", key), exclusive: true}
+}
+
+// preconditionName returns the name of the precondition lock
+// of the key or policy referenced by the request URL path. It
+// returns false if the request does not reference a single
+// key or policy.
+func preconditionName(r *http.Request) (string, bool) {
+	enclave := r.Header.Get(kes.HeaderEnclave)
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/key/bulk/"):
+		return "", false
+	case strings.HasPrefix(r.URL.Path, "/v1/policy/"):
+		return lockName(enclave, "policy", pathBase(r.URL.Path)), true
+	}
+	if name := keyName(r); name != "" {
+		return lockName(enclave, "key", name), true
+	}
+	return "", false
+}
+
+// lockName returns the name of the precondition lock of
+// the key or policy with the given name within the enclave.
+func lockName(enclave, kind, name string) string {
+	return enclave + "\x00" + kind + "\x00" + name
+}
+
+// lockTable is a set of read-write locks referenced by name.
+// It only keeps the locks that are currently held or waited
+// for.
+type lockTable struct {
+	// all is held exclusively by modifications of many
+	// names and shared by modifications of a single name.
+	all sync.RWMutex
+
+	mu    sync.Mutex
+	locks map[string]*nameLock
+}
+
+type nameLock struct {
+	sync.RWMutex
+	refs int // The number of goroutines holding or waiting for the lock
+}
+
+// acquire acquires the lock with the given name - either
+// exclusively or shared.
+func (t *lockTable) acquire(name string, exclusive bool) {
+	t.all.RLock()
+
+	t.mu.Lock()
+	lock, ok := t.locks[name]
+	if !ok {
+		lock = new(nameLock)
+		t.locks[name] = lock
+	}
+	lock.refs++
+	t.mu.Unlock()
+
+	if exclusive {
+		lock.Lock()
+	} else {
+		lock.RLock()
+	}
+}
+
+// release releases the lock with the given name that
+// has been acquired before.
+func (t *lockTable) release(name string, exclusive bool) {
+	t.mu.Lock()
+	lock := t.locks[name]
+	if lock.refs--; lock.refs == 0 {
+		delete(t.locks, name)
+	}
+	t.mu.Unlock()
+
+	if exclusive {
+		lock.Unlock()
+	} else {
+		lock.RUnlock()
+	}
+	t.all.RUnlock()
+}
+
+// nameLocker is a sync.Locker for a named precondition lock.
+type nameLocker struct {
+	name      string
+	exclusive bool
+}
+
+func (l nameLocker) Lock()   { preconditionLocks.acquire(l.name, l.exclusive) }
+func (l nameLocker) Unlock() { preconditionLocks.release(l.name, l.exclusive) }
+
+// checkPrecondition returns kes.ErrPreconditionFailed if the
+// request contains an If-Match header that does not match the
+// current version of the requested resource. An empty version
+// indicates that the resource does not exist.
+//
+// The If-Match header contains one or more versions, either
+// as entity tag - e.g. "<version>" - or as plain version. The
+// wildcard "*" matches any existing resource.
+func checkPrecondition(r *http.Request, version string) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return nil
+	}
+	if version != "" {
+		for _, tag := range strings.Split(ifMatch, ",") {
+			tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+			if tag == "*" || tag == version {
+				return nil
+			}
+		}
+	}
+	return kes.ErrPreconditionFailed
+}
+
+// policyVersion returns the version of the policy. It
+// matches the entity tag of the policy sent by ETag(
+// HandleReadPolicy) - without the surrounding quotes.
+func policyVersion(policy *kes.Policy) string {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(policy)
+	sum := sha256.Sum256(body.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
+
+// etagWriter is an http.ResponseWriter that buffers a
+// successful response body. It writes the response to the
+// underlying http.ResponseWriter directly if the status code
+// is not 200 OK or the response body exceeds maxETagBody.
+type etagWriter struct {
+	http.ResponseWriter
+
+	body        bytes.Buffer
+	status      int
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.body.Len()+len(b) > maxETagBody {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			return 0, err
+		}
+		w.body.Reset()
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// Flush sends the buffered response body to the client.
+// A flushed response is streamed and, therefore, does
+// not get an entity tag.
+func (w *etagWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+var matchETagTests = []struct {
+	IfNoneMatch string
+	ETag        string
+	Match       bool
+}{
+	{IfNoneMatch: "", ETag: `"abc"`, Match: false},                // 0
+	{IfNoneMatch: `"abc"`, ETag: `"abc"`, Match: true},            // 1
+	{IfNoneMatch: `W/"abc"`, ETag: `"abc"`, Match: true},          // 2
+	{IfNoneMatch: `"xyz", "abc"`, ETag: `"abc"`, Match: true},     // 3
+	{IfNoneMatch: "*", ETag: `"abc"`, Match: true},                // 4
+	{IfNoneMatch: `"xyz"`, ETag: `"abc"`, Match: false},           // 5
+	{IfNoneMatch: `abc`, ETag: `"abc"`, Match: false},             // 6
+	{IfNoneMatch: `"xyz",W/"abc"`, ETag: `"abc"`, Match: true},    // 7
+	{IfNoneMatch: `"ab", "c"`, ETag: `"abc"`, Match: false},       // 8
+	{IfNoneMatch: `W/"xyz" , "xyz"`, ETag: `"abc"`, Match: false}, // 9
+}
+
+func TestMatchETag(t *testing.T) {
+	for i, test := range matchETagTests {
+		if match := matchETag(test.IfNoneMatch, test.ETag); match != test.Match {
+			t.Fatalf("Test %d: got %v - want %v", i, match, test.Match)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		body = []byte(`{"name":"my-key"}`)
+		f    = ETag(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		})
+	)
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/describe/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	f(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if !bytes.Equal(resp.Body.Bytes(), body) {
+		t.Fatalf("Invalid response body: got %q - want %q", resp.Body.Bytes(), body)
+	}
+	etag := resp.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Response has no ETag")
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	resp = dummyResponseWriter{}
+	f(&resp, req)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusNotModified)
+	}
+	if resp.Body.Len() != 0 {
+		t.Fatalf("Response to a matching If-None-Match has a body: %q", resp.Body.Bytes())
+	}
+
+	body = []byte(`{"name":"my-key","id":"1"}`)
+	resp = dummyResponseWriter{}
+	f(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if newETag := resp.Header().Get("ETag"); newETag == etag {
+		t.Fatalf("ETag has not changed although the response body has changed: %s", newETag)
+	}
+}
+
+func TestETagPassthrough(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/describe/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("If-None-Match", "*")
+
+	var resp dummyResponseWriter
+	ETag(func(w http.ResponseWriter, r *http.Request) { Error(w, kes.ErrKeyNotFound) })(&resp, req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if etag := resp.Header().Get("ETag"); etag != "" {
+		t.Fatalf("Error response has an ETag: %s", etag)
+	}
+
+	var body = make([]byte, maxETagBody+1)
+	resp = dummyResponseWriter{}
+	ETag(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body[:10])
+		w.Write(body[10:])
+	})(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.Body.Len() != len(body) {
+		t.Fatalf("Invalid response body: got %d bytes - want %d", resp.Body.Len(), len(body))
+	}
+	if etag := resp.Header().Get("ETag"); etag != "" {
+		t.Fatalf("Large response has an ETag: %s", etag)
+	}
+}
+
+var checkPreconditionTests = []struct {
+	IfMatch string
+	Version string
+	Err     error
+}{
+	{IfMatch: "", Version: "abc", Err: nil},                                // 0
+	{IfMatch: "", Version: "", Err: nil},                                   // 1
+	{IfMatch: `"abc"`, Version: "abc", Err: nil},                           // 2
+	{IfMatch: `abc`, Version: "abc", Err: nil},                             // 3
+	{IfMatch: `"xyz", "abc"`, Version: "abc", Err: nil},                    // 4
+	{IfMatch: "*", Version: "abc", Err: nil},                               // 5
+	{IfMatch: "*", Version: "", Err: kes.ErrPreconditionFailed},            // 6
+	{IfMatch: `"xyz"`, Version: "abc", Err: kes.ErrPreconditionFailed},     // 7
+	{IfMatch: `"abc"`, Version: "", Err: kes.ErrPreconditionFailed},        // 8
+	{IfMatch: `"ab", "c"`, Version: "abc", Err: kes.ErrPreconditionFailed}, // 9
+}
+
+func TestCheckPrecondition(t *testing.T) {
+	for i, test := range checkPreconditionTests {
+		req, err := http.NewRequest(http.MethodDelete, "https://localhost:7373/v1/key/delete/my-key", nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if test.IfMatch != "" {
+			req.Header.Set("If-Match", test.IfMatch)
+		}
+		if err = checkPrecondition(req, test.Version); err != test.Err {
+			t.Fatalf("Test %d: got %v - want %v", i, err, test.Err)
+		}
+	}
+}
+
+func TestConditionalPolicyWrite(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	roles := &auth.Roles{}
+	read := ETag(HandleReadPolicy(roles))
+	write := HandleWritePolicy(roles)
+
+	send := func(f http.HandlerFunc, method, path, ifMatch, body string) *dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		var resp dummyResponseWriter
+		f(&resp, req)
+		return &resp
+	}
+
+	const policy = `{"paths":["/v1/key/generate/*"]}`
+	if resp := send(write, http.MethodPost, "/v1/policy/write/my-policy", `"abc"`, policy); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Conditional write of non-existing policy: got %d - want %d", resp.StatusCode, http.StatusPreconditionFailed)
+	}
+	if resp := send(write, http.MethodPost, "/v1/policy/write/my-policy", "", policy); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to write policy: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	etag := send(read, http.MethodGet, "/v1/policy/read/my-policy", "", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Policy has no entity tag")
+	}
+	if resp := send(write, http.MethodPost, "/v1/policy/write/my-policy", etag, `{"paths":["/v1/key/decrypt/*"]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to write policy conditionally: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp := send(write, http.MethodPost, "/v1/policy/write/my-policy", etag, policy); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Policy has been overwritten although it has been modified: got %d - want %d", resp.StatusCode, http.StatusPreconditionFailed)
+	}
+}
+
+func TestConditionalKeyDelete(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	if err := store.Create(context.Background(), "my-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	info, err := store.Stat("my-key")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	version := info.Version()
+
+	send := func(f http.HandlerFunc, method, path string) *dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("If-Match", `"`+version+`"`)
+		var resp dummyResponseWriter
+		f(&resp, req)
+		return &resp
+	}
+	if resp := send(HandleDisableKey(store), http.MethodPost, "/v1/key/disable/my-key"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to disable key conditionally: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp := send(HandleDeleteKey(store, roles), http.MethodDelete, "/v1/key/delete/my-key"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Key has been deleted although it has been modified: got %d - want %d", resp.StatusCode, http.StatusPreconditionFailed)
+	}
+	if _, err = store.Stat("my-key"); err != nil {
+		t.Fatalf("Key has been deleted: %v", err)
+	}
+
+	info, _ = store.Stat("my-key")
+	version = info.Version()
+	if resp := send(HandleDeleteKey(store, roles), http.MethodDelete, "/v1/key/delete/my-key"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete key conditionally: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestLockPreconditions(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	f := LockPreconditions(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+	send := func(path, enclave, ifMatch string) {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373"+path, nil)
+		if err != nil {
+			t.Errorf("Failed to create request: %v", err)
+			return
+		}
+		if enclave != "" {
+			req.Header.Set(kes.HeaderEnclave, enclave)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		f(&dummyResponseWriter{}, req)
+	}
+	mustWait := func() {
+		select {
+		case <-entered:
+			t.Fatal("Conditional request has been applied concurrently to another modification")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	const path = "/v1/key/disable/my-key"
+
+	// Unconditional requests share the lock but
+	// a conditional request waits for them.
+	go send(path, "", "")
+	go send(path, "", "")
+	<-entered
+	<-entered
+	go send(path, "", `"abc"`)
+	mustWait()
+	release <- struct{}{}
+	release <- struct{}{}
+	<-entered
+
+	// Modifications of other keys or of the same key
+	// within another enclave do not wait.
+	go send("/v1/key/disable/other-key", "", `"abc"`)
+	<-entered
+	go send(path, "my-enclave", `"abc"`)
+	<-entered
+	release <- struct{}{}
+	release <- struct{}{}
+	release <- struct{}{}
+
+	// Modifications outside the HTTP API share
+	// the lock, too.
+	lock := PreconditionLock("", "my-key")
+	lock.Lock()
+	go send(path, "", `"abc"`)
+	mustWait()
+	lock.Unlock()
+	<-entered
+	release <- struct{}{}
+
+	// Modifications of many keys wait for
+	// all other modifications.
+	go send(path, "", "")
+	<-entered
+	go send("/v1/key/bulk/delete/my-*", "", "")
+	mustWait()
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+
+	if n := len(preconditionLocks.locks); n != 0 {
+		t.Fatalf("Precondition locks have not been released: got %d", n)
+	}
+}
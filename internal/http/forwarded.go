@@ -0,0 +1,78 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedFor returns an http.Handler that replaces the remote
+// address of requests sent by a trusted proxy with the client
+// address from the X-Forwarded-For header before calling h.
+// Hence, the audit log and IP-based policy conditions see the
+// actual client and not the proxy. If trusted is empty,
+// ForwardedFor returns h.
+//
+// The X-Forwarded-For header of requests sent by any other
+// client is ignored. Proxies append the address of their peer
+// to the header. Therefore, the client address is the right-most
+// address that does not belong to a trusted proxy.
+func ForwardedFor(trusted []*net.IPNet, h http.Handler) http.Handler {
+	if len(trusted) == 0 {
+		return h
+	}
+	isTrusted := func(ip net.IP) bool {
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if ip := net.ParseIP(host); ip == nil || !isTrusted(ip) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var addrs []string
+		for _, value := range r.Header[http.CanonicalHeaderKey("X-Forwarded-For")] {
+			addrs = append(addrs, strings.Split(value, ",")...)
+		}
+		var client net.IP
+		for i := len(addrs) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(addrs[i]))
+			if ip == nil {
+				break // Don't trust anything left of an invalid address
+			}
+			client = ip
+			if !isTrusted(ip) {
+				break
+			}
+		}
+		if client != nil {
+			r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// remoteIP returns the IP address of the client that
+// has sent the request, or nil if the request has not
+// been sent via TCP - e.g. via a Unix domain socket.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
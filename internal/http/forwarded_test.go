@@ -0,0 +1,51 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+var forwardedForTests = []struct {
+	RemoteAddr string
+	Header     []string
+	Want       string
+}{
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"192.0.2.1"}, Want: "192.0.2.1:0"},                           // 0
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"198.51.100.7, 192.0.2.1"}, Want: "192.0.2.1:0"},             // 1: the left-most address is not trustworthy
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"192.0.2.1, 10.0.0.2"}, Want: "192.0.2.1:0"},                 // 2: skip trusted proxies
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"192.0.2.1", "10.0.0.2"}, Want: "192.0.2.1:0"},               // 3
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"2001:db8::1"}, Want: "[2001:db8::1]:0"},                     // 4
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"192.0.2.1, not-an-ip"}, Want: "10.0.0.1:4711"},              // 5
+	{RemoteAddr: "10.0.0.1:4711", Want: "10.0.0.1:4711"},                                                        // 6
+	{RemoteAddr: "192.0.2.1:4711", Header: []string{"198.51.100.7"}, Want: "192.0.2.1:4711"},                    // 7: not sent by a trusted proxy
+	{RemoteAddr: "10.0.0.1:4711", Header: []string{"198.51.100.7, 10.0.0.3, 10.0.0.2"}, Want: "198.51.100.7:0"}, // 8
+}
+
+func TestForwardedFor(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	var remoteAddr string
+	h := ForwardedFor([]*net.IPNet{trusted}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+	for i, test := range forwardedForTests {
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:7373/v1/status", nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		req.RemoteAddr = test.RemoteAddr
+		for _, value := range test.Header {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+
+		h.ServeHTTP(&dummyResponseWriter{}, req)
+		if remoteAddr != test.Want {
+			t.Fatalf("Test %d: got remote address '%s' - want '%s'", i, remoteAddr, test.Want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleWriteGroup returns a handler function that sets the
+// policies of the group specified by the request URL path
+// base. The policies are specified by the request body:
+//  {
+//    "policies": [ "<policy>", ... ]
+//  }
+// If the group does not exist, it gets created without any
+// members. The members of an existing group are kept.
+func HandleWriteGroup(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidGroupName = kes.NewError(http.StatusBadRequest, "invalid group name")
+		ErrInvalidJSON      = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		Policies []string `json:"policies"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := pathBase(r.URL.Path)
+		if name == "" {
+			Error(w, ErrInvalidGroupName)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if err := roles.SetGroup(name, req.Policies); err != nil {
+			Error(w, err)
+			return
+		}
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleReadGroup returns a handler function that returns the
+// policies and members of the group specified by the request
+// URL path base as JSON:
+//  {
+//    "policies": [ "<policy>", ... ],
+//    "members":  [ "<identity>", ... ]
+//  }
+func HandleReadGroup(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidGroupName = kes.NewError(http.StatusBadRequest, "invalid group name")
+		ErrGroupNotFound    = kes.NewError(http.StatusNotFound, "group does not exist")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := pathBase(r.URL.Path)
+		if name == "" {
+			Error(w, ErrInvalidGroupName)
+			return
+		}
+
+		group, ok := roles.Group(name)
+		if !ok {
+			Error(w, ErrGroupNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(group)
+	}
+}
+
+// HandleListGroups returns a handler function that returns
+// the names of all groups that match the pattern of the
+// request URL path base - e.g. /v1/group/list/my-app-*.
+func HandleListGroups(roles *auth.Roles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var groups = []string{}
+		pattern := pathBase(r.URL.Path)
+		for _, group := range roles.Groups() {
+			if ok, err := path.Match(pattern, group); ok && err == nil {
+				groups = append(groups, group)
+			}
+		}
+		sort.Strings(groups)
+		json.NewEncoder(w).Encode(groups)
+	}
+}
+
+// HandleDeleteGroup returns a handler function that deletes
+// the group specified by the request URL path base. The
+// members of the group lose the permissions of its policies.
+func HandleDeleteGroup(roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidGroupName = kes.NewError(http.StatusBadRequest, "invalid group name")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := pathBase(r.URL.Path)
+		if name == "" {
+			Error(w, ErrInvalidGroupName)
+			return
+		}
+		roles.DeleteGroup(name)
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleAddGroupMember returns a handler function that adds
+// an identity to a group. The group and the identity are
+// specified by the request URL path:
+//  /v1/group/add/<group>/<identity>
+func HandleAddGroupMember(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
+		ErrIdentityRoot    = kes.NewError(http.StatusBadRequest, "identity is root")
+		ErrSelfAdd         = kes.NewError(http.StatusForbidden, "identity cannot add itself to a group")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := kes.Identity(pathBase(r.URL.Path))
+		if identity.IsUnknown() {
+			Error(w, ErrIdentityUnknown)
+			return
+		}
+		if roles.IsRoot(identity) {
+			Error(w, ErrIdentityRoot)
+			return
+		}
+		if secret.EqualIdentity(identity, auth.Identify(r, roles.Identify)) {
+			Error(w, ErrSelfAdd)
+			return
+		}
+
+		group := pathBase(strings.TrimSuffix(r.URL.Path, "/"+identity.String()))
+		if err := roles.AddMember(group, identity); err != nil {
+			Error(w, err)
+			return
+		}
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleRemoveGroupMember returns a handler function that
+// removes an identity from a group. The group and the
+// identity are specified by the request URL path:
+//  /v1/group/remove/<group>/<identity>
+func HandleRemoveGroupMember(roles *auth.Roles) http.HandlerFunc {
+	var ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := kes.Identity(pathBase(r.URL.Path))
+		if identity.IsUnknown() {
+			Error(w, ErrIdentityUnknown)
+			return
+		}
+
+		group := pathBase(strings.TrimSuffix(r.URL.Path, "/"+identity.String()))
+		if err := roles.RemoveMember(group, identity); err != nil {
+			Error(w, err)
+			return
+		}
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+func TestHandleGroup(t *testing.T) {
+	roles := &auth.Roles{Root: "root"}
+	policy, _ := kes.NewPolicy("/v1/key/create/*")
+	roles.Set("my-app", policy)
+
+	for i, test := range []struct {
+		Method  string
+		Path    string
+		Body    string
+		Handler http.HandlerFunc
+		Status  int
+	}{
+		{Method: http.MethodPost, Path: "/v1/group/write/my-apps", Body: `{"policies":["unknown"]}`, Handler: HandleWriteGroup(roles), Status: http.StatusNotFound}, // 0
+		{Method: http.MethodPost, Path: "/v1/group/write/my-apps", Body: `{"policies":["my-app"]}`, Handler: HandleWriteGroup(roles), Status: http.StatusOK},        // 1
+		{Method: http.MethodPost, Path: "/v1/group/add/my-apps/af43c", Handler: HandleAddGroupMember(roles), Status: http.StatusOK},                                 // 2
+		{Method: http.MethodPost, Path: "/v1/group/add/my-apps/root", Handler: HandleAddGroupMember(roles), Status: http.StatusBadRequest},                          // 3
+		{Method: http.MethodPost, Path: "/v1/group/add/unknown/af43c", Handler: HandleAddGroupMember(roles), Status: http.StatusNotFound},                           // 4
+		{Method: http.MethodGet, Path: "/v1/group/read/unknown", Handler: HandleReadGroup(roles), Status: http.StatusNotFound},                                      // 5
+	} {
+		var body io.Reader
+		if test.Body != "" {
+			body = strings.NewReader(test.Body)
+		}
+		req, err := http.NewRequest(test.Method, "https://localhost:7373"+test.Path, body)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		var resp dummyResponseWriter
+		test.Handler(&resp, req)
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: got status %d - want %d: %s", i, resp.StatusCode, test.Status, resp.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://localhost:7373/v1/group/read/my-apps", nil)
+	var resp dummyResponseWriter
+	HandleReadGroup(roles)(&resp, req)
+	var group kes.Group
+	if err := json.Unmarshal(resp.Body.Bytes(), &group); err != nil {
+		t.Fatalf("Failed to parse group: %v", err)
+	}
+	if len(group.Policies) != 1 || group.Policies[0] != "my-app" {
+		t.Fatalf("Invalid group policies: got %v", group.Policies)
+	}
+	if len(group.Members) != 1 || group.Members[0] != "af43c" {
+		t.Fatalf("Invalid group members: got %v", group.Members)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, "https://localhost:7373/v1/group/remove/my-apps/af43c", nil)
+	resp = dummyResponseWriter{}
+	HandleRemoveGroupMember(roles)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to remove group member: got status %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if groups := roles.GroupsOf("af43c"); len(groups) != 0 {
+		t.Fatalf("Identity is still a group member: got %v", groups)
+	}
+}
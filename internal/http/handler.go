@@ -5,19 +5,30 @@
 package http
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/accounting"
 	"github.com/minio/kes/internal/auth"
 	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/metric"
 	"github.com/minio/kes/internal/secret"
-	"github.com/secure-io/sio-go/sioutil"
+	"github.com/minio/kes/internal/trace"
+	"github.com/minio/kes/internal/watch"
 )
 
 // EnforceHTTP2 returns a HTTP handler that verifies that
@@ -74,7 +85,7 @@ func ValidatePath(apiPattern string, f http.HandlerFunc) http.HandlerFunc {
 			r.URL.Path = `/` + r.URL.Path // URL.Path may omit leading slash
 		}
 
-		if ok, err := path.Match(apiPattern, r.URL.Path); !ok || err != nil {
+		if !kes.MatchPath(apiPattern, r.URL.Path) {
 			Error(w, ErrPatternMismatch)
 			return
 		}
@@ -86,10 +97,61 @@ func ValidatePath(apiPattern string, f http.HandlerFunc) http.HandlerFunc {
 // body of incoming requests to n bytes before calling f.
 //
 // It should be used to limit the amount of data a client can send
-// to prevent flooding/DoS attacks.
+// to prevent flooding/DoS attacks. Requests with a Content-Length
+// larger than n are rejected with kes.ErrRequestTooLarge without
+// calling f. If the length of the body is not known in advance -
+// e.g. for a chunked body - reading more than n bytes fails with
+// kes.ErrRequestTooLarge.
 func LimitRequestBody(n int64, f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, n)
+		if r.ContentLength > n {
+			Error(w, kes.ErrRequestTooLarge)
+			return
+		}
+		if r.Body != nil {
+			r.Body = &limitedBody{
+				ReadCloser: http.MaxBytesReader(w, r.Body, n),
+				limit:      n,
+			}
+		}
+		f(w, r)
+	}
+}
+
+// limitedBody is a request body limited by an
+// http.MaxBytesReader. It returns kes.ErrRequestTooLarge
+// once the body exceeds the limit.
+type limitedBody struct {
+	io.ReadCloser
+
+	limit int64
+	n     int64 // Number of bytes read so far
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && b.n >= b.limit {
+		err = kes.ErrRequestTooLarge
+	}
+	return n, err
+}
+
+// RequireContentType returns an http.HandlerFunc that rejects
+// requests with a body of any other media type than contentType
+// with kes.ErrUnsupportedMediaType without calling f.
+//
+// Requests without a body don't have to specify a Content-Type.
+// Media type parameters - like a charset - are ignored.
+func RequireContentType(contentType string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 { // The body is either non-empty or of unknown length
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !strings.EqualFold(mediaType, contentType) {
+				Error(w, kes.ErrUnsupportedMediaType)
+				return
+			}
+		}
 		f(w, r)
 	}
 }
@@ -100,16 +162,89 @@ func LimitRequestBody(n int64, f http.HandlerFunc) http.HandlerFunc {
 //
 // If the request is not authorized it will return an error to the
 // client and does not call f.
+//
+// Requests of the root identity that require approval - see
+// auth.Approvals - must carry enough approval tokens. The
+// approvers are recorded in the audit log. The tokens are
+// only used up if f responds with a 2xx status code.
 func EnforcePolicies(roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := roles.Verify(r); err != nil {
 			Error(w, err)
 			return
 		}
+		if roles.Approvals != nil && roles.Approvals.Required(r.URL.Path) {
+			if identity := auth.Identify(r, roles.Identify); roles.IsRoot(identity) {
+				approval, err := roles.Approvals.Approve(r, identity)
+				if err != nil {
+					Error(w, err)
+					return
+				}
+				if aw, ok := w.(*xlog.AuditResponseWriter); ok {
+					aw.Approvers = approval.Approvers
+				}
+
+				// A failed request can be retried with the
+				// same approval tokens.
+				sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+				f(sw, r)
+				if sw.status < 200 || sw.status > 299 {
+					approval.Release()
+				}
+				return
+			}
+		}
+		f(w, r)
+	}
+}
+
+// EnforceTenancy returns an http.HandlerFunc that looks up the
+// tenant of the request identity before calling f. If the identity
+// is bound to a tenant, the handlers operate on the tenant's key
+// namespace and only see identities and policies of this tenant.
+// The requests of a session belong to the tenant of the identity
+// that has issued the session. See: auth.Roles.Principal
+//
+// EnforceTenancy must be called after the request has been
+// verified - e.g. by EnforcePolicies.
+func EnforceTenancy(roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
+	if roles.Tenants == nil {
+		return f
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := roles.Tenants.Lookup(roles.Principal(r)); ok {
+			ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant{
+				Name:    name,
+				Tenants: roles.Tenants,
+			})
+			r = r.WithContext(ctx)
+		}
 		f(w, r)
 	}
 }
 
+// CancelOnDone returns an http.HandlerFunc that cancels the
+// request context of f once the ctx is done. It should be used
+// for long-running requests - like log traces - that would
+// otherwise block a server shutdown.
+func CancelOnDone(ctx context.Context, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqCtx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-done:
+			}
+		}()
+		f(w, r.WithContext(reqCtx))
+	}
+}
+
 // AuditLog returns a handler function that wraps f and logs the
 // HTTP request and response before sending the response status code
 // back to the client.
@@ -119,6 +254,7 @@ func AuditLog(logger *log.Logger, roles *auth.Roles, f http.HandlerFunc) http.Ha
 			ResponseWriter: w,
 			URL:            *r.URL,
 			Identity:       auth.Identify(r, roles.Identify),
+			IP:             remoteIP(r),
 			RequestHeader:  r.Header.Clone(),
 			Time:           time.Now(),
 
@@ -128,6 +264,114 @@ func AuditLog(logger *log.Logger, roles *auth.Roles, f http.HandlerFunc) http.Ha
 	}
 }
 
+// Router is an http.Handler that dispatches requests
+// to handlers registered for URL patterns. For example,
+// *http.ServeMux is a Router.
+type Router interface {
+	http.Handler
+
+	// Handler returns the handler and its pattern
+	// that would serve the given request.
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+var _ Router = (*http.ServeMux)(nil)
+
+// ObserveMetrics returns an http.Handler that dispatches
+// requests via the router and records the API, response
+// status and latency of every request.
+//
+// The API of a request is the router pattern that matches
+// the request URL. Therefore, the number of distinct APIs
+// is bounded by the number of registered patterns.
+func ObserveMetrics(metrics *metric.Metrics, router Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, api := router.Handler(r)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		router.ServeHTTP(sw, r)
+		metrics.ObserveRequest(api, sw.status, time.Since(start))
+	})
+}
+
+// AccountRequests returns a Router that dispatches requests
+// via the router and counts the requests of each identity
+// per API. If acct is nil, AccountRequests returns
+// the router.
+//
+// As for ObserveMetrics, the API of a request is the router
+// pattern that matches the request URL. Requests of unknown
+// identities are not counted.
+func AccountRequests(acct *accounting.Accounting, identify auth.IdentityFunc, router Router) Router {
+	if acct == nil {
+		return router
+	}
+	return accountingRouter{Router: router, accounting: acct, identify: identify}
+}
+
+type accountingRouter struct {
+	Router
+
+	accounting *accounting.Accounting
+	identify   auth.IdentityFunc
+}
+
+func (a accountingRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if identity := auth.Identify(r, a.identify); !identity.IsUnknown() {
+		_, api := a.Router.Handler(r)
+		a.accounting.Add(identity, api)
+	}
+	a.Router.ServeHTTP(w, r)
+}
+
+// Trace returns a Router that dispatches requests via
+// the router and records a trace span for every request.
+// If the tracer is nil, Trace returns the router.
+//
+// If the request contains a W3C traceparent header the
+// span continues the client trace. The span is passed
+// to the router handlers via the request context.
+func Trace(tracer *trace.Tracer, router Router) Router {
+	if tracer == nil {
+		return router
+	}
+	return traceRouter{Router: router, tracer: tracer}
+}
+
+type traceRouter struct {
+	Router
+
+	tracer *trace.Tracer
+}
+
+func (t traceRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, api := t.Router.Handler(r)
+	ctx, span := t.tracer.Start(r.Context(), r.Method+" "+api, r.Header.Get("traceparent"))
+	defer span.Finish()
+
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	t.Router.ServeHTTP(sw, r.WithContext(ctx))
+
+	span.SetAttribute("http.status_code", strconv.Itoa(sw.status))
+	if sw.status >= http.StatusInternalServerError {
+		span.SetError(errors.New(http.StatusText(sw.status)))
+	}
+}
+
+// HandleMetrics returns a handler function that writes
+// the server metrics in the Prometheus text format.
+func HandleMetrics(metrics *metric.Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		metrics.WriteTo(w)
+	}
+}
+
 // HandleVersion returns a handler function that returns the
 // given version as JSON. In particular, it returns a JSON
 // object:
@@ -138,33 +382,184 @@ func HandleVersion(version string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) { fmt.Fprintf(w, `{"version":"%s"}`, version) }
 }
 
+// HandleLive returns a handler function that responds with
+// 200 OK as long as the server is able to serve requests.
+// It is suitable as liveness probe.
+func HandleLive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok"}`)
+	}
+}
+
+// HandleReady returns a handler function that responds with
+// 200 OK if the key store and KMS of the store are available
+// and with 503 (service unavailable) otherwise. It is suitable
+// as readiness probe.
+//
+// The handler is not authenticated. Therefore, it checks the
+// key store and KMS at most once per interval and responds
+// with the cached result in between.
+func HandleReady(store *secret.Store, interval time.Duration) http.HandlerFunc {
+	var (
+		lock      sync.Mutex
+		lastCheck time.Time
+		lastErr   error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		if time.Since(lastCheck) >= interval {
+			remoteErr, kmsErr := store.Status()
+			switch {
+			case remoteErr != nil:
+				lastErr = kes.NewError(http.StatusServiceUnavailable, "key store is not available")
+			case kmsErr != nil:
+				lastErr = kes.NewError(http.StatusServiceUnavailable, "KMS is not available")
+			default:
+				lastErr = nil
+			}
+			lastCheck = time.Now()
+		}
+		err := lastErr
+		lock.Unlock()
+
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok"}`)
+	}
+}
+
+// HandleStatus returns a handler function that returns the
+// server status as JSON. In particular, it returns:
+//  {
+//    "version": "<version>",
+//    "uptime":  <seconds>,
+//    "keystore": { "state": "up" | "down", "error": "<error>" },
+//    "kms":      { "state": "up" | "down", "error": "<error>" },
+//    "entropy":  { "state": "up" | "down", "error": "<error>" },
+//    "cache":    { "hits": <n>, "misses": <n> }
+//  }
+// The "kms" field is omitted if no KMS is used and the "entropy"
+// field if the key store does not use a health-tested entropy
+// source.
+func HandleStatus(version string, startTime time.Time, store *secret.Store) http.HandlerFunc {
+	type State struct {
+		State string `json:"state"`
+		Error string `json:"error,omitempty"`
+	}
+	type Cache struct {
+		Hits   uint64 `json:"hits"`
+		Misses uint64 `json:"misses"`
+	}
+	type Response struct {
+		Version  string `json:"version"`
+		Uptime   uint64 `json:"uptime"`
+		KeyStore State  `json:"keystore"`
+		KMS      *State `json:"kms,omitempty"`
+		Entropy  *State `json:"entropy,omitempty"`
+		Cache    Cache  `json:"cache"`
+	}
+	newState := func(err error) State {
+		if err != nil {
+			return State{State: "down", Error: err.Error()}
+		}
+		return State{State: "up"}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		remoteErr, kmsErr := store.Status()
+		hits, misses := store.CacheStats()
+
+		response := Response{
+			Version:  version,
+			Uptime:   uint64(time.Since(startTime).Seconds()),
+			KeyStore: newState(remoteErr),
+			Cache:    Cache{Hits: hits, Misses: misses},
+		}
+		if store.KMS != nil {
+			state := newState(kmsErr)
+			response.KMS = &state
+		}
+		if source, ok := store.Rand.(interface{ Err() error }); ok {
+			state := newState(source.Err())
+			response.Entropy = &state
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
 // HandleCreateKey returns a handler function that generates a new
 // random Secret and stores in the Store under the request name, if
 // it doesn't exist.
 //
 // It infers the name of the new Secret from the request URL - in
-// particular from the URL's path base.
-// See: https://golang.org/pkg/path/#Base
-func HandleCreateKey(store *secret.Store) http.HandlerFunc {
-	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
-
+// particular from all path segments after the API path - e.g.
+// "my-app/my-key" for /v1/key/create/my-app/my-key.
+//
+// The client may restrict the new Secret to a set of operations
+// and mark it as immutable such that it can never be deleted.
+// See: secret.Store.CreateWithOps and secret.Store.CreateImmutable
+func HandleCreateKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		Ops       []string `json:"ops"`       // optional
+		Immutable bool     `json:"immutable"` // optional
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		name := pathBase(r.URL.Path)
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
 		}
 
-		var secret secret.Secret
-		bytes, err := sioutil.Random(len(secret))
+		// The request body is optional. Without a body
+		// the key can be used for any operation.
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		var key secret.Secret
+		bytes, err := store.Random(len(key))
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		copy(secret[:], bytes)
+		copy(key[:], bytes)
+		secret.Wipe(bytes)
+		defer key.Destroy()
 
-		if err := store.Create(name, secret); err != nil {
+		if err := reserveKey(r, roles, name); err != nil {
+			Error(w, err)
+			return
+		}
+		event := provenanceEvent(r, roles, secret.ProvenanceCreated)
+		if req.Immutable {
+			err = store.CreateImmutable(r.Context(), name, key, req.Ops)
+		} else {
+			err = store.CreateWithOps(r.Context(), name, key, req.Ops)
+		}
+		if err != nil {
+			releaseKey(roles, name)
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, event); err != nil {
 			Error(w, err)
+			return
+		}
+		if roles.Quotas != nil {
+			if err := roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
 		}
 		w.WriteHeader(http.StatusOK)
 	}
@@ -175,9 +570,9 @@ func HandleCreateKey(store *secret.Store) http.HandlerFunc {
 // request name, if it doesn't exist.
 //
 // It infers the name of the new Secret from the request URL - in
-// particular from the URL's path base.
-// See: https://golang.org/pkg/path/#Base
-func HandleImportKey(store *secret.Store) http.HandlerFunc {
+// particular from all path segments after the API path - e.g.
+// "my-app/my-key" for /v1/key/import/my-app/my-key.
+func HandleImportKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
 	var (
 		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
 		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
@@ -188,7 +583,7 @@ func HandleImportKey(store *secret.Store) http.HandlerFunc {
 			Bytes []byte `json:"bytes"`
 		}
 
-		name := pathBase(r.URL.Path)
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
@@ -200,194 +595,1151 @@ func HandleImportKey(store *secret.Store) http.HandlerFunc {
 			return
 		}
 
-		var secret secret.Secret
-		if len(req.Bytes) != len(secret) {
+		defer secret.Wipe(req.Bytes)
+
+		var key secret.Secret
+		if len(req.Bytes) != len(key) {
 			Error(w, ErrInvalidKey)
 			return
 		}
-		copy(secret[:], req.Bytes)
+		copy(key[:], req.Bytes)
+		defer key.Destroy()
 
-		if err := store.Create(name, secret); err != nil {
+		if err := reserveKey(r, roles, name); err != nil {
+			Error(w, err)
+			return
+		}
+		event := provenanceEvent(r, roles, secret.ProvenanceImported)
+		if err := store.Create(r.Context(), name, key); err != nil {
+			releaseKey(roles, name)
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, event); err != nil {
 			Error(w, err)
 			return
 		}
+		if roles.Quotas != nil {
+			if err := roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func HandleDeleteKey(store *secret.Store) http.HandlerFunc {
+func HandleDeleteKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
 	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		name := pathBase(r.URL.Path)
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
 		}
-		if err := store.Delete(name); err != nil {
+
+		// Deleting a key that does not exist succeeds
+		// but must not appear in the key's provenance.
+		info, err := store.Stat(name)
+		existed := err == nil
+		var version string
+		if existed {
+			version = info.Version()
+		}
+		if err = checkPrecondition(r, version); err != nil {
+			Error(w, err)
+			return
+		}
+		if err = store.Delete(r.Context(), name); err != nil {
 			Error(w, err)
 			return
 		}
+		if existed {
+			if err = store.AppendProvenance(name, provenanceEvent(r, roles, secret.ProvenanceDeleted)); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		if roles.Quotas != nil {
+			roles.Quotas.Release(name)
+			if err := roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-// HandleGenerateKey returns an http.HandlerFunc that generates
-// a data encryption key (DEK) at random and returns the plaintext
-// and ciphertext version of the DEK to the client. The DEK ciphertext
-// is the DEK plaintext encrypted with the secret key from the store.
-//
-// HandleGenerateKey behaves as HandleEncryptKey where the plaintext is
-// a randomly generated key.
+// HandleDisableKey returns an http.HandlerFunc that disables
+// the key referenced by the request URL - e.g.
+// /v1/key/disable/my-key. A disabled key cannot be used for
+// any key operation until it gets enabled again.
 //
-// If the client provides an optional context value the
-// returned http.HandlerFunc will authenticate but not encrypt
-// the context value. The client has to provide the same
-// context value again for decryption.
-func HandleGenerateKey(store *secret.Store) http.HandlerFunc {
-	var (
-		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
-		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
-	)
-	type Request struct {
-		Context []byte `json:"context"` // optional
-	}
-	type Response struct {
-		Plaintext  []byte `json:"plaintext"`
-		Ciphertext []byte `json:"ciphertext"`
-	}
+// In contrast to deleting a key, disabling a key can be
+// undone. Hence, an immutable key can be disabled.
+func HandleDisableKey(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req Request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			Error(w, ErrInvalidJSON)
-			return
-		}
-
-		name := pathBase(r.URL.Path)
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
 		}
-		secret, err := store.Get(name)
-		if err != nil {
+		if r.Header.Get("If-Match") != "" {
+			info, err := store.Stat(name)
+			if err != nil && err != kes.ErrKeyNotFound {
+				Error(w, err)
+				return
+			}
+			var version string
+			if err == nil {
+				version = info.Version()
+			}
+			if err = checkPrecondition(r, version); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		if err := store.Disable(name); err != nil {
 			Error(w, err)
 			return
 		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
 
-		dataKey, err := sioutil.Random(32)
-		if err != nil {
-			Error(w, err)
+// HandleEnableKey returns an http.HandlerFunc that enables
+// the key referenced by the request URL - e.g.
+// /v1/key/enable/my-key - after it has been disabled.
+func HandleEnableKey(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
 			return
 		}
-		ciphertext, err := secret.Wrap(dataKey, req.Context)
-		if err != nil {
+		if err := store.Enable(name); err != nil {
 			Error(w, err)
 			return
 		}
-		json.NewEncoder(w).Encode(Response{
-			Plaintext:  dataKey,
-			Ciphertext: ciphertext,
-		})
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
-// HandleEncryptKey returns an http.HandlerFunc that encrypts
-// and authenticates a plaintext message sent by the client.
-//
-// It should be used to encrypt small amounts of data - like
-// other cryptographic keys or small metadata objects.
-// HandleEncryptKey should not be used to encrypt large data
-// streams.
+// HandleScheduleKeyDeletion returns an http.HandlerFunc that
+// schedules the deletion of the key referenced by the request
+// URL - e.g. /v1/key/schedule-deletion/my-key. The request body
+// may contain the number of days - between 7 and 30 - until the
+// key gets deleted:
+//  {
+//    "days": <days>
+//  }
 //
-// If the client provides an optional context value the
-// returned http.HandlerFunc will authenticate but not encrypt
-// the context value. The client has to provide the same
-// context value again for decryption.
-func HandleEncryptKey(store *secret.Store) http.HandlerFunc {
+// By default, the key gets deleted after 30 days. Until then,
+// the key cannot be used and the deletion can be cancelled.
+// The handler replies with the point in time when the key will
+// be deleted:
+//  {
+//    "delete_at": "<RFC 3339 timestamp>"
+//  }
+func HandleScheduleKeyDeletion(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
 	var (
-		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
 		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
 	)
 	type Request struct {
-		Plaintext []byte `json:"plaintext"`
-		Context   []byte `json:"context"` // optional
+		Days int `json:"days"` // optional
 	}
 	type Response struct {
-		Ciphertext []byte `json:"ciphertext"`
+		DeleteAt time.Time `json:"delete_at"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
 		var req Request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
 			Error(w, ErrInvalidJSON)
 			return
 		}
+		window := secret.DefaultDeletionWindow
+		if req.Days != 0 {
+			window = time.Duration(req.Days) * 24 * time.Hour
+		}
 
-		name := pathBase(r.URL.Path)
+		event := provenanceEvent(r, roles, secret.ProvenanceScheduled)
+		deleteAt, err := store.ScheduleDeletion(name, window, event.Identity)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		event.Origin = "delete at " + deleteAt.Format(time.RFC3339)
+		if err = store.AppendProvenance(name, event); err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			DeleteAt: deleteAt,
+		})
+	}
+}
+
+// HandleCancelKeyDeletion returns an http.HandlerFunc that
+// cancels the scheduled deletion of the key referenced by the
+// request URL - e.g. /v1/key/cancel-deletion/my-key.
+func HandleCancelKeyDeletion(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
 		}
-		secret, err := store.Get(name)
-		if err != nil {
+		if err := store.CancelDeletion(name); err != nil {
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, provenanceEvent(r, roles, secret.ProvenanceCancelled)); err != nil {
 			Error(w, err)
 			return
 		}
-		ciphertext, err := secret.Wrap(req.Plaintext, req.Context)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleHoldKey returns an http.HandlerFunc that places a
+// legal hold on the key referenced by the request URL - e.g.
+// /v1/key/hold/set/my-key. The request body may contain the
+// reason for the hold:
+//  {
+//    "reason": "<reason>"
+//  }
+//
+// A key under legal hold cannot be deleted or rotated until
+// the hold gets released. Placing and releasing a hold are
+// recorded in the key's provenance.
+func HandleHoldKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		Reason string `json:"reason"` // optional
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		event := provenanceEvent(r, roles, secret.ProvenanceHeld)
+		event.Origin = req.Reason
+		err := store.Hold(name, secret.LegalHold{
+			Reason:   req.Reason,
+			Identity: event.Identity,
+		})
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = store.AppendProvenance(name, event); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleReleaseKey returns an http.HandlerFunc that releases
+// the legal hold on the key referenced by the request URL -
+// e.g. /v1/key/hold/release/my-key.
+//
+// Since the API path differs from the one that places a hold,
+// a policy can allow an identity to place but not to release
+// legal holds.
+func HandleReleaseKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		if err := store.Release(name); err != nil {
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, provenanceEvent(r, roles, secret.ProvenanceReleased)); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleBulkDeleteKey returns an http.HandlerFunc that deletes
+// all keys under the prefix referenced by the request URL -
+// e.g. all keys "my-app/..." for /v1/key/bulk/delete/my-app.
+// It responds with the names of the deleted keys and the
+// names of the keys that have been skipped since they are
+// immutable or under legal hold, together with the reason.
+//
+// If any other error occurs, it stops and responds with the
+// error, the keys deleted so far and the keys skipped so far.
+// The deleted keys remain deleted. Since deleting a key that
+// does not exist succeeds, a client can retry the request.
+func HandleBulkDeleteKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidPrefix = kes.NewError(http.StatusBadRequest, "invalid key prefix")
+	type Response struct {
+		Message string            `json:"message,omitempty"`
+		Code    string            `json:"code,omitempty"`
+		Deleted []string          `json:"deleted"`
+		Skipped map[string]string `json:"skipped,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefix := keyName(r)
+		if prefix == "" {
+			Error(w, ErrInvalidPrefix)
+			return
+		}
+		names, err := store.ListPrefix(prefix + "/")
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		sort.Strings(names)
+
+		t, isTenant := tenantOf(r)
+		response := Response{Deleted: []string{}}
+		for _, name := range names {
+			namespace, key := splitNamespace(roles.Tenants, name)
+			displayName := name
+			if isTenant {
+				if namespace != t.Name {
+					continue
+				}
+				displayName = key
+			} else if namespace != "" {
+				continue // Only the tenant can access its keys
+			}
+
+			err = store.Delete(r.Context(), name)
+			if err == secret.ErrImmutable || err == secret.ErrLegalHold {
+				if response.Skipped == nil {
+					response.Skipped = map[string]string{}
+				}
+				response.Skipped[displayName] = err.Error()
+				err = nil
+				continue
+			}
+			if err != nil {
+				break
+			}
+			releaseKey(roles, name)
+			response.Deleted = append(response.Deleted, displayName)
+			if err = store.AppendProvenance(name, provenanceEvent(r, roles, secret.ProvenanceDeleted)); err != nil {
+				break
+			}
+		}
+		if roles.Quotas != nil && len(response.Deleted) > 0 {
+			if saveErr := roles.Quotas.Save(); err == nil {
+				err = saveErr
+			}
+		}
+
+		status := http.StatusOK
+		if err != nil {
+			status, response.Message, response.Code = errorStatus(err)
+			if aw, ok := auditWriter(w); ok {
+				aw.ErrorCode = response.Code
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// HandleGenerateKey returns an http.HandlerFunc that generates
+// a data encryption key (DEK) at random and returns the plaintext
+// and ciphertext version of the DEK to the client. The DEK ciphertext
+// is the DEK plaintext encrypted with the secret key from the store.
+//
+// HandleGenerateKey behaves as HandleEncryptKey where the plaintext is
+// a randomly generated key.
+//
+// If the client provides an optional context value the
+// returned http.HandlerFunc will authenticate but not encrypt
+// the context value. The client has to provide the same
+// context value again for decryption.
+//
+// If the client sets the optional "wrap" value to WrapHybrid
+// the DEK is encrypted with the experimental post-quantum
+// hybrid scheme.
+func HandleGenerateKey(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidWrap    = kes.NewError(http.StatusBadRequest, "invalid wrap: must be empty or '"+WrapHybrid+"'")
+	)
+	type Request struct {
+		Context []byte `json:"context"` // optional
+		Wrap    string `json:"wrap"`    // optional
+	}
+	type Response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if req.Wrap != "" && req.Wrap != WrapHybrid {
+			Error(w, ErrInvalidWrap)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpGenerate)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+
+		dataKey, err := store.Random(32)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(dataKey)
+
+		ciphertext, err := wrap(store, key, req.Wrap, dataKey, req.Context)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		store.RecordUse(name, "generate")
+		json.NewEncoder(w).Encode(Response{
+			Plaintext:  dataKey,
+			Ciphertext: ciphertext,
+		})
+	}
+}
+
+// HandleEncryptKey returns an http.HandlerFunc that encrypts
+// and authenticates a plaintext message sent by the client.
+//
+// It should be used to encrypt small amounts of data - like
+// other cryptographic keys or small metadata objects.
+// HandleEncryptKey should not be used to encrypt large data
+// streams.
+//
+// If the client provides an optional context value the
+// returned http.HandlerFunc will authenticate but not encrypt
+// the context value. The client has to provide the same
+// context value again for decryption.
+//
+// If the client sets the optional "wrap" value to WrapHybrid
+// the plaintext is encrypted with the experimental post-quantum
+// hybrid scheme.
+func HandleEncryptKey(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidWrap    = kes.NewError(http.StatusBadRequest, "invalid wrap: must be empty or '"+WrapHybrid+"'")
+	)
+	type Request struct {
+		Plaintext []byte `json:"plaintext"`
+		Context   []byte `json:"context"` // optional
+		Wrap      string `json:"wrap"`    // optional
+	}
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if req.Wrap != "" && req.Wrap != WrapHybrid {
+			secret.Wipe(req.Plaintext)
+			Error(w, ErrInvalidWrap)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpEncrypt)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+		ciphertext, err := wrap(store, key, req.Wrap, req.Plaintext, req.Context)
+		secret.Wipe(req.Plaintext)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		store.RecordUse(name, "encrypt")
+		json.NewEncoder(w).Encode(Response{
+			Ciphertext: ciphertext,
+		})
+	}
+}
+
+// HandleDecryptKey returns an http.HandlerFunc that decrypts
+// and verifies a ciphertext sent by the client procuded by
+// HandleEncryptKey or HandleGenerateKey.
+//
+// If the client has provided a context value during
+// encryption / key generation then the client has to provide
+// the same context value again.
+func HandleDecryptKey(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	)
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context"`
+	}
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpDecrypt)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+		plaintext, err := key.UnwrapHybrid(store.HybridKey, req.Ciphertext, req.Context)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(plaintext)
+
+		store.RecordUse(name, "decrypt")
+		json.NewEncoder(w).Encode(Response{
+			Plaintext: plaintext,
+		})
+	}
+}
+
+// HandleReEncryptKey returns an http.HandlerFunc that decrypts
+// a data key ciphertext with the key referenced by the request
+// URL and encrypts the plaintext data key with another key -
+// e.g. to rotate the key of a bucket. The plaintext data key is
+// never returned to the client. For example,
+// /v1/key/reencrypt/old-key:
+//  {
+//    "ciphertext": "<base64-encoded-ciphertext>",
+//    "context":    "<base64-encoded-context>",
+//    "key":        "new-key"
+//  }
+//
+// The new ciphertext is bound to the same context. Besides
+// re-encrypting with the old key, the request identity must
+// be allowed to generate data keys with the new key - i.e.
+// to access /v1/key/generate/new-key.
+//
+// If the client sets the optional "wrap" value to WrapHybrid
+// the new ciphertext is produced by the experimental post-quantum
+// hybrid scheme - e.g. to migrate ciphertexts of long-retention
+// data.
+func HandleReEncryptKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidWrap    = kes.NewError(http.StatusBadRequest, "invalid wrap: must be empty or '"+WrapHybrid+"'")
+	)
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context"`
+		Key        string `json:"key"`
+		Wrap       string `json:"wrap"` // optional
+	}
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		if req.Wrap != "" && req.Wrap != WrapHybrid {
+			Error(w, ErrInvalidWrap)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" || !secret.ValidName(req.Key) {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		if err := verifyKeyAccess(r, roles, "generate", req.Key); err != nil {
+			Error(w, err)
+			return
+		}
+		newName := req.Key
+		if t, ok := tenantOf(r); ok {
+			newName = t.Tenants.KeyName(t.Name, newName)
+		}
+		newName = store.Resolve(newName)
+
+		key, err := store.GetFor(r.Context(), name, secret.OpDecrypt)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+		newKey, err := store.GetFor(r.Context(), newName, secret.OpGenerate)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer newKey.Destroy()
+
+		plaintext, err := key.UnwrapHybrid(store.HybridKey, req.Ciphertext, req.Context)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(plaintext)
+		ciphertext, err := wrap(store, newKey, req.Wrap, plaintext, req.Context)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		store.RecordUse(name, "reencrypt")
+		store.RecordUse(newName, "reencrypt")
+		json.NewEncoder(w).Encode(Response{
+			Ciphertext: ciphertext,
+		})
+	}
+}
+
+// WrapHybrid is the optional "wrap" request value that
+// selects the experimental post-quantum hybrid wrapping
+// of HandleGenerateKey, HandleEncryptKey and
+// HandleReEncryptKey. If the Store has no HybridKey,
+// these requests fail with secret.ErrHybridDisabled.
+// See: secret.Secret.WrapHybrid
+const WrapHybrid = "hybrid"
+
+// wrap encrypts the plaintext with the key using the
+// wrapping scheme selected by the client. The hybrid
+// scheme requires the store's HybridKey.
+func wrap(store *secret.Store, key secret.Secret, scheme string, plaintext, associatedData []byte) ([]byte, error) {
+	if scheme == WrapHybrid {
+		return key.WrapHybrid(store.Reader(), store.HybridKey, plaintext, associatedData)
+	}
+	return key.Wrap(plaintext, associatedData)
+}
+
+// maxBulkSize is the max. number of data keys that
+// can be generated resp. decrypted by a single bulk
+// request.
+const maxBulkSize = 1000
+
+// HandleBulkGenerateKey returns an http.HandlerFunc that
+// generates one data encryption key (DEK) for each context
+// sent by the client. Each DEK is bound to its context.
+//
+// It fails if it cannot generate all DEKs. In particular,
+// it does not return a partial result.
+func HandleBulkGenerateKey(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidJSON     = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName  = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidBulkSize = kes.NewError(http.StatusBadRequest, fmt.Sprintf("invalid bulk size: must be between 1 and %d", maxBulkSize))
+	)
+	type Request struct {
+		Context []byte `json:"context"` // optional
+	}
+	type Response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req []Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if len(req) == 0 || len(req) > maxBulkSize {
+			Error(w, ErrInvalidBulkSize)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpGenerate)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+
+		resp := make([]Response, 0, len(req))
+		defer func() {
+			for _, resp := range resp {
+				secret.Wipe(resp.Plaintext)
+			}
+		}()
+		for _, req := range req {
+			dataKey, err := store.Random(32)
+			if err != nil {
+				Error(w, err)
+				return
+			}
+			ciphertext, err := key.Wrap(dataKey, req.Context)
+			if err != nil {
+				secret.Wipe(dataKey)
+				Error(w, err)
+				return
+			}
+			resp = append(resp, Response{
+				Plaintext:  dataKey,
+				Ciphertext: ciphertext,
+			})
+		}
+		store.RecordUse(name, "generate")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleBulkDecryptKey returns an http.HandlerFunc that
+// decrypts all ciphertexts sent by the client.
+//
+// It fails if it cannot decrypt all ciphertexts - e.g.
+// because one ciphertext is not authentic. In particular,
+// it does not return a partial result.
+func HandleBulkDecryptKey(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidJSON     = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidKeyName  = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidBulkSize = kes.NewError(http.StatusBadRequest, fmt.Sprintf("invalid bulk size: must be between 1 and %d", maxBulkSize))
+	)
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context"` // optional
+	}
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req []Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if len(req) == 0 || len(req) > maxBulkSize {
+			Error(w, ErrInvalidBulkSize)
+			return
+		}
+
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		key, err := store.GetFor(r.Context(), name, secret.OpDecrypt)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer key.Destroy()
+
+		resp := make([]Response, 0, len(req))
+		defer func() {
+			for _, resp := range resp {
+				secret.Wipe(resp.Plaintext)
+			}
+		}()
+		for _, req := range req {
+			plaintext, err := key.UnwrapHybrid(store.HybridKey, req.Ciphertext, req.Context)
+			if err != nil {
+				Error(w, err)
+				return
+			}
+			resp = append(resp, Response{
+				Plaintext: plaintext,
+			})
+		}
+		store.RecordUse(name, "decrypt")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// HandleListKeys returns an http.HandlerFunc that lists
+// all keys with a name matching the pattern of the request
+// URL - e.g. /v1/key/list/my-app-*.
+//
+// For each key, it reports when the key has been created
+// and last used, how often it has been used, whether the
+// key is sealed by the KMS and which key operations each
+// policy allows. It does not consider any policy conditions
+// since they depend on the particular request.
+//
+// A tenant identity only sees the keys and policies of its
+// tenant. The key names are evaluated as seen by the tenant
+// - i.e. without the tenant prefix.
+//
+// The client can restrict the listing via the URL query
+// parameters:
+//  ?prefix=<prefix>&limit=<n>&continue=<token>
+// Only keys that start with the prefix are listed. If limit
+// is set, at most limit keys are listed. If there are more
+// keys, the response contains a Kes-Continue-Token header
+// that the client can send as continue parameter to list
+// the next keys.
+//
+// If the client accepts "application/x-ndjson", the keys are
+// streamed as one JSON object per line instead of a JSON array.
+// If an error occurs while streaming, the last line contains
+// the error message - i.e. {"error":"<message>"}.
+func HandleListKeys(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidLimit = kes.NewError(http.StatusBadRequest, "invalid limit")
+		ErrInvalidToken = kes.NewError(http.StatusBadRequest, "invalid continue token")
+	)
+	type Candidate struct {
+		Name, Namespace, Key, DisplayName string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern, _ := auth.APIKeyName(r.URL.Path)
+		t, isTenant := tenantOf(r)
+
+		query := r.URL.Query()
+		prefix := query.Get("prefix")
+		limit := 0
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				Error(w, ErrInvalidLimit)
+				return
+			}
+			limit = n
+		}
+		var after string
+		if token := query.Get("continue"); token != "" {
+			name, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil || len(name) == 0 {
+				Error(w, ErrInvalidToken)
+				return
+			}
+			after = string(name)
+		}
+
+		// Only list the keys under the literal prefix of the
+		// pattern - e.g. "my-app/" for "my-app/*" - such that
+		// the store doesn't have to list all keys.
+		storePrefix := patternPrefix(pattern)
+		if p := prefix[:strings.LastIndexByte(prefix, '/')+1]; len(p) > len(storePrefix) && strings.HasPrefix(p, storePrefix) {
+			storePrefix = p
+		}
+		if isTenant {
+			storePrefix = t.Tenants.KeyName(t.Name, storePrefix)
+		}
+		names, err := store.ListPrefix(storePrefix)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		sort.Strings(names)
+
+		// First, select the keys the client can see and
+		// that match the filters. Describing a key is more
+		// expensive and only done for the requested page.
+		var candidates []Candidate
+		for _, name := range names {
+			// The policies of a tenant are evaluated against
+			// the key name without the tenant prefix.
+			namespace, key := splitNamespace(roles.Tenants, name)
+			displayName := name
+			if isTenant {
+				if namespace != t.Name {
+					continue
+				}
+				displayName = key
+			} else if namespace != "" {
+				continue // Only the tenant can access its keys
+			}
+			if after != "" && displayName <= after {
+				continue
+			}
+			if !strings.HasPrefix(displayName, prefix) || !kes.MatchPath(pattern, displayName) {
+				continue
+			}
+			candidates = append(candidates, Candidate{
+				Name:        name,
+				Namespace:   namespace,
+				Key:         key,
+				DisplayName: displayName,
+			})
+		}
+		if limit > 0 && len(candidates) > limit {
+			candidates = candidates[:limit]
+			token := base64.RawURLEncoding.EncodeToString([]byte(candidates[limit-1].DisplayName))
+			w.Header().Set("Kes-Continue-Token", token)
+		}
+
+		// The policies that apply to the keys of a namespace.
+		// The keys of a tenant are only accessible by the
+		// identities of the tenant.
+		namespaces := map[string][]string{}
+		policiesOf := func(namespace string) []string {
+			if policies, ok := namespaces[namespace]; ok {
+				return policies
+			}
+			policies := namespacePolicies(roles, namespace)
+			namespaces[namespace] = policies
+			return policies
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			const FlushInterval = 64 // Flush the response every 64 keys
+
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			flusher, _ := w.(http.Flusher)
+			encoder := json.NewEncoder(w)
+			for i, c := range candidates {
+				info, err := describeKey(store, roles, policiesOf(c.Namespace), c.Name, c.Key, c.DisplayName)
+				if err == kes.ErrKeyNotFound {
+					continue // The key has been deleted in the meantime
+				}
+				if err != nil {
+					encoder.Encode(struct {
+						Error string `json:"error"`
+					}{Error: err.Error()})
+					return
+				}
+				if err = encoder.Encode(info); err != nil {
+					return // The client has closed the connection
+				}
+				if flusher != nil && (i+1)%FlushInterval == 0 {
+					flusher.Flush()
+				}
+			}
+			return
+		}
+
+		var keys = []keyInfo{}
+		for _, c := range candidates {
+			info, err := describeKey(store, roles, policiesOf(c.Namespace), c.Name, c.Key, c.DisplayName)
+			if err == kes.ErrKeyNotFound {
+				continue // The key has been deleted in the meantime
+			}
+			if err != nil {
+				Error(w, err)
+				return
+			}
+			keys = append(keys, info)
+		}
+		json.NewEncoder(w).Encode(keys)
+	}
+}
+
+// HandleDescribeKey returns an http.HandlerFunc that
+// describes the key referenced by the request URL - e.g.
+// /v1/key/describe/my-key. It reports the same information
+// as HandleListKeys does for each key.
+func HandleDescribeKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		namespace, key := splitNamespace(roles.Tenants, name)
+		displayName := name
+		if _, isTenant := tenantOf(r); isTenant {
+			displayName = key
+		}
+
+		info, err := describeKey(store, roles, namespacePolicies(roles, namespace), name, key, displayName)
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		json.NewEncoder(w).Encode(Response{
-			Ciphertext: ciphertext,
-		})
+		json.NewEncoder(w).Encode(info)
 	}
 }
 
-// HandleDecryptKey returns an http.HandlerFunc that decrypts
-// and verifies a ciphertext sent by the client procuded by
-// HandleEncryptKey or HandleGenerateKey.
+// HandleKeyProvenance returns an http.HandlerFunc that
+// responds with the provenance of the key referenced by
+// the request URL - e.g. /v1/key/provenance/my-key - as
+// JSON array, oldest event first.
 //
-// If the client has provided a context value during
-// encryption / key generation then the client has to provide
-// the same context value again.
-func HandleDecryptKey(store *secret.Store) http.HandlerFunc {
-	var (
-		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
-		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
-	)
-	type Request struct {
-		Ciphertext []byte `json:"ciphertext"`
-		Context    []byte `json:"context"`
-	}
-	type Response struct {
-		Plaintext []byte `json:"plaintext"`
-	}
+// The provenance of a deleted key remains available.
+// See: secret.Store.Provenance
+func HandleKeyProvenance(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req Request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			Error(w, ErrInvalidJSON)
-			return
-		}
-
-		name := pathBase(r.URL.Path)
+		name := keyName(r)
 		if name == "" {
 			Error(w, ErrInvalidKeyName)
 			return
 		}
-		secret, err := store.Get(name)
+		events, err := store.Provenance(name)
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		plaintext, err := secret.Unwrap(req.Ciphertext, req.Context)
-		if err != nil {
-			Error(w, err)
-			return
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+// keyInfo is the description of a key returned by
+// HandleListKeys and HandleDescribeKey.
+type keyInfo struct {
+	Name            string                  `json:"name"`
+	Type            string                  `json:"type,omitempty"`
+	Version         string                  `json:"version,omitempty"`
+	CreatedAt       *time.Time              `json:"created_at,omitempty"`
+	LastUsed        *time.Time              `json:"last_used,omitempty"`
+	Sealed          bool                    `json:"sealed"`
+	AllowedOps      []string                `json:"allowed_ops,omitempty"`
+	Immutable       bool                    `json:"immutable,omitempty"`
+	Disabled        bool                    `json:"disabled,omitempty"`
+	LegalHold       *secret.LegalHold       `json:"legal_hold,omitempty"`
+	PendingDeletion *secret.PendingDeletion `json:"pending_deletion,omitempty"`
+	Usage           map[string]uint64       `json:"usage,omitempty"`
+	Policies        map[string][]string     `json:"policies,omitempty"`
+}
+
+// keyOperations are the key operations reported
+// per policy by describeKey.
+var keyOperations = []string{"create", "import", "delete", "generate", "encrypt", "decrypt", "reencrypt"}
+
+// describeKey returns the description of the key with the
+// given name at the store. The key operations allowed by
+// the given policies are evaluated against the key name
+// without any tenant prefix. The description contains the
+// display name instead of the name.
+func describeKey(store *secret.Store, roles *auth.Roles, policies []string, name, key, displayName string) (keyInfo, error) {
+	stat, err := store.Stat(name)
+	if err != nil {
+		return keyInfo{}, err
+	}
+	info := keyInfo{
+		Name:            displayName,
+		Type:            stat.Type,
+		Version:         stat.Version(),
+		Sealed:          stat.Sealed,
+		AllowedOps:      stat.AllowedOps,
+		Immutable:       stat.Immutable,
+		Disabled:        stat.Disabled,
+		LegalHold:       stat.LegalHold,
+		PendingDeletion: stat.PendingDeletion,
+		Usage:           stat.Ops,
+	}
+	if !stat.CreatedAt.IsZero() {
+		info.CreatedAt = &stat.CreatedAt
+	}
+	if !stat.LastUsed.IsZero() {
+		info.LastUsed = &stat.LastUsed
+	}
+
+	for _, policyName := range policies {
+		policy, ok := roles.Get(policyName)
+		if !ok {
+			continue
 		}
-		json.NewEncoder(w).Encode(Response{
-			Plaintext: plaintext,
-		})
+		for _, op := range keyOperations {
+			if policy.AllowsPath("/v1/key/" + op + "/" + key) {
+				if info.Policies == nil {
+					info.Policies = map[string][]string{}
+				}
+				info.Policies[policyName] = append(info.Policies[policyName], op)
+			}
+		}
+	}
+	return info, nil
+}
+
+// namespacePolicies returns the names of the policies that
+// apply to the keys of the given namespace. The keys of a
+// tenant are only accessible by the identities of the tenant.
+// The empty namespace contains the keys of no tenant.
+func namespacePolicies(roles *auth.Roles, namespace string) []string {
+	if namespace == "" {
+		return roles.Policies()
+	}
+	var policies []string
+	for policy := range tenantPolicies(roles, namespace) {
+		policies = append(policies, policy)
 	}
+	return policies
+}
+
+// splitNamespace splits the key name into the namespace -
+// i.e. the tenant - and the key name within the namespace.
+// A key name like "my-app/my-key" is not within a namespace
+// unless "my-app" is a tenant.
+func splitNamespace(tenants *auth.Tenants, name string) (namespace, key string) {
+	if i := strings.IndexByte(name, '/'); i > 0 && tenants.Exists(name[:i]) {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// patternPrefix returns the literal prefix of the key name
+// pattern - i.e. all leading segments without wildcards -
+// including the trailing '/'. For example, "my-app/" for
+// "my-app/*" and "" for "my-*".
+func patternPrefix(pattern string) string {
+	i := strings.IndexAny(pattern, `*?[\`)
+	if i < 0 {
+		i = len(pattern)
+	}
+	return pattern[:strings.LastIndexByte(pattern[:i], '/')+1]
 }
 
 func HandleWritePolicy(roles *auth.Roles) http.HandlerFunc {
@@ -407,7 +1759,26 @@ func HandleWritePolicy(roles *auth.Roles) http.HandlerFunc {
 			Error(w, ErrInvalidJSON)
 			return
 		}
+		if r.Header.Get("If-Match") != "" {
+			var version string
+			if current, ok := roles.Get(name); ok {
+				version = policyVersion(current)
+			}
+			if err := checkPrecondition(r, version); err != nil {
+				Error(w, err)
+				return
+			}
+		}
 		roles.Set(name, &policy)
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		roles.Events.Publish(watch.Event{
+			Type:   watch.TypePolicy,
+			Action: watch.ActionWritten,
+			Name:   name,
+		})
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -423,6 +1794,10 @@ func HandleReadPolicy(roles *auth.Roles) http.HandlerFunc {
 			return
 		}
 
+		if t, ok := tenantOf(r); ok && !tenantPolicies(roles, t.Name)[name] {
+			Error(w, kes.ErrPolicyNotFound) // Don't reveal policies of others
+			return
+		}
 		policy, ok := roles.Get(name)
 		if !ok {
 			Error(w, kes.ErrPolicyNotFound)
@@ -434,9 +1809,17 @@ func HandleReadPolicy(roles *auth.Roles) http.HandlerFunc {
 
 func HandleListPolicies(roles *auth.Roles) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		var visible map[string]bool
+		if t, ok := tenantOf(r); ok {
+			visible = tenantPolicies(roles, t.Name)
+		}
+
 		var policies = []string{}
 		pattern := pathBase(r.URL.Path)
 		for _, policy := range roles.Policies() {
+			if visible != nil && !visible[policy] {
+				continue
+			}
 			if ok, err := path.Match(pattern, policy); ok && err == nil {
 				policies = append(policies, policy)
 			}
@@ -454,11 +1837,95 @@ func HandleDeletePolicy(roles *auth.Roles) http.HandlerFunc {
 			Error(w, ErrInvalidPolicyName)
 			return
 		}
+		_, exists := roles.Get(name)
 		roles.Delete(name)
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		if exists {
+			roles.Events.Publish(watch.Event{
+				Type:   watch.TypePolicy,
+				Action: watch.ActionDeleted,
+				Name:   name,
+			})
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// HandleTestPolicy returns a handler function that evaluates
+// a hypothetical request of an identity against the current
+// policies without sending the request itself:
+//  {
+//    "identity": "<identity>",
+//    "api":      "<api>",        // e.g. /v1/key/encrypt
+//    "key":      "<key-name>"    // optional
+//  }
+//
+// It responds whether the identity is allowed to send such
+// a request and the policy rule that decides it:
+//  {
+//    "allowed":     <bool>,
+//    "path":        "<api>/<key-name>",
+//    "policy":      "<policy-name>",
+//    "rule":        "<rule>",
+//    "reason":      "<reason>",
+//    "conditional": <bool>
+//  }
+// Policy conditions are not evaluated since they depend on
+// the particular request. See: auth.Roles.Explain
+func HandleTestPolicy(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidJSON     = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
+		ErrInvalidAPI      = kes.NewError(http.StatusBadRequest, "invalid API path")
+	)
+	type Request struct {
+		Identity kes.Identity `json:"identity"`
+		API      string       `json:"api"`
+		Key      string       `json:"key"`
+	}
+	type Response struct {
+		Allowed     bool   `json:"allowed"`
+		Path        string `json:"path"`
+		Policy      string `json:"policy,omitempty"`
+		Rule        string `json:"rule,omitempty"`
+		Reason      string `json:"reason"`
+		Conditional bool   `json:"conditional,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		if req.Identity.IsUnknown() {
+			Error(w, ErrIdentityUnknown)
+			return
+		}
+		if !strings.HasPrefix(req.API, "/") || path.Clean(req.API) != req.API {
+			Error(w, ErrInvalidAPI)
+			return
+		}
+		apiPath := req.API
+		if req.Key != "" {
+			apiPath += "/" + req.Key
+		}
+
+		decision := roles.Explain(req.Identity, apiPath)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Allowed:     decision.Allowed,
+			Path:        apiPath,
+			Policy:      decision.Policy,
+			Rule:        decision.Rule,
+			Reason:      decision.Reason,
+			Conditional: decision.Conditional,
+		})
+	}
+}
+
 func HandleAssignIdentity(roles *auth.Roles) http.HandlerFunc {
 	var (
 		ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
@@ -471,11 +1938,11 @@ func HandleAssignIdentity(roles *auth.Roles) http.HandlerFunc {
 			Error(w, ErrIdentityUnknown)
 			return
 		}
-		if identity == roles.Root {
+		if roles.IsRoot(identity) {
 			Error(w, ErrIdentityRoot)
 			return
 		}
-		if identity == auth.Identify(r, roles.Identify) {
+		if secret.EqualIdentity(identity, auth.Identify(r, roles.Identify)) {
 			Error(w, ErrSelfAssign)
 			return
 		}
@@ -485,15 +1952,26 @@ func HandleAssignIdentity(roles *auth.Roles) http.HandlerFunc {
 			Error(w, kes.ErrPolicyNotFound)
 			return
 		}
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
 func HandleListIdentities(roles *auth.Roles) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		t, isTenant := tenantOf(r)
+
 		pattern := pathBase(r.URL.Path)
 		identities := map[kes.Identity]string{}
 		for id, policy := range roles.Identities() {
+			if isTenant {
+				if name, ok := t.Tenants.Lookup(id); !ok || name != t.Name {
+					continue
+				}
+			}
 			if ok, err := path.Match(pattern, id.String()); ok && err == nil {
 				identities[id] = policy
 			}
@@ -502,6 +1980,52 @@ func HandleListIdentities(roles *auth.Roles) http.HandlerFunc {
 	}
 }
 
+// HandleIdentityUsage returns an http.HandlerFunc that reports
+// the number of requests per API of all identities matching the
+// pattern of the request URL - e.g. /v1/identity/usage/*. The
+// requests are reported per time window. The optional since
+// query parameter - e.g. ?since=24h - limits the report to the
+// time windows that ended during the given period.
+//
+// A tenant identity only sees the requests of the identities
+// of its tenant.
+func HandleIdentityUsage(acct *accounting.Accounting, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrAccountingDisabled = kes.NewError(http.StatusNotImplemented, "request accounting is disabled")
+		ErrInvalidSince       = kes.NewError(http.StatusBadRequest, "invalid since: must be a positive duration")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if acct == nil {
+			Error(w, ErrAccountingDisabled)
+			return
+		}
+		var since time.Time
+		if value := r.URL.Query().Get("since"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				Error(w, ErrInvalidSince)
+				return
+			}
+			since = time.Now().Add(-d)
+		}
+		t, isTenant := tenantOf(r)
+		pattern := pathBase(r.URL.Path)
+
+		var records = []accounting.Record{}
+		for _, record := range acct.Report(since) {
+			if isTenant {
+				if name, ok := t.Tenants.Lookup(record.Identity); !ok || name != t.Name {
+					continue
+				}
+			}
+			if ok, err := path.Match(pattern, record.Identity.String()); ok && err == nil {
+				records = append(records, record)
+			}
+		}
+		json.NewEncoder(w).Encode(records)
+	}
+}
+
 func HandleForgetIdentity(roles *auth.Roles) http.HandlerFunc {
 	var (
 		ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
@@ -513,11 +2037,152 @@ func HandleForgetIdentity(roles *auth.Roles) http.HandlerFunc {
 			Error(w, ErrIdentityUnknown)
 			return
 		}
-		if identity == roles.Root {
+		if roles.IsRoot(identity) {
 			Error(w, ErrIdentityRoot)
 			return
 		}
 		roles.Forget(identity)
+		if err := roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleRenewIdentity returns a handler function that sets the
+// TTL of the identity specified by the request URL path base.
+// The TTL is specified by the URL query parameter:
+//  ?ttl=<duration>
+// For example: ?ttl=720h
+//
+// If no TTL is specified the identity never expires.
+func HandleRenewIdentity(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrIdentityUnknown = kes.NewError(http.StatusBadRequest, "identity is unknown")
+		ErrIdentityRoot    = kes.NewError(http.StatusBadRequest, "identity is root")
+		ErrSelfRenew       = kes.NewError(http.StatusForbidden, "identity cannot renew itself")
+		ErrInvalidTTL      = kes.NewError(http.StatusBadRequest, "invalid ttl")
+		ErrNotAssigned     = kes.NewError(http.StatusNotFound, "identity is not assigned to a policy")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := kes.Identity(pathBase(r.URL.Path))
+		if identity.IsUnknown() {
+			Error(w, ErrIdentityUnknown)
+			return
+		}
+		if roles.IsRoot(identity) {
+			Error(w, ErrIdentityRoot)
+			return
+		}
+		if secret.EqualIdentity(identity, auth.Identify(r, roles.Identify)) {
+			Error(w, ErrSelfRenew)
+			return
+		}
+
+		var ttl time.Duration
+		if value := r.URL.Query().Get("ttl"); value != "" {
+			var err error
+			if ttl, err = time.ParseDuration(value); err != nil || ttl < 0 {
+				Error(w, ErrInvalidTTL)
+				return
+			}
+		}
+		expiry, err := roles.Renew(identity, ttl)
+		if err != nil {
+			Error(w, ErrNotAssigned)
+			return
+		}
+		if err = roles.Save(); err != nil {
+			Error(w, err)
+			return
+		}
+
+		type Response struct {
+			Expiry time.Time `json:"expiry,omitempty"`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{Expiry: expiry})
+	}
+}
+
+// HandleListQuotas returns a handler function that returns
+// the key quotas of all identities and tenants as JSON:
+//  {
+//    "identities": { "<identity>": { "limit": <n>, "keys": <n> } },
+//    "tenants":    { "<tenant>":   { "limit": <n>, "keys": <n> } }
+//  }
+// A limit <= 0 means that there is no limit.
+func HandleListQuotas(roles *auth.Roles) http.HandlerFunc {
+	var ErrQuotasDisabled = kes.NewError(http.StatusBadRequest, "key quotas are not enabled")
+
+	type Response struct {
+		Identities map[kes.Identity]auth.Quota `json:"identities"`
+		Tenants    map[string]auth.Quota       `json:"tenants"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if roles.Quotas == nil {
+			Error(w, ErrQuotasDisabled)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Identities: roles.Quotas.Identities(),
+			Tenants:    roles.Quotas.Tenants(),
+		})
+	}
+}
+
+// HandleSetQuota returns a handler function that sets the
+// key quota of an identity or tenant. The URL path must be:
+//  /v1/quota/set/identity/<identity>?limit=<n>
+//  /v1/quota/set/tenant/<tenant>?limit=<n>
+// A limit of 0 means that there is no limit.
+//
+// A quota set via the API takes precedence over the quota
+// specified in the config file.
+func HandleSetQuota(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrQuotasDisabled = kes.NewError(http.StatusBadRequest, "key quotas are not enabled")
+		ErrInvalidLimit   = kes.NewError(http.StatusBadRequest, "invalid limit")
+		ErrInvalidQuota   = kes.NewError(http.StatusBadRequest, "quota must be set for an identity or tenant")
+		ErrNoTenant       = kes.NewError(http.StatusNotFound, "tenant does not exist")
+		ErrIdentityRoot   = kes.NewError(http.StatusBadRequest, "identity is root")
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if roles.Quotas == nil {
+			Error(w, ErrQuotasDisabled)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 0 {
+			Error(w, ErrInvalidLimit)
+			return
+		}
+
+		name := pathBase(r.URL.Path)
+		switch pathBase(strings.TrimSuffix(r.URL.Path, "/"+name)) {
+		case "identity":
+			identity := kes.Identity(name)
+			if roles.IsRoot(identity) {
+				Error(w, ErrIdentityRoot)
+				return
+			}
+			roles.Quotas.SetIdentityRule(identity, limit)
+		case "tenant":
+			if !roles.Tenants.Exists(name) {
+				Error(w, ErrNoTenant)
+				return
+			}
+			roles.Quotas.SetTenantRule(name, limit)
+		default:
+			Error(w, ErrInvalidQuota)
+			return
+		}
+		if err = roles.Quotas.Save(); err != nil {
+			Error(w, err)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -525,13 +2190,34 @@ func HandleForgetIdentity(roles *auth.Roles) http.HandlerFunc {
 // HandleTraceAuditLog returns a HTTP handler that
 // writes whatever log logs to the client.
 //
+// The client can filter the audit events by identity,
+// API and response status via the URL query parameters:
+//  ?identity=<identity>&api=<api>&status=<code|class>
+// For example: ?api=/v1/key/decrypt&status=4xx
+//
 // The returned handler is a long-running server task
 // that will wait for the client to close the connection
 // resp. until the request context is done.
 // Therefore, it will not work properly with (write) timeouts.
 func HandleTraceAuditLog(log *xlog.SystemLog) http.HandlerFunc {
+	var ErrInvalidStatus = kes.NewError(http.StatusBadRequest, "invalid status filter")
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		out := xlog.NewFlushWriter(w)
+		query := r.URL.Query()
+		filter := kes.AuditFilter{
+			Identity: kes.Identity(query.Get("identity")),
+			API:      query.Get("api"),
+			Status:   strings.ToLower(query.Get("status")),
+		}
+		if !validStatusFilter(filter.Status) {
+			Error(w, ErrInvalidStatus)
+			return
+		}
+
+		out := &auditFilterWriter{
+			Writer: xlog.NewFlushWriter(w),
+			Filter: filter,
+		}
 		log.AddOutput(out)
 		defer log.RemoveOutput(out)
 
@@ -580,3 +2266,169 @@ func HandleTraceErrorLog(log *xlog.SystemLog) http.HandlerFunc {
 }
 
 func pathBase(p string) string { return path.Base(p) }
+
+type tenantContextKey struct{}
+
+// tenant is the tenant of a request identity.
+// See: EnforceTenancy
+type tenant struct {
+	Name    string
+	Tenants *auth.Tenants
+}
+
+// tenantOf returns the tenant of the request
+// identity, if any.
+func tenantOf(r *http.Request) (tenant, bool) {
+	t, ok := r.Context().Value(tenantContextKey{}).(tenant)
+	return t, ok
+}
+
+// keyName returns the name of the key referenced by the
+// request URL path - e.g. "my-app/my-key" for the path
+// /v1/key/create/my-app/my-key. If the request identity is
+// bound to a tenant, the name is within the tenant's
+// namespace. It returns "" if the name is not valid.
+func keyName(r *http.Request) string {
+	name := clientKeyName(r)
+	if t, ok := tenantOf(r); ok && name != "" {
+		return t.Tenants.KeyName(t.Name, name)
+	}
+	return name
+}
+
+// clientKeyName returns the name of the key referenced by
+// the request URL path as seen by the client - i.e. without
+// any tenant prefix. It returns "" if the name is not valid.
+func clientKeyName(r *http.Request) string {
+	name, _ := auth.APIKeyName(r.URL.Path)
+	if !secret.ValidName(name) {
+		return ""
+	}
+	return name
+}
+
+// clientName returns the name of the key as seen by the
+// client - i.e. without the tenant prefix of the request
+// identity, if any.
+func clientName(r *http.Request, name string) string {
+	if t, ok := tenantOf(r); ok {
+		return strings.TrimPrefix(name, t.Tenants.KeyName(t.Name, ""))
+	}
+	return name
+}
+
+// verifyKeyAccess verifies that the request identity is
+// allowed to perform the key operation with the given key,
+// as seen by the client, as if it had sent the request to
+// /v1/key/<operation>/<key>. It should be used when a request
+// refers to another key than the one in its URL path.
+func verifyKeyAccess(r *http.Request, roles *auth.Roles, op, key string) error {
+	req := r.Clone(r.Context())
+	req.URL.Path = "/v1/key/" + op + "/" + key
+	return roles.Verify(req)
+}
+
+// provenanceEvent returns a provenance event of the
+// given type caused by the identity of the request.
+func provenanceEvent(r *http.Request, roles *auth.Roles, typ string) secret.ProvenanceEvent {
+	return secret.ProvenanceEvent{
+		Type:     typ,
+		Identity: auth.Identify(r, roles.Identify),
+	}
+}
+
+// reserveKey reserves the key with the given name for the
+// request principal, if the roles enforce key quotas. All
+// sessions of an identity share its quota.
+func reserveKey(r *http.Request, roles *auth.Roles, name string) error {
+	if roles.Quotas == nil {
+		return nil
+	}
+	return roles.Quotas.Reserve(name, roles.Principal(r))
+}
+
+// releaseKey releases the key with the given name, if
+// the roles enforce key quotas.
+func releaseKey(roles *auth.Roles, name string) {
+	if roles.Quotas != nil {
+		roles.Quotas.Release(name)
+	}
+}
+
+// tenantPolicies returns the policies assigned to
+// the identities of the given tenant.
+func tenantPolicies(roles *auth.Roles, name string) map[string]bool {
+	policies := map[string]bool{}
+	for id, policy := range roles.Identities() {
+		if t, ok := roles.Tenants.Lookup(id); ok && t == name {
+			policies[policy] = true
+		}
+	}
+	return policies
+}
+
+// auditFilterWriter is an io.Writer that only writes
+// JSON-encoded audit events that match the filter.
+type auditFilterWriter struct {
+	io.Writer
+
+	Filter kes.AuditFilter
+}
+
+func (w *auditFilterWriter) Write(p []byte) (int, error) {
+	var event kes.AuditEvent
+	if err := json.Unmarshal(p, &event); err != nil {
+		return len(p), nil // Drop anything that is not an audit event
+	}
+	if !w.Filter.Match(&event) {
+		return len(p), nil
+	}
+	return w.Writer.Write(p)
+}
+
+// validStatusFilter reports whether s is empty, a HTTP
+// status code - e.g. "404" - or a HTTP status class -
+// e.g. "4xx".
+func validStatusFilter(s string) bool {
+	if s == "" {
+		return true
+	}
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// statusWriter is an http.ResponseWriter that
+// remembers the response status code.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+}
+
+var _ http.Flusher = (*statusWriter)(nil)
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
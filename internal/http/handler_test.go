@@ -6,8 +6,22 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/seal"
+	"github.com/minio/kes/internal/secret"
 )
 
 var validatePathHandlerTests = []struct {
@@ -30,13 +44,20 @@ var validatePathHandlerTests = []struct {
 	{Pattern: "/v1/identity/list/*", Path: "/v1/identity/list/af43c", ShouldMatch: true},                 // 12
 	{Pattern: "/v1/identity/list/*", Path: "/v1/identity/list/*", ShouldMatch: true},                     // 13
 	{Pattern: "/v1/identity/forget/*", Path: "/v1/identity/forget/af43c", ShouldMatch: true},             // 14
+	{Pattern: "/v1/key/bulk/generate/*", Path: "/v1/key/bulk/generate/my-key", ShouldMatch: true},        // 15
+	{Pattern: "/v1/key/bulk/decrypt/*", Path: "/v1/key/bulk/decrypt/my-key", ShouldMatch: true},          // 16
+
+	{Pattern: "/v1/key/create/*", Path: "/v1/key/create/my-key/..", ShouldMatch: false},   // 17
+	{Pattern: "/v1/key/create/*", Path: "/v1/key/create/../my-key", ShouldMatch: false},   // 18
+	{Pattern: "/v1/key/decypt/*", Path: "/v1/key/create/my-key", ShouldMatch: false},      // 19
+	{Pattern: "/v1/key/generate/*", Path: "/v1/key/create/my-key/x", ShouldMatch: false},  // 20
+	{Pattern: "/v1/key/create/[a-z]", Path: "/v1/key/create/my-key0", ShouldMatch: false}, // 21
+	{Pattern: "/v1/key/decypt/*", Path: "/v1/key/create/./*/../a", ShouldMatch: false},    // 22
 
-	{Pattern: "/v1/key/create/*", Path: "/v1/key/create/my-key/..", ShouldMatch: false},   // 15
-	{Pattern: "/v1/key/create/*", Path: "/v1/key/create/../my-key", ShouldMatch: false},   // 16
-	{Pattern: "/v1/key/decypt/*", Path: "/v1/key/create/my-key", ShouldMatch: false},      // 17
-	{Pattern: "/v1/key/generate/*", Path: "/v1/key/create/my-key/x", ShouldMatch: false},  // 18
-	{Pattern: "/v1/key/create/[a-z]", Path: "/v1/key/create/my-key0", ShouldMatch: false}, // 19
-	{Pattern: "/v1/key/decypt/*", Path: "/v1/key/create/./*/../a", ShouldMatch: false},    // 20
+	{Pattern: "/v1/key/create/**", Path: "/v1/key/create/my-app/my-key", ShouldMatch: true},  // 23
+	{Pattern: "/v1/key/create/**", Path: "/v1/key/create/my-key", ShouldMatch: true},         // 24
+	{Pattern: "/v1/key/create/*", Path: "/v1/key/create/my-app/my-key", ShouldMatch: false},  // 25
+	{Pattern: "/v1/key/create/**", Path: "/v1/key/delete/my-app/my-key", ShouldMatch: false}, // 26
 }
 
 func TestValidatePathHandler(t *testing.T) {
@@ -60,6 +81,926 @@ func TestValidatePathHandler(t *testing.T) {
 	}
 }
 
+func TestHandleReady(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/ready", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	var resp dummyResponseWriter
+	HandleReady(&secret.Store{Remote: &mem.Store{}}, 0)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Available store should be ready: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = dummyResponseWriter{}
+	HandleReady(&secret.Store{Remote: unavailableRemote{}}, 0)(&resp, req)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Unavailable store should not be ready: got %d - want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHandleBulkKey(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	if err := store.Create(context.Background(), "my-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/bulk/generate/my-key", strings.NewReader(`[{},{"context":"Y29udGV4dA=="}]`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	HandleBulkGenerateKey(store)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	type DEK struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	var deks []DEK
+	if err = json.Unmarshal(resp.Body.Bytes(), &deks); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(deks) != 2 {
+		t.Fatalf("Invalid number of keys: got %d - want %d", len(deks), 2)
+	}
+
+	body, _ := json.Marshal([]map[string][]byte{
+		{"ciphertext": deks[0].Ciphertext},
+		{"ciphertext": deks[1].Ciphertext, "context": []byte("context")},
+	})
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/key/bulk/decrypt/my-key", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleBulkDecryptKey(store)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to decrypt keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var plaintexts []DEK
+	if err = json.Unmarshal(resp.Body.Bytes(), &plaintexts); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	for i := range deks {
+		if !bytes.Equal(plaintexts[i].Plaintext, deks[i].Plaintext) {
+			t.Fatalf("Key %d: plaintext mismatch", i)
+		}
+	}
+
+	body, _ = json.Marshal([]map[string][]byte{
+		{"ciphertext": deks[0].Ciphertext},
+		{"ciphertext": deks[1].Ciphertext}, // Context is missing
+	})
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/key/bulk/decrypt/my-key", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleBulkDecryptKey(store)(&resp, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Decrypting a non-authentic ciphertext should fail: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandleListKeys(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	for _, name := range []string{"my-key", "other-key", "tenant/my-key"} {
+		if err := store.Create(context.Background(), name, secret.Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if _, err := store.Get(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to fetch key: %v", err)
+	}
+
+	roles := &auth.Roles{}
+	app, _ := kes.NewPolicy("/v1/key/generate/my-*", "/v1/key/decrypt/*")
+	ops, _ := kes.NewPolicy("/v1/key/delete/*")
+	roles.Set("app", app)
+	roles.Set("ops", ops)
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/list/*", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	HandleListKeys(store, roles)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to list keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+
+	var keys []kes.KeyInfo
+	if err = json.Unmarshal(resp.Body.Bytes(), &keys); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "my-key" || keys[1].Name != "other-key" {
+		t.Fatalf("Invalid keys: got %v - want [my-key other-key]", keys)
+	}
+	if keys[0].CreatedAt.IsZero() || keys[1].CreatedAt.IsZero() {
+		t.Fatal("Keys have no creation time")
+	}
+	if keys[0].LastUsed.IsZero() || !keys[1].LastUsed.IsZero() {
+		t.Fatalf("Invalid last use: got %v and %v", keys[0].LastUsed, keys[1].LastUsed)
+	}
+	if got := fmt.Sprint(keys[0].Policies); got != "map[app:[generate decrypt] ops:[delete]]" {
+		t.Fatalf("Invalid policies of '%s': got %s", keys[0].Name, got)
+	}
+	if got := fmt.Sprint(keys[1].Policies); got != "map[app:[decrypt] ops:[delete]]" {
+		t.Fatalf("Invalid policies of '%s': got %s", keys[1].Name, got)
+	}
+}
+
+func TestHandleListKeysPrefix(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	for _, name := range []string{"my-key", "my-app/key-1", "my-app/team/key-2", "tenant-a/key-3"} {
+		if err := store.Create(context.Background(), name, secret.Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	roles := &auth.Roles{Tenants: new(auth.Tenants)}
+	if err := roles.Tenants.Add("tenant-a", "af43c"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+
+	for i, test := range []struct {
+		Pattern string
+		Names   string
+	}{
+		{Pattern: "*", Names: "my-key"},                                 // 0
+		{Pattern: "**", Names: "my-app/key-1,my-app/team/key-2,my-key"}, // 1
+		{Pattern: "my-app/*", Names: "my-app/key-1"},                    // 2
+		{Pattern: "my-app/**", Names: "my-app/key-1,my-app/team/key-2"}, // 3
+		{Pattern: "my-app/*/key-[0-9]", Names: "my-app/team/key-2"},     // 4
+		{Pattern: "tenant-a/*", Names: ""},                              // 5
+		{Pattern: "**?prefix=my-app/t", Names: "my-app/team/key-2"},     // 6
+	} {
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/list/"+test.Pattern, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		var resp dummyResponseWriter
+		HandleListKeys(store, roles)(&resp, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Test %d: failed to list keys: got %d - want %d: %s", i, resp.StatusCode, http.StatusOK, resp.Body.String())
+		}
+
+		var keys []kes.KeyInfo
+		if err = json.Unmarshal(resp.Body.Bytes(), &keys); err != nil {
+			t.Fatalf("Test %d: failed to parse response: %v", i, err)
+		}
+		var names []string
+		for _, key := range keys {
+			names = append(names, key.Name)
+		}
+		if got := strings.Join(names, ","); got != test.Names {
+			t.Fatalf("Test %d: got %s - want %s", i, got, test.Names)
+		}
+	}
+}
+
+func TestHandleListKeysPages(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	for _, name := range []string{"key-1", "key-2", "key-3", "key-4", "key-5"} {
+		if err := store.Create(context.Background(), name, secret.Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	roles := &auth.Roles{}
+
+	var (
+		names []string
+		token string
+		pages int
+	)
+	for {
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/list/*?limit=2&continue="+token, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		HandleListKeys(store, roles)(&resp, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to list keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+		}
+		var keys []kes.KeyInfo
+		if err = json.Unmarshal(resp.Body.Bytes(), &keys); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		for _, key := range keys {
+			names = append(names, key.Name)
+		}
+		if pages++; pages > 3 {
+			t.Fatal("Listing does not terminate")
+		}
+		if token = resp.Header().Get("Kes-Continue-Token"); token == "" {
+			break
+		}
+	}
+	if got := strings.Join(names, ","); pages != 3 || got != "key-1,key-2,key-3,key-4,key-5" {
+		t.Fatalf("Invalid keys: got %s in %d pages - want key-1,...,key-5 in 3 pages", got, pages)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/list/*?continue=a2V5LTM", nil) // After key-3
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	var resp dummyResponseWriter
+	HandleListKeys(store, roles)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to stream keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Fatalf("Invalid content type: got %s - want application/x-ndjson", contentType)
+	}
+	names = names[:0]
+	decoder := json.NewDecoder(&resp.Body)
+	for decoder.More() {
+		var key kes.KeyInfo
+		if err = decoder.Decode(&key); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		names = append(names, key.Name)
+	}
+	if got := strings.Join(names, ","); got != "key-4,key-5" {
+		t.Fatalf("Invalid keys: got %s - want key-4,key-5", got)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, baseURL+"/v1/key/list/*?continue=!", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleListKeys(store, roles)(&resp, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Invalid continue token has been accepted: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandleBulkDeleteKey(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	for _, name := range []string{"my-app", "my-app/key-1", "my-app/team/key-2", "my-app-2/key-3", "tenant-a/key-4"} {
+		if err := store.Create(context.Background(), name, secret.Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := store.CreateImmutable(context.Background(), "my-app/immutable", secret.Secret{}, nil); err != nil {
+		t.Fatalf("Failed to create immutable key: %v", err)
+	}
+	roles := &auth.Roles{Tenants: new(auth.Tenants)}
+	if err := roles.Tenants.Add("tenant-a", "af43c"); err != nil {
+		t.Fatalf("Failed to add tenant: %v", err)
+	}
+	send := func(path string) dummyResponseWriter {
+		req, err := http.NewRequest(http.MethodDelete, baseURL+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		HandleBulkDeleteKey(store, roles)(&resp, req)
+		return resp
+	}
+
+	resp := send("/v1/key/bulk/delete/my-app")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var report struct {
+		Deleted []string          `json:"deleted"`
+		Skipped map[string]string `json:"skipped"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if got, want := strings.Join(report.Deleted, ","), "my-app/key-1,my-app/team/key-2"; got != want {
+		t.Fatalf("Invalid deleted keys: got %s - want %s", got, want)
+	}
+	if _, ok := report.Skipped["my-app/immutable"]; !ok || len(report.Skipped) != 1 {
+		t.Fatalf("Immutable key has not been skipped: got %v", report.Skipped)
+	}
+	names, err := store.List()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "my-app,my-app-2/key-3,my-app/immutable,tenant-a/key-4"; got != want {
+		t.Fatalf("Invalid remaining keys: got %s - want %s", got, want)
+	}
+
+	// Identities that are not bound to a tenant cannot
+	// delete the keys of a tenant.
+	if resp = send("/v1/key/bulk/delete/tenant-a"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete keys: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if _, err = store.Stat("tenant-a/key-4"); err != nil {
+		t.Fatalf("Key of tenant has been deleted: %v", err)
+	}
+	if resp = send("/v1/key/bulk/delete/"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Deleting all keys should have failed: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandleDescribeKey(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	store := &secret.Store{Remote: &mem.Store{}}
+	if err := store.Create(context.Background(), "my-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	roles := &auth.Roles{}
+	app, _ := kes.NewPolicy("/v1/key/generate/my-*")
+	roles.Set("app", app)
+
+	generate := func() {
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/generate/my-key", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		HandleGenerateKey(store)(&resp, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to generate key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+		}
+	}
+	generate()
+	generate()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/key/describe/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	HandleDescribeKey(store, roles)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to describe key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var info kes.KeyInfo
+	if err = json.Unmarshal(resp.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if info.Name != "my-key" || info.LastUsed.IsZero() {
+		t.Fatalf("Invalid key description: %v", info)
+	}
+	if got := fmt.Sprint(info.Usage); got != "map[generate:2]" {
+		t.Fatalf("Invalid usage: got %s - want map[generate:2]", got)
+	}
+	if got := fmt.Sprint(info.Policies); got != "map[app:[generate]]" {
+		t.Fatalf("Invalid policies: got %s - want map[app:[generate]]", got)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, baseURL+"/v1/key/describe/other-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleDescribeKey(store, roles)(&resp, req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Described non-existing key: got %d - want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHandleCreateKeyWithOps(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(http.MethodPost, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateKey(store, roles), "/v1/key/create/my-key", `{"ops":["decrypt"]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleCreateKey(store, roles), "/v1/key/create/any-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key without body: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleCreateKey(store, roles), "/v1/key/create/other-key", `{"ops":["sign"]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Created key with invalid operation: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	if resp := send(HandleGenerateKey(store), "/v1/key/generate/my-key", `{}`); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Generated data key with decrypt-only key: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	if resp := send(HandleGenerateKey(store), "/v1/key/generate/any-key", `{}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate data key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleDecryptKey(store), "/v1/key/decrypt/my-key", `{"ciphertext":null}`); resp.StatusCode == http.StatusForbidden {
+		t.Fatalf("Key usage policy rejected decryption: %s", resp.Body.String())
+	}
+}
+
+func TestHandleImmutableKey(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateKey(store, roles), http.MethodPost, "/v1/key/create/root-key", `{"immutable":true}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleDeleteKey(store, roles), http.MethodDelete, "/v1/key/delete/root-key", ""); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Deleted immutable key: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	if resp := send(HandleDisableKey(store), http.MethodPost, "/v1/key/disable/root-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to disable key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleGenerateKey(store), http.MethodPost, "/v1/key/generate/root-key", `{}`); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Generated data key with disabled key: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	resp := send(HandleDescribeKey(store, roles), http.MethodGet, "/v1/key/describe/root-key", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to describe key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var info kes.KeyInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if !info.Immutable || !info.Disabled {
+		t.Fatalf("Invalid key description: %+v", info)
+	}
+
+	if resp = send(HandleEnableKey(store), http.MethodPost, "/v1/key/enable/root-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to enable key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp = send(HandleGenerateKey(store), http.MethodPost, "/v1/key/generate/root-key", `{}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate data key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+}
+
+func TestHandleLegalHold(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateKey(store, roles), http.MethodPost, "/v1/key/create/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleHoldKey(store, roles), http.MethodPost, "/v1/key/hold/set/my-key", `{"reason":"case 42"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to place legal hold: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleHoldKey(store, roles), http.MethodPost, "/v1/key/hold/set/my-key", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Placed legal hold twice: got %d - want %d", resp.StatusCode, http.StatusConflict)
+	}
+	if resp := send(HandleDeleteKey(store, roles), http.MethodDelete, "/v1/key/delete/my-key", ""); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Deleted key under legal hold: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp := send(HandleDescribeKey(store, roles), http.MethodGet, "/v1/key/describe/my-key", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to describe key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var info kes.KeyInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if info.LegalHold == nil || info.LegalHold.Reason != "case 42" {
+		t.Fatalf("Invalid key description: %+v", info)
+	}
+
+	if resp = send(HandleReleaseKey(store, roles), http.MethodPost, "/v1/key/hold/release/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to release legal hold: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	events, err := store.Provenance("my-key")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if n := len(events); n < 2 || events[n-2].Type != secret.ProvenanceHeld || events[n-1].Type != secret.ProvenanceReleased {
+		t.Fatalf("Legal hold has not been recorded: %+v", events)
+	}
+	if resp = send(HandleDeleteKey(store, roles), http.MethodDelete, "/v1/key/delete/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+}
+
+func TestHandleScheduleKeyDeletion(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateKey(store, roles), http.MethodPost, "/v1/key/create/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleScheduleKeyDeletion(store, roles), http.MethodPost, "/v1/key/schedule-deletion/my-key", `{"days":3}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Scheduled deletion with invalid window: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	resp := send(HandleScheduleKeyDeletion(store, roles), http.MethodPost, "/v1/key/schedule-deletion/my-key", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to schedule deletion: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var response struct {
+		DeleteAt time.Time `json:"delete_at"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if window := time.Until(response.DeleteAt); window < secret.DefaultDeletionWindow-time.Minute || window > secret.DefaultDeletionWindow {
+		t.Fatalf("Invalid deletion window: got %v - want %v", window, secret.DefaultDeletionWindow)
+	}
+	if resp = send(HandleGenerateKey(store), http.MethodPost, "/v1/key/generate/my-key", `{}`); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Generated data key with key pending deletion: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	if resp = send(HandleCancelKeyDeletion(store, roles), http.MethodPost, "/v1/key/cancel-deletion/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to cancel deletion: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp = send(HandleGenerateKey(store), http.MethodPost, "/v1/key/generate/my-key", `{}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate data key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	events, err := store.Provenance("my-key")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if n := len(events); n < 2 || events[n-2].Type != secret.ProvenanceScheduled || events[n-1].Type != secret.ProvenanceCancelled {
+		t.Fatalf("Scheduled deletion has not been recorded: %+v", events)
+	}
+}
+
+func TestHandleKeyProvenance(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(method string, handler http.HandlerFunc, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(http.MethodGet, HandleKeyProvenance(store), "/v1/key/provenance/my-key", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Non-existing key has provenance: got %d - want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp := send(http.MethodPost, HandleImportKey(store, roles), "/v1/key/import/my-key", `{"bytes":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to import key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(http.MethodDelete, HandleDeleteKey(store, roles), "/v1/key/delete/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(http.MethodDelete, HandleDeleteKey(store, roles), "/v1/key/delete/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to delete key twice: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(http.MethodPost, HandleCreateKey(store, roles), "/v1/key/create/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+
+	resp := send(http.MethodGet, HandleKeyProvenance(store), "/v1/key/provenance/my-key", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to fetch provenance: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var events []secret.ProvenanceEvent
+	if err := json.NewDecoder(&resp.Body).Decode(&events); err != nil {
+		t.Fatalf("Failed to decode provenance: %v", err)
+	}
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if got, want := strings.Join(types, ","), "imported,deleted,created"; got != want {
+		t.Fatalf("Invalid provenance: got %v - want %v", types, want)
+	}
+}
+
+func TestRequireUnsealed(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	barrier := &seal.Barrier{Remote: &mem.Store{}}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/key/create/", HandleCreateKey(&secret.Store{Remote: &mem.Store{}, KMS: barrier}, &auth.Roles{}))
+	mux.Handle("/v1/seal/init", HandleInitSeal(barrier))
+	router := RequireUnsealed(barrier, mux)
+
+	send := func(path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(http.MethodPost, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		router.ServeHTTP(&resp, req)
+		return resp
+	}
+	if resp := send("/v1/key/create/my-key", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Sealed server created a key: got %d - want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if resp := send("/v1/seal/init", `{"shares":3,"threshold":2}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to initialize seal: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send("/v1/key/create/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unsealed server failed to create a key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+}
+
+func TestHandleEncryptDecryptData(t *testing.T) {
+	const (
+		baseURL = "https://localhost:7373"
+		maxSize = 1 << 20
+	)
+	store := &secret.Store{Remote: &mem.Store{}}
+	for _, name := range []string{"my-key", "other-key"} {
+		if err := store.Create(context.Background(), name, secret.Secret{1}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	data := bytes.Repeat([]byte("data"), 20*1024)
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/data/encrypt/my-key", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	HandleEncryptData(store, maxSize)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to encrypt data: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	envelope := resp.Body.Bytes()
+
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/data/decrypt/my-key", bytes.NewReader(envelope))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleDecryptData(store, maxSize)(&resp, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to decrypt data: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if !bytes.Equal(resp.Body.Bytes(), data) {
+		t.Fatal("Decrypted data is not equal to the original data")
+	}
+
+	// An envelope must not be decrypted via the decrypt API of
+	// another key. Otherwise, a policy that allows decrypting
+	// with one key would allow decrypting any envelope.
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/data/decrypt/other-key", bytes.NewReader(envelope))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleDecryptData(store, maxSize)(&resp, req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Decrypted envelope with wrong key: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	modified := append([]byte(nil), envelope...)
+	modified[len(modified)-1] ^= 1
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/data/decrypt/my-key", bytes.NewReader(modified))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Fatalf("Decrypted modified envelope: got %v - want %v", err, http.ErrAbortHandler)
+			}
+		}()
+		HandleDecryptData(store, maxSize)(&resp, req)
+	}()
+
+	req, err = http.NewRequest(http.MethodPost, baseURL+"/v1/data/encrypt/my-key", bytes.NewReader(make([]byte, maxSize+1)))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp = dummyResponseWriter{}
+	HandleEncryptData(store, maxSize)(&resp, req)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Encrypted too large request: got %d - want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestEnforcePoliciesApproval(t *testing.T) {
+	const (
+		baseURL = "https://localhost:7373"
+		Path    = "/v1/enclave/delete/my-enclave"
+	)
+	roles := &auth.Roles{
+		Root: auth.Peer{UID: 0}.Identity(),
+		Approvals: &auth.Approvals{
+			Admins: []kes.Identity{"admin"},
+			Quorum: 1,
+		},
+	}
+	token, _, err := roles.Approvals.Issue("admin", http.MethodDelete, Path)
+	if err != nil {
+		t.Fatalf("Failed to issue approval: %v", err)
+	}
+
+	status := http.StatusInternalServerError
+	handler := EnforcePolicies(roles, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(status) })
+	send := func() int {
+		req, err := http.NewRequest(http.MethodDelete, baseURL+Path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req = req.WithContext(auth.NewPeerContext(req.Context(), auth.Peer{UID: 0}))
+		req.Header.Set(kes.HeaderApproval, token)
+
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp.StatusCode
+	}
+	if code := send(); code != http.StatusInternalServerError {
+		t.Fatalf("Approved request failed: got %d - want %d", code, http.StatusInternalServerError)
+	}
+	status = http.StatusOK
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("Approval tokens of a failed request have been used up: got %d - want %d", code, http.StatusOK)
+	}
+	if code := send(); code != http.StatusForbidden {
+		t.Fatalf("Approval tokens have been used twice: got %d - want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestHandleReEncryptKey(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		ctx   = context.Background()
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{Root: "unix:0"}
+	)
+	for i, name := range []string{"old-key", "new-key", "other-key"} {
+		if err := store.Create(ctx, name, secret.Secret{byte(i + 1)}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	policy, err := kes.NewPolicy("/v1/key/reencrypt/old-key", "/v1/key/generate/new-key")
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	roles.Set("my-app", policy)
+	if err = roles.Assign("my-app", auth.Peer{UID: 1000}.Identity()); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+
+	oldKey, _ := store.Get(ctx, "old-key")
+	plaintext := bytes.Repeat([]byte{0x01}, 32)
+	ciphertext, err := oldKey.Wrap(plaintext, []byte("my-context"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt data key: %v", err)
+	}
+	send := func(newKey string) dummyResponseWriter {
+		body, _ := json.Marshal(map[string]interface{}{
+			"ciphertext": ciphertext,
+			"context":    []byte("my-context"),
+			"key":        newKey,
+		})
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/reencrypt/old-key", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req = req.WithContext(auth.NewPeerContext(req.Context(), auth.Peer{UID: 1000}))
+
+		var resp dummyResponseWriter
+		HandleReEncryptKey(store, roles)(&resp, req)
+		return resp
+	}
+
+	// The identity must not be able to re-encrypt
+	// data keys with a key it cannot generate data
+	// keys with.
+	if resp := send("other-key"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Re-encrypted data key with forbidden key: got %d - want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	resp := send("new-key")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to re-encrypt data key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err = json.NewDecoder(&resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Plaintext) != 0 {
+		t.Fatal("Plaintext data key has been returned")
+	}
+
+	newKey, _ := store.Get(ctx, "new-key")
+	got, err := newKey.Unwrap(response.Ciphertext, []byte("my-context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt re-encrypted data key: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("Invalid data key: got %x - want %x", got, plaintext)
+	}
+}
+
+func TestHandleGenerateKeyHybrid(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		ctx   = context.Background()
+		store = &secret.Store{Remote: &mem.Store{}}
+	)
+	hybridKey, err := secret.NewHybridKey(make([]byte, secret.HybridKeySize))
+	if err != nil {
+		t.Fatalf("Failed to create hybrid key: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", secret.Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	send := func(body string) dummyResponseWriter {
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/generate/my-key", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		HandleGenerateKey(store)(&resp, req)
+		return resp
+	}
+
+	if resp := send(`{"wrap":"kyber"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Generated data key with invalid wrap: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp := send(`{"wrap":"hybrid"}`); resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("Generated hybrid data key without hybrid key: got %d - want %d", resp.StatusCode, http.StatusNotImplemented)
+	}
+
+	store.HybridKey = hybridKey
+	resp := send(`{"context":"bXktY29udGV4dA==","wrap":"hybrid"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to generate data key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var response struct {
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(&resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !bytes.Contains(response.Ciphertext, []byte(secret.HybridAlgorithm)) {
+		t.Fatalf("Data key has not been wrapped with %s: %s", secret.HybridAlgorithm, response.Ciphertext)
+	}
+
+	key, _ := store.Get(ctx, "my-key")
+	plaintext, err := key.UnwrapHybrid(hybridKey, response.Ciphertext, []byte("my-context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key: %v", err)
+	}
+	if !bytes.Equal(plaintext, response.Plaintext) {
+		t.Fatalf("Invalid data key: got %x - want %x", plaintext, response.Plaintext)
+	}
+}
+
+// unavailableRemote is a secret.Remote that
+// fails with an error on every operation.
+type unavailableRemote struct{}
+
+var errUnavailable = errors.New("remote store is not available")
+
+func (unavailableRemote) Create(string, string) error { return errUnavailable }
+func (unavailableRemote) Delete(string) error         { return errUnavailable }
+func (unavailableRemote) Get(string) (string, error)  { return "", errUnavailable }
+
 var (
 	_ http.ResponseWriter = (*dummyResponseWriter)(nil)
 	_ http.Flusher        = (*dummyResponseWriter)(nil)
@@ -96,3 +1037,116 @@ func (d *dummyResponseWriter) Write(p []byte) (int, error) {
 	return d.Body.Write(p)
 }
 func (d *dummyResponseWriter) Flush() {}
+
+func TestHandleTestPolicy(t *testing.T) {
+	roles := &auth.Roles{Root: "root"}
+	policy, _ := kes.NewPolicy("/v1/key/decrypt/my-*")
+	roles.Set("my-app", policy)
+	if err := roles.Assign("my-app", "af43c"); err != nil {
+		t.Fatalf("Failed to assign identity: %v", err)
+	}
+
+	for i, test := range []struct {
+		Body    string
+		Status  int
+		Allowed bool
+	}{
+		{Body: `{"identity":"af43c","api":"/v1/key/decrypt","key":"my-key"}`, Status: http.StatusOK, Allowed: true},     // 0
+		{Body: `{"identity":"af43c","api":"/v1/key/decrypt","key":"other-key"}`, Status: http.StatusOK, Allowed: false}, // 1
+		{Body: `{"identity":"af43c","api":"/v1/key/../policy/write","key":"x"}`, Status: http.StatusBadRequest},         // 2
+		{Body: `{"api":"/v1/key/decrypt","key":"my-key"}`, Status: http.StatusBadRequest},                               // 3
+	} {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/policy/test", strings.NewReader(test.Body))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		var resp dummyResponseWriter
+		HandleTestPolicy(roles)(&resp, req)
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: got status %d - want %d: %s", i, resp.StatusCode, test.Status, resp.Body.String())
+		}
+		if test.Status != http.StatusOK {
+			continue
+		}
+
+		var decision kes.PolicyDecision
+		if err = json.Unmarshal(resp.Body.Bytes(), &decision); err != nil {
+			t.Fatalf("Test %d: failed to parse response: %v", i, err)
+		}
+		if decision.Allowed != test.Allowed || decision.Policy != "my-app" {
+			t.Fatalf("Test %d: invalid decision: %+v", i, decision)
+		}
+	}
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	const MaxSize = 16
+	var readErr error
+	f := func(w http.ResponseWriter, r *http.Request) {
+		if _, readErr = ioutil.ReadAll(r.Body); readErr != nil {
+			Error(w, readErr)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for i, test := range []struct {
+		Body    string
+		Chunked bool // The body length is not known in advance
+		Status  int
+		Err     error
+	}{
+		{Body: "small body", Status: http.StatusOK},                                                                                  // 0
+		{Body: strings.Repeat("a", MaxSize+1), Status: http.StatusRequestEntityTooLarge},                                             // 1
+		{Body: strings.Repeat("a", MaxSize), Chunked: true, Status: http.StatusOK},                                                   // 2
+		{Body: strings.Repeat("a", 2*MaxSize), Chunked: true, Status: http.StatusRequestEntityTooLarge, Err: kes.ErrRequestTooLarge}, // 3
+	} {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/key/create/my-key", strings.NewReader(test.Body))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if test.Chunked {
+			req.ContentLength = -1
+		}
+
+		readErr = nil
+		var resp dummyResponseWriter
+		LimitRequestBody(MaxSize, f)(&resp, req)
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: got status %d - want %d", i, resp.StatusCode, test.Status)
+		}
+		if readErr != test.Err {
+			t.Fatalf("Test %d: got error %v - want %v", i, readErr, test.Err)
+		}
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	f := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for i, test := range []struct {
+		ContentType string
+		Body        string
+		Status      int
+	}{
+		{ContentType: "application/json", Body: `{}`, Status: http.StatusOK},                                    // 0
+		{ContentType: "Application/JSON; charset=utf-8", Body: `{}`, Status: http.StatusOK},                     // 1
+		{ContentType: "", Body: "", Status: http.StatusOK},                                                      // 2: no body
+		{ContentType: "", Body: `{}`, Status: http.StatusUnsupportedMediaType},                                  // 3
+		{ContentType: "application/x-www-form-urlencoded", Body: `{}`, Status: http.StatusUnsupportedMediaType}, // 4
+		{ContentType: "application/json;;", Body: `{}`, Status: http.StatusUnsupportedMediaType},                // 5
+	} {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/key/create/my-key", strings.NewReader(test.Body))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if test.ContentType != "" {
+			req.Header.Set("Content-Type", test.ContentType)
+		}
+
+		var resp dummyResponseWriter
+		RequireContentType("application/json", f)(&resp, req)
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: got status %d - want %d", i, resp.StatusCode, test.Status)
+		}
+	}
+}
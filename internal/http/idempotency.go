@@ -0,0 +1,294 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+const (
+	// maxIdempotencyKey is the max. length of an
+	// idempotency key sent by the client.
+	maxIdempotencyKey = 128
+
+	// maxIdempotentBody is the max. size of a request
+	// or response body that Idempotent remembers. Requests
+	// with a larger body are not deduplicated.
+	maxIdempotentBody = 1 << 20
+)
+
+// IdempotencyCache remembers the responses of requests
+// that carry an idempotency key. See: Idempotent
+//
+// The cache is local to one server. Retries sent to
+// another server are not recognized.
+//
+// The zero value is ready for use.
+type IdempotencyCache struct {
+	// Window is the time span in which a request with
+	// the same idempotency key is recognized as retry.
+	// If 0, it defaults to 10 minutes.
+	Window time.Duration
+
+	// MaxEntries is the max. number of remembered
+	// responses. Once the cache is full, further requests
+	// are processed without deduplication until older
+	// entries expire. If 0, it defaults to 10000.
+	MaxEntries int
+
+	lock    sync.Mutex
+	entries map[string]*idempotentResponse
+}
+
+// idempotentResponse is the response to a request with
+// an idempotency key.
+type idempotentResponse struct {
+	request   [sha256.Size]byte // Hash of the request method, path and body
+	expiresAt time.Time
+
+	// done is closed once the request has been
+	// processed. Before, the fields below must
+	// not be accessed.
+	done chan struct{}
+
+	// ok is false if the response must not be
+	// replayed - e.g. because the request failed
+	// due to a temporary error.
+	ok        bool
+	status    int
+	header    http.Header
+	body      []byte
+	errorCode string // The error code recorded in the audit event
+}
+
+// begin returns the entry for the idempotency key and
+// whether the request is a retry of an earlier request.
+// If the request is no retry, the caller has to process
+// it and complete the returned entry.
+//
+// It returns a nil entry if the cache is full and an error
+// if the idempotency key has been used for a different
+// request before.
+func (c *IdempotencyCache) begin(key string, request [sha256.Size]byte) (*idempotentResponse, bool, error) {
+	var ErrKeyReused = kes.NewError(http.StatusUnprocessableEntity, "idempotency key has already been used for a different request")
+
+	window, maxEntries := c.Window, c.MaxEntries
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expiresAt) {
+		if entry.request != request {
+			return nil, false, ErrKeyReused
+		}
+		return entry, true, nil
+	}
+
+	if c.entries == nil {
+		c.entries = map[string]*idempotentResponse{}
+	}
+	if len(c.entries) >= maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxEntries {
+			return nil, false, nil
+		}
+	}
+	entry := &idempotentResponse{
+		request:   request,
+		expiresAt: now.Add(window),
+		done:      make(chan struct{}),
+	}
+	c.entries[key] = entry
+	return entry, false, nil
+}
+
+// complete stores the response recorded by w and wakes
+// up all retries waiting for it. A response that should
+// not be replayed is removed from the cache such that
+// the next retry gets processed again.
+func (c *IdempotencyCache) complete(key string, entry *idempotentResponse, w *idempotentWriter) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	entry.ok = w.finished && !w.overflow && w.status < 500 && w.status != http.StatusTooManyRequests
+	entry.status = w.status
+	entry.header = w.header
+	entry.body = w.body.Bytes()
+	if aw, ok := auditWriter(w); ok {
+		entry.errorCode = aw.ErrorCode
+	}
+	close(entry.done)
+
+	if !entry.ok {
+		c.lock.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.lock.Unlock()
+	}
+}
+
+// Idempotent returns a handler function that deduplicates
+// requests with the same idempotency key. The first request
+// with an idempotency key is processed by f while the response
+// is remembered by the cache. Any retry - i.e. a request of
+// the same identity with the same idempotency key, method,
+// path and body - within the cache window receives the same
+// response without calling f again. See: kes.HeaderIdempotencyKey
+//
+// A retry that arrives while the first request is still being
+// processed waits for its response. Responses due to temporary
+// errors, like 503 Service Unavailable, are not replayed.
+//
+// Idempotent must be wrapped by the TLSProxy, VerifySignature
+// and EnforcePolicies handlers. Hence, requests are deduplicated
+// per verified client identity - not per TLS proxy - and each
+// retry is authorized again. The audit event of a replayed
+// response is flagged as replay. See: kes.AuditEventResponse
+//
+// If cache is nil, Idempotent returns f unmodified.
+func Idempotent(cache *IdempotencyCache, roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
+	if cache == nil {
+		return f
+	}
+	var ErrInvalidKey = kes.NewError(http.StatusBadRequest, "invalid idempotency key")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(kes.HeaderIdempotencyKey)
+		if idempotencyKey == "" {
+			f(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKey {
+			Error(w, ErrInvalidKey)
+			return
+		}
+		identity := auth.Identify(r, roles.Identify)
+		if identity.IsUnknown() {
+			f(w, r)
+			return
+		}
+
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if len(body) > maxIdempotentBody {
+			r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			f(w, r)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		io.WriteString(h, r.Method+" "+r.URL.Path+"\n"+r.Header.Get(kes.HeaderEnclave)+"\n")
+		h.Write(body)
+		var request [sha256.Size]byte
+		copy(request[:], h.Sum(nil))
+
+		key := identity.String() + "\n" + idempotencyKey
+		entry, retry, err := cache.begin(key, request)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if entry == nil {
+			f(w, r)
+			return
+		}
+		if retry {
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				Error(w, r.Context().Err())
+				return
+			}
+			if entry.ok {
+				if aw, ok := auditWriter(w); ok {
+					aw.Replay = true
+					aw.ErrorCode = entry.errorCode
+				}
+				for k, v := range entry.header {
+					w.Header()[k] = v
+				}
+				w.Header().Set("Kes-Idempotent-Replay", "true")
+				w.WriteHeader(entry.status)
+				w.Write(entry.body)
+				return
+			}
+
+			// The first request failed due to a temporary
+			// error. So, we process the retry without
+			// remembering its response.
+			f(w, r)
+			return
+		}
+
+		iw := &idempotentWriter{ResponseWriter: w}
+		defer cache.complete(key, entry, iw)
+		f(iw, r)
+		iw.finished = true
+	}
+}
+
+// idempotentWriter is an http.ResponseWriter that
+// records the status code, headers and body of the
+// response while sending it to the client.
+type idempotentWriter struct {
+	http.ResponseWriter
+
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool // True if the body exceeds maxIdempotentBody
+	finished bool // True if the handler returned without panicking
+}
+
+func (w *idempotentWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if w.body.Len()+len(b) > maxIdempotentBody {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
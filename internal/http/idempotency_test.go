@@ -0,0 +1,132 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestIdempotent(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store   = &secret.Store{Remote: &mem.Store{}}
+		roles   = &auth.Roles{}
+		cache   = &IdempotencyCache{}
+		handler = Idempotent(cache, roles, HandleCreateKey(store, roles))
+	)
+	send := func(path, idempotencyKey string) *dummyResponseWriter {
+		req, err := http.NewRequest(http.MethodPost, baseURL+path, http.NoBody)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+		req.SetBasicAuth("alice", "")
+		if idempotencyKey != "" {
+			req.Header.Set(kes.HeaderIdempotencyKey, idempotencyKey)
+		}
+
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return &resp
+	}
+
+	if resp := send("/v1/key/create/my-key", "a1"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	resp := send("/v1/key/create/my-key", "a1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Retry has not been deduplicated: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.Header().Get("Kes-Idempotent-Replay") != "true" {
+		t.Fatal("Response of the retry has not been replayed")
+	}
+	if resp = send("/v1/key/create/my-key", "b2"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Request with another idempotency key should fail: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = send("/v1/key/create/my-key", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Request without idempotency key should fail: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = send("/v1/key/create/other-key", "a1"); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Reused idempotency key should be rejected: got %d - want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotentTemporaryError(t *testing.T) {
+	var calls int
+	handler := Idempotent(&IdempotencyCache{}, &auth.Roles{}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	for i, status := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		req, err := http.NewRequest(http.MethodDelete, "https://localhost:7373/v1/key/delete/my-key", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+		req.SetBasicAuth("alice", "")
+		req.Header.Set(kes.HeaderIdempotencyKey, "a1")
+
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		if resp.StatusCode != status {
+			t.Fatalf("Request %d: invalid status code: got %d - want %d", i, resp.StatusCode, status)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Invalid number of handler calls: got %d - want %d", calls, 2)
+	}
+}
+
+func TestIdempotentAuditReplay(t *testing.T) {
+	var (
+		store   = &secret.Store{Remote: &mem.Store{}}
+		roles   = &auth.Roles{}
+		events  bytes.Buffer
+		handler = AuditLog(log.New(&events, "", 0), roles, Idempotent(&IdempotencyCache{}, roles, HandleCreateKey(store, roles)))
+	)
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodPost, "https://localhost:7373/v1/key/create/my-key", http.NoBody)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+		req.SetBasicAuth("alice", "")
+		req.Header.Set(kes.HeaderIdempotencyKey, "a1")
+
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Request %d: invalid status code: got %d - want %d", i, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	decoder := json.NewDecoder(&events)
+	for i, replay := range []bool{false, true, true} {
+		var event kes.AuditEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("Event %d: failed to decode audit event: %v", i, err)
+		}
+		if event.Response.Replay != replay {
+			t.Fatalf("Event %d: invalid replay flag: got %v - want %v", i, event.Response.Replay, replay)
+		}
+	}
+	if decoder.More() {
+		t.Fatal("A request produced more than one audit event")
+	}
+}
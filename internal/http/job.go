@@ -0,0 +1,84 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/job"
+)
+
+// HandleSubmitJob returns a handler function that submits
+// a new job of the type specified by the request URL path
+// base. The request body contains the job parameters as
+// JSON object, if any.
+//
+// It responds with the submitted job. The job runs in
+// the background.
+func HandleSubmitJob(jobs *job.Manager, roles *auth.Roles) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if len(params) == 0 {
+			params = []byte("{}")
+		}
+		if !json.Valid(params) {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		j, err := jobs.Submit(pathBase(r.URL.Path), params, auth.Identify(r, roles.Identify))
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(j)
+	}
+}
+
+// HandleJobStatus returns a handler function that responds
+// with the job specified by the request URL path base.
+func HandleJobStatus(jobs *job.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := jobs.Get(pathBase(r.URL.Path))
+		if !ok {
+			Error(w, job.ErrNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(j)
+	}
+}
+
+// HandleListJobs returns a handler function that responds
+// with all jobs.
+func HandleListJobs(jobs *job.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs.List())
+	}
+}
+
+// HandleCancelJob returns a handler function that cancels
+// the job specified by the request URL path base.
+func HandleCancelJob(jobs *job.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := jobs.Cancel(pathBase(r.URL.Path)); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
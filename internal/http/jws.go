@@ -0,0 +1,162 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleCreateSigningKey returns an http.HandlerFunc that
+// creates a new asymmetric signing key with the name referenced
+// by the request URL - e.g. /v1/jws/create/my-app/my-key. The
+// request body may specify the signing algorithm:
+//  {
+//    "algorithm": "ES256" | "EdDSA" | "RS256"
+//  }
+//
+// By default, it creates an ES256 signing key.
+func HandleCreateSigningKey(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		Algorithm string `json:"algorithm"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+
+		var req Request
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				Error(w, ErrInvalidJSON)
+				return
+			}
+		}
+		if err := reserveKey(r, roles, name); err != nil {
+			Error(w, err)
+			return
+		}
+		event := provenanceEvent(r, roles, secret.ProvenanceCreated)
+		if err := store.CreateSigningKey(r.Context(), name, req.Algorithm); err != nil {
+			releaseKey(roles, name)
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, event); err != nil {
+			Error(w, err)
+			return
+		}
+		if roles.Quotas != nil {
+			if err := roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleSignJWS returns an http.HandlerFunc that signs the
+// payload sent as request body with the signing key referenced
+// by the request URL - e.g. /v1/jws/sign/my-app/my-key:
+//  {
+//    "payload": "<base64-encoded-payload>",
+//    "typ":     "JWT",
+//    "cty":     "<content-type>"
+//  }
+//
+// The typ and cty header parameters are optional. It responds
+// with the JWS in compact serialization:
+//  {
+//    "jws": "<header>.<payload>.<signature>"
+//  }
+func HandleSignJWS(store *secret.Store) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Request struct {
+		Payload     []byte `json:"payload"`
+		Type        string `json:"typ"`
+		ContentType string `json:"cty"`
+	}
+	type Response struct {
+		JWS string `json:"jws"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		jws, err := store.SignJWS(r.Context(), name, req.Payload, req.Type, req.ContentType)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		store.RecordUse(name, "sign")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			JWS: jws,
+		})
+	}
+}
+
+// HandleGetJWKS returns an http.HandlerFunc that returns
+// the JWK set (JWKS) that contains the public key of the
+// signing key referenced by the request URL - e.g.
+// /v1/jws/jwks/my-app/my-key:
+//  {
+//    "keys": [
+//      {
+//        "kty": "EC",
+//        "kid": "<key-id>",
+//        ...
+//      }
+//    ]
+//  }
+//
+// Clients use the JWKS to verify a JWS signed by the
+// signing key. It never returns the private key.
+func HandleGetJWKS(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	type Response struct {
+		Keys []secret.JWK `json:"keys"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		jwk, err := store.JWK(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Keys: []secret.JWK{jwk},
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestHandleJWS(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	if resp := send(HandleCreateSigningKey(store, roles), http.MethodPost, "/v1/jws/create/my-key", `{"algorithm":"EdDSA"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create signing key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleCreateSigningKey(store, roles), http.MethodPost, "/v1/jws/create/my-hmac", `{"algorithm":"HS256"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Created signing key with unsupported algorithm: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp := send(HandleSignJWS(store), http.MethodPost, "/v1/jws/sign/my-key", `{"payload":"eyJzdWIiOiJtZSJ9","typ":"JWT"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to sign payload: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var response struct {
+		JWS string `json:"jws"`
+	}
+	if err := json.NewDecoder(&resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode JWS: %v", err)
+	}
+	if parts := strings.Split(response.JWS, "."); len(parts) != 3 || parts[1] != "eyJzdWIiOiJtZSJ9" {
+		t.Fatalf("Invalid JWS: %s", response.JWS)
+	}
+
+	resp = send(HandleGetJWKS(store), http.MethodGet, "/v1/jws/jwks/my-key", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to get JWKS: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	var jwks struct {
+		Keys []secret.JWK `json:"keys"`
+	}
+	if err := json.NewDecoder(&resp.Body).Decode(&jwks); err != nil {
+		t.Fatalf("Failed to decode JWKS: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].Algorithm != secret.AlgEdDSA {
+		t.Fatalf("Invalid JWKS: %+v", jwks)
+	}
+	if resp = send(HandleGetSecret(store), http.MethodGet, "/v1/secret/get/my-key", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Returned signing key as opaque secret: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
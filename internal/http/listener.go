@@ -0,0 +1,34 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/minio/kes"
+)
+
+// RestrictAPIs returns an http.Handler that serves only requests
+// to APIs allowed by the policy. It rejects requests to any other
+// API with kes.ErrNotAllowed before calling h. If policy is nil,
+// RestrictAPIs returns h.
+//
+// RestrictAPIs limits which APIs are reachable via a particular
+// listener - e.g. it may expose only the data plane APIs on a
+// public address while the admin APIs are served on localhost.
+// The policy is applied in addition to the policy of the client
+// identity.
+func RestrictAPIs(policy *kes.Policy, h http.Handler) http.Handler {
+	if policy == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !policy.AllowsPath(r.URL.Path) {
+			Error(w, kes.ErrNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
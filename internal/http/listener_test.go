@@ -0,0 +1,44 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestRestrictAPIs(t *testing.T) {
+	policy, err := kes.NewPolicy("/v1/key/*/*", "/v1/status")
+	if err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	if err = policy.Deny("/v1/key/delete/*"); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	h := RestrictAPIs(policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	for i, test := range []struct {
+		Path   string
+		Status int
+	}{
+		{Path: "/v1/key/create/my-key", Status: http.StatusOK},             // 0
+		{Path: "/v1/status", Status: http.StatusOK},                        // 1
+		{Path: "/v1/key/delete/my-key", Status: http.StatusForbidden},      // 2
+		{Path: "/v1/policy/write/my-policy", Status: http.StatusForbidden}, // 3
+		{Path: "/v1/admin/backup", Status: http.StatusForbidden},           // 4
+	} {
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:7373"+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		var resp dummyResponseWriter
+		h.ServeHTTP(&resp, req)
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: got status %d - want %d", i, resp.StatusCode, test.Status)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// requestOverhead is the approximate number of bytes
+// used by an in-flight request - besides its body. It
+// includes the go-routine stack and the buffers of the
+// request and response.
+const requestOverhead = 16 << 10
+
+// MemoryLimiter rejects requests once the approximate memory
+// used by the server exceeds a limit - instead of running out
+// of memory and getting killed by the OS.
+//
+// The memory used by the server is approximated by the memory
+// used by in-flight requests and the memory reported by Usage -
+// e.g. by caches. Once the limit is exceeded, the MemoryLimiter
+// tries to free memory via Evict before rejecting requests.
+type MemoryLimiter struct {
+	// The counters must be 64 bit aligned for atomic
+	// operations. Therefore, they are the first fields.
+	inFlight  int64  // Bytes used by in-flight requests
+	shed      uint64 // Number of rejected requests
+	lastEvict int64  // Unix time (ns) of the last Evict call
+
+	// Limit is the max. number of bytes the server
+	// should use. If Limit <= 0, no requests are
+	// rejected.
+	Limit int64
+
+	// Usage returns the approximate number of bytes
+	// used by the server besides in-flight requests -
+	// e.g. by caches. If nil, only in-flight requests
+	// are considered.
+	Usage func() int64
+
+	// Evict tries to free memory - e.g. by clearing
+	// caches. It is called at most once per second
+	// while the limit is exceeded. If nil, requests
+	// are rejected right away.
+	Evict func()
+}
+
+// InUse returns the approximate number of bytes used
+// by in-flight requests and reported by Usage.
+func (l *MemoryLimiter) InUse() int64 {
+	inUse := atomic.LoadInt64(&l.inFlight)
+	if l.Usage != nil {
+		inUse += l.Usage()
+	}
+	return inUse
+}
+
+// Shed returns the number of requests that have
+// been rejected since the limit was exceeded.
+func (l *MemoryLimiter) Shed() uint64 { return atomic.LoadUint64(&l.shed) }
+
+// acquire reserves n bytes for an in-flight request and
+// reports whether the request should be served. The n
+// bytes must be released once the request has been served
+// - even if the request is rejected.
+func (l *MemoryLimiter) acquire(n int64) bool {
+	atomic.AddInt64(&l.inFlight, n)
+	if l.Limit <= 0 || l.InUse() <= l.Limit {
+		return true
+	}
+
+	if l.Evict != nil {
+		now := time.Now().UnixNano()
+		last := atomic.LoadInt64(&l.lastEvict)
+		if now-last >= int64(time.Second) && atomic.CompareAndSwapInt64(&l.lastEvict, last, now) {
+			l.Evict()
+			if l.InUse() <= l.Limit {
+				return true
+			}
+		}
+	}
+	atomic.AddUint64(&l.shed, 1)
+	return false
+}
+
+func (l *MemoryLimiter) release(n int64) { atomic.AddInt64(&l.inFlight, -n) }
+
+// LimitMemory returns a Router that dispatches requests via
+// the router unless the memory limit of the limiter is
+// exceeded. Then, it rejects requests with kes.ErrOverloaded.
+// If limiter is nil, LimitMemory returns the router.
+//
+// The probes and metrics are never rejected such that the
+// server can be observed while it is overloaded.
+func LimitMemory(limiter *MemoryLimiter, router Router) Router {
+	if limiter == nil {
+		return router
+	}
+	return memoryRouter{Router: router, limiter: limiter}
+}
+
+type memoryRouter struct {
+	Router
+
+	limiter *MemoryLimiter
+}
+
+func (m memoryRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/ready", "/v1/live", "/v1/metrics":
+		m.Router.ServeHTTP(w, r)
+		return
+	}
+
+	n := int64(requestOverhead)
+	if r.ContentLength > 0 {
+		n += r.ContentLength
+	}
+	defer m.limiter.release(n)
+
+	if !m.limiter.acquire(n) {
+		w.Header().Set("Retry-After", "1")
+		Error(w, kes.ErrOverloaded)
+		return
+	}
+	m.Router.ServeHTTP(w, r)
+}
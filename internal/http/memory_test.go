@@ -0,0 +1,60 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLimitMemory(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		cacheSize int64 = 4 * requestOverhead
+		evictions int
+	)
+	limiter := &MemoryLimiter{
+		Limit: 4 * requestOverhead,
+		Usage: func() int64 { return cacheSize },
+		Evict: func() { evictions++; cacheSize /= 2 },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	router := LimitMemory(limiter, mux)
+
+	serve := func(path string) int {
+		req, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		router.ServeHTTP(&resp, req)
+		return resp.StatusCode
+	}
+
+	// The first request exceeds the limit. The limiter
+	// evicts half of the cache and serves the request.
+	if status := serve("/v1/key/list/*"); status != http.StatusOK {
+		t.Fatalf("Invalid status code: got %d - want %d", status, http.StatusOK)
+	}
+	if evictions != 1 {
+		t.Fatalf("Invalid number of evictions: got %d - want %d", evictions, 1)
+	}
+
+	// The limiter evicts at most once per second.
+	cacheSize = 4 * requestOverhead
+	if status := serve("/v1/key/list/*"); status != http.StatusServiceUnavailable {
+		t.Fatalf("Invalid status code: got %d - want %d", status, http.StatusServiceUnavailable)
+	}
+	if shed := limiter.Shed(); shed != 1 {
+		t.Fatalf("Invalid number of rejected requests: got %d - want %d", shed, 1)
+	}
+	if status := serve("/v1/metrics"); status != http.StatusOK {
+		t.Fatalf("Metrics request has been rejected: got %d - want %d", status, http.StatusOK)
+	}
+	if inUse := limiter.InUse(); inUse != cacheSize {
+		t.Fatalf("Memory of in-flight requests has not been released: got %d - want %d", inUse, cacheSize)
+	}
+}
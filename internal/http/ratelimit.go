@@ -0,0 +1,143 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/rate"
+	"github.com/minio/kes/internal/secret"
+)
+
+// RateLimit is a token bucket limit of Rate requests
+// per second with bursts of at most Burst requests.
+//
+// A RateLimit with a Rate <= 0 does not limit any
+// requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter limits the requests of each identity.
+//
+// Each identity has its own token bucket for all its
+// requests and one token bucket for each API with an
+// API limit. Therefore, one identity cannot exhaust
+// the limits of other identities.
+//
+// Its zero value is a usable RateLimiter that does
+// not limit any requests.
+type RateLimiter struct {
+	lock       sync.Mutex
+	limit      RateLimit
+	identities map[kes.Identity]RateLimit
+	apis       map[string]RateLimit
+	buckets    map[rateBucket]*rate.Limiter
+}
+
+type rateBucket struct {
+	Identity kes.Identity
+	API      string // Empty for the bucket of all requests of an identity
+}
+
+// SetLimits replaces the current limits of the RateLimiter.
+//
+// The limit applies to all requests of any identity unless
+// there is an identity-specific limit. Each API limit applies
+// to all requests of an identity whose URL path starts with
+// the API path - e.g. "/v1/key/generate".
+//
+// SetLimits resets all token buckets.
+func (l *RateLimiter) SetLimits(limit RateLimit, identities map[kes.Identity]RateLimit, apis map[string]RateLimit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.limit = limit
+	l.identities = identities
+	l.apis = apis
+	l.buckets = nil
+}
+
+// Allow reports whether the identity may send a request
+// to the given URL path now. If not, Allow returns the
+// duration until the identity may send the request.
+func (l *RateLimiter) Allow(identity kes.Identity, urlPath string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limit, ok := l.identities[identity]
+	if !ok {
+		limit = l.limit
+	}
+	bucket := l.bucket(rateBucket{Identity: identity}, limit)
+	if !bucket.Allow() {
+		return false, bucket.Delay()
+	}
+	for api, limit := range l.apis {
+		if !strings.HasPrefix(urlPath, api) {
+			continue
+		}
+		bucket = l.bucket(rateBucket{Identity: identity, API: api}, limit)
+		if !bucket.Allow() {
+			return false, bucket.Delay()
+		}
+	}
+	return true, 0
+}
+
+// bucket returns the token bucket with the given
+// key. It creates a new bucket if none exists.
+//
+// The caller must hold the lock.
+func (l *RateLimiter) bucket(key rateBucket, limit RateLimit) *rate.Limiter {
+	if l.buckets == nil {
+		l.buckets = map[rateBucket]*rate.Limiter{}
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rate.Limiter{
+			Rate:  limit.Rate,
+			Burst: limit.Burst,
+		}
+		l.buckets[key] = bucket
+	}
+	return bucket
+}
+
+// LimitRate returns an http.HandlerFunc that checks whether
+// the request identity has exceeded its rate limit before
+// calling f. If so, it returns 429 (too many requests) to
+// the client and tells the client when it may retry via the
+// Retry-After header.
+//
+// The root identity is not limited. LimitRate should be
+// called after the request has been verified - e.g. by
+// EnforcePolicies. Otherwise, clients could create an
+// arbitrary number of token buckets.
+func LimitRate(limiter *RateLimiter, roles *auth.Roles, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := auth.Identify(r, roles.Identify)
+		if !secret.EqualIdentity(identity, roles.Root) {
+			if ok, delay := limiter.Allow(identity, r.URL.Path); !ok {
+				seconds := int(math.Ceil(delay.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				Error(w, kes.ErrTooManyRequests)
+				return
+			}
+		}
+		f(w, r)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var limiter RateLimiter
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("af43c", "/v1/key/create/my-key"); !ok {
+			t.Fatalf("Request %d: zero RateLimiter should not limit any request", i)
+		}
+	}
+
+	limiter.SetLimits(
+		RateLimit{Rate: 0.001, Burst: 2},
+		map[kes.Identity]RateLimit{"b2ce1": {Rate: 0.001, Burst: 4}},
+		map[string]RateLimit{"/v1/key/generate": {Rate: 0.001, Burst: 1}},
+	)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("af43c", "/v1/key/create/my-key"); !ok {
+			t.Fatalf("Request %d: should be allowed", i)
+		}
+	}
+	ok, delay := limiter.Allow("af43c", "/v1/key/create/my-key")
+	if ok {
+		t.Fatal("Request should have been limited")
+	}
+	if delay <= 0 {
+		t.Fatalf("Invalid delay: got %v - want > 0", delay)
+	}
+	if ok, _ = limiter.Allow("c4d3e", "/v1/key/create/my-key"); !ok {
+		t.Fatal("Request of another identity should not be limited")
+	}
+
+	for i := 0; i < 4; i++ {
+		if ok, _ = limiter.Allow("b2ce1", "/v1/key/create/my-key"); !ok {
+			t.Fatalf("Request %d: identity-specific limit is not applied", i)
+		}
+	}
+
+	if ok, _ = limiter.Allow("d7e8f", "/v1/key/generate/my-key"); !ok {
+		t.Fatal("First API request should be allowed")
+	}
+	if ok, _ = limiter.Allow("d7e8f", "/v1/key/generate/my-key"); ok {
+		t.Fatal("Second API request should have been limited")
+	}
+	if ok, _ = limiter.Allow("d7e8f", "/v1/key/decrypt/my-key"); ok {
+		t.Fatal("Request should have been limited by the identity limit")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/seal"
+)
+
+// RequireUnsealed returns a Router that rejects all
+// requests with seal.ErrSealed while the barrier is
+// sealed. Only the seal APIs and the APIs that report
+// the server version, health and status are served
+// while the barrier is sealed.
+//
+// If barrier is nil, RequireUnsealed returns the router.
+func RequireUnsealed(barrier *seal.Barrier, router Router) Router {
+	if barrier == nil {
+		return router
+	}
+	return sealRouter{Router: router, barrier: barrier}
+}
+
+type sealRouter struct {
+	Router
+
+	barrier *seal.Barrier
+}
+
+func (s sealRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.barrier.State().Sealed && !servedWhileSealed(r) {
+		Error(w, seal.ErrSealed)
+		return
+	}
+	s.Router.ServeHTTP(w, r)
+}
+
+// servedWhileSealed reports whether the request
+// is served while the server is sealed.
+func servedWhileSealed(r *http.Request) bool {
+	if r.Header.Get(kes.HeaderEnclave) != "" {
+		return false
+	}
+	switch r.URL.Path {
+	case "/version", "/v1/ready", "/v1/live", "/v1/status", "/v1/metrics":
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/v1/seal/")
+}
+
+// HandleSealStatus returns a handler function that
+// responds with the state of the barrier.
+func HandleSealStatus(barrier *seal.Barrier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(barrier.State())
+	}
+}
+
+// HandleInitSeal returns a handler function that initializes
+// the barrier and responds with the key shares of the root key.
+// The request body contains the number of key shares and the
+// threshold required to unseal the barrier:
+//  {
+//    "shares":    <n>,
+//    "threshold": <m>
+//  }
+func HandleInitSeal(barrier *seal.Barrier) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	type Request struct {
+		Shares    int `json:"shares"`
+		Threshold int `json:"threshold"`
+	}
+	type Response struct {
+		Shares [][]byte `json:"shares"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		shares, err := barrier.Init(req.Shares, req.Threshold)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{Shares: shares})
+	}
+}
+
+// HandleUnseal returns a handler function that submits a
+// key share to the barrier and responds with the state of
+// the barrier. The request body contains the key share:
+//  {
+//    "share": "<base64>"
+//  }
+func HandleUnseal(barrier *seal.Barrier) http.HandlerFunc {
+	var ErrInvalidJSON = kes.NewError(http.StatusBadRequest, "invalid json")
+	type Request struct {
+		Share []byte `json:"share"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		state, err := barrier.Unseal(req.Share)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+// HandleCreateSecret returns an http.HandlerFunc that stores
+// the opaque secret - e.g. an API token or a certificate -
+// sent as request body under the name referenced by the
+// request URL - e.g. /v1/secret/create/my-app/my-token:
+//  {
+//    "bytes": "<base64-encoded-secret>"
+//  }
+//
+// The secret must not be larger than maxSize bytes. An
+// opaque secret cannot be used as cryptographic key.
+func HandleCreateSecret(store *secret.Store, roles *auth.Roles, maxSize int) http.HandlerFunc {
+	var (
+		ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+		ErrInvalidJSON    = kes.NewError(http.StatusBadRequest, "invalid json")
+		ErrInvalidSecret  = kes.NewError(http.StatusBadRequest, fmt.Sprintf("invalid secret: size must be between 1 and %d bytes", maxSize))
+	)
+	type Request struct {
+		Bytes []byte `json:"bytes"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := keyName(r)
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+		defer secret.Wipe(req.Bytes)
+
+		if len(req.Bytes) == 0 || len(req.Bytes) > maxSize {
+			Error(w, ErrInvalidSecret)
+			return
+		}
+		if err := reserveKey(r, roles, name); err != nil {
+			Error(w, err)
+			return
+		}
+		event := provenanceEvent(r, roles, secret.ProvenanceImported)
+		if err := store.CreateOpaque(r.Context(), name, req.Bytes); err != nil {
+			releaseKey(roles, name)
+			Error(w, err)
+			return
+		}
+		if err := store.AppendProvenance(name, event); err != nil {
+			Error(w, err)
+			return
+		}
+		if roles.Quotas != nil {
+			if err := roles.Quotas.Save(); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleGetSecret returns an http.HandlerFunc that returns
+// the opaque secret referenced by the request URL - e.g.
+// /v1/secret/get/my-app/my-token:
+//  {
+//    "bytes": "<base64-encoded-secret>"
+//  }
+//
+// It never returns a cryptographic key.
+func HandleGetSecret(store *secret.Store) http.HandlerFunc {
+	var ErrInvalidKeyName = kes.NewError(http.StatusBadRequest, "invalid key name")
+	type Response struct {
+		Bytes []byte `json:"bytes"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := store.Resolve(keyName(r))
+		if name == "" {
+			Error(w, ErrInvalidKeyName)
+			return
+		}
+		value, err := store.GetOpaque(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		defer secret.Wipe(value)
+		store.RecordUse(name, "get")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Bytes: value,
+		})
+	}
+}
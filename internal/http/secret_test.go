@@ -0,0 +1,57 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestHandleSecret(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	var (
+		store = &secret.Store{Remote: &mem.Store{}}
+		roles = &auth.Roles{}
+	)
+	send := func(handler http.HandlerFunc, method, path, body string) dummyResponseWriter {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		var resp dummyResponseWriter
+		handler(&resp, req)
+		return resp
+	}
+
+	const maxSize = 16
+	if resp := send(HandleCreateSecret(store, roles, maxSize), http.MethodPost, "/v1/secret/create/my-token", `{"bytes":"czNjcjN0"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create secret: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if resp := send(HandleCreateSecret(store, roles, maxSize), http.MethodPost, "/v1/secret/create/large", `{"bytes":"AAAAAAAAAAAAAAAAAAAAAAAAAAAA"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Created too large secret: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp := send(HandleCreateKey(store, roles), http.MethodPost, "/v1/key/create/my-key", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create key: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+
+	resp := send(HandleGetSecret(store), http.MethodGet, "/v1/secret/get/my-token", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to get secret: got %d - want %d: %s", resp.StatusCode, http.StatusOK, resp.Body.String())
+	}
+	if got, want := strings.TrimSpace(resp.Body.String()), `{"bytes":"czNjcjN0"}`; got != want {
+		t.Fatalf("Invalid secret: got %s - want %s", got, want)
+	}
+	if resp = send(HandleGetSecret(store), http.MethodGet, "/v1/secret/get/my-key", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Returned key as opaque secret: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp = send(HandleGenerateKey(store), http.MethodPost, "/v1/key/generate/my-token", `{}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Generated data key with opaque secret: got %d - want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+// HandleIssueSession returns a handler function that issues a
+// short-lived session token of the request identity. The request
+// body is the policy of the session. The lifetime of the session
+// is specified by the URL query parameter:
+//  ?ttl=<duration>
+// For example: ?ttl=30m
+//
+// It responds with the session token, the identity of the
+// session and when it expires:
+//  {
+//    "token":    "<token>",
+//    "identity": "session:<identity>:<session-id>",
+//    "expiry":   "<time>"
+//  }
+// A request of the session is allowed if the session policy
+// and the request identity allow it. See: auth.Sessions
+//
+// Neither the root identity nor identities authenticated by
+// LDAP, OIDC or a session token can issue sessions.
+func HandleIssueSession(roles *auth.Roles) http.HandlerFunc {
+	var (
+		ErrSessionDisabled = kes.NewError(http.StatusNotImplemented, "sessions are not enabled")
+		ErrIdentityRoot    = kes.NewError(http.StatusForbidden, "root identity cannot issue sessions")
+		ErrIdentityInvalid = kes.NewError(http.StatusForbidden, "identity cannot issue sessions")
+		ErrInvalidTTL      = kes.NewError(http.StatusBadRequest, "invalid ttl")
+		ErrInvalidJSON     = kes.NewError(http.StatusBadRequest, "invalid json")
+	)
+	type Response struct {
+		Token    string       `json:"token"`
+		Identity kes.Identity `json:"identity"`
+		Expiry   time.Time    `json:"expiry"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if roles.Sessions == nil {
+			Error(w, ErrSessionDisabled)
+			return
+		}
+		identity := auth.Identify(r, roles.Identify)
+		if roles.IsRoot(identity) {
+			Error(w, ErrIdentityRoot)
+			return
+		}
+		for _, prefix := range []string{"ldap:", "oidc:", "session:"} {
+			if identity.IsUnknown() || strings.HasPrefix(identity.String(), prefix) {
+				Error(w, ErrIdentityInvalid)
+				return
+			}
+		}
+
+		var ttl time.Duration
+		if value := r.URL.Query().Get("ttl"); value != "" {
+			var err error
+			if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+				Error(w, ErrInvalidTTL)
+				return
+			}
+		}
+		var policy kes.Policy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			Error(w, ErrInvalidJSON)
+			return
+		}
+
+		token, session, err := roles.Sessions.Issue(identity, &policy, ttl)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Token:    token,
+			Identity: session.Identity(),
+			Expiry:   session.Expiry,
+		})
+	}
+}
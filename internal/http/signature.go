@@ -0,0 +1,89 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"crypto"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+// VerifySignature returns a handler function that verifies
+// the request signature before calling f. It rejects requests
+// that are not signed by the client or have been replayed.
+//
+// If verifier is nil, VerifySignature returns f unmodified
+// and requests do not have to be signed.
+//
+// VerifySignature has to be called after TLSProxy such that
+// the signature gets verified using the certificate of the
+// actual client.
+func VerifySignature(verifier *auth.SignatureVerifier, f http.HandlerFunc) http.HandlerFunc {
+	if verifier == nil {
+		return f
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := verifier.Verify(r); err != nil {
+			Error(w, err)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// SignResponse returns a handler function that signs the
+// response of f with the signer. Clients can verify that the
+// response - e.g. a data key - has been sent by the KES server
+// even if it passed through intermediaries like proxies or
+// service meshes. See: kes.SignResponse
+//
+// If signer is nil, SignResponse returns f unmodified and
+// responses are not signed.
+//
+// SignResponse buffers the entire response. Hence, it should
+// only wrap handlers that send small responses.
+func SignResponse(signer crypto.Signer, f http.HandlerFunc) http.HandlerFunc {
+	if signer == nil {
+		return f
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &signatureWriter{ResponseWriter: w}
+		f(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		if err := kes.SignResponse(w.Header(), r, sw.status, sw.body.Bytes(), signer); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(sw.status)
+		w.Write(sw.body.Bytes())
+	}
+}
+
+// signatureWriter is an http.ResponseWriter that
+// buffers the status code and the response body.
+type signatureWriter struct {
+	http.ResponseWriter
+
+	body   bytes.Buffer
+	status int
+}
+
+func (w *signatureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *signatureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
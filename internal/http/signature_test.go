@@ -0,0 +1,44 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestSignResponse(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/generate/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set(kes.HeaderSignatureNonce, "0123456789abcdef")
+
+	for i, f := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"plaintext":"..."}`)) },
+		func(w http.ResponseWriter, r *http.Request) { Error(w, kes.ErrKeyNotFound) },
+	} {
+		var resp dummyResponseWriter
+		SignResponse(private, f)(&resp, req)
+		if resp.Header().Get(kes.HeaderResponseSignature) == "" {
+			t.Fatalf("Test %d: response is not signed", i)
+		}
+
+		response := &http.Response{StatusCode: resp.StatusCode, Header: resp.Header()}
+		if err = kes.VerifyResponse(req, response, resp.Body.Bytes(), public); err != nil {
+			t.Fatalf("Test %d: failed to verify response: %v", i, err)
+		}
+	}
+}
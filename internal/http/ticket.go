@@ -0,0 +1,129 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"log"
+	"time"
+)
+
+// SessionTicketKeys rotates the keys that a TLS server uses
+// to encrypt and decrypt TLS session tickets. Clients can
+// resume a TLS session using a session ticket instead of
+// performing a full handshake - including the verification
+// of the client certificate.
+//
+// New tickets are encrypted with the current key. Tickets
+// encrypted with the previous key can still be used to
+// resume a session. Hence, a ticket is valid for at most
+// two rotation intervals.
+type SessionTicketKeys struct {
+	// Secret is an optional secret from which the keys
+	// are derived. Servers with the same secret use the
+	// same keys at the same time. Therefore, a client
+	// can resume a session established with one server
+	// with any other server.
+	//
+	// If empty, the keys are generated randomly.
+	Secret []byte
+
+	// Rotation is the interval at which the keys are
+	// rotated. If <= 0, it defaults to one hour.
+	Rotation time.Duration
+
+	// ErrorLog specifies an optional logger for errors
+	// when new random keys cannot be generated. If nil,
+	// logging is done via the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+}
+
+// Rotate sets the session ticket keys of the tls.Config
+// and replaces them every rotation interval. It stops
+// once the ctx.Done() channel returns.
+//
+// Rotate does not block but starts a new go-routine.
+func (k *SessionTicketKeys) Rotate(ctx context.Context, config *tls.Config) error {
+	var (
+		rotation = k.rotation()
+		epoch    = uint64(time.Now().UnixNano() / int64(rotation))
+	)
+	current, err := k.key(epoch)
+	if err != nil {
+		return err
+	}
+	previous, err := k.key(epoch - 1)
+	if err != nil {
+		return err
+	}
+	config.SetSessionTicketKeys([][32]byte{current, previous})
+
+	go func() {
+		for {
+			// Rotate the keys at the beginning of the next epoch
+			// such that servers with the same secret rotate
+			// their keys at the same time.
+			next := time.Unix(0, int64(epoch+1)*int64(rotation))
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			epoch++
+			key, err := k.key(epoch)
+			if err != nil {
+				k.logf("http: failed to rotate TLS session ticket keys: %v", err)
+				continue
+			}
+			current, previous = key, current
+			config.SetSessionTicketKeys([][32]byte{current, previous})
+		}
+	}()
+	return nil
+}
+
+func (k *SessionTicketKeys) rotation() time.Duration {
+	if k.Rotation <= 0 {
+		return time.Hour
+	}
+	return k.Rotation
+}
+
+// key returns the ticket key of the given epoch. If there
+// is no secret, it returns a random key.
+func (k *SessionTicketKeys) key(epoch uint64) ([32]byte, error) {
+	var key [32]byte
+	if len(k.Secret) == 0 {
+		_, err := io.ReadFull(rand.Reader, key[:])
+		return key, err
+	}
+
+	var ctx [8]byte
+	binary.BigEndian.PutUint64(ctx[:], epoch)
+
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write([]byte("kes tls session ticket key"))
+	mac.Write(ctx[:])
+	mac.Sum(key[:0])
+	return key, nil
+}
+
+func (k *SessionTicketKeys) logf(format string, v ...interface{}) {
+	if k.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		k.ErrorLog.Printf(format, v...)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var sessionTicketKeysTests = []struct {
+	SecretA, SecretB []byte
+	Resume           bool
+}{
+	{SecretA: []byte("my-secret"), SecretB: []byte("my-secret"), Resume: true},     // 0
+	{SecretA: []byte("my-secret"), SecretB: []byte("other-secret"), Resume: false}, // 1
+	{SecretA: nil, SecretB: nil, Resume: false},                                    // 2
+}
+
+func TestSessionTicketKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-ticket-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := filepath.Join(dir, "server.cert"), filepath.Join(dir, "server.key")
+	writeCertificate(t, certPath, keyPath, "localhost")
+	certificate, err := LoadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i, test := range sessionTicketKeysTests {
+		serverA := &tls.Config{MinVersion: tls.VersionTLS13, GetCertificate: certificate.GetCertificate}
+		serverB := serverA.Clone()
+		if err = (&SessionTicketKeys{Secret: test.SecretA, Rotation: time.Minute}).Rotate(ctx, serverA); err != nil {
+			t.Fatalf("Test %d: failed to set session ticket keys: %v", i, err)
+		}
+		if err = (&SessionTicketKeys{Secret: test.SecretB, Rotation: time.Minute}).Rotate(ctx, serverB); err != nil {
+			t.Fatalf("Test %d: failed to set session ticket keys: %v", i, err)
+		}
+
+		client := &tls.Config{
+			InsecureSkipVerify: true,
+			ClientSessionCache: tls.NewLRUClientSessionCache(1),
+		}
+		if resumed := handshake(t, client, serverA); resumed {
+			t.Fatalf("Test %d: initial handshake resumed a session", i)
+		}
+		if resumed := handshake(t, client, serverB); resumed != test.Resume {
+			t.Fatalf("Test %d: session resumption: got %v - want %v", i, resumed, test.Resume)
+		}
+	}
+}
+
+// handshake performs a TLS handshake between the client
+// and server and reports whether the client has resumed
+// a previous session.
+func handshake(t *testing.T, clientConfig, serverConfig *tls.Config) bool {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		server := tls.Server(serverConn, serverConfig)
+		defer server.Close()
+
+		if err := server.Handshake(); err != nil {
+			return
+		}
+		server.Write([]byte{0}) // Send the session ticket and a single byte
+	}()
+
+	client := tls.Client(clientConn, clientConfig)
+	var b [1]byte
+	if _, err := client.Read(b[:]); err != nil {
+		t.Fatalf("Failed to read from TLS connection: %v", err)
+	}
+	return client.ConnectionState().DidResume
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/watch"
+)
+
+// HandleWatchKeys returns a handler function that streams
+// an event to the client whenever a key that matches the
+// pattern of the request URL path - e.g. /v1/key/watch/my-app/*
+// - gets created, deleted, disabled, enabled or rotated.
+//
+// The events are sent as newline-delimited JSON:
+//  {"type":"key","action":"created","name":"<name>","time":"<time>"}
+//
+// Clients bound to a tenant only receive the events of keys
+// within their namespace - without the tenant prefix.
+//
+// The returned handler is a long-running server task. See:
+// HandleTraceAuditLog
+func HandleWatchKeys(store *secret.Store, roles *auth.Roles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern, _ := auth.APIKeyName(r.URL.Path)
+		t, isTenant := tenantOf(r)
+
+		// displayName returns the name of the key as seen
+		// by the client and whether the client can see it.
+		displayName := func(name string) (string, bool) {
+			namespace, key := splitNamespace(roles.Tenants, name)
+			if isTenant {
+				return key, namespace == t.Name
+			}
+			return name, namespace == ""
+		}
+		filter := func(e watch.Event) bool {
+			name, ok := displayName(e.Name)
+			return ok && kes.MatchPath(pattern, name)
+		}
+		subscription := store.Events.Subscribe(watch.TypeKey, 0, filter)
+		defer subscription.Close()
+
+		streamEvents(w, r, subscription, func(e watch.Event) watch.Event {
+			e.Name, _ = displayName(e.Name)
+			if e.Target != "" {
+				e.Target, _ = displayName(e.Target)
+			}
+			return e
+		})
+	}
+}
+
+// HandleWatchPolicies returns a handler function that streams
+// an event to the client whenever a policy that matches the
+// pattern of the request URL path - e.g. /v1/policy/watch/my-*
+// - gets written or deleted.
+//
+// The events are sent as newline-delimited JSON:
+//  {"type":"policy","action":"written","name":"<name>","time":"<time>"}
+//
+// Clients bound to a tenant only receive the events of the
+// policies assigned to identities of their tenant.
+//
+// The returned handler is a long-running server task. See:
+// HandleTraceAuditLog
+func HandleWatchPolicies(roles *auth.Roles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pattern := strings.TrimPrefix(r.URL.Path, "/v1/policy/watch/")
+		t, isTenant := tenantOf(r)
+
+		filter := func(e watch.Event) bool {
+			if isTenant && !tenantPolicies(roles, t.Name)[e.Name] {
+				return false
+			}
+			return kes.MatchPath(pattern, e.Name)
+		}
+		subscription := roles.Events.Subscribe(watch.TypePolicy, 0, filter)
+		defer subscription.Close()
+
+		streamEvents(w, r, subscription, func(e watch.Event) watch.Event { return e })
+	}
+}
+
+// streamEvents writes the events of the subscription, modified
+// by f, as newline-delimited JSON to w until the client closes
+// the connection resp. the request context is done.
+//
+// If the subscription could not keep up with the published
+// events, streamEvents ends the stream with an error line
+// - i.e. {"error":"<message>"} - such that the client can
+// re-synchronize.
+func streamEvents(w http.ResponseWriter, r *http.Request, subscription *watch.Subscription, f func(watch.Event) watch.Event) {
+	var (
+		ErrNotConfigured = kes.NewError(http.StatusNotImplemented, "watching is not supported")
+		ErrEventsLost    = kes.NewError(http.StatusServiceUnavailable, "events lost: client is too slow")
+	)
+	if subscription == nil {
+		Error(w, ErrNotConfigured)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush() // Tell the client that it is subscribed
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case e, ok := <-subscription.Events():
+			if !ok {
+				if subscription.Lost() {
+					encoder.Encode(struct {
+						Error string `json:"error"`
+					}{Error: ErrEventsLost.Error()})
+				}
+				return
+			}
+			if err := encoder.Encode(f(e)); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/watch"
+)
+
+func TestHandleWatchKeys(t *testing.T) {
+	ctx := context.Background()
+	store := &secret.Store{Remote: &mem.Store{}, Events: new(watch.Hub)}
+	roles := &auth.Roles{}
+
+	server := httptest.NewServer(HandleWatchKeys(store, roles))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/key/watch/my-app/*")
+	if err != nil {
+		t.Fatalf("Failed to watch keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to watch keys: got %d - want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if err = store.Create(ctx, "other-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-app/key-1", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Disable("my-app/key-1"); err != nil {
+		t.Fatalf("Failed to disable key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-app/key-1"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for i, action := range []string{watch.ActionCreated, watch.ActionDisabled, watch.ActionDeleted} {
+		var event watch.Event
+		if err = decoder.Decode(&event); err != nil {
+			t.Fatalf("Test %d: Failed to read event: %v", i, err)
+		}
+		if event.Type != watch.TypeKey || event.Name != "my-app/key-1" || event.Action != action {
+			t.Fatalf("Test %d: Invalid event: got %+v - want action '%s'", i, event, action)
+		}
+	}
+}
+
+func TestHandleWatchNotConfigured(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://localhost:7373/v1/policy/watch/*", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	var resp dummyResponseWriter
+	HandleWatchPolicies(&auth.Roles{})(&resp, req)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("Invalid status code: got %d - want %d", resp.StatusCode, http.StatusNotImplemented)
+	}
+}
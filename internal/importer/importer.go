@@ -0,0 +1,198 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package importer imports secrets created by other
+// tools - e.g. keys stored at a Vault K/V path, the
+// AWS SecretsManager or as files - into a KES server.
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// Destination is the KES server that keys get
+// imported into - e.g. a *kes.Client.
+type Destination interface {
+	// ImportKey creates a new key with the given name
+	// and key bytes. It returns kes.ErrKeyExists if
+	// a key with the same name exists already.
+	ImportKey(name string, key []byte) error
+}
+
+// Report describes the result of Import.
+type Report struct {
+	Keys    int `json:"keys"`    // Number of imported keys
+	Skipped int `json:"skipped"` // Number of keys that already existed
+
+	// Invalid contains the name of each entry that
+	// is not a valid key and the reason why.
+	Invalid map[string]string `json:"invalid,omitempty"`
+}
+
+// Importer reads the entries of a Source key store
+// and imports them as keys into a KES server.
+//
+// An entry can either be a key as written by a KES
+// server, a PEM block, the raw 32 key bytes or the
+// base64 or hex encoded key bytes.
+//
+// The KES server stores the imported keys at its own
+// key store. If it has a KMS, it encrypts them with
+// the KMS master key.
+type Importer struct {
+	// Source is the key store that keys are imported
+	// from. It must implement secret.Lister.
+	Source secret.Remote
+
+	// Prefix is an optional prefix of the names of
+	// the entries to import - e.g. "my-app/". The
+	// prefix is removed from the key names.
+	Prefix string
+
+	// KMS is an optional KMS that decrypts entries
+	// written by another KES server with the same
+	// KMS. Without a KMS, such entries are invalid.
+	KMS secret.KMS
+}
+
+// Import imports all entries of the Source into the dst
+// KES server. Keys that exist already are not replaced.
+// If dst is nil, Import only validates the entries - e.g.
+// for a dry run.
+//
+// Entries that are not valid keys are reported as invalid
+// while entries managed by a KES server itself, like its
+// policies, are ignored. Import stops and returns the report
+// so far if the KES server fails to import a key.
+func (i *Importer) Import(dst Destination) (Report, error) {
+	var report Report
+	names, err := secret.ListPrefix(i.Source, i.Prefix)
+	if err != nil {
+		return report, err
+	}
+	invalid := func(name string, err error) {
+		if report.Invalid == nil {
+			report.Invalid = map[string]string{}
+		}
+		report.Invalid[name] = err.Error()
+	}
+	for _, name := range names {
+		if secret.IsReserved(name) {
+			continue // The source may be the key store of another KES server
+		}
+		keyName := strings.TrimPrefix(name, i.Prefix)
+		if secret.IsReserved(keyName) || !secret.ValidName(keyName) {
+			invalid(name, errors.New("invalid name"))
+			continue
+		}
+
+		value, err := i.Source.Get(name)
+		if err == kes.ErrKeyNotFound {
+			continue // The entry has been deleted in the meantime
+		}
+		if err != nil {
+			return report, err
+		}
+		key, err := parseKey(i.KMS, name, value)
+		if err != nil {
+			invalid(name, err)
+			continue
+		}
+		if dst == nil {
+			key.Destroy()
+			report.Keys++
+			continue
+		}
+
+		err = dst.ImportKey(keyName, key[:])
+		key.Destroy()
+		switch err {
+		case nil:
+			report.Keys++
+		case kes.ErrKeyExists:
+			report.Skipped++
+		default:
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// parseKey converts the value of the entry with the
+// given name to a 256 bit key. It decrypts the value
+// with the KMS if it has been encrypted by a KES server.
+//
+// Restrictions of a key written by a KES server - like
+// the operations a key can be used for - cannot be
+// imported. Therefore, such keys are not valid since
+// importing them would remove these restrictions.
+func parseKey(kms secret.KMS, name, value string) (secret.Secret, error) {
+	if key, ok := parseEncodedKey(value); ok {
+		return key, nil
+	}
+
+	value, err := secret.DecodeValue(value)
+	if err != nil {
+		return secret.Secret{}, err
+	}
+	if typ, err := secret.ParseType(value); err != nil {
+		return secret.Secret{}, err
+	} else if typ != secret.TypeKey {
+		return secret.Secret{}, errors.New("entry is a " + typ + " secret and not a key")
+	}
+	if ops, err := secret.ParseOps(value); err != nil {
+		return secret.Secret{}, err
+	} else if len(ops) > 0 {
+		return secret.Secret{}, errors.New("key is restricted to operations")
+	}
+	if immutable, err := secret.ParseImmutable(value); err != nil {
+		return secret.Secret{}, err
+	} else if immutable {
+		return secret.Secret{}, errors.New("key is immutable")
+	}
+
+	if _, err = secret.ParseCiphertext(value); err == nil {
+		if kms == nil {
+			return secret.Secret{}, errors.New("key is encrypted but no KMS is specified")
+		}
+		return secret.DecryptSecret(kms, name, value)
+	}
+	return secret.ParseSecret(value)
+}
+
+// parseEncodedKey parses value as base64 or hex encoded
+// 256 bit key. Tools, like the Vault or AWS CLI, often
+// store keys as text. It reports whether value is such
+// an encoded key.
+func parseEncodedKey(value string) (secret.Secret, bool) {
+	var key secret.Secret
+
+	value = strings.TrimSpace(value)
+	switch len(value) {
+	case base64.StdEncoding.EncodedLen(len(key)):
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(b) != len(key) {
+			return key, false
+		}
+		copy(key[:], b)
+		secret.Wipe(b)
+		return key, true
+	case hex.EncodedLen(len(key)):
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			return key, false
+		}
+		copy(key[:], b)
+		secret.Wipe(b)
+		return key, true
+	default:
+		return key, false
+	}
+}
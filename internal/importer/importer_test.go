@@ -0,0 +1,106 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+type destination map[string][]byte
+
+func (d destination) ImportKey(name string, key []byte) error {
+	if _, ok := d[name]; ok {
+		return kes.ErrKeyExists
+	}
+	d[name] = append([]byte(nil), key...)
+	return nil
+}
+
+var key = bytes.Repeat([]byte{0x42}, 32)
+
+func TestImport(t *testing.T) {
+	var (
+		source = &mem.Store{}
+		kms    = &mem.KMS{MasterKey: secret.Secret{1}}
+		store  = &secret.Store{Remote: source, KMS: kms}
+	)
+	var k secret.Secret
+	copy(k[:], key)
+	if err := store.Create(context.Background(), "my-app/encrypted", k); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateWithOps(context.Background(), "my-app/restricted", k, []string{secret.OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	entries := map[string]string{
+		"my-app/json":     k.String(),
+		"my-app/raw":      string(key),
+		"my-app/base64":   base64.StdEncoding.EncodeToString(key) + "\n",
+		"my-app/hex":      hex.EncodeToString(key),
+		"my-app/pem":      secret.EncodeValue(secret.EncodingPEM, k.String()),
+		"my-app/short":    "too short",
+		"my-app/existing": string(key),
+		"other/json":      k.String(),
+	}
+	for name, value := range entries {
+		if err := source.Create(name, value); err != nil {
+			t.Fatalf("Failed to create entry '%s': %v", name, err)
+		}
+	}
+
+	dst := destination{"existing": key}
+	importer := &Importer{Source: source, Prefix: "my-app/", KMS: kms}
+	report, err := importer.Import(dst)
+	if err != nil {
+		t.Fatalf("Failed to import keys: %v", err)
+	}
+	if report.Keys != 6 || report.Skipped != 1 || len(report.Invalid) != 2 {
+		t.Fatalf("Invalid report: got %+v", report)
+	}
+	if _, ok := report.Invalid["my-app/short"]; !ok {
+		t.Fatal("Invalid key 'my-app/short' has not been reported")
+	}
+	if _, ok := report.Invalid["my-app/restricted"]; !ok {
+		t.Fatal("Restricted key 'my-app/restricted' has not been reported")
+	}
+	for _, name := range []string{"json", "raw", "base64", "hex", "pem", "encrypted"} {
+		if !bytes.Equal(dst[name], key) {
+			t.Fatalf("Key '%s' does not match: got %x - want %x", name, dst[name], key)
+		}
+	}
+	if _, ok := dst["other/json"]; ok {
+		t.Fatal("Imported key without prefix")
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	source := &mem.Store{}
+	if err := source.Create("my-key", string(key)); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := source.Create("encrypted", `{"ciphertext":"AAAA"}`); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+
+	importer := &Importer{Source: source}
+	report, err := importer.Import(nil)
+	if err != nil {
+		t.Fatalf("Failed to validate keys: %v", err)
+	}
+	if report.Keys != 1 || len(report.Invalid) != 1 {
+		t.Fatalf("Invalid report: got %+v", report)
+	}
+	if _, ok := report.Invalid["encrypted"]; !ok {
+		t.Fatal("Encrypted key without KMS has not been reported")
+	}
+}
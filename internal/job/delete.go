@@ -0,0 +1,138 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+)
+
+var errInvalidPattern = kes.NewError(http.StatusBadRequest, "invalid key pattern")
+
+// DeleteKeys returns a Func that deletes all keys whose names
+// match a glob pattern. The job parameters are:
+//   {"pattern": "<pattern>"}
+//
+// It processes the keys in lexical order and releases the
+// key quota of each deleted key, if the roles enforce quotas.
+// The deletion is recorded in the provenance of each key on
+// behalf of the identity that submitted the job.
+// The Remote store of the secret store must be able to list
+// its entries.
+//
+// The job holds the lock of a key, if lock is not nil, while
+// deleting the key.
+func DeleteKeys(store *secret.Store, roles *auth.Roles, lock func(name string) sync.Locker) Func {
+	return func(ctx context.Context, params json.RawMessage, p *Progress) error {
+		var identity kes.Identity
+		if job, ok := p.manager.Get(p.id); ok {
+			identity = job.Identity
+		}
+		err := forEachKey(ctx, store, params, p, func(name string) error {
+			return deleteKey(ctx, store, roles, lock, name, identity, p.id)
+		})
+		if err != nil {
+			return err
+		}
+		if roles.Quotas != nil {
+			return roles.Quotas.Save()
+		}
+		return nil
+	}
+}
+
+// deleteKey deletes the named key while holding its lock, if
+// lock is not nil, records the deletion in its provenance and
+// releases its key quota.
+func deleteKey(ctx context.Context, store *secret.Store, roles *auth.Roles, lock func(name string) sync.Locker, name string, identity kes.Identity, id string) error {
+	if lock != nil {
+		l := lock(name)
+		l.Lock()
+		defer l.Unlock()
+	}
+	if err := store.Delete(ctx, name); err != nil {
+		return err
+	}
+	err := store.AppendProvenance(name, secret.ProvenanceEvent{
+		Type:     secret.ProvenanceDeleted,
+		Identity: identity,
+		Origin:   "job " + id,
+	})
+	if err != nil {
+		return err
+	}
+	if roles.Quotas != nil {
+		roles.Quotas.Release(name)
+	}
+	return nil
+}
+
+// forEachKey calls f for each key of the secret store whose
+// name matches the glob pattern of the job parameters:
+//   {"pattern": "<pattern>"}
+//
+// It processes the keys in lexical order and reports the
+// progress after each key. When the job is resumed, it
+// continues after the last processed key.
+func forEachKey(ctx context.Context, store *secret.Store, params json.RawMessage, p *Progress, f func(name string) error) error {
+	type Params struct {
+		Pattern string `json:"pattern"`
+	}
+	var args Params
+	if err := json.Unmarshal(params, &args); err != nil || args.Pattern == "" {
+		return errInvalidPattern
+	}
+	if _, err := path.Match(args.Pattern, args.Pattern); err != nil {
+		return errInvalidPattern
+	}
+
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, name := range names {
+		if ok, _ := path.Match(args.Pattern, name); ok {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	// When resuming, skip all keys that have been
+	// processed before the server has been stopped.
+	// They are part of the total but, e.g. when they
+	// have been deleted, may not be listed anymore.
+	var done int
+	if cursor := p.Cursor(); cursor != "" {
+		i := sort.SearchStrings(keys, cursor)
+		if i < len(keys) && keys[i] == cursor {
+			i++
+		}
+		keys = keys[i:]
+		if job, ok := p.manager.Get(p.id); ok {
+			done = job.Done
+		}
+	}
+	total := done + len(keys)
+	for _, name := range keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = f(name); err != nil {
+			return err
+		}
+		done++
+		p.Update(done, total, name)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package job implements asynchronous, long-running
+// server operations - like deleting many keys.
+//
+// A client submits a job and receives a job ID. The
+// job runs in the background and the client can query
+// its state and progress using the job ID. The state of
+// all jobs is stored at the Remote store such that jobs
+// are resumed after a server restart.
+package job
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+var (
+	// ErrNotFound is returned when a job does not exist.
+	ErrNotFound = kes.NewError(http.StatusNotFound, "job does not exist")
+
+	errUnknownType = kes.NewError(http.StatusBadRequest, "unknown job type")
+	errFinished    = kes.NewError(http.StatusBadRequest, "job has already finished")
+)
+
+// State is the state of a job.
+type State string
+
+// All job states. A job is either pending, running
+// or has finished in one of the final states.
+const (
+	Pending   State = "pending"
+	Running   State = "running"
+	Completed State = "completed"
+	Failed    State = "failed"
+	Canceled  State = "canceled"
+)
+
+// Job describes an asynchronous operation.
+type Job struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Identity kes.Identity    `json:"identity"` // The identity that submitted the job
+	State    State           `json:"state"`
+	Error    string          `json:"error,omitempty"`
+
+	// Done and Total are the number of processed
+	// resp. total items - e.g. keys. Total is 0
+	// as long as it is not known.
+	Done  int `json:"done"`
+	Total int `json:"total"`
+
+	// Cursor is the position up to which the job has
+	// processed its items. It allows a job to resume
+	// after a server restart.
+	Cursor string `json:"cursor,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func (j *Job) finished() bool {
+	return j.State == Completed || j.State == Failed || j.State == Canceled
+}
+
+// Func performs a job of a particular type. It must
+// report its progress via the Progress. When a job
+// is resumed after a server restart, the Func must
+// continue after the Progress cursor.
+//
+// A Func must return once the ctx is done.
+type Func func(ctx context.Context, params json.RawMessage, p *Progress) error
+
+// Progress tracks the progress of a running job.
+type Progress struct {
+	manager *Manager
+	id      string
+	cursor  string
+}
+
+// Cursor returns the position up to which the job has
+// processed its items. It is empty when the job starts
+// and may be set when the job has been resumed.
+func (p *Progress) Cursor() string { return p.cursor }
+
+// Update records that the job has processed done of
+// total items, up to and including the item at cursor.
+// The progress is persisted periodically.
+func (p *Progress) Update(done, total int, cursor string) {
+	p.cursor = cursor
+	p.manager.update(p.id, func(job *Job) {
+		job.Done, job.Total, job.Cursor = done, total, cursor
+	}, false)
+}
+
+// Manager runs jobs and keeps track of their state.
+type Manager struct {
+	// Remote is the Remote store of the server. The
+	// Manager stores the state of all jobs at the
+	// Remote store.
+	Remote secret.Remote
+
+	// Types maps job types to the function that
+	// performs jobs of this type.
+	Types map[string]Func
+
+	// AuditLog, if not nil, is the audit logger. The
+	// Manager logs an audit event when a job finishes.
+	AuditLog *log.Logger
+
+	// Retention is the duration finished jobs are
+	// kept. If 0, it defaults to 7 days.
+	Retention time.Duration
+
+	ctx      context.Context
+	lock     sync.Mutex
+	saveLock sync.Mutex
+	jobs     map[string]*Job
+	cancel   map[string]context.CancelFunc
+	lastSave time.Time
+}
+
+// Start loads all jobs from the Remote store and resumes
+// all jobs that have not finished yet. The ctx controls the
+// lifetime of all jobs. Start must be called before any
+// other method.
+func (m *Manager) Start(ctx context.Context) error {
+	value, err := secret.Load(m.Remote, secret.ReservedJobsName)
+	if err != nil && err != kes.ErrKeyNotFound {
+		return err
+	}
+	var jobs []*Job
+	if err == nil {
+		if err = json.Unmarshal([]byte(value), &jobs); err != nil {
+			return errors.New("job: persisted jobs are malformed")
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.ctx = ctx
+	m.jobs = make(map[string]*Job, len(jobs))
+	m.cancel = map[string]context.CancelFunc{}
+	for _, job := range jobs {
+		m.jobs[job.ID] = job
+		if !job.finished() {
+			m.run(job)
+		}
+	}
+	return nil
+}
+
+// Submit submits a new job of the given type. It returns
+// the new job, which runs in the background.
+func (m *Manager) Submit(typ string, params json.RawMessage, identity kes.Identity) (Job, error) {
+	if _, ok := m.Types[typ]; !ok {
+		return Job{}, errUnknownType
+	}
+	id, err := sioutil.Random(16)
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:       hex.EncodeToString(id),
+		Type:     typ,
+		Params:   params,
+		Identity: identity,
+		State:    Pending,
+		Created:  now,
+		Updated:  now,
+	}
+
+	m.lock.Lock()
+	if m.jobs == nil {
+		m.lock.Unlock()
+		return Job{}, errors.New("job: manager has not been started")
+	}
+	m.jobs[job.ID] = job
+	m.lock.Unlock()
+
+	if err = m.save(true); err != nil {
+		m.lock.Lock()
+		delete(m.jobs, job.ID)
+		m.lock.Unlock()
+		return Job{}, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.run(job)
+	return *job, nil
+}
+
+// Get returns the job with the given ID, if it exists.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns all jobs sorted by their creation time.
+func (m *Manager) List() []Job {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	return jobs
+}
+
+// Cancel cancels the job with the given ID. It returns
+// an error if the job does not exist or has already
+// finished.
+func (m *Manager) Cancel(id string) error {
+	m.lock.Lock()
+	job, ok := m.jobs[id]
+	if !ok {
+		m.lock.Unlock()
+		return ErrNotFound
+	}
+	if job.finished() {
+		m.lock.Unlock()
+		return errFinished
+	}
+	cancel := m.cancel[id]
+	m.lock.Unlock()
+
+	m.update(id, func(job *Job) { job.State = Canceled }, true)
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// run starts the job in a separate go routine.
+// The caller must hold the manager lock.
+func (m *Manager) run(job *Job) {
+	f := m.Types[job.Type]
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancel[job.ID] = cancel
+
+	progress := &Progress{
+		manager: m,
+		id:      job.ID,
+		cursor:  job.Cursor,
+	}
+	params := job.Params
+	go func() {
+		defer cancel()
+
+		start := time.Now()
+		m.update(job.ID, func(job *Job) {
+			if job.State == Pending {
+				job.State = Running
+			}
+		}, true)
+
+		var err error
+		if f == nil {
+			err = errUnknownType
+		} else {
+			err = f(ctx, params, progress)
+		}
+
+		// If the server shuts down, the job is neither
+		// completed nor canceled. It will be resumed once
+		// the server starts again.
+		if m.ctx.Err() != nil {
+			return
+		}
+		var finished Job
+		m.update(job.ID, func(job *Job) {
+			switch {
+			case job.State == Canceled:
+			case err != nil:
+				job.State, job.Error = Failed, err.Error()
+			default:
+				job.State = Completed
+			}
+			finished = *job
+		}, true)
+
+		m.lock.Lock()
+		delete(m.cancel, job.ID)
+		m.lock.Unlock()
+
+		m.audit(finished, time.Since(start))
+	}()
+}
+
+// update applies f to the job with the given ID and
+// persists the state of all jobs. Unless force is set,
+// update persists the state at most every few seconds.
+func (m *Manager) update(id string, f func(*Job), force bool) {
+	m.lock.Lock()
+	job, ok := m.jobs[id]
+	if ok {
+		f(job)
+		job.Updated = time.Now().UTC()
+	}
+	m.lock.Unlock()
+
+	if ok {
+		m.save(force)
+	}
+}
+
+// save writes the state of all jobs to the Remote
+// store and removes finished jobs that have exceeded
+// their retention. Unless force is set, save does
+// nothing if the state has been saved recently.
+//
+// Since a Remote store cannot update an entry, save
+// deletes and re-creates the entry.
+func (m *Manager) save(force bool) error {
+	const (
+		SaveInterval     = 5 * time.Second
+		DefaultRetention = 7 * 24 * time.Hour
+	)
+	m.saveLock.Lock()
+	defer m.saveLock.Unlock()
+
+	retention := m.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	m.lock.Lock()
+	now := time.Now()
+	if !force && now.Sub(m.lastSave) < SaveInterval {
+		m.lock.Unlock()
+		return nil
+	}
+	m.lastSave = now
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for id, job := range m.jobs {
+		if job.finished() && now.Sub(job.Updated) > retention {
+			delete(m.jobs, id)
+			continue
+		}
+		j := *job
+		jobs = append(jobs, &j)
+	}
+	m.lock.Unlock()
+
+	value, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	return secret.Replace(m.Remote, secret.ReservedJobsName, string(value))
+}
+
+// audit logs an audit event for the finished job.
+func (m *Manager) audit(job Job, duration time.Duration) {
+	if m.AuditLog == nil {
+		return
+	}
+
+	status := http.StatusOK
+	switch job.State {
+	case Failed:
+		status = http.StatusInternalServerError
+	case Canceled:
+		status = http.StatusGone
+	}
+	event, err := json.Marshal(kes.AuditEvent{
+		Time: time.Now().UTC(),
+		Request: kes.AuditEventRequest{
+			Path:     "/v1/job/" + job.Type + "/" + job.ID,
+			API:      "/v1/job/" + job.Type,
+			Identity: job.Identity.String(),
+		},
+		Response: kes.AuditEventResponse{
+			StatusCode: status,
+			Time:       duration,
+		},
+	})
+	if err == nil {
+		m.AuditLog.Print(string(event))
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestDeleteKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	remote := &mem.Store{}
+	store := &secret.Store{Remote: remote}
+	for i := 0; i < 10; i++ {
+		if err := store.Create(ctx, fmt.Sprintf("my-app-%d", i), secret.Secret{}); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+	if err := store.Create(ctx, "other-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	var auditLog syncBuffer
+	manager := &Manager{
+		Remote: remote,
+		Types: map[string]Func{
+			"delete-keys": DeleteKeys(store, &auth.Roles{}, nil),
+		},
+		AuditLog: log.New(&auditLog, "", 0),
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	if _, err := manager.Submit("unknown", nil, kes.Identity("")); err == nil {
+		t.Fatal("Submitting a job of an unknown type should fail")
+	}
+	job, err := manager.Submit("delete-keys", []byte(`{"pattern":"my-app-*"}`), kes.Identity("my-identity"))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+
+	job = waitFor(t, manager, job.ID)
+	if job.State != Completed {
+		t.Fatalf("Job has not completed: got state '%s' - error: %s", job.State, job.Error)
+	}
+	if job.Done != 10 || job.Total != 10 {
+		t.Fatalf("Invalid job progress: got %d/%d - want %d/%d", job.Done, job.Total, 10, 10)
+	}
+	names, err := store.List()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "other-key" {
+		t.Fatalf("Invalid keys: got %v - want %v", names, []string{"other-key"})
+	}
+	for i := 0; !strings.Contains(auditLog.String(), "/v1/job/delete-keys/"+job.ID); i++ {
+		if i == 100 {
+			t.Fatalf("Completed job has not been logged: %s", auditLog.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A new manager loads the finished job from the Remote store.
+	manager = &Manager{Remote: remote}
+	if err = manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	if loaded, ok := manager.Get(job.ID); !ok || loaded.State != Completed {
+		t.Fatalf("Finished job has not been persisted: got %v", loaded)
+	}
+}
+
+func TestRewrapKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	remote := &mem.Store{}
+	old := &secret.Store{Remote: remote, KMS: &mem.KMS{MasterKey: secret.Secret{1}}}
+	for i := 0; i < 3; i++ {
+		if err := old.Create(ctx, fmt.Sprintf("my-app-%d", i), secret.Secret{byte(i)}); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+
+	// The mem.KMS cannot rotate its master key. Hence, the
+	// keys get re-wrapped with the same master key.
+	store := &secret.Store{Remote: remote, KMS: old.KMS}
+	manager := &Manager{
+		Remote: remote,
+		Types: map[string]Func{
+			"rewrap-keys": RewrapKeys(store, func(string) sync.Locker { return &sync.Mutex{} }),
+		},
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	job, err := manager.Submit("rewrap-keys", []byte(`{"pattern":"my-app-*"}`), kes.Identity("my-identity"))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	if job = waitFor(t, manager, job.ID); job.State != Completed {
+		t.Fatalf("Job has not completed: got state '%s' - error: %s", job.State, job.Error)
+	}
+	if job.Done != 3 || job.Total != 3 {
+		t.Fatalf("Invalid job progress: got %d/%d - want %d/%d", job.Done, job.Total, 3, 3)
+	}
+
+	store = &secret.Store{Remote: remote, KMS: old.KMS}
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("my-app-%d", i)
+		if key, err := store.Get(ctx, name); err != nil || key != (secret.Secret{byte(i)}) {
+			t.Fatalf("Key '%s' has been modified: got %x, %v - want %x", name, key, err, secret.Secret{byte(i)})
+		}
+		if _, err := remote.Get(secret.ReservedRewrapPrefix + name); err != kes.ErrKeyNotFound {
+			t.Fatalf("Re-wrapping of '%s' has not been completed: %v", name, err)
+		}
+	}
+}
+
+func TestMigrateKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		remote = &mem.Store{}
+		target = &mem.Store{}
+		kms    = &mem.KMS{MasterKey: secret.Secret{1}}
+		store  = &secret.Store{Remote: remote, KMS: kms}
+	)
+	for i := 0; i < 3; i++ {
+		if err := store.Create(ctx, fmt.Sprintf("my-app-%d", i), secret.Secret{byte(i)}); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+	if err := store.Create(ctx, "other-key", secret.Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := remote.Create(secret.ReservedProvenancePrefix+"my-app-0", "[]"); err != nil {
+		t.Fatalf("Failed to create provenance: %v", err)
+	}
+
+	manager := &Manager{
+		Remote: remote,
+		Types: map[string]Func{
+			"migrate-keys": MigrateKeys(store, target, nil),
+		},
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	for run := 0; run < 2; run++ { // A second run skips the migrated keys
+		job, err := manager.Submit("migrate-keys", []byte(`{"pattern":"my-app-*"}`), kes.Identity("my-identity"))
+		if err != nil {
+			t.Fatalf("Failed to submit job: %v", err)
+		}
+		if job = waitFor(t, manager, job.ID); job.State != Completed {
+			t.Fatalf("Job %d has not completed: got state '%s' - error: %s", run, job.State, job.Error)
+		}
+	}
+
+	migrated := &secret.Store{Remote: target, KMS: kms}
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("my-app-%d", i)
+		if key, err := migrated.Get(ctx, name); err != nil || key != (secret.Secret{byte(i)}) {
+			t.Fatalf("Key '%s' has not been migrated: got %x, %v - want %x", name, key, err, secret.Secret{byte(i)})
+		}
+	}
+	if _, err := target.Get("other-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Key 'other-key' should not have been migrated: %v", err)
+	}
+	if value, err := target.Get(secret.ReservedProvenancePrefix + "my-app-0"); err != nil || value != "[]" {
+		t.Fatalf("Provenance has not been migrated: got %q, %v", value, err)
+	}
+
+	// A key that exists at the target with another value
+	// must not be overwritten.
+	if err := target.Delete("my-app-1"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err := target.Create("my-app-1", "other-value"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	job, err := manager.Submit("migrate-keys", []byte(`{"pattern":"my-app-*"}`), kes.Identity("my-identity"))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	if job = waitFor(t, manager, job.ID); job.State != Failed {
+		t.Fatal("Job should fail if a key exists at the target with another value")
+	}
+	if value, _ := target.Get("my-app-1"); value != "other-value" {
+		t.Fatalf("Existing key has been overwritten: got %q", value)
+	}
+}
+
+func TestResumeJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		remote  = &mem.Store{}
+		started = make(chan string, 1)
+		release = make(chan struct{})
+	)
+	manager := &Manager{
+		Remote: remote,
+		Types: map[string]Func{
+			"test": func(ctx context.Context, _ json.RawMessage, p *Progress) error {
+				if p.Cursor() == "" {
+					p.Update(1, 2, "first")
+				}
+				started <- p.Cursor()
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-release:
+					return nil
+				}
+			},
+		},
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	job, err := manager.Submit("test", nil, kes.Identity(""))
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	<-started
+	manager.save(true)
+	cancel() // Simulate a server shutdown
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	manager = &Manager{Remote: remote, Types: manager.Types}
+	if err = manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	if cursor := <-started; cursor != "first" {
+		t.Fatalf("Job has not been resumed at its cursor: got '%s' - want '%s'", cursor, "first")
+	}
+	close(release)
+	if job = waitFor(t, manager, job.ID); job.State != Completed {
+		t.Fatalf("Resumed job has not completed: got state '%s'", job.State)
+	}
+}
+
+func waitFor(t *testing.T, manager *Manager, id string) Job {
+	for i := 0; i < 500; i++ {
+		job, ok := manager.Get(id)
+		if !ok {
+			t.Fatalf("Job '%s' does not exist", id)
+		}
+		if job.finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job '%s' has not finished", id)
+	return Job{}
+}
+
+// syncBuffer is a bytes.Buffer that can
+// be used concurrently.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
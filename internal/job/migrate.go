@@ -0,0 +1,87 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+// MigrateKeys returns a Func that copies all keys whose names
+// match a glob pattern, and their provenance, from the secret
+// store to the target key store - e.g. to move the keys to
+// another key store backend. The job parameters are:
+//   {"pattern": "<pattern>"}
+//
+// The keys are copied as stored - i.e. still encrypted by the
+// KMS of the secret store, if any. Hence, a server using the
+// target key store needs the same KMS. A key that already
+// exists at the target with the same value is skipped such
+// that a job can be submitted again.
+//
+// MigrateKeys does not delete any key from the secret store.
+// Entries managed by the server itself - e.g. the policies -
+// are not copied. Before it copies any key, it restores the
+// keys whose re-wrapping has been interrupted. See:
+// secret.Store.RecoverRewrap
+//
+// The job holds the lock of a key, if lock is not nil, while
+// copying the key. The lock must exclude any concurrent
+// re-wrapping of the key.
+func MigrateKeys(store *secret.Store, target secret.Remote, lock func(name string) sync.Locker) Func {
+	return func(ctx context.Context, params json.RawMessage, p *Progress) error {
+		if err := store.RecoverRewrap(); err != nil {
+			return err
+		}
+		return forEachKey(ctx, store, params, p, func(name string) error {
+			if lock != nil {
+				l := lock(name)
+				l.Lock()
+				defer l.Unlock()
+			}
+			return migrateKey(store.Remote, target, name)
+		})
+	}
+}
+
+// migrateKey copies the key with the given name and its
+// provenance from the src to the dst Remote store.
+func migrateKey(src, dst secret.Remote, name string) error {
+	value, err := src.Get(name)
+	if err == kes.ErrKeyNotFound { // The key has been deleted concurrently
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = dst.Create(name, value); err == kes.ErrKeyExists {
+		var existing string
+		if existing, err = dst.Get(name); err != nil {
+			return err
+		}
+		if existing != value {
+			return kes.NewError(http.StatusConflict, fmt.Sprintf("key '%s' already exists at the target key store", name))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// The provenance of the key may have grown since
+	// a previous migration. Hence, it gets replaced.
+	provenance, err := secret.Load(src, secret.ReservedProvenancePrefix+name)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return secret.Replace(dst, secret.ReservedProvenancePrefix+name, provenance)
+}
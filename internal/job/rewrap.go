@@ -0,0 +1,42 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/minio/kes/internal/secret"
+)
+
+// RewrapKeys returns a Func that encrypts all keys whose names
+// match a glob pattern again with the KMS of the secret store -
+// e.g. once the KMS master key has been rotated. The job
+// parameters are:
+//   {"pattern": "<pattern>"}
+//
+// Before it processes any key, it restores the keys whose
+// re-wrapping has been interrupted - e.g. by a server restart.
+// See: secret.Store.Rewrap
+//
+// The job holds the lock of a key, if lock is not nil, while
+// re-wrapping the key. The lock must exclude any concurrent
+// modification of the key.
+func RewrapKeys(store *secret.Store, lock func(name string) sync.Locker) Func {
+	return func(ctx context.Context, params json.RawMessage, p *Progress) error {
+		if err := store.RecoverRewrap(); err != nil {
+			return err
+		}
+		return forEachKey(ctx, store, params, p, func(name string) error {
+			if lock != nil {
+				l := lock(name)
+				l.Lock()
+				defer l.Unlock()
+			}
+			return store.Rewrap(ctx, name)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kmip
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+var itemEncodingTests = []Item{
+	{Tag: 0x420069, Type: TypeInteger, Value: int32(-1)},                                           // 0
+	{Tag: 0x420001, Type: TypeLongInteger, Value: int64(1) << 40},                                  // 1
+	{Tag: 0x420002, Type: TypeBigInteger, Value: []byte{0, 0, 0, 0, 0, 0, 1, 0}},                   // 2
+	{Tag: 0x42005C, Type: TypeEnumeration, Value: uint32(0x0A)},                                    // 3
+	{Tag: 0x420003, Type: TypeBoolean, Value: true},                                                // 4
+	{Tag: 0x420094, Type: TypeTextString, Value: "my-key"},                                         // 5
+	{Tag: 0x420043, Type: TypeByteString, Value: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}},                // 6
+	{Tag: 0x420092, Type: TypeDateTime, Value: time.Unix(1577836800, 0).UTC()},                     // 7
+	{Tag: 0x420004, Type: TypeInterval, Value: uint32(3600)},                                       // 8
+	structure(0x420078, structure(0x420077), Item{Tag: 0x420094, Type: TypeTextString, Value: ""}), // 9
+}
+
+func TestItemEncoding(t *testing.T) {
+	for i, test := range itemEncodingTests {
+		data, err := test.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode item: %v", i, err)
+		}
+		if len(data)%8 != 0 {
+			t.Fatalf("Test %d: encoding is not padded to 8 bytes: got %d bytes", i, len(data))
+		}
+
+		item, err := ReadItem(bytes.NewReader(data), maxMessageSize)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decode item: %v", i, err)
+		}
+		if !reflect.DeepEqual(item, test) {
+			t.Fatalf("Test %d: decoded item does not match: got %#v - want %#v", i, item, test)
+		}
+	}
+}
+
+func TestReadItemMalformed(t *testing.T) {
+	valid, err := Item{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: "my-key"}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to encode item: %v", err)
+	}
+	if _, err = ReadItem(bytes.NewReader(valid[:len(valid)-1]), maxMessageSize); err == nil {
+		t.Fatal("Truncated item should not be decoded")
+	}
+	if _, err = ReadItem(bytes.NewReader(valid), 8); err == nil {
+		t.Fatal("Item larger than the max. size should not be decoded")
+	}
+
+	invalidInt := []byte{0x42, 0x00, 0x69, byte(TypeInteger), 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0}
+	if _, err = ReadItem(bytes.NewReader(invalidInt), maxMessageSize); err == nil {
+		t.Fatal("Integer with invalid length should not be decoded")
+	}
+}
+
+func TestServerOperations(t *testing.T) {
+	cert := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("kmip-client")}
+	identity := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	server := &Server{
+		Store: &secret.Store{Remote: &mem.Store{}},
+		Roles: &auth.Roles{Root: kes.Identity(hex.EncodeToString(identity[:]))},
+	}
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5696}
+
+	response := server.handle(state, addr, requestMessage(opCreate, structure(tagRequestPayload,
+		Item{Tag: tagObjectType, Type: TypeEnumeration, Value: objectTypeSymmetricKey},
+		structure(tagTemplateAttribute,
+			structure(tagAttribute,
+				Item{Tag: tagAttributeName, Type: TypeTextString, Value: "Name"},
+				structure(tagAttributeValue, Item{Tag: tagNameValue, Type: TypeTextString, Value: "my-key"}),
+			),
+		),
+	)))
+	if payload := successPayload(t, response); payload == nil {
+		t.Fatal("Create failed")
+	} else if id, _ := textOf(*payload, tagUniqueIdentifier); id != "my-key" {
+		t.Fatalf("Invalid unique identifier: got '%s' - want 'my-key'", id)
+	}
+
+	response = server.handle(state, addr, requestMessage(opGet, structure(tagRequestPayload,
+		Item{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: "my-key"},
+	)))
+	payload := successPayload(t, response)
+	if payload == nil {
+		t.Fatal("Get failed")
+	}
+	symmetricKey, _ := payload.Child(tagSymmetricKey)
+	keyBlock, _ := symmetricKey.Child(tagKeyBlock)
+	keyValue, _ := keyBlock.Child(tagKeyValue)
+	material, _ := keyValue.Child(tagKeyMaterial)
+	if key, _ := material.Value.([]byte); len(key) != 32 {
+		t.Fatalf("Invalid key material: got %d bytes - want 32", len(key))
+	}
+
+	response = server.handle(state, addr, requestMessage(opDestroy, structure(tagRequestPayload,
+		Item{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: "my-key"},
+	)))
+	if successPayload(t, response) == nil {
+		t.Fatal("Destroy failed")
+	}
+
+	response = server.handle(state, addr, requestMessage(opGet, structure(tagRequestPayload,
+		Item{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: "my-key"},
+	)))
+	batchItem, _ := response.Child(tagBatchItem)
+	if reason, _ := enumOf(batchItem, tagResultReason); reason != reasonItemNotFound {
+		t.Fatalf("Get of deleted key should fail with item not found: got reason %#x", reason)
+	}
+
+	unknown := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{RawSubjectPublicKeyInfo: []byte("unknown")}}}
+	response = server.handle(unknown, addr, requestMessage(opGet, structure(tagRequestPayload,
+		Item{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: "my-key"},
+	)))
+	batchItem, _ = response.Child(tagBatchItem)
+	if reason, _ := enumOf(batchItem, tagResultReason); reason != reasonPermissionDenied {
+		t.Fatalf("Unknown identity should not be allowed: got reason %#x", reason)
+	}
+}
+
+func requestMessage(operation uint32, payload Item) Item {
+	return structure(tagRequestMessage,
+		structure(tagRequestHeader,
+			structure(tagProtocolVersion,
+				Item{Tag: tagProtocolVersionMajor, Type: TypeInteger, Value: int32(1)},
+				Item{Tag: tagProtocolVersionMinor, Type: TypeInteger, Value: int32(4)},
+			),
+			Item{Tag: tagBatchCount, Type: TypeInteger, Value: int32(1)},
+		),
+		structure(tagBatchItem,
+			Item{Tag: tagOperation, Type: TypeEnumeration, Value: operation},
+			payload,
+		),
+	)
+}
+
+func successPayload(t *testing.T, response Item) *Item {
+	batchItem, ok := response.Child(tagBatchItem)
+	if !ok {
+		t.Fatal("Response contains no batch item")
+	}
+	if status, _ := enumOf(batchItem, tagResultStatus); status != statusSuccess {
+		message, _ := textOf(batchItem, tagResultMessage)
+		t.Logf("Operation failed: %s", message)
+		return nil
+	}
+	payload, ok := batchItem.Child(tagResponsePayload)
+	if !ok {
+		t.Fatal("Response contains no payload")
+	}
+	return &payload
+}
@@ -0,0 +1,632 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kmip
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/secret"
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+// The KMIP tags used by the server.
+const (
+	tagAttribute              Tag = 0x420008
+	tagAttributeName          Tag = 0x42000A
+	tagAttributeValue         Tag = 0x42000B
+	tagBatchCount             Tag = 0x42000D
+	tagBatchItem              Tag = 0x42000F
+	tagCryptographicAlgorithm Tag = 0x420028
+	tagCryptographicLength    Tag = 0x42002A
+	tagKeyBlock               Tag = 0x420040
+	tagKeyFormatType          Tag = 0x420042
+	tagKeyMaterial            Tag = 0x420043
+	tagKeyValue               Tag = 0x420045
+	tagNameValue              Tag = 0x420055
+	tagObjectType             Tag = 0x420057
+	tagOperation              Tag = 0x42005C
+	tagProtocolVersion        Tag = 0x420069
+	tagProtocolVersionMajor   Tag = 0x42006A
+	tagProtocolVersionMinor   Tag = 0x42006B
+	tagRequestHeader          Tag = 0x420077
+	tagRequestMessage         Tag = 0x420078
+	tagRequestPayload         Tag = 0x420079
+	tagResponseHeader         Tag = 0x42007A
+	tagResponseMessage        Tag = 0x42007B
+	tagResponsePayload        Tag = 0x42007C
+	tagResultMessage          Tag = 0x42007D
+	tagResultReason           Tag = 0x42007E
+	tagResultStatus           Tag = 0x42007F
+	tagSymmetricKey           Tag = 0x42008F
+	tagTemplateAttribute      Tag = 0x420091
+	tagTimeStamp              Tag = 0x420092
+	tagUniqueBatchItemID      Tag = 0x420093
+	tagUniqueIdentifier       Tag = 0x420094
+)
+
+// The KMIP operations supported by the server.
+const (
+	opCreate           uint32 = 0x01
+	opGet              uint32 = 0x0A
+	opDestroy          uint32 = 0x14
+	opDiscoverVersions uint32 = 0x1E
+)
+
+// KMIP enumeration values.
+const (
+	objectTypeSymmetricKey uint32 = 0x02
+	algorithmAES           uint32 = 0x03
+	keyFormatRaw           uint32 = 0x01
+
+	statusSuccess         uint32 = 0x00
+	statusOperationFailed uint32 = 0x01
+)
+
+// The KMIP result reasons of failed operations.
+const (
+	reasonItemNotFound          uint32 = 0x01
+	reasonInvalidMessage        uint32 = 0x04
+	reasonOperationNotSupported uint32 = 0x05
+	reasonMissingData           uint32 = 0x06
+	reasonInvalidField          uint32 = 0x07
+	reasonIllegalOperation      uint32 = 0x0B
+	reasonPermissionDenied      uint32 = 0x0C
+	reasonGeneralFailure        uint32 = 0x100
+)
+
+// maxMessageSize is the max. size of a KMIP request
+// message. The supported requests are small. Therefore,
+// 1 MiB is more than enough.
+const maxMessageSize = 1 << 20
+
+// ErrServerClosed is returned by the Server's Serve and
+// ListenAndServe methods after a call to Close.
+var ErrServerClosed = errors.New("kmip: server closed")
+
+// Server is a KMIP server that maps the KMIP operations
+// to the key store:
+//   Create  -> creates a new 256 bit AES key
+//   Get     -> returns the raw key material
+//   Destroy -> deletes the key
+//
+// The KMIP unique identifier of an object is the name
+// of the key. Requests are authorized by the policies
+// of the client identity - as if the client had sent
+// the corresponding request to the HTTP API:
+//   Create  -> /v1/key/create/<name>
+//   Get     -> /v1/key/export/<name>
+//   Destroy -> /v1/key/delete/<name>
+//
+// Since the KMIP Get operation returns the key itself,
+// the export API has no HTTP counterpart. It must be
+// allowed explicitly.
+type Server struct {
+	// Addr is the TCP address the server listens
+	// on. KMIP uses port 5696 by default.
+	Addr string
+
+	// Store is the key store of the server.
+	Store *secret.Store
+
+	// Roles authenticates and authorizes
+	// the KMIP clients.
+	Roles *auth.Roles
+
+	// TLSConfig is the TLS configuration of
+	// the server. KMIP requires mutual TLS.
+	// Therefore, it should require a client
+	// certificate.
+	TLSConfig *tls.Config
+
+	// ErrorLog is an optional logger for errors
+	// that cannot be sent to the client - e.g.
+	// failed TLS handshakes.
+	ErrorLog *log.Logger
+
+	// Lock optionally returns the lock of the key
+	// with the given name that the server holds
+	// while creating or deleting the key - e.g.
+	// to serialize key modifications with
+	// conditional requests of the HTTP API.
+	Lock func(name string) sync.Locker
+
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// ListenAndServe listens on the TCP address s.Addr and
+// serves KMIP requests sent via TLS connections.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(tls.NewListener(listener, s.TLSConfig))
+}
+
+// Serve accepts connections on the listener and serves
+// KMIP requests. The listener must return TLS connections.
+//
+// Serve always returns a non-nil error and closes the
+// listener. After Close, it returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.lock.Unlock()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		if s.conns == nil {
+			s.conns = map[net.Conn]struct{}{}
+		}
+		s.conns[conn] = struct{}{}
+		s.lock.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes the listener and all open connections.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		s.logf("kmip: connection from %s is not a TLS connection", conn.RemoteAddr())
+		return
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		s.logf("kmip: TLS handshake with %s failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	state := tlsConn.ConnectionState()
+
+	for {
+		// KMIP clients keep connections open for
+		// subsequent requests. However, we close
+		// idle connections eventually.
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+		request, err := ReadItem(conn, maxMessageSize)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if _, ok := err.(net.Error); !ok {
+				s.logf("kmip: failed to read request from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		response, err := s.handle(&state, conn.RemoteAddr(), request).MarshalBinary()
+		if err != nil {
+			s.logf("kmip: failed to encode response: %v", err)
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err = conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+// handle processes all batch items of the request
+// message and returns the response message.
+func (s *Server) handle(state *tls.ConnectionState, remoteAddr net.Addr, request Item) Item {
+	major, minor := uint32(1), uint32(4)
+	header, _ := request.Child(tagRequestHeader)
+	if version, ok := header.Child(tagProtocolVersion); ok {
+		reqMajor, _ := int32Of(version, tagProtocolVersionMajor)
+		reqMinor, _ := int32Of(version, tagProtocolVersionMinor)
+		if reqMajor == 1 && reqMinor >= 0 && reqMinor < 4 {
+			minor = uint32(reqMinor) // Respond with the client's version if supported
+		}
+	}
+
+	var items []Item
+	if request.Tag != tagRequestMessage || request.Type != TypeStructure {
+		items = append(items, failure(0, nil, &opError{reasonInvalidMessage, "invalid request message"}))
+	} else {
+		batch := request.Children(tagBatchItem)
+		if len(batch) == 0 {
+			items = append(items, failure(0, nil, &opError{reasonInvalidMessage, "request contains no batch item"}))
+		}
+		for _, item := range batch {
+			items = append(items, s.handleBatchItem(state, remoteAddr, item))
+		}
+	}
+
+	header = structure(tagResponseHeader,
+		structure(tagProtocolVersion,
+			Item{Tag: tagProtocolVersionMajor, Type: TypeInteger, Value: int32(major)},
+			Item{Tag: tagProtocolVersionMinor, Type: TypeInteger, Value: int32(minor)},
+		),
+		Item{Tag: tagTimeStamp, Type: TypeDateTime, Value: time.Now().UTC()},
+		Item{Tag: tagBatchCount, Type: TypeInteger, Value: int32(len(items))},
+	)
+	return structure(tagResponseMessage, append([]Item{header}, items...)...)
+}
+
+func (s *Server) handleBatchItem(state *tls.ConnectionState, remoteAddr net.Addr, item Item) Item {
+	var batchID []byte
+	if id, ok := item.Child(tagUniqueBatchItemID); ok {
+		batchID, _ = id.Value.([]byte)
+	}
+	operation, ok := enumOf(item, tagOperation)
+	if !ok {
+		return failure(0, batchID, &opError{reasonInvalidMessage, "missing operation"})
+	}
+	payload, _ := item.Child(tagRequestPayload)
+
+	var (
+		response []Item
+		err      *opError
+	)
+	switch operation {
+	case opCreate:
+		response, err = s.create(state, remoteAddr, payload)
+	case opGet:
+		response, err = s.get(state, remoteAddr, payload)
+	case opDestroy:
+		response, err = s.destroy(state, remoteAddr, payload)
+	case opDiscoverVersions:
+		response = discoverVersions()
+	default:
+		err = &opError{reasonOperationNotSupported, "operation not supported"}
+	}
+	if err != nil {
+		return failure(operation, batchID, err)
+	}
+
+	items := []Item{{Tag: tagOperation, Type: TypeEnumeration, Value: operation}}
+	if batchID != nil {
+		items = append(items, Item{Tag: tagUniqueBatchItemID, Type: TypeByteString, Value: batchID})
+	}
+	items = append(items,
+		Item{Tag: tagResultStatus, Type: TypeEnumeration, Value: statusSuccess},
+		structure(tagResponsePayload, response...),
+	)
+	return structure(tagBatchItem, items...)
+}
+
+// create creates a new 256 bit AES key. The key name
+// is the value of the Name attribute. If the client
+// does not provide a name, create generates a random
+// name.
+func (s *Server) create(state *tls.ConnectionState, remoteAddr net.Addr, payload Item) ([]Item, *opError) {
+	if objectType, _ := enumOf(payload, tagObjectType); objectType != objectTypeSymmetricKey {
+		return nil, &opError{reasonInvalidField, "only symmetric keys are supported"}
+	}
+
+	var name string
+	template, _ := payload.Child(tagTemplateAttribute)
+	for _, attribute := range template.Children(tagAttribute) {
+		attrName, _ := attribute.Child(tagAttributeName)
+		value, _ := attribute.Child(tagAttributeValue)
+		switch attrName.Value {
+		case "Cryptographic Algorithm":
+			if algorithm, _ := value.Value.(uint32); algorithm != algorithmAES {
+				return nil, &opError{reasonInvalidField, "only AES keys are supported"}
+			}
+		case "Cryptographic Length":
+			if length, _ := value.Value.(int32); length != 256 {
+				return nil, &opError{reasonInvalidField, "only 256 bit keys are supported"}
+			}
+		case "Name":
+			if nameValue, ok := value.Child(tagNameValue); ok {
+				name, _ = nameValue.Value.(string)
+			}
+		}
+	}
+	if name == "" {
+		random, err := sioutil.Random(16)
+		if err != nil {
+			return nil, &opError{reasonGeneralFailure, "failed to generate key name"}
+		}
+		name = hex.EncodeToString(random)
+	}
+
+	req, err := s.authorize(state, remoteAddr, "/v1/key/create/", name)
+	if err != nil {
+		return nil, err
+	}
+	var key secret.Secret
+	random, rErr := s.Store.Random(len(key))
+	if rErr != nil {
+		return nil, &opError{reasonGeneralFailure, "failed to generate key"}
+	}
+	copy(key[:], random)
+	secret.Wipe(random)
+	defer key.Destroy()
+
+	keyName := s.keyName(req, name)
+	if s.Roles.Quotas != nil {
+		if err := s.Roles.Quotas.Reserve(keyName, auth.Identify(req, s.Roles.Identify)); err != nil {
+			return nil, toOpError(err)
+		}
+	}
+	if s.Lock != nil {
+		lock := s.Lock(keyName)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Store.Create(ctx, keyName, key); err != nil {
+		if s.Roles.Quotas != nil {
+			s.Roles.Quotas.Release(keyName)
+		}
+		return nil, toOpError(err)
+	}
+	if s.Roles.Quotas != nil {
+		if err := s.Roles.Quotas.Save(); err != nil {
+			return nil, toOpError(err)
+		}
+	}
+	return []Item{
+		{Tag: tagObjectType, Type: TypeEnumeration, Value: objectTypeSymmetricKey},
+		{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: name},
+	}, nil
+}
+
+// get returns the raw key material of the key.
+func (s *Server) get(state *tls.ConnectionState, remoteAddr net.Addr, payload Item) ([]Item, *opError) {
+	name, ok := textOf(payload, tagUniqueIdentifier)
+	if !ok {
+		return nil, &opError{reasonMissingData, "missing unique identifier"}
+	}
+	if format, ok := enumOf(payload, tagKeyFormatType); ok && format != keyFormatRaw {
+		return nil, &opError{reasonInvalidField, "only the raw key format is supported"}
+	}
+	req, err := s.authorize(state, remoteAddr, "/v1/key/export/", name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, sErr := s.Store.Get(ctx, s.keyName(req, name))
+	if sErr != nil {
+		return nil, toOpError(sErr)
+	}
+	defer key.Destroy()
+
+	return []Item{
+		{Tag: tagObjectType, Type: TypeEnumeration, Value: objectTypeSymmetricKey},
+		{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: name},
+		structure(tagSymmetricKey,
+			structure(tagKeyBlock,
+				Item{Tag: tagKeyFormatType, Type: TypeEnumeration, Value: keyFormatRaw},
+				structure(tagKeyValue,
+					Item{Tag: tagKeyMaterial, Type: TypeByteString, Value: append([]byte{}, key[:]...)},
+				),
+				Item{Tag: tagCryptographicAlgorithm, Type: TypeEnumeration, Value: algorithmAES},
+				Item{Tag: tagCryptographicLength, Type: TypeInteger, Value: int32(8 * len(key))},
+			),
+		),
+	}, nil
+}
+
+// destroy deletes the key.
+func (s *Server) destroy(state *tls.ConnectionState, remoteAddr net.Addr, payload Item) ([]Item, *opError) {
+	name, ok := textOf(payload, tagUniqueIdentifier)
+	if !ok {
+		return nil, &opError{reasonMissingData, "missing unique identifier"}
+	}
+	req, err := s.authorize(state, remoteAddr, "/v1/key/delete/", name)
+	if err != nil {
+		return nil, err
+	}
+
+	keyName := s.keyName(req, name)
+	if s.Lock != nil {
+		lock := s.Lock(keyName)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Store.Delete(ctx, keyName); err != nil {
+		return nil, toOpError(err)
+	}
+	if s.Roles.Quotas != nil {
+		s.Roles.Quotas.Release(keyName)
+		if err := s.Roles.Quotas.Save(); err != nil {
+			return nil, toOpError(err)
+		}
+	}
+	return []Item{
+		{Tag: tagUniqueIdentifier, Type: TypeTextString, Value: name},
+	}, nil
+}
+
+// discoverVersions returns the KMIP protocol
+// versions supported by the server.
+func discoverVersions() []Item {
+	var versions []Item
+	for minor := int32(4); minor >= 0; minor-- {
+		versions = append(versions, structure(tagProtocolVersion,
+			Item{Tag: tagProtocolVersionMajor, Type: TypeInteger, Value: int32(1)},
+			Item{Tag: tagProtocolVersionMinor, Type: TypeInteger, Value: minor},
+		))
+	}
+	return versions
+}
+
+// authorize verifies that the client identity is allowed
+// to perform the operation on the named key. Therefore,
+// it creates an HTTP request for the corresponding API
+// and verifies it using the server roles.
+//
+// It returns the HTTP request on success such that the
+// caller can compute the client identity.
+func (s *Server) authorize(state *tls.ConnectionState, remoteAddr net.Addr, api, name string) (*http.Request, *opError) {
+	if name == "" || path.Base(name) != name || name == "." || name == ".." {
+		return nil, &opError{reasonInvalidField, "invalid unique identifier"}
+	}
+	req := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: api + name},
+		Header:     http.Header{},
+		RemoteAddr: remoteAddr.String(),
+		TLS:        state,
+	}
+	if err := s.Roles.Verify(req); err != nil {
+		return nil, toOpError(err)
+	}
+	return req, nil
+}
+
+// keyName returns the name of the key at the key store.
+// Keys of tenant identities are within the tenant namespace.
+func (s *Server) keyName(req *http.Request, name string) string {
+	if s.Roles.Tenants != nil {
+		if tenant, ok := s.Roles.Tenants.Lookup(auth.Identify(req, s.Roles.Identify)); ok {
+			return s.Roles.Tenants.KeyName(tenant, name)
+		}
+	}
+	return name
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		s.ErrorLog.Printf(format, v...)
+	}
+}
+
+// opError is a failed KMIP operation.
+type opError struct {
+	Reason  uint32
+	Message string
+}
+
+// toOpError converts err to a KMIP operation error.
+func toOpError(err error) *opError {
+	switch err {
+	case kes.ErrKeyNotFound:
+		return &opError{reasonItemNotFound, err.Error()}
+	case kes.ErrKeyExists:
+		return &opError{reasonIllegalOperation, err.Error()}
+	case kes.ErrNotAllowed, kes.ErrIdentityExpired, kes.ErrQuotaExceeded:
+		return &opError{reasonPermissionDenied, err.Error()}
+	}
+	if kesErr, ok := err.(kes.Error); ok && kesErr.Status() < http.StatusInternalServerError {
+		return &opError{reasonInvalidField, kesErr.Error()}
+	}
+	return &opError{reasonGeneralFailure, "internal server error"}
+}
+
+// failure returns a batch item response for the
+// failed operation.
+func failure(operation uint32, batchID []byte, err *opError) Item {
+	var items []Item
+	if operation != 0 {
+		items = append(items, Item{Tag: tagOperation, Type: TypeEnumeration, Value: operation})
+	}
+	if batchID != nil {
+		items = append(items, Item{Tag: tagUniqueBatchItemID, Type: TypeByteString, Value: batchID})
+	}
+	items = append(items,
+		Item{Tag: tagResultStatus, Type: TypeEnumeration, Value: statusOperationFailed},
+		Item{Tag: tagResultReason, Type: TypeEnumeration, Value: err.Reason},
+		Item{Tag: tagResultMessage, Type: TypeTextString, Value: err.Message},
+	)
+	return structure(tagBatchItem, items...)
+}
+
+func structure(tag Tag, children ...Item) Item {
+	if children == nil {
+		children = []Item{}
+	}
+	return Item{Tag: tag, Type: TypeStructure, Value: children}
+}
+
+func enumOf(item Item, tag Tag) (uint32, bool) {
+	child, ok := item.Child(tag)
+	if !ok || child.Type != TypeEnumeration {
+		return 0, false
+	}
+	return child.Value.(uint32), true
+}
+
+func int32Of(item Item, tag Tag) (int32, bool) {
+	child, ok := item.Child(tag)
+	if !ok || child.Type != TypeInteger {
+		return 0, false
+	}
+	return child.Value.(int32), true
+}
+
+func textOf(item Item, tag Tag) (string, bool) {
+	child, ok := item.Child(tag)
+	if !ok || child.Type != TypeTextString {
+		return "", false
+	}
+	return child.Value.(string), true
+}
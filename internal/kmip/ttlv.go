@@ -0,0 +1,306 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package kmip implements a KMIP server that serves
+// a subset of the KMIP 1.x operations using the KES
+// key store.
+package kmip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Tag identifies a KMIP item - e.g. the operation of a
+// request or the unique identifier of an object. Tags are
+// 24 bit values.
+type Tag uint32
+
+// Type is the type of a KMIP item value.
+type Type byte
+
+// The KMIP item types.
+const (
+	TypeStructure   Type = 0x01
+	TypeInteger     Type = 0x02
+	TypeLongInteger Type = 0x03
+	TypeBigInteger  Type = 0x04
+	TypeEnumeration Type = 0x05
+	TypeBoolean     Type = 0x06
+	TypeTextString  Type = 0x07
+	TypeByteString  Type = 0x08
+	TypeDateTime    Type = 0x09
+	TypeInterval    Type = 0x0A
+)
+
+// Item is a KMIP item encoded as TTLV (tag, type,
+// length, value).
+//
+// The Go type of the value depends on the item type:
+//   TypeStructure:   []Item
+//   TypeInteger:     int32
+//   TypeLongInteger: int64
+//   TypeBigInteger:  []byte (two's complement, big endian)
+//   TypeEnumeration: uint32
+//   TypeBoolean:     bool
+//   TypeTextString:  string
+//   TypeByteString:  []byte
+//   TypeDateTime:    time.Time
+//   TypeInterval:    uint32
+type Item struct {
+	Tag   Tag
+	Type  Type
+	Value interface{}
+}
+
+// Child returns the first child item with the given
+// tag if i is a structure.
+func (i Item) Child(tag Tag) (Item, bool) {
+	children, _ := i.Value.([]Item)
+	for _, child := range children {
+		if child.Tag == tag {
+			return child, true
+		}
+	}
+	return Item{}, false
+}
+
+// Children returns all child items with the given
+// tag if i is a structure.
+func (i Item) Children(tag Tag) []Item {
+	var items []Item
+	children, _ := i.Value.([]Item)
+	for _, child := range children {
+		if child.Tag == tag {
+			items = append(items, child)
+		}
+	}
+	return items
+}
+
+// MarshalBinary returns the TTLV encoding of i.
+func (i Item) MarshalBinary() ([]byte, error) { return i.append(nil) }
+
+// UnmarshalBinary decodes the TTLV-encoded item. The data
+// must contain exactly one item.
+func (i *Item) UnmarshalBinary(data []byte) error {
+	item, rest, err := decodeItem(data, 0)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errMalformed
+	}
+	*i = item
+	return nil
+}
+
+// ReadItem reads one TTLV-encoded item from r. It returns
+// an error if the encoded item is larger than maxSize
+// bytes.
+func ReadItem(r io.Reader, maxSize int) (Item, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Item{}, err
+	}
+	size := 8 + padLength(binary.BigEndian.Uint32(header[4:]))
+	if size > uint64(maxSize) {
+		return Item{}, fmt.Errorf("kmip: message exceeds %d bytes", maxSize)
+	}
+
+	data := make([]byte, size)
+	copy(data, header[:])
+	if _, err := io.ReadFull(r, data[8:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Item{}, err
+	}
+
+	var item Item
+	if err := item.UnmarshalBinary(data); err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// maxDepth is the max. nesting depth of structures.
+// KMIP messages are not deeply nested. Therefore, we
+// reject anything deeper to bound the decoding work.
+const maxDepth = 16
+
+var errMalformed = errors.New("kmip: malformed TTLV encoding")
+
+func decodeItem(b []byte, depth int) (Item, []byte, error) {
+	if len(b) < 8 || depth > maxDepth {
+		return Item{}, nil, errMalformed
+	}
+	var (
+		tag    = Tag(uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]))
+		typ    = Type(b[3])
+		length = binary.BigEndian.Uint32(b[4:8])
+	)
+	b = b[8:]
+	if padLength(length) > uint64(len(b)) {
+		return Item{}, nil, errMalformed
+	}
+	value, rest := b[:length], b[padLength(length):]
+
+	item := Item{Tag: tag, Type: typ}
+	switch typ {
+	case TypeStructure:
+		children := []Item{}
+		for len(value) > 0 {
+			child, remaining, err := decodeItem(value, depth+1)
+			if err != nil {
+				return Item{}, nil, err
+			}
+			children = append(children, child)
+			value = remaining
+		}
+		item.Value = children
+	case TypeInteger:
+		if length != 4 {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = int32(binary.BigEndian.Uint32(value))
+	case TypeLongInteger:
+		if length != 8 {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = int64(binary.BigEndian.Uint64(value))
+	case TypeBigInteger:
+		if length == 0 || length%8 != 0 {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = append([]byte{}, value...)
+	case TypeEnumeration, TypeInterval:
+		if length != 4 {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = binary.BigEndian.Uint32(value)
+	case TypeBoolean:
+		if length != 8 {
+			return Item{}, nil, errMalformed
+		}
+		switch binary.BigEndian.Uint64(value) {
+		case 0:
+			item.Value = false
+		case 1:
+			item.Value = true
+		default:
+			return Item{}, nil, errMalformed
+		}
+	case TypeTextString:
+		if !utf8.Valid(value) {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = string(value)
+	case TypeByteString:
+		item.Value = append([]byte{}, value...)
+	case TypeDateTime:
+		if length != 8 {
+			return Item{}, nil, errMalformed
+		}
+		item.Value = time.Unix(int64(binary.BigEndian.Uint64(value)), 0).UTC()
+	default:
+		return Item{}, nil, errMalformed
+	}
+	return item, rest, nil
+}
+
+func (i Item) append(b []byte) ([]byte, error) {
+	var errType = fmt.Errorf("kmip: invalid value %T for item type %#x", i.Value, i.Type)
+
+	b = append(b, byte(i.Tag>>16), byte(i.Tag>>8), byte(i.Tag), byte(i.Type), 0, 0, 0, 0)
+	header := len(b) - 4 // Offset of the length field
+
+	var value [8]byte
+	switch i.Type {
+	case TypeStructure:
+		children, ok := i.Value.([]Item)
+		if !ok {
+			return nil, errType
+		}
+		for _, child := range children {
+			var err error
+			if b, err = child.append(b); err != nil {
+				return nil, err
+			}
+		}
+	case TypeInteger:
+		v, ok := i.Value.(int32)
+		if !ok {
+			return nil, errType
+		}
+		binary.BigEndian.PutUint32(value[:], uint32(v))
+		b = append(b, value[:4]...)
+	case TypeLongInteger:
+		v, ok := i.Value.(int64)
+		if !ok {
+			return nil, errType
+		}
+		binary.BigEndian.PutUint64(value[:], uint64(v))
+		b = append(b, value[:]...)
+	case TypeBigInteger:
+		v, ok := i.Value.([]byte)
+		if !ok || len(v) == 0 || len(v)%8 != 0 {
+			return nil, errType
+		}
+		b = append(b, v...)
+	case TypeEnumeration, TypeInterval:
+		v, ok := i.Value.(uint32)
+		if !ok {
+			return nil, errType
+		}
+		binary.BigEndian.PutUint32(value[:], v)
+		b = append(b, value[:4]...)
+	case TypeBoolean:
+		v, ok := i.Value.(bool)
+		if !ok {
+			return nil, errType
+		}
+		if v {
+			value[7] = 1
+		}
+		b = append(b, value[:]...)
+	case TypeTextString:
+		v, ok := i.Value.(string)
+		if !ok {
+			return nil, errType
+		}
+		b = append(b, v...)
+	case TypeByteString:
+		v, ok := i.Value.([]byte)
+		if !ok {
+			return nil, errType
+		}
+		b = append(b, v...)
+	case TypeDateTime:
+		v, ok := i.Value.(time.Time)
+		if !ok {
+			return nil, errType
+		}
+		binary.BigEndian.PutUint64(value[:], uint64(v.Unix()))
+		b = append(b, value[:]...)
+	default:
+		return nil, errType
+	}
+
+	length := uint32(len(b) - header - 4)
+	binary.BigEndian.PutUint32(b[header:], length)
+	for n := padLength(length) - uint64(length); n > 0; n-- {
+		b = append(b, 0)
+	}
+	return b, nil
+}
+
+// padLength returns the length of an item
+// value including the padding to a multiple
+// of 8 bytes.
+func padLength(length uint32) uint64 { return (uint64(length) + 7) &^ 7 }
@@ -5,10 +5,13 @@
 package log
 
 import (
+	"encoding/json"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,6 +30,9 @@ type SystemLog struct {
 	lock   sync.Mutex
 	output []io.Writer
 	logger *log.Logger
+
+	level  Level            // The min. level of structured records
+	levels map[string]Level // The min. level per component
 }
 
 // NewLogger creates a new SystemLog. The out variable sets the
@@ -91,9 +97,22 @@ type AuditResponseWriter struct {
 
 	URL           url.URL      // The request URL
 	Identity      kes.Identity // The request X.509 identity
+	IP            net.IP       // The IP address of the client
 	RequestHeader http.Header  // The request headers
 	Time          time.Time    // The time when we receive the request
 
+	// Approvers are the identities that have approved
+	// the request, if it required approval.
+	Approvers []kes.Identity
+
+	// ErrorCode is the machine-readable code of
+	// the error sent to the client, if any.
+	ErrorCode string
+
+	// Replay is true if the response is replayed
+	// for a retried request. See: kes.AuditEventResponse
+	Replay bool
+
 	Logger *log.Logger
 
 	sentHeader bool // Set to true on first WriteHeader
@@ -109,8 +128,35 @@ func (w *AuditResponseWriter) WriteHeader(statusCode int) {
 		w.sentHeader = true
 
 		now := time.Now().UTC()
-		const format = `{"time":"%s","request":{"path":"%s","identity":"%s"},"response":{"code":%d, "time":%d}}`
-		w.Logger.Printf(format, now.Format(time.RFC3339), w.URL.Path, w.Identity, statusCode, now.Sub(w.Time.UTC()))
+		api, key := splitAPIPath(w.URL.Path)
+		var ip string
+		if w.IP != nil {
+			ip = w.IP.String()
+		}
+		var approvers []string
+		for _, approver := range w.Approvers {
+			approvers = append(approvers, approver.String())
+		}
+		event, err := json.Marshal(kes.AuditEvent{
+			Time: now,
+			Request: kes.AuditEventRequest{
+				Path:      w.URL.Path,
+				API:       api,
+				Key:       key,
+				Identity:  w.Identity.String(),
+				IP:        ip,
+				Approvers: approvers,
+			},
+			Response: kes.AuditEventResponse{
+				StatusCode: statusCode,
+				Error:      w.ErrorCode,
+				Time:       now.Sub(w.Time.UTC()),
+				Replay:     w.Replay,
+			},
+		})
+		if err == nil {
+			w.Logger.Print(string(event))
+		}
 
 		// Here the following problem can appear:
 		//
@@ -159,6 +205,30 @@ func (w *AuditResponseWriter) Flush() {
 	}
 }
 
+// splitAPIPath splits the request URL path into the
+// API and the name of the key the API operates on.
+// For example:
+//  /v1/key/create/my-key => (/v1/key/create, my-key)
+//  /v1/policy/list/*     => (/v1/policy/list, "")
+//  /v1/log/audit/trace   => (/v1/log/audit/trace, "")
+//  /version              => (/version, "")
+func splitAPIPath(p string) (api, key string) {
+	if !strings.HasPrefix(p, "/v1/") || strings.HasPrefix(p, "/v1/log/") { // The log APIs have no arguments
+		return p, ""
+	}
+
+	// All v1 APIs have the form: /v1/<group>/<operation>/<args...>
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 4)
+	if len(parts) < 4 {
+		return p, ""
+	}
+	api = "/" + strings.Join(parts[:3], "/")
+	if parts[1] == "key" {
+		key = parts[3]
+	}
+	return api, key
+}
+
 // A FlushWriter wraps an io.Writer and performs
 // a flush operation after every write call if the
 // wrapped io.Writer implements http.Flusher.
@@ -0,0 +1,270 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ChainWriter is an io.Writer that makes a stream of
+// JSON log records tamper-evident.
+//
+// It adds the SHA-256 hash of the previous record to
+// each record:
+//  {...,"prev":"<hex-hash>"}
+// Therefore, modifying, inserting or removing a record
+// breaks the hash chain.
+//
+// In addition, it periodically writes a signature record
+// that contains an Ed25519 signature of the hash of the
+// previous record:
+//  {"time":"...","signature":"<base64-signature>","prev":"<hex-hash>"}
+// The signature proves that the chain up to this record
+// has been produced by the holder of the signing key.
+//
+// The ChainWriter expects that each Write call contains
+// exactly one JSON object - optionally terminated by a
+// newline - as written by a log.Logger.
+type ChainWriter struct {
+	// Writer is the destination of the chained records.
+	Writer io.Writer
+
+	// Key is the Ed25519 private key used to sign
+	// the chain. If nil, the chain is not signed.
+	Key ed25519.PrivateKey
+
+	// SignInterval is the number of records after
+	// which a signature record is written. If 0,
+	// it defaults to 100.
+	SignInterval int
+
+	// Prev is the hash of the record preceding the
+	// first record written by the ChainWriter. It
+	// should be set when appending to an existing
+	// chain - e.g. to a log file after a restart.
+	// See: LastRecordHash
+	//
+	// It must not be modified once the ChainWriter
+	// has been used.
+	Prev [sha256.Size]byte
+
+	lock     sync.Mutex
+	unsigned int
+}
+
+var _ io.Writer = (*ChainWriter)(nil)
+
+// Write adds the hash of the previous record to the
+// JSON object p and writes it to the underlying Writer.
+// It may write an additional signature record.
+func (w *ChainWriter) Write(p []byte) (int, error) {
+	record := bytes.TrimSpace(p)
+	if len(record) < 2 || record[0] != '{' || record[len(record)-1] != '}' {
+		return 0, errors.New("log: record is not a JSON object")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.writeRecord(record[:len(record)-1]); err != nil {
+		return 0, err
+	}
+
+	interval := w.SignInterval
+	if interval == 0 {
+		interval = 100
+	}
+	if w.unsigned++; w.Key != nil && w.unsigned >= interval {
+		signature := ed25519.Sign(w.Key, w.Prev[:])
+		signRecord := fmt.Sprintf(`{"time":"%s","signature":"%s"`, time.Now().UTC().Format(time.RFC3339), base64.StdEncoding.EncodeToString(signature))
+		if err := w.writeRecord([]byte(signRecord)); err != nil {
+			return 0, err
+		}
+		w.unsigned = 0
+	}
+	return len(p), nil
+}
+
+// writeRecord appends the hash of the previous record to
+// the JSON object - without its closing '}' - and writes
+// it to the underlying Writer.
+//
+// The caller must hold the lock.
+func (w *ChainWriter) writeRecord(object []byte) error {
+	var buffer bytes.Buffer
+	buffer.Write(object)
+	if len(object) > 1 { // Object is not empty - i.e. not just '{'
+		buffer.WriteByte(',')
+	}
+	buffer.WriteString(`"prev":"`)
+	buffer.WriteString(hex.EncodeToString(w.Prev[:]))
+	buffer.WriteString(`"}`)
+
+	w.Prev = sha256.Sum256(buffer.Bytes())
+	buffer.WriteByte('\n')
+	_, err := w.Writer.Write(buffer.Bytes())
+	return err
+}
+
+// ChainReport summarizes the result of verifying
+// a record chain.
+type ChainReport struct {
+	Records    int // Number of records - excluding signature records
+	Signatures int // Number of valid signature records
+	Unsigned   int // Number of records after the last signature record
+}
+
+// VerifyOptions control how VerifyChain verifies
+// a record chain.
+type VerifyOptions struct {
+	// Partial allows the first record to refer to a record
+	// that is not part of the stream - e.g. because older
+	// log files have been removed after log rotation.
+	// Otherwise, the stream must start at the beginning of
+	// the chain.
+	//
+	// Removing a prefix of the chain cannot be detected
+	// when verifying a partial chain.
+	Partial bool
+
+	// AllowUnsigned allows a chain without any signature
+	// record. Otherwise, a chain with records but without
+	// signature is rejected since anyone can produce such
+	// a chain.
+	AllowUnsigned bool
+}
+
+// VerifyChain reads a stream of chained records produced by
+// a ChainWriter and verifies the hash chain and all signature
+// records using the Ed25519 public key.
+//
+// The first record must be the beginning of the chain, unless
+// the options allow a partial chain. All other records must
+// refer to their predecessor. The chain must contain at least
+// one signature record, unless the options allow unsigned
+// chains.
+//
+// VerifyChain returns an error describing the first record that
+// has been modified, inserted or removed.
+//
+// The records after the last signature record, if any, are not
+// protected by a signature. Anyone can modify or remove them
+// without breaking the chain. The number of such records is
+// reported as ChainReport.Unsigned.
+func VerifyChain(r io.Reader, key ed25519.PublicKey, opts VerifyOptions) (ChainReport, error) {
+	type Record struct {
+		Prev      string `json:"prev"`
+		Signature []byte `json:"signature"`
+	}
+
+	var (
+		report  ChainReport
+		prev    [sha256.Size]byte // The first record of a chain refers to the zero hash
+		line    int
+		first   = true
+		scanner = bufio.NewScanner(r)
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return report, fmt.Errorf("log: line %d: invalid record: %v", line, err)
+		}
+		recordPrev, err := hex.DecodeString(record.Prev)
+		if err != nil || len(recordPrev) != sha256.Size {
+			return report, fmt.Errorf("log: line %d: invalid previous record hash", line)
+		}
+		if first && !opts.Partial && !bytes.Equal(recordPrev, prev[:]) {
+			return report, fmt.Errorf("log: line %d: chain does not start with the first record - records have been removed or the log is partial", line)
+		}
+		if !first && !bytes.Equal(recordPrev, prev[:]) {
+			return report, fmt.Errorf("log: line %d: hash chain is broken - the previous record has been modified, inserted or removed", line)
+		}
+
+		if record.Signature != nil {
+			if !ed25519.Verify(key, recordPrev, record.Signature) {
+				return report, fmt.Errorf("log: line %d: invalid signature", line)
+			}
+			report.Signatures++
+			report.Unsigned = 0
+		} else {
+			report.Records++
+			report.Unsigned++
+		}
+		prev, first = sha256.Sum256(scanner.Bytes()), false
+	}
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+	if report.Records > 0 && report.Signatures == 0 && !opts.AllowUnsigned {
+		return report, errors.New("log: chain is not signed - no signature record covers the records")
+	}
+	return report, nil
+}
+
+// LastRecordHash returns the hash of the last record
+// of the chain stored in the given file. It returns a
+// zero hash if the file does not exist or is empty.
+func LastRecordHash(filename string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return hash, nil
+	}
+	if err != nil {
+		return hash, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return hash, err
+	}
+
+	// A record is at most a few KiB large. Therefore, we
+	// only read the end of the file to find the last record.
+	const MaxRecordSize = 1 << 20
+	offset := stat.Size() - MaxRecordSize
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return hash, err
+	}
+	tail, err := ioutil.ReadAll(file)
+	if err != nil {
+		return hash, err
+	}
+
+	tail = bytes.TrimRight(tail, "\r\n")
+	if len(tail) == 0 {
+		return hash, nil
+	}
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	} else if offset > 0 {
+		return hash, errors.New("log: last record is too large")
+	}
+	return sha256.Sum256(tail), nil
+}
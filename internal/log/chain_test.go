@@ -0,0 +1,110 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChainWriter(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	w := &ChainWriter{Writer: &buffer, Key: private, SignInterval: 3}
+	for i := 0; i < 10; i++ {
+		if _, err = fmt.Fprintf(w, `{"event":%d}`+"\n", i); err != nil {
+			t.Fatalf("Failed to write record %d: %v", i, err)
+		}
+	}
+
+	report, err := VerifyChain(bytes.NewReader(buffer.Bytes()), public, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify chain: %v", err)
+	}
+	if report.Records != 10 || report.Signatures != 3 || report.Unsigned != 1 {
+		t.Fatalf("Invalid report: got %+v", report)
+	}
+
+	tampered := bytes.Replace(buffer.Bytes(), []byte(`{"event":4,`), []byte(`{"event":5,`), 1)
+	if _, err = VerifyChain(bytes.NewReader(tampered), public, VerifyOptions{}); err == nil {
+		t.Fatal("Modified record has not been detected")
+	}
+
+	lines := bytes.SplitAfter(buffer.Bytes(), []byte("\n"))
+	removed := bytes.Join(append(lines[:2:2], lines[3:]...), nil)
+	if _, err = VerifyChain(bytes.NewReader(removed), public, VerifyOptions{}); err == nil {
+		t.Fatal("Removed record has not been detected")
+	}
+
+	// Removing the first records can only be detected
+	// if the chain is not partial.
+	truncated := bytes.Join(lines[2:], nil)
+	if _, err = VerifyChain(bytes.NewReader(truncated), public, VerifyOptions{}); err == nil {
+		t.Fatal("Removed first records have not been detected")
+	}
+	if _, err = VerifyChain(bytes.NewReader(truncated), public, VerifyOptions{Partial: true}); err != nil {
+		t.Fatalf("Failed to verify partial chain: %v", err)
+	}
+
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err = VerifyChain(bytes.NewReader(buffer.Bytes()), otherKey, VerifyOptions{}); err == nil {
+		t.Fatal("Signature of a different key has been accepted")
+	}
+}
+
+func TestLastRecordHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-chain")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "audit.log")
+	if hash, err := LastRecordHash(filename); err != nil || hash != [32]byte{} {
+		t.Fatalf("Non-existing file: got hash %x and error %v", hash, err)
+	}
+
+	file := &RotatingFile{Path: filename}
+	w := &ChainWriter{Writer: file}
+	fmt.Fprintln(w, `{"event":0}`)
+	fmt.Fprintln(w, `{"event":1}`)
+	file.Close()
+
+	hash, err := LastRecordHash(filename)
+	if err != nil {
+		t.Fatalf("Failed to compute last record hash: %v", err)
+	}
+	if hash != w.Prev {
+		t.Fatalf("Hash mismatch: got %x - want %x", hash, w.Prev)
+	}
+
+	// Appending to the chain after a restart must
+	// produce a single valid chain.
+	file = &RotatingFile{Path: filename}
+	w = &ChainWriter{Writer: file, Prev: hash}
+	fmt.Fprintln(w, `{"event":2}`)
+	file.Close()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if _, err := VerifyChain(bytes.NewReader(data), nil, VerifyOptions{}); err == nil {
+		t.Fatal("Unsigned chain has been accepted")
+	}
+	if report, err := VerifyChain(bytes.NewReader(data), nil, VerifyOptions{AllowUnsigned: true}); err != nil || report.Records != 3 {
+		t.Fatalf("Failed to verify chain: got %+v and error %v", report, err)
+	}
+}
@@ -35,6 +35,7 @@ type JSONWriter struct {
 var (
 	_ io.StringWriter = (*JSONWriter)(nil)
 	_ http.Flusher    = (*JSONWriter)(nil)
+	_ RecordWriter    = (*JSONWriter)(nil)
 )
 
 // NewJSONWriter returns a new JSONWriter that
@@ -61,8 +62,12 @@ func (w JSONWriter) Write(p []byte) (int, error) {
 func (w JSONWriter) WriteString(s string) (n int, err error) {
 	n = len(s) // We have to return len(s) - not the len of the JSON object.
 
+	// An unstructured message is encoded as kes.ErrorEvent
+	// with just a message - i.e. without a zero time.
 	var (
-		event   = kes.ErrorEvent{Message: s}
+		event = struct {
+			Message string `json:"message"`
+		}{Message: s}
 		newline = strings.HasSuffix(event.Message, "\n")
 	)
 	if newline {
@@ -92,6 +97,32 @@ func (w JSONWriter) WriteString(s string) (n int, err error) {
 	return n, nil
 }
 
+// WriteRecord writes the record as JSON object:
+//  {
+//    "message":   "<message>",
+//    "time":      "<time>",
+//    "level":     "<level>",
+//    "component": "<component>",
+//    "fields":    { "<key>": "<value>", ... }
+//  }
+func (w JSONWriter) WriteRecord(r Record) error {
+	json, err := json.Marshal(kes.ErrorEvent{
+		Message:   r.Message,
+		Time:      r.Time,
+		Level:     r.Level.String(),
+		Component: r.Component,
+		Fields:    r.Fields,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = w.Writer.Write(append(json, '\n')); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
 func (w JSONWriter) Flush() {
 	if w.Flusher != nil {
 		w.Flusher.Flush()
@@ -0,0 +1,236 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Level is the severity of a log record.
+type Level int
+
+// The log levels - from the most to the least verbose.
+// The zero Level is LevelInfo.
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ParseLevel parses s as log level. It accepts
+// "debug", "info", "warn" and "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("log: invalid level '%s'", s)
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Record is a structured log record.
+type Record struct {
+	Time      time.Time
+	Level     Level
+	Component string // The component that emitted the record - e.g. "vault"
+	Message   string
+
+	// Fields are additional key-value pairs - e.g.
+	// the name of the key that could not be fetched.
+	Fields map[string]string
+}
+
+// String returns the record in a human-readable
+// format without the time:
+//   [<LEVEL>] <component>: <message> <key>=<value> ...
+func (r Record) String() string {
+	var s strings.Builder
+	s.WriteString("[" + strings.ToUpper(r.Level.String()) + "] ")
+	if r.Component != "" {
+		s.WriteString(r.Component + ": ")
+	}
+	s.WriteString(r.Message)
+
+	keys := make([]string, 0, len(r.Fields))
+	for key := range r.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := r.Fields[key]
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		s.WriteString(" " + key + "=" + value)
+	}
+	return s.String()
+}
+
+// RecordWriter is implemented by log outputs that
+// handle structured log records themselves - e.g.
+// by encoding them as JSON. All other outputs of a
+// SystemLog receive the records as text.
+type RecordWriter interface {
+	WriteRecord(Record) error
+}
+
+// Logger emits structured, leveled log records of one
+// component to a SystemLog. The SystemLog decides which
+// records are written based on the level configured for
+// the component. See: SystemLog.SetLevel
+//
+// A nil Logger writes records of level info or above
+// via the log package's standard logger.
+type Logger struct {
+	component string
+	log       *SystemLog
+}
+
+// Logger returns a Logger that emits records of the
+// given component - e.g. "vault" - to the SystemLog.
+func (l *SystemLog) Logger(component string) *Logger {
+	return &Logger{component: component, log: l}
+}
+
+// Debug emits a debug record. The fields are
+// alternating keys and values - e.g.:
+//   logger.Debug("key created", "key", name)
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.emit(LevelDebug, msg, fields) }
+
+// Info emits an info record. The fields are
+// alternating keys and values.
+func (l *Logger) Info(msg string, fields ...interface{}) { l.emit(LevelInfo, msg, fields) }
+
+// Warn emits a warning record. The fields are
+// alternating keys and values.
+func (l *Logger) Warn(msg string, fields ...interface{}) { l.emit(LevelWarn, msg, fields) }
+
+// Error emits an error record. The fields are
+// alternating keys and values - e.g.:
+//   logger.Error("failed to fetch key", "key", name, "err", err)
+func (l *Logger) Error(msg string, fields ...interface{}) { l.emit(LevelError, msg, fields) }
+
+func (l *Logger) emit(level Level, msg string, fields []interface{}) {
+	var component string
+	if l != nil {
+		component = l.component
+	}
+	if l == nil || l.log == nil {
+		if level >= LevelInfo {
+			log.Println(newRecord(level, component, msg, fields))
+		}
+		return
+	}
+	if l.log.Enabled(component, level) {
+		l.log.Emit(newRecord(level, component, msg, fields))
+	}
+}
+
+// SetLevel sets the minimum level of records that are
+// written. The levels map component names - e.g. "vault"
+// - to the minimum level of the component's records. It
+// overrides the level for these components.
+//
+// By default, records of level info or above are written.
+// Output written via the *log.Logger of the SystemLog is
+// not affected by any level.
+func (l *SystemLog) SetLevel(level Level, levels map[string]Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.level = level
+	l.levels = make(map[string]Level, len(levels))
+	for component, level := range levels {
+		l.levels[component] = level
+	}
+}
+
+// Enabled reports whether records of the component
+// with the given level are written.
+func (l *SystemLog) Enabled(component string, level Level) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if min, ok := l.levels[component]; ok {
+		return level >= min
+	}
+	return level >= l.level
+}
+
+// Emit writes the record to all outputs. Outputs that
+// implement RecordWriter receive the record itself. All
+// other outputs receive the record as text - formatted
+// like the output of the *log.Logger of the SystemLog.
+//
+// Emit does not check the level of the record.
+// See: Enabled
+func (l *SystemLog) Emit(record Record) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var text []io.Writer
+	for _, out := range l.output {
+		if w, ok := out.(RecordWriter); ok {
+			w.WriteRecord(record)
+		} else {
+			text = append(text, out)
+		}
+	}
+	if len(text) > 0 {
+		log.New(io.MultiWriter(text...), l.logger.Prefix(), l.logger.Flags()).Output(2, record.String())
+	}
+}
+
+// newRecord returns a new record with the current time.
+// The fields are alternating keys and values. A key
+// without value gets the value "<missing>".
+func newRecord(level Level, component, msg string, fields []interface{}) Record {
+	r := Record{
+		Time:      time.Now().UTC(),
+		Level:     level,
+		Component: component,
+		Message:   msg,
+	}
+	if len(fields) > 0 {
+		r.Fields = make(map[string]string, (len(fields)+1)/2)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		if i+1 < len(fields) {
+			r.Fields[key] = fmt.Sprint(fields[i+1])
+		} else {
+			r.Fields[key] = "<missing>"
+		}
+	}
+	return r
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+var parseLevelTests = []struct {
+	Value string
+	Level Level
+	Err   bool
+}{
+	{Value: "debug", Level: LevelDebug},
+	{Value: "info", Level: LevelInfo},
+	{Value: "WARN", Level: LevelWarn},
+	{Value: "error", Level: LevelError},
+	{Value: "", Err: true},
+	{Value: "trace", Err: true},
+}
+
+func TestParseLevel(t *testing.T) {
+	for i, test := range parseLevelTests {
+		level, err := ParseLevel(test.Value)
+		if err == nil && test.Err {
+			t.Fatalf("Test %d: should have failed", i)
+		}
+		if err != nil && !test.Err {
+			t.Fatalf("Test %d: failed to parse level: %v", i, err)
+		}
+		if err == nil && level != test.Level {
+			t.Fatalf("Test %d: got level '%v' - want '%v'", i, level, test.Level)
+		}
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buffer strings.Builder
+	log := NewLogger(&buffer, "", 0)
+
+	logger := log.Logger("vault")
+	logger.Debug("read entry", "key", "my-key")
+	if buffer.Len() != 0 {
+		t.Fatalf("Debug record has been written at default level: %s", buffer.String())
+	}
+	logger.Error("failed to read entry", "key", "my-key", "err", errors.New("not available"))
+	if want := "[ERROR] vault: failed to read entry err=\"not available\" key=my-key\n"; buffer.String() != want {
+		t.Fatalf("Invalid record: got '%s' - want '%s'", buffer.String(), want)
+	}
+
+	buffer.Reset()
+	log.SetLevel(LevelError, map[string]Level{"fs": LevelDebug})
+	logger.Warn("slow response")
+	log.Logger("fs").Debug("created entry", "key", "my-key")
+	if want := "[DEBUG] fs: created entry key=my-key\n"; buffer.String() != want {
+		t.Fatalf("Invalid record: got '%s' - want '%s'", buffer.String(), want)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buffer strings.Builder
+	log := NewLogger(NewJSONWriter(&buffer), "", 0)
+	log.Logger("aws-kms").Warn("retrying request", "attempt", 2)
+
+	var event kes.ErrorEvent
+	if err := json.Unmarshal([]byte(buffer.String()), &event); err != nil {
+		t.Fatalf("Failed to unmarshal error event: %v", err)
+	}
+	if event.Level != "warn" || event.Component != "aws-kms" || event.Message != "retrying request" {
+		t.Fatalf("Invalid error event: got %+v", event)
+	}
+	if event.Fields["attempt"] != "2" {
+		t.Fatalf("Invalid error event fields: got %v", event.Fields)
+	}
+	if event.Time.IsZero() {
+		t.Fatal("Error event has no time")
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var logger *Logger
+	logger.Debug("not written") // Must not panic
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser that appends
+// log events to a file and rotates the file once
+// it exceeds MaxSize bytes.
+//
+// On rotation, the current file gets renamed to
+// <Path>.1, the previous <Path>.1 to <Path>.2 and
+// so on. At most MaxBackups files are kept.
+type RotatingFile struct {
+	// Path is the path of the log file.
+	Path string
+
+	// MaxSize is the max. size of the log file
+	// in bytes. If MaxSize <= 0, the file is
+	// never rotated.
+	MaxSize int64
+
+	// MaxBackups is the max. number of rotated
+	// log files that are kept. If MaxBackups <= 0,
+	// rotated files are removed.
+	MaxBackups int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+var _ io.WriteCloser = (*RotatingFile)(nil)
+
+// Write appends p to the log file. It rotates the
+// log file if writing p would exceed MaxSize.
+//
+// Write never splits p across two log files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens or creates the log file.
+//
+// The caller must hold the lock.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, stat.Size()
+	return nil
+}
+
+// rotate closes the current log file, shifts all
+// backups and opens a new, empty log file.
+//
+// The caller must hold the lock.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.MaxBackups <= 0 {
+		if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", f.Path, f.MaxBackups)) // The oldest backup may not exist
+	for i := f.MaxBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", f.Path, i), fmt.Sprintf("%s.%d", f.Path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.Path, f.Path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return f.open()
+}
+
+// Webhook is an io.Writer that sends each log
+// event as HTTP POST request to an endpoint.
+//
+// Write does not block until the event has been
+// delivered. Instead, events are queued and sent
+// by a background go-routine. If the queue is full,
+// e.g. because the endpoint is not reachable, new
+// events are dropped.
+type Webhook struct {
+	// Endpoint is the URL that receives
+	// the log events.
+	Endpoint string
+
+	// Header contains additional HTTP headers sent
+	// to the endpoint - e.g. for authentication.
+	Header http.Header
+
+	// Client is the HTTP client used to send events.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// MaxQueueSize is the max. number of queued
+	// events. If 0, it defaults to 1024.
+	MaxQueueSize int
+
+	// ErrorLog specifies an optional logger for errors
+	// when log events cannot be delivered.
+	// If nil, logging is done via the log package's
+	// standard logger.
+	ErrorLog *log.Logger
+
+	once  sync.Once
+	queue chan []byte
+}
+
+var _ io.Writer = (*Webhook)(nil)
+
+// Write queues a copy of p for delivery.
+func (w *Webhook) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		size := w.MaxQueueSize
+		if size == 0 {
+			size = 1024
+		}
+		w.queue = make(chan []byte, size)
+		go w.send()
+	})
+
+	event := make([]byte, len(p))
+	copy(event, p)
+	select {
+	case w.queue <- event:
+	default:
+		w.log("log: webhook queue is full: dropping event")
+	}
+	return len(p), nil
+}
+
+func (w *Webhook) send() {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for event := range w.queue {
+		req, err := http.NewRequest(http.MethodPost, w.Endpoint, bytes.NewReader(event))
+		if err != nil {
+			w.log(fmt.Sprintf("log: failed to send event to '%s': %v", w.Endpoint, err))
+			continue
+		}
+		for key, values := range w.Header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			w.log(fmt.Sprintf("log: failed to send event to '%s': %v", w.Endpoint, err))
+			continue
+		}
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			w.log(fmt.Sprintf("log: failed to send event to '%s': %s", w.Endpoint, resp.Status))
+		}
+	}
+}
+
+func (w *Webhook) log(v ...interface{}) {
+	if w.ErrorLog == nil {
+		log.Println(v...)
+	} else {
+		w.ErrorLog.Println(v...)
+	}
+}
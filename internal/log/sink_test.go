@@ -0,0 +1,76 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-log-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := &RotatingFile{
+		Path:       filepath.Join(dir, "audit.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+	}
+	defer file.Close()
+
+	for _, event := range []string{"event-1\n", "event-2\n", "event-3\n", "event-4\n"} {
+		if _, err = file.Write([]byte(event)); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+	}
+
+	var files = map[string]string{
+		"audit.log":   "event-4\n",
+		"audit.log.1": "event-3\n",
+		"audit.log.2": "event-2\n",
+	}
+	for name, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read '%s': %v", name, err)
+		}
+		if string(b) != content {
+			t.Fatalf("Invalid content of '%s': got '%s' - want '%s'", name, b, content)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dir, "audit.log.3")); !os.IsNotExist(err) {
+		t.Fatalf("Rotated file exceeds max. number of backups")
+	}
+}
+
+var splitAPIPathTests = []struct {
+	Path string
+	API  string
+	Key  string
+}{
+	{Path: "/v1/key/create/my-key", API: "/v1/key/create", Key: "my-key"},   // 0
+	{Path: "/v1/key/decrypt/my-key", API: "/v1/key/decrypt", Key: "my-key"}, // 1
+	{Path: "/v1/policy/read/my-policy", API: "/v1/policy/read", Key: ""},    // 2
+	{Path: "/v1/log/audit/trace", API: "/v1/log/audit/trace", Key: ""},      // 3
+	{Path: "/version", API: "/version", Key: ""},                            // 4
+	{Path: "/v1/metrics", API: "/v1/metrics", Key: ""},                      // 5
+}
+
+func TestSplitAPIPath(t *testing.T) {
+	for i, test := range splitAPIPathTests {
+		api, key := splitAPIPath(test.Path)
+		if api != test.API {
+			t.Fatalf("Test %d: got API '%s' - want '%s'", i, api, test.API)
+		}
+		if key != test.Key {
+			t.Fatalf("Test %d: got key '%s' - want '%s'", i, key, test.Key)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The syslog facilities. See: RFC 5424, section 6.2.1
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"audit":    13,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// ParseFacility parses s as syslog facility - e.g.
+// "auth" or "local0" - and returns its numerical code.
+func ParseFacility(s string) (int, error) {
+	facility, ok := facilities[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("log: invalid syslog facility '%s'", s)
+	}
+	return facility, nil
+}
+
+// sdID is the SD-ID of the structured data element of
+// syslog messages. The enterprise number 32473 is the
+// number reserved for documentation. See: RFC 5612
+const sdID = "kes@32473"
+
+// Syslog is an io.Writer that sends each log event as
+// RFC 5424 message to a syslog server - via UDP, TCP or
+// TLS - or to the local syslog daemon.
+//
+// Write does not block until the message has been sent.
+// Instead, messages are queued and sent by a background
+// go-routine. While the syslog server is not reachable,
+// the queue buffers messages and the go-routine tries to
+// reconnect. If the queue is full, new messages are
+// dropped.
+//
+// Syslog sends the fields of JSON-encoded events, like
+// audit events, as structured data. It also implements
+// RecordWriter such that the fields of error log records
+// are sent as structured data as well.
+type Syslog struct {
+	// Network is the network of the syslog server.
+	// Either "udp", "tcp" or "tls". If empty, it
+	// defaults to "udp". If Network and Address are
+	// empty, messages are sent to the local syslog
+	// daemon.
+	Network string
+
+	// Address is the address of the syslog server
+	// - e.g. "127.0.0.1:514".
+	Address string
+
+	// TLSConfig is the TLS client configuration
+	// used if the Network is "tls".
+	TLSConfig *tls.Config
+
+	// Facility is the syslog facility of all messages.
+	// If 0, it defaults to the "auth" facility.
+	// See: ParseFacility
+	Facility int
+
+	// Level is the level of events written via Write.
+	// The level of records written via WriteRecord is
+	// the level of the record.
+	Level Level
+
+	// AppName is the name of the application sending
+	// messages - e.g. "kes".
+	AppName string
+
+	// MsgID identifies the type of messages - e.g.
+	// "audit".
+	MsgID string
+
+	// MaxQueueSize is the max. number of queued
+	// messages. If 0, it defaults to 1024.
+	MaxQueueSize int
+
+	// ErrorLog specifies an optional logger for errors
+	// when messages cannot be sent.
+	// If nil, logging is done via the log package's
+	// standard logger.
+	ErrorLog *log.Logger
+
+	once     sync.Once
+	queue    chan []byte
+	hostname string
+	dropped  uint64 // Number of dropped messages - updated atomically
+}
+
+var (
+	_ io.Writer    = (*Syslog)(nil)
+	_ RecordWriter = (*Syslog)(nil)
+)
+
+// Write queues p as syslog message. If p is a JSON
+// object, its fields are sent as structured data.
+func (s *Syslog) Write(p []byte) (int, error) {
+	s.once.Do(s.start)
+
+	event := bytes.TrimRight(p, "\r\n")
+
+	var params [][2]string
+	if trimmed := bytes.TrimSpace(event); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+
+		var fields map[string]interface{}
+		if decoder.Decode(&fields) == nil {
+			params = flattenJSON("", fields, params)
+		}
+	}
+	s.enqueue(s.format(s.Level, time.Now(), params, string(event)))
+	return len(p), nil
+}
+
+// WriteRecord queues the record as syslog message.
+// The record fields are sent as structured data.
+func (s *Syslog) WriteRecord(r Record) error {
+	s.once.Do(s.start)
+
+	params := make([][2]string, 0, len(r.Fields)+1)
+	if r.Component != "" {
+		params = append(params, [2]string{"component", r.Component})
+	}
+	for key, value := range r.Fields {
+		params = append(params, [2]string{key, value})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i][0] < params[j][0] })
+
+	msg := r.Message
+	if r.Component != "" {
+		msg = r.Component + ": " + msg
+	}
+	s.enqueue(s.format(r.Level, r.Time, params, msg))
+	return nil
+}
+
+// start initializes the queue and starts the go-routine
+// that sends the queued messages.
+func (s *Syslog) start() {
+	if hostname, err := os.Hostname(); err == nil {
+		s.hostname = hostname
+	}
+	size := s.MaxQueueSize
+	if size == 0 {
+		size = 1024
+	}
+	s.queue = make(chan []byte, size)
+	go s.send()
+}
+
+func (s *Syslog) enqueue(msg []byte) {
+	select {
+	case s.queue <- msg:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// format returns the RFC 5424 syslog message:
+//   <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAMS] MSG
+func (s *Syslog) format(level Level, t time.Time, params [][2]string, msg string) []byte {
+	facility := s.Facility
+	if facility == 0 {
+		facility = facilities["auth"]
+	}
+	var severity int
+	switch {
+	case level <= LevelDebug:
+		severity = 7
+	case level == LevelInfo:
+		severity = 6
+	case level == LevelWarn:
+		severity = 4
+	default:
+		severity = 3
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + strconv.Itoa(facility*8+severity) + ">1 ")
+	b.WriteString(t.UTC().Format("2006-01-02T15:04:05.000000Z07:00") + " ")
+	b.WriteString(header(s.hostname, 255) + " ")
+	b.WriteString(header(s.AppName, 48) + " ")
+	b.WriteString(strconv.Itoa(os.Getpid()) + " ")
+	b.WriteString(header(s.MsgID, 32) + " ")
+	if len(params) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + sdID)
+		for _, param := range params {
+			name := header(strings.Map(func(r rune) rune {
+				if r == '=' || r == ']' || r == '"' {
+					return -1
+				}
+				return r
+			}, param[0]), 32)
+			if name == "-" {
+				continue
+			}
+			b.WriteString(" " + name + `="`)
+			for _, r := range param[1] {
+				if r == '"' || r == '\\' || r == ']' {
+					b.WriteByte('\\')
+				}
+				b.WriteRune(r)
+			}
+			b.WriteString(`"`)
+		}
+		b.WriteString("]")
+	}
+	if msg != "" {
+		b.WriteString(" \xEF\xBB\xBF" + msg) // The BOM marks msg as UTF-8
+	}
+	return []byte(b.String())
+}
+
+func (s *Syslog) send() {
+	var (
+		conn   net.Conn
+		framed bool // Whether messages are sent with an octet count
+		delay  time.Duration
+	)
+	for msg := range s.queue {
+		for retry := false; ; retry = true {
+			for conn == nil {
+				var err error
+				if conn, framed, err = s.dial(); err != nil {
+					s.log(fmt.Sprintf("log: failed to connect to syslog '%s': %v", s.Address, err))
+
+					// Wait before trying again. Meanwhile,
+					// the queue buffers new messages.
+					if delay = 2 * delay; delay < time.Second {
+						delay = time.Second
+					} else if delay > time.Minute {
+						delay = time.Minute
+					}
+					time.Sleep(delay)
+				}
+			}
+			delay = 0
+			if n := atomic.SwapUint64(&s.dropped, 0); n > 0 {
+				s.log(fmt.Sprintf("log: syslog queue was full: dropped %d messages", n))
+			}
+
+			var err error
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if framed {
+				_, err = conn.Write(append([]byte(strconv.Itoa(len(msg))+" "), msg...))
+			} else {
+				_, err = conn.Write(msg)
+			}
+			if err == nil {
+				break
+			}
+			conn.Close()
+			conn = nil
+
+			// Retry once with a new connection. If the message
+			// cannot be sent even then, it is dropped. Otherwise,
+			// a message that is e.g. too large would block all
+			// other messages.
+			if retry {
+				s.log(fmt.Sprintf("log: failed to send message to syslog '%s': %v", s.Address, err))
+				break
+			}
+		}
+	}
+}
+
+// dial connects to the syslog server. It reports whether
+// messages have to be sent with an octet count to separate
+// them - i.e. when sending via a stream. See: RFC 6587
+func (s *Syslog) dial() (net.Conn, bool, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	switch s.Network {
+	case "":
+		if s.Address == "" {
+			return dialLocal()
+		}
+		conn, err := dialer.Dial("udp", s.Address)
+		return conn, false, err
+	case "udp", "udp4", "udp6":
+		conn, err := dialer.Dial(s.Network, s.Address)
+		return conn, false, err
+	case "tcp", "tcp4", "tcp6":
+		conn, err := dialer.Dial(s.Network, s.Address)
+		return conn, true, err
+	case "tls":
+		config := s.TLSConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+		conn, err := tls.DialWithDialer(dialer, "tcp", s.Address, config)
+		if err != nil {
+			return nil, false, err
+		}
+		return conn, true, nil
+	default:
+		return nil, false, errors.New("log: unsupported syslog network '" + s.Network + "'")
+	}
+}
+
+func (s *Syslog) log(v ...interface{}) {
+	if s.ErrorLog == nil {
+		log.Println(v...)
+	} else {
+		s.ErrorLog.Println(v...)
+	}
+}
+
+// header returns the syslog header field s with at most
+// max printable US-ASCII characters. If s is empty, it
+// returns the NILVALUE "-".
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// flattenJSON appends all scalar fields of the JSON
+// object to params. The names of nested fields are
+// joined by a '.' - e.g. "request.path".
+func flattenJSON(prefix string, object map[string]interface{}, params [][2]string) [][2]string {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch value := object[name].(type) {
+		case map[string]interface{}:
+			params = flattenJSON(prefix+name+".", value, params)
+		case string:
+			params = append(params, [2]string{prefix + name, value})
+		case json.Number:
+			params = append(params, [2]string{prefix + name, value.String()})
+		case bool:
+			params = append(params, [2]string{prefix + name, strconv.FormatBool(value)})
+		}
+	}
+	return params
+}
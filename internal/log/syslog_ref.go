@@ -0,0 +1,22 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build windows plan9
+
+package log
+
+import (
+	"errors"
+	"net"
+)
+
+// LocalSyslog reports whether the platform has
+// a local syslog daemon.
+const LocalSyslog = false
+
+// dialLocal returns an error since there is no
+// local syslog daemon on this platform.
+func dialLocal() (net.Conn, bool, error) {
+	return nil, false, errors.New("log: syslog is not supported on this platform")
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogFormatTests = []struct {
+	Level  Level
+	Params [][2]string
+	Msg    string
+	Want   string
+}{
+	{Level: LevelInfo, Msg: "hello", Want: "<38>1 2020-01-02T03:04:05.000000Z host kes PID audit - \xEF\xBB\xBFhello"},                                 // 0
+	{Level: LevelError, Want: "<35>1 2020-01-02T03:04:05.000000Z host kes PID audit -"},                                                                // 1
+	{Level: LevelDebug, Params: [][2]string{{"key", "my-key"}}, Want: `<39>1 2020-01-02T03:04:05.000000Z host kes PID audit [kes@32473 key="my-key"]`}, // 2
+	{Level: LevelWarn, Params: [][2]string{{"a=b", `x"]\`}}, Want: `<36>1 2020-01-02T03:04:05.000000Z host kes PID audit [kes@32473 ab="x\"\]\\"]`},    // 3: escape values and remove invalid name characters
+	{Level: LevelInfo, Params: [][2]string{{" ", "x"}}, Want: "<38>1 2020-01-02T03:04:05.000000Z host kes PID audit [kes@32473]"},                      // 4
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s := &Syslog{AppName: "kes", MsgID: "audit", hostname: "host"}
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, test := range syslogFormatTests {
+		want := strings.Replace(test.Want, "PID", strconv.Itoa(os.Getpid()), 1)
+		if msg := string(s.format(test.Level, date, test.Params, test.Msg)); msg != want {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, msg, want)
+		}
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	s := &Syslog{
+		Network:  "tcp",
+		Address:  listener.Addr().String(),
+		Facility: facilities["local0"],
+		AppName:  "kes",
+		MsgID:    "audit",
+	}
+	s.Write([]byte(`{"request":{"path":"/v1/key/create/my-key"},"response":{"status":200}}` + "\n"))
+	s.WriteRecord(Record{Level: LevelError, Component: "vault", Message: "failed to read entry", Fields: map[string]string{"key": "my-key"}})
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept connection: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// Each message is prefixed with its length. See: RFC 6587
+	r := bufio.NewReader(conn)
+	readMessage := func() string {
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatalf("Failed to read message length: %v", err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			t.Fatalf("Invalid message length '%s': %v", length, err)
+		}
+		msg := make([]byte, n)
+		if _, err = io.ReadFull(r, msg); err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		return string(msg)
+	}
+
+	msg := readMessage()
+	if !strings.HasPrefix(msg, "<134>1 ") {
+		t.Fatalf("Invalid priority: got '%s'", msg)
+	}
+	if sd := `[kes@32473 request.path="/v1/key/create/my-key" response.status="200"]`; !strings.Contains(msg, sd) {
+		t.Fatalf("Message does not contain structured data '%s': got '%s'", sd, msg)
+	}
+	if !strings.HasSuffix(msg, `{"request":{"path":"/v1/key/create/my-key"},"response":{"status":200}}`) {
+		t.Fatalf("Message does not contain the event: got '%s'", msg)
+	}
+
+	msg = readMessage()
+	if !strings.HasPrefix(msg, "<131>1 ") {
+		t.Fatalf("Invalid priority: got '%s'", msg)
+	}
+	if sd := `[kes@32473 component="vault" key="my-key"] ` + "\xEF\xBB\xBF" + "vault: failed to read entry"; !strings.HasSuffix(msg, sd) {
+		t.Fatalf("Message does not end with '%s': got '%s'", sd, msg)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"net"
+)
+
+// LocalSyslog reports whether the platform has
+// a local syslog daemon.
+const LocalSyslog = true
+
+// dialLocal connects to the local syslog daemon via
+// its Unix domain socket. It reports whether messages
+// have to be sent with an octet count - which is never
+// the case for the local syslog daemon.
+func dialLocal() (net.Conn, bool, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				return conn, false, nil
+			}
+		}
+	}
+	return nil, false, errors.New("log: no local syslog daemon")
+}
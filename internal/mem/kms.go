@@ -0,0 +1,136 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package mem
+
+import (
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/secret"
+)
+
+// The errors a KMS can be scripted to fail with. They
+// resemble the errors of AWS-KMS and have the same kinds
+// of API errors as the errors of the aws.KMS.
+var (
+	ErrKMSThrottled         = errs.New(kes.ErrKMSThrottled, "mem: ThrottlingException: rate exceeded")
+	ErrKMSUnreachable       = errs.New(kes.ErrKMSUnavailable, "mem: RequestError: send request failed")
+	ErrKMSAccessDenied      = errs.New(kes.ErrKMSFailure, "mem: AccessDeniedException: not authorized to use the master key")
+	ErrKMSDisabled          = errs.New(kes.ErrKMSFailure, "mem: DisabledException: master key is disabled")
+	ErrKMSInvalidCiphertext = errs.New(kes.ErrKMSFailure, "mem: InvalidCiphertextException: ciphertext is not authentic")
+)
+
+// KMSCall is a call of a KMS operation.
+type KMSCall struct {
+	Op      string // The operation - secret.OpEncrypt, secret.OpDecrypt or secret.OpStatus
+	Context string // The encryption context. Empty for secret.OpStatus.
+	Err     error  // The error returned by the operation, if any
+}
+
+// KMS is an in-memory KMS that records all calls and
+// can be scripted to fail. It encrypts and decrypts
+// with its MasterKey. Its zero value is ready to use.
+//
+// KMS is meant for testing the Store and the server
+// without a KMS instance or cloud credentials.
+type KMS struct {
+	// MasterKey is the key used to encrypt and
+	// decrypt. It must not be modified once the KMS
+	// has been used.
+	MasterKey secret.Secret
+
+	lock   sync.Mutex
+	calls  []KMSCall
+	script map[string][]error
+}
+
+var (
+	_ secret.KMS           = (*KMS)(nil)
+	_ secret.StatusChecker = (*KMS)(nil)
+)
+
+// Script sets the results of the next calls of the given
+// operation - e.g. secret.OpDecrypt. The i-th call fails
+// with the i-th error, or succeeds if it is nil. Calls
+// beyond the scripted ones succeed. For example:
+//   kms.Script(secret.OpDecrypt, mem.ErrKMSThrottled, nil, mem.ErrKMSThrottled)
+func (k *KMS) Script(op string, results ...error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.script == nil {
+		k.script = map[string][]error{}
+	}
+	k.script[op] = append([]error(nil), results...)
+}
+
+// Calls returns all calls of the KMS - oldest first.
+func (k *KMS) Calls() []KMSCall {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return append([]KMSCall(nil), k.calls...)
+}
+
+// Encrypt encrypts the plaintext with the master key and
+// binds the context to the ciphertext - unless the call
+// has been scripted to fail.
+func (k *KMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	if err := k.scripted(secret.OpEncrypt); err != nil {
+		k.record(secret.OpEncrypt, context, err)
+		return nil, err
+	}
+	ciphertext, err := k.MasterKey.Wrap(plaintext, []byte(context))
+	k.record(secret.OpEncrypt, context, err)
+	return ciphertext, err
+}
+
+// Decrypt decrypts the ciphertext with the master key
+// - unless the call has been scripted to fail. It returns
+// ErrKMSInvalidCiphertext if the ciphertext is not
+// authentic or has not been encrypted with the context.
+func (k *KMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	if err := k.scripted(secret.OpDecrypt); err != nil {
+		k.record(secret.OpDecrypt, context, err)
+		return nil, err
+	}
+	plaintext, err := k.MasterKey.Unwrap(ciphertext, []byte(context))
+	if err != nil {
+		err = ErrKMSInvalidCiphertext
+	}
+	k.record(secret.OpDecrypt, context, err)
+	return plaintext, err
+}
+
+// Status returns an error if the KMS has been scripted
+// to be unavailable.
+func (k *KMS) Status() error {
+	err := k.scripted(secret.OpStatus)
+	k.record(secret.OpStatus, "", err)
+	return err
+}
+
+// scripted returns the scripted error of the next
+// call of the operation, if any.
+func (k *KMS) scripted(op string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	script := k.script[op]
+	if len(script) == 0 {
+		return nil
+	}
+	k.script[op] = script[1:]
+	return script[0]
+}
+
+// record adds the call of the operation to the calls.
+func (k *KMS) record(op, context string, err error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.calls = append(k.calls, KMSCall{Op: op, Context: context, Err: err})
+}
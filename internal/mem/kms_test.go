@@ -0,0 +1,78 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package mem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestKMS(t *testing.T) {
+	kms := &KMS{}
+	ciphertext, err := kms.Encrypt([]byte("plaintext"), "my-key")
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	plaintext, err := kms.Decrypt(ciphertext, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("plaintext")) {
+		t.Fatalf("Invalid plaintext: got %q - want %q", plaintext, "plaintext")
+	}
+	if _, err = kms.Decrypt(ciphertext, "other-key"); err != ErrKMSInvalidCiphertext {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrKMSInvalidCiphertext)
+	}
+
+	calls := kms.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("Invalid number of calls: got %d - want %d", len(calls), 3)
+	}
+	if c := calls[2]; c.Op != secret.OpDecrypt || c.Context != "other-key" || c.Err != ErrKMSInvalidCiphertext {
+		t.Fatalf("Invalid call: got %+v", c)
+	}
+}
+
+func TestKMSScript(t *testing.T) {
+	kms := &KMS{}
+	kms.Script(secret.OpStatus, ErrKMSUnreachable, nil)
+	if err := kms.Status(); err != ErrKMSUnreachable {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrKMSUnreachable)
+	}
+	if err := kms.Status(); err != nil {
+		t.Fatalf("KMS is not available: %v", err)
+	}
+	if err := kms.Status(); err != nil {
+		t.Fatalf("KMS is not available: %v", err)
+	}
+
+	store := &secret.Store{Remote: &Store{}, KMS: kms}
+	var key secret.Secret
+	if err := store.Create(context.Background(), "my-key", key); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	store.Evict("my-key")
+
+	kms.Script(secret.OpDecrypt, ErrKMSThrottled)
+	if _, err := store.Get(context.Background(), "my-key"); !errors.Is(err, kes.ErrKMSThrottled) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKMSThrottled)
+	}
+	if _, err := store.Get(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to get secret: %v", err)
+	}
+
+	kms.Script(secret.OpEncrypt, ErrKMSAccessDenied)
+	if err := store.Create(context.Background(), "other-key", key); !errors.Is(err, kes.ErrKMSFailure) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKMSFailure)
+	}
+	if _, err := store.Get(context.Background(), "other-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Secret has been created although the KMS failed: %v", err)
+	}
+}
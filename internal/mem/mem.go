@@ -2,11 +2,13 @@
 // Use of this source code is governed by the AGPLv3
 // license that can be found in the LICENSE file.
 
-// Package mem implements an in-memory key-value store.
+// Package mem implements an in-memory key-value store
+// and an in-memory KMS for testing.
 package mem
 
 import (
 	"sync"
+	"time"
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/secret"
@@ -15,11 +17,15 @@ import (
 // Store is an in-memory key-value store. Its zero value is
 // ready to use.
 type Store struct {
-	lock  sync.RWMutex
-	store map[string]string
+	lock    sync.RWMutex
+	store   map[string]string
+	created map[string]time.Time
 }
 
-var _ secret.Remote = (*Store)(nil)
+var (
+	_ secret.Remote = (*Store)(nil)
+	_ secret.Stater = (*Store)(nil)
+)
 
 // Create adds the given key-value pair to the store if and
 // only if no entry for key exists. If an entry already exists
@@ -30,11 +36,13 @@ func (s *Store) Create(key, value string) error {
 
 	if s.store == nil {
 		s.store = map[string]string{}
+		s.created = map[string]time.Time{}
 	}
 	if _, ok := s.store[key]; ok {
 		return kes.ErrKeyExists
 	}
 	s.store[key] = value
+	s.created[key] = time.Now().UTC()
 	return nil
 }
 
@@ -44,6 +52,7 @@ func (s *Store) Delete(key string) error {
 	defer s.lock.Unlock()
 
 	delete(s.store, key)
+	delete(s.created, key)
 	return nil
 }
 
@@ -59,3 +68,29 @@ func (s *Store) Get(key string) (string, error) {
 	}
 	return value, nil
 }
+
+// CreatedAt returns the point in time when the entry
+// for the given key has been created. If no entry for
+// key exists it returns kes.ErrKeyNotFound.
+func (s *Store) CreatedAt(key string) (time.Time, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	created, ok := s.created[key]
+	if !ok {
+		return time.Time{}, kes.ErrKeyNotFound
+	}
+	return created, nil
+}
+
+// List returns the names of all entries.
+func (s *Store) List() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	names := make([]string, 0, len(s.store))
+	for name := range s.store {
+		names = append(names, name)
+	}
+	return names, nil
+}
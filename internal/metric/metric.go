@@ -0,0 +1,313 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package metric implements server metrics that
+// can be exported in the Prometheus text format.
+// See: https://prometheus.io/docs/instrumenting/exposition_formats
+package metric
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Buckets are the upper bounds, in seconds, of
+// all latency histograms.
+var Buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects server metrics, like request
+// rates or latencies, and writes them in the
+// Prometheus text format.
+//
+// Its zero value is ready to use. A Metrics
+// is safe for concurrent use.
+type Metrics struct {
+	activeConns int64 // Must be 64 bit aligned for atomic operations
+
+	lock      sync.Mutex
+	requests  map[request]uint64
+	latencies map[string]*histogram
+	backend   map[string]*histogram
+	kms       map[string]*histogram
+	funcs     []metricFunc
+}
+
+// request is a (API, status class) pair
+// used to count HTTP requests.
+type request struct {
+	API    string
+	Status string // The status class: 2xx, 3xx, 4xx or 5xx
+}
+
+// metricFunc is a counter or gauge that
+// is computed on demand when the metrics
+// are written.
+//
+// It either has a single value computed by
+// F or, if it has labels, many samples
+// computed by Samples.
+type metricFunc struct {
+	Name    string
+	Help    string
+	Type    string // "counter" or "gauge"
+	F       func() float64
+	Labels  []string
+	Samples func() []Sample
+}
+
+// Sample is a value of a metric with labels.
+type Sample struct {
+	Labels []string // The label values in the order of the metric's label names
+	Value  float64
+}
+
+// ObserveRequest records a request to the given
+// API that has been answered with the status code
+// after the given latency.
+func (m *Metrics) ObserveRequest(api string, status int, latency time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.requests == nil {
+		m.requests = map[request]uint64{}
+	}
+	if m.latencies == nil {
+		m.latencies = map[string]*histogram{}
+	}
+	m.requests[request{API: api, Status: statusClass(status)}]++
+	observe(m.latencies, api, latency)
+}
+
+// ObserveBackend records a key store operation,
+// like "create", "get" or "delete", that took
+// the given latency.
+func (m *Metrics) ObserveBackend(op string, latency time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.backend == nil {
+		m.backend = map[string]*histogram{}
+	}
+	observe(m.backend, op, latency)
+}
+
+// ObserveKMS records a KMS operation, like
+// "encrypt" or "decrypt", that took the
+// given latency.
+func (m *Metrics) ObserveKMS(op string, latency time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.kms == nil {
+		m.kms = map[string]*histogram{}
+	}
+	observe(m.kms, op, latency)
+}
+
+// ConnState tracks the number of active connections.
+// It can be used as http.Server.ConnState hook.
+func (m *Metrics) ConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&m.activeConns, 1)
+	case http.StateHijacked, http.StateClosed:
+		atomic.AddInt64(&m.activeConns, -1)
+	}
+}
+
+// CounterFunc adds a counter with the given name
+// whose value is computed by f whenever the metrics
+// are written.
+func (m *Metrics) CounterFunc(name, help string, f func() float64) {
+	m.addFunc(metricFunc{Name: name, Help: help, Type: "counter", F: f})
+}
+
+// GaugeFunc adds a gauge with the given name whose
+// value is computed by f whenever the metrics are
+// written.
+func (m *Metrics) GaugeFunc(name, help string, f func() float64) {
+	m.addFunc(metricFunc{Name: name, Help: help, Type: "gauge", F: f})
+}
+
+// CounterVecFunc adds a counter with the given name and
+// label names whose samples are computed by f whenever
+// the metrics are written.
+func (m *Metrics) CounterVecFunc(name, help string, labels []string, f func() []Sample) {
+	m.addFunc(metricFunc{Name: name, Help: help, Type: "counter", Labels: labels, Samples: f})
+}
+
+// GaugeVecFunc adds a gauge with the given name and label
+// names whose samples are computed by f whenever the
+// metrics are written.
+func (m *Metrics) GaugeVecFunc(name, help string, labels []string, f func() []Sample) {
+	m.addFunc(metricFunc{Name: name, Help: help, Type: "gauge", Labels: labels, Samples: f})
+}
+
+func (m *Metrics) addFunc(f metricFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.funcs = append(m.funcs, f)
+}
+
+// WriteTo writes all metrics in the Prometheus
+// text format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{W: bufio.NewWriter(w)}
+
+	m.lock.Lock()
+	keys := make([]request, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].API != keys[j].API {
+			return keys[i].API < keys[j].API
+		}
+		return keys[i].Status < keys[j].Status
+	})
+	fmt.Fprintln(cw, "# HELP kes_http_requests_total Number of HTTP requests by API and status class.")
+	fmt.Fprintln(cw, "# TYPE kes_http_requests_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(cw, "kes_http_requests_total{api=%q,status=%q} %d\n", key.API, key.Status, m.requests[key])
+	}
+
+	writeHistograms(cw, "kes_http_request_duration_seconds", "Latency of HTTP requests by API.", "api", m.latencies)
+	writeHistograms(cw, "kes_backend_duration_seconds", "Latency of key store operations.", "op", m.backend)
+	writeHistograms(cw, "kes_kms_duration_seconds", "Latency of KMS operations.", "op", m.kms)
+
+	funcs := make([]metricFunc, len(m.funcs))
+	copy(funcs, m.funcs)
+	m.lock.Unlock()
+
+	fmt.Fprintln(cw, "# HELP kes_http_active_connections Number of active client connections.")
+	fmt.Fprintln(cw, "# TYPE kes_http_active_connections gauge")
+	fmt.Fprintf(cw, "kes_http_active_connections %d\n", atomic.LoadInt64(&m.activeConns))
+
+	for _, f := range funcs { // Don't hold the lock while calling f
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.Name, f.Type)
+		if f.Samples == nil {
+			fmt.Fprintf(cw, "%s %s\n", f.Name, formatFloat(f.F()))
+			continue
+		}
+		for _, sample := range f.Samples() {
+			fmt.Fprintf(cw, "%s{%s} %s\n", f.Name, formatLabels(f.Labels, sample.Labels), formatFloat(sample.Value))
+		}
+	}
+
+	if cw.Err != nil {
+		return cw.N, cw.Err
+	}
+	return cw.N, cw.W.Flush()
+}
+
+// histogram is a Prometheus histogram with
+// cumulative buckets as defined by Buckets.
+type histogram struct {
+	Counts []uint64 // Counts[i] is the number of observations <= Buckets[i]
+	Count  uint64
+	Sum    float64
+}
+
+func observe(histograms map[string]*histogram, label string, latency time.Duration) {
+	h, ok := histograms[label]
+	if !ok {
+		h = &histogram{Counts: make([]uint64, len(Buckets))}
+		histograms[label] = h
+	}
+
+	seconds := latency.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += seconds
+}
+
+func writeHistograms(w io.Writer, name, help, label string, histograms map[string]*histogram) {
+	labels := make([]string, 0, len(histograms))
+	for l := range histograms {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, l := range labels {
+		h := histograms[l]
+		for i, bound := range Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", name, label, l, formatFloat(bound), h.Counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, l, h.Count)
+		fmt.Fprintf(w, "%s_sum{%s=%q} %s\n", name, label, l, formatFloat(h.Sum))
+		fmt.Fprintf(w, "%s_count{%s=%q} %d\n", name, label, l, h.Count)
+	}
+}
+
+// formatLabels returns the label pairs - e.g.
+// key="my-key",op="generate".
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&b, "%s=%q", name, value)
+	}
+	return b.String()
+}
+
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countWriter wraps an io.Writer, counts the bytes
+// written and remembers the first error.
+type countWriter struct {
+	W   *bufio.Writer
+	N   int64
+	Err error
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	if w.Err != nil {
+		return 0, w.Err
+	}
+	n, err := w.W.Write(p)
+	w.N += int64(n)
+	w.Err = err
+	return n, err
+}
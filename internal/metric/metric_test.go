@@ -0,0 +1,74 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package metric
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var statusClassTests = []struct {
+	Status int
+	Class  string
+}{
+	{Status: http.StatusOK, Class: "2xx"},                  // 0
+	{Status: http.StatusNotFound, Class: "4xx"},            // 1
+	{Status: http.StatusInternalServerError, Class: "5xx"}, // 2
+	{Status: 0, Class: "unknown"},                          // 3
+	{Status: 600, Class: "unknown"},                        // 4
+}
+
+func TestStatusClass(t *testing.T) {
+	for i, test := range statusClassTests {
+		if class := statusClass(test.Status); class != test.Class {
+			t.Fatalf("Test %d: got %s - want %s", i, class, test.Class)
+		}
+	}
+}
+
+func TestMetricsWriteTo(t *testing.T) {
+	var metrics Metrics
+	metrics.ObserveRequest("/v1/key/create/", http.StatusOK, 3*time.Millisecond)
+	metrics.ObserveRequest("/v1/key/create/", http.StatusBadRequest, 20*time.Millisecond)
+	metrics.ObserveBackend("get", 2*time.Second)
+	metrics.ConnState(nil, http.StateNew)
+	metrics.CounterFunc("kes_test_total", "A test counter.", func() float64 { return 42 })
+	metrics.CounterVecFunc("kes_test_ops_total", "A test counter with labels.", []string{"key", "op"}, func() []Sample {
+		return []Sample{
+			{Labels: []string{"my-key", "generate"}, Value: 3},
+			{Labels: []string{"my-key", "decrypt"}, Value: 7},
+		}
+	})
+
+	var sb strings.Builder
+	if _, err := metrics.WriteTo(&sb); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	output := sb.String()
+
+	var lines = []string{
+		`kes_http_requests_total{api="/v1/key/create/",status="2xx"} 1`,
+		`kes_http_requests_total{api="/v1/key/create/",status="4xx"} 1`,
+		`kes_http_request_duration_seconds_bucket{api="/v1/key/create/",le="0.005"} 1`,
+		`kes_http_request_duration_seconds_bucket{api="/v1/key/create/",le="0.025"} 2`,
+		`kes_http_request_duration_seconds_bucket{api="/v1/key/create/",le="+Inf"} 2`,
+		`kes_http_request_duration_seconds_count{api="/v1/key/create/"} 2`,
+		`kes_backend_duration_seconds_bucket{op="get",le="1"} 0`,
+		`kes_backend_duration_seconds_bucket{op="get",le="2.5"} 1`,
+		`kes_http_active_connections 1`,
+		`# TYPE kes_test_total counter`,
+		`kes_test_total 42`,
+		`# TYPE kes_test_ops_total counter`,
+		`kes_test_ops_total{key="my-key",op="generate"} 3`,
+		`kes_test_ops_total{key="my-key",op="decrypt"} 7`,
+	}
+	for i, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("Test %d: metrics do not contain '%s':\n%s", i, line, output)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package metric
+
+import (
+	"time"
+
+	"github.com/minio/kes/internal/secret"
+)
+
+// Remote is a secret.Remote that records the
+// latency of all key store operations.
+type Remote struct {
+	secret.Remote
+
+	Metrics *Metrics
+}
+
+var _ secret.Remote = Remote{}
+
+// Create creates a new entry at the Remote store
+// and records the latency of the operation.
+func (r Remote) Create(key, value string) error {
+	defer r.observe("create", time.Now())
+	return r.Remote.Create(key, value)
+}
+
+// Delete deletes an entry at the Remote store
+// and records the latency of the operation.
+func (r Remote) Delete(key string) error {
+	defer r.observe("delete", time.Now())
+	return r.Remote.Delete(key)
+}
+
+// Get fetches an entry from the Remote store
+// and records the latency of the operation.
+func (r Remote) Get(key string) (string, error) {
+	defer r.observe("get", time.Now())
+	return r.Remote.Get(key)
+}
+
+// List returns the names of all entries at the Remote
+// store. It returns secret.ErrListNotSupported if the
+// Remote store does not implement secret.Lister.
+func (r Remote) List() ([]string, error) {
+	lister, ok := r.Remote.(secret.Lister)
+	if !ok {
+		return nil, secret.ErrListNotSupported
+	}
+	defer r.observe("list", time.Now())
+	return lister.List()
+}
+
+// ListPrefix returns the names of all entries at the
+// Remote store that start with the prefix. It returns
+// secret.ErrListNotSupported if the Remote store cannot
+// list its entries.
+func (r Remote) ListPrefix(prefix string) ([]string, error) {
+	defer r.observe("list", time.Now())
+	return secret.ListPrefix(r.Remote, prefix)
+}
+
+// CreatedAt returns the point in time when the entry has
+// been created at the Remote store. It returns
+// secret.ErrStatNotSupported if the Remote store does not
+// implement secret.Stater.
+func (r Remote) CreatedAt(key string) (time.Time, error) {
+	stater, ok := r.Remote.(secret.Stater)
+	if !ok {
+		return time.Time{}, secret.ErrStatNotSupported
+	}
+	defer r.observe("stat", time.Now())
+	return stater.CreatedAt(key)
+}
+
+func (r Remote) observe(op string, start time.Time) {
+	r.Metrics.ObserveBackend(op, time.Since(start))
+}
+
+// KMS is a secret.KMS that records the latency
+// of all KMS operations.
+type KMS struct {
+	secret.KMS
+
+	Metrics *Metrics
+}
+
+var _ secret.KMS = KMS{}
+
+// Encrypt encrypts the plaintext with the KMS
+// and records the latency of the operation.
+func (k KMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	defer k.observe("encrypt", time.Now())
+	return k.KMS.Encrypt(plaintext, context)
+}
+
+// Decrypt decrypts the ciphertext with the KMS
+// and records the latency of the operation.
+func (k KMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	defer k.observe("decrypt", time.Now())
+	return k.KMS.Decrypt(ciphertext, context)
+}
+
+func (k KMS) observe(op string, start time.Time) {
+	k.Metrics.ObserveKMS(op, time.Since(start))
+}
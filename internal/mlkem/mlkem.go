@@ -0,0 +1,266 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package mlkem implements the ML-KEM-768 key encapsulation
+// mechanism - formerly known as Kyber - as specified in
+// NIST FIPS 203.
+//
+// The implementation is not optimized for speed. It is used
+// by the experimental post-quantum hybrid wrapping of data
+// keys only. See: secret.Secret.WrapHybrid
+package mlkem
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The ML-KEM-768 parameters and sizes.
+const (
+	n  = 256
+	q  = 3329
+	k  = 3
+	η1 = 2
+	η2 = 2
+	du = 10
+	dv = 4
+
+	encryptionKeySize = 384*k + 32
+	decryptionKeySize = 384 * k
+
+	// SeedSize is the size of the seed (d || z)
+	// a DecapsulationKey is derived from.
+	SeedSize = 64
+
+	// EncapsulationKeySize is the size of an
+	// encoded encapsulation key.
+	EncapsulationKeySize = encryptionKeySize
+
+	// CiphertextSize is the size of a ciphertext.
+	CiphertextSize = 32 * (du*k + dv)
+
+	// SharedKeySize is the size of a shared key.
+	SharedKeySize = 32
+)
+
+// DecapsulationKey is an ML-KEM-768 decapsulation
+// (private) key.
+type DecapsulationKey struct {
+	dk [decryptionKeySize]byte // The K-PKE decryption key
+	ek [encryptionKeySize]byte // The encapsulation key
+	h  [32]byte                // H(ek)
+	z  [32]byte                // The implicit rejection value
+}
+
+// NewDecapsulationKey derives a decapsulation key from
+// the seed (d || z). The seed must be SeedSize bytes long.
+func NewDecapsulationKey(seed []byte) (*DecapsulationKey, error) {
+	if len(seed) != SeedSize {
+		return nil, errors.New("mlkem: invalid seed size")
+	}
+
+	var key DecapsulationKey
+	pkeKeyGen(seed[:32], &key.ek, &key.dk)
+	key.h = sha3.Sum256(key.ek[:])
+	copy(key.z[:], seed[32:])
+	return &key, nil
+}
+
+// EncapsulationKey returns the encoded encapsulation
+// (public) key that corresponds to the decapsulation
+// key.
+func (key *DecapsulationKey) EncapsulationKey() []byte {
+	ek := make([]byte, len(key.ek))
+	copy(ek, key.ek[:])
+	return ek
+}
+
+// Decapsulate returns the shared key encapsulated in
+// the ciphertext. It implements implicit rejection -
+// i.e. it returns a pseudo-random shared key if the
+// ciphertext is not valid.
+func (key *DecapsulationKey) Decapsulate(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) != CiphertextSize {
+		return nil, errors.New("mlkem: invalid ciphertext size")
+	}
+
+	m := pkeDecrypt(&key.dk, ciphertext)
+	g := sha3.Sum512(append(m[:], key.h[:]...))
+	sharedKey, r := g[:32], g[32:]
+
+	rejectKey := make([]byte, SharedKeySize)
+	j := sha3.NewShake256()
+	j.Write(key.z[:])
+	j.Write(ciphertext)
+	j.Read(rejectKey)
+
+	c := pkeEncrypt(key.ek[:], m[:], r)
+	subtle.ConstantTimeCopy(1-subtle.ConstantTimeCompare(c, ciphertext), sharedKey, rejectKey)
+	return sharedKey, nil
+}
+
+// Encapsulate generates a shared key and encapsulates it
+// for the encapsulation key. It returns the shared key and
+// the ciphertext. The random bytes are read from random.
+func Encapsulate(random io.Reader, encapsulationKey []byte) (sharedKey, ciphertext []byte, err error) {
+	if len(encapsulationKey) != EncapsulationKeySize {
+		return nil, nil, errors.New("mlkem: invalid encapsulation key size")
+	}
+	// The encapsulation key must encode coefficients
+	// modulo q. See: FIPS 203, 7.2
+	for i := 0; i < k; i++ {
+		var f ringElement
+		encoded := encapsulationKey[i*384 : (i+1)*384]
+		byteDecode12(encoded, &f)
+		if subtle.ConstantTimeCompare(byteEncode12(&f), encoded) != 1 {
+			return nil, nil, errors.New("mlkem: invalid encapsulation key")
+		}
+	}
+
+	var m [32]byte
+	if _, err = io.ReadFull(random, m[:]); err != nil {
+		return nil, nil, err
+	}
+	h := sha3.Sum256(encapsulationKey)
+	g := sha3.Sum512(append(m[:], h[:]...))
+	sharedKey, r := g[:32], g[32:]
+	return sharedKey, pkeEncrypt(encapsulationKey, m[:], r), nil
+}
+
+// pkeKeyGen implements K-PKE.KeyGen. It derives the
+// encryption key ek and the decryption key dk from d.
+func pkeKeyGen(d []byte, ek *[encryptionKeySize]byte, dk *[decryptionKeySize]byte) {
+	g := sha3.Sum512(append(append([]byte{}, d...), k))
+	ρ, σ := g[:32], g[32:]
+
+	A := sampleMatrix(ρ)
+	var (
+		s, e [k]ringElement
+		N    byte
+	)
+	for i := range s {
+		samplePolyCBD(prf(σ, N, η1), η1, &s[i])
+		ntt(&s[i])
+		N++
+	}
+	for i := range e {
+		samplePolyCBD(prf(σ, N, η1), η1, &e[i])
+		ntt(&e[i])
+		N++
+	}
+
+	for i := 0; i < k; i++ {
+		var t ringElement
+		for j := 0; j < k; j++ {
+			var p ringElement
+			multiplyNTTs(&A[i][j], &s[j], &p)
+			add(&t, &p, &t)
+		}
+		add(&t, &e[i], &t)
+		copy(ek[i*384:], byteEncode12(&t))
+		copy(dk[i*384:], byteEncode12(&s[i]))
+	}
+	copy(ek[k*384:], ρ)
+}
+
+// pkeEncrypt implements K-PKE.Encrypt. It encrypts the
+// message m with the encryption key ek and the randomness
+// r.
+func pkeEncrypt(ek, m, r []byte) []byte {
+	var t [k]ringElement
+	for i := range t {
+		byteDecode12(ek[i*384:(i+1)*384], &t[i])
+	}
+	A := sampleMatrix(ek[k*384:])
+
+	var (
+		y, e1 [k]ringElement
+		e2    ringElement
+		N     byte
+	)
+	for i := range y {
+		samplePolyCBD(prf(r, N, η1), η1, &y[i])
+		ntt(&y[i])
+		N++
+	}
+	for i := range e1 {
+		samplePolyCBD(prf(r, N, η2), η2, &e1[i])
+		N++
+	}
+	samplePolyCBD(prf(r, N, η2), η2, &e2)
+
+	c := make([]byte, 0, CiphertextSize)
+	for i := 0; i < k; i++ {
+		var u ringElement
+		for j := 0; j < k; j++ {
+			var p ringElement
+			multiplyNTTs(&A[j][i], &y[j], &p) // Aᵀ
+			add(&u, &p, &u)
+		}
+		inverseNTT(&u)
+		add(&u, &e1[i], &u)
+		c = append(c, compressEncode(&u, du)...)
+	}
+
+	var v, μ ringElement
+	for i := 0; i < k; i++ {
+		var p ringElement
+		multiplyNTTs(&t[i], &y[i], &p)
+		add(&v, &p, &v)
+	}
+	inverseNTT(&v)
+	add(&v, &e2, &v)
+	decodeDecompress(m, 1, &μ)
+	add(&v, &μ, &v)
+	return append(c, compressEncode(&v, dv)...)
+}
+
+// pkeDecrypt implements K-PKE.Decrypt. It decrypts the
+// ciphertext c with the decryption key dk.
+func pkeDecrypt(dk *[decryptionKeySize]byte, c []byte) [32]byte {
+	const uSize = 32 * du
+
+	var w ringElement
+	for i := 0; i < k; i++ {
+		var u, s, p ringElement
+		decodeDecompress(c[i*uSize:(i+1)*uSize], du, &u)
+		ntt(&u)
+		byteDecode12(dk[i*384:(i+1)*384], &s)
+		multiplyNTTs(&s, &u, &p)
+		add(&w, &p, &w)
+	}
+	inverseNTT(&w)
+
+	var v ringElement
+	decodeDecompress(c[k*uSize:], dv, &v)
+	sub(&v, &w, &w)
+
+	var m [32]byte
+	copy(m[:], compressEncode(&w, 1))
+	return m
+}
+
+// sampleMatrix returns the matrix Â in NTT representation
+// generated from the seed ρ.
+func sampleMatrix(ρ []byte) (A [k][k]ringElement) {
+	for i := 0; i < k; i++ {
+		for j := 0; j < k; j++ {
+			sampleNTT(ρ, byte(j), byte(i), &A[i][j])
+		}
+	}
+	return A
+}
+
+// prf returns PRF_η(s, b) = SHAKE256(s || b, 64·η).
+func prf(s []byte, b byte, η int) []byte {
+	out := make([]byte, 64*η)
+	h := sha3.NewShake256()
+	h.Write(s)
+	h.Write([]byte{b})
+	h.Read(out)
+	return out
+}
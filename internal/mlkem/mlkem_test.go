@@ -0,0 +1,113 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestEncapsulate(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		seed := make([]byte, SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			t.Fatalf("Test %d: Failed to generate seed: %v", i, err)
+		}
+		key, err := NewDecapsulationKey(seed)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create decapsulation key: %v", i, err)
+		}
+
+		sharedKey, ciphertext, err := Encapsulate(rand.Reader, key.EncapsulationKey())
+		if err != nil {
+			t.Fatalf("Test %d: Failed to encapsulate: %v", i, err)
+		}
+		if len(sharedKey) != SharedKeySize {
+			t.Fatalf("Test %d: Invalid shared key size: got %d - want %d", i, len(sharedKey), SharedKeySize)
+		}
+		if len(ciphertext) != CiphertextSize {
+			t.Fatalf("Test %d: Invalid ciphertext size: got %d - want %d", i, len(ciphertext), CiphertextSize)
+		}
+		decapsulatedKey, err := key.Decapsulate(ciphertext)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to decapsulate: %v", i, err)
+		}
+		if !bytes.Equal(sharedKey, decapsulatedKey) {
+			t.Fatalf("Test %d: Shared key mismatch: got %x - want %x", i, decapsulatedKey, sharedKey)
+		}
+
+		ciphertext[0] ^= 1
+		rejectKey, err := key.Decapsulate(ciphertext)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to decapsulate: %v", i, err)
+		}
+		if bytes.Equal(sharedKey, rejectKey) {
+			t.Fatalf("Test %d: Modified ciphertext has not been rejected", i)
+		}
+	}
+}
+
+// The expected values have been computed with the
+// ML-KEM-768 implementation of the Go standard library.
+const (
+	kemEncapsulationKeyHash = "0b7934c83125c788995e2ba6bd761e33046b3e40571be53e023309a29f398cc9"
+	kemRejectKey            = "fc1c7a0b83e1137e1558f691159a2b050b9837f89d7c616e274c84aaf8a58f20"
+)
+
+func TestDecapsulationKey(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	key, err := NewDecapsulationKey(seed)
+	if err != nil {
+		t.Fatalf("Failed to create decapsulation key: %v", err)
+	}
+	if hash := sha256.Sum256(key.EncapsulationKey()); hex.EncodeToString(hash[:]) != kemEncapsulationKeyHash {
+		t.Fatalf("Encapsulation key mismatch: got %x - want %s", hash, kemEncapsulationKeyHash)
+	}
+
+	// A random ciphertext is (almost certainly) invalid.
+	// Hence, it must be rejected implicitly.
+	ciphertext := make([]byte, CiphertextSize)
+	xof := sha3.NewShake128()
+	xof.Write([]byte("kes"))
+	xof.Read(ciphertext)
+	sharedKey, err := key.Decapsulate(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decapsulate: %v", err)
+	}
+	if hex.EncodeToString(sharedKey) != kemRejectKey {
+		t.Fatalf("Shared key mismatch: got %x - want %s", sharedKey, kemRejectKey)
+	}
+}
+
+func TestInvalidSizes(t *testing.T) {
+	if _, err := NewDecapsulationKey(make([]byte, SeedSize-1)); err == nil {
+		t.Fatal("Created decapsulation key from invalid seed")
+	}
+
+	key, err := NewDecapsulationKey(make([]byte, SeedSize))
+	if err != nil {
+		t.Fatalf("Failed to create decapsulation key: %v", err)
+	}
+	if _, err = key.Decapsulate(make([]byte, CiphertextSize+1)); err == nil {
+		t.Fatal("Decapsulated invalid ciphertext")
+	}
+	if _, _, err = Encapsulate(rand.Reader, key.EncapsulationKey()[1:]); err == nil {
+		t.Fatal("Encapsulated for invalid encapsulation key")
+	}
+
+	encapsulationKey := key.EncapsulationKey()
+	encapsulationKey[0], encapsulationKey[1] = 0xff, 0xff // Coefficient >= q
+	if _, _, err = Encapsulate(rand.Reader, encapsulationKey); err == nil {
+		t.Fatal("Encapsulated for non-reduced encapsulation key")
+	}
+}
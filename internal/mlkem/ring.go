@@ -0,0 +1,209 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import "golang.org/x/crypto/sha3"
+
+// ringElement is a polynomial of the ring Z_q[X]/(X^256 + 1).
+// Its coefficients are always reduced modulo q.
+type ringElement [n]uint16
+
+// zetas are the powers 17^BitRev7(i) mod q and gammas
+// are the powers 17^(2·BitRev7(i)+1) mod q. 17 is a
+// primitive 256-th root of unity modulo q.
+var zetas, gammas [128]uint16
+
+func init() {
+	pow := func(e int) uint16 {
+		r := uint32(1)
+		for i := 0; i < e; i++ {
+			r = r * 17 % q
+		}
+		return uint16(r)
+	}
+	for i := range zetas {
+		rev := 0
+		for b := 0; b < 7; b++ {
+			rev |= (i >> uint(b) & 1) << uint(6-b)
+		}
+		zetas[i] = pow(rev)
+		gammas[i] = pow(2*rev + 1)
+	}
+}
+
+func fieldAdd(a, b uint16) uint16 { return uint16((uint32(a) + uint32(b)) % q) }
+
+func fieldSub(a, b uint16) uint16 { return uint16((uint32(a) + q - uint32(b)) % q) }
+
+func fieldMul(a, b uint16) uint16 { return uint16(uint32(a) * uint32(b) % q) }
+
+// add computes c = a + b.
+func add(a, b, c *ringElement) {
+	for i := range c {
+		c[i] = fieldAdd(a[i], b[i])
+	}
+}
+
+// sub computes c = a - b.
+func sub(a, b, c *ringElement) {
+	for i := range c {
+		c[i] = fieldSub(a[i], b[i])
+	}
+}
+
+// ntt transforms f into its NTT representation in place.
+// See: FIPS 203, Algorithm 9
+func ntt(f *ringElement) {
+	i := 1
+	for length := 128; length >= 2; length /= 2 {
+		for start := 0; start < n; start += 2 * length {
+			zeta := zetas[i]
+			i++
+			for j := start; j < start+length; j++ {
+				t := fieldMul(zeta, f[j+length])
+				f[j+length] = fieldSub(f[j], t)
+				f[j] = fieldAdd(f[j], t)
+			}
+		}
+	}
+}
+
+// inverseNTT transforms f from its NTT representation
+// in place. See: FIPS 203, Algorithm 10
+func inverseNTT(f *ringElement) {
+	i := 127
+	for length := 2; length <= 128; length *= 2 {
+		for start := 0; start < n; start += 2 * length {
+			zeta := zetas[i]
+			i--
+			for j := start; j < start+length; j++ {
+				t := f[j]
+				f[j] = fieldAdd(t, f[j+length])
+				f[j+length] = fieldMul(zeta, fieldSub(f[j+length], t))
+			}
+		}
+	}
+	for j := range f {
+		f[j] = fieldMul(f[j], 3303) // 3303 = 128^-1 mod q
+	}
+}
+
+// multiplyNTTs computes h = f·g for f and g in NTT
+// representation. See: FIPS 203, Algorithm 11
+func multiplyNTTs(f, g, h *ringElement) {
+	for i := 0; i < n/2; i++ {
+		a0, a1 := f[2*i], f[2*i+1]
+		b0, b1 := g[2*i], g[2*i+1]
+		h[2*i] = fieldAdd(fieldMul(a0, b0), fieldMul(fieldMul(a1, b1), gammas[i]))
+		h[2*i+1] = fieldAdd(fieldMul(a0, b1), fieldMul(a1, b0))
+	}
+}
+
+// sampleNTT samples a ring element in NTT representation
+// uniformly from the seed ρ and the indices j and i.
+// See: FIPS 203, Algorithm 7
+func sampleNTT(ρ []byte, j, i byte, a *ringElement) {
+	xof := sha3.NewShake128()
+	xof.Write(ρ)
+	xof.Write([]byte{j, i})
+
+	var buf [168]byte // The SHAKE128 rate
+	xof.Read(buf[:])
+	for off, c := 0, 0; c < n; off += 3 {
+		if off == len(buf) {
+			xof.Read(buf[:])
+			off = 0
+		}
+		d1 := uint16(buf[off]) | uint16(buf[off+1]&0x0f)<<8
+		d2 := uint16(buf[off+1])>>4 | uint16(buf[off+2])<<4
+		if d1 < q {
+			a[c] = d1
+			c++
+		}
+		if d2 < q && c < n {
+			a[c] = d2
+			c++
+		}
+	}
+}
+
+// samplePolyCBD samples a ring element from the centered
+// binomial distribution D_η using the 64·η bytes of b.
+// See: FIPS 203, Algorithm 8
+func samplePolyCBD(b []byte, η int, f *ringElement) {
+	bit := func(i int) uint16 { return uint16(b[i/8] >> uint(i%8) & 1) }
+	for i := range f {
+		var x, y uint16
+		for j := 0; j < η; j++ {
+			x += bit(2*i*η + j)
+			y += bit(2*i*η + η + j)
+		}
+		f[i] = fieldSub(x, y)
+	}
+}
+
+// byteEncode12 encodes the 12 bit coefficients of f.
+func byteEncode12(f *ringElement) []byte {
+	b := make([]byte, 0, 384)
+	for i := 0; i < n; i += 2 {
+		x := uint32(f[i]) | uint32(f[i+1])<<12
+		b = append(b, byte(x), byte(x>>8), byte(x>>16))
+	}
+	return b
+}
+
+// byteDecode12 decodes the 384 bytes of b into f. It
+// reduces each coefficient modulo q.
+func byteDecode12(b []byte, f *ringElement) {
+	for i := 0; i < n; i += 2 {
+		x := uint32(b[3*i/2]) | uint32(b[3*i/2+1])<<8 | uint32(b[3*i/2+2])<<16
+		f[i] = uint16(x&0xfff) % q
+		f[i+1] = uint16(x>>12) % q
+	}
+}
+
+// compressEncode compresses the coefficients of f to d
+// bits and encodes them. See: FIPS 203, 4.2.1
+func compressEncode(f *ringElement, d uint) []byte {
+	b := make([]byte, 32*d)
+	var (
+		acc  uint32
+		bits uint
+		off  int
+	)
+	for _, x := range f {
+		y := ((uint32(x)<<d + q/2) / q) & (1<<d - 1)
+		acc |= y << bits
+		bits += d
+		for bits >= 8 {
+			b[off] = byte(acc)
+			off++
+			acc >>= 8
+			bits -= 8
+		}
+	}
+	return b
+}
+
+// decodeDecompress decodes the d bit values of b and
+// decompresses them into the coefficients of f.
+func decodeDecompress(b []byte, d uint, f *ringElement) {
+	var (
+		acc  uint32
+		bits uint
+		off  int
+	)
+	for i := range f {
+		for bits < d {
+			acc |= uint32(b[off]) << bits
+			off++
+			bits += 8
+		}
+		y := acc & (1<<d - 1)
+		acc >>= d
+		bits -= d
+		f[i] = uint16((y*q + 1<<(d-1)) >> d)
+	}
+}
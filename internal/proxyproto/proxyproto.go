@@ -0,0 +1,240 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package proxyproto implements the server side of the
+// PROXY protocol version 2. A load balancer - like HAProxy
+// or an AWS NLB - that forwards TCP connections sends a
+// PROXY protocol header with the address of the actual
+// client before any application data.
+//
+// See: https://www.haproxy.org/download/2.4/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// signature is the PROXY protocol v2 header signature.
+var signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	cmdLocal = 0x0
+	cmdProxy = 0x1
+
+	familyUnspec = 0x0
+	familyInet   = 0x1
+	familyInet6  = 0x2
+)
+
+var (
+	errInvalidSignature = errors.New("proxyproto: invalid PROXY protocol v2 signature")
+	errInvalidVersion   = errors.New("proxyproto: unsupported PROXY protocol version")
+	errInvalidCommand   = errors.New("proxyproto: unsupported PROXY protocol command")
+	errInvalidAddress   = errors.New("proxyproto: invalid PROXY protocol address")
+)
+
+// ReadHeader reads a PROXY protocol v2 header from r and
+// returns the address of the client. It returns nil if the
+// header does not contain a client address - e.g. for health
+// checks of the proxy itself (LOCAL command) or for protocols
+// other than TCP over IPv4 or IPv6.
+func ReadHeader(r io.Reader) (net.Addr, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:12], signature) {
+		return nil, errInvalidSignature
+	}
+	if version := header[12] >> 4; version != 2 {
+		return nil, errInvalidVersion
+	}
+
+	// The header is followed by the addresses and optional
+	// TLVs. We only need the addresses and discard the rest.
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	switch command := header[12] & 0x0F; command {
+	case cmdLocal:
+		return nil, nil
+	case cmdProxy:
+	default:
+		return nil, errInvalidCommand
+	}
+
+	switch family := header[13] >> 4; family {
+	case familyInet:
+		if len(payload) < 12 {
+			return nil, errInvalidAddress
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:10])),
+		}, nil
+	case familyInet6:
+		if len(payload) < 36 {
+			return nil, errInvalidAddress
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:34])),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Listener is a net.Listener that reads the PROXY protocol
+// header of connections from trusted proxies. The remote
+// address of such a connection is the address of the actual
+// client sent by the proxy.
+//
+// Connections from any other address are accepted as they
+// are. Hence, a client cannot spoof its address by sending
+// a PROXY protocol header itself.
+type Listener struct {
+	net.Listener
+
+	// Trusted are the networks of the proxies that send
+	// a PROXY protocol header.
+	Trusted []*net.IPNet
+
+	// Timeout is the max. time a trusted proxy may take
+	// to send the PROXY protocol header. If <= 0, it
+	// defaults to 10 seconds.
+	Timeout time.Duration
+
+	// ErrorLog is an optional logger for connections
+	// with an invalid PROXY protocol header. If nil,
+	// logging is done via the log package's standard
+	// logger.
+	ErrorLog *log.Logger
+
+	once      sync.Once
+	closeOnce sync.Once
+	accepted  chan accepted
+	done      chan struct{}
+}
+
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// Accept waits for and returns the next connection.
+//
+// The PROXY protocol header of a connection is read
+// concurrently such that a slow or malicious proxy
+// cannot block other connections.
+func (l *Listener) Accept() (net.Conn, error) {
+	l.once.Do(l.init)
+	select {
+	case a := <-l.accepted:
+		return a.conn, a.err
+	case <-l.done:
+		return nil, errClosed
+	}
+}
+
+// Close closes the listener. Any blocked Accept
+// operations will be unblocked and return errors.
+func (l *Listener) Close() error {
+	l.once.Do(l.init)
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+var errClosed = errors.New("proxyproto: listener closed")
+
+func (l *Listener) init() {
+	l.accepted = make(chan accepted)
+	l.done = make(chan struct{})
+	go l.acceptLoop()
+}
+
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.accepted <- accepted{err: err}:
+			case <-l.done:
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.handshake(conn)
+	}
+}
+
+// handshake reads the PROXY protocol header, if the
+// connection has been established by a trusted proxy,
+// and passes the connection to Accept.
+func (l *Listener) handshake(conn net.Conn) {
+	if l.isTrusted(conn.RemoteAddr()) {
+		timeout := l.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		addr, err := ReadHeader(conn)
+		if err != nil {
+			l.logf("proxyproto: failed to read PROXY protocol header from '%v': %v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+		if addr != nil {
+			conn = &proxyConn{Conn: conn, remoteAddr: addr}
+		}
+	}
+
+	select {
+	case l.accepted <- accepted{conn: conn}:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.Trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Listener) logf(format string, v ...interface{}) {
+	if l.ErrorLog == nil {
+		log.Printf(format, v...)
+	} else {
+		l.ErrorLog.Printf(format, v...)
+	}
+}
+
+// proxyConn is a net.Conn whose remote address
+// is the client address sent by the proxy.
+type proxyConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr { return c.remoteAddr }
@@ -0,0 +1,104 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+)
+
+var readHeaderTests = []struct {
+	Header string // hex-encoded
+	Addr   string
+	Err    error
+}{
+	{ // 0: TCP over IPv4 - 192.0.2.1:56324 -> 10.0.0.1:7373
+		Header: "0d0a0d0a000d0a515549540a" + "2111000c" + "c0000201" + "0a000001" + "dc04" + "1ccd",
+		Addr:   "192.0.2.1:56324",
+	},
+	{ // 1: TCP over IPv6 - [2001:db8::1]:56324 -> [2001:db8::2]:7373
+		Header: "0d0a0d0a000d0a515549540a" + "21210024" + "20010db8000000000000000000000001" + "20010db8000000000000000000000002" + "dc04" + "1ccd",
+		Addr:   "[2001:db8::1]:56324",
+	},
+	{ // 2: LOCAL command - e.g. a health check
+		Header: "0d0a0d0a000d0a515549540a" + "20000000",
+	},
+	{ // 3: TCP over IPv4 with a TLV
+		Header: "0d0a0d0a000d0a515549540a" + "21110010" + "c0000201" + "0a000001" + "dc04" + "1ccd" + "04000100",
+		Addr:   "192.0.2.1:56324",
+	},
+	{ // 4: PROXY protocol v1 is not supported
+		Header: "0d0a0d0a000d0a515549540a" + "1111000c" + "c0000201" + "0a000001" + "dc04" + "1ccd",
+		Err:    errInvalidVersion,
+	},
+	{ // 5: invalid signature
+		Header: "0d0a0d0a000d0a515549540b" + "2111000c" + "c0000201" + "0a000001" + "dc04" + "1ccd",
+		Err:    errInvalidSignature,
+	},
+	{ // 6: address too short
+		Header: "0d0a0d0a000d0a515549540a" + "21110004" + "c0000201",
+		Err:    errInvalidAddress,
+	},
+}
+
+func TestReadHeader(t *testing.T) {
+	for i, test := range readHeaderTests {
+		header, err := hex.DecodeString(test.Header)
+		if err != nil {
+			t.Fatalf("Test %d: invalid test header: %v", i, err)
+		}
+		addr, err := ReadHeader(bytes.NewReader(header))
+		if err != test.Err {
+			t.Fatalf("Test %d: got error %v - want %v", i, err, test.Err)
+		}
+		if test.Addr == "" && addr != nil {
+			t.Fatalf("Test %d: got address %v - want none", i, addr)
+		}
+		if test.Addr != "" && (addr == nil || addr.String() != test.Addr) {
+			t.Fatalf("Test %d: got address %v - want %s", i, addr, test.Addr)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	listener := &Listener{
+		Listener: tcpListener,
+		Trusted:  []*net.IPNet{loopback},
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	defer listener.Close()
+
+	header, _ := hex.DecodeString(readHeaderTests[0].Header)
+	conn, err := net.Dial("tcp", tcpListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write(append(header, "hello"...)); err != nil {
+		t.Fatalf("Failed to send PROXY protocol header: %v", err)
+	}
+
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept connection: %v", err)
+	}
+	defer accepted.Close()
+	if addr := accepted.RemoteAddr().String(); addr != readHeaderTests[0].Addr {
+		t.Fatalf("Invalid remote address: got %s - want %s", addr, readHeaderTests[0].Addr)
+	}
+	var data [5]byte
+	if _, err = accepted.Read(data[:]); err != nil || string(data[:]) != "hello" {
+		t.Fatalf("Failed to read data after PROXY protocol header: %v - %q", err, data)
+	}
+}
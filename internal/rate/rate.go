@@ -0,0 +1,153 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package rate implements a token bucket rate limiter.
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter. It allows
+// events up to Rate events per second and permits
+// bursts of at most Burst events.
+//
+// Initially, the bucket is full - i.e. a Limiter
+// allows Burst events at once before it starts
+// limiting.
+//
+// A Limiter with a Rate <= 0 does not limit any
+// events. Its zero value is a usable Limiter that
+// allows all events.
+type Limiter struct {
+	// Rate is the number of events per second.
+	// If Rate <= 0 the Limiter allows all events.
+	//
+	// It must not be modified once the Limiter
+	// has been used.
+	Rate float64
+
+	// Burst is the max. number of events that
+	// can happen at once. If Burst < 1 the
+	// Limiter uses a burst of 1.
+	//
+	// It must not be modified once the Limiter
+	// has been used.
+	Burst int
+
+	lock   sync.Mutex
+	tokens float64   // The number of available tokens
+	last   time.Time // The last time the bucket has been refilled
+	init   bool      // Set to true once the bucket has been filled
+}
+
+// Allow reports whether an event may happen now.
+// If Allow returns true the event consumes a token
+// from the bucket.
+//
+// Allow should be used when events that exceed the
+// rate limit should be dropped or rejected.
+func (l *Limiter) Allow() bool {
+	if l.Rate <= 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.refill(time.Now())
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Delay returns the duration until the next
+// event may happen. It returns 0 if an event
+// may happen now.
+//
+// Delay does not consume any token.
+func (l *Limiter) Delay() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.refill(time.Now())
+	if l.tokens >= 1 {
+		return 0
+	}
+	return l.duration(1 - l.tokens)
+}
+
+// Wait blocks until an event may happen or the
+// ctx is done. If the ctx is done before the event
+// may happen Wait returns ctx.Err() and does not
+// consume any token.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.Rate <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	l.refill(time.Now())
+	l.tokens-- // Reserve a token - the bucket may now be in debt
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = l.duration(-l.tokens)
+	}
+	l.lock.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.lock.Lock()
+		l.tokens++ // Return the reserved token
+		l.lock.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill adds all tokens to the bucket that
+// have been accumulated since the last refill.
+//
+// The caller must hold the lock.
+func (l *Limiter) refill(now time.Time) {
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if !l.init {
+		l.init = true
+		l.tokens = burst
+		l.last = now
+		return
+	}
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.Rate
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+	}
+	l.last = now
+}
+
+// duration returns the time it takes to
+// accumulate the given number of tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var limiterAllowTests = []struct {
+	Rate    float64
+	Burst   int
+	Allowed int // Number of events allowed immediately
+}{
+	{Rate: 0, Burst: 0, Allowed: 100},    // 0
+	{Rate: -1, Burst: 5, Allowed: 100},   // 1
+	{Rate: 1, Burst: 0, Allowed: 1},      // 2
+	{Rate: 1, Burst: 1, Allowed: 1},      // 3
+	{Rate: 0.5, Burst: 10, Allowed: 10},  // 4
+	{Rate: 1000, Burst: 50, Allowed: 50}, // 5
+}
+
+func TestLimiterAllow(t *testing.T) {
+	for i, test := range limiterAllowTests {
+		limiter := Limiter{Rate: test.Rate, Burst: test.Burst}
+
+		var allowed int
+		for j := 0; j < 100; j++ {
+			if limiter.Allow() {
+				allowed++
+			}
+		}
+		// For high rates some tokens may be refilled while
+		// iterating. Therefore, we only check a lower bound.
+		if allowed < test.Allowed {
+			t.Fatalf("Test %d: got %d allowed events - want at least %d", i, allowed, test.Allowed)
+		}
+		if test.Rate > 0 && test.Rate < 100 && allowed != test.Allowed {
+			t.Fatalf("Test %d: got %d allowed events - want %d", i, allowed, test.Allowed)
+		}
+	}
+}
+
+func TestLimiterDelay(t *testing.T) {
+	limiter := Limiter{Rate: 1, Burst: 1}
+	if delay := limiter.Delay(); delay != 0 {
+		t.Fatalf("Full bucket should not delay: got %v", delay)
+	}
+	if !limiter.Allow() {
+		t.Fatal("Full bucket should allow an event")
+	}
+	if delay := limiter.Delay(); delay <= 0 || delay > time.Second {
+		t.Fatalf("Empty bucket should delay (0, 1s]: got %v", delay)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	limiter := Limiter{Rate: 1, Burst: 1}
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Full bucket should not block: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait should have been canceled: got %v - want %v", err, context.Canceled)
+	}
+	if limiter.tokens < -0.01 { // A canceled Wait must return its token
+		t.Fatalf("Canceled Wait consumed a token: bucket contains %f tokens", limiter.tokens)
+	}
+}
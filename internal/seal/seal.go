@@ -0,0 +1,428 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package seal protects the secrets at a key store with
+// a root key that is split into key shares. The server
+// starts sealed and cannot access any secret until a
+// threshold of key shares has been submitted.
+//
+// Optionally, the root key can also be protected by a
+// KMS such that the server unseals itself on startup.
+// See: Barrier.AutoUnseal
+package seal
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secret"
+)
+
+var (
+	// ErrSealed is returned by a Barrier that has
+	// not been unsealed yet.
+	ErrSealed = kes.NewError(http.StatusServiceUnavailable, "server is sealed")
+
+	// ErrNotInitialized is returned by Unseal if
+	// the Barrier has not been initialized.
+	ErrNotInitialized = kes.NewError(http.StatusConflict, "seal is not initialized")
+
+	// ErrInitialized is returned by Init if the
+	// Barrier has already been initialized.
+	ErrInitialized = kes.NewError(http.StatusConflict, "seal is already initialized")
+
+	// ErrUnsealed is returned by Unseal if the
+	// Barrier has already been unsealed.
+	ErrUnsealed = kes.NewError(http.StatusConflict, "server is already unsealed")
+
+	// ErrInvalidShares is returned by Unseal if the
+	// submitted key shares do not reconstruct the
+	// root key. All submitted shares are discarded.
+	ErrInvalidShares = kes.NewError(http.StatusBadRequest, "key shares are invalid")
+
+	errInvalidShare  = kes.NewError(http.StatusBadRequest, "key share is malformed")
+	errInvalidConfig = kes.NewError(http.StatusBadRequest, "invalid seal config: threshold must be between 1 and the number of shares - at most 255")
+)
+
+// checkContext is the associated data of the ciphertext
+// used to verify a reconstructed root key.
+const checkContext = "kes seal check"
+
+// kmsContext is the KMS context of the root key
+// encrypted by the KMS.
+const kmsContext = "kes seal root key"
+
+// State describes the state of a Barrier.
+type State struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+
+	// AutoUnseal reports whether the root key is
+	// protected by a KMS such that the Barrier can
+	// unseal itself. See: AutoUnseal
+	AutoUnseal bool `json:"auto_unseal,omitempty"`
+
+	// Threshold is the number of key shares required
+	// to unseal the Barrier and Shares is the number
+	// of key shares the root key has been split into.
+	Threshold int `json:"threshold,omitempty"`
+	Shares    int `json:"shares,omitempty"`
+
+	// Progress is the number of key shares submitted
+	// since the Barrier has been sealed.
+	Progress int `json:"progress"`
+}
+
+// Barrier is a secret.KMS that encrypts secrets with
+// a root key. The root key is split into key shares
+// when the Barrier is initialized and never stored in
+// plaintext.
+// Once a threshold of key shares has been submitted,
+// the Barrier reconstructs the root key and is unsealed.
+// See: Init and Unseal
+//
+// While sealed, a Barrier fails to encrypt or decrypt
+// any secret with ErrSealed.
+type Barrier struct {
+	// Remote is the Remote store at which the seal
+	// configuration is stored under the name
+	// secret.ReservedSealName.
+	Remote secret.Remote
+
+	// KMS is an optional KMS. If set, the root key is
+	// also encrypted with the KMS master key and stored
+	// at the Remote store such that the Barrier can unseal
+	// itself without key shares. The key shares remain
+	// valid to unseal the Barrier if the KMS is not
+	// available. See: AutoUnseal
+	KMS secret.KMS
+
+	// Rand is an optional source of random bytes used
+	// to generate the root key and its key shares. If
+	// nil, crypto/rand.Reader is used. See: secret.Store.Rand
+	Rand io.Reader
+
+	lock   sync.RWMutex
+	config *config        // The seal configuration - nil if not initialized
+	key    *secret.Secret // The root key - nil while sealed
+	shares [][]byte       // Key shares submitted while sealed
+}
+
+var _ secret.KMS = (*Barrier)(nil)
+
+// rand returns the Barrier's source of random bytes.
+func (b *Barrier) rand() io.Reader {
+	if b.Rand != nil {
+		return b.Rand
+	}
+	return rand.Reader
+}
+
+// config is the seal configuration persisted at
+// the Remote store.
+type config struct {
+	Threshold int `json:"threshold"`
+	Shares    int `json:"shares"`
+
+	// Check is the empty plaintext encrypted with the
+	// root key. Unseal verifies a reconstructed root key
+	// by decrypting it.
+	Check []byte `json:"check"`
+
+	// Key is the root key encrypted with the KMS master
+	// key. It is empty if no KMS has been used.
+	Key []byte `json:"key,omitempty"`
+}
+
+// Load reads the seal configuration from the Remote
+// store. The Barrier remains sealed. It does nothing
+// if the Barrier has not been initialized yet.
+func (b *Barrier) Load() error {
+	value, err := secret.Load(b.Remote, secret.ReservedSealName)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var c config
+	if err = json.Unmarshal([]byte(value), &c); err != nil || c.Threshold < 1 || len(c.Check) == 0 {
+		return errors.New("seal: persisted seal config is malformed")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.config = &c
+	return nil
+}
+
+// State returns the current state of the Barrier.
+func (b *Barrier) State() State {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.state()
+}
+
+// Init generates a new root key, splits it into the given
+// number of key shares and returns them. Any threshold of
+// them can unseal the Barrier. The key shares are never
+// stored. Hence, they must be distributed to their holders
+// immediately.
+//
+// Once initialized, the Barrier is unsealed. Init returns
+// ErrInitialized if the Barrier has already been initialized.
+func (b *Barrier) Init(shares, threshold int) ([][]byte, error) {
+	if threshold < 1 || threshold > shares || shares > 255 {
+		return nil, errInvalidConfig
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.config != nil {
+		return nil, ErrInitialized
+	}
+
+	var key secret.Secret
+	if _, err := io.ReadFull(b.rand(), key[:]); err != nil {
+		return nil, err
+	}
+
+	keyShares, err := Split(b.rand(), key[:], shares, threshold)
+	if err != nil {
+		return nil, err
+	}
+	check, err := key.Wrap(nil, []byte(checkContext))
+	if err != nil {
+		return nil, err
+	}
+	c := config{
+		Threshold: threshold,
+		Shares:    shares,
+		Check:     check,
+	}
+	if b.KMS != nil {
+		if c.Key, err = b.KMS.Encrypt(key[:], kmsContext); err != nil {
+			return nil, err
+		}
+	}
+	value, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// An interrupted storeKey may have left the seal
+	// configuration under its replace entry only.
+	if _, err = secret.Load(b.Remote, secret.ReservedSealName); err == nil {
+		return nil, ErrInitialized
+	} else if err != kes.ErrKeyNotFound {
+		return nil, err
+	}
+	switch err = b.Remote.Create(secret.ReservedSealName, string(value)); err {
+	case nil:
+	case kes.ErrKeyExists: // Another server has been faster
+		return nil, ErrInitialized
+	default:
+		return nil, err
+	}
+
+	b.config = &c
+	b.key = &key
+	b.wipeShares()
+	return keyShares, nil
+}
+
+// Unseal submits a key share. Once a threshold of key
+// shares has been submitted, Unseal reconstructs the root
+// key and unseals the Barrier. It returns the state of the
+// Barrier after the key share has been submitted.
+//
+// Submitting the same key share more than once has no
+// effect.
+func (b *Barrier) Unseal(share []byte) (State, error) {
+	if len(share) != len(secret.Secret{})+1 {
+		return b.State(), errInvalidShare
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.config == nil {
+		return b.state(), ErrNotInitialized
+	}
+	if b.key != nil {
+		return b.state(), ErrUnsealed
+	}
+	for _, s := range b.shares {
+		if s[len(s)-1] == share[len(share)-1] {
+			if secret.Equal(s, share) {
+				return b.state(), nil
+			}
+			return b.state(), errInvalidShare
+		}
+	}
+	b.shares = append(b.shares, append([]byte(nil), share...))
+	if len(b.shares) < b.config.Threshold {
+		return b.state(), nil
+	}
+
+	plaintext, err := Combine(b.shares)
+	b.wipeShares()
+	if err != nil {
+		return b.state(), ErrInvalidShares
+	}
+	var key secret.Secret
+	copy(key[:], plaintext)
+	secret.Wipe(plaintext)
+	if _, err = key.Unwrap(b.config.Check, []byte(checkContext)); err != nil {
+		key.Destroy()
+		return b.state(), ErrInvalidShares
+	}
+	b.key = &key
+
+	// A seal that has been initialized without a KMS
+	// can unseal itself once the root key has been
+	// encrypted with the KMS.
+	if b.KMS != nil && len(b.config.Key) == 0 {
+		if err = b.storeKey(key); err != nil {
+			return b.state(), fmt.Errorf("seal: failed to enable auto-unseal: %v", err)
+		}
+	}
+	return b.state(), nil
+}
+
+// AutoUnseal decrypts the root key with the KMS and
+// unseals the Barrier. It returns an error if the
+// Barrier has no KMS or the root key has not been
+// encrypted with the KMS yet - i.e. the Barrier has
+// been initialized without a KMS and not been unsealed
+// with a KMS since. It does nothing if the Barrier is
+// not sealed.
+func (b *Barrier) AutoUnseal() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.config == nil {
+		return ErrNotInitialized
+	}
+	if b.key != nil {
+		return nil
+	}
+	if b.KMS == nil || len(b.config.Key) == 0 {
+		return errors.New("seal: root key is not protected by a KMS")
+	}
+	plaintext, err := b.KMS.Decrypt(b.config.Key, kmsContext)
+	if err != nil {
+		return err
+	}
+
+	defer secret.Wipe(plaintext)
+
+	var key secret.Secret
+	if len(plaintext) != len(key) {
+		return errors.New("seal: root key is malformed")
+	}
+	copy(key[:], plaintext)
+	if _, err = key.Unwrap(b.config.Check, []byte(checkContext)); err != nil {
+		key.Destroy()
+		return errors.New("seal: root key does not match the seal")
+	}
+	b.key = &key
+	b.wipeShares()
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the root key and
+// binds the context to the ciphertext. It returns ErrSealed
+// if the Barrier is sealed.
+func (b *Barrier) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	b.lock.RLock()
+	key := b.key
+	b.lock.RUnlock()
+
+	if key == nil {
+		return nil, ErrSealed
+	}
+	return key.Wrap(plaintext, []byte(context))
+}
+
+// Decrypt decrypts the ciphertext with the root key and
+// verifies the context. It returns ErrSealed if the
+// Barrier is sealed.
+func (b *Barrier) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	b.lock.RLock()
+	key := b.key
+	b.lock.RUnlock()
+
+	if key == nil {
+		return nil, ErrSealed
+	}
+	return key.Unwrap(ciphertext, []byte(context))
+}
+
+// Status returns ErrSealed if the Barrier is sealed.
+// It implements secret.StatusChecker such that a sealed
+// server does not report itself as ready.
+func (b *Barrier) Status() error {
+	if b.State().Sealed {
+		return ErrSealed
+	}
+	return nil
+}
+
+// storeKey encrypts the root key with the KMS and adds
+// it to the seal configuration at the Remote store. The
+// caller must hold the lock.
+//
+// storeKey replaces the entry via secret.Replace. It
+// never deletes the only seal configuration. Otherwise,
+// the root key could not be reconstructed anymore if
+// storeKey fails or the server stops in between.
+func (b *Barrier) storeKey(key secret.Secret) error {
+	ciphertext, err := b.KMS.Encrypt(key[:], kmsContext)
+	if err != nil {
+		return err
+	}
+	c := *b.config
+	c.Key = ciphertext
+	value, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = secret.Replace(b.Remote, secret.ReservedSealName, string(value)); err != nil {
+		return err
+	}
+	b.config = &c
+	return nil
+}
+
+// wipeShares overwrites and discards all submitted
+// key shares. The caller must hold the lock.
+func (b *Barrier) wipeShares() {
+	for _, share := range b.shares {
+		secret.Wipe(share)
+	}
+	b.shares = nil
+}
+
+// state returns the current state of the Barrier.
+// The caller must hold the lock.
+func (b *Barrier) state() State {
+	if b.config == nil {
+		return State{Sealed: true}
+	}
+	return State{
+		Initialized: true,
+		Sealed:      b.key == nil,
+		AutoUnseal:  b.KMS != nil && len(b.config.Key) > 0,
+		Threshold:   b.config.Threshold,
+		Shares:      b.config.Shares,
+		Progress:    len(b.shares),
+	}
+}
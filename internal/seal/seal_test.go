@@ -0,0 +1,200 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package seal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+)
+
+func TestBarrier(t *testing.T) {
+	remote := &mem.Store{}
+	barrier := &Barrier{Remote: remote}
+	if err := barrier.Load(); err != nil {
+		t.Fatalf("Failed to load uninitialized barrier: %v", err)
+	}
+	if state := barrier.State(); state.Initialized || !state.Sealed {
+		t.Fatalf("Invalid state of uninitialized barrier: %+v", state)
+	}
+	if _, err := barrier.Encrypt([]byte("plaintext"), "context"); err != ErrSealed {
+		t.Fatalf("Uninitialized barrier encrypted a plaintext: %v", err)
+	}
+
+	shares, err := barrier.Init(5, 3)
+	if err != nil {
+		t.Fatalf("Failed to initialize barrier: %v", err)
+	}
+	if _, err = barrier.Init(5, 3); err != ErrInitialized {
+		t.Fatalf("Barrier has been initialized twice: %v", err)
+	}
+	ciphertext, err := barrier.Encrypt([]byte("plaintext"), "context")
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+
+	// A restarted server must be unsealed with
+	// a threshold of key shares.
+	barrier = &Barrier{Remote: remote}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if _, err = barrier.Decrypt(ciphertext, "context"); err != ErrSealed {
+		t.Fatalf("Sealed barrier decrypted a ciphertext: %v", err)
+	}
+	for i, share := range shares[1:4] {
+		if _, err = barrier.Unseal(share); err != nil {
+			t.Fatalf("Failed to submit key share %d: %v", i, err)
+		}
+		if i == 0 {
+			if state, _ := barrier.Unseal(share); state.Progress != 1 {
+				t.Fatalf("Duplicate key share has been counted: %+v", state)
+			}
+		}
+	}
+	if state := barrier.State(); state.Sealed || state.Threshold != 3 || state.Shares != 5 {
+		t.Fatalf("Invalid state of unsealed barrier: %+v", state)
+	}
+	plaintext, err := barrier.Decrypt(ciphertext, "context")
+	if err != nil {
+		t.Fatalf("Failed to decrypt ciphertext: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("plaintext")) {
+		t.Fatalf("Invalid plaintext: got %q - want %q", plaintext, "plaintext")
+	}
+	if _, err = barrier.Decrypt(ciphertext, "other-context"); err == nil {
+		t.Fatal("Barrier decrypted a ciphertext with a different context")
+	}
+}
+
+func TestBarrierInvalidShares(t *testing.T) {
+	remote := &mem.Store{}
+	if _, err := (&Barrier{Remote: remote}).Init(3, 2); err != nil {
+		t.Fatalf("Failed to initialize barrier: %v", err)
+	}
+	otherShares, err := (&Barrier{Remote: &mem.Store{}}).Init(3, 2)
+	if err != nil {
+		t.Fatalf("Failed to initialize barrier: %v", err)
+	}
+
+	barrier := &Barrier{Remote: remote}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if _, err = barrier.Unseal(otherShares[0]); err != nil {
+		t.Fatalf("Failed to submit key share: %v", err)
+	}
+	if _, err = barrier.Unseal(otherShares[1]); err != ErrInvalidShares {
+		t.Fatalf("Barrier has been unsealed with key shares of a different root key: %v", err)
+	}
+	if state := barrier.State(); !state.Sealed || state.Progress != 0 {
+		t.Fatalf("Invalid state after invalid key shares: %+v", state)
+	}
+	if _, err = barrier.Unseal(make([]byte, len(secret.Secret{}))); err == nil {
+		t.Fatal("Barrier accepted a malformed key share")
+	}
+}
+
+func TestBarrierAutoUnseal(t *testing.T) {
+	// An unsealed Barrier is a KMS as well.
+	kms := &Barrier{Remote: &mem.Store{}}
+	if _, err := kms.Init(1, 1); err != nil {
+		t.Fatalf("Failed to initialize KMS: %v", err)
+	}
+
+	remote := &mem.Store{}
+	shares, err := (&Barrier{Remote: remote}).Init(3, 2)
+	if err != nil {
+		t.Fatalf("Failed to initialize barrier: %v", err)
+	}
+
+	// A seal initialized without a KMS cannot unseal itself
+	// until it has been unsealed with the key shares once.
+	barrier := &Barrier{Remote: remote, KMS: kms}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if err = barrier.AutoUnseal(); err == nil {
+		t.Fatal("Barrier unsealed itself without an encrypted root key")
+	}
+	for i, share := range shares[:2] {
+		if _, err = barrier.Unseal(share); err != nil {
+			t.Fatalf("Failed to submit key share %d: %v", i, err)
+		}
+	}
+	if state := barrier.State(); state.Sealed || !state.AutoUnseal {
+		t.Fatalf("Invalid state of unsealed barrier: %+v", state)
+	}
+	ciphertext, err := barrier.Encrypt([]byte("plaintext"), "context")
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+
+	barrier = &Barrier{Remote: remote, KMS: kms}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if err = barrier.AutoUnseal(); err != nil {
+		t.Fatalf("Failed to auto-unseal barrier: %v", err)
+	}
+	if _, err = barrier.Decrypt(ciphertext, "context"); err != nil {
+		t.Fatalf("Failed to decrypt ciphertext: %v", err)
+	}
+
+	// The key shares remain valid if the KMS is not available.
+	barrier = &Barrier{Remote: remote, KMS: &Barrier{Remote: &mem.Store{}}}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if err = barrier.AutoUnseal(); err != ErrSealed {
+		t.Fatalf("Barrier unsealed itself with an unavailable KMS: %v", err)
+	}
+	for i, share := range shares[1:] {
+		if _, err = barrier.Unseal(share); err != nil {
+			t.Fatalf("Failed to submit key share %d: %v", i, err)
+		}
+	}
+	if _, err = barrier.Decrypt(ciphertext, "context"); err != nil {
+		t.Fatalf("Failed to decrypt ciphertext: %v", err)
+	}
+}
+
+func TestBarrierStoreKeyFails(t *testing.T) {
+	kms := &Barrier{Remote: &mem.Store{}}
+	if _, err := kms.Init(1, 1); err != nil {
+		t.Fatalf("Failed to initialize KMS: %v", err)
+	}
+
+	remote := &secret.FaultyRemote{Remote: &mem.Store{}}
+	shares, err := (&Barrier{Remote: remote}).Init(1, 1)
+	if err != nil {
+		t.Fatalf("Failed to initialize barrier: %v", err)
+	}
+
+	// Enabling auto-unseal must not lose the seal
+	// config if the key store fails in between.
+	remote.Inject(secret.Fault{Op: secret.OpCreate, Key: secret.ReservedSealName})
+	barrier := &Barrier{Remote: remote, KMS: kms}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if _, err = barrier.Unseal(shares[0]); err == nil {
+		t.Fatal("Storing the encrypted root key should have failed")
+	}
+
+	remote.Reset()
+	if _, err = (&Barrier{Remote: remote}).Init(1, 1); err != ErrInitialized {
+		t.Fatalf("Barrier has been initialized twice: got %v - want %v", err, ErrInitialized)
+	}
+	barrier = &Barrier{Remote: remote}
+	if err = barrier.Load(); err != nil {
+		t.Fatalf("Failed to load barrier: %v", err)
+	}
+	if state, err := barrier.Unseal(shares[0]); err != nil || state.Sealed {
+		t.Fatalf("Failed to unseal barrier: %+v, %v", state, err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package seal
+
+import (
+	"errors"
+	"io"
+)
+
+// Split splits the secret into n shares such that any
+// threshold of them can reconstruct the secret while
+// fewer shares reveal nothing about it. See: Combine
+//
+// Split uses Shamir's Secret Sharing over GF(2^8). Each
+// share is one byte longer than the secret. The last byte
+// is the x-coordinate of the share.
+//
+// The x-coordinates and polynomial coefficients are read
+// from random - e.g. crypto/rand.Reader.
+func Split(random io.Reader, secret []byte, n, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("seal: secret is empty")
+	}
+	if n < 1 || n > 255 {
+		return nil, errors.New("seal: number of shares must be between 1 and 255")
+	}
+	if threshold < 1 || threshold > n {
+		return nil, errors.New("seal: threshold must be between 1 and the number of shares")
+	}
+
+	// Each share has a distinct, non-zero x-coordinate.
+	// We pick them at random from [1, 255] by shuffling
+	// all coordinates.
+	var xs [255]byte
+	for i := range xs {
+		xs[i] = byte(i + 1)
+	}
+	var perm [len(xs)]byte
+	if _, err := io.ReadFull(random, perm[:]); err != nil {
+		return nil, err
+	}
+	for i := len(xs) - 1; i > 0; i-- {
+		j := int(perm[i]) % (i + 1)
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = xs[i]
+	}
+
+	// For each secret byte, we evaluate a random polynomial
+	// of degree threshold-1 with the secret byte as constant
+	// term at the x-coordinate of each share.
+	coefficients := make([]byte, threshold)
+	for i, b := range secret {
+		if _, err := io.ReadFull(random, coefficients[1:]); err != nil {
+			return nil, err
+		}
+		coefficients[0] = b
+
+		for _, share := range shares {
+			share[i] = evaluate(coefficients, share[len(secret)])
+		}
+	}
+	return shares, nil
+}
+
+// Combine reconstructs a secret from shares produced by
+// Split. If the shares are fewer than the threshold or
+// belong to different secrets, Combine returns a wrong
+// secret. Hence, the caller must verify the secret.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("seal: no shares")
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("seal: share is malformed")
+	}
+	xs := make([]byte, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("seal: shares have different lengths")
+		}
+		xs[i] = share[size-1]
+		if xs[i] == 0 {
+			return nil, errors.New("seal: share is malformed")
+		}
+		for _, x := range xs[:i] {
+			if x == xs[i] {
+				return nil, errors.New("seal: duplicate share")
+			}
+		}
+	}
+
+	// Lagrange interpolation at x = 0. In GF(2^8),
+	// addition and subtraction are both XOR.
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, x := range xs {
+			if i != j {
+				basis = mul(basis, div(x, x^xs[i]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= mul(share[k], basis)
+		}
+	}
+	return secret, nil
+}
+
+// evaluate returns the value of the polynomial with
+// the given coefficients - constant term first - at x.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// mul multiplies a and b in GF(2^8) modulo the AES
+// polynomial x^8 + x^4 + x^3 + x + 1. It does not
+// branch on its inputs.
+func mul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		a = (a << 1) ^ (-(a >> 7) & 0x1b)
+		b >>= 1
+	}
+	return p
+}
+
+// div divides a by b in GF(2^8). b must not be zero.
+func div(a, b byte) byte {
+	// b^254 is the multiplicative inverse of b.
+	inv := b
+	for i := 0; i < 6; i++ {
+		inv = mul(mul(inv, inv), b)
+	}
+	return mul(a, mul(inv, inv))
+}
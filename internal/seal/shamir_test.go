@@ -0,0 +1,68 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package seal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+var splitCombineTests = []struct {
+	Shares    int
+	Threshold int
+}{
+	{Shares: 1, Threshold: 1},   // 0
+	{Shares: 3, Threshold: 2},   // 1
+	{Shares: 5, Threshold: 3},   // 2
+	{Shares: 5, Threshold: 5},   // 3
+	{Shares: 255, Threshold: 7}, // 4
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	for i, test := range splitCombineTests {
+		shares, err := Split(rand.Reader, secret, test.Shares, test.Threshold)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to split secret: %v", i, err)
+		}
+		if len(shares) != test.Shares {
+			t.Fatalf("Test %d: Invalid number of shares: got %d - want %d", i, len(shares), test.Shares)
+		}
+
+		combined, err := Combine(shares[len(shares)-test.Threshold:])
+		if err != nil {
+			t.Fatalf("Test %d: Failed to combine shares: %v", i, err)
+		}
+		if !bytes.Equal(combined, secret) {
+			t.Fatalf("Test %d: Combined secret does not match: got %x - want %x", i, combined, secret)
+		}
+		if test.Threshold > 1 {
+			combined, err = Combine(shares[:test.Threshold-1])
+			if err != nil {
+				t.Fatalf("Test %d: Failed to combine shares: %v", i, err)
+			}
+			if bytes.Equal(combined, secret) {
+				t.Fatalf("Test %d: Secret has been combined from fewer than %d shares", i, test.Threshold)
+			}
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	if _, err := Split(rand.Reader, []byte("secret"), 3, 4); err == nil {
+		t.Fatal("Split accepted a threshold larger than the number of shares")
+	}
+	if _, err := Split(rand.Reader, []byte("secret"), 256, 2); err == nil {
+		t.Fatal("Split accepted more than 255 shares")
+	}
+	shares, err := Split(rand.Reader, []byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	if _, err = Combine([][]byte{shares[0], shares[0]}); err == nil {
+		t.Fatal("Combine accepted duplicate shares")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+
+	"github.com/minio/kes"
+	"github.com/secure-io/sio-go/sioutil"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// maxSealerUses is the number of plaintexts Wrap
+	// encrypts with the same derived key before it
+	// derives a new one. It is far below the 2^32
+	// messages that AES-GCM can encrypt under the same
+	// key with random nonces.
+	maxSealerUses = 1 << 20
+
+	// maxSealers is the max. number of secrets for
+	// which derived AEAD instances are cached.
+	maxSealers = 4096
+
+	// maxOpeners is the max. number of AEAD instances
+	// cached per secret for decryption - i.e. the number
+	// of distinct IVs.
+	maxOpeners = 16
+)
+
+// sealers caches the AEAD instances derived from secrets
+// such that Wrap and Unwrap don't derive a new key for every
+// plaintext or ciphertext.
+//
+// It only caches AEAD instances for secrets held by a Store
+// cache. They are removed when the secret is removed from the
+// cache - e.g. because the secret has been deleted or has not
+// been used recently. Wrap and Unwrap derive a new key for
+// every call for any other secret. See: sealerCache.Add
+var sealers sealerCache
+
+// sealerCache maps the fingerprints of secrets to
+// the AEAD instances derived from them.
+//
+// It does not contain the secrets themselves but
+// a SHA-256 hash of each secret.
+type sealerCache struct {
+	lock    sync.Mutex
+	sealers map[[sha256.Size]byte]*sealer
+}
+
+// A sealer holds the AEAD instances derived from
+// one secret.
+type sealer struct {
+	lock sync.Mutex
+	refs int // The number of cache entries holding the secret
+
+	// The AEAD currently used for encryption, its
+	// IV and the number of plaintexts encrypted.
+	algorithm string
+	iv        []byte
+	aead      cipher.AEAD
+	uses      int
+
+	openers map[openerKey]cipher.AEAD // The AEAD instances used for decryption
+}
+
+type openerKey struct {
+	algorithm string
+	iv        [16]byte
+}
+
+// Seal returns a random IV and the AEAD derived from the
+// secret and the IV for the given algorithm. If the secret
+// has been added to the cache, it returns the same IV and
+// AEAD for up to maxSealerUses calls.
+func (c *sealerCache) Seal(s *Secret, algorithm string) ([]byte, cipher.AEAD, error) {
+	sl := c.sealer(s)
+	if sl == nil {
+		iv, err := sioutil.Random(16)
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err := newAEAD(s, algorithm, iv)
+		if err != nil {
+			return nil, nil, err
+		}
+		return iv, aead, nil
+	}
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	if sl.aead == nil || sl.algorithm != algorithm || sl.uses >= maxSealerUses {
+		iv, err := sioutil.Random(16)
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err := newAEAD(s, algorithm, iv)
+		if err != nil {
+			return nil, nil, err
+		}
+		sl.algorithm, sl.iv, sl.aead, sl.uses = algorithm, iv, aead, 0
+	}
+	sl.uses++
+	return sl.iv, sl.aead, nil
+}
+
+// Open returns the AEAD derived from the secret and the
+// IV for the given algorithm. The IV must be 16 bytes long.
+func (c *sealerCache) Open(s *Secret, algorithm string, iv []byte) (cipher.AEAD, error) {
+	var key = openerKey{algorithm: algorithm}
+	copy(key.iv[:], iv)
+
+	sl := c.sealer(s)
+	if sl == nil {
+		return newAEAD(s, algorithm, iv)
+	}
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	if aead, ok := sl.openers[key]; ok {
+		return aead, nil
+	}
+	aead, err := newAEAD(s, algorithm, iv)
+	if err != nil {
+		return nil, err
+	}
+	if sl.openers == nil {
+		sl.openers = make(map[openerKey]cipher.AEAD, maxOpeners)
+	}
+	if len(sl.openers) >= maxOpeners {
+		for k := range sl.openers { // Remove a random AEAD
+			delete(sl.openers, k)
+			break
+		}
+	}
+	sl.openers[key] = aead
+	return aead, nil
+}
+
+// Add adds the secret to the cache such that Seal
+// and Open cache the AEAD instances derived from it.
+// Each Add must be followed by a Forget once the secret
+// is no longer used.
+func (c *sealerCache) Add(s *Secret) {
+	fingerprint := sha256.Sum256(s[:])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if sl, ok := c.sealers[fingerprint]; ok {
+		sl.refs++
+		return
+	}
+	if c.sealers == nil {
+		c.sealers = map[[sha256.Size]byte]*sealer{}
+	}
+	if len(c.sealers) >= maxSealers {
+		for k := range c.sealers { // Remove a random sealer
+			delete(c.sealers, k)
+			break
+		}
+	}
+	c.sealers[fingerprint] = &sealer{refs: 1}
+}
+
+// Forget undoes one Add of the secret. Once the
+// secret has been forgotten as often as it has been
+// added, Forget removes all AEAD instances derived
+// from it.
+func (c *sealerCache) Forget(s *Secret) {
+	fingerprint := sha256.Sum256(s[:])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if sl, ok := c.sealers[fingerprint]; ok {
+		if sl.refs--; sl.refs <= 0 {
+			delete(c.sealers, fingerprint)
+		}
+	}
+}
+
+// sealer returns the sealer of the secret or
+// nil if the secret has not been added.
+func (c *sealerCache) sealer(s *Secret) *sealer {
+	fingerprint := sha256.Sum256(s[:])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.sealers[fingerprint]
+}
+
+// newAEAD derives a new key from the secret and the
+// IV and returns an AEAD for the given algorithm.
+func newAEAD(s *Secret, algorithm string, iv []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case "AES-256-GCM-HMAC-SHA-256":
+		sealingKey := s.hmac(iv)
+		block, err := aes.NewCipher(sealingKey[:])
+		Wipe(sealingKey[:])
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case "ChaCha20Poly1305":
+		sealingKey, err := chacha20.HChaCha20(s[:], iv)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := chacha20poly1305.New(sealingKey)
+		Wipe(sealingKey)
+		return aead, err
+	default:
+		return nil, kes.NewError(http.StatusBadRequest, "invalid algorithm: "+algorithm)
+	}
+}
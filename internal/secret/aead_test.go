@@ -0,0 +1,119 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+func TestSealerCache(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+	sealers.Add(&secret)
+
+	first, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	second, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	if !bytes.Equal(ivOf(t, first), ivOf(t, second)) {
+		t.Fatal("Wrap derived a new key although a derived key is cached")
+	}
+
+	sealers.Forget(&secret)
+	third, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	if bytes.Equal(ivOf(t, first), ivOf(t, third)) {
+		t.Fatal("Wrap used a derived key that should have been removed")
+	}
+
+	for i, ciphertext := range [][]byte{first, second, third} {
+		plaintext, err := secret.Unwrap(ciphertext, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to unwrap ciphertext: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, []byte("plaintext")) {
+			t.Fatalf("Test %d: Plaintext mismatch: got %q", i, plaintext)
+		}
+	}
+
+	var other Secret
+	if _, err = other.Unwrap(first, nil); err == nil {
+		t.Fatal("Unwrapped ciphertext with a different secret")
+	}
+}
+
+func TestSealerCacheUncached(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+
+	first, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	second, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	if bytes.Equal(ivOf(t, first), ivOf(t, second)) {
+		t.Fatal("Wrap used a cached key for a secret that is not in a cache")
+	}
+	if _, err = secret.Unwrap(first, nil); err != nil {
+		t.Fatalf("Failed to unwrap ciphertext: %v", err)
+	}
+
+	if isSealerCached(&secret) {
+		t.Fatal("Derived keys of a secret that is not in a cache are cached")
+	}
+}
+
+func TestCacheDeleteForgetsSealer(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+
+	var c cache
+	c.Set("0", secret)
+	c.Set("1", secret)
+	if _, err := secret.Wrap([]byte("plaintext"), nil); err != nil {
+		t.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+
+	c.Delete("0")
+	if !isSealerCached(&secret) {
+		t.Fatal("Derived keys of a secret that is still in the cache have been removed")
+	}
+	c.Delete("1")
+	if isSealerCached(&secret) {
+		t.Fatal("Derived keys of a deleted secret are still cached")
+	}
+}
+
+func isSealerCached(secret *Secret) bool {
+	sealers.lock.Lock()
+	defer sealers.lock.Unlock()
+
+	_, ok := sealers.sealers[sha256.Sum256(secret[:])]
+	return ok
+}
+
+func ivOf(t *testing.T, ciphertext []byte) []byte {
+	var sealedSecret struct {
+		IV []byte `json:"iv"`
+	}
+	if err := json.Unmarshal(ciphertext, &sealedSecret); err != nil {
+		t.Fatalf("Failed to parse ciphertext: %v", err)
+	}
+	return sealedSecret.IV
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/watch"
+)
+
+// ReservedAliasName is the name of the Remote entry
+// that holds all key aliases. The Store refuses to
+// create, fetch or delete a secret with this name.
+const ReservedAliasName = ".kes-aliases"
+
+var (
+	errAliasNotFound = kes.NewError(http.StatusNotFound, "alias does not exist")
+	errAliasTarget   = kes.NewError(http.StatusBadRequest, "alias must refer to a key")
+)
+
+// SetAlias creates or updates the alias such that it
+// refers to the secret with the given name. Once set,
+// Resolve returns the name of the secret for the alias.
+//
+// An alias cannot refer to another alias and must not
+// have the same name as an existing secret. SetAlias
+// returns kes.ErrKeyNotFound if there is no secret with
+// the given name and ErrLegalHold if the alias currently
+// refers to another secret that is under legal hold.
+//
+// The aliases are written to the Remote store under
+// ReservedAliasName.
+func (s *Store) SetAlias(alias, name string) error {
+	if isReserved(alias) || isReserved(name) {
+		return errReservedName
+	}
+	if alias == name {
+		return errAliasTarget
+	}
+
+	s.aliasLock.Lock()
+	defer s.aliasLock.Unlock()
+
+	if _, ok := s.aliases[name]; ok {
+		return errAliasTarget
+	}
+	if target, ok := s.aliases[alias]; ok && target != name && s.State(target).LegalHold != nil {
+		return ErrLegalHold
+	}
+	if _, err := s.load(alias); err == nil {
+		return kes.ErrKeyExists
+	} else if err != kes.ErrKeyNotFound {
+		return err
+	}
+	if _, err := s.load(name); err != nil {
+		return err
+	}
+
+	aliases := make(map[string]string, len(s.aliases)+1)
+	for a, n := range s.aliases {
+		aliases[a] = n
+	}
+	aliases[alias] = name
+	if err := s.saveAliases(aliases); err != nil {
+		return err
+	}
+	s.aliases = aliases
+	s.publish(watch.ActionRotated, alias, name)
+	return nil
+}
+
+// DeleteAlias deletes the alias. It does not delete the
+// secret the alias refers to. It returns ErrLegalHold if
+// the alias refers to a secret under legal hold.
+func (s *Store) DeleteAlias(alias string) error {
+	s.aliasLock.Lock()
+	defer s.aliasLock.Unlock()
+
+	target, ok := s.aliases[alias]
+	if !ok {
+		return errAliasNotFound
+	}
+	if s.State(target).LegalHold != nil {
+		return ErrLegalHold
+	}
+	aliases := make(map[string]string, len(s.aliases))
+	for a, n := range s.aliases {
+		if a != alias {
+			aliases[a] = n
+		}
+	}
+	if err := s.saveAliases(aliases); err != nil {
+		return err
+	}
+	s.aliases = aliases
+	return nil
+}
+
+// Aliases returns all aliases and the names of the
+// secrets they refer to.
+func (s *Store) Aliases() map[string]string {
+	s.aliasLock.RLock()
+	defer s.aliasLock.RUnlock()
+
+	aliases := make(map[string]string, len(s.aliases))
+	for alias, name := range s.aliases {
+		aliases[alias] = name
+	}
+	return aliases
+}
+
+// Resolve returns the name of the secret the given
+// alias refers to. If name is not an alias, Resolve
+// returns name unmodified.
+func (s *Store) Resolve(name string) string {
+	s.aliasLock.RLock()
+	defer s.aliasLock.RUnlock()
+
+	if target, ok := s.aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// LoadAliases reads all aliases from the Remote store
+// and replaces the aliases kept in memory. It should be
+// called when the aliases have been changed by another
+// KES server - e.g. by another node of a cluster.
+func (s *Store) LoadAliases() error {
+	value, err := Load(s.Remote, ReservedAliasName)
+	if err == kes.ErrKeyNotFound {
+		value, err = "{}", nil
+	}
+	if err != nil {
+		return err
+	}
+	var aliases map[string]string
+	if err = json.Unmarshal([]byte(value), &aliases); err != nil {
+		return errors.New("secret: persisted key aliases are malformed")
+	}
+
+	s.aliasLock.Lock()
+	defer s.aliasLock.Unlock()
+	s.aliases = aliases
+	return nil
+}
+
+// isAlias reports whether name is an alias.
+func (s *Store) isAlias(name string) bool {
+	s.aliasLock.RLock()
+	defer s.aliasLock.RUnlock()
+
+	_, ok := s.aliases[name]
+	return ok
+}
+
+// saveAliases writes the aliases to the Remote store.
+// It replaces the entry via Replace. The caller must
+// hold the alias lock.
+func (s *Store) saveAliases(aliases map[string]string) error {
+	value, err := json.Marshal(aliases)
+	if err != nil {
+		return err
+	}
+	return Replace(s.Remote, ReservedAliasName, string(value))
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreAlias(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	for _, name := range []string{"key-1", "key-2"} {
+		if err := store.Create(context.Background(), name, Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	if err := store.SetAlias("current", "key-1"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+	if name := store.Resolve("current"); name != "key-1" {
+		t.Fatalf("Invalid alias target: got '%s' - want '%s'", name, "key-1")
+	}
+	if name := store.Resolve("key-2"); name != "key-2" {
+		t.Fatalf("Key name has been resolved: got '%s' - want '%s'", name, "key-2")
+	}
+	if err := store.SetAlias("current", "key-2"); err != nil {
+		t.Fatalf("Failed to update alias: %v", err)
+	}
+	if name := store.Resolve("current"); name != "key-2" {
+		t.Fatalf("Invalid alias target: got '%s' - want '%s'", name, "key-2")
+	}
+
+	if err := store.SetAlias("key-1", "key-2"); err != kes.ErrKeyExists {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if err := store.SetAlias("other", "key-3"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if err := store.SetAlias("other", "current"); err != errAliasTarget {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errAliasTarget)
+	}
+	if err := store.SetAlias(ReservedAliasName, "key-1"); err != errReservedName {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errReservedName)
+	}
+	if err := store.Create(context.Background(), "current", Secret{}); err != kes.ErrKeyExists {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+
+	loaded := &Store{Remote: remote}
+	if err := loaded.LoadAliases(); err != nil {
+		t.Fatalf("Failed to load aliases: %v", err)
+	}
+	if name := loaded.Resolve("current"); name != "key-2" {
+		t.Fatalf("Invalid loaded alias target: got '%s' - want '%s'", name, "key-2")
+	}
+
+	if err := store.DeleteAlias("current"); err != nil {
+		t.Fatalf("Failed to delete alias: %v", err)
+	}
+	if err := store.DeleteAlias("current"); err != errAliasNotFound {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errAliasNotFound)
+	}
+	if aliases := store.Aliases(); len(aliases) != 0 {
+		t.Fatalf("Alias has not been deleted: %v", aliases)
+	}
+	if _, err := store.Get(context.Background(), "key-2"); err != nil {
+		t.Fatalf("Deleting the alias has deleted the key: %v", err)
+	}
+	if err := loaded.LoadAliases(); err != nil {
+		t.Fatalf("Failed to load aliases: %v", err)
+	}
+	if name := loaded.Resolve("current"); name != "current" {
+		t.Fatalf("Deleted alias has been loaded: got '%s'", name)
+	}
+}
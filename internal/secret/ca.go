@@ -0,0 +1,250 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+)
+
+// The default and the max. lifetime of a certificate
+// issued by a CA. Certificates issued by a CA are meant
+// to be short-lived. Instead of revoking a certificate,
+// a client should request a new one frequently.
+const (
+	DefaultCertificateTTL = 24 * time.Hour
+	MaxCertificateTTL     = 7 * 24 * time.Hour
+)
+
+// caValidity is the lifetime of a CA certificate.
+const caValidity = 10 * 365 * 24 * time.Hour
+
+var (
+	errNotCA          = kes.NewError(http.StatusBadRequest, "key is not a certificate authority")
+	errInvalidCSR     = kes.NewError(http.StatusBadRequest, "invalid certificate request")
+	errCertificateTTL = kes.NewError(http.StatusBadRequest, "certificate lifetime must be between 1 minute and 7 days")
+)
+
+// CreateCA generates a new certificate authority (CA) and
+// adds it to the Store. The CA consists of an ECDSA P-256
+// private key and a self-signed certificate with the given
+// common name. If commonName is empty, CreateCA uses the
+// name of the CA.
+//
+// Like opaque secrets, a CA shares the namespace of keys
+// and cannot be used as cryptographic key. Its private key
+// never leaves the Store. Instead, the CA signs certificate
+// requests. See: Store.SignCertificate
+//
+// It returns the PEM-encoded CA certificate.
+func (s *Store) CreateCA(ctx context.Context, name, commonName string) ([]byte, error) {
+	if commonName == "" {
+		commonName = name
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), s.Reader())
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := subjectKeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:             now,
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		SubjectKeyId:          keyID,
+	}
+	rawCert, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	rawKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(rawKey)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rawCert})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey})
+	defer Wipe(keyPEM)
+
+	value := make([]byte, 0, len(certPEM)+len(keyPEM))
+	value = append(value, certPEM...)
+	value = append(value, keyPEM...)
+	defer Wipe(value)
+
+	if err = s.createTyped(ctx, name, value, TypeCA); err != nil {
+		return nil, err
+	}
+	return certPEM, nil
+}
+
+// CACertificate returns the PEM-encoded certificate of
+// the CA with the given name. Clients use it to verify
+// certificates issued by the CA.
+func (s *Store) CACertificate(ctx context.Context, name string) ([]byte, error) {
+	cert, _, err := s.getCA(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), nil
+}
+
+// SignCertificate issues a certificate for the PEM-encoded
+// certificate request (CSR) signed by the CA with the given
+// name. The certificate is valid for the given lifetime - but
+// not longer than the CA certificate - and can be used for
+// TLS client and server authentication.
+//
+// The certificate contains the subject and the subject
+// alternative names of the CSR. All other CSR extensions
+// are ignored. It returns the PEM-encoded certificate.
+func (s *Store) SignCertificate(ctx context.Context, name string, csr []byte, ttl time.Duration) ([]byte, error) {
+	if ttl < time.Minute || ttl > MaxCertificateTTL {
+		return nil, errCertificateTTL
+	}
+	block, _ := pem.Decode(csr)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errInvalidCSR
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, errInvalidCSR
+	}
+	if err = req.CheckSignature(); err != nil {
+		return nil, errInvalidCSR
+	}
+
+	caCert, caKey, err := s.getCA(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// Backdate the certificate a little bit to tolerate
+	// clock skew between the KES server and its clients.
+	now := time.Now().UTC()
+	notAfter := now.Add(ttl)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               req.Subject,
+		DNSNames:              req.DNSNames,
+		EmailAddresses:        req.EmailAddresses,
+		IPAddresses:           req.IPAddresses,
+		URIs:                  req.URIs,
+		NotBefore:             now.Add(-1 * time.Minute),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		AuthorityKeyId:        caCert.SubjectKeyId,
+	}
+	rawCert, err := x509.CreateCertificate(rand.Reader, template, caCert, req.PublicKey, caKey)
+	if err != nil {
+		return nil, errInvalidCSR
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rawCert}), nil
+}
+
+// getCA returns the certificate and private key of the CA
+// with the given name.
+func (s *Store) getCA(ctx context.Context, name string) (*x509.Certificate, crypto.Signer, error) {
+	value, err := s.getTyped(ctx, name, TypeCA, errNotCA)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer Wipe(value)
+
+	cert, key, err := parseCA(value)
+	if err != nil {
+		return nil, nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+	return cert, key, nil
+}
+
+// parseCA parses the PEM-encoded CA certificate and
+// private key.
+func parseCA(value []byte) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, rest := pem.Decode(value)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return nil, nil, errors.New("CA certificate is malformed")
+	}
+	keyBlock, _ := pem.Decode(rest)
+	if keyBlock == nil || keyBlock.Type != "PRIVATE KEY" {
+		return nil, nil, errors.New("CA private key is malformed")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.New("CA certificate is malformed")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.New("CA private key is malformed")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("CA private key is not supported")
+	}
+	return cert, signer, nil
+}
+
+// newSerialNumber returns a random 128 bit
+// certificate serial number.
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// subjectKeyID returns the SHA-1 hash of the public key
+// as described by RFC 5280 section 4.2.1.2 (1).
+func subjectKeyID(publicKey crypto.PublicKey) ([]byte, error) {
+	raw, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err = asn1.Unmarshal(raw, &info); err != nil {
+		return nil, err
+	}
+	id := sha1.Sum(info.PublicKey.Bytes)
+	return id[:], nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestStoreCA(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		kms    = xorKMS{0x5a}
+	)
+	store := &Store{Remote: remote, KMS: kms}
+	caPEM, err := store.CreateCA(ctx, "my-ca", "")
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	if err = store.CreateOpaque(ctx, "my-token", []byte("s3cr3t")); err != nil {
+		t.Fatalf("Failed to create opaque secret: %v", err)
+	}
+
+	// A fresh Store must neither return the CA private
+	// key as opaque secret nor use the CA as key.
+	store = &Store{Remote: remote, KMS: kms}
+	if _, err = store.GetOpaque(ctx, "my-ca"); err != errNotOpaque {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotOpaque)
+	}
+	if _, err = store.Get(ctx, "my-ca"); err != ErrNotKey {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrNotKey)
+	}
+	if _, err = store.CACertificate(ctx, "my-token"); err != errNotCA {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotCA)
+	}
+	if info, err := store.Stat("my-ca"); err != nil || info.Type != TypeCA {
+		t.Fatalf("Invalid secret type: %+v - %v", info, err)
+	}
+
+	chain, err := store.CACertificate(ctx, "my-ca")
+	if err != nil {
+		t.Fatalf("Failed to fetch CA certificate: %v", err)
+	}
+	if string(chain) != string(caPEM) {
+		t.Fatalf("Invalid CA certificate: got %s - want %s", chain, caPEM)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(chain) {
+		t.Fatal("Failed to parse CA certificate")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	rawCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "my-service"},
+		DNSNames: []string{"my-service.local"},
+	}, key)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+	csr := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: rawCSR})
+
+	if _, err = store.SignCertificate(ctx, "my-ca", csr, MaxCertificateTTL+time.Hour); err != errCertificateTTL {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errCertificateTTL)
+	}
+	if _, err = store.SignCertificate(ctx, "my-ca", []byte("not a CSR"), time.Hour); err != errInvalidCSR {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errInvalidCSR)
+	}
+	certPEM, err := store.SignCertificate(ctx, "my-ca", csr, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to sign CSR: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("Certificate is not PEM-encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName:   "my-service.local",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatalf("Failed to verify certificate: %v", err)
+	}
+	if ttl := cert.NotAfter.Sub(cert.NotBefore); ttl > time.Hour+time.Minute {
+		t.Fatalf("Certificate is valid for too long: %v", ttl)
+	}
+
+	if err = store.Disable("my-ca"); err != nil {
+		t.Fatalf("Failed to disable CA: %v", err)
+	}
+	if _, err = store.SignCertificate(ctx, "my-ca", csr, time.Hour); err != ErrDisabled {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrDisabled)
+	}
+}
@@ -20,27 +20,79 @@ type entry struct {
 	used uint32
 }
 
+// newEntry returns a new cache entry for the secret.
+// Only secrets held by a cache entry have cached AEAD
+// instances. See: sealerCache.Add
+func newEntry(secret Secret) *entry {
+	sealers.Add(&secret)
+	return &entry{
+		Secret: secret,
+		used:   1,
+	}
+}
+
+// destroy destroys the secret of the entry and
+// removes all AEAD instances derived from it.
+func (e *entry) destroy() {
+	sealers.Forget(&e.Secret)
+	e.Secret.Destroy()
+}
+
+// entryOverhead is the approximate number of bytes
+// used by a cache entry - besides its name. It includes
+// the secret, the entry metadata and the map bucket.
+const entryOverhead = 128
+
+// cacheShards is the number of cache shards. It is
+// a power of two such that the shard of a name can be
+// computed efficiently.
+const cacheShards = 64
+
 // cache is a in-memory cache mapping names to
 // cache entries. It is safe for concurrent use.
+//
+// The cache destroys the secret of an entry when
+// the entry is replaced or removed. Therefore, it
+// returns copies of its secrets only.
+//
+// The entries are distributed over multiple shards
+// based on the hash of their names. Each shard has
+// its own lock such that concurrent operations on
+// different entries rarely contend for the same lock.
 type cache struct {
+	shards [cacheShards]cacheShard
+}
+
+// A cacheShard holds all cache entries whose
+// names hash to the shard.
+type cacheShard struct {
 	lock  sync.RWMutex
 	store map[string]*entry
+	size  int // The approximate memory used by the entries
+
+	// Pad the shard to the size of a cache line such
+	// that CPUs locking different shards don't
+	// invalidate each other's cache lines.
+	_ [24]byte
 }
 
 // Set adds the given secret to the cache.
 // If there is already an entry for the given
 // name then Set replaces this entry.
 func (c *cache) Set(name string, secret Secret) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	shard := c.shard(name)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
 
-	if c.store == nil {
-		c.store = map[string]*entry{}
+	if shard.store == nil {
+		shard.store = map[string]*entry{}
 	}
-	c.store[name] = &entry{
-		Secret: secret,
-		used:   1,
+	if entry, ok := shard.store[name]; ok {
+		entry.destroy()
+		shard.size -= len(name) + entryOverhead
 	}
+	shard.store[name] = newEntry(secret)
+	shard.size += len(name) + entryOverhead
 }
 
 // SetOrGet adds  given secret to the cache
@@ -53,21 +105,20 @@ func (c *cache) Set(name string, secret Secret) {
 // is in the cache right now - either the given
 // one or the one that has been there before.
 func (c *cache) SetOrGet(name string, secret Secret) Secret {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	shard := c.shard(name)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
 
-	if entry, ok := c.store[name]; ok {
+	if entry, ok := shard.store[name]; ok {
 		atomic.StoreUint32(&entry.used, 1)
 		return entry.Secret
 	}
 
-	if c.store == nil {
-		c.store = map[string]*entry{}
-	}
-	c.store[name] = &entry{
-		Secret: secret,
-		used:   1,
+	if shard.store == nil {
+		shard.store = map[string]*entry{}
 	}
+	shard.store[name] = newEntry(secret)
+	shard.size += len(name) + entryOverhead
 	return secret
 }
 
@@ -75,10 +126,11 @@ func (c *cache) SetOrGet(name string, secret Secret) Secret {
 // It returns true if and only if a cache entry
 // exists.
 func (c *cache) Get(name string) (Secret, bool) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	shard := c.shard(name)
+	shard.lock.RLock()
+	defer shard.lock.RUnlock()
 
-	entry, ok := c.store[name]
+	entry, ok := shard.store[name]
 	if !ok {
 		return Secret{}, ok
 	}
@@ -89,10 +141,58 @@ func (c *cache) Get(name string) (Secret, bool) {
 // Delete removes the entry with the
 // given name if it exists.
 func (c *cache) Delete(name string) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	shard := c.shard(name)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if entry, ok := shard.store[name]; ok {
+		entry.destroy()
+		delete(shard.store, name)
+		shard.size -= len(name) + entryOverhead
+	}
+}
+
+// Size returns the approximate number of bytes
+// used by the cache entries.
+func (c *cache) Size() int64 {
+	var size int64
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.lock.RLock()
+		size += int64(shard.size)
+		shard.lock.RUnlock()
+	}
+	return size
+}
 
-	delete(c.store, name)
+// Clear removes all entries from the cache.
+func (c *cache) Clear() {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.lock.Lock()
+		for _, entry := range shard.store {
+			entry.destroy()
+		}
+		shard.store = map[string]*entry{}
+		shard.size = 0
+		shard.lock.Unlock()
+	}
+}
+
+// shard returns the shard of the given name. It
+// hashes the name using FNV-1a - which does not
+// allocate, in contrast to the hash/fnv package.
+func (c *cache) shard(name string) *cacheShard {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
+	hash := uint32(offset)
+	for i := 0; i < len(name); i++ {
+		hash ^= uint32(name[i])
+		hash *= prime
+	}
+	return &c.shards[hash%cacheShards]
 }
 
 // StartGC spawns a new go-routine that clears
@@ -111,9 +211,7 @@ func (c *cache) StartGC(ctx context.Context, t time.Duration) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				c.lock.Lock()
-				c.store = map[string]*entry{}
-				c.lock.Unlock()
+				c.Clear()
 			}
 		}
 	}()
@@ -145,29 +243,36 @@ func (c *cache) StartUnusedGC(ctx context.Context, t time.Duration) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				var names []string
-
-				c.lock.RLock()
-				for name, entry := range c.store {
-					// We check whether Used == 1. If so,
-					// we mark it as "to delete on next iteration
-					// if not used in between" by setting it to 0.
-					// If Used != 1 we consider this as "not used
-					// since we marked as to delete". Therefore,
-					// we add it to the list of entries that should
-					// be deleted.
-					if !atomic.CompareAndSwapUint32(&entry.used, 1, 0) {
-						names = append(names, name)
+				for i := range c.shards {
+					shard := &c.shards[i]
+					var names []string
+
+					shard.lock.RLock()
+					for name, entry := range shard.store {
+						// We check whether Used == 1. If so,
+						// we mark it as "to delete on next iteration
+						// if not used in between" by setting it to 0.
+						// If Used != 1 we consider this as "not used
+						// since we marked as to delete". Therefore,
+						// we add it to the list of entries that should
+						// be deleted.
+						if !atomic.CompareAndSwapUint32(&entry.used, 1, 0) {
+							names = append(names, name)
+						}
 					}
-				}
-				c.lock.RUnlock()
+					shard.lock.RUnlock()
 
-				// Now delete all "expired" entries.
-				c.lock.Lock()
-				for _, name := range names {
-					delete(c.store, name)
+					// Now delete all "expired" entries.
+					shard.lock.Lock()
+					for _, name := range names {
+						if entry, ok := shard.store[name]; ok {
+							entry.destroy()
+							delete(shard.store, name)
+							shard.size -= len(name) + entryOverhead
+						}
+					}
+					shard.lock.Unlock()
 				}
-				c.lock.Unlock()
 			}
 		}
 	}()
@@ -5,6 +5,8 @@
 package secret
 
 import (
+	"context"
+	"strconv"
 	"testing"
 )
 
@@ -75,3 +77,190 @@ func TestCacheDelete(t *testing.T) {
 		t.Fatal("Cache entry should not exist")
 	}
 }
+
+func TestCacheDeleteDestroys(t *testing.T) {
+	var secret Secret
+	secret[0] = 0xff
+
+	var c cache
+	c.Set("0", secret)
+	entry := c.shard("0").store["0"]
+	c.Delete("0")
+	if entry.Secret != (Secret{}) {
+		t.Fatalf("Expected deleted cache entry to be destroyed: got: %x", entry.Secret)
+	}
+	if secret[0] != 0xff {
+		t.Fatal("Deleting a cache entry must not modify the caller's copy")
+	}
+
+	c.Set("1", secret)
+	entry = c.shard("1").store["1"]
+	c.Set("1", secret)
+	if entry.Secret != (Secret{}) {
+		t.Fatalf("Expected replaced cache entry to be destroyed: got: %x", entry.Secret)
+	}
+}
+
+func TestCacheShard(t *testing.T) {
+	var c cache
+	used := map[*cacheShard]bool{}
+	for i := 0; i < 16*cacheShards; i++ {
+		name := "key-" + strconv.Itoa(i)
+		if c.shard(name) != c.shard(name) {
+			t.Fatalf("Name '%s' is mapped to different shards", name)
+		}
+		used[c.shard(name)] = true
+	}
+	if len(used) != cacheShards {
+		t.Fatalf("Names are not distributed over all shards: got %d - want %d", len(used), cacheShards)
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	var c cache
+	c.Set("my-key", Secret{})
+	c.SetOrGet("other-key", Secret{})
+	c.Set("my-key", Secret{}) // Replacing an entry does not change the size
+
+	if size, want := c.Size(), int64(len("my-key")+len("other-key")+2*entryOverhead); size != want {
+		t.Fatalf("Invalid cache size: got %d - want %d", size, want)
+	}
+	c.Delete("my-key")
+	if size, want := c.Size(), int64(len("other-key")+entryOverhead); size != want {
+		t.Fatalf("Invalid cache size: got %d - want %d", size, want)
+	}
+	c.Clear()
+	if size := c.Size(); size != 0 {
+		t.Fatalf("Invalid cache size: got %d - want %d", size, 0)
+	}
+	if _, ok := c.Get("other-key"); ok {
+		t.Fatal("Cache entry has not been removed")
+	}
+}
+
+// TestStoreGetCachedAllocs checks that fetching a
+// cached secret does not allocate.
+func TestStoreGetCachedAllocs(t *testing.T) {
+	store := &Store{Remote: remoteMap{}}
+	var key Secret
+	if err := store.Create(context.Background(), "my-key", key); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	store.RecordUse("my-key", OpDecrypt)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		key, err := store.GetFor(context.Background(), "my-key", OpDecrypt)
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		store.RecordUse("my-key", OpDecrypt)
+		key.Destroy()
+	})
+	if allocs != 0 {
+		t.Fatalf("Fetching a cached secret allocates: got %v allocs - want 0", allocs)
+	}
+}
+
+func BenchmarkStoreGetCached(b *testing.B) {
+	store := &Store{Remote: remoteMap{}}
+	var key Secret
+	if err := store.Create(context.Background(), "my-key", key); err != nil {
+		b.Fatalf("Failed to create secret: %v", err)
+	}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key, _ := store.GetFor(ctx, "my-key", OpDecrypt)
+			key.Destroy()
+		}
+	})
+}
+
+// benchmarkCacheSize is the number of cache
+// entries used by the cache benchmarks.
+//
+// To check how the cache scales, run the parallel
+// benchmarks with different numbers of CPUs - e.g.:
+//   go test -run=^$ -bench=CacheGetParallel -cpu=1,4,16,64
+const benchmarkCacheSize = 1024
+
+func BenchmarkCacheGet(b *testing.B) {
+	c, names := newBenchmarkCache()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(names[i%len(names)])
+	}
+}
+
+func BenchmarkCacheGetParallel(b *testing.B) {
+	c, names := newBenchmarkCache()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			c.Get(names[i%len(names)])
+		}
+	})
+}
+
+func BenchmarkCacheSet(b *testing.B) {
+	c, names := newBenchmarkCache()
+
+	var secret Secret
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(names[i%len(names)], secret)
+	}
+}
+
+func BenchmarkCacheSetParallel(b *testing.B) {
+	c, names := newBenchmarkCache()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var secret Secret
+		for i := 0; pb.Next(); i++ {
+			c.Set(names[i%len(names)], secret)
+		}
+	})
+}
+
+// BenchmarkCacheMixedParallel measures the cache under
+// contention - with one Set for every 16 Gets.
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	c, names := newBenchmarkCache()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var secret Secret
+		for i := 0; pb.Next(); i++ {
+			if i%16 == 0 {
+				c.Set(names[i%len(names)], secret)
+			} else {
+				c.Get(names[i%len(names)])
+			}
+		}
+	})
+}
+
+func newBenchmarkCache() (*cache, []string) {
+	var (
+		c      = &cache{}
+		names  = make([]string, benchmarkCacheSize)
+		secret Secret
+	)
+	for i := range names {
+		names[i] = "key-" + strconv.Itoa(i)
+		c.Set(names[i], secret)
+	}
+	return c, names
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/subtle"
+
+	"github.com/minio/kes"
+)
+
+// Equal reports whether a and b are equal. It takes
+// the same time for all a and b of the same length.
+// In particular, it does not return early on the first
+// byte that differs. It may leak the length of a and b.
+//
+// Equal should be used to compare secret values - like
+// key shares, MACs or hashes of secret values - instead
+// of bytes.Equal such that the comparison does not reveal
+// how many leading bytes match.
+func Equal(a, b []byte) bool { return subtle.ConstantTimeCompare(a, b) == 1 }
+
+// EqualIdentity reports whether a and b are the same
+// identity. Like Equal, it does not return early on
+// the first byte that differs.
+//
+// EqualIdentity should be used whenever an identity
+// sent by a client is compared to a privileged identity
+// - like the root identity - such that a client cannot
+// guess the privileged identity byte by byte.
+func EqualIdentity(a, b kes.Identity) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Equal reports whether s and other are the same
+// secret. It takes the same time for all secrets.
+func (s Secret) Equal(other Secret) bool { return Equal(s[:], other[:]) }
@@ -0,0 +1,60 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+var equalTests = []struct {
+	A, B  []byte
+	Equal bool
+}{
+	{A: nil, B: nil, Equal: true},
+	{A: []byte{}, B: nil, Equal: true},
+	{A: []byte("key share"), B: []byte("key share"), Equal: true},
+	{A: []byte("key share"), B: []byte("key shard"), Equal: false},
+	{A: []byte("key share"), B: []byte("key"), Equal: false},
+}
+
+func TestEqual(t *testing.T) {
+	for i, test := range equalTests {
+		if equal := Equal(test.A, test.B); equal != test.Equal {
+			t.Fatalf("Test %d: got %v - want %v", i, equal, test.Equal)
+		}
+	}
+}
+
+var equalIdentityTests = []struct {
+	A, B  kes.Identity
+	Equal bool
+}{
+	{A: kes.IdentityUnknown, B: kes.IdentityUnknown, Equal: true},
+	{A: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22", B: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22", Equal: true},
+	{A: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22", B: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd23", Equal: false},
+	{A: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22", B: kes.IdentityUnknown, Equal: false},
+}
+
+func TestEqualIdentity(t *testing.T) {
+	for i, test := range equalIdentityTests {
+		if equal := EqualIdentity(test.A, test.B); equal != test.Equal {
+			t.Fatalf("Test %d: got %v - want %v", i, equal, test.Equal)
+		}
+	}
+}
+
+func TestSecretEqual(t *testing.T) {
+	var a, b Secret
+	a[31], b[31] = 1, 1
+	if !a.Equal(b) {
+		t.Fatal("Equal secrets are not equal")
+	}
+	b[0] = 1
+	if a.Equal(b) {
+		t.Fatal("Different secrets are equal")
+	}
+}
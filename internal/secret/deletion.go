@@ -0,0 +1,159 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// The bounds and default of the time window between
+// scheduling the deletion of a secret and deleting it.
+const (
+	MinDeletionWindow     = 7 * 24 * time.Hour
+	MaxDeletionWindow     = 30 * 24 * time.Hour
+	DefaultDeletionWindow = MaxDeletionWindow
+)
+
+// ErrPendingDeletion is returned when a secret that is
+// scheduled for deletion is fetched from the Store.
+// See: Store.ScheduleDeletion
+var ErrPendingDeletion = kes.NewError(http.StatusForbidden, "key is pending deletion")
+
+var (
+	errDeletionWindow = kes.NewError(http.StatusBadRequest, "deletion window must be between 7 and 30 days")
+	errAlreadyPending = kes.NewError(http.StatusConflict, "key is already pending deletion")
+	errNotPending     = kes.NewError(http.StatusConflict, "key is not pending deletion")
+)
+
+// PendingDeletion describes when and by whom the
+// deletion of a secret has been scheduled.
+type PendingDeletion struct {
+	DeleteAt time.Time    `json:"delete_at"`
+	Identity kes.Identity `json:"identity,omitempty"`
+}
+
+// ScheduleDeletion schedules the deletion of the secret with
+// the given name after the window has passed. The window must
+// be between MinDeletionWindow and MaxDeletionWindow. It returns
+// the point in time when the secret will be deleted.
+//
+// Until then, the secret cannot be fetched from the Store - as
+// if it were disabled - and its deletion can be cancelled. Once
+// the window has passed, the secret is deleted by DeletePending.
+//
+// ScheduleDeletion returns ErrImmutable if the secret is immutable
+// and ErrLegalHold if the secret is under legal hold.
+func (s *Store) ScheduleDeletion(name string, window time.Duration, identity kes.Identity) (time.Time, error) {
+	if window < MinDeletionWindow || window > MaxDeletionWindow {
+		return time.Time{}, errDeletionWindow
+	}
+	if immutable, err := s.isImmutable(name); err != nil {
+		return time.Time{}, err
+	} else if immutable {
+		return time.Time{}, ErrImmutable
+	}
+
+	deleteAt := time.Now().UTC().Add(window)
+	err := s.setState(name, func(state *State) error {
+		if state.LegalHold != nil {
+			return ErrLegalHold
+		}
+		if state.PendingDeletion != nil {
+			return errAlreadyPending
+		}
+		state.PendingDeletion = &PendingDeletion{
+			DeleteAt: deleteAt,
+			Identity: identity,
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return deleteAt, nil
+}
+
+// CancelDeletion cancels the scheduled deletion of the
+// secret with the given name such that it can be fetched
+// from the Store again. It returns an error if the secret
+// is not pending deletion.
+func (s *Store) CancelDeletion(name string) error {
+	return s.setState(name, func(state *State) error {
+		if state.PendingDeletion == nil {
+			return errNotPending
+		}
+		state.PendingDeletion = nil
+		return nil
+	})
+}
+
+// DeletePending deletes all secrets whose deletion window
+// has passed at now and records the deletion in their
+// provenance. It skips secrets under legal hold. Once the
+// hold is released, they get deleted.
+//
+// It returns the names of the deleted secrets. If a secret
+// cannot be deleted, DeletePending returns the names of the
+// secrets deleted so far and the error.
+//
+// It holds the lock of a secret, if lock is not nil, while
+// deleting the secret.
+func (s *Store) DeletePending(ctx context.Context, now time.Time, lock func(name string) sync.Locker) ([]string, error) {
+	var names []string
+	s.stateLock.RLock()
+	for name, state := range s.states {
+		if state.PendingDeletion != nil && state.LegalHold == nil && !now.Before(state.PendingDeletion.DeleteAt) {
+			names = append(names, name)
+		}
+	}
+	s.stateLock.RUnlock()
+	sort.Strings(names)
+
+	var deleted []string
+	for _, name := range names {
+		ok, err := s.deletePending(ctx, name, lock)
+		if err != nil {
+			return deleted, err
+		}
+		if ok {
+			deleted = append(deleted, name)
+		}
+	}
+	return deleted, nil
+}
+
+// deletePending deletes the named secret while holding its
+// lock, if lock is not nil, unless its deletion has been
+// cancelled concurrently. It reports whether the secret has
+// been deleted.
+func (s *Store) deletePending(ctx context.Context, name string, lock func(name string) sync.Locker) (bool, error) {
+	if lock != nil {
+		l := lock(name)
+		l.Lock()
+		defer l.Unlock()
+	}
+	pending := s.State(name).PendingDeletion
+	if pending == nil { // The deletion has been cancelled concurrently
+		return false, nil
+	}
+	if err := s.Delete(ctx, name); err != nil {
+		return false, err
+	}
+	err := s.AppendProvenance(name, ProvenanceEvent{
+		Type:     ProvenanceDeleted,
+		Identity: pending.Identity,
+		Origin:   "scheduled deletion",
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
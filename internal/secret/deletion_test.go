@@ -0,0 +1,105 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreScheduleDeletion(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	for _, name := range []string{"key-1", "key-2"} {
+		if err := store.Create(context.Background(), name, Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := store.CreateImmutable(context.Background(), "root-key", Secret{}, nil); err != nil {
+		t.Fatalf("Failed to create immutable key: %v", err)
+	}
+
+	if _, err := store.ScheduleDeletion("key-1", 24*time.Hour, ""); err != errDeletionWindow {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errDeletionWindow)
+	}
+	if _, err := store.ScheduleDeletion("key-1", 31*24*time.Hour, ""); err != errDeletionWindow {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errDeletionWindow)
+	}
+	if _, err := store.ScheduleDeletion("root-key", MinDeletionWindow, ""); err != ErrImmutable {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrImmutable)
+	}
+	if _, err := store.ScheduleDeletion("key-3", MinDeletionWindow, ""); err != kes.ErrKeyNotFound {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	deleteAt, err := store.ScheduleDeletion("key-1", MinDeletionWindow, "af43c")
+	if err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+	if _, err = store.ScheduleDeletion("key-1", MinDeletionWindow, ""); err != errAlreadyPending {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errAlreadyPending)
+	}
+	if _, err = store.Get(context.Background(), "key-1"); err != ErrPendingDeletion {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrPendingDeletion)
+	}
+	if info, err := store.Stat("key-1"); err != nil || info.PendingDeletion == nil || !info.PendingDeletion.DeleteAt.Equal(deleteAt) {
+		t.Fatalf("Key is not pending deletion: %+v - %v", info, err)
+	}
+	if _, err = store.ScheduleDeletion("key-2", MaxDeletionWindow, ""); err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+	if err = store.CancelDeletion("key-2"); err != nil {
+		t.Fatalf("Failed to cancel deletion: %v", err)
+	}
+	if err = store.CancelDeletion("key-2"); err != errNotPending {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotPending)
+	}
+	if _, err = store.Get(context.Background(), "key-2"); err != nil {
+		t.Fatalf("Failed to get key after cancelling its deletion: %v", err)
+	}
+
+	loaded := &Store{Remote: remote}
+	if err = loaded.LoadStates(); err != nil {
+		t.Fatalf("Failed to load key states: %v", err)
+	}
+	if pending := loaded.State("key-1").PendingDeletion; pending == nil || pending.Identity != "af43c" {
+		t.Fatalf("Invalid loaded pending deletion: %+v", pending)
+	}
+
+	// A key is only deleted once its deletion window
+	// has passed and it is not under legal hold.
+	if deleted, err := store.DeletePending(context.Background(), deleteAt.Add(-time.Minute), nil); err != nil || len(deleted) != 0 {
+		t.Fatalf("Deleted keys before their deletion window has passed: %v - %v", deleted, err)
+	}
+	if err = store.Hold("key-1", LegalHold{}); err != nil {
+		t.Fatalf("Failed to place legal hold: %v", err)
+	}
+	if deleted, err := store.DeletePending(context.Background(), deleteAt, nil); err != nil || len(deleted) != 0 {
+		t.Fatalf("Deleted keys under legal hold: %v - %v", deleted, err)
+	}
+	if err = store.Release("key-1"); err != nil {
+		t.Fatalf("Failed to release legal hold: %v", err)
+	}
+	deleted, err := store.DeletePending(context.Background(), deleteAt, nil)
+	if err != nil {
+		t.Fatalf("Failed to delete pending keys: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "key-1" {
+		t.Fatalf("Invalid deleted keys: got %v - want %v", deleted, []string{"key-1"})
+	}
+	if _, err = store.Stat("key-1"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	events, err := store.Provenance("key-1")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if n := len(events); n == 0 || events[n-1].Type != ProvenanceDeleted || events[n-1].Identity != "af43c" {
+		t.Fatalf("Deletion has not been recorded: %+v", events)
+	}
+}
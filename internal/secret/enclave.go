@@ -0,0 +1,95 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"github.com/minio/kes"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ReservedEnclaveName is the name of the Remote entry
+// that holds the KMS-encrypted enclave key. The Store
+// refuses to create, fetch or delete a secret with this
+// name.
+const ReservedEnclaveName = ".kes-enclave"
+
+// EnclaveKey is a root key shared by all KES servers
+// that use the same Remote store and KMS. The servers
+// derive their server-local secrets - e.g. the audit
+// log signing key - from the enclave key such that they
+// are interchangeable.
+type EnclaveKey [32]byte
+
+// LoadEnclaveKey fetches the KMS-encrypted enclave key
+// from the Remote store and decrypts it with the KMS.
+//
+// If the Remote store does not contain an enclave key,
+// LoadEnclaveKey generates a new one and stores it,
+// encrypted with the KMS, at the Remote store. If another
+// server has stored an enclave key in the meantime, it
+// uses this key instead. A new enclave key is read from
+// random - e.g. the Store's source of random bytes.
+// See: Store.Reader
+func LoadEnclaveKey(random io.Reader, remote Remote, kms KMS) (EnclaveKey, error) {
+	value, err := remote.Get(ReservedEnclaveName)
+	if err == kes.ErrKeyNotFound {
+		var key EnclaveKey
+		if _, err := io.ReadFull(random, key[:]); err != nil {
+			return EnclaveKey{}, err
+		}
+
+		ciphertext, err := kms.Encrypt(key[:], ReservedEnclaveName)
+		if err != nil {
+			return EnclaveKey{}, err
+		}
+		switch err = remote.Create(ReservedEnclaveName, Ciphertext(ciphertext).String()); err {
+		case nil:
+			return key, nil
+		case kes.ErrKeyExists: // Another server has been faster
+			value, err = remote.Get(ReservedEnclaveName)
+		}
+	}
+	if err != nil {
+		return EnclaveKey{}, err
+	}
+
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return EnclaveKey{}, err
+	}
+	plaintext, err := kms.Decrypt(ciphertext, ReservedEnclaveName)
+	if err != nil {
+		return EnclaveKey{}, err
+	}
+
+	defer Wipe(plaintext)
+
+	var key EnclaveKey
+	if len(plaintext) != len(key) {
+		return EnclaveKey{}, errors.New("enclave key is malformed")
+	}
+	copy(key[:], plaintext)
+	return key, nil
+}
+
+// DeriveKey derives a key of the given size from the
+// enclave key using HKDF-SHA256. The purpose must be
+// unique for each kind of derived key.
+//
+// All servers sharing the enclave key derive the same
+// key for the same purpose.
+func (k *EnclaveKey) DeriveKey(purpose string, size int) []byte {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k[:], nil, []byte(purpose)), key); err != nil {
+		// HKDF-SHA256 can only fail if more than
+		// 255 * 32 bytes are requested.
+		panic("secret: cannot derive key: " + err.Error())
+	}
+	return key
+}
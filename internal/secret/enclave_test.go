@@ -0,0 +1,93 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestLoadEnclaveKey(t *testing.T) {
+	var (
+		remote = remoteMap{}
+		kms    = xorKMS{0x5a}
+	)
+	key, err := LoadEnclaveKey(rand.Reader, remote, kms)
+	if err != nil {
+		t.Fatalf("Failed to create enclave key: %v", err)
+	}
+	if _, ok := remote[ReservedEnclaveName]; !ok {
+		t.Fatal("Enclave key has not been stored")
+	}
+	if ciphertext, _ := ParseCiphertext(remote[ReservedEnclaveName]); bytes.Contains(ciphertext, key[:]) {
+		t.Fatal("Enclave key has been stored in plaintext")
+	}
+
+	loaded, err := LoadEnclaveKey(rand.Reader, remote, kms)
+	if err != nil {
+		t.Fatalf("Failed to load enclave key: %v", err)
+	}
+	if loaded != key {
+		t.Fatal("Loaded enclave key does not match the stored one")
+	}
+	if !bytes.Equal(loaded.DeriveKey("test", 32), key.DeriveKey("test", 32)) {
+		t.Fatal("Keys derived from the same enclave key do not match")
+	}
+	if bytes.Equal(key.DeriveKey("a", 32), key.DeriveKey("b", 32)) {
+		t.Fatal("Keys derived for different purposes must not match")
+	}
+
+	if _, err = LoadEnclaveKey(rand.Reader, remote, xorKMS{0x42}); err == nil {
+		t.Fatal("Enclave key encrypted by a different KMS should not be loaded")
+	}
+}
+
+type remoteMap map[string]string
+
+func (r remoteMap) Create(key, value string) error {
+	if _, ok := r[key]; ok {
+		return kes.ErrKeyExists
+	}
+	r[key] = value
+	return nil
+}
+
+func (r remoteMap) Delete(key string) error { delete(r, key); return nil }
+
+func (r remoteMap) Get(key string) (string, error) {
+	value, ok := r[key]
+	if !ok {
+		return "", kes.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// xorKMS is an insecure KMS that "encrypts" by XOR-ing
+// the plaintext with its key byte. It prepends a key
+// byte and the context to detect mismatches.
+type xorKMS struct{ key byte }
+
+func (k xorKMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	ciphertext := append([]byte{k.key}, context...)
+	for _, b := range plaintext {
+		ciphertext = append(ciphertext, b^k.key)
+	}
+	return ciphertext, nil
+}
+
+func (k xorKMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	if len(ciphertext) < 1+len(context) || ciphertext[0] != k.key || string(ciphertext[1:1+len(context)]) != context {
+		return nil, errors.New("decryption failed")
+	}
+	plaintext := make([]byte, 0, len(ciphertext)-1-len(context))
+	for _, b := range ciphertext[1+len(context):] {
+		plaintext = append(plaintext, b^k.key)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+)
+
+// The encodings of values at a Remote store.
+const (
+	EncodingJSON = "json" // The JSON encoding used by the Store - e.g. {"bytes":"..."}
+	EncodingRaw  = "raw"  // The raw secret bytes
+	EncodingPEM  = "pem"  // A PEM block with the secret bytes and its metadata as PEM headers
+)
+
+// The PEM block types of secrets and KMS ciphertexts.
+const (
+	pemTypeSecret     = "KES SECRET"
+	pemTypeCiphertext = "KES CIPHERTEXT"
+)
+
+// EncodingRemote is a Remote store that converts the values
+// of secrets between the JSON encoding of the Store and the
+// encoding used at the Remote store it wraps.
+//
+// When reading a value, EncodingRemote detects its encoding
+// automatically. Hence, it can read values written with any
+// encoding - e.g. raw keys or PEM files written by other tools.
+//
+// The raw encoding can only represent the bytes of a secret
+// but no metadata - like the operations a key is restricted
+// to or a KMS ciphertext. Such values are stored as JSON.
+// Entries managed by the server itself, like the policies,
+// are always stored as JSON.
+type EncodingRemote struct {
+	Remote
+
+	// Encoding is the encoding of values written to
+	// the Remote store - either EncodingJSON, EncodingRaw
+	// or EncodingPEM. If empty, it defaults to EncodingJSON.
+	Encoding string
+}
+
+var (
+	_ Remote        = EncodingRemote{}
+	_ Lister        = EncodingRemote{}
+	_ PrefixLister  = EncodingRemote{}
+	_ Stater        = EncodingRemote{}
+	_ StatusChecker = EncodingRemote{}
+)
+
+// ValidEncoding reports whether encoding is a supported
+// Remote encoding. The empty string is a valid encoding
+// and equal to EncodingJSON.
+func ValidEncoding(encoding string) bool {
+	switch encoding {
+	case "", EncodingJSON, EncodingRaw, EncodingPEM:
+		return true
+	default:
+		return false
+	}
+}
+
+// Create encodes the value and creates a new entry at
+// the wrapped Remote store.
+func (r EncodingRemote) Create(key, value string) error {
+	if isReserved(key) {
+		return r.Remote.Create(key, value)
+	}
+	return r.Remote.Create(key, EncodeValue(r.Encoding, value))
+}
+
+// Get fetches an entry from the wrapped Remote store
+// and converts its value to JSON.
+func (r EncodingRemote) Get(key string) (string, error) {
+	value, err := r.Remote.Get(key)
+	if err != nil || isReserved(key) {
+		return value, err
+	}
+	if value, err = DecodeValue(value); err != nil {
+		return "", errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", key, err)
+	}
+	return value, nil
+}
+
+// List returns the names of all entries at the wrapped
+// Remote store. It returns ErrListNotSupported if the
+// wrapped Remote store does not implement Lister.
+func (r EncodingRemote) List() ([]string, error) {
+	lister, ok := r.Remote.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	return lister.List()
+}
+
+// ListPrefix returns the names of all entries at the
+// wrapped Remote store that start with the prefix.
+func (r EncodingRemote) ListPrefix(prefix string) ([]string, error) {
+	return ListPrefix(r.Remote, prefix)
+}
+
+// CreatedAt returns the point in time when the entry has
+// been created at the wrapped Remote store. It returns
+// ErrStatNotSupported if the wrapped Remote store does
+// not implement Stater.
+func (r EncodingRemote) CreatedAt(key string) (time.Time, error) {
+	stater, ok := r.Remote.(Stater)
+	if !ok {
+		return time.Time{}, ErrStatNotSupported
+	}
+	return stater.CreatedAt(key)
+}
+
+// Status reports whether the wrapped Remote store is
+// available.
+func (r EncodingRemote) Status() error {
+	if checker, ok := r.Remote.(StatusChecker); ok {
+		return checker.Status()
+	}
+	if _, err := r.Remote.Get(ReservedName); err != nil && err != kes.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+// EncodeValue converts the JSON-encoded value of a secret
+// to the given encoding. It returns the value as it is if
+// the encoding cannot represent the value.
+func EncodeValue(encoding, value string) string {
+	if encoding == "" || encoding == EncodingJSON {
+		return value
+	}
+	fields, body, blockType, ok := splitValue(value)
+	if !ok {
+		return value
+	}
+	switch encoding {
+	case EncodingRaw:
+		if len(fields) > 0 || blockType != pemTypeSecret {
+			return value
+		}
+		return string(body)
+	case EncodingPEM:
+		headers := make(map[string]string, len(fields))
+		for name, field := range fields {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, field); err != nil {
+				return value
+			}
+			headers[name] = compact.String()
+		}
+		block := pem.EncodeToMemory(&pem.Block{
+			Type:    blockType,
+			Headers: headers,
+			Bytes:   body,
+		})
+		if block == nil { // A header name is not valid
+			return value
+		}
+		return string(block)
+	default:
+		return value
+	}
+}
+
+// DecodeValue converts a value read from a Remote store
+// to the JSON encoding of secrets. It detects whether the
+// value is JSON-encoded, a PEM block or raw bytes.
+//
+// The headers of a PEM block are converted to JSON fields.
+// Hence, a PEM block written by another tool may specify
+// the type of the secret - e.g. "type: opaque".
+func DecodeValue(value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return value, nil
+	}
+	if strings.HasPrefix(trimmed, "-----BEGIN ") {
+		block, rest := pem.Decode([]byte(trimmed))
+		if block == nil || len(bytes.TrimSpace(rest)) != 0 {
+			return "", errors.New("PEM block is malformed")
+		}
+		if _, ok := block.Headers["Proc-Type"]; ok {
+			return "", errors.New("encrypted PEM blocks are not supported")
+		}
+		field := "bytes"
+		if block.Type == pemTypeCiphertext {
+			field = "ciphertext"
+		}
+		names := make([]string, 0, len(block.Headers))
+		for name := range block.Headers {
+			if name != "bytes" && name != "ciphertext" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		b.WriteString(`{"` + field + `":"` + base64.StdEncoding.EncodeToString(block.Bytes) + `"`)
+		for _, name := range names {
+			fieldName, _ := json.Marshal(name) // Marshaling a string cannot fail
+			fieldValue := block.Headers[name]
+			if !json.Valid([]byte(fieldValue)) {
+				quoted, _ := json.Marshal(fieldValue)
+				fieldValue = string(quoted)
+			}
+			b.WriteString("," + string(fieldName) + ":" + fieldValue)
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	}
+	return `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(value)) + `"}`, nil
+}
+
+// splitValue splits the JSON-encoded value of a secret
+// into its secret or ciphertext bytes and its remaining
+// fields. It reports whether value is a JSON object with
+// either a "bytes" or a "ciphertext" field.
+func splitValue(value string) (fields map[string]json.RawMessage, body []byte, blockType string, ok bool) {
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return nil, nil, "", false
+	}
+	encoded, hasBytes := fields["bytes"]
+	blockType = pemTypeSecret
+	if ciphertext, hasCiphertext := fields["ciphertext"]; hasCiphertext {
+		if hasBytes {
+			return nil, nil, "", false
+		}
+		encoded, blockType = ciphertext, pemTypeCiphertext
+	} else if !hasBytes {
+		return nil, nil, "", false
+	}
+	if err := json.Unmarshal(encoded, &body); err != nil {
+		return nil, nil, "", false
+	}
+	delete(fields, "bytes")
+	delete(fields, "ciphertext")
+	return fields, body, blockType, true
+}
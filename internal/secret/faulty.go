@@ -0,0 +1,241 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+)
+
+// The operations of a Remote store that can be faulty.
+const (
+	OpCreate = "create"
+	OpDelete = "delete"
+	OpGet    = "get"
+	OpList   = "list"
+	OpStat   = "stat"
+	OpStatus = "status"
+)
+
+// ErrFault is the default error returned by a FaultyRemote
+// for an injected fault. It is a transient error.
+var ErrFault = errs.New(kes.ErrBackendUnavailable, "secret: injected fault")
+
+// Fault describes a failure of a Remote store operation
+// that a FaultyRemote injects.
+//
+// Faults are deterministic. For example, the following
+// fault fails the 2nd and 3rd Get of any key:
+//   Fault{Op: OpGet, Skip: 1, Count: 2}
+type Fault struct {
+	Op  string // The operation - e.g. OpGet. If empty, the fault applies to all operations.
+	Key string // The key of the entry. If empty, the fault applies to all keys.
+
+	// Skip is the number of matching operations that
+	// succeed before the fault is injected.
+	Skip int
+
+	// Count is the number of times the fault is injected.
+	// If 0, the fault is injected for all subsequent
+	// matching operations.
+	Count int
+
+	// Latency delays the operation. A fault with
+	// latency but without Err or Partial slows
+	// down the operation without failing it.
+	Latency time.Duration
+
+	// Err is the error returned by the operation.
+	// If nil and the fault has no latency, it
+	// is ErrFault.
+	Err error
+
+	// Partial makes a create operation write only the
+	// first half of the value to the Remote store before
+	// failing with Err or ErrFault - like a key store
+	// that crashes while writing an entry.
+	Partial bool
+}
+
+// FaultyRemote is a Remote store that injects faults - like
+// latencies, transient errors or partial writes - into the
+// operations of the Remote store it wraps.
+//
+// It can be used to test how the Store and the server
+// handle an unreliable Remote store.
+type FaultyRemote struct {
+	Remote
+
+	lock   sync.Mutex
+	faults []*faultState
+}
+
+var (
+	_ Remote        = (*FaultyRemote)(nil)
+	_ Lister        = (*FaultyRemote)(nil)
+	_ Stater        = (*FaultyRemote)(nil)
+	_ StatusChecker = (*FaultyRemote)(nil)
+)
+
+// Inject adds the fault to the FaultyRemote. If multiple
+// faults match an operation, the one injected first wins.
+func (r *FaultyRemote) Inject(fault Fault) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.faults = append(r.faults, &faultState{Fault: fault})
+}
+
+// Reset removes all faults.
+func (r *FaultyRemote) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.faults = nil
+}
+
+// Create creates a new entry at the wrapped Remote store
+// unless a fault is injected.
+func (r *FaultyRemote) Create(key, value string) error {
+	fault, ok := r.fault(OpCreate, key)
+	if !ok || !fault.fails() {
+		return r.Remote.Create(key, value)
+	}
+	if fault.Partial {
+		if err := r.Remote.Create(key, value[:len(value)/2]); err != nil {
+			return err
+		}
+	}
+	return fault.err()
+}
+
+// Delete deletes an entry at the wrapped Remote
+// store unless a fault is injected.
+func (r *FaultyRemote) Delete(key string) error {
+	if fault, ok := r.fault(OpDelete, key); ok && fault.fails() {
+		return fault.err()
+	}
+	return r.Remote.Delete(key)
+}
+
+// Get fetches an entry from the wrapped Remote
+// store unless a fault is injected.
+func (r *FaultyRemote) Get(key string) (string, error) {
+	if fault, ok := r.fault(OpGet, key); ok && fault.fails() {
+		return "", fault.err()
+	}
+	return r.Remote.Get(key)
+}
+
+// List returns the names of all entries at the wrapped
+// Remote store unless a fault is injected. It returns
+// ErrListNotSupported if the wrapped Remote store does
+// not implement Lister.
+func (r *FaultyRemote) List() ([]string, error) {
+	if fault, ok := r.fault(OpList, ""); ok && fault.fails() {
+		return nil, fault.err()
+	}
+	lister, ok := r.Remote.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	return lister.List()
+}
+
+// ListPrefix returns the names of all entries at the
+// wrapped Remote store that start with the prefix unless
+// a fault is injected.
+func (r *FaultyRemote) ListPrefix(prefix string) ([]string, error) {
+	if fault, ok := r.fault(OpList, ""); ok && fault.fails() {
+		return nil, fault.err()
+	}
+	return ListPrefix(r.Remote, prefix)
+}
+
+// CreatedAt returns the point in time when the entry
+// has been created at the wrapped Remote store unless
+// a fault is injected. It returns ErrStatNotSupported
+// if the wrapped Remote store does not implement Stater.
+func (r *FaultyRemote) CreatedAt(key string) (time.Time, error) {
+	if fault, ok := r.fault(OpStat, key); ok && fault.fails() {
+		return time.Time{}, fault.err()
+	}
+	stater, ok := r.Remote.(Stater)
+	if !ok {
+		return time.Time{}, ErrStatNotSupported
+	}
+	return stater.CreatedAt(key)
+}
+
+// Status reports whether the wrapped Remote store is
+// available unless a fault is injected. If the wrapped
+// Remote store does not implement StatusChecker, it is
+// considered available.
+func (r *FaultyRemote) Status() error {
+	if fault, ok := r.fault(OpStatus, ""); ok && fault.fails() {
+		return fault.err()
+	}
+	if checker, ok := r.Remote.(StatusChecker); ok {
+		return checker.Status()
+	}
+	return nil
+}
+
+// fault returns the fault that should be injected into
+// the given operation, if any. It waits for the fault's
+// latency before returning.
+func (r *FaultyRemote) fault(op, key string) (Fault, bool) {
+	r.lock.Lock()
+	var (
+		fault Fault
+		found bool
+	)
+	for _, state := range r.faults {
+		if state.matches(op, key) && state.next() {
+			fault, found = state.Fault, true
+			break
+		}
+	}
+	r.lock.Unlock()
+
+	if found && fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	return fault, found
+}
+
+// faultState is a Fault and the number of operations
+// it has matched so far.
+type faultState struct {
+	Fault
+	matched int
+}
+
+func (s *faultState) matches(op, key string) bool {
+	return (s.Op == "" || s.Op == op) && (s.Key == "" || s.Key == key)
+}
+
+// next counts the matching operation and reports
+// whether the fault should be injected into it.
+func (s *faultState) next() bool {
+	s.matched++
+	if s.matched <= s.Skip {
+		return false
+	}
+	return s.Count == 0 || s.matched <= s.Skip+s.Count
+}
+
+// fails reports whether the fault fails the operation.
+func (f Fault) fails() bool { return f.Err != nil || f.Partial || f.Latency == 0 }
+
+func (f Fault) err() error {
+	if f.Err != nil {
+		return f.Err
+	}
+	return ErrFault
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+var faultyRemoteTests = []struct {
+	Fault Fault
+	Ops   []string // The sequence of operations
+	Fails []bool   // Whether the i-th operation fails
+}{
+	{
+		Fault: Fault{Op: OpGet},
+		Ops:   []string{OpGet, OpGet, OpDelete},
+		Fails: []bool{true, true, false},
+	},
+	{
+		Fault: Fault{Op: OpGet, Skip: 1, Count: 2},
+		Ops:   []string{OpGet, OpGet, OpGet, OpGet},
+		Fails: []bool{false, true, true, false},
+	},
+	{
+		Fault: Fault{Count: 1},
+		Ops:   []string{OpDelete, OpGet},
+		Fails: []bool{true, false},
+	},
+	{
+		Fault: Fault{Key: "other-key"},
+		Ops:   []string{OpGet, OpDelete},
+		Fails: []bool{false, true},
+	},
+	{
+		Fault: Fault{Op: OpGet, Latency: time.Millisecond},
+		Ops:   []string{OpGet},
+		Fails: []bool{false},
+	},
+}
+
+func TestFaultyRemote(t *testing.T) {
+	for i, test := range faultyRemoteTests {
+		remote := &FaultyRemote{Remote: remoteMap{"my-key": "my-value"}}
+		remote.Inject(test.Fault)
+
+		for j, op := range test.Ops {
+			var err error
+			switch op {
+			case OpGet:
+				_, err = remote.Get("my-key")
+			case OpDelete:
+				err = remote.Delete("other-key")
+			}
+			if fails := err != nil; fails != test.Fails[j] {
+				t.Fatalf("Test %d: operation %d: got error '%v' - want failure: %v", i, j, err, test.Fails[j])
+			}
+			if err != nil && err != ErrFault {
+				t.Fatalf("Test %d: operation %d: got error '%v' - want '%v'", i, j, err, ErrFault)
+			}
+		}
+	}
+}
+
+func TestFaultyRemoteLatency(t *testing.T) {
+	const Latency = 20 * time.Millisecond
+
+	remote := &FaultyRemote{Remote: remoteMap{}}
+	remote.Inject(Fault{Op: OpCreate, Latency: Latency})
+
+	start := time.Now()
+	if err := remote.Create("my-key", "my-value"); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < Latency {
+		t.Fatalf("Create has not been delayed: took %v - want at least %v", elapsed, Latency)
+	}
+}
+
+func TestFaultyRemoteCacheFallback(t *testing.T) {
+	remote := &FaultyRemote{Remote: remoteMap{}}
+	store := &Store{Remote: remote}
+
+	var key Secret
+	if err := store.Create(context.Background(), "my-key", key); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	// The Store must serve cached secrets while the
+	// Remote store is not available.
+	remote.Inject(Fault{Op: OpGet})
+	if _, err := store.Get(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to get cached secret: %v", err)
+	}
+
+	store.Evict("my-key")
+	if _, err := store.Get(context.Background(), "my-key"); !errors.Is(err, kes.ErrBackendUnavailable) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrBackendUnavailable)
+	}
+
+	remote.Reset()
+	if _, err := store.Get(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to get secret: %v", err)
+	}
+}
+
+func TestFaultyRemotePartialWrite(t *testing.T) {
+	remote := &FaultyRemote{Remote: remoteMap{}}
+	store := &Store{Remote: remote}
+
+	remote.Inject(Fault{Op: OpCreate, Partial: true, Count: 1})
+	var key Secret
+	if err := store.Create(context.Background(), "my-key", key); err != ErrFault {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrFault)
+	}
+	if _, err := store.Get(context.Background(), "my-key"); !errors.Is(err, kes.ErrCorrupted) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrCorrupted)
+	}
+}
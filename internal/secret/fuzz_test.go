@@ -0,0 +1,112 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package secret
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The fuzz targets check that the parsers of persisted
+// and client-provided values neither panic nor hang on
+// malformed input. Run them with:
+//   go test -run=^$ -fuzz=FuzzParseSecret ./internal/secret
+
+func FuzzParseSecret(f *testing.F) {
+	f.Add(`{"bytes":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}`)
+	f.Add(`{"bytes":"J8qmOyEV2ce2yoAC+5t0Y7CSP/hTMppL7XHpAnyc+0E=","ops":["encrypt"]}`)
+	f.Add(`{"bytes":"AAAA"}`)
+	f.Add(`{"bytes":null}`)
+	f.Add(`{"bytes":"` + string(bytes.Repeat([]byte("A"), 1024)) + `"}`)
+	f.Add(`{"ciphertext":"AAAA"}`)
+	f.Add(`[]`)
+	f.Add(``)
+
+	f.Fuzz(func(t *testing.T, value string) {
+		secret, err := ParseSecret(value)
+		if err != nil {
+			return
+		}
+		parsed, err := ParseSecret(secret.String())
+		if err != nil {
+			t.Fatalf("Failed to parse encoded secret: %v", err)
+		}
+		if parsed != secret {
+			t.Fatalf("Encoded secret does not match: got %x - want %x", parsed, secret)
+		}
+	})
+}
+
+func FuzzParseCiphertext(f *testing.F) {
+	f.Add(`{"ciphertext":"AAAA"}`)
+	f.Add(`{"ciphertext":"eyJhZWFkIjoiQUVTLTI1Ni1HQ00tSE1BQy1TSEEtMjU2In0=","ops":["decrypt"]}`)
+	f.Add(`{"ciphertext":""}`)
+	f.Add(`{"ciphertext":"!"}`)
+	f.Add(`{"bytes":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}`)
+	f.Add(`{`)
+
+	f.Fuzz(func(t *testing.T, value string) {
+		ciphertext, err := ParseCiphertext(value)
+		if err != nil {
+			return
+		}
+		parsed, err := ParseCiphertext(ciphertext.String())
+		if err != nil {
+			t.Fatalf("Failed to parse encoded ciphertext: %v", err)
+		}
+		if !bytes.Equal(parsed, ciphertext) {
+			t.Fatalf("Encoded ciphertext does not match: got %x - want %x", parsed, ciphertext)
+		}
+	})
+}
+
+func FuzzParseOps(f *testing.F) {
+	f.Add(`{"bytes":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=","ops":["encrypt","decrypt"]}`)
+	f.Add(`{"ops":[]}`)
+	f.Add(`{"ops":null}`)
+	f.Add(`{"ops":[1,2,3]}`)
+	f.Add(`{"ops":"generate"}`)
+
+	f.Fuzz(func(t *testing.T, value string) {
+		ops, err := ParseOps(value)
+		if err != nil {
+			return
+		}
+		for _, op := range ops {
+			AllowsOp(ops, op) // Must not panic
+		}
+	})
+}
+
+func FuzzSecretUnwrap(f *testing.F) {
+	var secret Secret
+	ciphertext, err := secret.Wrap([]byte("plaintext"), []byte("context"))
+	if err != nil {
+		f.Fatalf("Failed to wrap plaintext: %v", err)
+	}
+	f.Add(ciphertext, []byte("context"))
+	f.Add(ciphertext, []byte(nil))
+	f.Add([]byte(`{"aead":"ChaCha20Poly1305","iv":"AAAAAAAAAAAAAAAAAAAAAA==","nonce":"AAAAAAAAAAAAAAAA","bytes":""}`), []byte(nil))
+	f.Add([]byte(`{"aead":"AES-256-GCM-HMAC-SHA-256","iv":"","nonce":"","bytes":""}`), []byte(nil))
+	f.Add([]byte(`{"aead":"none"}`), []byte(nil))
+	f.Add([]byte(`null`), []byte(nil))
+
+	f.Fuzz(func(t *testing.T, ciphertext, associatedData []byte) {
+		plaintext, err := secret.Unwrap(ciphertext, associatedData)
+		if err != nil {
+			return
+		}
+
+		// The fuzzer may only produce authentic ciphertexts by
+		// modifying the seeds in ways that don't affect the
+		// authenticated content - e.g. the JSON whitespace.
+		if !bytes.Equal(plaintext, []byte("plaintext")) {
+			t.Fatalf("Unwrapped unexpected plaintext: got %q", plaintext)
+		}
+	})
+}
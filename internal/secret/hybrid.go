@@ -0,0 +1,262 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/mlkem"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// HybridAlgorithm is the algorithm of ciphertexts
+// produced by WrapHybrid. It is recorded as "aead"
+// of the ciphertext such that UnwrapHybrid can
+// decrypt them.
+const HybridAlgorithm = "X25519-MLKEM768-AES-256-GCM"
+
+// hybridLabel is the domain separation label of the
+// KEM combiner of WrapHybrid.
+const hybridLabel = "kes-hybrid-wrap-v1"
+
+// ReservedHybridName is the name of the Remote entry
+// that holds the KMS-encrypted HybridKey. The Store
+// refuses to create, fetch or delete a secret with
+// this name.
+const ReservedHybridName = ".kes-hybrid-kem"
+
+// HybridKeySize is the size of the seed from which
+// a HybridKey is generated.
+const HybridKeySize = 32 + mlkem.SeedSize
+
+// ErrHybridDisabled is returned when a ciphertext should
+// be produced or decrypted by the post-quantum hybrid
+// scheme but no HybridKey is available.
+var ErrHybridDisabled = kes.NewError(http.StatusNotImplemented, "hybrid wrapping is not enabled")
+
+// HybridKey is the X25519 and ML-KEM-768 key pair used
+// by WrapHybrid and UnwrapHybrid.
+//
+// It is generated at random - independent of any secret.
+// Hence, a ciphertext produced by WrapHybrid remains
+// confidential as long as either the HybridKey or the
+// secret is not compromised. The HybridKey is stored at
+// the Remote store encrypted with the KMS.
+// See: LoadHybridKey
+type HybridKey struct {
+	x25519Private    [32]byte
+	x25519Public     [32]byte
+	decapsulationKey *mlkem.DecapsulationKey
+}
+
+// NewHybridKey returns the HybridKey generated from the
+// seed. The seed must be HybridKeySize bytes long and
+// should be read from a cryptographically secure source
+// of random bytes.
+func NewHybridKey(seed []byte) (*HybridKey, error) {
+	if len(seed) != HybridKeySize {
+		return nil, errors.New("secret: invalid hybrid key seed size")
+	}
+	decapsulationKey, err := mlkem.NewDecapsulationKey(seed[32:])
+	if err != nil {
+		return nil, err
+	}
+	key := &HybridKey{decapsulationKey: decapsulationKey}
+	copy(key.x25519Private[:], seed[:32])
+	curve25519.ScalarBaseMult(&key.x25519Public, &key.x25519Private)
+	return key, nil
+}
+
+// LoadHybridKey fetches the KMS-encrypted HybridKey seed
+// from the Remote store and decrypts it with the KMS.
+//
+// If the Remote store does not contain a HybridKey,
+// LoadHybridKey reads a new seed from random and stores
+// it, encrypted with the KMS, at the Remote store. If
+// another server has stored a HybridKey in the meantime,
+// it uses this key instead. See: LoadEnclaveKey
+func LoadHybridKey(random io.Reader, remote Remote, kms KMS) (*HybridKey, error) {
+	value, err := remote.Get(ReservedHybridName)
+	if err == kes.ErrKeyNotFound {
+		var seed [HybridKeySize]byte
+		defer Wipe(seed[:])
+		if _, err = io.ReadFull(random, seed[:]); err != nil {
+			return nil, err
+		}
+
+		ciphertext, err := kms.Encrypt(seed[:], ReservedHybridName)
+		if err != nil {
+			return nil, err
+		}
+		switch err = remote.Create(ReservedHybridName, Ciphertext(ciphertext).String()); err {
+		case nil:
+			return NewHybridKey(seed[:])
+		case kes.ErrKeyExists: // Another server has been faster
+			value, err = remote.Get(ReservedHybridName)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := kms.Decrypt(ciphertext, ReservedHybridName)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(seed)
+
+	if len(seed) != HybridKeySize {
+		return nil, errors.New("hybrid key is malformed")
+	}
+	return NewHybridKey(seed)
+}
+
+// WrapHybrid encrypts and authenticates the plaintext,
+// authenticates the associatedData and returns the
+// resulting ciphertext - like Wrap. However, it encrypts
+// the plaintext with a key derived from a hybrid key
+// encapsulation to the HybridKey - X25519 and the
+// post-quantum ML-KEM-768 - and from the secret.
+//
+// WrapHybrid is experimental. It is meant for data that
+// has to be retained for a long time. Since the HybridKey
+// is independent of the secret, the ciphertext remains
+// confidential as long as either the HybridKey - i.e.
+// both of its KEMs - or the secret is not compromised.
+//
+// The ephemeral X25519 key, the ML-KEM encapsulation and
+// the nonce are read from random.
+func (s Secret) WrapHybrid(random io.Reader, key *HybridKey, plaintext, associatedData []byte) ([]byte, error) {
+	if key == nil {
+		return nil, ErrHybridDisabled
+	}
+	mlkemKey, mlkemCiphertext, err := mlkem.Encapsulate(random, key.decapsulationKey.EncapsulationKey())
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(mlkemKey)
+
+	var ephemeralPrivate, ephemeralPublic, x25519Key [32]byte
+	defer Wipe(ephemeralPrivate[:])
+	defer Wipe(x25519Key[:])
+	if _, err = io.ReadFull(random, ephemeralPrivate[:]); err != nil {
+		return nil, err
+	}
+	curve25519.ScalarBaseMult(&ephemeralPublic, &ephemeralPrivate)
+	curve25519.ScalarMult(&x25519Key, &ephemeralPrivate, &key.x25519Public)
+
+	seed := s.hmac([]byte(hybridLabel))
+	defer Wipe(seed[:])
+	aead, err := newHybridAEAD(&seed, mlkemKey, &x25519Key, &ephemeralPublic, &key.x25519Public)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+
+	type SealedSecret struct {
+		Algorithm string `json:"aead"`
+		KEM       []byte `json:"kem"`
+		Nonce     []byte `json:"nonce"`
+		Bytes     []byte `json:"bytes"`
+	}
+	return json.Marshal(SealedSecret{
+		Algorithm: HybridAlgorithm,
+		KEM:       append(mlkemCiphertext, ephemeralPublic[:]...),
+		Nonce:     nonce,
+		Bytes:     aead.Seal(nil, nonce, plaintext, associatedData),
+	})
+}
+
+// UnwrapHybrid decrypts and verifies a ciphertext
+// produced by WrapHybrid with the HybridKey. Any other
+// ciphertext is decrypted by Unwrap. Hence, UnwrapHybrid
+// decrypts ciphertexts produced by Wrap and WrapHybrid.
+func (s Secret) UnwrapHybrid(key *HybridKey, ciphertext, associatedData []byte) ([]byte, error) {
+	type SealedSecret struct {
+		Algorithm string `json:"aead"`
+		KEM       []byte `json:"kem"`
+		Nonce     []byte `json:"nonce"`
+		Bytes     []byte `json:"bytes"`
+	}
+	var sealedSecret SealedSecret
+	if err := json.Unmarshal(ciphertext, &sealedSecret); err != nil {
+		return nil, err
+	}
+	if sealedSecret.Algorithm != HybridAlgorithm {
+		return s.Unwrap(ciphertext, associatedData)
+	}
+	if key == nil {
+		return nil, ErrHybridDisabled
+	}
+	if len(sealedSecret.KEM) != mlkem.CiphertextSize+32 {
+		return nil, kes.NewError(http.StatusBadRequest, "invalid kem ciphertext")
+	}
+
+	mlkemKey, err := key.decapsulationKey.Decapsulate(sealedSecret.KEM[:mlkem.CiphertextSize])
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	defer Wipe(mlkemKey)
+
+	var ephemeralPublic, x25519Key, zero [32]byte
+	defer Wipe(x25519Key[:])
+	copy(ephemeralPublic[:], sealedSecret.KEM[mlkem.CiphertextSize:])
+	curve25519.ScalarMult(&x25519Key, &key.x25519Private, &ephemeralPublic)
+	if subtle.ConstantTimeCompare(x25519Key[:], zero[:]) == 1 { // Low-order point
+		return nil, kes.ErrDecrypt
+	}
+
+	seed := s.hmac([]byte(hybridLabel))
+	defer Wipe(seed[:])
+	aead, err := newHybridAEAD(&seed, mlkemKey, &x25519Key, &ephemeralPublic, &key.x25519Public)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(sealedSecret.Nonce); n != aead.NonceSize() {
+		return nil, kes.NewError(http.StatusBadRequest, "invalid nonce size")
+	}
+	plaintext, err := aead.Open(nil, sealedSecret.Nonce, sealedSecret.Bytes, associatedData)
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// newHybridAEAD returns an AES-256-GCM AEAD with a key
+// derived from the seed of the secret, both KEM shared
+// keys, the X25519 ephemeral and static public key -
+// similar to the X-Wing KEM combiner.
+func newHybridAEAD(seed *[32]byte, mlkemKey []byte, x25519Key, ephemeralPublic, x25519Public *[32]byte) (cipher.AEAD, error) {
+	h := sha3.New256()
+	h.Write(mlkemKey)
+	h.Write(x25519Key[:])
+	h.Write(ephemeralPublic[:])
+	h.Write(x25519Public[:])
+	h.Write(seed[:])
+	h.Write([]byte(hybridLabel))
+	key := h.Sum(nil)
+	defer Wipe(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/minio/kes"
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+func TestSecretWrapHybrid(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+	key, otherKey := newHybridKey(t), newHybridKey(t)
+	for i, test := range secretWrapTests {
+		data := make([]byte, test.KeyLen)
+		ciphertext, err := secret.WrapHybrid(rand.Reader, key, data, test.AssociatedData)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to wrap data: %v", i, err)
+		}
+		plaintext, err := secret.UnwrapHybrid(key, ciphertext, test.AssociatedData)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to unwrap data: %v", i, err)
+		}
+		if !bytes.Equal(data, plaintext) {
+			t.Fatalf("Test %d: Original plaintext does not match unwrapped plaintext", i)
+		}
+
+		if _, err = secret.UnwrapHybrid(key, ciphertext, append(test.AssociatedData, 0)); err == nil {
+			t.Fatalf("Test %d: Unwrapped data with invalid associated data", i)
+		}
+		var otherSecret Secret
+		copy(otherSecret[:], sioutil.MustRandom(len(otherSecret)))
+		if _, err = otherSecret.UnwrapHybrid(key, ciphertext, test.AssociatedData); err == nil {
+			t.Fatalf("Test %d: Unwrapped data with a different secret", i)
+		}
+		if _, err = secret.UnwrapHybrid(otherKey, ciphertext, test.AssociatedData); err == nil {
+			t.Fatalf("Test %d: Unwrapped data with a different hybrid key", i)
+		}
+		if _, err = secret.Unwrap(ciphertext, test.AssociatedData); err != ErrHybridDisabled {
+			t.Fatalf("Test %d: Unwrap should fail with %v: got %v", i, ErrHybridDisabled, err)
+		}
+	}
+}
+
+func TestSecretUnwrapHybrid(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+
+	ciphertext, err := secret.Wrap([]byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap data: %v", err)
+	}
+	for _, key := range []*HybridKey{nil, newHybridKey(t)} {
+		plaintext, err := secret.UnwrapHybrid(key, ciphertext, nil)
+		if err != nil {
+			t.Fatalf("Failed to unwrap data: %v", err)
+		}
+		if !bytes.Equal(plaintext, []byte("plaintext")) {
+			t.Fatalf("Plaintext mismatch: got %q", plaintext)
+		}
+	}
+
+	if _, err = secret.WrapHybrid(rand.Reader, nil, []byte("plaintext"), nil); err != ErrHybridDisabled {
+		t.Fatalf("WrapHybrid should fail with %v: got %v", ErrHybridDisabled, err)
+	}
+}
+
+func TestLoadHybridKey(t *testing.T) {
+	var (
+		remote = remoteMap{}
+		kms    = xorKMS{0x17}
+	)
+	key, err := LoadHybridKey(rand.Reader, remote, kms)
+	if err != nil {
+		t.Fatalf("Failed to create hybrid key: %v", err)
+	}
+	if _, ok := remote[ReservedHybridName]; !ok {
+		t.Fatal("Hybrid key has not been stored at the remote store")
+	}
+
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+	ciphertext, err := secret.WrapHybrid(rand.Reader, key, []byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap data: %v", err)
+	}
+
+	loaded, err := LoadHybridKey(rand.Reader, remote, kms)
+	if err != nil {
+		t.Fatalf("Failed to load hybrid key: %v", err)
+	}
+	if _, err = secret.UnwrapHybrid(loaded, ciphertext, nil); err != nil {
+		t.Fatalf("Failed to unwrap data with loaded hybrid key: %v", err)
+	}
+	if _, err = LoadHybridKey(rand.Reader, remote, xorKMS{0x42}); err == nil {
+		t.Fatal("Loaded hybrid key with a different KMS")
+	}
+	if err = (&Store{Remote: remote}).Create(context.Background(), ReservedHybridName, secret); err == nil || err == kes.ErrKeyExists {
+		t.Fatalf("Created secret with reserved name: %v", err)
+	}
+}
+
+func TestSecretWrapHybridKEM(t *testing.T) {
+	var secret Secret
+	copy(secret[:], sioutil.MustRandom(len(secret)))
+	key := newHybridKey(t)
+	ciphertext, err := secret.WrapHybrid(rand.Reader, key, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatalf("Failed to wrap data: %v", err)
+	}
+
+	type SealedSecret struct {
+		Algorithm string `json:"aead"`
+		KEM       []byte `json:"kem"`
+		Nonce     []byte `json:"nonce"`
+		Bytes     []byte `json:"bytes"`
+	}
+	var sealedSecret SealedSecret
+	if err = json.Unmarshal(ciphertext, &sealedSecret); err != nil {
+		t.Fatalf("Failed to parse ciphertext: %v", err)
+	}
+	if sealedSecret.Algorithm != HybridAlgorithm {
+		t.Fatalf("Invalid algorithm: got %s - want %s", sealedSecret.Algorithm, HybridAlgorithm)
+	}
+
+	// Modifying the ML-KEM or X25519 part of the KEM
+	// ciphertext must cause the decryption to fail.
+	for _, i := range []int{0, 1087, 1088, 1119} {
+		modified := sealedSecret
+		modified.KEM = append([]byte{}, sealedSecret.KEM...)
+		modified.KEM[i] ^= 1
+
+		c, err := json.Marshal(modified)
+		if err != nil {
+			t.Fatalf("Failed to encode ciphertext: %v", err)
+		}
+		if _, err = secret.UnwrapHybrid(key, c, nil); err == nil {
+			t.Fatalf("Unwrapped data with modified KEM ciphertext byte %d", i)
+		}
+	}
+}
+
+func newHybridKey(t *testing.T) *HybridKey {
+	key, err := NewHybridKey(sioutil.MustRandom(HybridKeySize))
+	if err != nil {
+		t.Fatalf("Failed to create hybrid key: %v", err)
+	}
+	return key
+}
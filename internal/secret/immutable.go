@@ -0,0 +1,76 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+)
+
+// ErrImmutable is returned by Delete if the secret is
+// immutable. See: Store.CreateImmutable
+var ErrImmutable = kes.NewError(http.StatusForbidden, "key is immutable")
+
+// CreateImmutable behaves like CreateWithOps but marks the
+// secret as immutable. An immutable secret can never be
+// deleted or replaced through the Store. It can only be
+// disabled. See: Store.Disable
+//
+// The immutability is stored together with the secret.
+// Hence, it is enforced independent of the Remote store
+// and preserved by backups.
+func (s *Store) CreateImmutable(ctx context.Context, name string, secret Secret, ops []string) error {
+	ops, err := normalizeOps(ops)
+	if err != nil {
+		return err
+	}
+	return s.create(ctx, name, secret, ops, true)
+}
+
+// ParseImmutable reports whether the secret stored as
+// Remote value is immutable.
+func ParseImmutable(value string) (bool, error) {
+	var v struct {
+		Immutable bool `json:"immutable"`
+	}
+	if err := json.NewDecoder(strings.NewReader(value)).Decode(&v); err != nil {
+		return false, errors.New("secret is malformed")
+	}
+	return v.Immutable, nil
+}
+
+// isImmutable reports whether the secret with the given
+// name is immutable. Since a secret cannot become mutable,
+// the result is cached until the secret gets evicted.
+func (s *Store) isImmutable(name string) (bool, error) {
+	if immutable, ok := s.immutable.Load(name); ok {
+		return immutable.(bool), nil
+	}
+	value, err := s.load(name)
+	if err != nil {
+		return false, err
+	}
+	immutable, err := ParseImmutable(value)
+	if err != nil {
+		return false, err
+	}
+	s.immutable.Store(name, immutable)
+	return immutable, nil
+}
+
+// withImmutable marks the JSON-encoded value of a secret
+// or ciphertext as immutable. It returns the value as it
+// is if immutable is false.
+func withImmutable(value string, immutable bool) string {
+	if !immutable {
+		return value
+	}
+	return strings.TrimSuffix(value, "}") + `,"immutable":true}`
+}
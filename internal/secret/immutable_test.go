@@ -0,0 +1,84 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStoreImmutable(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	if err := store.CreateImmutable(context.Background(), "root-key", Secret{}, []string{OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create immutable key: %v", err)
+	}
+	if err := store.Create(context.Background(), "my-key", Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	if err := store.Delete(context.Background(), "root-key"); err != ErrImmutable {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrImmutable)
+	}
+	info, err := store.Stat("root-key")
+	if err != nil {
+		t.Fatalf("Failed to stat key: %v", err)
+	}
+	if !info.Immutable || len(info.AllowedOps) != 1 || info.AllowedOps[0] != OpDecrypt {
+		t.Fatalf("Invalid key info: %+v", info)
+	}
+
+	// The immutability is stored together with the key.
+	// Hence, another Store must not delete the key either.
+	other := &Store{Remote: remote}
+	if err = other.Delete(context.Background(), "root-key"); err != ErrImmutable {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrImmutable)
+	}
+	if err = other.Delete(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to delete mutable key: %v", err)
+	}
+	if err = other.Delete(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to delete non-existing key: %v", err)
+	}
+}
+
+func TestStoreDisable(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	if err := store.CreateImmutable(context.Background(), "root-key", Secret{}, nil); err != nil {
+		t.Fatalf("Failed to create immutable key: %v", err)
+	}
+
+	if err := store.Disable("root-key"); err != nil {
+		t.Fatalf("Failed to disable key: %v", err)
+	}
+	if _, err := store.Get(context.Background(), "root-key"); err != ErrDisabled {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrDisabled)
+	}
+	if _, err := store.GetFor(context.Background(), "root-key", OpDecrypt); err != ErrDisabled {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrDisabled)
+	}
+	if info, err := store.Stat("root-key"); err != nil || !info.Disabled {
+		t.Fatalf("Key is not disabled: %+v - %v", info, err)
+	}
+
+	loaded := &Store{Remote: remote}
+	if err := loaded.LoadStates(); err != nil {
+		t.Fatalf("Failed to load key states: %v", err)
+	}
+	if !loaded.State("root-key").Disabled {
+		t.Fatal("Loaded key state is not disabled")
+	}
+
+	if err := store.Enable("root-key"); err != nil {
+		t.Fatalf("Failed to enable key: %v", err)
+	}
+	if _, err := store.Get(context.Background(), "root-key"); err != nil {
+		t.Fatalf("Failed to get enabled key: %v", err)
+	}
+	if err := store.Disable("other-key"); err == nil {
+		t.Fatal("Disabled non-existing key")
+	}
+}
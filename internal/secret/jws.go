@@ -0,0 +1,230 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+)
+
+// The JWS algorithms supported by signing keys.
+// See: RFC 7518 and RFC 8037
+const (
+	AlgES256 = "ES256" // ECDSA using P-256 and SHA-256
+	AlgEdDSA = "EdDSA" // Ed25519
+	AlgRS256 = "RS256" // RSASSA-PKCS1-v1_5 using SHA-256
+)
+
+var (
+	errNotJWS       = kes.NewError(http.StatusBadRequest, "key is not a signing key")
+	errJWSAlgorithm = kes.NewError(http.StatusBadRequest, "signing algorithm must be ES256, EdDSA or RS256")
+)
+
+// JWK is the public key of a signing key as JSON Web Key.
+// See: RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// CreateSigningKey generates a new asymmetric signing key
+// for the given JWS algorithm and adds it to the Store. If
+// algorithm is empty, CreateSigningKey uses ES256.
+//
+// Like opaque secrets, a signing key shares the namespace
+// of keys and cannot be used as cryptographic key. Its
+// private key never leaves the Store. Instead, the Store
+// signs payloads with it. See: Store.SignJWS
+func (s *Store) CreateSigningKey(ctx context.Context, name, algorithm string) error {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch algorithm {
+	case "", AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), s.Reader())
+	case AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(s.Reader())
+	case AlgRS256:
+		key, err = rsa.GenerateKey(s.Reader(), 2048)
+	default:
+		return errJWSAlgorithm
+	}
+	if err != nil {
+		return err
+	}
+	rawKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	defer Wipe(rawKey)
+
+	value := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKey})
+	defer Wipe(value)
+	return s.createTyped(ctx, name, value, TypeJWS)
+}
+
+// SignJWS signs the payload with the signing key with the
+// given name and returns the JWS in compact serialization.
+// See: RFC 7515
+//
+// The protected header contains the signing algorithm and
+// the ID of the signing key as well as the optional type
+// and content type - e.g. "JWT" for JSON Web Tokens.
+func (s *Store) SignJWS(ctx context.Context, name string, payload []byte, typ, contentType string) (string, error) {
+	key, err := s.getSigningKey(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	jwk, err := publicJWK(key.Public())
+	if err != nil {
+		return "", errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+
+	header, err := json.Marshal(struct {
+		Algorithm   string `json:"alg"`
+		KeyID       string `json:"kid"`
+		Type        string `json:"typ,omitempty"`
+		ContentType string `json:"cty,omitempty"`
+	}{
+		Algorithm:   jwk.Algorithm,
+		KeyID:       jwk.KeyID,
+		Type:        typ,
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// A JWS ECDSA signature is the concatenation of the
+		// fixed-size, big-endian encoded r and s values.
+		signature = append(leftPad(r.Bytes(), 32), leftPad(s.Bytes(), 32)...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signingInput))
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWK returns the public key of the signing key with
+// the given name. Clients use it to verify the JWS
+// signed by the Store.
+func (s *Store) JWK(ctx context.Context, name string) (JWK, error) {
+	key, err := s.getSigningKey(ctx, name)
+	if err != nil {
+		return JWK{}, err
+	}
+	jwk, err := publicJWK(key.Public())
+	if err != nil {
+		return JWK{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+	return jwk, nil
+}
+
+// getSigningKey returns the private key of the signing key
+// with the given name.
+func (s *Store) getSigningKey(ctx context.Context, name string) (crypto.Signer, error) {
+	value, err := s.getTyped(ctx, name, TypeJWS, errNotJWS)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(value)
+
+	block, _ := pem.Decode(value)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': signing key is malformed", name)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': signing key is malformed", name)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': signing key is not supported", name)
+	}
+}
+
+// publicJWK returns the public key as JWK. The key ID is
+// the JWK thumbprint as described by RFC 7638.
+func publicJWK(publicKey crypto.PublicKey) (JWK, error) {
+	var (
+		jwk        JWK
+		thumbprint string
+	)
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return JWK{}, errors.New("elliptic curve is not supported")
+		}
+		x, y := leftPad(key.X.Bytes(), 32), leftPad(key.Y.Bytes(), 32)
+		jwk = JWK{KeyType: "EC", Algorithm: AlgES256, Curve: "P-256", X: encode(x), Y: encode(y)}
+		thumbprint = `{"crv":"P-256","kty":"EC","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`
+	case ed25519.PublicKey:
+		jwk = JWK{KeyType: "OKP", Algorithm: AlgEdDSA, Curve: "Ed25519", X: encode(key)}
+		thumbprint = `{"crv":"Ed25519","kty":"OKP","x":"` + jwk.X + `"}`
+	case *rsa.PublicKey:
+		jwk = JWK{KeyType: "RSA", Algorithm: AlgRS256, N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+		thumbprint = `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	default:
+		return JWK{}, errors.New("public key is not supported")
+	}
+	id := sha256.Sum256([]byte(thumbprint))
+	jwk.KeyID = encode(id[:])
+	jwk.Use = "sig"
+	return jwk, nil
+}
+
+// leftPad prepends zero bytes to b until it is n bytes long.
+func leftPad(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b
+	}
+	padded := make([]byte, n)
+	copy(padded[n-len(b):], b)
+	return padded
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var signJWSTests = []struct {
+	Algorithm string
+	Type      string
+}{
+	{Algorithm: "", Type: "JWT"},       // 0
+	{Algorithm: AlgES256, Type: ""},    // 1
+	{Algorithm: AlgEdDSA, Type: "JWT"}, // 2
+	{Algorithm: AlgRS256, Type: "JWT"}, // 3
+}
+
+func TestStoreSignJWS(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		store   = &Store{Remote: remoteMap{}, KMS: xorKMS{0x5a}}
+		payload = []byte(`{"sub":"my-service"}`)
+	)
+	for i, test := range signJWSTests {
+		name := fmt.Sprintf("my-key-%d", i)
+		if err := store.CreateSigningKey(ctx, name, test.Algorithm); err != nil {
+			t.Fatalf("Test %d: failed to create signing key: %v", i, err)
+		}
+		jws, err := store.SignJWS(ctx, name, payload, test.Type, "")
+		if err != nil {
+			t.Fatalf("Test %d: failed to sign payload: %v", i, err)
+		}
+		jwk, err := store.JWK(ctx, name)
+		if err != nil {
+			t.Fatalf("Test %d: failed to fetch JWK: %v", i, err)
+		}
+
+		parts := strings.Split(jws, ".")
+		if len(parts) != 3 {
+			t.Fatalf("Test %d: invalid JWS: %s", i, jws)
+		}
+		var header struct {
+			Algorithm string `json:"alg"`
+			KeyID     string `json:"kid"`
+			Type      string `json:"typ"`
+		}
+		if err = json.Unmarshal(decodeBase64URL(t, parts[0]), &header); err != nil {
+			t.Fatalf("Test %d: invalid JWS header: %v", i, err)
+		}
+		if header.Algorithm != jwk.Algorithm || header.KeyID != jwk.KeyID || header.Type != test.Type {
+			t.Fatalf("Test %d: invalid JWS header: got %+v - JWK %+v", i, header, jwk)
+		}
+		if got := decodeBase64URL(t, parts[1]); string(got) != string(payload) {
+			t.Fatalf("Test %d: invalid JWS payload: got %s - want %s", i, got, payload)
+		}
+		if !verifyJWS(t, jwk, parts[0]+"."+parts[1], decodeBase64URL(t, parts[2])) {
+			t.Fatalf("Test %d: failed to verify JWS signature", i)
+		}
+	}
+
+	if err := store.CreateSigningKey(ctx, "my-key-hs256", "HS256"); err != errJWSAlgorithm {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errJWSAlgorithm)
+	}
+	if err := store.CreateOpaque(ctx, "my-token", []byte("s3cr3t")); err != nil {
+		t.Fatalf("Failed to create opaque secret: %v", err)
+	}
+	if _, err := store.SignJWS(ctx, "my-token", payload, "", ""); err != errNotJWS {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotJWS)
+	}
+	if _, err := store.GetOpaque(ctx, "my-key-0"); err != errNotOpaque {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotOpaque)
+	}
+	if _, err := store.Get(ctx, "my-key-0"); err != ErrNotKey {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrNotKey)
+	}
+}
+
+func decodeBase64URL(t *testing.T, s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid base64url encoding: %v", err)
+	}
+	return b
+}
+
+func verifyJWS(t *testing.T, jwk JWK, signingInput string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch jwk.Algorithm {
+	case AlgES256:
+		publicKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(decodeBase64URL(t, jwk.X)),
+			Y:     new(big.Int).SetBytes(decodeBase64URL(t, jwk.Y)),
+		}
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(publicKey, digest[:], r, s)
+	case AlgEdDSA:
+		return ed25519.Verify(ed25519.PublicKey(decodeBase64URL(t, jwk.X)), []byte(signingInput), signature)
+	case AlgRS256:
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decodeBase64URL(t, jwk.N)),
+			E: int(new(big.Int).SetBytes(decodeBase64URL(t, jwk.E)).Int64()),
+		}
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	default:
+		t.Fatalf("Unsupported JWS algorithm: %s", jwk.Algorithm)
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// KMS is a key management system that holds a
+// master key and encrypts / decrypts secrets
+// with it. The master key never leaves the KMS.
+//
+// A Store uses a KMS to encrypt secrets before
+// writing them to its Remote store and to decrypt
+// them after fetching them from the Remote store.
+type KMS interface {
+	// Encrypt encrypts the plaintext with the KMS
+	// master key and cryptographically binds the
+	// context to the returned ciphertext.
+	Encrypt(plaintext []byte, context string) ([]byte, error)
+
+	// Decrypt decrypts the ciphertext with the KMS
+	// master key. The context must be equal to the
+	// context provided when encrypting the plaintext.
+	//
+	// The caller owns the returned plaintext and
+	// should wipe it once it is no longer needed.
+	// See: Wipe
+	Decrypt(ciphertext []byte, context string) ([]byte, error)
+}
+
+// Ciphertext is a secret that has been
+// encrypted by a KMS.
+type Ciphertext []byte
+
+// ParseCiphertext parses s as JSON-encoded
+// Ciphertext. It returns an error if s is
+// not a well-formed Ciphertext.
+func ParseCiphertext(s string) (Ciphertext, error) {
+	type CiphertextJSON struct {
+		Bytes []byte `json:"ciphertext"`
+	}
+
+	var ciphertextJSON CiphertextJSON
+	if err := json.NewDecoder(strings.NewReader(s)).Decode(&ciphertextJSON); err != nil {
+		return nil, errors.New("ciphertext is malformed")
+	}
+	if len(ciphertextJSON.Bytes) == 0 {
+		return nil, errors.New("ciphertext is malformed")
+	}
+	return Ciphertext(ciphertextJSON.Bytes), nil
+}
+
+func (c Ciphertext) String() string {
+	return `{"ciphertext":"` + base64.StdEncoding.EncodeToString(c) + `"}`
+}
+
+// VerifyCiphertext reports whether the KMS can decrypt the
+// value stored at a Remote store under the given name - i.e.
+// a key or a typed secret encrypted by a Store with the KMS.
+// It does not return the plaintext.
+func VerifyCiphertext(kms KMS, name, value string) error {
+	typ, err := ParseType(value)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return err
+	}
+
+	context := typeContext(name, typ)
+	if typ == TypeKey {
+		ops, err := ParseOps(value)
+		if err != nil {
+			return err
+		}
+		context = kmsContext(name, ops)
+	}
+	plaintext, err := kms.Decrypt(ciphertext, context)
+	if err != nil {
+		return err
+	}
+	defer Wipe(plaintext)
+	if typ == TypeKey && len(plaintext) != len(Secret{}) {
+		return errors.New("secret is malformed")
+	}
+	return nil
+}
+
+// DecryptSecret decrypts the key stored at a Remote store
+// under the given name by a Store with the KMS - e.g. to
+// import the keys of another server that uses the same KMS.
+//
+// It returns an error if the value is not a KMS-encrypted
+// key but e.g. an opaque secret.
+func DecryptSecret(kms KMS, name, value string) (Secret, error) {
+	if typ, err := ParseType(value); err != nil {
+		return Secret{}, err
+	} else if typ != TypeKey {
+		return Secret{}, ErrNotKey
+	}
+	ops, err := ParseOps(value)
+	if err != nil {
+		return Secret{}, err
+	}
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return Secret{}, err
+	}
+	plaintext, err := kms.Decrypt(ciphertext, kmsContext(name, ops))
+	if err != nil {
+		return Secret{}, err
+	}
+	defer Wipe(plaintext)
+
+	var secret Secret
+	if len(plaintext) != len(secret) {
+		return Secret{}, errors.New("secret is malformed")
+	}
+	copy(secret[:], plaintext)
+	return secret, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestParseCiphertext(t *testing.T) {
+	ciphertext := Ciphertext(bytes.Repeat([]byte{0xff}, 64))
+	parsed, err := ParseCiphertext(ciphertext.String())
+	if err != nil {
+		t.Fatalf("Failed to parse ciphertext: %v", err)
+	}
+	if !bytes.Equal(parsed, ciphertext) {
+		t.Fatalf("Parsed ciphertext does not match: got %x - want %x", parsed, ciphertext)
+	}
+
+	if _, err = ParseCiphertext(`{"ciphertext":""}`); err == nil {
+		t.Fatal("Parsed empty ciphertext")
+	}
+}
+
+func TestVerifyCiphertext(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		store  = &Store{Remote: remote, KMS: xorKMS{0x5a}}
+	)
+	if err := store.Create(ctx, "my-key", Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateWithOps(ctx, "my-ops-key", Secret{1}, []string{OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateOpaque(ctx, "my-secret", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	for _, name := range []string{"my-key", "my-ops-key", "my-secret"} {
+		value, err := remote.Get(name)
+		if err != nil {
+			t.Fatalf("Failed to fetch '%s': %v", name, err)
+		}
+		if err = VerifyCiphertext(xorKMS{0x5a}, name, value); err != nil {
+			t.Fatalf("Failed to verify '%s': %v", name, err)
+		}
+		if err = VerifyCiphertext(xorKMS{0x42}, name, value); err == nil {
+			t.Fatalf("Verified '%s' with another KMS", name)
+		}
+		if err = VerifyCiphertext(xorKMS{0x5a}, "other-name", value); err == nil {
+			t.Fatalf("Verified '%s' under another name", name)
+		}
+	}
+}
+
+func TestDecryptSecret(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		store  = &Store{Remote: remote, KMS: xorKMS{0x5a}}
+	)
+	if err := store.CreateWithOps(ctx, "my-key", Secret{1}, []string{OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateOpaque(ctx, "my-secret", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	value, _ := remote.Get("my-key")
+	secret, err := DecryptSecret(xorKMS{0x5a}, "my-key", value)
+	if err != nil {
+		t.Fatalf("Failed to decrypt key: %v", err)
+	}
+	if secret != (Secret{1}) {
+		t.Fatalf("Decrypted key does not match: got %x - want %x", secret, Secret{1})
+	}
+	if _, err = DecryptSecret(xorKMS{0x5a}, "other-name", value); err == nil {
+		t.Fatal("Decrypted key under another name")
+	}
+
+	value, _ = remote.Get("my-secret")
+	if _, err = DecryptSecret(xorKMS{0x5a}, "my-secret", value); err != ErrNotKey {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrNotKey)
+	}
+}
+
+// benchmarkCiphertext has roughly the size of a
+// secret encrypted by a KMS - like AWS-KMS.
+var benchmarkCiphertext = Ciphertext(bytes.Repeat([]byte{0xff}, 184))
+
+func BenchmarkCiphertextString(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkCiphertext)))
+	for i := 0; i < b.N; i++ {
+		_ = benchmarkCiphertext.String()
+	}
+}
+
+func BenchmarkParseCiphertext(b *testing.B) {
+	s := benchmarkCiphertext.String()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkCiphertext)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseCiphertext(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSecretWrap(b *testing.B) {
+	var (
+		secret    Secret
+		plaintext = make([]byte, 32)
+	)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(plaintext)))
+	for i := 0; i < b.N; i++ {
+		if _, err := secret.Wrap(plaintext, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSecretUnwrap(b *testing.B) {
+	var secret Secret
+	ciphertext, err := secret.Wrap(make([]byte, 32), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(32)
+	for i := 0; i < b.N; i++ {
+		if _, err := secret.Unwrap(ciphertext, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"time"
+
+	"github.com/minio/kes"
+	xlog "github.com/minio/kes/internal/log"
+)
+
+// LoggingRemote is a Remote store that logs all failed
+// operations of the Remote store it wraps as errors. It
+// does not log expected errors - like kes.ErrKeyNotFound -
+// that are handled by the Store. Successful operations
+// are logged at debug level.
+//
+// A Remote store implementation can rely on the
+// LoggingRemote instead of logging errors itself.
+type LoggingRemote struct {
+	Remote
+
+	// Log is the logger of the wrapped Remote store
+	// - e.g. of the component "fs". If nil, logging is
+	// done via the log package's standard logger.
+	Log *xlog.Logger
+}
+
+var (
+	_ Remote        = LoggingRemote{}
+	_ Lister        = LoggingRemote{}
+	_ Stater        = LoggingRemote{}
+	_ StatusChecker = LoggingRemote{}
+)
+
+// Create creates a new entry at the wrapped Remote
+// store and logs the error, if any.
+func (r LoggingRemote) Create(key, value string) error {
+	err := r.Remote.Create(key, value)
+	switch {
+	case err == nil:
+		r.Log.Debug("created entry", "key", key)
+	case err != kes.ErrKeyExists:
+		r.Log.Error("failed to create entry", "key", key, "err", err)
+	}
+	return err
+}
+
+// Delete deletes an entry at the wrapped Remote
+// store and logs the error, if any.
+func (r LoggingRemote) Delete(key string) error {
+	err := r.Remote.Delete(key)
+	if err != nil {
+		r.Log.Error("failed to delete entry", "key", key, "err", err)
+	} else {
+		r.Log.Debug("deleted entry", "key", key)
+	}
+	return err
+}
+
+// Get fetches an entry from the wrapped Remote
+// store and logs the error, if any.
+func (r LoggingRemote) Get(key string) (string, error) {
+	value, err := r.Remote.Get(key)
+	switch {
+	case err == nil:
+		r.Log.Debug("read entry", "key", key)
+	case err != kes.ErrKeyNotFound:
+		r.Log.Error("failed to read entry", "key", key, "err", err)
+	}
+	return value, err
+}
+
+// List returns the names of all entries at the wrapped
+// Remote store and logs the error, if any. It returns
+// ErrListNotSupported if the wrapped Remote store does
+// not implement Lister.
+func (r LoggingRemote) List() ([]string, error) {
+	lister, ok := r.Remote.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	names, err := lister.List()
+	if err != nil {
+		r.Log.Error("failed to list entries", "err", err)
+	}
+	return names, err
+}
+
+// ListPrefix returns the names of all entries at the
+// wrapped Remote store that start with the prefix and
+// logs the error, if any.
+func (r LoggingRemote) ListPrefix(prefix string) ([]string, error) {
+	names, err := ListPrefix(r.Remote, prefix)
+	if err != nil && err != ErrListNotSupported {
+		r.Log.Error("failed to list entries", "prefix", prefix, "err", err)
+	}
+	return names, err
+}
+
+// CreatedAt returns the point in time when the entry has
+// been created at the wrapped Remote store and logs the
+// error, if any. It returns ErrStatNotSupported if the
+// wrapped Remote store does not implement Stater.
+func (r LoggingRemote) CreatedAt(key string) (time.Time, error) {
+	stater, ok := r.Remote.(Stater)
+	if !ok {
+		return time.Time{}, ErrStatNotSupported
+	}
+	createdAt, err := stater.CreatedAt(key)
+	if err != nil && err != kes.ErrKeyNotFound && err != ErrStatNotSupported {
+		r.Log.Error("failed to stat entry", "key", key, "err", err)
+	}
+	return createdAt, err
+}
+
+// Status reports whether the wrapped Remote store is
+// available. If the wrapped Remote store does not
+// implement StatusChecker, Status tries to fetch an
+// entry - like Store.Status does.
+func (r LoggingRemote) Status() error {
+	if checker, ok := r.Remote.(StatusChecker); ok {
+		return checker.Status()
+	}
+	if _, err := r.Get(ReservedName); err != nil && err != kes.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+	xlog "github.com/minio/kes/internal/log"
+)
+
+func TestLoggingRemote(t *testing.T) {
+	var buffer bytes.Buffer
+	remote := LoggingRemote{
+		Remote: remoteMap{},
+		Log:    xlog.NewLogger(&buffer, "", 0).Logger("test"),
+	}
+	if err := remote.Create("my-key", "value"); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := remote.Create("my-key", "value"); err != kes.ErrKeyExists {
+		t.Fatalf("Created entry twice: %v", err)
+	}
+	if _, err := remote.Get("other-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Fetched non-existing entry: %v", err)
+	}
+	if _, err := remote.List(); err != ErrListNotSupported {
+		t.Fatalf("Listed entries of a Remote store without Lister: %v", err)
+	}
+	if buffer.Len() != 0 {
+		t.Fatalf("Expected errors have been logged: %q", buffer.String())
+	}
+
+	remote.Remote = failingRemote{}
+	if _, err := remote.Get("my-key"); err == nil {
+		t.Fatal("Failing Remote store returned no error")
+	}
+	if err := remote.Status(); err == nil {
+		t.Fatal("Failing Remote store is available")
+	}
+	if got := buffer.String(); !strings.HasPrefix(got, `[ERROR] test: failed to read entry err="remote is not available" key=my-key`+"\n") {
+		t.Fatalf("Error has not been logged: got %q", got)
+	}
+}
+
+type failingRemote struct{}
+
+var errRemoteUnavailable = errors.New("remote is not available")
+
+func (failingRemote) Create(key, value string) error { return errRemoteUnavailable }
+
+func (failingRemote) Delete(key string) error { return errRemoteUnavailable }
+
+func (failingRemote) Get(key string) (string, error) { return "", errRemoteUnavailable }
@@ -0,0 +1,67 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import "strings"
+
+// MaxNameDepth is the max. number of segments
+// of a secret name.
+const MaxNameDepth = 16
+
+// ValidName reports whether name is a valid secret name.
+//
+// A name consists of one or more segments separated by
+// a '/' - e.g. "tenant/app/my-key". The segments form a
+// hierarchy that Remote stores may map to directories or
+// paths. Therefore, no segment must be empty, "." or "..".
+func ValidName(name string) bool {
+	if name == "" || strings.Count(name, "/") >= MaxNameDepth {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "", ".", "..":
+			return false
+		}
+		if strings.ContainsAny(segment, "\\\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReserved reports whether name is reserved for
+// entries managed by the server itself - like the
+// policies or the provenance of keys.
+func IsReserved(name string) bool { return isReserved(name) }
+
+// ListPrefix returns the names of all entries of the
+// Remote store that start with the given prefix. The
+// prefix must be empty or end with a '/'.
+//
+// If the Remote store does not implement PrefixLister
+// it lists all entries and filters them. It returns
+// ErrListNotSupported if the Remote store implements
+// neither PrefixLister nor Lister.
+func ListPrefix(r Remote, prefix string) ([]string, error) {
+	if lister, ok := r.(PrefixLister); ok {
+		return lister.ListPrefix(prefix)
+	}
+	lister, ok := r.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	entries, err := lister.List()
+	if err != nil {
+		return nil, err
+	}
+	names := entries[:0]
+	for _, name := range entries {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
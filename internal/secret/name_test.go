@@ -0,0 +1,36 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+var validNameTests = []struct {
+	Name  string
+	Valid bool
+}{
+	{Name: "my-key", Valid: true},                                        // 0
+	{Name: "tenant/app/my-key", Valid: true},                             // 1
+	{Name: ".hidden", Valid: true},                                       // 2
+	{Name: "", Valid: false},                                             // 3
+	{Name: "/my-key", Valid: false},                                      // 4
+	{Name: "my-key/", Valid: false},                                      // 5
+	{Name: "tenant//my-key", Valid: false},                               // 6
+	{Name: "tenant/../my-key", Valid: false},                             // 7
+	{Name: "./my-key", Valid: false},                                     // 8
+	{Name: "tenant\\my-key", Valid: false},                               // 9
+	{Name: strings.Repeat("a/", MaxNameDepth-1) + "my-key", Valid: true}, // 10
+	{Name: strings.Repeat("a/", MaxNameDepth) + "my-key", Valid: false},  // 11
+}
+
+func TestValidName(t *testing.T) {
+	for i, test := range validNameTests {
+		if valid := ValidName(test.Name); valid != test.Valid {
+			t.Fatalf("Test %d: got %v - want %v", i, valid, test.Valid)
+		}
+	}
+}
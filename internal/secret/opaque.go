@@ -0,0 +1,222 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/trace"
+	"github.com/minio/kes/internal/watch"
+)
+
+// The types of secrets a Store can hold.
+const (
+	TypeKey    = "key"    // A 256 bit cryptographic key. See: Secret
+	TypeOpaque = "opaque" // Arbitrary bytes - e.g. an API token or a certificate
+	TypeCA     = "ca"     // A certificate authority. See: Store.CreateCA
+	TypeJWS    = "jws"    // An asymmetric signing key. See: Store.CreateSigningKey
+)
+
+// MaxOpaqueSize is the max. size of an opaque secret.
+// Even when encrypted by a KMS, an opaque secret of this
+// size does not exceed MaxSize once encoded as Remote value.
+const MaxOpaqueSize = 256 << 10 // 256 KiB
+
+var (
+	// ErrNotKey is returned when an opaque secret, a CA
+	// or a signing key is fetched from the Store as
+	// cryptographic key - e.g. to encrypt or decrypt data.
+	ErrNotKey = kes.NewError(http.StatusBadRequest, "secret is not a cryptographic key")
+
+	errNotOpaque = kes.NewError(http.StatusBadRequest, "key is not an opaque secret")
+)
+
+// CreateOpaque adds the opaque secret value with the given
+// name to the Store. The value must not be empty and must
+// not be larger than MaxOpaqueSize.
+//
+// Keys and opaque secrets share the same namespace. Hence,
+// CreateOpaque returns kes.ErrKeyExists if there is already
+// a key or opaque secret with the given name.
+//
+// An opaque secret cannot be used as cryptographic key. The
+// Store returns ErrNotKey when an opaque secret is fetched
+// via Get or GetFor. If the Store has a KMS, the type of the
+// secret is bound to the KMS ciphertext.
+func (s *Store) CreateOpaque(ctx context.Context, name string, value []byte) error {
+	if len(value) == 0 || len(value) > MaxOpaqueSize {
+		return kes.NewError(http.StatusBadRequest, fmt.Sprintf("secret size must be between 1 and %d bytes", MaxOpaqueSize))
+	}
+	return s.createTyped(ctx, name, value, TypeOpaque)
+}
+
+// GetOpaque returns the opaque secret with the given name.
+// It returns an error if the name refers to a cryptographic
+// key. Keys never leave the Store as plaintext.
+//
+// The caller owns the returned value and should wipe it
+// once it is no longer needed. See: Wipe
+func (s *Store) GetOpaque(ctx context.Context, name string) ([]byte, error) {
+	return s.getTyped(ctx, name, TypeOpaque, errNotOpaque)
+}
+
+// createTyped adds the value as secret of the given type
+// to the Store. Keys are created via create instead.
+func (s *Store) createTyped(ctx context.Context, name string, value []byte, typ string) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if s.isAlias(name) {
+		return kes.ErrKeyExists
+	}
+
+	entry := `{"bytes":"` + base64.StdEncoding.EncodeToString(value) + `"}`
+	if s.KMS != nil {
+		_, span := trace.StartSpan(ctx, "kms.encrypt")
+		ciphertext, err := s.KMS.Encrypt(value, typeContext(name, typ))
+		span.SetError(err)
+		span.Finish()
+		if err != nil {
+			return err
+		}
+		entry = Ciphertext(ciphertext).String()
+	}
+	entry = withType(entry, typ)
+
+	if err := s.restore(name); err != nil { // See: Store.create
+		return err
+	}
+	_, span := trace.StartSpan(ctx, "store.create")
+	err := s.Remote.Create(name, entry)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return err
+	}
+	s.types.Store(name, typ)
+	s.immutable.Store(name, false)
+	s.publish(watch.ActionCreated, name, "")
+	return nil
+}
+
+// getTyped returns the value of the secret with the given
+// name. It returns errType if the secret is not of the given
+// type.
+func (s *Store) getTyped(ctx context.Context, name, typ string, errType error) ([]byte, error) {
+	if isReserved(name) {
+		return nil, errReservedName
+	}
+	if state := s.State(name); state.Disabled {
+		return nil, ErrDisabled
+	} else if state.PendingDeletion != nil {
+		return nil, ErrPendingDeletion
+	}
+
+	_, span := trace.StartSpan(ctx, "store.get")
+	entry, err := s.load(name)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if t, err := ParseType(entry); err != nil {
+		return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	} else if t != typ {
+		return nil, errType
+	}
+
+	var value []byte
+	if s.KMS == nil {
+		var v struct {
+			Bytes []byte `json:"bytes"`
+		}
+		if err = json.NewDecoder(strings.NewReader(entry)).Decode(&v); err != nil || len(v.Bytes) == 0 {
+			return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': secret is malformed", name)
+		}
+		value = v.Bytes
+	} else {
+		ciphertext, err := ParseCiphertext(entry)
+		if err != nil {
+			return nil, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+		}
+		_, span = trace.StartSpan(ctx, "kms.decrypt")
+		value, err = s.KMS.Decrypt(ciphertext, typeContext(name, typ))
+		span.SetError(err)
+		span.Finish()
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.touch(name)
+	return value, nil
+}
+
+// ParseType returns the type of the secret stored as
+// Remote value - either TypeKey, TypeOpaque, TypeCA or
+// TypeJWS.
+func ParseType(value string) (string, error) {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(strings.NewReader(value)).Decode(&v); err != nil {
+		return "", errors.New("secret is malformed")
+	}
+	switch v.Type {
+	case "", TypeKey:
+		return TypeKey, nil
+	case TypeOpaque:
+		return TypeOpaque, nil
+	case TypeCA:
+		return TypeCA, nil
+	case TypeJWS:
+		return TypeJWS, nil
+	default:
+		return "", errors.New("secret type '" + v.Type + "' is not supported")
+	}
+}
+
+// typeOf returns the type of the secret with the given
+// name. Since the type of a secret cannot change, the
+// result is cached until the secret gets evicted.
+func (s *Store) typeOf(name string) (string, error) {
+	if typ, ok := s.types.Load(name); ok {
+		return typ.(string), nil
+	}
+	value, err := s.load(name)
+	if err != nil {
+		return "", err
+	}
+	typ, err := ParseType(value)
+	if err != nil {
+		return "", err
+	}
+	s.types.Store(name, typ)
+	return typ, nil
+}
+
+// withType adds the type to the JSON-encoded value of a
+// secret or ciphertext. It returns the value as it is for
+// keys such that their encoding remains unchanged.
+func withType(value, typ string) string {
+	if typ == TypeKey {
+		return value
+	}
+	return strings.TrimSuffix(value, "}") + `,"type":"` + typ + `"}`
+}
+
+// typeContext returns the KMS context of the secret with
+// the given name and type. It differs from the context of
+// any key such that a KMS ciphertext of an opaque secret, CA
+// or signing key cannot be decrypted as key - even if its type
+// is removed.
+func typeContext(name, typ string) string { return name + "\n" + typ }
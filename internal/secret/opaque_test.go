@@ -0,0 +1,80 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreOpaque(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		kms    = xorKMS{0x5a}
+		token  = bytes.Repeat([]byte{0x01}, 32) // As large as a key
+	)
+	store := &Store{Remote: remote, KMS: kms}
+	if err := store.CreateOpaque(ctx, "my-token", token); err != nil {
+		t.Fatalf("Failed to create opaque secret: %v", err)
+	}
+	if err := store.CreateOpaque(ctx, "my-token", []byte("other")); err != kes.ErrKeyExists {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if err := store.CreateOpaque(ctx, "empty", nil); err == nil {
+		t.Fatal("Empty opaque secret has been created")
+	}
+	if err := store.CreateOpaque(ctx, "large", make([]byte, MaxOpaqueSize+1)); err == nil {
+		t.Fatal("Too large opaque secret has been created")
+	}
+	if err := store.Create(ctx, "my-key", Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// A fresh Store must distinguish opaque
+	// secrets and keys stored at the Remote store.
+	store = &Store{Remote: remote, KMS: kms}
+	value, err := store.GetOpaque(ctx, "my-token")
+	if err != nil {
+		t.Fatalf("Failed to fetch opaque secret: %v", err)
+	}
+	if !bytes.Equal(value, token) {
+		t.Fatalf("Invalid opaque secret: got %x - want %x", value, token)
+	}
+	if _, err = store.Get(ctx, "my-token"); err != ErrNotKey {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrNotKey)
+	}
+	if _, err = store.GetFor(ctx, "my-token", OpGenerate); err != ErrNotKey {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrNotKey)
+	}
+	if _, err = store.GetOpaque(ctx, "my-key"); err != errNotOpaque {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotOpaque)
+	}
+	if info, err := store.Stat("my-token"); err != nil || info.Type != TypeOpaque {
+		t.Fatalf("Invalid secret type: %+v - %v", info, err)
+	}
+	if info, err := store.Stat("my-key"); err != nil || info.Type != TypeKey {
+		t.Fatalf("Invalid secret type: %+v - %v", info, err)
+	}
+
+	// Removing the type from a sealed opaque secret
+	// must not produce a key.
+	remote["my-token"] = remote["my-token"][:strings.Index(remote["my-token"], `,"type"`)] + "}"
+	store = &Store{Remote: remote, KMS: kms}
+	if _, err = store.Get(ctx, "my-token"); err == nil {
+		t.Fatal("Opaque secret without its type has been fetched as key")
+	}
+
+	if err = store.Delete(ctx, "my-token"); err != nil {
+		t.Fatalf("Failed to delete opaque secret: %v", err)
+	}
+	if _, err = store.GetOpaque(ctx, "my-token"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/minio/kes"
+)
+
+// The operations a secret can be restricted to.
+// See: Store.CreateWithOps
+const (
+	OpGenerate = "generate" // Generate data keys
+	OpEncrypt  = "encrypt"  // Encrypt plaintexts
+	OpDecrypt  = "decrypt"  // Decrypt ciphertexts
+	OpExport   = "export"   // Include the secret in backups
+)
+
+var errInvalidOps = kes.NewError(http.StatusBadRequest, "invalid key operation: must be generate, encrypt, decrypt or export")
+
+// ErrOpNotAllowed returns the error returned by GetFor if the
+// key usage policy of a secret does not allow the operation.
+func ErrOpNotAllowed(op string) error {
+	return kes.NewError(http.StatusForbidden, fmt.Sprintf("key usage policy does not allow '%s'", op))
+}
+
+// CreateWithOps behaves like Create but restricts the secret
+// to the given operations - e.g. OpDecrypt. The secret cannot
+// be used for any other operation - independent of the policy
+// of the identity that uses it. If ops is empty, the secret
+// can be used for any operation.
+//
+// The operations are stored together with the secret. If the
+// Store has a KMS, they are bound to the KMS ciphertext such
+// that they cannot be removed without the KMS.
+func (s *Store) CreateWithOps(ctx context.Context, name string, secret Secret, ops []string) error {
+	ops, err := normalizeOps(ops)
+	if err != nil {
+		return err
+	}
+	return s.create(ctx, name, secret, ops, false)
+}
+
+// GetFor behaves like Get but returns ErrOpNotAllowed if the
+// key usage policy of the secret does not allow the given
+// operation.
+func (s *Store) GetFor(ctx context.Context, name, op string) (Secret, error) {
+	if isReserved(name) {
+		return Secret{}, errReservedName
+	}
+	secret, err := s.get(ctx, name)
+	if err != nil {
+		return Secret{}, err
+	}
+	ops, err := s.opsOf(name)
+	if err != nil {
+		return Secret{}, err
+	}
+	if !AllowsOp(ops, op) {
+		return Secret{}, ErrOpNotAllowed(op)
+	}
+	s.touch(name) // A rejected operation does not count as use
+	return secret, nil
+}
+
+// ParseOps returns the operations the secret stored as
+// Remote value is restricted to. It returns nil if the
+// secret is not restricted.
+func ParseOps(value string) ([]string, error) {
+	var v struct {
+		Ops []string `json:"ops"`
+	}
+	if err := json.NewDecoder(strings.NewReader(value)).Decode(&v); err != nil {
+		return nil, errors.New("secret is malformed")
+	}
+	return v.Ops, nil
+}
+
+// AllowsOp reports whether a secret restricted to the
+// given operations can be used for the operation op.
+// A secret without any restrictions can be used for
+// any operation.
+func AllowsOp(ops []string, op string) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// opsOf returns the operations the secret with the given
+// name is restricted to. The operations are cached once
+// the secret has been created or fetched by this Store.
+func (s *Store) opsOf(name string) ([]string, error) {
+	if ops, ok := s.ops.Load(name); ok {
+		return ops.([]string), nil
+	}
+	value, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := ParseOps(value)
+	if err != nil {
+		return nil, err
+	}
+	s.ops.Store(name, ops)
+	return ops, nil
+}
+
+// normalizeOps returns the sorted operations without
+// duplicates. It returns an error if any operation is
+// not a valid operation.
+func normalizeOps(ops []string) ([]string, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	set := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		switch op {
+		case OpGenerate, OpEncrypt, OpDecrypt, OpExport:
+			set[op] = true
+		default:
+			return nil, errInvalidOps
+		}
+	}
+	normalized := make([]string, 0, len(set))
+	for op := range set {
+		normalized = append(normalized, op)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// kmsContext returns the KMS context of the secret with
+// the given name and operations. A secret without any
+// restrictions uses its name as context - as before key
+// usage policies have been introduced. Hence, the
+// operations cannot be removed from a sealed secret.
+func kmsContext(name string, ops []string) string {
+	if len(ops) == 0 {
+		return name
+	}
+	return name + "\n" + strings.Join(ops, ",")
+}
+
+// withOps adds the operations to the JSON-encoded value
+// of a secret or ciphertext. It returns the value as it
+// is if there are no operations.
+func withOps(value string, ops []string) string {
+	if len(ops) == 0 {
+		return value
+	}
+	b, _ := json.Marshal(ops) // Marshaling a []string cannot fail
+	return strings.TrimSuffix(value, "}") + `,"ops":` + string(b) + "}"
+}
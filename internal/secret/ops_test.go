@@ -0,0 +1,61 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreCreateWithOps(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		kms    = xorKMS{0x5a}
+	)
+	store := &Store{Remote: remote, KMS: kms}
+	if err := store.CreateWithOps(ctx, "my-key", Secret{1}, []string{OpDecrypt, OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.CreateWithOps(ctx, "other-key", Secret{}, []string{"sign"}); err == nil {
+		t.Fatal("Key with invalid operation has been created")
+	}
+	if err := store.Create(ctx, "any-key", Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// A fresh Store must enforce the operations
+	// stored at the Remote store.
+	store = &Store{Remote: remote, KMS: kms}
+	if _, err := store.GetFor(ctx, "my-key", OpDecrypt); err != nil {
+		t.Fatalf("Failed to fetch key for %s: %v", OpDecrypt, err)
+	}
+	_, err := store.GetFor(ctx, "my-key", OpGenerate)
+	if kesErr, ok := err.(kes.Error); !ok || kesErr.Status() != http.StatusForbidden {
+		t.Fatalf("Key restricted to %s has been fetched for %s: %v", OpDecrypt, OpGenerate, err)
+	}
+	if _, err = store.GetFor(ctx, "any-key", OpGenerate); err != nil {
+		t.Fatalf("Failed to fetch unrestricted key: %v", err)
+	}
+	info, err := store.Stat("my-key")
+	if err != nil {
+		t.Fatalf("Failed to stat key: %v", err)
+	}
+	if len(info.AllowedOps) != 1 || info.AllowedOps[0] != OpDecrypt {
+		t.Fatalf("Invalid allowed operations: got %v - want [%s]", info.AllowedOps, OpDecrypt)
+	}
+
+	// Removing the operations from a sealed key must
+	// not produce an unrestricted key.
+	remote["my-key"] = remote["my-key"][:strings.Index(remote["my-key"], `,"ops"`)] + "}"
+	store = &Store{Remote: remote, KMS: kms}
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Key without its operations has been fetched")
+	}
+}
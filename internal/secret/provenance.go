@@ -0,0 +1,205 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// The provenance event types.
+const (
+	ProvenanceCreated   = "created"   // Generated by the server
+	ProvenanceImported  = "imported"  // Imported by a client
+	ProvenanceRestored  = "restored"  // Restored from a backup archive
+	ProvenanceDeleted   = "deleted"   // Deleted from the key store
+	ProvenanceHeld      = "held"      // Placed under legal hold
+	ProvenanceReleased  = "released"  // Released from legal hold
+	ProvenanceScheduled = "scheduled" // Scheduled for deletion
+	ProvenanceCancelled = "cancelled" // Scheduled deletion has been cancelled
+)
+
+// errMalformedProvenance is returned when a persisted
+// provenance chain does not verify.
+var errMalformedProvenance = errors.New("secret: persisted key provenance is malformed")
+
+// ProvenanceEvent describes how a secret came into
+// existence or left the key store - e.g. whether it
+// has been generated by the server or imported - and
+// when it has been placed under or released from a
+// legal hold or scheduled for deletion.
+//
+// The events of a secret form an append-only chain.
+// Each event contains a hash over its content and the
+// hash of the previous event. Hence, modifying or
+// removing an event changes the hash of all subsequent
+// events. See: VerifyProvenance
+type ProvenanceEvent struct {
+	Type     string       `json:"type"`
+	Time     time.Time    `json:"time"`
+	Identity kes.Identity `json:"identity,omitempty"` // The identity that caused the event, if any
+
+	// Origin describes the context of the event - e.g.
+	// the backup archive the secret has been restored
+	// from or the job that has deleted it.
+	Origin string `json:"origin,omitempty"`
+
+	Hash []byte `json:"hash"`
+}
+
+// VerifyProvenance checks that the events form an unmodified
+// chain. It returns an error if any event has been modified,
+// removed or reordered.
+//
+// VerifyProvenance cannot detect a chain that has been
+// recomputed entirely. Auditors should compare the hash
+// of the last event with a previously seen one.
+func VerifyProvenance(events []ProvenanceEvent) error {
+	var prev []byte
+	for _, event := range events {
+		if !Equal(event.Hash, event.hash(prev)) {
+			return errMalformedProvenance
+		}
+		prev = event.Hash
+	}
+	return nil
+}
+
+// AppendProvenance appends the event to the provenance of
+// the secret with the given name. If the event has no time,
+// AppendProvenance uses the current time.
+//
+// The provenance is kept when the secret is deleted such
+// that the history of a key name remains available.
+//
+// Since a Remote store cannot update an entry, AppendProvenance
+// deletes and re-creates the entry.
+func (s *Store) AppendProvenance(name string, event ProvenanceEvent) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	s.provenanceLock.Lock()
+	defer s.provenanceLock.Unlock()
+
+	events, err := s.provenanceOf(name)
+	if err != nil {
+		return err
+	}
+	var prev []byte
+	if len(events) > 0 {
+		prev = events[len(events)-1].Hash
+	}
+	event.Hash = event.hash(prev)
+	return s.storeProvenance(name, append(events, event), len(events) > 0)
+}
+
+// ImportProvenance stores the events - e.g. from a backup
+// archive - as the provenance of the secret with the given
+// name if the secret has no provenance yet. Otherwise, it
+// keeps the existing provenance and does nothing.
+//
+// It returns an error if the events do not verify.
+// See: VerifyProvenance
+func (s *Store) ImportProvenance(name string, events []ProvenanceEvent) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := VerifyProvenance(events); err != nil {
+		return err
+	}
+
+	s.provenanceLock.Lock()
+	defer s.provenanceLock.Unlock()
+
+	if err := s.storeProvenance(name, events, false); err != kes.ErrKeyExists {
+		return err
+	}
+	return nil
+}
+
+// Provenance returns the provenance of the secret with the
+// given name - oldest event first. It returns the provenance
+// of deleted secrets as well.
+//
+// It returns kes.ErrKeyNotFound if neither the secret nor its
+// provenance exist. Secrets created before the server recorded
+// provenance have no events.
+func (s *Store) Provenance(name string) ([]ProvenanceEvent, error) {
+	if isReserved(name) {
+		return nil, errReservedName
+	}
+
+	s.provenanceLock.Lock()
+	events, err := s.provenanceOf(name)
+	s.provenanceLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		if _, err = s.load(name); err != nil {
+			return nil, err
+		}
+		return []ProvenanceEvent{}, nil
+	}
+	if err = VerifyProvenance(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// provenanceOf returns the provenance of the secret
+// with the given name from the Remote store. It returns
+// no events if the secret has no provenance.
+func (s *Store) provenanceOf(name string) ([]ProvenanceEvent, error) {
+	value, err := Load(s.Remote, ReservedProvenancePrefix+name)
+	if err == kes.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []ProvenanceEvent
+	if err = json.Unmarshal([]byte(value), &events); err != nil {
+		return nil, errMalformedProvenance
+	}
+	return events, nil
+}
+
+// storeProvenance writes the events to the Remote store.
+// If replace is true, it replaces the existing entry.
+func (s *Store) storeProvenance(name string, events []ProvenanceEvent, replace bool) error {
+	value, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	if replace {
+		return Replace(s.Remote, ReservedProvenancePrefix+name, string(value))
+	}
+	return s.Remote.Create(ReservedProvenancePrefix+name, string(value))
+}
+
+// hash returns the hash of the event chained to the
+// hash of the previous event. It ignores the event's
+// current hash.
+func (e ProvenanceEvent) hash(prev []byte) []byte {
+	e.Hash = nil
+	content, _ := json.Marshal(e) // Marshaling a ProvenanceEvent cannot fail
+
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(content)
+	return h.Sum(nil)
+}
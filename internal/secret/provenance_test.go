@@ -0,0 +1,68 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreProvenance(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	if _, err := store.Provenance("my-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Non-existing key has provenance: %v", err)
+	}
+	if err := store.Create(context.Background(), "my-key", Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if events, err := store.Provenance("my-key"); err != nil || len(events) != 0 {
+		t.Fatalf("Key without recorded provenance has events: %v - %v", events, err)
+	}
+
+	if err := store.AppendProvenance("my-key", ProvenanceEvent{Type: ProvenanceImported, Identity: "af43c"}); err != nil {
+		t.Fatalf("Failed to append provenance: %v", err)
+	}
+	if err := store.Delete(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err := store.AppendProvenance("my-key", ProvenanceEvent{Type: ProvenanceDeleted}); err != nil {
+		t.Fatalf("Failed to append provenance: %v", err)
+	}
+	events, err := store.Provenance("my-key")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance of deleted key: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != ProvenanceImported || events[0].Identity != "af43c" || events[1].Type != ProvenanceDeleted {
+		t.Fatalf("Invalid provenance: %+v", events)
+	}
+	if events[0].Time.IsZero() {
+		t.Fatal("Provenance event has no time")
+	}
+	if names, _ := store.List(); len(names) != 0 {
+		t.Fatalf("Provenance entries are listed as keys: %v", names)
+	}
+
+	// Importing provenance does not replace an existing one.
+	if err = store.ImportProvenance("my-key", events[:1]); err != nil {
+		t.Fatalf("Failed to import provenance: %v", err)
+	}
+	if events, _ = store.Provenance("my-key"); len(events) != 2 {
+		t.Fatalf("Imported provenance replaced the existing one: %+v", events)
+	}
+
+	// Modifying any event breaks the chain.
+	name := ReservedProvenancePrefix + "my-key"
+	remote[name] = strings.Replace(remote[name], "af43c", "af43d", 1)
+	if _, err = store.Provenance("my-key"); err == nil {
+		t.Fatal("Modified provenance has been accepted")
+	}
+	if err = store.ImportProvenance("other-key", []ProvenanceEvent{events[1]}); err == nil {
+		t.Fatal("Incomplete provenance has been imported")
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/aws"
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/fs"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/secret"
+	"github.com/minio/kes/internal/vault"
+)
+
+// TestRemote checks that all Remote store implementations
+// behave the same - for randomly generated key names and
+// values.
+//
+// The fs and mem stores are always tested. The remote key
+// stores are tested when the environment points to an
+// instance - e.g. a local Vault dev server or LocalStack:
+//   KES_TEST_VAULT_ADDR, KES_TEST_VAULT_APPROLE_ID, KES_TEST_VAULT_APPROLE_SECRET
+//   KES_TEST_AWS_ENDPOINT, KES_TEST_AWS_REGION
+// See: make test-backends
+func TestRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-remote-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range remoteTests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			remote, ok := test.New(t, filepath.Join(dir, test.Name))
+			if !ok {
+				t.Skipf("Skipping %s: not configured", test.Name)
+			}
+
+			config := &quick.Config{MaxCount: test.MaxCount, Rand: rand.New(rand.NewSource(1))}
+			for _, property := range remoteProperties {
+				f := func(name remoteName, value remoteValue) bool {
+					defer remote.Delete(string(name))
+					return property.Check(t, remote, string(name), string(value))
+				}
+				if err := quick.Check(f, config); err != nil {
+					t.Fatalf("Property '%s' does not hold: %v", property.Name, err)
+				}
+			}
+
+			store := &secret.Store{Remote: remote}
+			f := func(name remoteName, key secret.Secret) bool {
+				defer store.Delete(context.Background(), string(name))
+				return checkStoreRoundTrip(t, store, string(name), key)
+			}
+			if err := quick.Check(f, config); err != nil {
+				t.Fatalf("Property 'store round-trip' does not hold: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteErrorKind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kes-remote-")
+	if err != nil {
+		t.Fatalf("Failed to create temp. directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// A Store whose directory is a file fails all operations.
+	// The errors must not be mistaken for the expected errors
+	// of a Remote store and must have an API error kind.
+	remote := &fs.Store{Dir: file}
+	if err := remote.Create("my-key", "my-value"); !errors.Is(err, kes.ErrBackendFailure) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, kes.ErrBackendFailure)
+	}
+	if _, err := remote.Get("my-key"); err == kes.ErrKeyNotFound {
+		t.Fatalf("Invalid error: got '%v'", err)
+	}
+	if _, err := remote.Get("my-key"); err != nil {
+		if kind, ok := errs.Kind(err); !ok || kind != kes.ErrBackendFailure {
+			t.Fatalf("Invalid error kind: got '%v' - want '%v'", kind, kes.ErrBackendFailure)
+		}
+	}
+}
+
+var remoteTests = []struct {
+	Name     string
+	MaxCount int                                                  // The number of generated key names and values per property
+	New      func(t *testing.T, dir string) (secret.Remote, bool) // dir is a non-existing path within a temp. directory
+}{
+	{
+		Name:     "mem",
+		MaxCount: 100,
+		New:      func(*testing.T, string) (secret.Remote, bool) { return &mem.Store{}, true },
+	},
+	{
+		Name:     "fs",
+		MaxCount: 100,
+		New: func(t *testing.T, dir string) (secret.Remote, bool) {
+			if err := os.Mkdir(dir, 0700); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			return &fs.Store{Dir: dir}, true
+		},
+	},
+	{
+		Name:     "vault",
+		MaxCount: 10,
+		New: func(t *testing.T, _ string) (secret.Remote, bool) {
+			addr, ok := os.LookupEnv("KES_TEST_VAULT_ADDR")
+			if !ok {
+				return nil, false
+			}
+			store := &vault.Store{
+				Addr:     addr,
+				Engine:   "kv",
+				Location: "kes-test",
+				AppRole: vault.AppRole{
+					Engine: "approle",
+					ID:     os.Getenv("KES_TEST_VAULT_APPROLE_ID"),
+					Secret: os.Getenv("KES_TEST_VAULT_APPROLE_SECRET"),
+				},
+			}
+			if err := store.Authenticate(context.Background()); err != nil {
+				t.Fatalf("Failed to connect to Vault: %v", err)
+			}
+			return store, true
+		},
+	},
+	{
+		Name:     "aws-secrets-manager",
+		MaxCount: 10,
+		New: func(t *testing.T, _ string) (secret.Remote, bool) {
+			addr, ok := os.LookupEnv("KES_TEST_AWS_ENDPOINT")
+			if !ok {
+				return nil, false
+			}
+			store := &aws.SecretsManager{
+				Addr:   addr,
+				Region: awsTestRegion(),
+				Login:  aws.Credentials{AccessKey: "test", SecretKey: "test"},
+			}
+			if err := store.Authenticate(); err != nil {
+				t.Fatalf("Failed to connect to AWS Secrets Manager: %v", err)
+			}
+			return store, true
+		},
+	},
+	{
+		Name:     "aws-parameter-store",
+		MaxCount: 10,
+		New: func(t *testing.T, _ string) (secret.Remote, bool) {
+			addr, ok := os.LookupEnv("KES_TEST_AWS_ENDPOINT")
+			if !ok {
+				return nil, false
+			}
+			store := &aws.ParameterStore{
+				Addr:   addr,
+				Region: awsTestRegion(),
+				Prefix: "/kes-test/",
+				Login:  aws.Credentials{AccessKey: "test", SecretKey: "test"},
+			}
+			if err := store.Authenticate(); err != nil {
+				t.Fatalf("Failed to connect to AWS Parameter Store: %v", err)
+			}
+			return store, true
+		},
+	},
+}
+
+var remoteProperties = []struct {
+	Name  string
+	Check func(t *testing.T, remote secret.Remote, name, value string) bool
+}{
+	{
+		Name: "create-get round-trip",
+		Check: func(t *testing.T, remote secret.Remote, name, value string) bool {
+			if err := remote.Create(name, value); err != nil {
+				t.Logf("Failed to create '%s': %v", name, err)
+				return false
+			}
+			v, err := remote.Get(name)
+			if err != nil {
+				t.Logf("Failed to get '%s': %v", name, err)
+				return false
+			}
+			return v == value
+		},
+	},
+	{
+		Name: "create does not replace",
+		Check: func(t *testing.T, remote secret.Remote, name, value string) bool {
+			if err := remote.Create(name, value); err != nil {
+				t.Logf("Failed to create '%s': %v", name, err)
+				return false
+			}
+			if err := remote.Create(name, value+"-replaced"); err != kes.ErrKeyExists {
+				t.Logf("Created '%s' twice: %v", name, err)
+				return false
+			}
+			v, err := remote.Get(name)
+			return err == nil && v == value
+		},
+	},
+	{
+		Name: "get non-existing",
+		Check: func(t *testing.T, remote secret.Remote, name, _ string) bool {
+			_, err := remote.Get(name)
+			if err != kes.ErrKeyNotFound {
+				t.Logf("Got non-existing entry '%s': %v", name, err)
+				return false
+			}
+			return true
+		},
+	},
+	{
+		Name: "delete is idempotent",
+		Check: func(t *testing.T, remote secret.Remote, name, value string) bool {
+			if err := remote.Create(name, value); err != nil {
+				t.Logf("Failed to create '%s': %v", name, err)
+				return false
+			}
+			for i := 0; i < 2; i++ {
+				if err := remote.Delete(name); err != nil {
+					t.Logf("Failed to delete '%s': %v", name, err)
+					return false
+				}
+			}
+			if _, err := remote.Get(name); err != kes.ErrKeyNotFound {
+				t.Logf("Got deleted entry '%s': %v", name, err)
+				return false
+			}
+			return true
+		},
+	},
+	{
+		Name: "re-create after delete",
+		Check: func(t *testing.T, remote secret.Remote, name, value string) bool {
+			if err := remote.Create(name, value+"-deleted"); err != nil {
+				t.Logf("Failed to create '%s': %v", name, err)
+				return false
+			}
+			if err := remote.Delete(name); err != nil {
+				t.Logf("Failed to delete '%s': %v", name, err)
+				return false
+			}
+			if err := remote.Create(name, value); err != nil {
+				t.Logf("Failed to re-create '%s': %v", name, err)
+				return false
+			}
+			v, err := remote.Get(name)
+			return err == nil && v == value
+		},
+	},
+}
+
+// checkStoreRoundTrip checks that a secret stored at the
+// Store is the same secret when fetched from the Store or
+// the Remote store.
+func checkStoreRoundTrip(t *testing.T, store *secret.Store, name string, key secret.Secret) bool {
+	ctx := context.Background()
+	if err := store.Create(ctx, name, key); err != nil {
+		t.Logf("Failed to create '%s': %v", name, err)
+		return false
+	}
+	if err := store.Create(ctx, name, key); err != kes.ErrKeyExists {
+		t.Logf("Created '%s' twice: %v", name, err)
+		return false
+	}
+	cached, err := store.Get(ctx, name)
+	if err != nil || cached != key {
+		t.Logf("Failed to get '%s' from the cache: %v", name, err)
+		return false
+	}
+
+	store.Evict(name)
+	stored, err := store.Get(ctx, name)
+	if err != nil || stored != key {
+		t.Logf("Failed to get '%s' from the remote store: %v", name, err)
+		return false
+	}
+	return true
+}
+
+// remoteName is a randomly generated key name.
+//
+// All generated names have a common prefix such that
+// test entries can be recognized at a shared key store.
+type remoteName string
+
+const remoteNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+func (remoteName) Generate(r *rand.Rand, size int) reflect.Value {
+	name := make([]byte, 1+r.Intn(32))
+	for i := range name {
+		name[i] = remoteNameChars[r.Intn(len(remoteNameChars))]
+	}
+	return reflect.ValueOf(remoteName("kes-test-" + string(name)))
+}
+
+// remoteValue is a randomly generated value. Values
+// are printable ASCII - like the JSON documents the
+// Store writes to the Remote store.
+type remoteValue string
+
+func (remoteValue) Generate(r *rand.Rand, size int) reflect.Value {
+	value := make([]byte, 1+r.Intn(4*size))
+	for i := range value {
+		value[i] = byte(' ' + r.Intn('~'-' '+1))
+	}
+	return reflect.ValueOf(remoteValue(value))
+}
+
+func awsTestRegion() string {
+	if region, ok := os.LookupEnv("KES_TEST_AWS_REGION"); ok {
+		return region
+	}
+	return "us-east-1"
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import "github.com/minio/kes"
+
+// Replace replaces the value of the Remote entry with the
+// given name - e.g. the entry that holds the policies.
+//
+// A Remote store cannot update an entry. Hence, Replace
+// first writes the value to a second entry under the
+// ReservedReplacePrefix. Then it deletes and re-creates
+// the entry and, finally, removes the second entry. So,
+// the Remote store contains either the previous or the
+// new value at any time - even if Replace fails or the
+// server stops in between. Entries written by Replace
+// must be read with Load.
+//
+// Replace must not be called concurrently for the same
+// entry.
+func Replace(remote Remote, name, value string) error {
+	return replaceEntry(remote, name, ReservedReplacePrefix+name, value)
+}
+
+// Load returns the value of the Remote entry with the
+// given name that has been written by Replace. If the
+// entry is missing because Replace has been interrupted,
+// Load returns the value Replace has been writing.
+//
+// It returns kes.ErrKeyNotFound if no such entry exists.
+func Load(remote Remote, name string) (string, error) {
+	return loadEntry(remote, name, ReservedReplacePrefix+name)
+}
+
+// DeleteReplaced deletes the Remote entry with the given
+// name and any value written by an interrupted Replace.
+func DeleteReplaced(remote Remote, name string) error {
+	if err := remote.Delete(ReservedReplacePrefix + name); err != nil {
+		return err
+	}
+	return remote.Delete(name)
+}
+
+// replaceEntry replaces the value of the entry with the
+// given name. It keeps the new value under next until
+// the entry has been replaced.
+func replaceEntry(remote Remote, name, next, value string) error {
+	err := remote.Create(next, value)
+	if err == kes.ErrKeyExists {
+		// A previous replace has been interrupted. The entry
+		// may be missing such that next holds the only value.
+		if err = restoreEntry(remote, name, next); err != nil {
+			return err
+		}
+		err = remote.Create(next, value)
+	}
+	if err != nil {
+		return err
+	}
+	if err = remote.Delete(name); err != nil {
+		return err
+	}
+	if err = remote.Create(name, value); err != nil {
+		return err
+	}
+	return remote.Delete(next)
+}
+
+// loadEntry returns the value of the entry with the
+// given name or, if the entry is missing, the value
+// kept under next by an interrupted replace.
+func loadEntry(remote Remote, name, next string) (string, error) {
+	value, err := remote.Get(name)
+	if err != kes.ErrKeyNotFound {
+		return value, err
+	}
+	if value, err = remote.Get(next); err == kes.ErrKeyNotFound {
+		// The replace may have completed in the meantime.
+		return remote.Get(name)
+	}
+	return value, err
+}
+
+// restoreEntry restores the entry with the given name
+// from the value kept under next by an interrupted
+// replace, if any. If the entry exists, it has either
+// its previous or its new value. Both are valid.
+func restoreEntry(remote Remote, name, next string) error {
+	value, err := remote.Get(next)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = remote.Create(name, value); err != nil && err != kes.ErrKeyExists {
+		return err
+	}
+	return remote.Delete(next)
+}
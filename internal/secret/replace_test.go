@@ -0,0 +1,45 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import "testing"
+
+var replaceTests = []struct {
+	Fault Fault // The fault that interrupts the first Replace
+}{
+	{Fault: Fault{Op: OpCreate, Key: ReservedReplacePrefix + ReservedName}},
+	{Fault: Fault{Op: OpDelete, Key: ReservedName}},
+	{Fault: Fault{Op: OpCreate, Key: ReservedName}},
+	{Fault: Fault{Op: OpDelete, Key: ReservedReplacePrefix + ReservedName}},
+}
+
+func TestReplace(t *testing.T) {
+	for i, test := range replaceTests {
+		remote := &FaultyRemote{Remote: remoteMap{ReservedName: "v1"}}
+		remote.Inject(test.Fault)
+
+		if err := Replace(remote, ReservedName, "v2"); err == nil {
+			t.Fatalf("Test %d: replace should have failed", i)
+		}
+		value, err := Load(remote, ReservedName)
+		if err != nil {
+			t.Fatalf("Test %d: entry has been lost: %v", i, err)
+		}
+		if value != "v1" && value != "v2" {
+			t.Fatalf("Test %d: invalid value: got %q", i, value)
+		}
+
+		remote.Reset()
+		if err = Replace(remote, ReservedName, "v3"); err != nil {
+			t.Fatalf("Test %d: failed to replace entry: %v", i, err)
+		}
+		if value, err = Load(remote, ReservedName); err != nil || value != "v3" {
+			t.Fatalf("Test %d: got %q, %v - want %q", i, value, err, "v3")
+		}
+		if _, ok := remote.Remote.(remoteMap)[ReservedReplacePrefix+ReservedName]; ok {
+			t.Fatalf("Test %d: replace has not been completed", i)
+		}
+	}
+}
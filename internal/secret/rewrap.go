@@ -0,0 +1,151 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/trace"
+)
+
+// ErrNoKMS is returned by Store.Rewrap if the Store
+// does not encrypt secrets with a KMS.
+var ErrNoKMS = kes.NewError(http.StatusNotImplemented, "key store is not encrypted by a KMS")
+
+// Rewrap encrypts the secret with the given name again with
+// the KMS and replaces its value at the Remote store - e.g.
+// once the KMS master key has been rotated such that the
+// secret is encrypted with the current master key version.
+// The secret itself, its type and its metadata do not change.
+// However, the Remote store may report a new creation time.
+//
+// The Remote store cannot replace a value atomically. Hence,
+// Rewrap first writes the new value under ReservedRewrapPrefix
+// and keeps it there until it has replaced the secret. While
+// the secret is missing - e.g. because Rewrap got interrupted
+// - the Store reads the value kept by Rewrap instead.
+//
+// Rewrap must not be called concurrently to any other
+// modification of the secret. It returns ErrNoKMS if the
+// Store has no KMS.
+func (s *Store) Rewrap(ctx context.Context, name string) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if s.KMS == nil {
+		return ErrNoKMS
+	}
+	value, err := s.load(name)
+	if err != nil {
+		return err
+	}
+	typ, err := ParseType(value)
+	if err != nil {
+		return errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+	context := typeContext(name, typ)
+	if typ == TypeKey {
+		ops, err := ParseOps(value)
+		if err != nil {
+			return errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+		}
+		context = kmsContext(name, ops)
+	}
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+
+	_, span := trace.StartSpan(ctx, "kms.decrypt")
+	plaintext, err := s.KMS.Decrypt(ciphertext, context)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return err
+	}
+	defer Wipe(plaintext)
+
+	_, span = trace.StartSpan(ctx, "kms.encrypt")
+	ciphertext, err = s.KMS.Encrypt(plaintext, context)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return err
+	}
+	if value, err = withCiphertext(value, ciphertext); err != nil {
+		return errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+
+	// A cached key can be used while its value gets
+	// replaced at the Remote store.
+	if typ == TypeKey && len(plaintext) == len(Secret{}) {
+		var secret Secret
+		copy(secret[:], plaintext)
+		s.cache.SetOrGet(name, secret)
+	}
+
+	_, span = trace.StartSpan(ctx, "store.rewrap")
+	err = replaceEntry(s.Remote, name, ReservedRewrapPrefix+name, value)
+	span.SetError(err)
+	span.Finish()
+	return err
+}
+
+// RecoverRewrap restores all secrets whose value has not
+// been replaced completely by an interrupted Rewrap.
+//
+// It returns ErrListNotSupported if the Remote store cannot
+// list its entries.
+func (s *Store) RecoverRewrap() error {
+	names, err := ListPrefix(s.Remote, ReservedRewrapPrefix)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = s.restore(strings.TrimPrefix(name, ReservedRewrapPrefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore restores the secret with the given name from
+// the value kept by an interrupted Rewrap, if any.
+func (s *Store) restore(name string) error {
+	return restoreEntry(s.Remote, name, ReservedRewrapPrefix+name)
+}
+
+// load returns the value of the secret with the given
+// name at the Remote store. If the secret is missing
+// because Rewrap is replacing it, load returns the
+// value kept by Rewrap.
+func (s *Store) load(name string) (string, error) {
+	return loadEntry(s.Remote, name, ReservedRewrapPrefix+name)
+}
+
+// withCiphertext replaces the KMS ciphertext of the
+// JSON-encoded value and keeps all other fields - like
+// the type or the operations of the secret.
+func withCiphertext(value string, ciphertext Ciphertext) (string, error) {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return "", errors.New("secret is malformed")
+	}
+	if _, ok := v["ciphertext"]; !ok {
+		return "", errors.New("secret is not encrypted by a KMS")
+	}
+	v["ciphertext"], _ = json.Marshal([]byte(ciphertext)) // Marshaling a []byte cannot fail
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
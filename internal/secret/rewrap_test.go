@@ -0,0 +1,150 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreRewrap(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = listRemoteMap{remoteMap{}}
+		store  = &Store{Remote: remote, KMS: rotatedKMS{old: xorKMS{0x5a}, new: xorKMS{0x42}}}
+	)
+	if err := (&Store{Remote: remote}).Rewrap(ctx, "my-key"); err != ErrNoKMS {
+		t.Fatalf("Rewrap without KMS: got %v - want %v", err, ErrNoKMS)
+	}
+
+	old := &Store{Remote: remote, KMS: xorKMS{0x5a}}
+	if err := old.Create(ctx, "my-key", Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := old.CreateImmutable(ctx, "my-ops-key", Secret{2}, []string{OpDecrypt}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := old.CreateOpaque(ctx, "my-secret", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	for _, name := range []string{"my-key", "my-ops-key", "my-secret"} {
+		if err := store.Rewrap(ctx, name); err != nil {
+			t.Fatalf("Failed to rewrap '%s': %v", name, err)
+		}
+		if _, ok := remote.remoteMap[ReservedRewrapPrefix+name]; ok {
+			t.Fatalf("Rewrap of '%s' has not been completed", name)
+		}
+	}
+
+	rotated := &Store{Remote: remote, KMS: xorKMS{0x42}}
+	if key, err := rotated.Get(ctx, "my-key"); err != nil || key != (Secret{1}) {
+		t.Fatalf("Key has been modified: got %x, %v - want %x", key, err, Secret{1})
+	}
+	if info, err := rotated.Stat("my-ops-key"); err != nil || !info.Immutable || !AllowsOp(info.AllowedOps, OpDecrypt) || AllowsOp(info.AllowedOps, OpEncrypt) {
+		t.Fatalf("Key metadata has been modified: got %+v, %v", info, err)
+	}
+	if value, err := rotated.GetOpaque(ctx, "my-secret"); err != nil || string(value) != "my-value" {
+		t.Fatalf("Secret has been modified: got %q, %v - want %q", value, err, "my-value")
+	}
+}
+
+func TestStoreRecoverRewrap(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = listRemoteMap{remoteMap{}}
+		store  = &Store{Remote: remote, KMS: xorKMS{0x5a}}
+	)
+	if err := store.Create(ctx, "my-key", Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Create(ctx, "other-key", Secret{2}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Rewrap has been interrupted after deleting my-key
+	// resp. after replacing other-key.
+	remote.remoteMap[ReservedRewrapPrefix+"my-key"] = remote.remoteMap["my-key"]
+	delete(remote.remoteMap, "my-key")
+	remote.remoteMap[ReservedRewrapPrefix+"other-key"] = remote.remoteMap["other-key"]
+
+	if err := store.RecoverRewrap(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	if len(remote.remoteMap) != 2 {
+		t.Fatalf("Invalid entries after recovery: got %v", remote.remoteMap)
+	}
+	for name, want := range map[string]Secret{"my-key": {1}, "other-key": {2}} {
+		store.Evict(name)
+		if key, err := store.Get(ctx, name); err != nil || key != want {
+			t.Fatalf("Key '%s' has not been restored: got %x, %v - want %x", name, key, err, want)
+		}
+	}
+}
+
+func TestStoreRewrapInterrupted(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		remote = remoteMap{}
+		faulty = &FaultyRemote{Remote: remote}
+	)
+	if err := (&Store{Remote: remote, KMS: xorKMS{0x5a}}).Create(ctx, "my-key", Secret{1}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Rewrap gets interrupted after deleting my-key.
+	faulty.Inject(Fault{Op: OpCreate, Key: "my-key"})
+	if err := (&Store{Remote: faulty, KMS: xorKMS{0x5a}}).Rewrap(ctx, "my-key"); err == nil {
+		t.Fatal("Rewrap should have failed")
+	}
+	if _, ok := remote["my-key"]; ok {
+		t.Fatal("Rewrap has not been interrupted")
+	}
+
+	store := &Store{Remote: remote, KMS: xorKMS{0x5a}}
+	if key, err := store.Get(ctx, "my-key"); err != nil || key != (Secret{1}) {
+		t.Fatalf("Key is not readable: got %x, %v - want %x", key, err, Secret{1})
+	}
+	if _, err := store.Stat("my-key"); err != nil {
+		t.Fatalf("Failed to stat key: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", Secret{2}); err != kes.ErrKeyExists {
+		t.Fatalf("Key has been replaced: got %v - want %v", err, kes.ErrKeyExists)
+	}
+	if err := store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if len(remote) != 0 {
+		t.Fatalf("Key has not been deleted: got %v", remote)
+	}
+}
+
+// listRemoteMap is a remoteMap that can list its entries.
+type listRemoteMap struct{ remoteMap }
+
+func (r listRemoteMap) List() ([]string, error) {
+	names := make([]string, 0, len(r.remoteMap))
+	for name := range r.remoteMap {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// rotatedKMS is a KMS whose master key has been rotated.
+// It encrypts with the new key and decrypts with either
+// key.
+type rotatedKMS struct{ old, new xorKMS }
+
+func (k rotatedKMS) Encrypt(plaintext []byte, context string) ([]byte, error) {
+	return k.new.Encrypt(plaintext, context)
+}
+
+func (k rotatedKMS) Decrypt(ciphertext []byte, context string) ([]byte, error) {
+	if plaintext, err := k.new.Decrypt(ciphertext, context); err == nil {
+		return plaintext, nil
+	}
+	return k.old.Decrypt(ciphertext, context)
+}
@@ -5,26 +5,26 @@
 package secret
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/minio/kes"
 	"github.com/secure-io/sio-go/sioutil"
-	"golang.org/x/crypto/chacha20"
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
 // Secret is a 256 bit cryptographic key.
 // It can be used to encrypt and decrypt
 // data encryption keys (DEK).
+//
+// A Secret is a value. Each copy should be
+// destroyed once it is no longer needed.
+// See: Destroy
 type Secret [32]byte
 
 func ParseSecret(s string) (Secret, error) {
@@ -42,9 +42,28 @@ func ParseSecret(s string) (Secret, error) {
 
 	var secret Secret
 	copy(secret[:], secretJSON.Bytes)
+	Wipe(secretJSON.Bytes)
 	return secret, nil
 }
 
+// Destroy overwrites the secret with zeros such that
+// the key material does not remain in memory. The
+// secret must not be used once it has been destroyed.
+//
+// Destroy only destroys this copy of the secret.
+func (s *Secret) Destroy() { Wipe(s[:]) }
+
+// Wipe overwrites b with zeros. It should be used to
+// remove plaintext key material - like a decrypted
+// secret or data key - from memory once it is no
+// longer needed.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
 func (s Secret) String() string {
 	return `{"bytes":"` + base64.StdEncoding.EncodeToString(s[:]) + `"}`
 }
@@ -60,12 +79,11 @@ func (s Secret) String() string {
 // Wrap derives keys using AES and encrypts plaintexts
 // using AES-GCM. Otherwise, Wrap derives keys using
 // HChaCha20 and encrypts plaintexts using ChaCha20-Poly1305.
+//
+// Wrap derives a new key for a random IV only once every
+// maxSealerUses plaintexts and uses random nonces for all
+// plaintexts encrypted with the same derived key.
 func (s Secret) Wrap(plaintext, associatedData []byte) ([]byte, error) {
-	iv, err := sioutil.Random(16)
-	if err != nil {
-		return nil, err
-	}
-
 	var algorithm string
 	if sioutil.NativeAES() {
 		algorithm = "AES-256-GCM-HMAC-SHA-256"
@@ -73,34 +91,9 @@ func (s Secret) Wrap(plaintext, associatedData []byte) ([]byte, error) {
 		algorithm = "ChaCha20Poly1305"
 	}
 
-	var aead cipher.AEAD
-	switch algorithm {
-	case "AES-256-GCM-HMAC-SHA-256":
-		mac := hmac.New(sha256.New, s[:])
-		mac.Write(iv)
-		sealingKey := mac.Sum(nil)
-
-		var block cipher.Block
-		block, err = aes.NewCipher(sealingKey)
-		if err != nil {
-			return nil, err
-		}
-		aead, err = cipher.NewGCM(block)
-		if err != nil {
-			return nil, err
-		}
-	case "ChaCha20Poly1305":
-		var sealingKey []byte
-		sealingKey, err = chacha20.HChaCha20(s[:], iv)
-		if err != nil {
-			return nil, err
-		}
-		aead, err = chacha20poly1305.New(sealingKey)
-		if err != nil {
-			return nil, err
-		}
-	default:
-		return nil, errors.New("invalid algorithm: " + algorithm)
+	iv, aead, err := sealers.Seal(&s, algorithm)
+	if err != nil {
+		return nil, err
 	}
 
 	nonce, err := sioutil.Random(aead.NonceSize())
@@ -127,6 +120,9 @@ func (s Secret) Wrap(plaintext, associatedData []byte) ([]byte, error) {
 // verifies the associated data and, if successful,
 // returns the resuting plaintext. It returns an
 // error if ciphertext is malformed or not authentic.
+//
+// Unwrap does not decrypt ciphertexts produced by
+// WrapHybrid. See: UnwrapHybrid
 func (s Secret) Unwrap(ciphertext []byte, associatedData []byte) ([]byte, error) {
 	// TODO(aead): The Go JSON unmarshaling is malleable.
 	// For instance, it ignores the first key-value pair if
@@ -143,36 +139,16 @@ func (s Secret) Unwrap(ciphertext []byte, associatedData []byte) ([]byte, error)
 	if err := json.Unmarshal(ciphertext, &sealedSecret); err != nil {
 		return nil, err
 	}
+	if sealedSecret.Algorithm == HybridAlgorithm {
+		return nil, ErrHybridDisabled
+	}
 	if n := len(sealedSecret.IV); n != 16 {
 		return nil, kes.NewError(http.StatusBadRequest, "invalid iv size "+strconv.Itoa(n))
 	}
 
-	var aead cipher.AEAD
-	switch sealedSecret.Algorithm {
-	case "AES-256-GCM-HMAC-SHA-256":
-		mac := hmac.New(sha256.New, s[:])
-		mac.Write(sealedSecret.IV)
-		sealingKey := mac.Sum(nil)
-
-		block, err := aes.NewCipher(sealingKey[:])
-		if err != nil {
-			return nil, err
-		}
-		aead, err = cipher.NewGCM(block)
-		if err != nil {
-			return nil, err
-		}
-	case "ChaCha20Poly1305":
-		sealingKey, err := chacha20.HChaCha20(s[:], sealedSecret.IV)
-		if err != nil {
-			return nil, err
-		}
-		aead, err = chacha20poly1305.New(sealingKey)
-		if err != nil {
-			return nil, err
-		}
-	default:
-		return nil, kes.NewError(http.StatusBadRequest, "invalid algorithm: "+sealedSecret.Algorithm)
+	aead, err := sealers.Open(&s, sealedSecret.Algorithm, sealedSecret.IV)
+	if err != nil {
+		return nil, err
 	}
 
 	if n := len(sealedSecret.Nonce); n != aead.NonceSize() {
@@ -180,7 +156,36 @@ func (s Secret) Unwrap(ciphertext []byte, associatedData []byte) ([]byte, error)
 	}
 	plaintext, err := aead.Open(nil, sealedSecret.Nonce, sealedSecret.Bytes, associatedData)
 	if err != nil {
-		return nil, kes.NewError(http.StatusBadRequest, "ciphertext is not authentic")
+		return nil, kes.ErrDecrypt
 	}
 	return plaintext, nil
 }
+
+// hmac returns HMAC-SHA-256(s, iv). The iv must not be
+// longer than 32 bytes.
+//
+// In contrast to the crypto/hmac package, hmac computes
+// the MAC on the stack. Hence, no copy of the secret
+// escapes to the heap, where it could not be wiped.
+func (s *Secret) hmac(iv []byte) [sha256.Size]byte {
+	const (
+		ipad = 0x36
+		opad = 0x5c
+	)
+	var buffer [sha256.BlockSize + sha256.Size]byte
+	defer Wipe(buffer[:])
+
+	copy(buffer[:], s[:])
+	for i := 0; i < sha256.BlockSize; i++ {
+		buffer[i] ^= ipad
+	}
+	n := copy(buffer[sha256.BlockSize:], iv)
+	inner := sha256.Sum256(buffer[:sha256.BlockSize+n])
+
+	for i := 0; i < sha256.BlockSize; i++ {
+		buffer[i] ^= ipad ^ opad
+	}
+	copy(buffer[sha256.BlockSize:], inner[:])
+	Wipe(inner[:])
+	return sha256.Sum256(buffer[:])
+}
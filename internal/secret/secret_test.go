@@ -6,6 +6,8 @@ package secret
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"testing"
@@ -143,6 +145,11 @@ var secretUnwrapTests = []struct {
 		AssociatedData: nil,
 		ShouldFail:     true, // invalid JSON
 	},
+	{ // 9
+		Ciphertext:     `{"aead":"X25519-MLKEM768-AES-256-GCM","kem":"DVsVEM+WpwNUbDuo38mXRNxDv1BxL+JfZ9uFEyRyDn9o1ARd1XyYMmlgZSou7pwTR1NH4aWR2MxJnUEmo0j92LIRcFi6a5iVf33jyOlPnqAeRu9AFEAw7zrZCSpUDpYSAzitIRZ2zL3K4gERwBtYmTlTOIGro/JVScSSm0Ryagm30HPYAyl5AY4sqNAen6wKc7B8J8Lw72cBYYzZOMi05ZlnI1cs06/LKvtWf3HSZ3xXRKSUrIYrkgNNyJVA1ZN4R0hUUf/AA39ZYOwMOhzmp3WQrCnvs8ItMWIDhXL0y4LOnibCQNtDotBPXuAicA09QuUxt/V7epAx2m1v26csmqwO06KHgMmLtN5pEO/B8ncCCe5+mgET8oCVX9o9HN9E81YyaDPBUFLbRKa9KBTzcMGXyv+2uTDQPyrihrt/lI6ykodbvrOd8OG15dLoT05K751v+B4gmOtPX6YEkz1JgWaME0SGf+z2q1TR2eCOA9XNY+X7N25OF2aTAySXay5aRzR1zqfzsHN8N1MC6fmczh+/2ifZK3cbeHaxzgx5qf6x8wzKAG72+1LHkN0G+c2UNt/8oooez7HLiKg/JZL69ZBJoA6UXWhEEhxLeuki1tcfVvpJPT1irxlQIRh4gS2a/+0smpFm9J04omTO1NdW3kyohHOS9bQvLnsinVAbpIFURUrCdA0OkQbIGyWFp3YhRhD5AmRMZHjcQtDgSPJ+W/7UdpSh07Br9PvJA2IiP66qvUEbRxpS4cn0uGhVrTh+pk59C1uUk2fx6mw9qQiIEj6J5HJ6BZ3Cn0l4hHgF0aU027hykVAhJoPQXajeirmWNwnTNlI++gERoaDWEWbkcI7zKceOhAZMW1AT0qE76PZU16yyWvKbksNOCUrs7y0+xE+/dK7VA4uf+v2QgQalQjvtXHHkKz3Krdo3vvoM+iVMjxjox1RAdiXDB3AzKXvjqLG7FcwcHYszl2qBttOpc0WzRoph3cD0Zdklh7P0vFED25V9djZptS4uk57rMtJoXUCvTTKeObKHTphJQRGpMDIzYqV9J4mwLo9nbMkzYbJnTc35sVjv5Z80KoC6+i/VViTmB0hNQeJS/Xq7DjFsPdmaStfj9K35gCWzpXOwTuVgmYskU9XvHYFWofGpVJCK+3/Si23xCsXy2z0SJ4ID4r3/DcEncJoMeTr1OYaAIg43Xis1rJEEdM8mN9DfSWoFrewHuPPleL0xqnplKwMjbu5i7h3nPW0FSHVXYEvmcJqH3Q7mo4/UAnl61CcA1oXrc2177zSrO84USAeYIFbVe4mwSN3e0yeMh801wcTzHH/7Sgk0qGmNDwxjE07ugyP/Fpp3D8FwCXl1sclCmANmVEIuLki5v+b4bYGPmSzsCNINaPq5A85X0riOtLpbcPimq7RRiz8jptv8KRVyLWsZz5ltE9diioemwVWDtmH5hWFqxjpva8UgHB0L+XMeS4/Rj/CoCwVILnTdVtCM5n5WEA==","nonce":"KXs9W9Vu3DELbXlq","bytes":"pCfKSbVBtGf8czsicegYpo0WUBqbbB97MwGyf0gDjmQ="}`,
+		AssociatedData: nil,
+		ShouldFail:     true, // hybrid ciphertext - requires the hybrid key
+	},
 }
 
 func TestSecrectUnwrap(t *testing.T) {
@@ -162,6 +169,20 @@ func TestSecrectUnwrap(t *testing.T) {
 	}
 }
 
+func TestSecretHMAC(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		var secret Secret
+		copy(secret[:], sioutil.MustRandom(len(secret)))
+		iv := sioutil.MustRandom(16)
+
+		mac := hmac.New(sha256.New, secret[:])
+		mac.Write(iv)
+		if sum := secret.hmac(iv); !bytes.Equal(sum[:], mac.Sum(nil)) {
+			t.Fatalf("Test %d: HMAC mismatch: got %x - want %x", i, sum, mac.Sum(nil))
+		}
+	}
+}
+
 func mustDecodeHex(s string) []byte {
 	b, err := hex.DecodeString(s)
 	if err != nil {
@@ -180,3 +201,26 @@ func mustDecodeSecret(s string) Secret {
 	copy(secret[:], b)
 	return secret
 }
+
+func TestSecretDestroy(t *testing.T) {
+	var secret Secret
+	for i := range secret {
+		secret[i] = byte(i + 1)
+	}
+	c := secret
+	secret.Destroy()
+	if secret != (Secret{}) {
+		t.Fatalf("Secret has not been destroyed: got %x", secret)
+	}
+	if c == (Secret{}) {
+		t.Fatal("Destroy must only destroy its own copy")
+	}
+
+	b := []byte("plaintext")
+	Wipe(b)
+	for i, v := range b {
+		if v != 0 {
+			t.Fatalf("Byte %d has not been wiped: got %x", i, v)
+		}
+	}
+}
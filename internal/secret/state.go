@@ -0,0 +1,218 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/watch"
+)
+
+// ReservedStateName is the name of the Remote entry
+// that holds the state of all secrets - e.g. whether
+// a secret is disabled, under legal hold or pending
+// deletion. The Store
+// refuses to create, fetch or delete a secret with this
+// name.
+const ReservedStateName = ".kes-key-states"
+
+// ErrDisabled is returned when a disabled secret is
+// fetched from the Store. See: Store.Disable
+var ErrDisabled = kes.NewError(http.StatusForbidden, "key is disabled")
+
+// ErrLegalHold is returned when a secret under legal hold
+// should be deleted or an alias that refers to it should
+// be changed. See: Store.Hold
+var ErrLegalHold = kes.NewError(http.StatusForbidden, "key is under legal hold")
+
+var (
+	errAlreadyHeld = kes.NewError(http.StatusConflict, "key is already under legal hold")
+	errNotHeld     = kes.NewError(http.StatusConflict, "key is not under legal hold")
+)
+
+// State is the state of a secret that can change
+// once the secret has been created.
+type State struct {
+	// Disabled reports whether the secret cannot
+	// be fetched from the Store.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// LegalHold is the legal hold on the secret,
+	// if any. See: Store.Hold
+	LegalHold *LegalHold `json:"legal_hold,omitempty"`
+
+	// PendingDeletion is the scheduled deletion of
+	// the secret, if any. See: Store.ScheduleDeletion
+	PendingDeletion *PendingDeletion `json:"pending_deletion,omitempty"`
+}
+
+// LegalHold describes why, since when and by whom
+// a secret is held.
+type LegalHold struct {
+	Reason   string       `json:"reason,omitempty"`
+	Identity kes.Identity `json:"identity,omitempty"`
+	Time     time.Time    `json:"time"`
+}
+
+// Disable disables the secret with the given name. A
+// disabled secret cannot be fetched from the Store -
+// e.g. to encrypt or decrypt data - until it gets
+// enabled again. However, it can still be described.
+func (s *Store) Disable(name string) error {
+	err := s.setState(name, func(state *State) error {
+		state.Disabled = true
+		return nil
+	})
+	if err == nil {
+		s.publish(watch.ActionDisabled, name, "")
+	}
+	return err
+}
+
+// Enable enables the secret with the given name such
+// that it can be fetched from the Store again.
+func (s *Store) Enable(name string) error {
+	err := s.setState(name, func(state *State) error {
+		state.Disabled = false
+		return nil
+	})
+	if err == nil {
+		s.publish(watch.ActionEnabled, name, "")
+	}
+	return err
+}
+
+// Hold places a legal hold on the secret with the given
+// name. A secret under legal hold cannot be deleted and
+// no alias that refers to it can be changed or deleted -
+// i.e. the secret cannot be rotated - until the hold is
+// released. If the hold has no time, Hold uses the current
+// time.
+//
+// Hold returns an error if the secret is already under
+// legal hold.
+func (s *Store) Hold(name string, hold LegalHold) error {
+	if hold.Time.IsZero() {
+		hold.Time = time.Now().UTC()
+	}
+	return s.setState(name, func(state *State) error {
+		if state.LegalHold != nil {
+			return errAlreadyHeld
+		}
+		state.LegalHold = &hold
+		return nil
+	})
+}
+
+// Release releases the legal hold on the secret with
+// the given name. It returns an error if the secret is
+// not under legal hold.
+func (s *Store) Release(name string) error {
+	return s.setState(name, func(state *State) error {
+		if state.LegalHold == nil {
+			return errNotHeld
+		}
+		state.LegalHold = nil
+		return nil
+	})
+}
+
+// State returns the state of the secret with
+// the given name.
+func (s *Store) State(name string) State {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+
+	return s.states[name]
+}
+
+// LoadStates reads the state of all secrets from the
+// Remote store and replaces the states kept in memory.
+// It should be called when the states have been changed
+// by another KES server - e.g. by another node of a
+// cluster.
+func (s *Store) LoadStates() error {
+	value, err := Load(s.Remote, ReservedStateName)
+	if err == kes.ErrKeyNotFound {
+		value, err = "{}", nil
+	}
+	if err != nil {
+		return err
+	}
+	var states map[string]State
+	if err = json.Unmarshal([]byte(value), &states); err != nil {
+		return errors.New("secret: persisted key states are malformed")
+	}
+
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	s.states = states
+	return nil
+}
+
+// setState applies f to the state of the secret with the
+// given name and writes the states of all secrets to the
+// Remote store. It returns kes.ErrKeyNotFound if no such
+// secret exists and the error of f, if any.
+func (s *Store) setState(name string, f func(*State) error) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if _, err := s.load(name); err != nil {
+		return err
+	}
+
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+
+	state := s.states[name]
+	if err := f(&state); err != nil {
+		return err
+	}
+	return s.saveStates(name, state)
+}
+
+// removeState removes the state of the secret with
+// the given name, if any. It is called when the secret
+// gets deleted.
+func (s *Store) removeState(name string) error {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+
+	if _, ok := s.states[name]; !ok {
+		return nil
+	}
+	return s.saveStates(name, State{})
+}
+
+// saveStates replaces the state of the secret with the
+// given name and writes the states of all secrets to the
+// Remote store. A zero State is removed. It replaces the
+// entry via Replace. The caller must hold the state lock.
+func (s *Store) saveStates(name string, state State) error {
+	states := make(map[string]State, len(s.states)+1)
+	for n, st := range s.states {
+		if n != name {
+			states[n] = st
+		}
+	}
+	if state != (State{}) {
+		states[name] = state
+	}
+
+	value, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	if err = Replace(s.Remote, ReservedStateName, string(value)); err != nil {
+		return err
+	}
+	s.states = states
+	return nil
+}
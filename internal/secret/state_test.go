@@ -0,0 +1,70 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStoreLegalHold(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	for _, name := range []string{"key-1", "key-2"} {
+		if err := store.Create(context.Background(), name, Secret{}); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := store.SetAlias("current", "key-1"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+
+	if err := store.Hold("key-1", LegalHold{Reason: "case 42", Identity: "af43c"}); err != nil {
+		t.Fatalf("Failed to place legal hold: %v", err)
+	}
+	if err := store.Hold("key-1", LegalHold{}); err != errAlreadyHeld {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errAlreadyHeld)
+	}
+	if err := store.Delete(context.Background(), "key-1"); err != ErrLegalHold {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrLegalHold)
+	}
+	if err := store.SetAlias("current", "key-2"); err != ErrLegalHold {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrLegalHold)
+	}
+	if err := store.DeleteAlias("current"); err != ErrLegalHold {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrLegalHold)
+	}
+	if _, err := store.Get(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Failed to get key under legal hold: %v", err)
+	}
+	info, err := store.Stat("key-1")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	if info.LegalHold == nil || info.LegalHold.Reason != "case 42" || info.LegalHold.Time.IsZero() {
+		t.Fatalf("Invalid legal hold: %+v", info.LegalHold)
+	}
+
+	loaded := &Store{Remote: remote}
+	if err := loaded.LoadStates(); err != nil {
+		t.Fatalf("Failed to load key states: %v", err)
+	}
+	if hold := loaded.State("key-1").LegalHold; hold == nil || hold.Identity != "af43c" {
+		t.Fatalf("Invalid loaded legal hold: %+v", hold)
+	}
+
+	if err := store.Release("key-1"); err != nil {
+		t.Fatalf("Failed to release legal hold: %v", err)
+	}
+	if err := store.Release("key-1"); err != errNotHeld {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, errNotHeld)
+	}
+	if err := store.SetAlias("current", "key-2"); err != nil {
+		t.Fatalf("Failed to update alias: %v", err)
+	}
+	if err := store.Delete(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Failed to delete released key: %v", err)
+	}
+}
@@ -6,8 +6,22 @@ package secret
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	"github.com/minio/kes/internal/trace"
+	"github.com/minio/kes/internal/watch"
 )
 
 // MaxSize is the max. size of a secret.
@@ -18,6 +32,83 @@ import (
 // store.
 const MaxSize = 1 << 20 // 1 MiB
 
+// ReservedName is the name of the Remote entry that
+// holds the policies and identity assignments managed
+// at runtime. The Store refuses to create, fetch or
+// delete a secret with this name.
+const ReservedName = ".kes-policies"
+
+// ReservedQuotaName is the name of the Remote entry
+// that holds the key quotas and the creators of keys.
+// The Store refuses to create, fetch or delete a
+// secret with this name.
+const ReservedQuotaName = ".kes-quotas"
+
+// ReservedEnclavesName is the name of the Remote entry
+// that holds the names and root identities of all
+// enclaves. The Store refuses to create, fetch or
+// delete a secret with this name.
+const ReservedEnclavesName = ".kes-enclaves"
+
+// ReservedJobsName is the name of the Remote entry
+// that holds the state of all asynchronous jobs. The
+// Store refuses to create, fetch or delete a secret
+// with this name.
+const ReservedJobsName = ".kes-jobs"
+
+// ReservedUsageName is the name of the Remote entry
+// that holds the usage of all secrets. The Store
+// refuses to create, fetch or delete a secret with
+// this name. See: Store.SaveUsage
+const ReservedUsageName = ".kes-usage"
+
+// ReservedAccountingName is the name of the Remote
+// entry that holds the number of requests of each
+// identity. The Store refuses to create, fetch or
+// delete a secret with this name.
+const ReservedAccountingName = ".kes-accounting"
+
+// ReservedSealName is the name of the Remote entry
+// that holds the configuration of the seal that
+// protects the root key. The Store refuses to create,
+// fetch or delete a secret with this name.
+const ReservedSealName = ".kes-seal"
+
+// ReservedEnclavePrefix is the prefix of all Remote
+// entries that belong to an enclave. The Store refuses
+// to create, fetch or delete a secret with this prefix.
+const ReservedEnclavePrefix = ".enclaves/"
+
+// ReservedProvenancePrefix is the prefix of all Remote
+// entries that hold the provenance of a secret - e.g.
+// ".kes-provenance/my-key". The Store refuses to create,
+// fetch or delete a secret with this prefix.
+// See: Store.AppendProvenance
+const ReservedProvenancePrefix = ".kes-provenance/"
+
+// ReservedApprovalPrefix is the prefix of all Remote
+// entries that mark an approval token as used - e.g.
+// ".kes-approval/<nonce>". The Store refuses to create,
+// fetch or delete a secret with this prefix.
+// See: auth.Approvals
+const ReservedApprovalPrefix = ".kes-approval/"
+
+// ReservedRewrapPrefix is the prefix of all Remote
+// entries that hold the new value of a secret while
+// Store.Rewrap replaces it - e.g. ".kes-rewrap/my-key".
+// The Store refuses to create, fetch or delete a secret
+// with this prefix.
+const ReservedRewrapPrefix = ".kes-rewrap/"
+
+// ReservedReplacePrefix is the prefix of all Remote
+// entries that hold the new value of an entry managed
+// by the server while Replace replaces it - e.g.
+// ".kes-replace/.kes-policies". The Store refuses to
+// create, fetch or delete a secret with this prefix.
+const ReservedReplacePrefix = ".kes-replace/"
+
+var errReservedName = kes.NewError(http.StatusBadRequest, "key name is reserved")
+
 // Remote is a key-value store for secrets
 // Therefore, it stores keys and values as
 // strings.
@@ -26,11 +117,17 @@ const MaxSize = 1 << 20 // 1 MiB
 // implemented by secret store backends,
 // like Vault or AWS SecretsManager.
 //
-// In general, values are not encrypted before
-// they are stored at the Remote store. Therefore,
-// an implementation must ensure that it:
+// The Store caches secrets and, if it has a KMS,
+// encrypts them before writing them to the Remote
+// store. Without a KMS, values are not encrypted
+// before they are stored at the Remote store.
+// Therefore, an implementation must ensure that it:
 // •  stores values securely - i.e. encrypt them.
 // •  protect any network communication - i.e. via TLS.
+//
+// A Remote store can be wrapped by another Remote
+// store - like a LoggingRemote - that adds behavior
+// to all of its operations.
 type Remote interface {
 	// Create creates a new entry under the given
 	// key and stores the given key-value pair
@@ -51,6 +148,123 @@ type Remote interface {
 	Get(key string) (string, error)
 }
 
+// Lister is implemented by Remote stores that can
+// list the names of their entries.
+type Lister interface {
+	// List returns the names of all entries.
+	List() ([]string, error)
+}
+
+// PrefixLister is implemented by Remote stores that
+// can list the entries under a name prefix without
+// listing all entries - e.g. because the prefix maps
+// to a directory or path of the key-value store.
+type PrefixLister interface {
+	// ListPrefix returns the names of all entries
+	// that start with the given prefix. The prefix
+	// is either empty or ends with a '/'.
+	ListPrefix(prefix string) ([]string, error)
+}
+
+// ErrListNotSupported is returned by Store.List if the
+// Remote store cannot list its entries.
+var ErrListNotSupported = kes.NewError(http.StatusNotImplemented, "key store does not support listing keys")
+
+// Stater is implemented by Remote stores that can
+// report when an entry has been created.
+type Stater interface {
+	// CreatedAt returns the point in time when the
+	// entry has been created. It returns
+	// kes.ErrKeyNotFound if no such entry exists.
+	CreatedAt(key string) (time.Time, error)
+}
+
+// ErrStatNotSupported is returned by a Stater that
+// wraps another Remote store if the wrapped Remote
+// store does not implement Stater itself.
+var ErrStatNotSupported = kes.NewError(http.StatusNotImplemented, "key store does not support key metadata")
+
+// Info describes a secret at the Store.
+type Info struct {
+	Name string
+
+	// Type is the type of the secret - either
+	// TypeKey, TypeOpaque, TypeCA or TypeJWS.
+	Type string
+
+	// CreatedAt is the point in time when the secret
+	// has been created. It is zero if the Remote store
+	// cannot report it.
+	CreatedAt time.Time
+
+	// LastUsed is the point in time when the secret
+	// has been fetched from the Store the last time.
+	// It is zero if the secret has not been used since
+	// the usage has been loaded. See: Store.LoadUsage
+	LastUsed time.Time
+
+	// Ops maps each operation to the number of times
+	// the secret has been used for it. See: Store.RecordUse
+	Ops map[string]uint64
+
+	// AllowedOps are the operations the secret is
+	// restricted to. If empty, the secret can be used
+	// for any operation. See: Store.CreateWithOps
+	AllowedOps []string
+
+	// Immutable reports whether the secret can never
+	// be deleted. See: Store.CreateImmutable
+	Immutable bool
+
+	// Disabled reports whether the secret has been
+	// disabled. See: Store.Disable
+	Disabled bool
+
+	// LegalHold is the legal hold on the secret,
+	// if any. See: Store.Hold
+	LegalHold *LegalHold
+
+	// PendingDeletion is the scheduled deletion of the
+	// secret, if any. See: Store.ScheduleDeletion
+	PendingDeletion *PendingDeletion
+
+	// Sealed reports whether the secret is encrypted
+	// with the KMS master key at the Remote store.
+	Sealed bool
+}
+
+// Version returns the version of the secret's metadata.
+// It changes whenever the metadata - e.g. the allowed
+// operations or the state of the secret - changes. It
+// does not change when the secret gets used.
+//
+// Clients can send the version as If-Match precondition
+// to modify the secret only if it has not been modified
+// in the meantime.
+func (i Info) Version() string {
+	metadata, _ := json.Marshal(struct {
+		Name            string
+		Type            string
+		CreatedAt       time.Time
+		AllowedOps      []string
+		Immutable       bool
+		Disabled        bool
+		LegalHold       *LegalHold
+		PendingDeletion *PendingDeletion
+	}{
+		Name:            i.Name,
+		Type:            i.Type,
+		CreatedAt:       i.CreatedAt,
+		AllowedOps:      i.AllowedOps,
+		Immutable:       i.Immutable,
+		Disabled:        i.Disabled,
+		LegalHold:       i.LegalHold,
+		PendingDeletion: i.PendingDeletion,
+	})
+	sum := sha256.Sum256(metadata)
+	return hex.EncodeToString(sum[:16])
+}
+
 // Store is the local secret store connected
 // to a remote key-value store.
 //
@@ -58,6 +272,12 @@ type Remote interface {
 // storing/fetching values to/from the the
 // Remote store.
 type Store struct {
+	// The cache statistics must be 64 bit aligned for
+	// atomic operations. Therefore, they are the first
+	// fields.
+	cacheHits   uint64
+	cacheMisses uint64
+
 	// Remote is the remote key-value store. Secrets
 	// will be fetched from or written to this store.
 	//
@@ -65,51 +285,422 @@ type Store struct {
 	// used to fetch or store secrets.
 	Remote Remote
 
-	cache cache
-	once  sync.Once // For the cache garbage collection
+	// KMS is an optional key management system. If
+	// set, the Store encrypts secrets with the KMS
+	// master key before writing them to the Remote
+	// store.
+	//
+	// It must not be modified once the Store has been
+	// used to fetch or store secrets.
+	KMS KMS
+
+	// Rand is an optional source of random bytes
+	// used to generate keys - e.g. an entropy.Source
+	// that fails once its health tests fail. If nil,
+	// crypto/rand.Reader is used.
+	Rand io.Reader
+
+	// HybridKey is an optional key pair used for the
+	// experimental post-quantum hybrid wrapping. If nil,
+	// hybrid wrapping is disabled. See: Secret.WrapHybrid
+	HybridKey *HybridKey
+
+	// Events is an optional hub to which the Store
+	// publishes an event whenever a key gets created,
+	// deleted, disabled, enabled or rotated.
+	Events *watch.Hub
+
+	cache  cache
+	gcLock sync.Mutex         // For the cache garbage collection
+	stopGC context.CancelFunc // Stops the running cache garbage collection
+
+	usageLock  sync.Mutex
+	usage      map[string]*Usage // Maps secret names to their usage
+	usageDirty bool              // Whether the usage has changed since the last SaveUsage
+	saveLock   sync.Mutex        // Orders concurrent calls of SaveUsage
+
+	ops       sync.Map // Maps secret names to the operations they are restricted to
+	immutable sync.Map // Maps secret names to whether they are immutable
+	types     sync.Map // Maps secret names to their type
+
+	provenanceLock sync.Mutex // Orders the read-modify-write of provenance entries
+
+	aliasLock sync.RWMutex
+	aliases   map[string]string // Maps aliases to secret names
+
+	stateLock sync.RWMutex
+	states    map[string]State // Maps secret names to their state, if not zero
+}
+
+// Random returns n random bytes read from the
+// Store's source of random bytes. See: Store.Rand
+func (s *Store) Random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.Reader(), b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reader returns the Store's source of random bytes.
+// Keys generated outside the Store - e.g. the wrapping
+// key of an escrow package - should be read from it as
+// well. See: Store.Rand
+func (s *Store) Reader() io.Reader {
+	if s.Rand != nil {
+		return s.Rand
+	}
+	return rand.Reader
 }
 
 // Create adds the given secret with the given name to
 // the secret store. If there is already a secret with
 // this name then it does not replacce the secret and
-// returns kes.ErrKeyExists.
-func (s *Store) Create(name string, secret Secret) (err error) {
-	if err = s.Remote.Create(name, secret.String()); err != nil {
+// returns kes.ErrKeyExists. The same applies if there is
+// an alias with this name.
+//
+// If the ctx contains a trace span, Create records the
+// KMS and Remote store operations as child spans.
+func (s *Store) Create(ctx context.Context, name string, secret Secret) (err error) {
+	return s.create(ctx, name, secret, nil, false)
+}
+
+func (s *Store) create(ctx context.Context, name string, secret Secret, ops []string, immutable bool) (err error) {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if s.isAlias(name) {
+		return kes.ErrKeyExists
+	}
+	value := secret.String()
+	if s.KMS != nil {
+		_, span := trace.StartSpan(ctx, "kms.encrypt")
+		ciphertext, err := s.KMS.Encrypt(secret[:], kmsContext(name, ops))
+		span.SetError(err)
+		span.Finish()
+		if err != nil {
+			return err
+		}
+		value = Ciphertext(ciphertext).String()
+	}
+	value = withImmutable(withOps(value, ops), immutable)
+
+	// An interrupted Rewrap may have left the secret
+	// missing. Then, it still exists and must not be
+	// replaced by a new secret.
+	if err = s.restore(name); err != nil {
 		return err
 	}
+	_, span := trace.StartSpan(ctx, "store.create")
+	err = s.Remote.Create(name, value)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return err
+	}
+	s.ops.Store(name, ops)
+	s.immutable.Store(name, immutable)
+	s.types.Store(name, TypeKey)
 	s.cache.SetOrGet(name, secret)
+	s.publish(watch.ActionCreated, name, "")
 	return nil
 }
 
 // Delete deletes the secret associated with the given
-// name, if one exists.
-func (s *Store) Delete(name string) error {
+// name, if one exists. It returns ErrImmutable if the
+// secret is immutable and ErrLegalHold if the secret is
+// under legal hold.
+//
+// If the ctx contains a trace span, Delete records the
+// Remote store operation as child span.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if isReserved(name) {
+		return errReservedName
+	}
+	if immutable, err := s.isImmutable(name); err != nil && err != kes.ErrKeyNotFound {
+		return err
+	} else if immutable {
+		return ErrImmutable
+	}
+	if s.State(name).LegalHold != nil {
+		return ErrLegalHold
+	}
+
 	// We can always remove a secret from the cache.
 	// If the delete operation on the remote store
 	// fails we will fetch it again on the next Get.
 	s.cache.Delete(name)
-	return s.Remote.Delete(name)
+	s.ops.Delete(name)
+	s.immutable.Delete(name)
+	s.types.Delete(name)
+	s.forget(name)
+
+	_, span := trace.StartSpan(ctx, "store.delete")
+	defer span.Finish()
+
+	// The value kept by an interrupted Rewrap is deleted
+	// first. Otherwise, the secret could be read again.
+	err := s.Remote.Delete(ReservedRewrapPrefix + name)
+	if err == nil {
+		err = s.Remote.Delete(name)
+	}
+	span.SetError(err)
+	if err != nil {
+		return err
+	}
+	s.publish(watch.ActionDeleted, name, "")
+	return s.removeState(name)
+}
+
+// publish publishes a key event with the given action
+// to the Store's event hub, if any.
+func (s *Store) publish(action, name, target string) {
+	s.Events.Publish(watch.Event{
+		Type:   watch.TypeKey,
+		Action: action,
+		Name:   name,
+		Target: target,
+	})
+}
+
+// Evict removes the secret associated with the given name
+// from the cache, if present, such that the next Get fetches
+// it from the Remote store. It does not modify the Remote
+// store.
+//
+// Evict should be called when a secret has been deleted from
+// the Remote store by a different Store - e.g. by another KES
+// server of a cluster.
+func (s *Store) Evict(name string) {
+	s.cache.Delete(name)
+	s.ops.Delete(name)
+	s.immutable.Delete(name)
+	s.types.Delete(name)
+	s.forget(name)
 }
 
+// EvictAll removes all secrets from the cache such that
+// subsequent Gets fetch them from the Remote store. It can
+// be used to free memory. It does not modify the Remote
+// store.
+func (s *Store) EvictAll() { s.cache.Clear() }
+
+// CacheSize returns the approximate number of bytes
+// used by the cached secrets.
+func (s *Store) CacheSize() int64 { return s.cache.Size() }
+
 // Get returns the secret associated with the given name,
 // if any. If no such secret exists it returns
-// kes.ErrKeyNotFound.
-func (s *Store) Get(name string) (Secret, error) {
-	if secret, ok := s.cache.Get(name); ok {
+// kes.ErrKeyNotFound. If the secret has been disabled,
+// it returns ErrDisabled. If the secret is an opaque
+// secret, it returns ErrNotKey.
+//
+// If the ctx contains a trace span, Get records the cache
+// lookup, the Remote store and the KMS operations as child
+// spans.
+//
+// Get records when a secret has been used. See: Stat
+func (s *Store) Get(ctx context.Context, name string) (Secret, error) {
+	if isReserved(name) {
+		return Secret{}, errReservedName
+	}
+	secret, err := s.get(ctx, name)
+	if err == nil {
+		s.touch(name)
+	}
+	return secret, err
+}
+
+func (s *Store) get(ctx context.Context, name string) (Secret, error) {
+	if state := s.State(name); state.Disabled {
+		return Secret{}, ErrDisabled
+	} else if state.PendingDeletion != nil {
+		return Secret{}, ErrPendingDeletion
+	}
+	_, span := trace.StartSpan(ctx, "cache.lookup")
+	secret, ok := s.cache.Get(name)
+	span.SetAttribute("cache.hit", strconv.FormatBool(ok))
+	span.Finish()
+	if ok {
+		atomic.AddUint64(&s.cacheHits, 1)
 		return secret, nil
 	}
+	atomic.AddUint64(&s.cacheMisses, 1)
 
-	value, err := s.Remote.Get(name)
+	_, span = trace.StartSpan(ctx, "store.get")
+	value, err := s.load(name)
+	span.SetError(err)
+	span.Finish()
 	if err != nil {
 		return Secret{}, err
 	}
-	secret, err := ParseSecret(value)
+	if typ, err := ParseType(value); err != nil {
+		return Secret{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	} else if typ != TypeKey {
+		return Secret{}, ErrNotKey
+	}
+	ops, err := ParseOps(value)
+	if err != nil {
+		return Secret{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+	if s.KMS == nil {
+		secret, err := ParseSecret(value)
+		if err != nil {
+			return Secret{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+		}
+		s.ops.Store(name, ops)
+		return s.cache.SetOrGet(name, secret), nil
+	}
+
+	ciphertext, err := ParseCiphertext(value)
+	if err != nil {
+		return Secret{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': %w", name, err)
+	}
+	_, span = trace.StartSpan(ctx, "kms.decrypt")
+	plaintext, err := s.KMS.Decrypt(ciphertext, kmsContext(name, ops))
+	span.SetError(err)
+	span.Finish()
 	if err != nil {
 		return Secret{}, err
 	}
+
+	defer Wipe(plaintext)
+	if len(plaintext) != len(secret) {
+		return Secret{}, errs.Errorf(kes.ErrCorrupted, "secret: failed to read '%s': secret is malformed", name)
+	}
+	copy(secret[:], plaintext)
+	s.ops.Store(name, ops)
 	return s.cache.SetOrGet(name, secret), nil
 }
 
+// List returns the names of all secrets at the Remote
+// store. Entries managed by the server itself, like
+// the persisted policies, are not included.
+//
+// It returns ErrListNotSupported if the Remote store
+// does not implement Lister.
+func (s *Store) List() ([]string, error) {
+	lister, ok := s.Remote.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	entries, err := lister.List()
+	if err != nil {
+		return nil, err
+	}
+	names := entries[:0]
+	for _, name := range entries {
+		if !isReserved(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ListPrefix returns the names of all secrets at the
+// Remote store that start with the given prefix - e.g.
+// "tenant/app/". The prefix must be empty or end with
+// a '/'. Entries managed by the server itself are not
+// included.
+//
+// It returns ErrListNotSupported if the Remote store
+// implements neither PrefixLister nor Lister.
+func (s *Store) ListPrefix(prefix string) ([]string, error) {
+	entries, err := ListPrefix(s.Remote, prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := entries[:0]
+	for _, name := range entries {
+		if !isReserved(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Stat returns a description of the secret with the
+// given name. If no such secret exists it returns
+// kes.ErrKeyNotFound.
+//
+// If the Remote store does not implement Stater, Stat
+// only checks that the secret exists and the returned
+// Info has no creation time. The same applies while
+// Rewrap replaces the secret.
+func (s *Store) Stat(name string) (Info, error) {
+	if isReserved(name) {
+		return Info{}, errReservedName
+	}
+
+	info := Info{
+		Name:   name,
+		Sealed: s.KMS != nil,
+	}
+	var err error = ErrStatNotSupported
+	if stater, ok := s.Remote.(Stater); ok {
+		info.CreatedAt, err = stater.CreatedAt(name)
+	}
+	if err == ErrStatNotSupported || err == kes.ErrKeyNotFound {
+		// The secret may be missing while Rewrap replaces it.
+		info.CreatedAt = time.Time{}
+		_, err = s.load(name)
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if info.AllowedOps, err = s.opsOf(name); err != nil {
+		return Info{}, err
+	}
+	if info.Immutable, err = s.isImmutable(name); err != nil {
+		return Info{}, err
+	}
+	if info.Type, err = s.typeOf(name); err != nil {
+		return Info{}, err
+	}
+	state := s.State(name)
+	info.Disabled, info.LegalHold, info.PendingDeletion = state.Disabled, state.LegalHold, state.PendingDeletion
+	if u, ok := s.Usage(name); ok {
+		info.LastUsed, info.Ops = u.LastUsed, u.Ops
+	}
+	return info, nil
+}
+
+// CacheStats returns the number of times Get has found
+// a secret in the cache (hits) and the number of times
+// Get had to fetch a secret from the Remote store (misses).
+func (s *Store) CacheStats() (hits, misses uint64) {
+	return atomic.LoadUint64(&s.cacheHits), atomic.LoadUint64(&s.cacheMisses)
+}
+
+// StatusChecker is implemented by Remote stores and
+// KMS implementations that can report whether they
+// are available.
+type StatusChecker interface {
+	// Status returns an error if the Remote store
+	// or KMS is not available - e.g. because it
+	// is not reachable or sealed.
+	Status() error
+}
+
+// Status reports whether the Remote store and the KMS
+// are available. The KMS error is nil if no KMS is used.
+//
+// If the Remote store does not implement StatusChecker,
+// Status tries to fetch an entry from the Remote store.
+// If the KMS does not implement StatusChecker, Status
+// assumes that it is available.
+func (s *Store) Status() (remote, kms error) {
+	if checker, ok := s.Remote.(StatusChecker); ok {
+		remote = checker.Status()
+	} else if _, err := s.Remote.Get(ReservedName); err != nil && err != kes.ErrKeyNotFound {
+		remote = err
+	}
+	if checker, ok := s.KMS.(StatusChecker); ok {
+		kms = checker.Status()
+	}
+	return remote, kms
+}
+
 // StartGC starts the cache garbage collection background process.
 // The GC will discard all cached secrets after expiry. Further,
 // it will discard all entries that havn't been used for unusedExpiry.
@@ -118,15 +709,31 @@ func (s *Store) Get(name string) (Secret, error) {
 // the unusedExpiry is 0 then the GC will not discard unused secrets.
 //
 // There is only one garbage collection background process. Calling
-// StartGC more than once has no effect.
+// StartGC again stops the running process and starts a new one with
+// the given expiry values - e.g. after a configuration change.
 func (s *Store) StartGC(ctx context.Context, expiry, unusedExpiry time.Duration) {
-	s.once.Do(func() {
-		s.cache.StartGC(ctx, expiry)
-
-		// Actually, we also don't run the unused GC if unusedExpiry/2 == 0,
-		// not if unusedExpiry == 0.
-		// However, that can only happen if unusedExpiry is 1ns - which is
-		// anyway an unreasonable value for the expiry.
-		s.cache.StartUnusedGC(ctx, unusedExpiry/2)
-	})
+	s.gcLock.Lock()
+	defer s.gcLock.Unlock()
+
+	if s.stopGC != nil {
+		s.stopGC()
+	}
+	ctx, s.stopGC = context.WithCancel(ctx)
+	s.cache.StartGC(ctx, expiry)
+
+	// Actually, we also don't run the unused GC if unusedExpiry/2 == 0,
+	// not if unusedExpiry == 0.
+	// However, that can only happen if unusedExpiry is 1ns - which is
+	// anyway an unreasonable value for the expiry.
+	s.cache.StartUnusedGC(ctx, unusedExpiry/2)
+}
+
+// isReserved reports whether name is reserved for
+// entries managed by the server itself.
+func isReserved(name string) bool {
+	switch name {
+	case ReservedName, ReservedQuotaName, ReservedEnclaveName, ReservedEnclavesName, ReservedJobsName, ReservedUsageName, ReservedAccountingName, ReservedSealName, ReservedAliasName, ReservedStateName, ReservedHybridName:
+		return true
+	}
+	return strings.HasPrefix(name, ReservedEnclavePrefix) || strings.HasPrefix(name, ReservedProvenancePrefix) || strings.HasPrefix(name, ReservedApprovalPrefix) || strings.HasPrefix(name, ReservedRewrapPrefix) || strings.HasPrefix(name, ReservedReplacePrefix)
 }
@@ -0,0 +1,181 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// Usage describes when and how often a secret
+// has been used.
+type Usage struct {
+	// LastUsed is the point in time when the
+	// secret has been used the last time.
+	LastUsed time.Time `json:"last_used"`
+
+	// Ops maps each operation - e.g. "generate" -
+	// to the number of requests that have used the
+	// secret for it.
+	Ops map[string]uint64 `json:"ops,omitempty"`
+}
+
+// RecordUse records that the secret with the given name
+// has been used for the given operation - e.g. "decrypt".
+//
+// The usage is kept in memory and written to the Remote
+// store by SaveUsage.
+func (s *Store) RecordUse(name, op string) {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+
+	u := s.usageOf(name)
+	u.LastUsed = time.Now().UTC()
+	if u.Ops == nil {
+		u.Ops = map[string]uint64{}
+	}
+	u.Ops[op]++
+	s.usageDirty = true
+}
+
+// Usage returns the usage of the secret with the given
+// name. It returns false if the secret has not been used
+// since the usage has been loaded from the Remote store.
+func (s *Store) Usage(name string) (Usage, bool) {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+
+	u, ok := s.usage[name]
+	if !ok {
+		return Usage{}, false
+	}
+	return u.clone(), true
+}
+
+// Usages returns the usage of all secrets that
+// have been used.
+func (s *Store) Usages() map[string]Usage {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+
+	usages := make(map[string]Usage, len(s.usage))
+	for name, u := range s.usage {
+		usages[name] = u.clone()
+	}
+	return usages
+}
+
+// SaveUsage writes the usage of all secrets to the Remote
+// store under ReservedUsageName. It does nothing if the
+// usage has not changed since the last SaveUsage. Hence,
+// it should be called periodically to persist the usage
+// in batches.
+//
+// SaveUsage replaces the entry via Replace. If it fails,
+// the Remote store keeps the previously saved usage.
+func (s *Store) SaveUsage() error {
+	// The save lock ensures that concurrent calls to SaveUsage
+	// write their snapshots in order. Otherwise, an older
+	// snapshot may overwrite a more recent one.
+	s.saveLock.Lock()
+	defer s.saveLock.Unlock()
+
+	s.usageLock.Lock()
+	if !s.usageDirty {
+		s.usageLock.Unlock()
+		return nil
+	}
+	value, err := json.Marshal(s.usage)
+	s.usageDirty = false
+	s.usageLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = Replace(s.Remote, ReservedUsageName, string(value)); err != nil {
+		s.markDirty()
+		return err
+	}
+	return nil
+}
+
+// LoadUsage reads the usage of all secrets from the Remote
+// store and replaces the usage kept in memory. It does
+// nothing if the Remote store does not contain any usage.
+func (s *Store) LoadUsage() error {
+	value, err := Load(s.Remote, ReservedUsageName)
+	if err == kes.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var usage map[string]*Usage
+	if err = json.Unmarshal([]byte(value), &usage); err != nil {
+		return errors.New("secret: persisted key usage is malformed")
+	}
+
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	s.usage = usage
+	s.usageDirty = false
+	return nil
+}
+
+// touch records that the secret with the given name
+// has been used without counting any operation.
+func (s *Store) touch(name string) {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+
+	s.usageOf(name).LastUsed = time.Now().UTC()
+	s.usageDirty = true
+}
+
+// forget removes the usage of the secret with the
+// given name.
+func (s *Store) forget(name string) {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+
+	if _, ok := s.usage[name]; ok {
+		delete(s.usage, name)
+		s.usageDirty = true
+	}
+}
+
+func (s *Store) markDirty() {
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	s.usageDirty = true
+}
+
+// usageOf returns the usage of the secret with the given
+// name. The caller must hold the usage lock.
+func (s *Store) usageOf(name string) *Usage {
+	if s.usage == nil {
+		s.usage = map[string]*Usage{}
+	}
+	u, ok := s.usage[name]
+	if !ok {
+		u = &Usage{}
+		s.usage[name] = u
+	}
+	return u
+}
+
+func (u *Usage) clone() Usage {
+	c := Usage{LastUsed: u.LastUsed}
+	if len(u.Ops) > 0 {
+		c.Ops = make(map[string]uint64, len(u.Ops))
+		for op, n := range u.Ops {
+			c.Ops[op] = n
+		}
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStoreUsage(t *testing.T) {
+	remote := remoteMap{}
+	store := &Store{Remote: remote}
+	if err := store.Create(context.Background(), "my-key", Secret{}); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, ok := store.Usage("my-key"); ok {
+		t.Fatal("Key has usage before it has been used")
+	}
+
+	store.RecordUse("my-key", "generate")
+	store.RecordUse("my-key", "generate")
+	store.RecordUse("my-key", "decrypt")
+	usage, ok := store.Usage("my-key")
+	if !ok || usage.LastUsed.IsZero() {
+		t.Fatalf("Key has no usage: %v", usage)
+	}
+	if usage.Ops["generate"] != 2 || usage.Ops["decrypt"] != 1 {
+		t.Fatalf("Invalid usage: got %v - want map[decrypt:1 generate:2]", usage.Ops)
+	}
+
+	if err := store.SaveUsage(); err != nil {
+		t.Fatalf("Failed to save usage: %v", err)
+	}
+	if _, ok := remote[ReservedUsageName]; !ok {
+		t.Fatal("Usage has not been saved")
+	}
+	delete(remote, ReservedUsageName)
+	if err := store.SaveUsage(); err != nil {
+		t.Fatalf("Failed to save usage: %v", err)
+	}
+	if _, ok := remote[ReservedUsageName]; ok {
+		t.Fatal("Usage has been saved although it has not changed")
+	}
+
+	store.RecordUse("my-key", "encrypt")
+	if err := store.SaveUsage(); err != nil {
+		t.Fatalf("Failed to save usage: %v", err)
+	}
+	loaded := &Store{Remote: remote}
+	if err := loaded.LoadUsage(); err != nil {
+		t.Fatalf("Failed to load usage: %v", err)
+	}
+	usage, ok = loaded.Usage("my-key")
+	if !ok || usage.Ops["generate"] != 2 || usage.Ops["decrypt"] != 1 || usage.Ops["encrypt"] != 1 {
+		t.Fatalf("Invalid loaded usage: got %v", usage)
+	}
+
+	if err := loaded.Delete(context.Background(), "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, ok := loaded.Usage("my-key"); ok {
+		t.Fatal("Deleted key has usage")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// OTLP is an Exporter that sends spans to an
+// OpenTelemetry collector using the OTLP/HTTP
+// protocol with JSON encoding.
+// See: https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/protocol/otlp.md
+type OTLP struct {
+	// Endpoint is the URL of the collector's trace
+	// endpoint - e.g. http://127.0.0.1:4318/v1/traces
+	Endpoint string
+
+	// ServiceName is the name of the traced service.
+	// If empty, it defaults to "kes".
+	ServiceName string
+
+	// Header contains additional HTTP headers sent to
+	// the collector - e.g. for authentication.
+	Header http.Header
+
+	// Client is the HTTP client used to send spans
+	// to the collector. If nil, http.DefaultClient
+	// is used.
+	Client *http.Client
+}
+
+var _ Exporter = (*OTLP)(nil)
+
+// Export sends the spans to the collector.
+func (o *OTLP) Export(spans []*Span) error {
+	type KeyValue struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+	type Status struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	type SpanJSON struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		Name         string     `json:"name"`
+		Kind         Kind       `json:"kind"`
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []KeyValue `json:"attributes,omitempty"`
+		Status       Status     `json:"status"`
+	}
+	type ScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []SpanJSON `json:"spans"`
+	}
+	type ResourceSpans struct {
+		Resource struct {
+			Attributes []KeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []ScopeSpans `json:"scopeSpans"`
+	}
+	type Request struct {
+		ResourceSpans []ResourceSpans `json:"resourceSpans"`
+	}
+	const (
+		StatusOK    = 1
+		StatusError = 2
+	)
+
+	keyValue := func(key, value string) KeyValue {
+		kv := KeyValue{Key: key}
+		kv.Value.StringValue = value
+		return kv
+	}
+
+	serviceName := o.ServiceName
+	if serviceName == "" {
+		serviceName = "kes"
+	}
+	var scope ScopeSpans
+	scope.Scope.Name = "github.com/minio/kes"
+	scope.Spans = make([]SpanJSON, 0, len(spans))
+	for _, span := range spans {
+		span.lock.Lock()
+		s := SpanJSON{
+			TraceID: hex.EncodeToString(span.TraceID[:]),
+			SpanID:  hex.EncodeToString(span.SpanID[:]),
+			Name:    span.Name,
+			Kind:    span.Kind,
+			Start:   strconv.FormatInt(span.Start.UnixNano(), 10),
+			End:     strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:  Status{Code: StatusOK},
+		}
+		if span.ParentID != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+		}
+		if span.Err != nil {
+			s.Status = Status{Code: StatusError, Message: span.Err.Error()}
+		}
+		keys := make([]string, 0, len(span.Attributes))
+		for key := range span.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s.Attributes = append(s.Attributes, keyValue(key, span.Attributes[key]))
+		}
+		span.lock.Unlock()
+
+		scope.Spans = append(scope.Spans, s)
+	}
+
+	var resource ResourceSpans
+	resource.Resource.Attributes = []KeyValue{keyValue("service.name", serviceName)}
+	resource.ScopeSpans = []ScopeSpans{scope}
+
+	body, err := json.Marshal(Request{ResourceSpans: []ResourceSpans{resource}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range o.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20)) // Drain the body such that the connection can be reused
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("trace: collector responded with: %s", resp.Status)
+	}
+	return nil
+}
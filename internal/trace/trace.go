@@ -0,0 +1,291 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package trace implements request tracing. A trace
+// consists of spans that describe the operations - e.g.
+// cache lookups or key store requests - performed while
+// handling a request.
+//
+// Spans are propagated via a context.Context and are
+// compatible with W3C trace context. Finished spans are
+// exported, for example, to an OpenTelemetry collector.
+// See: https://www.w3.org/TR/trace-context
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/secure-io/sio-go/sioutil"
+)
+
+// Kind describes the relationship between a span
+// and its caller.
+type Kind int
+
+// All span kinds as defined by OpenTelemetry.
+const (
+	KindInternal Kind = 1 // An internal operation
+	KindServer   Kind = 2 // A server handling a request
+	KindClient   Kind = 3 // A client sending a request
+)
+
+// Exporter exports finished spans.
+type Exporter interface {
+	// Export exports the given spans - e.g. by
+	// sending them to a trace collector.
+	Export(spans []*Span) error
+}
+
+// Tracer records spans and exports them in batches
+// via its Exporter.
+type Tracer struct {
+	// Exporter exports finished spans.
+	Exporter Exporter
+
+	// MaxQueueSize is the max. number of finished
+	// spans that are buffered before they are
+	// exported. If the queue is full, new spans
+	// are dropped. If 0, it defaults to 4096.
+	MaxQueueSize int
+
+	// ErrorLog specifies an optional logger for errors
+	// when spans cannot be exported.
+	// If nil, logging is done via the log package's
+	// standard logger.
+	ErrorLog *log.Logger
+
+	lock  sync.Mutex
+	queue []*Span
+	once  sync.Once // For the background export
+}
+
+// Start starts a new root span with the given name.
+// It is the first span of a new trace unless the
+// parent is a valid W3C traceparent header value.
+// In this case, the span continues the parent trace.
+//
+// The returned context contains the new span.
+func (t *Tracer) Start(ctx context.Context, name, parent string) (context.Context, *Span) {
+	span := &Span{
+		Name:   name,
+		Kind:   KindServer,
+		Start:  time.Now(),
+		tracer: t,
+	}
+	if traceID, parentID, ok := parseTraceParent(parent); ok {
+		span.TraceID, span.ParentID = traceID, parentID
+	} else {
+		copy(span.TraceID[:], sioutil.MustRandom(len(span.TraceID)))
+	}
+	copy(span.SpanID[:], sioutil.MustRandom(len(span.SpanID)))
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartExport spawns a new go-routine that exports
+// all finished spans repeatedly in the given interval
+// until the ctx is done.
+//
+// There is only one export background process. Calling
+// StartExport more than once has no effect.
+//
+// If interval == 0, StartExport does nothing.
+func (t *Tracer) StartExport(ctx context.Context, interval time.Duration) {
+	if interval == 0 {
+		return
+	}
+	t.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					t.Flush()
+					return
+				case <-ticker.C:
+					t.Flush()
+				}
+			}
+		}()
+	})
+}
+
+// Flush exports all finished spans.
+func (t *Tracer) Flush() error {
+	t.lock.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.lock.Unlock()
+
+	if len(spans) == 0 || t.Exporter == nil {
+		return nil
+	}
+	if err := t.Exporter.Export(spans); err != nil {
+		t.log("trace: failed to export spans:", err)
+		return err
+	}
+	return nil
+}
+
+func (t *Tracer) finish(span *Span) {
+	maxQueueSize := t.MaxQueueSize
+	if maxQueueSize == 0 {
+		maxQueueSize = 4096
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.queue) < maxQueueSize {
+		t.queue = append(t.queue, span)
+	}
+}
+
+func (t *Tracer) log(v ...interface{}) {
+	if t.ErrorLog == nil {
+		log.Println(v...)
+	} else {
+		t.ErrorLog.Println(v...)
+	}
+}
+
+// Span is a single operation within a trace.
+//
+// A nil *Span is a valid span that records nothing.
+// Therefore, code can be instrumented unconditionally
+// even when tracing is disabled.
+type Span struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte // All zero if the span has no parent
+
+	Name  string
+	Kind  Kind
+	Start time.Time
+	End   time.Time
+
+	Attributes map[string]string
+	Err        error // Non-nil if the operation failed
+
+	tracer *Tracer
+	lock   sync.Mutex
+	ended  bool
+}
+
+type spanKey struct{}
+
+// FromContext returns the span stored in ctx, if any.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a new span as child of the span
+// stored in ctx. If ctx does not contain a span,
+// StartSpan returns ctx and a nil span.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		TraceID:  parent.TraceID,
+		ParentID: parent.SpanID,
+		Name:     name,
+		Kind:     KindInternal,
+		Start:    time.Now(),
+		tracer:   parent.tracer,
+	}
+	copy(span.SpanID[:], sioutil.MustRandom(len(span.SpanID)))
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute sets the attribute key to value.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = map[string]string{}
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Err = err
+}
+
+// Finish ends the span and passes it to the Tracer
+// for export. Calling Finish more than once has no
+// effect.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.lock.Unlock()
+
+	if s.tracer != nil {
+		s.tracer.finish(s)
+	}
+}
+
+// TraceParent returns the span as W3C traceparent
+// header value.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-01"
+}
+
+// parseTraceParent parses s as W3C traceparent header
+// value of the form:
+//  <version>-<trace-id>-<parent-id>-<flags>
+func parseTraceParent(s string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return traceID, parentID, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return traceID, parentID, false
+	}
+	if len(parts[1]) != 2*len(traceID) || len(parts[2]) != 2*len(parentID) || len(parts[3]) != 2 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false
+	}
+	if traceID == [16]byte{} || parentID == [8]byte{} { // All-zero IDs are invalid
+		return traceID, parentID, false
+	}
+	return traceID, parentID, true
+}
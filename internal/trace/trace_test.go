@@ -0,0 +1,65 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"context"
+	"testing"
+)
+
+var parseTraceParentTests = []struct {
+	TraceParent string
+	OK          bool
+}{
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", OK: true},    // 0
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", OK: true},    // 1
+	{TraceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xy", OK: true}, // 2
+	{TraceParent: "", OK: false}, // 3
+	{TraceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", OK: false},    // 4
+	{TraceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", OK: false},    // 5
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", OK: false},    // 6
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", OK: false},     // 7
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xy", OK: false}, // 8
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", OK: false},    // 9
+}
+
+func TestParseTraceParent(t *testing.T) {
+	for i, test := range parseTraceParentTests {
+		if _, _, ok := parseTraceParent(test.TraceParent); ok != test.OK {
+			t.Fatalf("Test %d: got %v - want %v", i, ok, test.OK)
+		}
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	if _, span := StartSpan(context.Background(), "no-parent"); span != nil {
+		t.Fatal("StartSpan returned a span without a parent span")
+	}
+
+	var tracer Tracer
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx, root := tracer.Start(context.Background(), "root", traceParent)
+	if root.TraceParent()[:35] != traceParent[:35] {
+		t.Fatalf("Root span does not continue the parent trace: got %s - want %s", root.TraceParent(), traceParent)
+	}
+
+	_, child := StartSpan(ctx, "child")
+	if child == nil {
+		t.Fatal("StartSpan returned no child span")
+	}
+	if child.TraceID != root.TraceID {
+		t.Fatal("Child span belongs to a different trace")
+	}
+	if child.ParentID != root.SpanID {
+		t.Fatal("Child span parent ID does not match root span ID")
+	}
+
+	child.Finish()
+	child.Finish() // Finishing a span twice must have no effect
+	root.Finish()
+	if n := len(tracer.queue); n != 2 {
+		t.Fatalf("Tracer queue contains %d spans - want 2", n)
+	}
+}
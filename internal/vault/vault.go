@@ -14,16 +14,16 @@ package vault
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/errs"
+	xlog "github.com/minio/kes/internal/log"
 )
 
 // AppRole holds the Vault AppRole
@@ -60,6 +60,15 @@ type Store struct {
 	// case you may set KeyStore.Location = "key/my-app".
 	Location string
 
+	// Field is the name of the field of a K/V entry
+	// that holds the value. If empty, the name of
+	// the entry is used - e.g. the field "my-key" of
+	// the entry "<location>/my-key".
+	//
+	// It can be used to read entries written by other
+	// tools - e.g. when importing existing secrets.
+	Field string
+
 	// AppRole contains the Vault AppRole authentication
 	// credentials.
 	AppRole AppRole
@@ -71,12 +80,12 @@ type Store struct {
 	// has been sealed resp. unsealed again.
 	StatusPingAfter time.Duration
 
-	// ErrorLog specifies an optional logger for errors
+	// Log specifies an optional logger for errors
 	// when K/V pairs cannot be stored, fetched, deleted
 	// or contain invalid content.
 	// If nil, logging is done via the log package's
 	// standard logger.
-	ErrorLog *log.Logger
+	Log *xlog.Logger
 
 	// Path to the mTLS client private key to authenticate to
 	// the Vault server.
@@ -152,13 +161,29 @@ func (s *Store) Authenticate(context context.Context) error {
 	return nil
 }
 
-var errSealed = kes.NewError(http.StatusForbidden, "key store is sealed")
+var errSealed = errs.New(kes.ErrBackendUnavailable, "vault: key store is sealed")
+
+// Status returns an error if the Vault server is
+// not reachable or sealed.
+//
+// Status uses the most recently fetched Vault health
+// status. Therefore, it does not send any request to
+// the Vault server.
+func (s *Store) Status() error {
+	if s.client == nil {
+		return errNoConnection
+	}
+	if s.client.Sealed() {
+		return errSealed
+	}
+	return nil
+}
 
 // Get returns the value associated with the given key.
 // If no entry for the key exists it returns kes.ErrKeyNotFound.
 func (s *Store) Get(key string) (string, error) {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return "", errNoConnection
 	}
 	if s.client.Sealed() {
@@ -173,20 +198,20 @@ func (s *Store) Get(key string) (string, error) {
 		if err == nil && entry == nil {
 			return "", kes.ErrKeyNotFound
 		}
-		s.logf("vault: failed to read '%s': %v", location, err)
-		return "", err
+		s.Log.Error("failed to read entry", "location", location, "err", err)
+		return "", errs.Errorf(kes.ErrBackendFailure, "vault: failed to read '%s': %w", location, err)
 	}
 
 	// Verify that we got a well-formed response from Vault
-	v, ok := entry.Data[key]
+	v, ok := entry.Data[s.field(key)]
 	if !ok || v == nil {
-		s.logf("vault: failed to read '%s': entry exists but no secret key is present", location)
-		return "", errors.New("vault: K/V entry does not contain any value")
+		s.Log.Error("failed to read entry", "location", location, "err", "entry exists but no secret key is present")
+		return "", errs.New(kes.ErrCorrupted, "vault: K/V entry does not contain any value")
 	}
 	value, ok := v.(string)
 	if !ok {
-		s.logf("vault: failed to read '%s': invalid K/V format", location)
-		return "", errors.New("vault: invalid K/V entry format")
+		s.Log.Error("failed to read entry", "location", location, "err", "invalid K/V format")
+		return "", errs.New(kes.ErrCorrupted, "vault: invalid K/V entry format")
 	}
 	return value, nil
 }
@@ -196,7 +221,7 @@ func (s *Store) Get(key string) (string, error) {
 // it returns kes.ErrKeyExists.
 func (s *Store) Create(key, value string) error {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return errNoConnection
 	}
 	if s.client.Sealed() {
@@ -228,8 +253,8 @@ func (s *Store) Create(key, value string) error {
 	case err == nil && secret != nil:
 		return kes.ErrKeyExists
 	case err != nil:
-		s.logf("vault: failed to create '%s': %v", location, err)
-		return err
+		s.Log.Error("failed to create entry", "location", location, "err", err)
+		return errs.Errorf(kes.ErrBackendFailure, "vault: failed to create '%s': %w", location, err)
 	}
 
 	// Finally, we create the value since it seems that it
@@ -239,11 +264,11 @@ func (s *Store) Create(key, value string) error {
 	// that whoever has the permission to create keys does that in
 	// a non-racy way.
 	_, err := s.client.Logical().Write(location, map[string]interface{}{
-		key: value,
+		s.field(key): value,
 	})
 	if err != nil {
-		s.logf("vault: failed to create '%s': %v", location, err)
-		return err
+		s.Log.Error("failed to create entry", "location", location, "err", err)
+		return errs.Errorf(kes.ErrBackendFailure, "vault: failed to create '%s': %w", location, err)
 	}
 	return nil
 }
@@ -252,7 +277,7 @@ func (s *Store) Create(key, value string) error {
 // from Vault, if it exists.
 func (s *Store) Delete(key string) error {
 	if s.client == nil {
-		s.log(errNoConnection)
+		s.Log.Error("not connected", "err", errNoConnection)
 		return errNoConnection
 	}
 	if s.client.Sealed() {
@@ -266,9 +291,76 @@ func (s *Store) Delete(key string) error {
 	location := path.Join(s.Engine, s.Location, key) // /<engine>/<location>/<key>
 	_, err := s.client.Logical().Delete(location)
 	if err != nil {
-		s.logf("vault: failed to delete '%s': %v", location, err)
+		s.Log.Error("failed to delete entry", "location", location, "err", err)
+		return errs.Errorf(kes.ErrBackendFailure, "vault: failed to delete '%s': %w", location, err)
+	}
+	return nil
+}
+
+// List returns the names of all entries at the
+// Location of the K/V engine.
+func (s *Store) List() ([]string, error) { return s.ListPrefix("") }
+
+// ListPrefix returns the names of all entries that start
+// with the prefix - e.g. "my-tenant/". Hierarchical key
+// names are stored as Vault paths. Therefore, ListPrefix
+// only lists the path of the prefix and its sub-paths.
+func (s *Store) ListPrefix(prefix string) ([]string, error) {
+	if s.client == nil {
+		s.Log.Error("not connected", "err", errNoConnection)
+		return nil, errNoConnection
+	}
+	if s.client.Sealed() {
+		return nil, errSealed
+	}
+
+	var names []string
+	var list func(prefix string) error
+	list = func(prefix string) error {
+		location := path.Join(s.Engine, s.Location, prefix) // /<engine>/<location>/<prefix>
+		entry, err := s.client.Logical().List(location)
+		if err != nil {
+			s.Log.Error("failed to list entries", "location", location, "err", err)
+			return errs.Errorf(kes.ErrBackendFailure, "vault: failed to list '%s': %w", location, err)
+		}
+		if entry == nil { // Vault responds with 404 if there are no entries
+			return nil
+		}
+		keys, ok := entry.Data["keys"].([]interface{})
+		if !ok {
+			s.Log.Error("failed to list entries", "location", location, "err", "invalid K/V list format")
+			return errs.New(kes.ErrCorrupted, "vault: invalid K/V list format")
+		}
+		for _, key := range keys {
+			name, ok := key.(string)
+			if !ok {
+				s.Log.Error("failed to list entries", "location", location, "err", "invalid K/V list format")
+				return errs.New(kes.ErrCorrupted, "vault: invalid K/V list format")
+			}
+			if strings.HasSuffix(name, "/") { // A sub-path - e.g. "my-app/"
+				if err = list(prefix + name); err != nil {
+					return err
+				}
+				continue
+			}
+			names = append(names, prefix+name)
+		}
+		return nil
 	}
-	return err
+	if err := list(prefix); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// field returns the name of the field of the
+// K/V entry with the given name that holds its
+// value.
+func (s *Store) field(key string) string {
+	if s.Field != "" {
+		return s.Field
+	}
+	return key
 }
 
 // errNoConnection is the error returned and logged by
@@ -277,20 +369,5 @@ func (s *Store) Delete(key string) error {
 // This error is returned by Create, Get, Delete, a.s.o.
 // in case of an invalid configuration - i.e. when Authenticate()
 // hasn't been called.
-var errNoConnection = errors.New("vault: no connection to vault server")
+var errNoConnection = errs.New(kes.ErrBackendUnavailable, "vault: no connection to vault server")
 
-func (s *Store) log(v ...interface{}) {
-	if s.ErrorLog == nil {
-		log.Println(v...)
-	} else {
-		s.ErrorLog.Println(v...)
-	}
-}
-
-func (s *Store) logf(format string, v ...interface{}) {
-	if s.ErrorLog == nil {
-		log.Printf(format, v...)
-	} else {
-		s.ErrorLog.Printf(format, v...)
-	}
-}
@@ -0,0 +1,157 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package watch implements a publish-subscribe hub
+// for key and policy change events.
+package watch
+
+import (
+	"sync"
+	"time"
+)
+
+// The types of the objects an Event refers to.
+const (
+	TypeKey    = "key"
+	TypePolicy = "policy"
+)
+
+// The actions that an Event describes.
+const (
+	ActionCreated  = "created"
+	ActionDeleted  = "deleted"
+	ActionDisabled = "disabled"
+	ActionEnabled  = "enabled"
+	ActionRotated  = "rotated"
+	ActionWritten  = "written"
+)
+
+// Event describes a change of a key or policy.
+type Event struct {
+	Type   string    `json:"type"`   // TypeKey or TypePolicy
+	Action string    `json:"action"` // For example ActionCreated
+	Name   string    `json:"name"`
+	Target string    `json:"target,omitempty"` // The key an alias refers to after a rotation
+	Time   time.Time `json:"time"`
+}
+
+// Hub delivers published events to all subscribers.
+//
+// Publish never blocks. Instead, a subscriber that does
+// not keep up with the published events is closed such
+// that it can re-synchronize and subscribe again.
+//
+// The zero value is ready for use. A nil *Hub discards
+// all published events.
+type Hub struct {
+	lock        sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Publish sends the event to all subscribers. If the
+// event has no time, Publish uses the current time.
+func (h *Hub) Publish(event Event) {
+	if h == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for s := range h.subscribers {
+		if s.Type != "" && s.Type != event.Type {
+			continue
+		}
+		if s.Filter != nil && !s.Filter(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			s.lost = true
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribe returns a new subscription that receives
+// all events of the given type - or of any type if typ
+// is empty - for which filter returns true. A nil filter
+// accepts all events.
+//
+// The subscription buffers up to n events. If n < 1,
+// Subscribe uses a buffer of 64 events.
+//
+// If h is nil, Subscribe returns nil.
+func (h *Hub) Subscribe(typ string, n int, filter func(Event) bool) *Subscription {
+	if h == nil {
+		return nil
+	}
+	if n < 1 {
+		n = 64
+	}
+	s := &Subscription{
+		Type:   typ,
+		Filter: filter,
+		hub:    h,
+		events: make(chan Event, n),
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.subscribers == nil {
+		h.subscribers = map[*Subscription]struct{}{}
+	}
+	h.subscribers[s] = struct{}{}
+	return s
+}
+
+// Subscription is a subscription to the events
+// of a Hub. See: Hub.Subscribe
+type Subscription struct {
+	Type   string
+	Filter func(Event) bool
+
+	hub    *Hub
+	events chan Event
+	lost   bool
+}
+
+// Events returns a channel that receives the events.
+// The channel is closed once the subscription has been
+// closed.
+func (s *Subscription) Events() <-chan Event { return s.events }
+
+// Lost reports whether the subscription has been closed
+// because it could not keep up with the published events.
+// Events published after the last received one are lost.
+//
+// Lost must only be called once the Events channel has
+// been closed.
+func (s *Subscription) Lost() bool {
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+	return s.lost
+}
+
+// Close closes the subscription. No further events are
+// delivered once Close returns. Closing a nil
+// subscription is a no-op.
+func (s *Subscription) Close() {
+	if s == nil {
+		return
+	}
+	s.hub.lock.Lock()
+	defer s.hub.lock.Unlock()
+
+	if _, ok := s.hub.subscribers[s]; ok {
+		delete(s.hub.subscribers, s)
+		close(s.events)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package watch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	var hub Hub
+	keys := hub.Subscribe(TypeKey, 0, func(e Event) bool { return strings.HasPrefix(e.Name, "my-app/") })
+	defer keys.Close()
+	all := hub.Subscribe("", 0, nil)
+	defer all.Close()
+
+	hub.Publish(Event{Type: TypeKey, Action: ActionCreated, Name: "my-app/key-1"})
+	hub.Publish(Event{Type: TypeKey, Action: ActionCreated, Name: "other-key"})
+	hub.Publish(Event{Type: TypePolicy, Action: ActionWritten, Name: "my-app/policy"})
+
+	if n := len(keys.Events()); n != 1 {
+		t.Fatalf("Invalid number of key events: got %d - want %d", n, 1)
+	}
+	if e := <-keys.Events(); e.Name != "my-app/key-1" || e.Time.IsZero() {
+		t.Fatalf("Invalid event: got %+v", e)
+	}
+	if n := len(all.Events()); n != 3 {
+		t.Fatalf("Invalid number of events: got %d - want %d", n, 3)
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	var hub Hub
+	s := hub.Subscribe("", 2, nil)
+	for i := 0; i < 3; i++ {
+		hub.Publish(Event{Type: TypeKey, Action: ActionDeleted, Name: "my-key"})
+	}
+
+	var n int
+	for range s.Events() {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("Invalid number of received events: got %d - want %d", n, 2)
+	}
+	if !s.Lost() {
+		t.Fatal("Slow subscription has not been marked as lost")
+	}
+	s.Close() // Closing a lost subscription must not panic
+
+	var nilHub *Hub
+	nilHub.Publish(Event{Type: TypeKey, Action: ActionCreated, Name: "my-key"})
+}
@@ -8,6 +8,8 @@ import (
 	"bufio"
 	"encoding/json"
 	"io"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -119,6 +121,13 @@ func (s *ErrorStream) Close() (err error) {
 // by a newline.
 type ErrorEvent struct {
 	Message string `json:"message"` // The logged error message
+
+	// The following fields are only present if the
+	// server has logged a structured log record.
+	Time      time.Time         `json:"time,omitempty"`
+	Level     string            `json:"level,omitempty"`     // "debug", "info", "warn" or "error"
+	Component string            `json:"component,omitempty"` // The server component - e.g. "vault"
+	Fields    map[string]string `json:"fields,omitempty"`    // Additional key-value pairs
 }
 
 // NewAuditStream returns a new AuditStream that
@@ -249,7 +258,14 @@ type AuditEvent struct {
 // client and other audit-related information.
 type AuditEventRequest struct {
 	Path     string `json:"path"`
+	API      string `json:"api,omitempty"` // The API path without any arguments - e.g. /v1/key/create
+	Key      string `json:"key,omitempty"` // The key name, if the API operates on a key
 	Identity string `json:"identity"`
+	IP       string `json:"ip,omitempty"` // The IP address of the client
+
+	// Approvers are the identities that have approved
+	// the request, if it required approval.
+	Approvers []string `json:"approvers,omitempty"`
 }
 
 // AuditEventResponse contains the audit information
@@ -259,5 +275,46 @@ type AuditEventRequest struct {
 // and other audit-related information.
 type AuditEventResponse struct {
 	StatusCode int           `json:"code"`
+	Error      string        `json:"error,omitempty"` // The error code, if the request failed - e.g. too_many_requests
 	Time       time.Duration `json:"time"`
+
+	// Replay is true if the response has been replayed
+	// for a retried request with the same idempotency key.
+	// The request has not been processed again.
+	Replay bool `json:"replay,omitempty"`
+}
+
+// AuditFilter selects the audit events a client
+// receives when tracing the audit log. An empty
+// field matches any audit event.
+type AuditFilter struct {
+	// Identity selects audit events of requests
+	// sent by this identity.
+	Identity Identity
+
+	// API selects audit events of requests sent
+	// to this API - e.g. /v1/key/create.
+	API string
+
+	// Status selects audit events with this response
+	// status code - e.g. "404" - or status class -
+	// e.g. "4xx".
+	Status string
+}
+
+// Match reports whether the audit event matches the filter.
+func (f *AuditFilter) Match(event *AuditEvent) bool {
+	if f.Identity != "" && f.Identity.String() != event.Request.Identity {
+		return false
+	}
+	if f.API != "" && f.API != event.Request.API {
+		return false
+	}
+	if f.Status == "" {
+		return true
+	}
+	if len(f.Status) == 3 && strings.HasSuffix(f.Status, "xx") {
+		return strconv.Itoa(event.Response.StatusCode/100) == f.Status[:1]
+	}
+	return strconv.Itoa(event.Response.StatusCode) == f.Status
 }
@@ -0,0 +1,69 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import "testing"
+
+var auditFilterMatchTests = []struct {
+	Filter AuditFilter
+	Event  AuditEvent
+	Match  bool
+}{
+	{ // 0
+		Filter: AuditFilter{},
+		Event:  newAuditEvent("my-app", "/v1/key/create", 200),
+		Match:  true,
+	},
+	{ // 1
+		Filter: AuditFilter{Identity: "my-app"},
+		Event:  newAuditEvent("my-app", "/v1/key/create", 200),
+		Match:  true,
+	},
+	{ // 2
+		Filter: AuditFilter{Identity: "my-app"},
+		Event:  newAuditEvent("other-app", "/v1/key/create", 200),
+		Match:  false,
+	},
+	{ // 3
+		Filter: AuditFilter{API: "/v1/key/decrypt", Status: "4xx"},
+		Event:  newAuditEvent("my-app", "/v1/key/decrypt", 403),
+		Match:  true,
+	},
+	{ // 4
+		Filter: AuditFilter{API: "/v1/key/decrypt", Status: "4xx"},
+		Event:  newAuditEvent("my-app", "/v1/key/decrypt", 200),
+		Match:  false,
+	},
+	{ // 5
+		Filter: AuditFilter{Status: "404"},
+		Event:  newAuditEvent("my-app", "/v1/key/decrypt", 404),
+		Match:  true,
+	},
+	{ // 6
+		Filter: AuditFilter{Status: "404"},
+		Event:  newAuditEvent("my-app", "/v1/key/decrypt", 403),
+		Match:  false,
+	},
+}
+
+func TestAuditFilterMatch(t *testing.T) {
+	for i, test := range auditFilterMatchTests {
+		if match := test.Filter.Match(&test.Event); match != test.Match {
+			t.Fatalf("Test %d: got %v - want %v", i, match, test.Match)
+		}
+	}
+}
+
+func newAuditEvent(identity, api string, status int) AuditEvent {
+	return AuditEvent{
+		Request: AuditEventRequest{
+			API:      api,
+			Identity: identity,
+		},
+		Response: AuditEventResponse{
+			StatusCode: status,
+		},
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PolicyConditions restrict when, from where and with
+// which client certificate a policy allows requests.
+//
+// All conditions are evaluated for each request that
+// matches the policy. A request is only allowed if it
+// satisfies every condition. An empty condition is
+// always satisfied.
+type PolicyConditions struct {
+	// Time is a daily time window in UTC - e.g.
+	// "08:00-18:00". If the start is after the
+	// end, the window spans midnight - e.g.
+	// "22:00-02:00".
+	Time string `json:"time,omitempty"`
+
+	// After is the point in time before which
+	// no request is allowed.
+	After *time.Time `json:"after,omitempty"`
+
+	// Before is the point in time after which
+	// no request is allowed.
+	Before *time.Time `json:"before,omitempty"`
+
+	// IP is a list of CIDR ranges - e.g. 10.0.0.0/8.
+	// A request is only allowed if it has been sent
+	// from an IP address within one of the ranges.
+	//
+	// The IP address is the address of the peer that
+	// has established the connection. Therefore, when
+	// requests are sent through a TLS proxy, it is the
+	// address of the proxy.
+	IP []string `json:"ip,omitempty"`
+
+	// OU is a list of organizational units. A request
+	// is only allowed if the client certificate contains
+	// at least one of them.
+	OU []string `json:"ou,omitempty"`
+
+	// SAN is a list of subject alternative names - i.e.
+	// DNS names, email addresses, URIs or IP addresses.
+	// A request is only allowed if the client certificate
+	// contains at least one of them.
+	SAN []string `json:"san,omitempty"`
+}
+
+// parsedConditions are the PolicyConditions in a
+// representation that can be evaluated efficiently.
+type parsedConditions struct {
+	PolicyConditions
+
+	hasTime    bool
+	start, end time.Duration // Time window as offset from midnight
+	networks   []*net.IPNet
+}
+
+func parseConditions(c PolicyConditions) (*parsedConditions, error) {
+	parsed := &parsedConditions{PolicyConditions: c}
+	if c.Time != "" {
+		i := strings.IndexByte(c.Time, '-')
+		if i < 0 {
+			return nil, fmt.Errorf("kes: invalid policy time window '%s'", c.Time)
+		}
+		start, err := parseTimeOfDay(c.Time[:i])
+		if err != nil {
+			return nil, fmt.Errorf("kes: invalid policy time window '%s'", c.Time)
+		}
+		end, err := parseTimeOfDay(c.Time[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("kes: invalid policy time window '%s'", c.Time)
+		}
+		parsed.hasTime, parsed.start, parsed.end = true, start, end
+	}
+	if c.After != nil && c.Before != nil && !c.After.Before(*c.Before) {
+		return nil, errors.New("kes: invalid policy date range: 'after' is not before 'before'")
+	}
+	for _, cidr := range c.IP {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("kes: invalid policy IP range '%s'", cidr)
+		}
+		parsed.networks = append(parsed.networks, network)
+	}
+	return parsed, nil
+}
+
+// parseTimeOfDay parses s as "HH:MM" and returns
+// it as offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// verify returns ErrNotAllowed if the request r,
+// received at the given point in time, does not
+// satisfy all conditions.
+func (c *parsedConditions) verify(r *http.Request, now time.Time) error {
+	now = now.UTC()
+	if c.After != nil && now.Before(*c.After) {
+		return ErrNotAllowed
+	}
+	if c.Before != nil && now.After(*c.Before) {
+		return ErrNotAllowed
+	}
+	if c.hasTime {
+		offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+		if c.start <= c.end && (offset < c.start || offset > c.end) {
+			return ErrNotAllowed
+		}
+		if c.start > c.end && offset < c.start && offset > c.end { // The time window spans midnight
+			return ErrNotAllowed
+		}
+	}
+
+	if len(c.networks) > 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return ErrNotAllowed
+		}
+		var ok bool
+		for _, network := range c.networks {
+			if ok = network.Contains(ip); ok {
+				break
+			}
+		}
+		if !ok {
+			return ErrNotAllowed
+		}
+	}
+
+	if len(c.OU) > 0 || len(c.SAN) > 0 {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return ErrNotAllowed
+		}
+		cert := r.TLS.PeerCertificates[0]
+		if len(c.OU) > 0 && !containsAny(cert.Subject.OrganizationalUnit, c.OU) {
+			return ErrNotAllowed
+		}
+		if len(c.SAN) > 0 && !containsAny(subjectAltNames(cert), c.SAN) {
+			return ErrNotAllowed
+		}
+	}
+	return nil
+}
+
+// subjectAltNames returns all subject alternative
+// names of the certificate.
+func subjectAltNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs)+len(cert.IPAddresses))
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// containsAny reports whether values contains
+// at least one of the wanted values.
+func containsAny(values, wanted []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func mustParseTime(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+var policyConditionsTests = []struct {
+	Conditions  PolicyConditions
+	Time        string
+	RemoteAddr  string
+	Cert        *x509.Certificate
+	ShouldMatch bool
+}{
+	{Conditions: PolicyConditions{}, ShouldMatch: true},                                                                                                                      // 0
+	{Conditions: PolicyConditions{Time: "08:00-18:00"}, Time: "2020-06-01T12:00:00Z", ShouldMatch: true},                                                                     // 1
+	{Conditions: PolicyConditions{Time: "08:00-18:00"}, Time: "2020-06-01T19:00:00Z", ShouldMatch: false},                                                                    // 2
+	{Conditions: PolicyConditions{Time: "22:00-02:00"}, Time: "2020-06-01T23:30:00Z", ShouldMatch: true},                                                                     // 3
+	{Conditions: PolicyConditions{Time: "22:00-02:00"}, Time: "2020-06-01T01:59:00Z", ShouldMatch: true},                                                                     // 4
+	{Conditions: PolicyConditions{Time: "22:00-02:00"}, Time: "2020-06-01T12:00:00Z", ShouldMatch: false},                                                                    // 5
+	{Conditions: PolicyConditions{Time: "08:00-18:00"}, Time: "2020-06-01T12:00:00+07:00", ShouldMatch: false},                                                               // 6
+	{Conditions: PolicyConditions{After: mustParseTime("2020-01-01T00:00:00Z")}, Time: "2020-06-01T12:00:00Z", ShouldMatch: true},                                            // 7
+	{Conditions: PolicyConditions{Before: mustParseTime("2020-01-01T00:00:00Z")}, Time: "2020-06-01T12:00:00Z", ShouldMatch: false},                                          // 8
+	{Conditions: PolicyConditions{IP: []string{"10.0.0.0/8"}}, RemoteAddr: "10.1.2.3:5000", ShouldMatch: true},                                                               // 9
+	{Conditions: PolicyConditions{IP: []string{"10.0.0.0/8"}}, RemoteAddr: "192.168.1.1:5000", ShouldMatch: false},                                                           // 10
+	{Conditions: PolicyConditions{IP: []string{"10.0.0.0/8", "fd00::/8"}}, RemoteAddr: "[fd00::1]:5000", ShouldMatch: true},                                                  // 11
+	{Conditions: PolicyConditions{OU: []string{"Operations"}}, Cert: &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"Operations"}}}, ShouldMatch: true},   // 12
+	{Conditions: PolicyConditions{OU: []string{"Operations"}}, Cert: &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"Development"}}}, ShouldMatch: false}, // 13
+	{Conditions: PolicyConditions{OU: []string{"Operations"}}, ShouldMatch: false},                                                                                           // 14
+	{Conditions: PolicyConditions{SAN: []string{"app.example.com"}}, Cert: &x509.Certificate{DNSNames: []string{"app.example.com"}}, ShouldMatch: true},                      // 15
+	{Conditions: PolicyConditions{SAN: []string{"app.example.com"}}, Cert: &x509.Certificate{EmailAddresses: []string{"app@example.com"}}, ShouldMatch: false},               // 16
+}
+
+func TestPolicyConditions(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+
+	for i, test := range policyConditionsTests {
+		policy := mustNewPolicy("/v1/key/create/*")
+		if err := policy.SetConditions(test.Conditions); err != nil {
+			t.Fatalf("Test %d: failed to set conditions: %v", i, err)
+		}
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/v1/key/create/my-key", nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		req.RemoteAddr = test.RemoteAddr
+		req.TLS = &tls.ConnectionState{}
+		if test.Cert != nil {
+			req.TLS.PeerCertificates = []*x509.Certificate{test.Cert}
+		}
+
+		now := time.Now()
+		if test.Time != "" {
+			now = *mustParseTime(test.Time)
+		}
+		err = policy.verify(req, now)
+		if err != nil && test.ShouldMatch {
+			t.Fatalf("Test %d: request should have been allowed - but got: %v", i, err)
+		}
+		if err != ErrNotAllowed && !test.ShouldMatch {
+			t.Fatalf("Test %d: request should have been denied: got %v - want %v", i, err, ErrNotAllowed)
+		}
+	}
+}
+
+var parseConditionsTests = []struct {
+	Conditions PolicyConditions
+	ShouldFail bool
+}{
+	{Conditions: PolicyConditions{Time: "08:00-18:00"}},                                                                                           // 0
+	{Conditions: PolicyConditions{Time: "08:00"}, ShouldFail: true},                                                                               // 1
+	{Conditions: PolicyConditions{Time: "8-18"}, ShouldFail: true},                                                                                // 2
+	{Conditions: PolicyConditions{IP: []string{"10.0.0.1"}}, ShouldFail: true},                                                                    // 3
+	{Conditions: PolicyConditions{After: mustParseTime("2021-01-01T00:00:00Z"), Before: mustParseTime("2020-01-01T00:00:00Z")}, ShouldFail: true}, // 4
+}
+
+func TestParseConditions(t *testing.T) {
+	for i, test := range parseConditionsTests {
+		_, err := parseConditions(test.Conditions)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: parsing should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to parse conditions: %v", i, err)
+		}
+	}
+}
@@ -11,29 +11,116 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"time"
 )
 
+// Policy is a set of rules that determine whether
+// a request is allowed.
+//
+// A request is allowed if its URL path matches at
+// least one allow pattern and no deny pattern. Deny
+// patterns always take precedence over allow patterns.
+//
+// A pattern is matched segment-wise. Each segment may
+// contain wildcards as described by path.Match. In
+// addition, a "**" segment matches zero or more path
+// segments - e.g. "/v1/key/**" matches any key API.
+//
+// A policy can further restrict the keys an identity
+// may use. If key patterns are present, a request to
+// a key or data API - e.g. /v1/key/create/<name> - is
+// only allowed if the key name matches at least one key
+// pattern. Key names and patterns are matched segment-wise
+// as well - e.g. "my-app/**" matches "my-app/my-key".
+//
+// Finally, a policy may have conditions that each
+// allowed request has to satisfy. See PolicyConditions.
 type Policy struct {
-	patterns []string
+	patterns   []string
+	deny       []string
+	keys       []string
+	conditions *parsedConditions
 }
 
+// NewPolicy returns a new Policy that allows all
+// requests with a URL path matching at least one
+// of the given patterns.
 func NewPolicy(patterns ...string) (*Policy, error) {
-	for _, pattern := range patterns {
-		if _, err := path.Match(pattern, pattern); err != nil {
-			return nil, err
-		}
+	if err := validatePatterns(patterns); err != nil {
+		return nil, err
 	}
 	return &Policy{
 		patterns: patterns,
 	}, nil
 }
 
+// Deny adds the patterns to the deny patterns of the
+// policy. A request with a URL path matching any deny
+// pattern is rejected - even if it matches an allow
+// pattern.
+//
+// Deny must not be called once the policy is in use.
+func (p *Policy) Deny(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	p.deny = append(p.deny, patterns...)
+	return nil
+}
+
+// RestrictKeys restricts the keys the policy allows
+// to keys with a name matching at least one of the
+// given patterns - e.g. "my-app-*" or "my-app/**".
+//
+// RestrictKeys must not be called once the policy
+// is in use.
+func (p *Policy) RestrictKeys(patterns ...string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	p.keys = append(p.keys, patterns...)
+	return nil
+}
+
+// SetConditions sets the conditions every request allowed
+// by the policy has to satisfy. It returns an error if
+// the conditions are malformed.
+//
+// SetConditions must not be called once the policy
+// is in use.
+func (p *Policy) SetConditions(conditions PolicyConditions) error {
+	parsed, err := parseConditions(conditions)
+	if err != nil {
+		return err
+	}
+	p.conditions = parsed
+	return nil
+}
+
+// Conditions returns the conditions of the policy.
+func (p *Policy) Conditions() PolicyConditions {
+	if p.conditions == nil {
+		return PolicyConditions{}
+	}
+	return p.conditions.PolicyConditions
+}
+
 func (p Policy) MarshalJSON() ([]byte, error) {
 	type PolicyJSON struct {
-		Patterns []string `json:"paths"`
+		Patterns   []string          `json:"paths"`
+		Deny       []string          `json:"deny,omitempty"`
+		Keys       []string          `json:"keys,omitempty"`
+		Conditions *PolicyConditions `json:"conditions,omitempty"`
 	}
 
-	policy := PolicyJSON{Patterns: p.patterns}
+	policy := PolicyJSON{
+		Patterns: p.patterns,
+		Deny:     p.deny,
+		Keys:     p.keys,
+	}
+	if p.conditions != nil {
+		policy.Conditions = &p.conditions.PolicyConditions
+	}
 	if len(policy.Patterns) == 0 {
 		policy.Patterns = []string{} // marshal nil as empty array ([]) -  not null
 	}
@@ -45,37 +132,229 @@ func (p *Policy) UnmarshalJSON(b []byte) error {
 	d.DisallowUnknownFields()
 
 	var policyJSON struct {
-		Patterns []string `json:"paths"`
+		Patterns   []string          `json:"paths"`
+		Deny       []string          `json:"deny"`
+		Keys       []string          `json:"keys"`
+		Conditions *PolicyConditions `json:"conditions"`
 	}
 	if err := d.Decode(&policyJSON); err != nil {
 		return err
 	}
-	for _, pattern := range policyJSON.Patterns {
-		if _, err := path.Match(pattern, pattern); err != nil {
+	var conditions *parsedConditions
+	if policyJSON.Conditions != nil {
+		var err error
+		if conditions, err = parseConditions(*policyJSON.Conditions); err != nil {
 			return err
 		}
 	}
+	if err := validatePatterns(policyJSON.Patterns); err != nil {
+		return err
+	}
+	if err := validatePatterns(policyJSON.Deny); err != nil {
+		return err
+	}
+	if err := validatePatterns(policyJSON.Keys); err != nil {
+		return err
+	}
 	p.patterns = policyJSON.Patterns
+	p.deny = policyJSON.Deny
+	p.keys = policyJSON.Keys
+	p.conditions = conditions
 	return nil
 }
 
 func (p *Policy) String() string {
 	var builder strings.Builder
-	fmt.Fprintln(&builder, "[")
-	for _, pattern := range p.patterns {
-		if pattern != "" {
-			fmt.Fprintf(&builder, "  %s\n", pattern)
+	writeList := func(prefix string, patterns []string) {
+		fmt.Fprintln(&builder, prefix+"[")
+		for _, pattern := range patterns {
+			if pattern != "" {
+				fmt.Fprintf(&builder, "  %s\n", pattern)
+			}
 		}
+		fmt.Fprintln(&builder, "]")
+	}
+
+	writeList("", p.patterns)
+	if len(p.deny) > 0 {
+		writeList("deny ", p.deny)
+	}
+	if len(p.keys) > 0 {
+		writeList("keys ", p.keys)
+	}
+	if p.conditions != nil {
+		c := p.conditions
+		fmt.Fprintln(&builder, "conditions [")
+		if c.Time != "" {
+			fmt.Fprintf(&builder, "  time   %s\n", c.Time)
+		}
+		if c.After != nil {
+			fmt.Fprintf(&builder, "  after  %s\n", c.After.Format(time.RFC3339))
+		}
+		if c.Before != nil {
+			fmt.Fprintf(&builder, "  before %s\n", c.Before.Format(time.RFC3339))
+		}
+		if len(c.IP) > 0 {
+			fmt.Fprintf(&builder, "  ip     %s\n", strings.Join(c.IP, ", "))
+		}
+		if len(c.OU) > 0 {
+			fmt.Fprintf(&builder, "  ou     %s\n", strings.Join(c.OU, ", "))
+		}
+		if len(c.SAN) > 0 {
+			fmt.Fprintf(&builder, "  san    %s\n", strings.Join(c.SAN, ", "))
+		}
+		fmt.Fprintln(&builder, "]")
 	}
-	fmt.Fprintln(&builder, "]")
 	return builder.String()
 }
 
-func (p *Policy) Verify(r *http.Request) error {
+func (p *Policy) Verify(r *http.Request) error { return p.verify(r, time.Now()) }
+
+func (p *Policy) verify(r *http.Request, now time.Time) error {
+	if !p.AllowsPath(r.URL.Path) {
+		return ErrNotAllowed
+	}
+	if p.conditions != nil {
+		return p.conditions.verify(r, now)
+	}
+	return nil
+}
+
+// AllowsPath reports whether the policy allows requests
+// with the given URL path. In contrast to Verify, it
+// ignores the policy conditions since they depend on
+// the particular request.
+func (p *Policy) AllowsPath(apiPath string) bool {
+	allowed, _ := p.Explain(apiPath)
+	return allowed
+}
+
+// Explain reports whether the policy allows requests with
+// the given URL path - like AllowsPath - and the rule that
+// decides it. The rule is either the matching deny pattern
+// - e.g. "deny /v1/key/delete/*" -, the key patterns the
+// key name does not match - e.g. "keys my-app/*" - or the
+// matching allow pattern - e.g. "allow /v1/key/create/*".
+// If no pattern matches the URL path, the rule is empty.
+func (p *Policy) Explain(apiPath string) (allowed bool, rule string) {
+	for _, pattern := range p.deny {
+		if MatchPath(pattern, apiPath) {
+			return false, "deny " + pattern
+		}
+	}
+	if len(p.keys) > 0 && (strings.HasPrefix(apiPath, "/v1/key/") || strings.HasPrefix(apiPath, "/v1/data/") || strings.HasPrefix(apiPath, "/v1/secret/") || strings.HasPrefix(apiPath, "/v1/ca/") || strings.HasPrefix(apiPath, "/v1/jws/")) {
+		if !p.allowsKey(apiPath) {
+			return false, "keys " + strings.Join(p.keys, ", ")
+		}
+	}
 	for _, pattern := range p.patterns {
-		if ok, err := path.Match(pattern, r.URL.Path); ok && err == nil {
-			return nil
+		if MatchPath(pattern, apiPath) {
+			return true, "allow " + pattern
+		}
+	}
+	return false, ""
+}
+
+// allowsKey reports whether the key name of the key API
+// path - i.e. /v1/key/<operation>/<name>,
+// /v1/key/bulk/<operation>/<name>,
+// /v1/key/alias/<operation>/<name>,
+// /v1/key/hold/<operation>/<name>,
+// /v1/data/<operation>/<name>,
+// /v1/secret/<operation>/<name>,
+// /v1/ca/<operation>/<name> or
+// /v1/jws/<operation>/<name> - matches at least one
+// key pattern of the policy.
+func (p *Policy) allowsKey(apiPath string) bool {
+	n := 3 // The number of segments before the key name
+	if strings.HasPrefix(apiPath, "/v1/key/bulk/") || strings.HasPrefix(apiPath, "/v1/key/alias/") || strings.HasPrefix(apiPath, "/v1/key/hold/") {
+		n = 4
+	}
+	segments := strings.SplitN(strings.TrimPrefix(apiPath, "/v1/"), "/", n)
+	if len(segments) != n || segments[n-1] == "" {
+		return false
+	}
+	for _, pattern := range p.keys {
+		if MatchPath(pattern, segments[n-1]) {
+			return true
 		}
 	}
-	return ErrNotAllowed
+	return false
+}
+
+// validatePatterns returns path.ErrBadPattern if any
+// of the patterns is malformed.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, pattern); err != nil {
+			return err
+		}
+		if strings.Count(pattern, "/") >= MaxPatternSegments {
+			return path.ErrBadPattern
+		}
+	}
+	return nil
+}
+
+// MaxPatternSegments is the max. number of '/'-separated
+// segments of a pattern. Policies with longer patterns are
+// rejected and MatchPath reports that no path matches them.
+const MaxPatternSegments = 64
+
+// MatchPath reports whether the URL path or key name
+// matches the pattern. The pattern and the path are
+// matched segment by segment using path.Match. A "**"
+// pattern segment matches zero or more path segments.
+//
+// A Policy matches its patterns with MatchPath. Hence,
+// it should be used wherever a pattern has to behave
+// like a policy pattern - e.g. to filter key names.
+//
+// MatchPath takes at most O(n*m) segment comparisons for
+// a pattern with n and a path with m segments - even if
+// the pattern contains many "**" segments.
+func MatchPath(pattern, urlPath string) bool {
+	if strings.Count(pattern, "/") >= MaxPatternSegments {
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(urlPath, "/"))
+}
+
+// matchSegments matches the segments like a glob matcher
+// matches characters. On a mismatch, it only retries the
+// most recent "**" with one more segment. Retrying any
+// earlier "**" cannot produce a match that the most recent
+// one would not produce as well. Consecutive "**" segments
+// collapse into one.
+func matchSegments(pattern, segments []string) bool {
+	var (
+		p, s int
+		star = -1 // The index of the most recent "**" pattern segment
+		next int  // The segment the most recent "**" is retried at
+	)
+	for s < len(segments) {
+		switch {
+		case p < len(pattern) && pattern[p] == "**":
+			star, next = p, s
+			p++
+		case p < len(pattern) && matchSegment(pattern[p], segments[s]):
+			p, s = p+1, s+1
+		case star >= 0:
+			next++
+			p, s = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == "**" {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchSegment reports whether the path segment
+// matches the pattern segment using path.Match.
+func matchSegment(pattern, segment string) bool {
+	ok, err := path.Match(pattern, segment)
+	return ok && err == nil
 }
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"path"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 var newPolicyTests = []struct {
@@ -39,6 +41,10 @@ var newPolicyTests = []struct {
 		Patterns: []string{"/v1/key/generate/my-key-\\"},
 		Err:      path.ErrBadPattern,
 	},
+	{ // 6
+		Patterns: []string{"/v1/key/generate/" + strings.Repeat("a/", MaxPatternSegments)},
+		Err:      path.ErrBadPattern,
+	},
 }
 
 func TestNewPolicy(t *testing.T) {
@@ -66,6 +72,10 @@ var policyMarshalJSONTests = []struct {
 		Policy: mustNewPolicy("/v1/key/create/*", "/v1/key/delete/*", "/v1/key/generate/my-key"),
 		Output: `{"paths":["/v1/key/create/*","/v1/key/delete/*","/v1/key/generate/my-key"]}`,
 	},
+	{
+		Policy: &Policy{patterns: []string{"/v1/key/**"}, deny: []string{"/v1/key/delete/*"}, keys: []string{"my-app-*"}},
+		Output: `{"paths":["/v1/key/**"],"deny":["/v1/key/delete/*"],"keys":["my-app-*"]}`,
+	},
 }
 
 func TestPolicyMarshalJSON(t *testing.T) {
@@ -226,6 +236,197 @@ func TestPolicyVerify(t *testing.T) {
 	}
 }
 
+var policyVerifyDenyTests = []struct {
+	Allow       []string
+	Deny        []string
+	Keys        []string
+	Path        string
+	ShouldMatch bool
+}{
+	{Allow: []string{"/v1/key/**"}, Path: "/v1/key/create/my-key", ShouldMatch: true},                                                        // 0
+	{Allow: []string{"/v1/**/my-key"}, Path: "/v1/key/create/my-key", ShouldMatch: true},                                                     // 1
+	{Allow: []string{"/v1/**"}, Path: "/v1/identity/assign/af43c/my-policy", ShouldMatch: true},                                              // 2
+	{Allow: []string{"/v1/key/**"}, Deny: []string{"/v1/key/delete/*"}, Path: "/v1/key/create/my-key", ShouldMatch: true},                    // 3
+	{Allow: []string{"/v1/key/**"}, Deny: []string{"/v1/key/delete/*"}, Path: "/v1/key/delete/my-key", ShouldMatch: false},                   // 4
+	{Allow: []string{"/v1/key/delete/my-key"}, Deny: []string{"/v1/**"}, Path: "/v1/key/delete/my-key", ShouldMatch: false},                  // 5
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-a-*"}, Path: "/v1/key/create/app-a-key", ShouldMatch: true},                          // 6
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-a-*"}, Path: "/v1/key/create/app-b-key", ShouldMatch: false},                         // 7
+	{Allow: []string{"/v1/**"}, Keys: []string{"app-a-*"}, Path: "/v1/policy/read/app-b", ShouldMatch: true},                                 // 8
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-a-*", "app-b-*"}, Path: "/v1/key/decrypt/app-b-key", ShouldMatch: true},              // 9
+	{Allow: []string{"/v1/key/**/x"}, Path: "/v1/key/create/my-key", ShouldMatch: false},                                                     // 10
+	{Allow: []string{"/v1/key/*"}, Path: "/v1/key/create/my-key", ShouldMatch: false},                                                        // 11
+	{Allow: []string{"/v1/log/**"}, Deny: []string{"/v1/log/error/**"}, Path: "/v1/log/error/trace", ShouldMatch: false},                     // 12
+	{Allow: []string{"/v1/log/**"}, Deny: []string{"/v1/log/error/**"}, Keys: []string{"*"}, Path: "/v1/log/audit/trace", ShouldMatch: true}, // 13
+}
+
+func TestPolicyVerifyDeny(t *testing.T) {
+	const baseURL = "https://localhost:7373"
+
+	for i, test := range policyVerifyDenyTests {
+		policy, err := NewPolicy(test.Allow...)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create policy: %v", i, err)
+		}
+		if err = policy.Deny(test.Deny...); err != nil {
+			t.Fatalf("Test %d: failed to add deny patterns: %v", i, err)
+		}
+		if err = policy.RestrictKeys(test.Keys...); err != nil {
+			t.Fatalf("Test %d: failed to add key patterns: %v", i, err)
+		}
+		req, err := http.NewRequest(http.MethodGet, baseURL+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+
+		err = policy.Verify(req)
+		if err != nil && test.ShouldMatch {
+			t.Fatalf("Test %d: request should have been allowed - but got: %v", i, err)
+		}
+		if err != ErrNotAllowed && !test.ShouldMatch {
+			t.Fatalf("Test %d: request should have been denied: got %v - want %v", i, err, ErrNotAllowed)
+		}
+	}
+}
+
+var policyAllowsPathTests = []struct {
+	Allow       []string
+	Deny        []string
+	Keys        []string
+	Conditions  PolicyConditions
+	Path        string
+	ShouldMatch bool
+}{
+	{Allow: []string{"/v1/key/generate/*"}, Path: "/v1/key/generate/my-key", ShouldMatch: true},                                              // 0
+	{Allow: []string{"/v1/key/**"}, Deny: []string{"/v1/key/delete/*"}, Path: "/v1/key/decrypt/my-key", ShouldMatch: true},                   // 1
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-*"}, Path: "/v1/key/encrypt/app-key", ShouldMatch: true},                             // 2
+	{Allow: []string{"/v1/key/**"}, Conditions: PolicyConditions{IP: []string{"10.0.0.0/8"}}, Path: "/v1/key/create/key", ShouldMatch: true}, // 3
+	{Allow: []string{"/v1/data/**"}, Keys: []string{"app-*"}, Path: "/v1/data/encrypt/app-key", ShouldMatch: true},                           // 4
+
+	{Allow: []string{"/v1/key/generate/*"}, Path: "/v1/key/decrypt/my-key", ShouldMatch: false},                            // 5
+	{Allow: []string{"/v1/key/**"}, Deny: []string{"/v1/key/delete/*"}, Path: "/v1/key/delete/my-key", ShouldMatch: false}, // 6
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-*"}, Path: "/v1/key/encrypt/my-key", ShouldMatch: false},           // 7
+	{Allow: []string{"/v1/data/**"}, Keys: []string{"app-*"}, Path: "/v1/data/decrypt/my-key", ShouldMatch: false},         // 8
+
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/**"}, Path: "/v1/key/create/app/team/my-key", ShouldMatch: true}, // 9
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/*"}, Path: "/v1/key/create/app/my-key", ShouldMatch: true},       // 10
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/*"}, Path: "/v1/key/create/app/team/my-key", ShouldMatch: false}, // 11
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app-*"}, Path: "/v1/key/create/app-a/my-key", ShouldMatch: false},    // 12
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/**"}, Path: "/v1/key/bulk/delete/app/team", ShouldMatch: true},   // 13
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/**"}, Path: "/v1/key/bulk/delete/other", ShouldMatch: false},     // 14
+	{Allow: []string{"/v1/key/create/**"}, Path: "/v1/key/create/app/my-key", ShouldMatch: true},                         // 15
+	{Allow: []string{"/v1/key/create/*"}, Path: "/v1/key/create/app/my-key", ShouldMatch: false},                         // 16
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/*"}, Path: "/v1/key/alias/set/app/current", ShouldMatch: true},   // 17
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/*"}, Path: "/v1/key/alias/set/current", ShouldMatch: false},      // 18
+	{Allow: []string{"/v1/key/hold/set/*"}, Path: "/v1/key/hold/release/my-key", ShouldMatch: false},                     // 19
+	{Allow: []string{"/v1/key/**"}, Keys: []string{"app/*"}, Path: "/v1/key/hold/release/app/my-key", ShouldMatch: true}, // 20
+	{Allow: []string{"/v1/secret/**"}, Keys: []string{"app/*"}, Path: "/v1/secret/get/app/token", ShouldMatch: true},     // 21
+	{Allow: []string{"/v1/secret/**"}, Keys: []string{"app/*"}, Path: "/v1/secret/get/token", ShouldMatch: false},        // 22
+	{Allow: []string{"/v1/ca/sign/**"}, Keys: []string{"app/*"}, Path: "/v1/ca/sign/app/ca", ShouldMatch: true},          // 23
+	{Allow: []string{"/v1/ca/**"}, Keys: []string{"app/*"}, Path: "/v1/ca/chain/ca", ShouldMatch: false},                 // 24
+	{Allow: []string{"/v1/jws/**"}, Keys: []string{"app/*"}, Path: "/v1/jws/sign/app/key", ShouldMatch: true},            // 25
+	{Allow: []string{"/v1/jws/**"}, Keys: []string{"app/*"}, Path: "/v1/jws/jwks/key", ShouldMatch: false},               // 26
+}
+
+func TestPolicyAllowsPath(t *testing.T) {
+	for i, test := range policyAllowsPathTests {
+		policy, err := NewPolicy(test.Allow...)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create policy: %v", i, err)
+		}
+		if err = policy.Deny(test.Deny...); err != nil {
+			t.Fatalf("Test %d: failed to add deny patterns: %v", i, err)
+		}
+		if err = policy.RestrictKeys(test.Keys...); err != nil {
+			t.Fatalf("Test %d: failed to add key patterns: %v", i, err)
+		}
+		if err = policy.SetConditions(test.Conditions); err != nil {
+			t.Fatalf("Test %d: failed to set conditions: %v", i, err)
+		}
+		if allowed := policy.AllowsPath(test.Path); allowed != test.ShouldMatch {
+			t.Fatalf("Test %d: got %v - want %v", i, allowed, test.ShouldMatch)
+		}
+	}
+}
+
+var policyExplainTests = []struct {
+	Allow []string
+	Deny  []string
+	Keys  []string
+	Path  string
+
+	Allowed bool
+	Rule    string
+}{
+	{Allow: []string{"/v1/key/create/*"}, Path: "/v1/key/create/my-key", Allowed: true, Rule: "allow /v1/key/create/*"},                                // 0
+	{Allow: []string{"/v1/key/*/*"}, Deny: []string{"/v1/key/delete/*"}, Path: "/v1/key/delete/my-key", Allowed: false, Rule: "deny /v1/key/delete/*"}, // 1
+	{Allow: []string{"/v1/key/*/*"}, Keys: []string{"app/*", "ops/*"}, Path: "/v1/key/create/my-key", Allowed: false, Rule: "keys app/*, ops/*"},       // 2
+	{Allow: []string{"/v1/key/create/*"}, Path: "/v1/key/delete/my-key", Allowed: false, Rule: ""},                                                     // 3
+}
+
+func TestPolicyExplain(t *testing.T) {
+	for i, test := range policyExplainTests {
+		policy, err := NewPolicy(test.Allow...)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create policy: %v", i, err)
+		}
+		if err = policy.Deny(test.Deny...); err != nil {
+			t.Fatalf("Test %d: failed to add deny patterns: %v", i, err)
+		}
+		if err = policy.RestrictKeys(test.Keys...); err != nil {
+			t.Fatalf("Test %d: failed to add key patterns: %v", i, err)
+		}
+		allowed, rule := policy.Explain(test.Path)
+		if allowed != test.Allowed || rule != test.Rule {
+			t.Fatalf("Test %d: got '%v %s' - want '%v %s'", i, allowed, rule, test.Allowed, test.Rule)
+		}
+	}
+}
+
+var matchPathTests = []struct {
+	Pattern string
+	Path    string
+	Match   bool
+}{
+	{Pattern: "my-app/*", Path: "my-app/my-key", Match: true},                    // 0
+	{Pattern: "my-app/*", Path: "my-app/sub/my-key", Match: false},               // 1
+	{Pattern: "my-app/**", Path: "my-app/sub/my-key", Match: true},               // 2
+	{Pattern: "my-app/**", Path: "my-app", Match: true},                          // 3
+	{Pattern: "**/my-key", Path: "a/b/c/my-key", Match: true},                    // 4
+	{Pattern: "**/my-key", Path: "a/b/c/other-key", Match: false},                // 5
+	{Pattern: "a/**/b/**/c", Path: "a/x/b/y/b/z/c", Match: true},                 // 6
+	{Pattern: "a/**/b/**/c", Path: "a/x/b/y/c/z", Match: false},                  // 7
+	{Pattern: "a/**/**/c", Path: "a/c", Match: true},                             // 8
+	{Pattern: "**", Path: "", Match: true},                                       // 9
+	{Pattern: "my-key-[a-]", Path: "my-key-a", Match: false},                     // 10
+	{Pattern: "/v1/key/*/**", Path: "/v1/key/create/my-app/my-key", Match: true}, // 11
+}
+
+func TestMatchPath(t *testing.T) {
+	for i, test := range matchPathTests {
+		if match := MatchPath(test.Pattern, test.Path); match != test.Match {
+			t.Fatalf("Test %d: got %v - want %v", i, match, test.Match)
+		}
+	}
+}
+
+func TestMatchPathManyWildcards(t *testing.T) {
+	// A backtracking matcher would take exponential time.
+	var (
+		pattern = strings.Repeat("**/a/", 30) + "b"
+		name    = strings.Repeat("a/", 60) + "c"
+	)
+	start := time.Now()
+	if MatchPath(pattern, name) {
+		t.Fatal("Pattern should not match")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Matching took too long: %v", d)
+	}
+	if MatchPath(strings.Repeat("*/", MaxPatternSegments)+"*", strings.Repeat("a/", MaxPatternSegments)+"a") {
+		t.Fatal("Pattern with too many segments should not match")
+	}
+}
+
 func mustNewPolicy(patterns ...string) *Policy {
 	p, err := NewPolicy(patterns...)
 	if err != nil {
@@ -5,6 +5,9 @@
 package kes
 
 import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +16,8 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -46,15 +51,97 @@ func retryBody(body io.ReadSeeker) io.ReadCloser {
 	}
 }
 
-// retry is an http.Client that implements
-// a retry mechanism for requests that fail
-// due to a temporary network error.
+// RetryPolicy controls how a Client retries requests that
+// failed due to a temporary network error or a temporary
+// server error - like 503 Service Unavailable or 429 Too
+// Many Requests.
+//
+// Requests that have been rejected by the server without
+// being processed - i.e. 429 and 503 responses - are always
+// retried. Requests that failed for other reasons, like a
+// dropped connection or a 502 Bad Gateway response, are only
+// retried if they are idempotent. For example, a request to
+// create a key can only be retried since it carries an
+// idempotency key. The server recognizes the retry and
+// replays its first response instead of failing with
+// ErrKeyExists. See: HeaderIdempotencyKey
+//
+// Between two attempts, the client waits for an exponentially
+// increasing and randomized delay. If the server responds with
+// a Retry-After header, the client waits as long as requested
+// but not longer than MaxDelay.
+type RetryPolicy struct {
+	// MaxRetries is the max. number of times a request
+	// is retried. If 0, a request is retried up to 3
+	// times. If negative, requests are not retried.
+	MaxRetries int
+
+	// MinDelay is the delay before the first retry. It
+	// doubles with each further retry. If 0, it defaults
+	// to 200ms.
+	MinDelay time.Duration
+
+	// MaxDelay is the max. delay between two attempts.
+	// If 0, it defaults to 5s.
+	MaxDelay time.Duration
+}
+
+// delay returns the delay before the n-th retry,
+// starting at 0, given the response of the previous
+// attempt, if any.
+func (p RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	const (
+		DefaultMinDelay = 200 * time.Millisecond
+		DefaultMaxDelay = 5 * time.Second
+	)
+	minDelay, maxDelay := p.MinDelay, p.MaxDelay
+	if minDelay <= 0 {
+		minDelay = DefaultMinDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if delay := time.Duration(seconds) * time.Second; delay < maxDelay {
+				return delay
+			}
+			return maxDelay
+		}
+	}
+
+	delay := maxDelay
+	if n < 32 && minDelay<<uint(n) > 0 && minDelay<<uint(n) < maxDelay {
+		delay = minDelay << uint(n)
+	}
+	// Randomize the delay to avoid that many clients
+	// retry their requests at the same time.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retry is an http.Client that implements a retry
+// mechanism for requests that fail due to a temporary
+// error. See: RetryPolicy
 //
 // It provides a similar interface as the http.Client
 // but requires that the request body implements io.Seeker.
 // Otherwise, it cannot guarantee that the entire request
 // body gets sent when retrying a request.
-type retry http.Client
+type retry struct {
+	Client http.Client
+	Policy RetryPolicy
+
+	// Context, if not nil, is the context of all requests
+	// including retries. Once the context is done, no further
+	// attempt is made.
+	Context context.Context
+
+	// Balancer, if not nil, spreads requests across multiple
+	// server endpoints. If an endpoint is not reachable, the
+	// request is sent to the next endpoint immediately.
+	Balancer *loadBalancer
+}
 
 // Get issues a GET to the specified URL.
 // It is a wrapper around retry.Do.
@@ -79,9 +166,9 @@ func (r *retry) Post(url, contentType string, body io.ReadSeeker) (*http.Respons
 
 // Do sends an HTTP request and returns an HTTP response using
 // the underlying http.Client. If the request fails b/c of a
-// temporary error Do retries the request a few times. If the
-// request keeps failing, Do will give up and return a descriptive
-// error.
+// temporary error Do retries the request according to the
+// retry policy. If the request keeps failing, Do will give up
+// and return a descriptive error.
 func (r *retry) Do(req *http.Request) (*http.Response, error) {
 	type RetryReader interface {
 		io.Reader
@@ -108,21 +195,58 @@ func (r *retry) Do(req *http.Request) (*http.Response, error) {
 			// error since the caller has specified a wrong type.
 			panic("kes: request cannot be retried")
 		}
+
+		// The body is sent with a Content-Length such that
+		// the server can reject requests that exceed its body
+		// size limit without reading the body.
+		if req.ContentLength <= 0 {
+			size, err := body.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = body.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if size > 0 {
+				req.ContentLength = size
+			}
+		}
+	}
+	if r.Context != nil {
+		req = req.WithContext(r.Context)
+	}
+
+	// The idempotency key is set once such that all
+	// attempts of the request carry the same key.
+	if acceptsIdempotencyKey(req) && req.Header.Get(HeaderIdempotencyKey) == "" {
+		var key [16]byte
+		if _, err := io.ReadFull(cryptorand.Reader, key[:]); err != nil {
+			return nil, err
+		}
+		req.Header = req.Header.Clone()
+		req.Header.Set(HeaderIdempotencyKey, hex.EncodeToString(key[:]))
 	}
 
-	const (
-		MinRetryDelay     = 200 * time.Millisecond
-		MaxRandRetryDelay = 800
-	)
-	var (
-		retry  = 2 // For now, we retry 2 times before we give up
-		client = (*http.Client)(r)
-	)
-	resp, err := client.Do(req)
-	for retry > 0 && (isTemporary(err) || (resp != nil && resp.StatusCode == http.StatusServiceUnavailable)) {
-		randomRetryDelay := time.Duration(rand.Intn(MaxRandRetryDelay)) * time.Millisecond
-		time.Sleep(MinRetryDelay + randomRetryDelay)
-		retry--
+	maxRetries := r.Policy.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+	idempotent := isIdempotent(req)
+	resp, err := r.send(req, body)
+	for n := 0; n < maxRetries && shouldRetry(resp, err, idempotent); n++ {
+		timer := time.NewTimer(r.Policy.delay(n, resp))
+		if resp != nil {
+			// Drain and close the response body such that
+			// the connection can be reused.
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
 
 		// If there is a body we have to reset it. Otherwise, we may send
 		// only partial data to the server when we retry the request.
@@ -133,7 +257,7 @@ func (r *retry) Do(req *http.Request) (*http.Response, error) {
 			req.Body = body
 		}
 
-		resp, err = client.Do(req) // Now, retry.
+		resp, err = r.send(req, body) // Now, retry.
 	}
 	if isTemporary(err) {
 		// If the request still fails with a temporary error
@@ -148,6 +272,126 @@ func (r *retry) Do(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
+// send sends the request once using the underlying http.Client.
+//
+// If there is a load balancer, send sends the request to the
+// next endpoint. If the endpoint is not reachable, send takes it
+// offline and sends the request to another endpoint right away.
+// Since the request has not reached any server, this is safe for
+// non-idempotent requests as well.
+func (r *retry) send(req *http.Request, body io.ReadSeeker) (*http.Response, error) {
+	if r.Balancer == nil {
+		return r.Client.Do(req)
+	}
+	if r.Balancer.err != nil {
+		return nil, r.Balancer.err
+	}
+
+	var (
+		tried []*endpoint
+		resp  *http.Response
+		err   error
+	)
+	for e := r.Balancer.pick(&r.Client, tried); e != nil; e = r.Balancer.pick(&r.Client, tried) {
+		if len(tried) > 0 && body != nil {
+			if _, err = body.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			req.Body = ioutil.NopCloser(body)
+		}
+		tried = append(tried, e)
+
+		req.URL.Scheme, req.URL.Host = e.URL.Scheme, e.URL.Host
+		req.Host = e.URL.Host
+		resp, err = r.Client.Do(req)
+		if err == nil {
+			r.Balancer.markOnline(e)
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		if isDialError(err) || isTemporary(err) {
+			r.Balancer.markOffline(e)
+		}
+		if !isDialError(err) {
+			return nil, err
+		}
+	}
+	return resp, err
+}
+
+// shouldRetry reports whether a request should be retried
+// given its response or error and whether it is idempotent.
+func shouldRetry(resp *http.Response, err error, idempotent bool) bool {
+	if err != nil {
+		return idempotent && isTemporary(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true // The server has not processed the request
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	default:
+		return idempotent && resp.StatusCode >= 500
+	}
+}
+
+// HeaderIdempotencyKey is the HTTP header that contains
+// the idempotency key of a request that creates or deletes
+// a key. The server remembers the response of such a request
+// for a bounded time window and replays it when it receives
+// the same request with the same idempotency key again -
+// e.g. because the client retried it after a network error.
+//
+// The client sets a random idempotency key automatically.
+const HeaderIdempotencyKey = "Kes-Idempotency-Key"
+
+// acceptsIdempotencyKey reports whether the server
+// deduplicates the request based on its idempotency key.
+func acceptsIdempotencyKey(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost:
+		return strings.HasPrefix(req.URL.Path, "/v1/key/create/") || strings.HasPrefix(req.URL.Path, "/v1/key/import/")
+	case http.MethodDelete:
+		return strings.HasPrefix(req.URL.Path, "/v1/key/delete/")
+	default:
+		return false
+	}
+}
+
+// isIdempotent reports whether sending the request more
+// than once has the same effect as sending it once.
+//
+// Requests that create a resource, like a key, are not
+// idempotent since retrying such a request may fail with
+// an "already exists" error - unless the request carries
+// an idempotency key. See: HeaderIdempotencyKey
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		if acceptsIdempotencyKey(req) && req.Header.Get(HeaderIdempotencyKey) != "" {
+			return true
+		}
+		for _, api := range []string{
+			"/v1/key/create/",
+			"/v1/key/import/",
+			"/v1/admin/restore",
+			"/v1/enclave/create/",
+			"/v1/job/submit/",
+		} {
+			if strings.HasPrefix(req.URL.Path, api) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
 // isTemporary returns true if the given error is
 // temporary - e.g. a temporary *url.Error or an
 // net.Error that indicates that a request got
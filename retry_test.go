@@ -7,9 +7,13 @@ package kes
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 var retryBodyTests = []struct {
@@ -69,3 +73,125 @@ func TestIsTemporary(t *testing.T) {
 		}
 	}
 }
+
+var isIdempotentTests = []struct {
+	Method     string
+	Path       string
+	Idempotent bool
+}{
+	{Method: http.MethodGet, Path: "/v1/policy/list/*", Idempotent: true},
+	{Method: http.MethodDelete, Path: "/v1/key/delete/my-key", Idempotent: true},
+	{Method: http.MethodPost, Path: "/v1/key/generate/my-key", Idempotent: true},
+	{Method: http.MethodPost, Path: "/v1/key/create/my-key", Idempotent: false},
+	{Method: http.MethodPost, Path: "/v1/key/import/my-key", Idempotent: false},
+	{Method: http.MethodPatch, Path: "/v1/key/generate/my-key", Idempotent: false},
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for i, test := range isIdempotentTests {
+		req, err := http.NewRequest(test.Method, "https://127.0.0.1:7373"+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if idempotent := isIdempotent(req); idempotent != test.Idempotent {
+			t.Fatalf("Test %d: got %v - want %v", i, idempotent, test.Idempotent)
+		}
+	}
+}
+
+var shouldRetryTests = []struct {
+	Status     int
+	Idempotent bool
+	Retry      bool
+}{
+	{Status: http.StatusOK, Idempotent: true, Retry: false},
+	{Status: http.StatusNotFound, Idempotent: true, Retry: false},
+	{Status: http.StatusTooManyRequests, Idempotent: false, Retry: true},
+	{Status: http.StatusServiceUnavailable, Idempotent: false, Retry: true},
+	{Status: http.StatusBadGateway, Idempotent: true, Retry: true},
+	{Status: http.StatusBadGateway, Idempotent: false, Retry: false},
+	{Status: http.StatusNotImplemented, Idempotent: true, Retry: false},
+}
+
+func TestShouldRetry(t *testing.T) {
+	for i, test := range shouldRetryTests {
+		resp := &http.Response{StatusCode: test.Status}
+		if retry := shouldRetry(resp, nil, test.Idempotent); retry != test.Retry {
+			t.Fatalf("Test %d: got %v - want %v", i, retry, test.Retry)
+		}
+	}
+}
+
+func TestRetryDo(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "request" {
+			t.Errorf("Attempt %d: invalid request body: got '%s'", attempts, body)
+		}
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &retry{Policy: RetryPolicy{MinDelay: time.Millisecond}}
+	resp, err := client.Post(server.URL+"/v1/key/create/my-key", "text/plain", bytes.NewReader([]byte("request")))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("Invalid response: got status %d after %d attempts - want status %d after %d attempts", resp.StatusCode, attempts, http.StatusOK, 3)
+	}
+
+	attempts = 0
+	client.Policy.MaxRetries = -1
+	resp, err = client.Post(server.URL+"/v1/key/create/my-key", "text/plain", bytes.NewReader([]byte("request")))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Fatalf("Request has been retried although retries are disabled: %d attempts", attempts)
+	}
+}
+
+func TestRetryIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(HeaderIdempotencyKey))
+		if len(keys) < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &retry{Policy: RetryPolicy{MinDelay: time.Millisecond}}
+	resp, err := client.Post(server.URL+"/v1/key/create/my-key", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(keys) != 2 {
+		t.Fatalf("Invalid response: got status %d after %d attempts - want status %d after %d attempts", resp.StatusCode, len(keys), http.StatusOK, 2)
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("Retry has not been sent with the same idempotency key: got '%s' and '%s'", keys[0], keys[1])
+	}
+
+	keys = nil
+	resp, err = client.Post(server.URL+"/v1/key/generate/my-key", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if keys[0] != "" {
+		t.Fatalf("Idempotency key has been sent to an API that does not accept it: '%s'", keys[0])
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The HTTP headers of a signed request.
+const (
+	// HeaderSignatureDate is the HTTP header containing
+	// the RFC 3339 time at which the request has been
+	// signed.
+	HeaderSignatureDate = "Kes-Date"
+
+	// HeaderSignatureNonce is the HTTP header containing
+	// a random and unique value that allows a server to
+	// detect replayed requests.
+	HeaderSignatureNonce = "Kes-Nonce"
+
+	// HeaderSignature is the HTTP header containing
+	// the base64-encoded request signature.
+	HeaderSignature = "Kes-Signature"
+)
+
+// The HTTP headers of a signed response.
+const (
+	// HeaderResponseDate is the HTTP header containing
+	// the RFC 3339 time at which the response has been
+	// signed.
+	HeaderResponseDate = "Kes-Response-Date"
+
+	// HeaderResponseSignature is the HTTP header
+	// containing the base64-encoded response signature.
+	HeaderResponseSignature = "Kes-Response-Signature"
+)
+
+// ErrInvalidSignature is returned when a request
+// signature is not valid.
+var ErrInvalidSignature = errors.New("kes: invalid request signature")
+
+// ErrInvalidResponseSignature is returned when a response
+// signature is not valid. See: VerifyingTransport
+var ErrInvalidResponseSignature = errors.New("kes: invalid response signature")
+
+// SigningTransport is an http.RoundTripper that signs
+// each request with the private key of the client before
+// sending it to the server. See: SignRequest
+//
+// A KES server that requires signed requests verifies that
+// the request has been signed with the private key of the
+// client certificate and rejects replayed requests - even
+// if the TLS connection has been terminated by a proxy.
+type SigningTransport struct {
+	http.RoundTripper
+
+	// Signer is the private key of the client
+	// certificate. It must be an ECDSA, Ed25519
+	// or RSA private key.
+	Signer crypto.Signer
+}
+
+// RoundTrip signs the request and sends it using
+// the underlying http.RoundTripper.
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if err := SignRequest(req, body, t.Signer); err != nil {
+		return nil, err
+	}
+
+	transport := t.RoundTripper
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// SignRequest signs the HTTP request with the given body
+// using the signer. It sets the HeaderSignatureDate,
+// HeaderSignatureNonce and HeaderSignature headers.
+//
+// The signature covers the request method, the request
+// URI, the HeaderEnclave, If-Match, HeaderIdempotencyKey
+// and HeaderApproval headers, the date, the nonce and the
+// SHA-256 hash of the body. Hence, these headers must be
+// set before signing the request.
+func SignRequest(req *http.Request, body []byte, signer crypto.Signer) error {
+	var nonce [16]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return err
+	}
+	var (
+		date    = time.Now().UTC().Format(time.RFC3339)
+		message = signatureMessage(req, date, hex.EncodeToString(nonce[:]), body)
+	)
+
+	signature, err := sign(signer, message)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderSignatureDate, date)
+	req.Header.Set(HeaderSignatureNonce, hex.EncodeToString(nonce[:]))
+	req.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// VerifyRequest verifies that the HTTP request with the
+// given body has been signed by the private key that
+// corresponds to the given public key.
+//
+// It only verifies the signature itself. Checking the date
+// and nonce of the request - e.g. to detect replayed requests -
+// is left to the caller.
+func VerifyRequest(req *http.Request, body []byte, key crypto.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(req.Header.Get(HeaderSignature))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidSignature
+	}
+	message := signatureMessage(req, req.Header.Get(HeaderSignatureDate), req.Header.Get(HeaderSignatureNonce), body)
+	if !verify(key, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignResponse signs the response to the HTTP request with
+// the given status code and body using the signer. It sets the
+// HeaderResponseDate and HeaderResponseSignature headers.
+//
+// The signature covers the request method, the request URI,
+// the nonce sent by the client as HeaderSignatureNonce, the
+// date, the status code and the SHA-256 hash of the body.
+// Hence, a response cannot be replayed for another request
+// with a different nonce.
+func SignResponse(h http.Header, req *http.Request, status int, body []byte, signer crypto.Signer) error {
+	var (
+		date    = time.Now().UTC().Format(time.RFC3339)
+		message = responseSignatureMessage(req, date, status, body)
+	)
+	signature, err := sign(signer, message)
+	if err != nil {
+		return err
+	}
+	h.Set(HeaderResponseDate, date)
+	h.Set(HeaderResponseSignature, base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+// VerifyResponse verifies that the response to the HTTP
+// request with the given body has been signed by the private
+// key that corresponds to the given public key.
+//
+// The request should contain a random nonce as
+// HeaderSignatureNonce. Otherwise, a response to another
+// request for the same URI can be replayed.
+func VerifyResponse(req *http.Request, resp *http.Response, body []byte, key crypto.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(resp.Header.Get(HeaderResponseSignature))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidResponseSignature
+	}
+	message := responseSignatureMessage(req, resp.Header.Get(HeaderResponseDate), resp.StatusCode, body)
+	if !verify(key, message, signature) {
+		return ErrInvalidResponseSignature
+	}
+	return nil
+}
+
+// VerifyingTransport is an http.RoundTripper that verifies
+// that the responses of the data key APIs - i.e. generate,
+// encrypt, decrypt, re-encrypt and their bulk variants - have
+// been signed by the KES server. See: VerifyResponse
+//
+// Clients that talk to a KES server through intermediaries -
+// like proxies or service meshes - can use it to ensure that
+// key material has been returned by the KES deployment they
+// trust. The server must be configured with the private key
+// that corresponds to the PublicKey.
+type VerifyingTransport struct {
+	http.RoundTripper
+
+	// PublicKey is the public key of the
+	// server's response signing key.
+	PublicKey crypto.PublicKey
+}
+
+// RoundTrip sends the request using the underlying
+// http.RoundTripper and verifies the response signature.
+// If the request has no nonce, RoundTrip adds a random one.
+func (t *VerifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.RoundTripper
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if !isSignedResponsePath(req.URL.Path) {
+		return transport.RoundTrip(req)
+	}
+
+	if req.Header.Get(HeaderSignatureNonce) == "" {
+		var nonce [16]byte
+		if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderSignatureNonce, hex.EncodeToString(nonce[:]))
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	const MaxBody = 8 << 20 // Responses of the data key APIs are small
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBody))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = VerifyResponse(req, resp, body, t.PublicKey); err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// isSignedResponsePath reports whether the server signs
+// the responses of the API with the given path.
+func isSignedResponsePath(path string) bool {
+	for _, prefix := range []string{
+		"/v1/key/generate/",
+		"/v1/key/encrypt/",
+		"/v1/key/decrypt/",
+		"/v1/key/reencrypt/",
+		"/v1/key/bulk/generate/",
+		"/v1/key/bulk/decrypt/",
+	} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sign signs the message with the signer. It signs the
+// SHA-256 hash of the message unless the signer is an
+// Ed25519 private key.
+func sign(signer crypto.Signer, message []byte) ([]byte, error) {
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, message, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		})
+	default:
+		return nil, errors.New("kes: unsupported private key type")
+	}
+}
+
+// verify reports whether the signature of the message
+// has been created by the private key that corresponds
+// to the public key. See: sign
+func verify(key crypto.PublicKey, message, signature []byte) bool {
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, signature)
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) != 0 {
+			return false
+		}
+		if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+			return false
+		}
+		digest := sha256.Sum256(message)
+		return ecdsa.Verify(key, digest[:], sig.R, sig.S)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		err := rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		})
+		return err == nil
+	default:
+		return false
+	}
+}
+
+// signatureMessage returns the message that gets
+// signed resp. verified for the given request. It
+// contains all request headers that change what the
+// server does - e.g. which enclave it modifies.
+func signatureMessage(req *http.Request, date, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	var message bytes.Buffer
+	message.WriteString("kes-request-signature-v1\n")
+	message.WriteString(req.Method + "\n")
+	message.WriteString(req.URL.RequestURI() + "\n")
+	message.WriteString(req.Header.Get(HeaderEnclave) + "\n")
+	message.WriteString(req.Header.Get("If-Match") + "\n")
+	message.WriteString(req.Header.Get(HeaderIdempotencyKey) + "\n")
+	message.WriteString(strings.Join(req.Header[http.CanonicalHeaderKey(HeaderApproval)], ",") + "\n")
+	message.WriteString(date + "\n")
+	message.WriteString(nonce + "\n")
+	message.WriteString(hex.EncodeToString(bodyHash[:]))
+	return message.Bytes()
+}
+
+// responseSignatureMessage returns the message that
+// gets signed resp. verified for the response to the
+// given request.
+func responseSignatureMessage(req *http.Request, date string, status int, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	var message bytes.Buffer
+	message.WriteString("kes-response-signature-v1\n")
+	message.WriteString(req.Method + "\n")
+	message.WriteString(req.URL.RequestURI() + "\n")
+	message.WriteString(req.Header.Get(HeaderSignatureNonce) + "\n")
+	message.WriteString(date + "\n")
+	message.WriteString(strconv.Itoa(status) + "\n")
+	message.WriteString(hex.EncodeToString(bodyHash[:]))
+	return message.Bytes()
+}
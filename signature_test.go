@@ -0,0 +1,146 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignRequest(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	for i, signer := range []crypto.Signer{edKey, ecKey, rsaKey} {
+		req, err := http.NewRequest(http.MethodPost, "https://127.0.0.1:7373/v1/key/create/my-key", nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		body := []byte(`{"plaintext":"..."}`)
+		if err = SignRequest(req, body, signer); err != nil {
+			t.Fatalf("Test %d: failed to sign request: %v", i, err)
+		}
+		if err = VerifyRequest(req, body, signer.Public()); err != nil {
+			t.Fatalf("Test %d: failed to verify request: %v", i, err)
+		}
+
+		if err = VerifyRequest(req, []byte(`{}`), signer.Public()); err != ErrInvalidSignature {
+			t.Fatalf("Test %d: verified request with modified body", i)
+		}
+		req.URL.Path = "/v1/key/delete/my-key"
+		if err = VerifyRequest(req, body, signer.Public()); err != ErrInvalidSignature {
+			t.Fatalf("Test %d: verified request with modified path", i)
+		}
+		req.URL.Path = "/v1/key/create/my-key"
+
+		for _, header := range []string{HeaderEnclave, "If-Match", HeaderIdempotencyKey, HeaderApproval} {
+			modified := req.Clone(req.Context())
+			modified.Header.Add(header, "my-value")
+			if err = VerifyRequest(modified, body, signer.Public()); err != ErrInvalidSignature {
+				t.Fatalf("Test %d: verified request with modified %s header", i, header)
+			}
+		}
+	}
+}
+
+func TestSignResponse(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	for i, signer := range []crypto.Signer{edKey, ecKey} {
+		req, err := http.NewRequest(http.MethodPost, "https://127.0.0.1:7373/v1/key/generate/my-key", nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		req.Header.Set(HeaderSignatureNonce, "0123456789abcdef")
+
+		body := []byte(`{"plaintext":"...","ciphertext":"..."}`)
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		if err = SignResponse(resp.Header, req, resp.StatusCode, body, signer); err != nil {
+			t.Fatalf("Test %d: failed to sign response: %v", i, err)
+		}
+		if err = VerifyResponse(req, resp, body, signer.Public()); err != nil {
+			t.Fatalf("Test %d: failed to verify response: %v", i, err)
+		}
+
+		if err = VerifyResponse(req, resp, []byte(`{}`), signer.Public()); err != ErrInvalidResponseSignature {
+			t.Fatalf("Test %d: verified response with modified body", i)
+		}
+		req.Header.Set(HeaderSignatureNonce, "fedcba9876543210")
+		if err = VerifyResponse(req, resp, body, signer.Public()); err != ErrInvalidResponseSignature {
+			t.Fatalf("Test %d: verified response to another request", i)
+		}
+	}
+}
+
+func TestVerifyingTransport(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	const Body = `{"plaintext":"..."}`
+	var signer crypto.Signer = private
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := SignResponse(w.Header(), r, http.StatusOK, []byte(Body), signer); err != nil {
+			t.Errorf("Failed to sign response: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(Body))
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &VerifyingTransport{PublicKey: public},
+	}
+	resp, err := client.Post(server.URL+"/v1/key/decrypt/my-key", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to verify response: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	if string(body) != Body {
+		t.Fatalf("Invalid response body: got '%s' - want '%s'", body, Body)
+	}
+
+	signer = other // Responses signed by another key must be rejected
+	if _, err = client.Post(server.URL+"/v1/key/decrypt/my-key", "application/json", nil); err == nil {
+		t.Fatal("Verified response signed by another key")
+	}
+	if _, err = client.Get(server.URL + "/v1/key/describe/my-key"); err != nil {
+		t.Fatalf("Failed to send request to API without signed responses: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// WatchEvent describes a change of a key or policy
+// observed by the server. See: Client.WatchKeys and
+// Client.WatchPolicies
+//
+// The type is either "key" or "policy". The action of
+// a key event is one of: "created", "deleted", "disabled",
+// "enabled" or "rotated". The action of a policy event is
+// either "written" or "deleted".
+type WatchEvent struct {
+	Type   string    `json:"type"`
+	Action string    `json:"action"`
+	Name   string    `json:"name"`
+	Target string    `json:"target,omitempty"` // The key an alias refers to after a rotation
+	Time   time.Time `json:"time"`
+}
+
+// WatchStream iterates over a stream of change events sent
+// by the server. Successive calls to the Next method step
+// through the events. The stream does not end until the
+// server or client closes it.
+//
+// If the client does not keep up with the events, the
+// server ends the stream with an error. Then, the client
+// should re-synchronize - e.g. via ListKeys - before it
+// watches again.
+//
+// Closing a WatchStream closes the underlying response
+// body and any subsequent call to Next returns false.
+type WatchStream struct {
+	decoder *json.Decoder
+	closer  io.Closer
+
+	event  WatchEvent
+	err    error
+	closed bool
+}
+
+// Event returns the most recent WatchEvent generated
+// by a call to Next.
+func (s *WatchStream) Event() WatchEvent { return s.event }
+
+// Err returns the first non-EOF error that was encountered
+// while iterating over the stream - including any error the
+// server has sent while streaming the events.
+//
+// Err does not return any error returned from Close.
+func (s *WatchStream) Err() error { return s.err }
+
+// Next advances the stream to the next WatchEvent, which
+// will then be available through the Event method. It blocks
+// until the server sends the next event and returns false
+// when the stream iteration stops - i.e. by reaching the end
+// of the stream, closing the stream or in case of an error.
+func (s *WatchStream) Next() bool {
+	if s.err != nil || s.closed {
+		return false
+	}
+
+	var line struct {
+		WatchEvent
+		Error string `json:"error"`
+	}
+	if err := s.decoder.Decode(&line); err != nil {
+		if err != io.EOF && !s.closed {
+			s.err = err
+		}
+		return false
+	}
+	if line.Error != "" {
+		s.err = errors.New("kes: watch stream ended: " + line.Error)
+		return false
+	}
+	s.event = line.WatchEvent
+	return true
+}
+
+// Close closes the underlying stream. After Close has
+// been called once the Next method returns false.
+func (s *WatchStream) Close() error {
+	s.closed = true
+	return s.closer.Close()
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const watchStream = `{"type":"key","action":"created","name":"my-key","time":"2020-10-01T12:00:00Z"}
+{"type":"key","action":"rotated","name":"my-alias","target":"my-key","time":"2020-10-01T12:00:01Z"}
+{"error":"events lost: client is too slow"}
+`
+
+func TestWatchStream(t *testing.T) {
+	body := ioutil.NopCloser(strings.NewReader(watchStream))
+	stream := &WatchStream{decoder: json.NewDecoder(body), closer: body}
+	defer stream.Close()
+
+	var events []WatchEvent
+	for stream.Next() {
+		events = append(events, stream.Event())
+	}
+	if len(events) != 2 {
+		t.Fatalf("Invalid number of events: got %d - want %d", len(events), 2)
+	}
+	if events[1].Action != "rotated" || events[1].Target != "my-key" {
+		t.Fatalf("Invalid event: got %+v", events[1])
+	}
+	if stream.Err() == nil {
+		t.Fatal("Stream error has not been reported")
+	}
+}